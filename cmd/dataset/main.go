@@ -0,0 +1,109 @@
+// Command dataset exports the extraction output as a conversation dataset for machine learning:
+// train.jsonl, validation.jsonl and test.jsonl, one topic per line with its posts as ordered
+// turns (author, timestamp, text). Quoted text is removed from replies and replaced by a
+// reference to the quoted turn. Topics are split by a deterministic shuffle, so the same -seed
+// always gives the same splits.
+//
+// Usage:
+//
+//	dataset -output output_data -out dataset -titles data/topic_indices
+//	dataset -output output_data -out dataset -min 3 -subforums 66,54 -split 0.9,0.05,0.05 -seed 1
+//	dataset -output output_data -out dataset -exclude 12 -split 1,0,0
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"project-waypoint/pkg/completeness"
+	"project-waypoint/pkg/dataset"
+)
+
+func main() {
+	outputDir := flag.String("output", "output_data", "Extraction output tree")
+	destDir := flag.String("out", "", "Directory to write the dataset to (required)")
+	titlesDir := flag.String("titles", "", "Directory of topic index JSON files to take topic titles from")
+	minTurns := flag.Int("min", 2, "Leave out topics with fewer posts")
+	subForums := flag.String("subforums", "", "Comma-separated sub-forum IDs to export (default all)")
+	exclude := flag.String("exclude", "", "Comma-separated sub-forum IDs to leave out")
+	split := flag.String("split", "0.8,0.1,0.1", "Shares of topics in train, validation and test")
+	seed := flag.Int64("seed", 42, "Seed of the shuffle that assigns topics to splits")
+	flag.Parse()
+
+	if *destDir == "" {
+		fmt.Fprintf(os.Stderr, "Error: -out is required.\n")
+		flag.Usage()
+		os.Exit(2)
+	}
+	shares, err := parseSplit(*split)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var titles map[string]string
+	if *titlesDir != "" {
+		topics, err := completeness.LoadTopicIndex(*titlesDir)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		titles = make(map[string]string, len(topics))
+		for _, topic := range topics {
+			titles[topic.ID] = topic.Title
+		}
+	}
+
+	stats, err := dataset.Export(dataset.Options{
+		OutputDir:        *outputDir,
+		DestDir:          *destDir,
+		Titles:           titles,
+		MinTurns:         *minTurns,
+		SubForums:        list(*subForums),
+		ExcludeSubForums: list(*exclude),
+		Train:            shares[0],
+		Validation:       shares[1],
+		Test:             shares[2],
+		Seed:             *seed,
+	})
+	if err != nil {
+		log.Fatalf("[ERROR] Dataset export failed: %v", err)
+	}
+	for _, name := range []string{dataset.SplitTrain, dataset.SplitValidation, dataset.SplitTest} {
+		if s, ok := stats.Splits[name]; ok {
+			log.Printf("[INFO] %s: %d conversations, %d turns.", name, s.Conversations, s.Turns)
+		}
+	}
+	log.Printf("[INFO] %d quotes, %d replaced by a reference to the quoted turn.", stats.Quotes, stats.ResolvedQuotes)
+}
+
+// parseSplit reads "train,validation,test" shares; missing trailing shares are zero.
+func parseSplit(value string) ([3]float64, error) {
+	var shares [3]float64
+	parts := strings.Split(value, ",")
+	if len(parts) > 3 {
+		return shares, fmt.Errorf("-split takes at most three shares, got %q", value)
+	}
+	for i, part := range parts {
+		share, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || share < 0 {
+			return shares, fmt.Errorf("invalid share %q in -split", part)
+		}
+		shares[i] = share
+	}
+	return shares, nil
+}
+
+func list(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
@@ -0,0 +1,58 @@
+// Command extraction_diff compares two extraction output trees (the OutputJSONPath directories
+// written by orchestrator.ProcessTopic), matching posts by post ID. It reports added, missing and
+// changed posts and fields, aggregated by field and sub-forum.
+//
+// Usage:
+//
+//	extraction_diff -old output_data/v1 -new output_data/v2
+//	extraction_diff -old output_data/v1 -new output_data/v2 -json > diff.json
+//
+// Like diff(1), the command exits with status 1 if the trees differ and 2 on errors.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"project-waypoint/pkg/outputdiff"
+)
+
+func main() {
+	oldDir := flag.String("old", "", "Output tree of the earlier extraction (required)")
+	newDir := flag.String("new", "", "Output tree of the new extraction (required)")
+	jsonOutput := flag.Bool("json", false, "Print the full report as JSON")
+	maxPosts := flag.Int("posts", 50, "Number of post differences to list in the text report (-1 for all)")
+	flag.Parse()
+
+	if *oldDir == "" || *newDir == "" {
+		fmt.Fprintf(os.Stderr, "Error: both -old and -new are required.\n")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	report, err := outputdiff.Compare(*oldDir, *newDir)
+	if err != nil {
+		log.Printf("[ERROR] Failed to compare output trees: %v", err)
+		os.Exit(2)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = outputdiff.WriteText(os.Stdout, report, *maxPosts)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to write report: %v", err)
+		os.Exit(2)
+	}
+
+	if report.HasDifferences() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,112 @@
+// Command extraction_errors summarizes the error ledger written by the extraction orchestrator
+// (OrchestratorConfig.ErrorLedgerPath): how many posts were dropped or degraded, at which stage,
+// in which sub-forums, and the most frequent kinds of error with an example post for each.
+//
+// Usage:
+//
+//	extraction_errors -ledger logs/extraction_errors.jsonl
+//	extraction_errors -ledger logs/extraction_errors.jsonl -stage metadata -subforum 66
+//	extraction_errors -ledger logs/extraction_errors.jsonl -json > summary.json
+//
+// The offending post HTML of an entry can be printed with -show page_file#post_index, using an
+// example from the summary.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"project-waypoint/pkg/errorledger"
+)
+
+func main() {
+	ledgerPath := flag.String("ledger", "", "Error ledger (JSON Lines) to summarize (required)")
+	stage := flag.String("stage", "", "Only include entries of this stage (e.g. metadata, clean_text)")
+	subForum := flag.String("subforum", "", "Only include entries of this sub-forum ID")
+	outcome := flag.String("outcome", "", "Only include entries with this outcome (dropped or degraded)")
+	maxErrors := flag.Int("errors", 20, "Number of error kinds to list in the text summary (-1 for all)")
+	jsonOutput := flag.Bool("json", false, "Print the summary as JSON")
+	show := flag.String("show", "", "Print the entry and post HTML for page_file#post_index instead of a summary")
+	flag.Parse()
+
+	if *ledgerPath == "" {
+		fmt.Fprintf(os.Stderr, "Error: -ledger is required.\n")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	entries, err := errorledger.Load(*ledgerPath)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to load error ledger: %v", err)
+	}
+
+	var selected []errorledger.Entry
+	for _, entry := range entries {
+		if (*stage == "" || entry.Stage == *stage) &&
+			(*subForum == "" || entry.SubForumID == *subForum) &&
+			(*outcome == "" || entry.Outcome == *outcome) {
+			selected = append(selected, entry)
+		}
+	}
+
+	if *show != "" {
+		if err := showEntries(selected, *show); err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		return
+	}
+
+	summary := errorledger.Summarize(selected)
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(summary)
+	} else {
+		err = errorledger.WriteText(os.Stdout, summary, *maxErrors)
+	}
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to write summary: %v", err)
+	}
+}
+
+// showEntries prints every entry recorded for the post identified by ref ("page_file#post_index").
+func showEntries(entries []errorledger.Entry, ref string) error {
+	sep := strings.LastIndex(ref, "#")
+	if sep < 0 {
+		return fmt.Errorf("invalid -show value %q, expected page_file#post_index", ref)
+	}
+	pageFile := ref[:sep]
+	postIndex, err := strconv.Atoi(ref[sep+1:])
+	if err != nil {
+		return fmt.Errorf("invalid post index in -show value %q: %w", ref, err)
+	}
+
+	found := 0
+	for _, entry := range entries {
+		if entry.PageFile != pageFile || entry.PostIndex != postIndex {
+			continue
+		}
+		found++
+		fmt.Printf("%s %s at stage %s (topic %s, post %q), recorded %s\n",
+			entry.PageFile, entry.Outcome, entry.Stage, entry.TopicID, entry.PostID, entry.RecordedAt.Format("2006-01-02 15:04:05"))
+		fmt.Printf("Error: %s\n", entry.Error)
+		if entry.HTMLSHA256 != "" {
+			fmt.Printf("HTML: %d bytes, sha256 %s", entry.HTMLBytes, entry.HTMLSHA256)
+			if entry.HTMLTruncated {
+				fmt.Printf(" (first %d bytes stored)", len(entry.HTML))
+			}
+			fmt.Printf("\n%s\n", entry.HTML)
+		}
+		fmt.Println()
+	}
+	if found == 0 {
+		return fmt.Errorf("no ledger entries for %s", ref)
+	}
+	return nil
+}
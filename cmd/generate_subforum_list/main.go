@@ -8,10 +8,11 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
+
+	"waypoint_archive_scripts/pkg/canonurl"
 )
 
 // SubForum holds the extracted information for a sub-forum
@@ -30,9 +31,11 @@ type SubForum struct {
 func main() {
 	outputDirFlag := flag.String("outputDir", "data", "Directory to save the output CSV file")
 	outputFileFlag := flag.String("outputFile", "subforum_list.csv", "Name of the output CSV file")
+	inputFileFlag := flag.String("inputFile", filepath.Join("bmad-agent", "forum_front_page.html"), "Saved forum front page HTML to read")
+	baseURLFlag := flag.String("baseURL", canonurl.DefaultBaseURL, "Forum root the front page's relative links are resolved against")
 	flag.Parse()
 
-	inputFileValue := "bmad-agent\\forum_front_page.html" // Hardcoded path
+	inputFileValue := *inputFileFlag
 
 	file, err := os.Open(inputFileValue)
 	if err != nil {
@@ -59,17 +62,16 @@ func main() {
 				sf.Name = strings.TrimSpace(linkSel.Text())
 				baseURL, _ := linkSel.Attr("href")
 				if baseURL != "" {
-					// Make URL absolute if it's relative
-					if !strings.HasPrefix(baseURL, "http") {
-						sf.BaseURL = "https://www.themagiccafe.com/forums/" + baseURL
-					} else {
-						sf.BaseURL = baseURL
+					// Resolve relative links against the forum root and store the canonical listing URL,
+					// so the sub-forum list, the indexer and the archiver agree on URLs and IDs
+					if u, err := url.Parse(*baseURLFlag); err == nil {
+						if abs, err := u.Parse(baseURL); err == nil {
+							sf.BaseURL = abs.String()
+						}
 					}
-					// Extract ID from BaseURL
-					u, err := url.Parse(sf.BaseURL)
-					if err == nil {
-						q := u.Query()
-						sf.ID = q.Get("forum")
+					if ref, err := canonurl.Parse(sf.BaseURL); err == nil && ref.Kind == canonurl.KindForum {
+						sf.BaseURL = ref.String()
+						sf.ID = string(ref.SubForumID)
 					}
 				}
 
@@ -106,12 +108,10 @@ func main() {
 						lastPostLinkSel := lastActiveCell.Find("span.smalltext a.b[href*='viewtopic.php']")
 						if lastPostLinkSel.Length() > 0 {
 							lastPostURL, _ := lastPostLinkSel.Attr("href")
-							u, err := url.Parse(lastPostURL)
-							if err == nil {
-								q := u.Query()
-								sf.LastPostID = q.Get("post")
+							if ref, err := canonurl.Parse(lastPostURL); err == nil {
+								sf.LastPostID = string(ref.PostID)
 								if sf.LastPostID == "" { // sometimes it's in topic=123&post=456 format
-									sf.LastPostID = q.Get("topic") // fallback, less ideal
+									sf.LastPostID = string(ref.TopicID) // fallback, less ideal
 								}
 							}
 						}
@@ -186,13 +186,11 @@ func main() {
 	log.Printf("Successfully parsed %d unique sub-forums and wrote to %s", len(uniqueSubForums), outputPath)
 }
 
-// Helper to extract forum ID, more robustly if needed
-var forumIDRegex = regexp.MustCompile(`forum=(\d+)`)
-
+// extractForumID returns the sub-forum ID of a forum URL, as parsed by canonurl.
 func extractForumID(urlStr string) string {
-	matches := forumIDRegex.FindStringSubmatch(urlStr)
-	if len(matches) > 1 {
-		return matches[1]
+	ref, err := canonurl.Parse(urlStr)
+	if err != nil {
+		return ""
 	}
-	return ""
+	return string(ref.SubForumID)
 }
@@ -2,9 +2,21 @@ module cmd
 
 go 1.24.3
 
-require github.com/PuerkitoBio/goquery v1.10.3
+require (
+	github.com/PuerkitoBio/goquery v1.10.3
+	internal v0.0.0-00010101000000-000000000000
+	project-waypoint v0.0.0-00010101000000-000000000000
+	waypoint_archive_scripts v0.0.0-00010101000000-000000000000
+)
 
 require (
 	github.com/andybalholm/cascadia v1.3.3 // indirect
 	golang.org/x/net v0.39.0 // indirect
+	golang.org/x/text v0.25.0 // indirect
 )
+
+replace waypoint_archive_scripts => ../waypoint_archive_scripts
+
+replace project-waypoint => ../
+
+replace internal => ../internal
@@ -0,0 +1,114 @@
+// Command graph builds interaction graphs from the extraction output for network analysis in
+// Gephi or networkx: a user graph, with an edge from each user to the users they quote or reply
+// to, and a topic graph, with an edge from each topic to the topics its posts link to. Edges are
+// weighted by the number of interactions and carry the timestamps of the first and last one.
+//
+// It writes users.graphml, users.gexf and users_edges.csv, and topics.graphml, topics.gexf and
+// topics_edges.csv, for the formats asked for.
+//
+// Usage:
+//
+//	graph -output output_data -titles data/topic_indices -out graphs
+//	graph -output output_data -out graphs -formats gexf
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"project-waypoint/pkg/completeness"
+	"project-waypoint/pkg/graph"
+
+	"waypoint_archive_scripts/pkg/forumadapter"
+)
+
+func main() {
+	outputDir := flag.String("output", "output_data", "Extraction output tree to build the graphs from")
+	outDir := flag.String("out", "", "Directory to write the graph files to (required)")
+	titlesDir := flag.String("titles", "", "Directory of topic index JSON files to label topics with their titles")
+	formats := flag.String("formats", "graphml,gexf,csv", "Comma-separated output formats (graphml, gexf, csv)")
+	engine := flag.String("engine", forumadapter.MagicCafeName, "Forum software the archive was fetched from ("+strings.Join(forumadapter.Names(), ", ")+")")
+	flag.Parse()
+
+	if *outDir == "" {
+		fmt.Fprintf(os.Stderr, "Error: -out is required.\n")
+		flag.Usage()
+		os.Exit(2)
+	}
+	var selected []string
+	for _, format := range strings.Split(*formats, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format == "" {
+			continue
+		}
+		if _, ok := graph.Formats[format]; !ok {
+			log.Fatalf("[ERROR] Unknown format %q (use graphml, gexf or csv)", format)
+		}
+		selected = append(selected, format)
+	}
+	adapter, err := forumadapter.Lookup(*engine)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	var titles map[string]string
+	if *titlesDir != "" {
+		topics, err := completeness.LoadTopicIndex(*titlesDir)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		titles = make(map[string]string, len(topics))
+		for _, topic := range topics {
+			titles[topic.ID] = topic.Title
+		}
+	}
+
+	users, topics, err := graph.Build(graph.Options{OutputDir: *outputDir, Titles: titles, Adapter: adapter})
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalf("[ERROR] Failed to create %s: %v", *outDir, err)
+	}
+	for _, g := range []*graph.Graph{users, topics} {
+		for _, format := range selected {
+			path := filepath.Join(*outDir, fileName(g.Name, format))
+			if err := writeFile(path, g, graph.Formats[format]); err != nil {
+				log.Fatalf("[ERROR] %v", err)
+			}
+		}
+		log.Printf("[INFO] %s graph: %d nodes, %d edges.", g.Name, len(g.Nodes()), len(g.Edges()))
+	}
+	log.Printf("[INFO] Graphs written to %s.", *outDir)
+}
+
+// fileName returns the file a graph is written to in a format; the CSV holds only the edges.
+func fileName(name string, format string) string {
+	if format == "csv" {
+		return name + "_edges.csv"
+	}
+	return name + "." + format
+}
+
+func writeFile(path string, g *graph.Graph, write func(io.Writer, *graph.Graph) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	writer := bufio.NewWriter(file)
+	if err := write(writer, g); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
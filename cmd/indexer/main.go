@@ -7,7 +7,8 @@ import (
 	"log"
 	"os"            // New: For ordered output
 	"path/filepath" // New: For joining paths
-	"time"          // Added for politeness delay
+	"strings"
+	"time" // Added for politeness delay
 
 	// "sort" // Will be needed later for ordered output if desired
 
@@ -16,6 +17,10 @@ import (
 	"internal/indexer/navigation"
 	"internal/indexer/storage" // Corrected
 	"internal/indexer/topic"   // Corrected
+
+	"waypoint_archive_scripts/pkg/cassette"
+	"waypoint_archive_scripts/pkg/forumadapter"
+	"waypoint_archive_scripts/pkg/respcache"
 )
 
 // Configuration struct to hold all configurable parameters
@@ -25,6 +30,13 @@ type Config struct {
 	requestDelay int // in milliseconds
 	logLevel     string
 	maxPages     int // New: Max pages to process for testing; 0 for no limit
+	cassettePath string
+	cassetteMode string // off, record or replay
+	cacheDir     string // Shared response cache directory; empty disables caching
+	listingTTL   time.Duration
+	topicTTL     time.Duration
+	engine       string // Forum adapter: magiccafe or phpbb3
+	profilesFile string // Selector profiles for the magiccafe adapter; empty uses the built-in profile
 }
 
 // loadConfig loads configuration from command-line flags
@@ -36,9 +48,27 @@ func loadConfig() Config {
 	flag.IntVar(&cfg.requestDelay, "delay", 1000, "Delay between HTTP requests in milliseconds")
 	flag.StringVar(&cfg.logLevel, "loglevel", "INFO", "Logging verbosity (DEBUG, INFO, WARNING, ERROR)")
 	flag.IntVar(&cfg.maxPages, "maxpages", 0, "Maximum number of pages to process (0 for no limit, for testing)") // New flag
+	flag.StringVar(&cfg.cassettePath, "cassette", "", "Path to an HTTP cassette to record to or replay from")
+	flag.StringVar(&cfg.cassetteMode, "cassettemode", "off", "HTTP cassette mode (off, record, replay)")
+	flag.StringVar(&cfg.cacheDir, "cachedir", "", "Shared response cache directory, also used by the archiver (empty disables caching)")
+	flag.DurationVar(&cfg.listingTTL, "cachelistingttl", 30*time.Minute, "How long cached sub-forum listing pages stay fresh")
+	flag.DurationVar(&cfg.topicTTL, "cachetopicttl", 6*time.Hour, "How long cached topic pages stay fresh")
+	flag.StringVar(&cfg.engine, "engine", forumadapter.MagicCafeName, "Forum software the sub-forum runs ("+strings.Join(forumadapter.Names(), ", ")+")")
+	flag.StringVar(&cfg.profilesFile, "profiles", "", "JSON file of selector profiles for the magiccafe engine (empty uses the built-in profile)")
 
 	flag.Parse()
 
+	if _, err := cassette.ParseMode(cfg.cassetteMode); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+	if _, err := forumadapter.Lookup(cfg.engine); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+
 	if cfg.subForumURL == "" {
 		// Use standard log here as our logger might not be initialized yet, or for early critical errors.
 		fmt.Fprintf(os.Stderr, "Error: Target sub-forum URL (-url) is required.\n")
@@ -178,6 +208,57 @@ func main() {
 		cfg.subForumURL, cfg.outputDir, cfg.requestDelay, cfg.logLevel, cfg.maxPages)
 	logger.Infof("Logs will also be written to: %s", logFilePath)
 
+	// Navigation and topic extraction read listing pages through the process-wide forum adapter
+	adapter, _ := forumadapter.Lookup(cfg.engine)
+	if cfg.profilesFile != "" {
+		var err error
+		if adapter, err = forumadapter.WithProfilesFile(adapter, cfg.profilesFile); err != nil {
+			logger.Fatalf("Failed to load selector profiles: %v", err)
+		}
+	}
+	forumadapter.SetDefault(adapter)
+	logger.Infof("Forum adapter: %s", adapter.Name())
+
+	// Route every fetch (navigation.FetchHTML goes through http.DefaultTransport) via the cassette, if enabled
+	if mode, _ := cassette.ParseMode(cfg.cassetteMode); mode != cassette.ModeOff {
+		httpCassette, err := cassette.Open(cfg.cassettePath, mode, nil)
+		if err != nil {
+			logger.Fatalf("Failed to open HTTP cassette %s: %v", cfg.cassettePath, err)
+		}
+		cassette.Install(httpCassette)
+		defer func() {
+			if err := httpCassette.Close(); err != nil {
+				logger.Errorf("Failed to close HTTP cassette %s: %v", cfg.cassettePath, err)
+			}
+		}()
+		logger.Infof("HTTP cassette: mode=%s, path=%s", mode, cfg.cassettePath)
+	}
+
+	// Listing pages go through the shared response cache, so JIT refresh and the archiver can reuse them.
+	// rescanFetchHTML bypasses it for the first-page re-scan, which must see the live page.
+	rescanFetchHTML := navigation.FetchHTML
+	if cfg.cacheDir != "" {
+		responseCache, err := respcache.NewCache(cfg.cacheDir, map[respcache.Class]time.Duration{
+			respcache.ClassListing: cfg.listingTTL,
+			respcache.ClassTopic:   cfg.topicTTL,
+		})
+		if err != nil {
+			logger.Fatalf("Failed to open response cache %s: %v", cfg.cacheDir, err)
+		}
+		uncachedFetchHTML := navigation.FetchHTML
+		navigation.FetchHTML = func(url string, delay time.Duration) (string, error) {
+			return responseCache.Fetch(url, func() (string, error) { return uncachedFetchHTML(url, delay) })
+		}
+		rescanFetchHTML = func(url string, delay time.Duration) (string, error) {
+			return responseCache.Refresh(url, func() (string, error) { return uncachedFetchHTML(url, delay) })
+		}
+		defer func() {
+			hits, misses := responseCache.Stats()
+			logger.Infof("Response cache served %d page(s) and missed %d.", hits, misses)
+		}()
+		logger.Infof("Response cache: dir=%s, listingTTL=%s, topicTTL=%s", cfg.cacheDir, cfg.listingTTL, cfg.topicTTL)
+	}
+
 	tracker := metrics.NewMetricsTracker()
 	// Defer final metrics logging for the very end, even if panics occur (though log.Fatalf will exit)
 	// For robust panic handling, a more complex setup might be needed, but this covers normal exit.
@@ -212,7 +293,7 @@ func main() {
 		time.Sleep(time.Duration(cfg.requestDelay) * time.Millisecond)
 
 		tracker.IncrementHTTPRequests() // Track request for first-page re-scan
-		firstPageHTML, err := navigation.FetchHTML(firstPageURL, time.Duration(cfg.requestDelay)*time.Millisecond)
+		firstPageHTML, err := rescanFetchHTML(firstPageURL, time.Duration(cfg.requestDelay)*time.Millisecond)
 		if err != nil {
 			tracker.IncrementFailedRequests()
 			logger.Warnf("Orchestrator: Warning - Failed to fetch first page for re-scan (%s): %v. Proceeding with full scan results only.", firstPageURL, err)
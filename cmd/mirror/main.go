@@ -0,0 +1,78 @@
+// Command mirror turns the raw HTML archive into a static offline mirror: a plain folder of
+// HTML files that can be opened from disk or published on any web server. Archived topic pages
+// keep the forum's look; links between archived pages are rewritten to relative paths, links to
+// topics and sub-forums that were not archived point at the live forum and are marked external,
+// and scripts, forms, ads and navigation that needs the live forum are removed. Sub-forum
+// listings are generated from the topic index. With -assets and -assetmanifest (the store
+// collect_assets fills), images, avatars, stylesheets and attachments are copied into the mirror
+// and load from there.
+//
+// Usage:
+//
+//	mirror -archive archive_output -index data/topic_indices -out mirror
+//	mirror -archive archive_output -index data/topic_indices -subforums data/subforum_list.csv -out mirror -strip 'div.sponsor,#banner'
+//	mirror -archive archive_output -out mirror -assets archive_assets -assetmanifest asset_manifest.json
+//
+// Open mirror/index.html to browse it.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"project-waypoint/pkg/mirror"
+
+	"waypoint_archive_scripts/pkg/forumadapter"
+)
+
+func main() {
+	archiveDir := flag.String("archive", "archive_output", "Root directory of the raw HTML archive")
+	indexDir := flag.String("index", "data/topic_indices", "Directory of topic index JSON files")
+	subForumList := flag.String("subforums", "", "Sub-forum list (CSV or JSON) for sub-forum names")
+	outputDir := flag.String("out", "", "Directory to write the mirror to (required)")
+	engine := flag.String("engine", forumadapter.MagicCafeName, "Forum software the archive was fetched from ("+strings.Join(forumadapter.Names(), ", ")+")")
+	strip := flag.String("strip", "", "Comma-separated CSS selectors of further elements to remove from archived pages")
+	assetDir := flag.String("assets", "", "Directory of the archived asset store (optional)")
+	assetManifest := flag.String("assetmanifest", "asset_manifest.json", "Asset manifest mapping asset URLs to stored files, used with -assets")
+	flag.Parse()
+
+	if *outputDir == "" {
+		fmt.Fprintf(os.Stderr, "Error: -out is required.\n")
+		flag.Usage()
+		os.Exit(2)
+	}
+	adapter, err := forumadapter.Lookup(*engine)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	selectors := append([]string{}, mirror.DefaultStripSelectors...)
+	for _, selector := range strings.Split(*strip, ",") {
+		if selector = strings.TrimSpace(selector); selector != "" {
+			selectors = append(selectors, selector)
+		}
+	}
+
+	stats, err := mirror.Generate(mirror.Options{
+		ArchiveDir:       *archiveDir,
+		TopicIndexDir:    *indexDir,
+		SubForumListPath: *subForumList,
+		OutputDir:        *outputDir,
+		Adapter:          adapter,
+		StripSelectors:   selectors,
+
+		AssetDir:          *assetDir,
+		AssetManifestPath: *assetManifest,
+	})
+	if err != nil {
+		log.Fatalf("[ERROR] Mirror generation failed: %v", err)
+	}
+	log.Printf("[INFO] Mirror written to %s: %d topic pages, %d sub-forum listing pages.", *outputDir, stats.TopicPages, stats.SubForumPages)
+	log.Printf("[INFO] Links: %d rewritten to the mirror, %d marked external, %d dead navigation links removed. %d elements stripped.",
+		stats.LocalLinks, stats.ExternalLinks, stats.DeadLinks, stats.Stripped)
+	if *assetDir != "" {
+		log.Printf("[INFO] %d archived assets copied into the mirror.", stats.Assets)
+	}
+}
@@ -0,0 +1,95 @@
+// Command parser_regress runs the post extraction pipeline (LoadHTMLPage, GetPostBlocks,
+// ExtractPostMetadata, ParseContentBlocks, CleanNewTextBlock) over a corpus of archived topic
+// pages and compares the output with golden JSON files, printing a diff per post and field.
+//
+// Usage:
+//
+//	parser_regress -corpus test-data/regress/corpus -golden test-data/regress/golden
+//	parser_regress -corpus test-data/regress/corpus -golden test-data/regress/golden -accept
+//
+// Corpus pages must sit at <subforum>/<topic>/page_<n>.html, as in the archive. The command exits
+// with status 1 if any page differs from or has no golden.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"project-waypoint/pkg/regress"
+
+	"waypoint_archive_scripts/pkg/forumadapter"
+)
+
+func main() {
+	corpusDir := flag.String("corpus", "test-data/regress/corpus", "Directory of archived pages laid out as <subforum>/<topic>/page_<n>.html")
+	goldenDir := flag.String("golden", "test-data/regress/golden", "Directory of golden JSON files mirroring the corpus layout")
+	accept := flag.Bool("accept", false, "Rewrite the goldens from the current output instead of comparing")
+	jsonOutput := flag.Bool("json", false, "Print the report as JSON")
+	engine := flag.String("engine", forumadapter.MagicCafeName, "Forum software the corpus was archived from ("+strings.Join(forumadapter.Names(), ", ")+")")
+	profilesFile := flag.String("profiles", "", "JSON file of selector profiles for the magiccafe engine (empty uses the built-in profile)")
+	verbose := flag.Bool("v", false, "Keep the pipeline's own logging")
+	flag.Parse()
+
+	adapter, err := forumadapter.Lookup(*engine)
+	if err != nil {
+		log.Fatalf("[FATAL] %v", err)
+	}
+	if *profilesFile != "" {
+		if adapter, err = forumadapter.WithProfilesFile(adapter, *profilesFile); err != nil {
+			log.Fatalf("[FATAL] Failed to load selector profiles: %v", err)
+		}
+	}
+
+	// The pipeline logs every post at DEBUG level; only the report is of interest here
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	report, err := regress.Run(regress.Options{
+		CorpusDir: *corpusDir,
+		GoldenDir: *goldenDir,
+		Accept:    *accept,
+		Adapter:   adapter,
+	})
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		log.Fatalf("[FATAL] Regression run failed: %v", err)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("[FATAL] Failed to encode report: %v", err)
+		}
+	} else {
+		printReport(report, *accept)
+	}
+
+	if !*accept && !report.Passed() {
+		os.Exit(1)
+	}
+}
+
+func printReport(report regress.Report, accepted bool) {
+	if accepted {
+		fmt.Printf("Accepted %d goldens (%d posts).\n", report.Accepted, report.Posts)
+		return
+	}
+	for _, diff := range report.Diffs {
+		fmt.Println(diff)
+	}
+	for _, page := range report.MissingGoldens {
+		fmt.Printf("%s: no golden (run with -accept to create it)\n", page)
+	}
+	status := "PASS"
+	if !report.Passed() {
+		status = "FAIL"
+	}
+	fmt.Printf("%s: %d pages, %d posts, %d field differences, %d pages without golden\n",
+		status, report.Pages, report.Posts, len(report.Diffs), len(report.MissingGoldens))
+}
@@ -0,0 +1,65 @@
+// Command redact removes personal information from an extraction output tree: email addresses,
+// phone numbers, postal addresses and configured patterns in new and quoted text, and optionally
+// usernames. Each value is replaced by a placeholder that is the same wherever the value appears
+// (see package redact), and every redaction is appended to the audit log.
+//
+// Usage:
+//
+//	redact -output output_data -out redacted_data -config redaction.json
+//	redact -output output_data -out redacted_data -users -audit redactions.jsonl
+//	redact -output output_data -out output_data -config redaction.json   # in place
+//
+// The placeholder secret is read from the config or WAYPOINT_REDACTION_SECRET; use the same
+// secret for every pass over the archive so placeholders and pseudonyms stay consistent.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"project-waypoint/pkg/redact"
+)
+
+func main() {
+	configPath := flag.String("config", "", "Redaction config JSON (optional; all built-in detectors without it)")
+	srcDir := flag.String("output", "output_data", "Extraction output tree to redact")
+	destDir := flag.String("out", "", "Directory to write the redacted tree to (may be -output to redact in place)")
+	pseudonymise := flag.Bool("users", false, "Pseudonymise author and quoted usernames (overrides the config)")
+	auditPath := flag.String("audit", "", "Audit log to append redactions to (overrides the config)")
+	flag.Parse()
+
+	if *destDir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -out is required.")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var config redact.Config
+	if *configPath != "" {
+		var err error
+		config, err = redact.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+	}
+	if *pseudonymise {
+		config.PseudonymiseUsers = true
+	}
+	if *auditPath != "" {
+		config.AuditLogPath = *auditPath
+	}
+
+	redactor, err := redact.New(config)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	_, err = redact.RedactTree(redactor, *srcDir, *destDir)
+	if closeErr := redactor.Close(); closeErr != nil {
+		log.Printf("[ERROR] %v", closeErr)
+	}
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+}
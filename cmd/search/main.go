@@ -0,0 +1,122 @@
+// Command search queries the full-text index of extracted posts, and builds or updates it from an
+// extraction output tree. Updates are incremental: only output files that changed since the last
+// update (e.g. topics re-extracted by the orchestrator) are indexed again.
+//
+// Usage:
+//
+//	search -index search_index.gob.gz -update output_data -titles data/topic_indices
+//	search -index search_index.gob.gz cold start
+//	search -index search_index.gob.gz -author alice -subforum 66 -from 2019-01-01 -to 2019-12-31 '"cold start" engine'
+//
+// Double-quoted words are matched as a phrase; other words must all occur, in the post text,
+// quoted text, author or topic title. Results are ranked and link back to the post URL.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"project-waypoint/pkg/completeness"
+	"project-waypoint/pkg/search"
+)
+
+func main() {
+	indexPath := flag.String("index", "search_index.gob.gz", "Search index file")
+	updateDir := flag.String("update", "", "Extraction output tree to update the index from before searching")
+	titlesDir := flag.String("titles", "", "Directory of topic index JSON files to take topic titles from (with -update)")
+	author := flag.String("author", "", "Only posts by this author")
+	subForum := flag.String("subforum", "", "Only posts in this sub-forum ID")
+	from := flag.String("from", "", "Only posts on or after this date (YYYY-MM-DD)")
+	to := flag.String("to", "", "Only posts on or before this date (YYYY-MM-DD)")
+	limit := flag.Int("n", 20, "Number of results to show (0 for all)")
+	jsonOutput := flag.Bool("json", false, "Print results as JSON")
+	flag.Parse()
+
+	for _, date := range []string{*from, *to} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid date %q, expected YYYY-MM-DD.\n", date)
+			os.Exit(2)
+		}
+	}
+
+	index, err := search.Load(*indexPath)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	if *updateDir != "" {
+		var titles map[string]string
+		if *titlesDir != "" {
+			topics, err := completeness.LoadTopicIndex(*titlesDir)
+			if err != nil {
+				log.Fatalf("[ERROR] %v", err)
+			}
+			titles = make(map[string]string, len(topics))
+			for _, topic := range topics {
+				titles[topic.ID] = topic.Title
+			}
+		}
+		stats, err := index.Update(*updateDir, titles)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		if err := index.Save(*indexPath); err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		files, posts, terms := index.Stats()
+		log.Printf("[INFO] Index updated: %d files added, %d updated, %d removed, %d unchanged (%d posts indexed). Index holds %d files, %d posts, %d terms.",
+			stats.Added, stats.Updated, stats.Removed, stats.Unchanged, stats.Posts, files, posts, terms)
+	}
+
+	query := search.ParseQuery(strings.Join(flag.Args(), " "))
+	query.Author = *author
+	query.SubForumID = *subForum
+	query.From = *from
+	query.To = *to
+	if len(query.Terms) == 0 && len(query.Phrases) == 0 && query.Author == "" && query.SubForumID == "" && query.From == "" && query.To == "" {
+		if *updateDir == "" {
+			fmt.Fprintf(os.Stderr, "Error: nothing to search for; give query words, a filter or -update.\n")
+			flag.Usage()
+			os.Exit(2)
+		}
+		return
+	}
+
+	hits := index.Search(query, *limit)
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if hits == nil {
+			hits = []search.Hit{}
+		}
+		if err := encoder.Encode(hits); err != nil {
+			log.Fatalf("[ERROR] Failed to write results: %v", err)
+		}
+		return
+	}
+
+	if len(hits) == 0 {
+		fmt.Println("No matching posts.")
+		return
+	}
+	for i, hit := range hits {
+		doc := hit.Doc
+		fmt.Printf("%d. %s, %s", i+1, doc.Author, doc.Timestamp)
+		if doc.Title != "" {
+			fmt.Printf(", in %q", doc.Title)
+		}
+		fmt.Printf(" (topic %s/%s, score %.2f)\n", doc.SubForumID, doc.TopicID, hit.Score)
+		fmt.Printf("   %s\n", doc.PostURL)
+		if hit.Snippet != "" {
+			fmt.Printf("   %s\n", hit.Snippet)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Command serve starts a local web viewer for the archive. It browses sub-forums, their topics
+// and the extracted posts of each topic page by page, shows each topic's archive status from the
+// archiver's progress state, and links every post to the raw page it was extracted from.
+//
+// Usage:
+//
+//	serve -output output_data -archive archive_output -index data/topic_indices
+//	serve -addr :8080 -state data/archive_state.json -subforums data/subforum_list.json
+//
+// Then open http://localhost:8080 in a browser. The archive is re-read every -refresh interval,
+// so a running archiver's progress shows up without restarting the viewer. The viewer only reads
+// files; by default it listens on localhost only.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"project-waypoint/pkg/viewer"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "Address to listen on")
+	outputDir := flag.String("output", "output_data", "Extraction output directory (JSON files of extracted posts)")
+	archiveDir := flag.String("archive", "archive_output", "Root directory of the raw HTML archive")
+	indexDir := flag.String("index", "data/topic_indices", "Directory of topic index JSON files")
+	subForumList := flag.String("subforums", "data/subforum_list.csv", "Sub-forum list (CSV or JSON) for sub-forum names")
+	statePath := flag.String("state", "archive_progress.json", "The archiver's progress state file")
+	refresh := flag.Duration("refresh", time.Minute, "Re-read the archive when it is older than this (0 to never)")
+	flag.Parse()
+
+	config := viewer.Config{
+		OutputDir:        *outputDir,
+		ArchiveDir:       *archiveDir,
+		TopicIndexDir:    *indexDir,
+		SubForumListPath: *subForumList,
+		StatePath:        *statePath,
+	}
+	server, err := viewer.NewServer(config, *refresh)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to load the archive: %v", err)
+	}
+
+	log.Printf("[INFO] Archive viewer listening on http://%s/", *addr)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+// Command stats reports how big the archive is and how active the forum was: topics and posts
+// indexed and extracted, posts per year and month, the most active authors, the longest threads,
+// thread length, reply latency and quote density, for the whole archive and for each sub-forum.
+//
+// Usage:
+//
+//	stats -output output_data -index data/topic_indices -subforums data/subforum_list.csv
+//	stats -output output_data -index data/topic_indices -out reports
+//	stats -output output_data -format json > stats.json
+//
+// Without -out the report is printed in the -format given; with -out, stats.md, stats.html and
+// stats.json are written there.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"project-waypoint/pkg/stats"
+)
+
+var formats = map[string]func(io.Writer, *stats.Report) error{
+	"md":   stats.WriteMarkdown,
+	"html": stats.WriteHTML,
+	"json": stats.WriteJSON,
+}
+
+func main() {
+	outputDir := flag.String("output", "output_data", "Extraction output tree")
+	indexDir := flag.String("index", "", "Directory of topic index JSON files (optional)")
+	subForumList := flag.String("subforums", "", "Sub-forum list (CSV or JSON) for sub-forum names (optional)")
+	top := flag.Int("top", stats.DefaultTop, "Authors and threads listed per section")
+	format := flag.String("format", "md", "Format to print without -out: md, html or json")
+	outDir := flag.String("out", "", "Directory to write stats.md, stats.html and stats.json to")
+	flag.Parse()
+
+	write, ok := formats[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q (use md, html or json).\n", *format)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	report, err := stats.Compute(stats.Options{
+		OutputDir:        *outputDir,
+		TopicIndexDir:    *indexDir,
+		SubForumListPath: *subForumList,
+		Top:              *top,
+	})
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	if *outDir == "" {
+		writer := bufio.NewWriter(os.Stdout)
+		if err := write(writer, report); err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		if err := writer.Flush(); err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		return
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalf("[ERROR] Failed to create %s: %v", *outDir, err)
+	}
+	for _, extension := range []string{"md", "html", "json"} {
+		path := filepath.Join(*outDir, "stats."+extension)
+		if err := writeFile(path, report, formats[extension]); err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+	}
+	log.Printf("[INFO] Statistics written to %s: %d topics, %d posts, %d authors.", *outDir, report.Archive.Topics, report.Archive.Posts, report.Archive.Authors)
+}
+
+func writeFile(path string, report *stats.Report, write func(io.Writer, *stats.Report) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	writer := bufio.NewWriter(file)
+	if err := write(writer, report); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,176 @@
+// Command users builds the user directory from the extraction output and looks users up in it.
+// Each user gets a JSON profile (first and last post, posts per sub-forum and per year, topics
+// started, who they quote and who quotes them, name variants), and users.csv summarises them all.
+//
+// Usage:
+//
+//	users -dir users -build output_data -titles data/topic_indices
+//	users -dir users "Dai Vernon"
+//	users -dir users -json "dai vernon"
+//	users -dir users -top 20
+//
+// A name that matches no user exactly lists the users whose name contains it.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"project-waypoint/pkg/completeness"
+	"project-waypoint/pkg/outputtree"
+	"project-waypoint/pkg/users"
+)
+
+func main() {
+	dir := flag.String("dir", "users", "User directory to write to or query")
+	buildDir := flag.String("build", "", "Extraction output tree to build the user directory from before querying")
+	titlesDir := flag.String("titles", "", "Directory of topic index JSON files to take titles of started topics from (with -build)")
+	top := flag.Int("top", 0, "List the users with the most posts")
+	jsonOutput := flag.Bool("json", false, "Print the profile as JSON")
+	flag.Parse()
+
+	if *buildDir != "" {
+		var titles map[string]string
+		if *titlesDir != "" {
+			topics, err := completeness.LoadTopicIndex(*titlesDir)
+			if err != nil {
+				log.Fatalf("[ERROR] %v", err)
+			}
+			titles = make(map[string]string, len(topics))
+			for _, topic := range topics {
+				titles[topic.ID] = topic.Title
+			}
+		}
+		directory, err := users.Build(*buildDir, titles)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		if err := directory.Write(*dir); err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		log.Printf("[INFO] User directory written to %s: %d users.", *dir, len(directory.Profiles()))
+	}
+
+	name := strings.Join(flag.Args(), " ")
+	if name == "" && *top == 0 {
+		if *buildDir == "" {
+			fmt.Fprintf(os.Stderr, "Error: give a user name, -top or -build.\n")
+			flag.Usage()
+			os.Exit(2)
+		}
+		return
+	}
+
+	if *top > 0 {
+		rows, err := users.ReadSummary(*dir)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		printRows(rows[:min(*top, len(rows))])
+		if name == "" {
+			return
+		}
+		fmt.Println()
+	}
+
+	profile, ok, err := users.LoadProfile(*dir, name)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if !ok {
+		rows, err := users.ReadSummary(*dir)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		matches := users.Match(rows, name)
+		if len(matches) == 0 {
+			fmt.Printf("No user named %q.\n", name)
+			os.Exit(1)
+		}
+		fmt.Printf("No user named %q. Users whose name contains it:\n", name)
+		printRows(matches)
+		return
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(profile); err != nil {
+			log.Fatalf("[ERROR] Failed to write profile: %v", err)
+		}
+		return
+	}
+	printProfile(profile)
+}
+
+func printRows(rows []users.SummaryRow) {
+	for _, row := range rows {
+		fmt.Printf("%-30s %6d posts  %s to %s\n", row.Name, row.PostCount, outputtree.DateOf(row.FirstPost, "?"), outputtree.DateOf(row.LastPost, "?"))
+	}
+}
+
+func printProfile(profile *users.Profile) {
+	fmt.Printf("%s\n", profile.Name)
+	if len(profile.NameVariants) > 1 {
+		var variants []string
+		for _, variant := range profile.NameVariants {
+			variants = append(variants, fmt.Sprintf("%s (%d posts, quoted %d times)", variant.Name, variant.Posts, variant.Quoted))
+		}
+		fmt.Printf("  Also written as: %s\n", strings.Join(variants[1:], ", "))
+	}
+	fmt.Printf("  %d posts in %d topics\n", profile.PostCount, profile.TopicsPostedIn)
+	if profile.FirstPost != nil {
+		fmt.Printf("  First post: %s  %s\n", profile.FirstPost.Timestamp, profile.FirstPost.PostURL)
+		fmt.Printf("  Last post:  %s  %s\n", profile.LastPost.Timestamp, profile.LastPost.PostURL)
+	}
+
+	fmt.Printf("\n  Posts per year:\n")
+	for _, year := range sortedKeys(profile.PostsByYear) {
+		fmt.Printf("    %-8s %6d\n", year, profile.PostsByYear[year])
+	}
+	fmt.Printf("\n  Posts per sub-forum:\n")
+	subForums := sortedKeys(profile.PostsBySubForum)
+	sort.SliceStable(subForums, func(i, j int) bool {
+		return profile.PostsBySubForum[subForums[i]] > profile.PostsBySubForum[subForums[j]]
+	})
+	for _, subForum := range subForums {
+		fmt.Printf("    %-8s %6d\n", subForum, profile.PostsBySubForum[subForum])
+	}
+
+	if len(profile.TopicsStarted) > 0 {
+		fmt.Printf("\n  Topics started (%d):\n", len(profile.TopicsStarted))
+		for _, topic := range profile.TopicsStarted {
+			title := topic.Title
+			if title == "" {
+				title = "Topic " + topic.TopicID
+			}
+			fmt.Printf("    %s  %s (sub-forum %s, topic %s)\n", outputtree.DateOf(topic.Timestamp, "?"), title, topic.SubForumID, topic.TopicID)
+		}
+	}
+	printQuotes("Quotes most", profile.Quotes)
+	printQuotes("Quoted most by", profile.QuotedBy)
+}
+
+func printQuotes(heading string, counts []users.QuoteCount) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Printf("\n  %s:\n", heading)
+	for _, count := range counts[:min(10, len(counts))] {
+		fmt.Printf("    %-30s %6d\n", count.Name, count.Count)
+	}
+}
+
+func sortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,104 @@
+// Command validate_completeness checks an extraction output tree against the topic index. Each
+// indexed topic's extracted post count is compared with its reply count (Replies+1 posts), and its
+// posts are checked for missing pages, gaps in post_order_on_page and duplicate or out-of-order
+// post IDs. Topics are reported as complete, short or over-counted.
+//
+// Usage:
+//
+//	validate_completeness -index data/topic_indices -output output_data
+//	validate_completeness -index data/topic_indices -output output_data -subforum 66 -json > report.json
+//	validate_completeness -index data/topic_indices -output output_data -queue rearchive_queue.json
+//
+// With -queue, short topics and topics with missing pages are added to the archiver's re-archive
+// queue. The command exits with status 1 if any topic is not clean and 2 on errors.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"project-waypoint/pkg/completeness"
+
+	wdata "waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/forumadapter"
+	"waypoint_archive_scripts/pkg/rearchive"
+)
+
+func main() {
+	indexDir := flag.String("index", "", "Directory of topic index JSON files (required)")
+	outputDir := flag.String("output", "", "Extraction output tree to check (required)")
+	subForum := flag.String("subforum", "", "Only check topics of this sub-forum ID")
+	postsPerPage := flag.Int("postsPerPage", forumadapter.Default().PostsPerPage(), "Posts per topic page on the forum")
+	reportPath := flag.String("report", "", "Also write the full report as JSON to this file")
+	jsonOutput := flag.Bool("json", false, "Print the full report as JSON")
+	maxTopics := flag.Int("topics", 50, "Number of topics to list in the text report (-1 for all)")
+	queuePath := flag.String("queue", "", "Re-archive queue to add short topics and topics with missing pages to")
+	flag.Parse()
+
+	if *indexDir == "" || *outputDir == "" {
+		fmt.Fprintf(os.Stderr, "Error: both -index and -output are required.\n")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	topics, err := completeness.LoadTopicIndex(*indexDir)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		os.Exit(2)
+	}
+	if *subForum != "" {
+		var selected []wdata.Topic
+		for _, topic := range topics {
+			if topic.SubForumID == *subForum {
+				selected = append(selected, topic)
+			}
+		}
+		topics = selected
+	}
+
+	report, err := completeness.Validate(*outputDir, topics, *postsPerPage)
+	if err != nil {
+		log.Printf("[ERROR] Completeness check failed: %v", err)
+		os.Exit(2)
+	}
+
+	if *reportPath != "" {
+		if err := report.Save(*reportPath); err != nil {
+			log.Printf("[ERROR] %v", err)
+			os.Exit(2)
+		}
+	}
+
+	if *queuePath != "" {
+		queue, err := rearchive.LoadQueue(*queuePath)
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+			os.Exit(2)
+		}
+		queued := report.QueueRearchive(queue)
+		if err := queue.Save(*queuePath); err != nil {
+			log.Printf("[ERROR] %v", err)
+			os.Exit(2)
+		}
+		log.Printf("[INFO] %d topics newly queued for re-archiving in %s (%d queued in total).", queued, *queuePath, len(queue.Topics))
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = completeness.WriteText(os.Stdout, report, *maxTopics)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to write report: %v", err)
+		os.Exit(2)
+	}
+
+	if len(report.Topics) > 0 {
+		os.Exit(1)
+	}
+}
@@ -9,6 +9,7 @@ replace waypoint_archive_scripts => ./waypoint_archive_scripts
 require (
 	github.com/PuerkitoBio/goquery v1.10.3
 	github.com/stretchr/testify v1.10.0
+	waypoint_archive_scripts v0.0.0-00010101000000-000000000000
 )
 
 require (
@@ -16,5 +17,6 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/net v0.39.0 // indirect
+	golang.org/x/text v0.25.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
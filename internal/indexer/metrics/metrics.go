@@ -5,7 +5,7 @@ import (
 	"sync"
 	"time"
 
-	"waypoint_archive_scripts/internal/indexer/logger" // Corrected
+	"internal/indexer/logger" // Corrected
 )
 
 // MetricsTracker holds performance metrics for an indexing run.
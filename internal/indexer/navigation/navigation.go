@@ -6,14 +6,15 @@ import (
 	"net/http"
 	"net/url"
 	"sort"
-	"strconv"
-	"strings"
 	"time"
 
-	"internal/indexer/data"
 	"internal/indexer/logger"
 
-	"github.com/PuerkitoBio/goquery"
+	"waypoint_archive_scripts/pkg/canonurl"
+	"waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/forumadapter"
+	"waypoint_archive_scripts/pkg/htmlutil"
+	"waypoint_archive_scripts/pkg/pageplan"
 )
 
 // fetchHTMLFunc is the type for the HTML fetching function
@@ -50,53 +51,48 @@ var FetchHTML fetchHTMLFunc = defaultFetchHTML
 // It determines the total number of pages and generates a list of absolute URLs
 // for each page in the sub-forum.
 func ParsePaginationLinks(htmlContent string, pageURL string) ([]string, error) {
-	parsedCurrentURL, err := url.Parse(pageURL)
-	if err != nil {
+	if _, err := url.Parse(pageURL); err != nil {
 		return nil, fmt.Errorf("failed to parse pageURL '%s': %w", pageURL, err)
 	}
 
-	forumID := parsedCurrentURL.Query().Get("forum")
-	if forumID == "" {
+	adapter := forumadapter.Default()
+	forumRef, err := adapter.ParseURL(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pageURL '%s': %w", pageURL, err)
+	}
+	if forumRef.SubForumID == "" {
 		return nil, fmt.Errorf("forum ID not found in query parameters of URL: %s", pageURL)
 	}
+	// Listing page URLs are always formatted by the adapter so they match the archiver's URLs
+	forumRef.Kind, forumRef.TopicID, forumRef.PostID = canonurl.KindForum, "", ""
 
-	basePathForLinks := fmt.Sprintf("%s://%s%s", parsedCurrentURL.Scheme, parsedCurrentURL.Host, parsedCurrentURL.Path)
-
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	doc, err := forumadapter.ParseHTML(htmlContent)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML content: %w", err)
 	}
 
+	// The adapter finds the page links (the "Go to page" cell on Magic Cafe); only the
+	// furthest listing page matters, as every page before it is generated below.
+	links, err := adapter.PaginationLinks(doc, pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pagination links on '%s': %w", pageURL, err)
+	}
 	var startValues []int
-	doc.Find("td.normal.bgc1.b.midtext a[href]").Each(func(i int, s *goquery.Selection) {
-		href, exists := s.Attr("href")
-		if !exists {
-			return
-		}
-
-		linkText := strings.ToLower(s.Text())
-		if linkText == "next" || linkText == "prev" || linkText == "[next]" || linkText == "[prev]" {
-			return
-		}
-
-		resolvedLinkURL, err := parsedCurrentURL.Parse(href)
-		if err != nil {
-			logger.Warnf("Could not parse pagination link href '%s': %v", href, err)
-			return
+	for _, link := range links {
+		if linkRef, err := adapter.ParseURL(link); err == nil && linkRef.Kind == canonurl.KindForum && linkRef.Start > 0 {
+			startValues = append(startValues, linkRef.Start)
 		}
+	}
 
-		if strings.HasSuffix(resolvedLinkURL.Path, "viewforum.php") || (resolvedLinkURL.Path == "" && strings.HasSuffix(parsedCurrentURL.Path, "viewforum.php")) {
-			startStr := resolvedLinkURL.Query().Get("start")
-			if startStr != "" {
-				startVal, err := strconv.Atoi(startStr)
-				if err == nil && startVal >= 0 {
-					startValues = append(startValues, startVal)
-				}
-			}
-		}
-	})
-
-	topicsPerPage := 30
+	// The page size is the step between the linked pages' start values; the adapter's page size
+	// is only assumed when the links do not show it
+	topicsPerPage := 0
+	for _, start := range startValues {
+		topicsPerPage = gcd(topicsPerPage, start)
+	}
+	if topicsPerPage == 0 {
+		topicsPerPage = adapter.TopicsPerPage()
+	}
 
 	maxStart := 0
 	if len(startValues) > 0 {
@@ -107,16 +103,12 @@ func ParsePaginationLinks(htmlContent string, pageURL string) ([]string, error)
 	totalPages := 1
 	if topicsPerPage > 0 && maxStart > 0 {
 		totalPages = (maxStart / topicsPerPage) + 1
-	} else if maxStart == 0 && len(startValues) > 0 {
-		// If startValues contains only 0, or is empty, totalPages remains 1.
 	}
 
 	var allPageURLs []string
 	pageURLsSet := make(map[string]struct{})
 
-	qPage1 := url.Values{}
-	qPage1.Set("forum", forumID)
-	page1URL := fmt.Sprintf("%s?%s", basePathForLinks, qPage1.Encode())
+	page1URL := adapter.FormatURL(forumRef.WithStart(0))
 
 	if _, ok := pageURLsSet[page1URL]; !ok {
 		allPageURLs = append(allPageURLs, page1URL)
@@ -125,11 +117,7 @@ func ParsePaginationLinks(htmlContent string, pageURL string) ([]string, error)
 
 	for i := 1; i < totalPages; i++ {
 		currentStartValue := i * topicsPerPage
-		q := url.Values{}
-		q.Set("forum", forumID)
-		q.Set("start", strconv.Itoa(currentStartValue))
-
-		nextPageURL := fmt.Sprintf("%s?%s", basePathForLinks, q.Encode())
+		nextPageURL := adapter.FormatURL(forumRef.WithStart(currentStartValue))
 		if _, ok := pageURLsSet[nextPageURL]; !ok {
 			allPageURLs = append(allPageURLs, nextPageURL)
 			pageURLsSet[nextPageURL] = struct{}{}
@@ -143,15 +131,23 @@ func ParsePaginationLinks(htmlContent string, pageURL string) ([]string, error)
 	return allPageURLs, nil
 }
 
+// gcd returns the greatest common divisor of a and b, or the other if one is 0.
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
 // PageNavigationInfo holds information about a single page within a topic.
 type PageNavigationInfo struct {
 	PageNumber int    // 1-indexed page number
 	URL        string // Absolute URL of the page
 }
 
-// GetTopicPageURLs fetches and parses a topic's pages to return all page URLs.
-// It handles pagination by following "Next" links or page numbers until the last page is found.
-// It now also accepts a politenessDelay to be passed to FetchHTML.
+// GetTopicPageURLs returns the URLs of all pages of a topic. The pages are planned from the
+// topic's indexed reply count (see pageplan.Planner), so nothing is fetched; only when the counts
+// look stale is the first page fetched, with politenessDelay, to read its pagination links.
 func GetTopicPageURLs(topicDetails data.Topic, politenessDelay time.Duration) ([]PageNavigationInfo, error) {
 	logger.Infof("Starting to get page URLs for Topic ID: %s (URL: %s), Politeness Delay: %v", topicDetails.ID, topicDetails.URL, politenessDelay)
 
@@ -161,86 +157,35 @@ func GetTopicPageURLs(topicDetails data.Topic, politenessDelay time.Duration) ([
 		return nil, fmt.Errorf("topic ID cannot be empty")
 	}
 
-	// Use the topic's URL as the starting point
-	firstPageURL, err := url.Parse(topicDetails.URL)
-	if err != nil {
-		logger.Errorf("GetTopicPageURLs: Failed to parse topic URL '%s' for Topic ID %s: %v", topicDetails.URL, topicDetails.ID, err)
-		return nil, fmt.Errorf("failed to parse topic URL '%s': %w", topicDetails.URL, err)
-	}
-	if firstPageURL.Scheme == "" || firstPageURL.Host == "" {
-		logger.Errorf("GetTopicPageURLs: Invalid topic URL '%s' (missing scheme or host) for Topic ID %s", topicDetails.URL, topicDetails.ID)
-		return nil, fmt.Errorf("invalid topic URL '%s': missing scheme or host", topicDetails.URL)
+	planner := &pageplan.Planner{
+		PostsPerPage: forumadapter.Default().PostsPerPage(),
+		Fetcher:      delayedFetcher{delay: politenessDelay},
+		Parser:       htmlutil.NewPaginationParser(""),
 	}
-
-	currentURL := firstPageURL.String()
-	var pageNavInfos []PageNavigationInfo
-	seenURLs := make(map[string]struct{})
-	pageCounter := 0
-
-	for {
-		if _, seen := seenURLs[currentURL]; !seen {
-			pageCounter++
-			pageNavInfos = append(pageNavInfos, PageNavigationInfo{PageNumber: pageCounter, URL: currentURL})
-			seenURLs[currentURL] = struct{}{}
-			logger.Debugf("GetTopicPageURLs: Found page %d for Topic ID %s: %s", pageCounter, topicDetails.ID, currentURL) // DEBUG level for each page
-		}
-
-		html, err := FetchHTML(currentURL, politenessDelay)
-		if err != nil {
-			logger.Errorf("GetTopicPageURLs: Failed to fetch page %s for Topic ID %s: %v", currentURL, topicDetails.ID, err)
-			return pageNavInfos, fmt.Errorf("failed to fetch page %s: %w", currentURL, err)
-		}
-
-		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
-		if err != nil {
-			logger.Errorf("GetTopicPageURLs: Failed to parse HTML for page %s (Topic ID %s): %v", currentURL, topicDetails.ID, err)
-			return pageNavInfos, fmt.Errorf("failed to parse HTML for %s: %w", currentURL, err)
-		}
-
-		var nextLink string
-		doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
-			text := strings.ToLower(strings.TrimSpace(s.Text()))
-			if text == "next" || text == "[next]" {
-				if href, exists := s.Attr("href"); exists {
-					nextLink = href
-				}
-			}
-		})
-
-		if nextLink == "" {
-			logger.Debugf("GetTopicPageURLs: No 'Next' link found on page %s for Topic ID %s. Assuming last page.", currentURL, topicDetails.ID)
-			break
-		}
-
-		parsedCurrent, err := url.Parse(currentURL)
-		if err != nil {
-			logger.Errorf("GetTopicPageURLs: Failed to parse currentURL '%s' for relative resolution (Topic ID %s): %v", currentURL, topicDetails.ID, err)
-			return pageNavInfos, fmt.Errorf("failed to parse currentURL for relative resolution '%s': %w", currentURL, err)
-		}
-		resolvedNextURL, err := parsedCurrent.Parse(nextLink)
-		if err != nil {
-			logger.Errorf("GetTopicPageURLs: Failed to parse nextLink '%s' relative to '%s' (Topic ID %s): %v", nextLink, currentURL, topicDetails.ID, err)
-			return pageNavInfos, fmt.Errorf("failed to parse next URL '%s' relative to '%s': %w", nextLink, currentURL, err)
-		}
-		nextURL := resolvedNextURL.String()
-
-		if _, seen := seenURLs[nextURL]; seen {
-			logger.Warnf("GetTopicPageURLs: Detected pagination loop or revisit at URL: %s for Topic ID %s. Stopping pagination here.", nextURL, topicDetails.ID)
-			break
-		}
-
-		currentURL = nextURL
+	pageURLs, err := planner.TopicPageURLs(topicDetails)
+	if err != nil {
+		logger.Errorf("GetTopicPageURLs: Failed to plan pages for Topic ID %s: %v", topicDetails.ID, err)
+		return nil, fmt.Errorf("failed to plan pages of topic %s: %w", topicDetails.ID, err)
 	}
 
-	if len(pageNavInfos) == 0 && topicDetails.URL != "" {
-		pageNavInfos = append(pageNavInfos, PageNavigationInfo{PageNumber: 1, URL: firstPageURL.String()})
-		logger.Infof("GetTopicPageURLs: No pages were processed but initial URL was valid for Topic ID %s. Returning first page URL: %s", topicDetails.ID, firstPageURL.String())
+	pageNavInfos := make([]PageNavigationInfo, 0, len(pageURLs))
+	for i, pageURL := range pageURLs {
+		pageNavInfos = append(pageNavInfos, PageNavigationInfo{PageNumber: i + 1, URL: pageURL})
 	}
-
 	logger.Infof("GetTopicPageURLs: Successfully found %d page(s) for Topic ID: %s", len(pageNavInfos), topicDetails.ID)
 	return pageNavInfos, nil
 }
 
+// delayedFetcher fetches through FetchHTML, so the planner's live verification goes wherever
+// FetchHTML does (the cassette or response cache, or a test's replacement).
+type delayedFetcher struct {
+	delay time.Duration
+}
+
+func (f delayedFetcher) FetchHTML(pageURL string) (string, error) {
+	return FetchHTML(pageURL, f.delay)
+}
+
 // TODO: Implement sub-forum page navigation logic here
 // This will include functions to:
 // - Fetch HTML content (AC1)
@@ -4,7 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
-	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
@@ -146,6 +147,25 @@ func TestParsePaginationLinks(t *testing.T) {
 			}(),
 			wantErr: false,
 		},
+		{
+			name:    "page size taken from the links",
+			pageURL: fmt.Sprintf("%s?forum=66&start=2", forumBaseURL),
+			htmlContent: `
+				<table class="normal" cellpadding="4" cellspacing="1">
+					<tr><td class="normal bgc1 b midtext" colspan="6">
+						&nbsp;Go to page <a href="viewforum.php?forum=66&amp;start=0">1</a>~<span class="on_page">2</span>~
+						<a href="viewforum.php?forum=66&amp;start=4">3</a>~<a href="viewforum.php?forum=66&amp;start=6">4</a>
+						[<a href="viewforum.php?forum=66&amp;start=4">Next</a>]
+					</td></tr>
+				</table>`,
+			wantLinks: []string{
+				fmt.Sprintf("%s?forum=66", forumBaseURL),
+				fmt.Sprintf("%s?forum=66&start=2", forumBaseURL),
+				fmt.Sprintf("%s?forum=66&start=4", forumBaseURL),
+				fmt.Sprintf("%s?forum=66&start=6", forumBaseURL),
+			},
+			wantErr: false,
+		},
 		{
 			name:           "invalid page URL",
 			pageURL:        "://invalid-url",
@@ -201,90 +221,55 @@ func TestParsePaginationLinks(t *testing.T) {
 }
 
 func TestGetTopicPageURLs(t *testing.T) {
+	// A real topic page whose pagination links go to page 2
+	corpusPage, err := os.ReadFile(filepath.Join("..", "..", "..", "test-data", "regress", "corpus", "66", "19618", "page_1.html"))
+	if err != nil {
+		t.Fatalf("failed to read corpus page: %v", err)
+	}
+	firstPage := "https://www.themagiccafe.com/forums/viewtopic.php?forum=66&topic=19618"
+	secondPage := "https://www.themagiccafe.com/forums/viewtopic.php?forum=66&start=20&topic=19618"
+	topicURL := "https://www.themagiccafe.com/forums/viewtopic.php?topic=19618&forum=66"
+
 	tests := []struct {
 		name         string
 		topicDetails data.Topic
 		mockHTML     map[string]string // Map URL to mock HTML content
 		wantNavInfos []PageNavigationInfo
+		wantFetches  []string
 		wantErr      bool
 	}{
 		{
-			name: "single page topic",
-			topicDetails: data.Topic{
-				ID:  "12345",
-				URL: "https://www.themagiccafe.com/forums/viewtopic.php?topic_id=12345",
-			},
-			mockHTML: map[string]string{
-				"https://www.themagiccafe.com/forums/viewtopic.php?topic_id=12345": `<html><body><p>Single page content</p></body></html>`,
-			},
-			wantNavInfos: []PageNavigationInfo{
-				{PageNumber: 1, URL: "https://www.themagiccafe.com/forums/viewtopic.php?topic_id=12345"},
-			},
-			wantErr: false,
+			name:         "planned from indexed replies without fetching",
+			topicDetails: data.Topic{ID: "19618", SubForumID: "66", URL: topicURL, Replies: 24, LastPostTimestampRaw: "Feb 25, 2004 03:09 pm"},
+			wantNavInfos: []PageNavigationInfo{{PageNumber: 1, URL: firstPage}, {PageNumber: 2, URL: secondPage}},
 		},
 		{
-			name: "multi page topic",
-			topicDetails: data.Topic{
-				ID:  "67890",
-				URL: "http://example.com/viewtopic.php?topic_id=67890",
-			},
-			mockHTML: map[string]string{
-				"http://example.com/viewtopic.php?topic_id=67890":        `<html><body>Page 1 <a href="viewtopic.php?topic_id=67890&page=2">Next</a></body></html>`,
-				"http://example.com/viewtopic.php?topic_id=67890&page=2": `<html><body>Page 2 <a href="viewtopic.php?topic_id=67890&page=3">Next</a></body></html>`,
-				"http://example.com/viewtopic.php?topic_id=67890&page=3": `<html><body>Page 3 No Next Link</body></html>`,
-			},
-			wantNavInfos: []PageNavigationInfo{
-				{PageNumber: 1, URL: "http://example.com/viewtopic.php?topic_id=67890"},
-				{PageNumber: 2, URL: "http://example.com/viewtopic.php?topic_id=67890&page=2"},
-				{PageNumber: 3, URL: "http://example.com/viewtopic.php?topic_id=67890&page=3"},
-			},
-			wantErr: false,
+			name:         "no replies with a last post is a single page",
+			topicDetails: data.Topic{ID: "19618", SubForumID: "66", URL: topicURL, LastPostTimestampRaw: "Jan 9, 2003 11:20 pm"},
+			wantNavInfos: []PageNavigationInfo{{PageNumber: 1, URL: firstPage}},
 		},
 		{
-			name: "empty topic ID",
-			topicDetails: data.Topic{
-				ID:  "", // Empty ID
-				URL: "https://www.themagiccafe.com/forums/viewtopic.php?topic_id=",
-			},
-			wantErr: true,
+			name:         "stale counts verified against live pagination",
+			topicDetails: data.Topic{ID: "19618", SubForumID: "66", URL: topicURL},
+			mockHTML:     map[string]string{firstPage: string(corpusPage)},
+			wantNavInfos: []PageNavigationInfo{{PageNumber: 1, URL: firstPage}, {PageNumber: 2, URL: secondPage}},
+			wantFetches:  []string{firstPage},
 		},
 		{
-			name: "invalid topic URL",
-			topicDetails: data.Topic{
-				ID:  "123",
-				URL: "://not-a-url",
-			},
-			wantErr: true,
+			name:         "empty topic ID",
+			topicDetails: data.Topic{ID: "", URL: topicURL},
+			wantErr:      true,
 		},
 		{
-			name: "fetch error on first page",
-			topicDetails: data.Topic{
-				ID:  "fetcherror1",
-				URL: "http://example.com/fetcherror1",
-			},
-			mockHTML: map[string]string{ // Intentionally empty to cause fetch error with specific check
-				// "http://example.com/fetcherror1": "", // This will be handled by error in mock FetchHTML
-			},
-			wantNavInfos: []PageNavigationInfo{ // Should return what it had, which is the first page attempt
-				{PageNumber: 1, URL: "http://example.com/fetcherror1"},
-			},
-			wantErr: true, // Error from FetchHTML
+			name:         "no topic URL",
+			topicDetails: data.Topic{ID: "123"},
+			wantErr:      true,
 		},
 		{
-			name: "fetch error on second page",
-			topicDetails: data.Topic{
-				ID:  "fetcherror2",
-				URL: "http://example.com/fetcherror2_page1", // First page URL
-			},
-			mockHTML: map[string]string{
-				"http://example.com/fetcherror2_page1": `<html><body>Page 1 <a href="fetcherror2_page2">Next</a></body></html>`,
-				// No HTML needed for fetcherror2_page2 as it will cause a fetch error in the mock
-			},
-			wantNavInfos: []PageNavigationInfo{
-				{PageNumber: 1, URL: "http://example.com/fetcherror2_page1"},
-				{PageNumber: 2, URL: "http://example.com/fetcherror2_page2"}, // This URL is determined, then fetch fails
-			},
-			wantErr: true, // Error from FetchHTML on second page
+			name:         "fetch error on first page of a stale topic",
+			topicDetails: data.Topic{ID: "19618", SubForumID: "66", URL: topicURL},
+			wantFetches:  []string{firstPage},
+			wantErr:      true,
 		},
 	}
 
@@ -293,33 +278,13 @@ func TestGetTopicPageURLs(t *testing.T) {
 			originalFetchHTML := FetchHTML
 			defer func() { FetchHTML = originalFetchHTML }()
 
+			var fetches []string
 			FetchHTML = func(fetchURL string, delay time.Duration) (string, error) {
-				if tt.name == "fetch error on first page" && fetchURL == tt.topicDetails.URL {
-					return "", fmt.Errorf("mock fetch error for first page")
-				}
-				expectedSecondPageURLForFetchError2 := ""
-				if tt.topicDetails.ID == "fetcherror2" {
-					parsedBase, errBase := url.Parse(tt.topicDetails.URL)
-					if errBase != nil {
-						return "", fmt.Errorf("test setup error: failed to parse base URL %s: %w", tt.topicDetails.URL, errBase)
-					}
-					refURL, errRef := url.Parse("fetcherror2_page2")
-					if errRef != nil {
-						return "", fmt.Errorf("test setup error: failed to parse ref URL %s: %w", "fetcherror2_page2", errRef)
-					}
-					expectedSecondPageURLForFetchError2 = parsedBase.ResolveReference(refURL).String()
-				}
-
-				if tt.name == "fetch error on second page" && fetchURL == expectedSecondPageURLForFetchError2 {
-					return "", fmt.Errorf("mock fetch error for second page")
-				}
+				fetches = append(fetches, fetchURL)
 				if html, ok := tt.mockHTML[fetchURL]; ok {
 					return html, nil
 				}
-				if tt.name == "multi page topic" {
-					return "<html><body>Generic mock page</body></html>", nil
-				}
-				return "", fmt.Errorf("unexpected URL fetched in test: %s for test case %s", fetchURL, tt.name)
+				return "", fmt.Errorf("mock fetch error for %s", fetchURL)
 			}
 
 			gotNavInfos, err := GetTopicPageURLs(tt.topicDetails, 0)
@@ -327,10 +292,12 @@ func TestGetTopicPageURLs(t *testing.T) {
 				t.Errorf("GetTopicPageURLs() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-
 			if !reflect.DeepEqual(gotNavInfos, tt.wantNavInfos) {
 				t.Errorf("GetTopicPageURLs() got = %v, want %v", gotNavInfos, tt.wantNavInfos)
 			}
+			if !reflect.DeepEqual(fetches, tt.wantFetches) {
+				t.Errorf("GetTopicPageURLs() fetched %v, want %v", fetches, tt.wantFetches)
+			}
 		})
 	}
 }
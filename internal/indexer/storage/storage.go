@@ -3,31 +3,26 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
-	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
 
 	"internal/indexer/logger"
 	"internal/indexer/topic"
+
+	"waypoint_archive_scripts/pkg/canonurl"
 )
 
-// ExtractSubForumID attempts to get a forum ID from the URL query string.
-// It is an exported function.
+// ExtractSubForumID gets the sub-forum ID of a listing or topic URL via canonurl, so 'forum'
+// and its phpBB alias 'f' are both recognised.
+// It returns an empty string (and no error) when the URL carries no forum ID; the caller
+// decides on "unknown_forum" or other fallbacks.
 func ExtractSubForumID(pageURL string) (string, error) {
-	u, err := url.Parse(pageURL)
+	ref, err := canonurl.Parse(pageURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse URL '%s': %w", pageURL, err)
 	}
-	forumID := u.Query().Get("forum")
-	if forumID != "" {
-		return forumID, nil
-	}
-	// If 'forum' parameter is not found, it's not necessarily an error for this function's purpose,
-	// but the caller might treat an empty string as such. For robustness, let's return an empty string
-	// and let the caller decide on "unknown_forum" or other fallbacks.
-	// logger.Warnf within this specific utility might be too chatty if used by various parts.
-	return "", nil // Return empty string if not found, let caller handle fallback if needed.
+	return string(ref.SubForumID), nil
 }
 
 // SaveTopicIndex saves the collected topics to a JSON file in the specified output directory.
@@ -34,8 +34,28 @@ func TestExtractTopics(t *testing.T) {
     </tr>
 </table>`,
 			wantTopics: []TopicInfo{
-				{ID: "286725", Title: "Silk Sizes Explained", URL: "https://www.themagiccafe.com/forums/viewtopic.php?topic=286725&forum=54"},
-				{ID: "780955", Title: "Purse Swindle by Alexander De Cova", URL: "https://www.themagiccafe.com/forums/viewtopic.php?topic=780955&forum=54"},
+				{ID: "286725", Title: "Silk Sizes Explained", URL: "https://www.themagiccafe.com/forums/viewtopic.php?forum=54&topic=286725"},
+				{ID: "780955", Title: "Purse Swindle by Alexander De Cova", URL: "https://www.themagiccafe.com/forums/viewtopic.php?forum=54&topic=780955"},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "replies, views and last post from the listing row",
+			pageURL: pageBaseURL,
+			htmlContent: `
+<table class="normal">
+    <tr>
+        <td class="normal bgc1 c w5"></td>
+        <td class="normal bgc2"><a class="b" href="viewtopic.php?topic=19600&forum=54">First topic</a></td>
+        <td class="normal bgc1 c midtext">User0</td>
+        <td class="normal bgc2 c midtext">1,204</td>
+        <td class="normal bgc1 c midtext">5000</td>
+        <td class="normal bgc2 c midtext">Jan 10, 2003 11:42 am<br />by User3</td>
+    </tr>
+</table>`,
+			wantTopics: []TopicInfo{
+				{ID: "19600", Title: "First topic", URL: "https://www.themagiccafe.com/forums/viewtopic.php?forum=54&topic=19600",
+					Replies: 1204, Views: 5000, LastPostUsername: "User3", LastPostTimestampRaw: "Jan 10, 2003 11:42 am"},
 			},
 			wantErr: false,
 		},
@@ -93,8 +113,8 @@ func TestExtractTopics(t *testing.T) {
     </tr>
 </table>`,
 			wantTopics: []TopicInfo{
-				{ID: "111", Title: "Topic Alpha", URL: "https://www.themagiccafe.com/forums/viewtopic.php?topic=111&forum=54"},
-				{ID: "222", Title: "Topic Beta", URL: "https://www.themagiccafe.com/forums/viewtopic.php?topic=222&forum=54"},
+				{ID: "111", Title: "Topic Alpha", URL: "https://www.themagiccafe.com/forums/viewtopic.php?forum=54&topic=111"},
+				{ID: "222", Title: "Topic Beta", URL: "https://www.themagiccafe.com/forums/viewtopic.php?forum=54&topic=222"},
 			},
 			wantErr: false,
 		},
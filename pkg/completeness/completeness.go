@@ -0,0 +1,225 @@
+// Package completeness checks that extraction captured every post of a topic. A topic's extracted
+// posts are compared with the reply count of the topic index (Replies+1 posts), and checked for
+// missing pages, gaps in PostOrderOnPage and duplicate or out-of-order post IDs.
+package completeness
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree"
+
+	wdata "waypoint_archive_scripts/pkg/data"
+)
+
+// Status classifies a topic by its extracted post count.
+type Status string
+
+const (
+	StatusComplete    Status = "complete"     // As many posts as the index expects
+	StatusShort       Status = "short"        // Fewer posts than the index expects
+	StatusOverCounted Status = "over_counted" // More posts than the index expects, usually a stale index
+)
+
+// OrderGap describes a page whose PostOrderOnPage values are not 0..n-1.
+type OrderGap struct {
+	Page       int   `json:"page"`
+	Missing    []int `json:"missing,omitempty"`    // Positions below the highest one that no post has
+	Duplicates []int `json:"duplicates,omitempty"` // Positions held by more than one post
+}
+
+// TopicResult is the completeness check of one topic.
+type TopicResult struct {
+	SubForumID        string     `json:"subforum_id"`
+	TopicID           string     `json:"topic_id"`
+	Title             string     `json:"title,omitempty"`
+	OutputFile        string     `json:"output_file,omitempty"` // Empty if the topic has no output file
+	Expected          int        `json:"expected"`              // Replies+1 from the topic index
+	Extracted         int        `json:"extracted"`
+	Status            Status     `json:"status"`
+	MissingPages      []int      `json:"missing_pages,omitempty"`
+	OrderGaps         []OrderGap `json:"order_gaps,omitempty"`
+	DuplicatePostIDs  []string   `json:"duplicate_post_ids,omitempty"`
+	OutOfOrderPostIDs []string   `json:"out_of_order_post_ids,omitempty"` // Post IDs not greater than the one before them
+}
+
+// Clean reports whether the topic is complete and has no structural problems.
+func (r TopicResult) Clean() bool {
+	return r.Status == StatusComplete && len(r.MissingPages) == 0 && len(r.OrderGaps) == 0 &&
+		len(r.DuplicatePostIDs) == 0 && len(r.OutOfOrderPostIDs) == 0
+}
+
+// NeedsRearchive reports whether fetching the topic again may fix it: it is short or pages are
+// missing. Gaps within a page mean posts were dropped by the extraction itself (see the error
+// ledger), which archiving again does not fix.
+func (r TopicResult) NeedsRearchive() bool {
+	return r.Status == StatusShort || len(r.MissingPages) > 0
+}
+
+// Reason describes in one line why the topic is not clean.
+func (r TopicResult) Reason() string {
+	reason := fmt.Sprintf("%s: %d of %d posts extracted", r.Status, r.Extracted, r.Expected)
+	if len(r.MissingPages) > 0 {
+		reason += fmt.Sprintf(", pages %v missing", r.MissingPages)
+	}
+	if len(r.OrderGaps) > 0 {
+		reason += fmt.Sprintf(", post order gaps on %d pages", len(r.OrderGaps))
+	}
+	if len(r.DuplicatePostIDs) > 0 {
+		reason += fmt.Sprintf(", %d duplicate post IDs", len(r.DuplicatePostIDs))
+	}
+	if len(r.OutOfOrderPostIDs) > 0 {
+		reason += fmt.Sprintf(", %d post IDs out of order", len(r.OutOfOrderPostIDs))
+	}
+	return reason
+}
+
+// Check compares the posts extracted for topic with its index entry. postsPerPage is the
+// forum's page size, used to work out how many pages the topic should have.
+func Check(topic wdata.Topic, posts []data.PostMetadata, postsPerPage int) TopicResult {
+	result := TopicResult{
+		SubForumID: topic.SubForumID,
+		TopicID:    topic.ID,
+		Title:      topic.Title,
+		Expected:   topic.Replies + 1,
+		Extracted:  len(posts),
+	}
+	switch {
+	case result.Extracted < result.Expected:
+		result.Status = StatusShort
+	case result.Extracted > result.Expected:
+		result.Status = StatusOverCounted
+	default:
+		result.Status = StatusComplete
+	}
+
+	ordered := make([]data.PostMetadata, len(posts))
+	copy(ordered, posts)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].PageNumber != ordered[j].PageNumber {
+			return ordered[i].PageNumber < ordered[j].PageNumber
+		}
+		return ordered[i].PostOrderOnPage < ordered[j].PostOrderOnPage
+	})
+
+	// Pages: every page up to the last expected or extracted one should have posts
+	ordersByPage := make(map[int][]int)
+	lastPage := 0
+	if postsPerPage > 0 {
+		lastPage = (result.Expected + postsPerPage - 1) / postsPerPage
+	}
+	for _, post := range ordered {
+		ordersByPage[post.PageNumber] = append(ordersByPage[post.PageNumber], post.PostOrderOnPage)
+		if post.PageNumber > lastPage {
+			lastPage = post.PageNumber
+		}
+	}
+	for page := 1; page <= lastPage; page++ {
+		if _, ok := ordersByPage[page]; !ok {
+			result.MissingPages = append(result.MissingPages, page)
+		}
+	}
+
+	// Post order: the positions on each page should be 0..n-1
+	pages := make([]int, 0, len(ordersByPage))
+	for page := range ordersByPage {
+		pages = append(pages, page)
+	}
+	sort.Ints(pages)
+	for _, page := range pages {
+		if gap, ok := orderGap(page, ordersByPage[page]); ok {
+			result.OrderGaps = append(result.OrderGaps, gap)
+		}
+	}
+
+	// Post IDs: unique and increasing in page order
+	seen := make(map[string]bool)
+	previous := ""
+	for _, post := range ordered {
+		if post.PostID == "" {
+			continue
+		}
+		if seen[post.PostID] {
+			result.DuplicatePostIDs = append(result.DuplicatePostIDs, post.PostID)
+			continue
+		}
+		seen[post.PostID] = true
+		if previous != "" && !IDLess(previous, post.PostID) {
+			result.OutOfOrderPostIDs = append(result.OutOfOrderPostIDs, post.PostID)
+		}
+		previous = post.PostID
+	}
+	return result
+}
+
+// orderGap checks that the sorted positions of a page are 0..n-1.
+func orderGap(page int, orders []int) (OrderGap, bool) {
+	gap := OrderGap{Page: page}
+	next := 0
+	for i, order := range orders {
+		if i > 0 && order == orders[i-1] {
+			if len(gap.Duplicates) == 0 || gap.Duplicates[len(gap.Duplicates)-1] != order {
+				gap.Duplicates = append(gap.Duplicates, order)
+			}
+			continue
+		}
+		for ; next < order; next++ {
+			gap.Missing = append(gap.Missing, next)
+		}
+		next = order + 1
+	}
+	return gap, len(gap.Missing) > 0 || len(gap.Duplicates) > 0
+}
+
+// IDLess compares post, topic or sub-forum IDs numerically when both are numbers.
+func IDLess(a, b string) bool {
+	na, errA := strconv.ParseInt(a, 10, 64)
+	nb, errB := strconv.ParseInt(b, 10, 64)
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+	return a < b
+}
+
+// OutputFile returns the output file of a topic in outputDir: {subforum_id}_{topic_id}.json, or
+// the file of the topic under another sub-forum ID (e.g. a moved topic archived where it was
+// first found). It returns "" if the topic has no output file.
+func OutputFile(outputDir string, topic wdata.Topic) (string, error) {
+	path := filepath.Join(outputDir, fmt.Sprintf("%s_%s.json", topic.SubForumID, topic.ID))
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to stat output file %s: %w", path, err)
+	}
+	matches, err := filepath.Glob(filepath.Join(outputDir, "*_"+topic.ID+".json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to look for the output file of topic %s: %w", topic.ID, err)
+	}
+	if len(matches) == 0 {
+		return "", nil
+	}
+	sort.Strings(matches)
+	return matches[0], nil
+}
+
+// CheckOutput checks the output file written for topic in outputDir. A topic without an output
+// file has no extracted posts.
+func CheckOutput(outputDir string, topic wdata.Topic, postsPerPage int) (TopicResult, error) {
+	path, err := OutputFile(outputDir, topic)
+	if err != nil {
+		return TopicResult{}, err
+	}
+	var posts []data.PostMetadata
+	if path != "" {
+		if posts, err = outputtree.Read(path); err != nil {
+			return TopicResult{}, err
+		}
+	}
+	result := Check(topic, posts, postsPerPage)
+	result.OutputFile = path
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package completeness
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"project-waypoint/pkg/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	wdata "waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/rearchive"
+)
+
+const postsPerPage = 3
+
+// topicPosts returns count posts of topic 100 laid out postsPerPage to a page, with increasing IDs.
+func topicPosts(count int) []data.PostMetadata {
+	posts := make([]data.PostMetadata, count)
+	for i := range posts {
+		posts[i] = data.PostMetadata{
+			PostID:          strconv.Itoa(1000 + i),
+			TopicID:         "100",
+			SubForumID:      "66",
+			PageNumber:      i/postsPerPage + 1,
+			PostOrderOnPage: i % postsPerPage,
+		}
+	}
+	return posts
+}
+
+func TestCheck(t *testing.T) {
+	topic := wdata.Topic{ID: "100", SubForumID: "66", Replies: 6}
+
+	complete := Check(topic, topicPosts(7), postsPerPage)
+	assert.Equal(t, StatusComplete, complete.Status)
+	assert.True(t, complete.Clean())
+	assert.False(t, complete.NeedsRearchive())
+
+	// Page 2 was not archived
+	posts := topicPosts(7)
+	short := Check(topic, append(posts[:3:3], posts[6]), postsPerPage)
+	assert.Equal(t, StatusShort, short.Status)
+	assert.Equal(t, []int{2}, short.MissingPages)
+	assert.Empty(t, short.OrderGaps)
+	assert.True(t, short.NeedsRearchive())
+	assert.Equal(t, "short: 4 of 7 posts extracted, pages [2] missing", short.Reason())
+
+	// The second post of page 1 was dropped and another post appears twice
+	posts = topicPosts(7)
+	gappy := append([]data.PostMetadata{posts[0]}, posts[2:]...)
+	gappy = append(gappy, posts[4])
+	result := Check(topic, gappy, postsPerPage)
+	assert.Equal(t, StatusComplete, result.Status)
+	assert.Equal(t, []OrderGap{{Page: 1, Missing: []int{1}}, {Page: 2, Duplicates: []int{1}}}, result.OrderGaps)
+	assert.Equal(t, []string{"1004"}, result.DuplicatePostIDs)
+	assert.False(t, result.Clean())
+	assert.False(t, result.NeedsRearchive(), "posts dropped by extraction are not fixed by archiving again")
+
+	// The index predates two replies; IDs out of order
+	posts = topicPosts(9)
+	posts[4].PostID = "999"
+	over := Check(topic, posts, postsPerPage)
+	assert.Equal(t, StatusOverCounted, over.Status)
+	assert.Empty(t, over.MissingPages)
+	assert.Equal(t, []string{"999"}, over.OutOfOrderPostIDs)
+}
+
+func TestValidate(t *testing.T) {
+	outputDir := t.TempDir()
+	writeOutput := func(name string, posts []data.PostMetadata) {
+		content, err := json.Marshal(posts)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(outputDir, name), content, 0644))
+	}
+	writeOutput("66_100.json", topicPosts(4))
+	writeOutput("12_200.json", topicPosts(2)) // Archived under the sub-forum it was first found in
+
+	topics := []wdata.Topic{
+		{ID: "100", SubForumID: "66", Replies: 5},
+		{ID: "200", SubForumID: "54", Replies: 1},
+		{ID: "300", SubForumID: "66", Replies: 0},
+	}
+	report, err := Validate(outputDir, topics, postsPerPage)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Checked)
+	assert.Equal(t, Counts{Complete: 1, Short: 2, WithGaps: 1}, report.Totals)
+	require.Len(t, report.Topics, 2)
+	assert.Equal(t, "100", report.Topics[0].TopicID)
+	assert.Equal(t, "300", report.Topics[1].TopicID)
+	assert.Empty(t, report.Topics[1].OutputFile)
+	assert.Equal(t, []int{1}, report.Topics[1].MissingPages)
+
+	queue := rearchive.NewQueue()
+	assert.Equal(t, 2, report.QueueRearchive(queue))
+	assert.Equal(t, 0, report.QueueRearchive(queue), "topics already queued are not counted again")
+	assert.True(t, queue.Contains("100"))
+	assert.Equal(t, "completeness", queue.Topics["300"].Source)
+
+	var text bytes.Buffer
+	require.NoError(t, WriteText(&text, report, -1))
+	assert.Contains(t, text.String(), "Complete 1, short 2, over-counted 0, with gaps 1")
+	assert.Contains(t, text.String(), "66/300")
+}
+
+func TestLoadTopicIndex(t *testing.T) {
+	indexDir := t.TempDir()
+	forumDir := filepath.Join(indexDir, "forum_66")
+	require.NoError(t, os.MkdirAll(forumDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(forumDir, "topic_index_66.json"), []byte(`[{"ID":"100","Replies":5}]`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "topic_index_54.json"), []byte(`[{"ID":"200","Replies":1}]`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "notes.json"), []byte(`not an index`), 0644))
+
+	topics, err := LoadTopicIndex(indexDir)
+	require.NoError(t, err)
+	require.Len(t, topics, 2)
+	assert.Equal(t, wdata.Topic{ID: "100", SubForumID: "66", Replies: 5}, topics[0])
+	assert.Equal(t, "54", topics[1].SubForumID)
+}
@@ -0,0 +1,164 @@
+package completeness
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/indexerlogic"
+	"waypoint_archive_scripts/pkg/rearchive"
+)
+
+// Counts tallies checked topics.
+type Counts struct {
+	Complete    int `json:"complete"`
+	Short       int `json:"short"`
+	OverCounted int `json:"over_counted"`
+	WithGaps    int `json:"with_gaps"` // Topics with missing pages, post order gaps or post ID problems, whatever their count
+}
+
+// Report is the completeness check of a set of topics. Only topics that are not clean are listed.
+type Report struct {
+	Checked int           `json:"checked"`
+	Totals  Counts        `json:"totals"`
+	Topics  []TopicResult `json:"topics"`
+}
+
+// NewReport creates an empty Report.
+func NewReport() *Report {
+	return &Report{Topics: []TopicResult{}}
+}
+
+// Add counts a topic result, listing it if it is not clean.
+func (r *Report) Add(result TopicResult) {
+	r.Checked++
+	switch result.Status {
+	case StatusShort:
+		r.Totals.Short++
+	case StatusOverCounted:
+		r.Totals.OverCounted++
+	default:
+		r.Totals.Complete++
+	}
+	if len(result.MissingPages) > 0 || len(result.OrderGaps) > 0 || len(result.DuplicatePostIDs) > 0 || len(result.OutOfOrderPostIDs) > 0 {
+		r.Totals.WithGaps++
+	}
+	if !result.Clean() {
+		r.Topics = append(r.Topics, result)
+	}
+}
+
+// Validate checks the output of every topic in outputDir.
+func Validate(outputDir string, topics []data.Topic, postsPerPage int) (*Report, error) {
+	report := NewReport()
+	for _, topic := range topics {
+		result, err := CheckOutput(outputDir, topic, postsPerPage)
+		if err != nil {
+			return nil, err
+		}
+		report.Add(result)
+	}
+	return report, nil
+}
+
+// QueueRearchive adds every listed topic that NeedsRearchive to queue and returns how many were
+// newly queued.
+func (r *Report) QueueRearchive(queue *rearchive.Queue) int {
+	queued := 0
+	now := time.Now().UTC()
+	for _, result := range r.Topics {
+		if !result.NeedsRearchive() {
+			continue
+		}
+		if queue.Add(rearchive.Entry{TopicID: result.TopicID, SubForumID: result.SubForumID, Reason: result.Reason(), Source: "completeness", QueuedAt: now}) {
+			queued++
+		}
+	}
+	return queued
+}
+
+// Save writes the report to filePath as JSON.
+func (r *Report) Save(filePath string) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal completeness report: %w", err)
+	}
+	if dir := filepath.Dir(filePath); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory for completeness report %s: %w", dir, err)
+		}
+	}
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write completeness report %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// WriteText writes a human-readable summary followed by at most maxTopics listed topics (all of
+// them if maxTopics is negative).
+func WriteText(w io.Writer, report *Report, maxTopics int) error {
+	t := report.Totals
+	fmt.Fprintf(w, "Topics checked: %d\n", report.Checked)
+	fmt.Fprintf(w, "Complete %d, short %d, over-counted %d, with gaps %d\n", t.Complete, t.Short, t.OverCounted, t.WithGaps)
+
+	topics := report.Topics
+	if maxTopics >= 0 && len(topics) > maxTopics {
+		fmt.Fprintf(w, "\nTopics (first %d of %d):\n", maxTopics, len(topics))
+		topics = topics[:maxTopics]
+	} else if len(topics) > 0 {
+		fmt.Fprintf(w, "\nTopics:\n")
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, result := range topics {
+		fmt.Fprintf(tw, "  %s/%s\t%s\n", result.SubForumID, result.TopicID, result.Reason())
+		for _, gap := range result.OrderGaps {
+			var details []string
+			if len(gap.Missing) > 0 {
+				details = append(details, fmt.Sprintf("missing %v", gap.Missing))
+			}
+			if len(gap.Duplicates) > 0 {
+				details = append(details, fmt.Sprintf("duplicated %v", gap.Duplicates))
+			}
+			fmt.Fprintf(tw, "  \tpage %d post order %s\n", gap.Page, strings.Join(details, ", "))
+		}
+	}
+	return tw.Flush()
+}
+
+// LoadTopicIndex reads every topic index JSON file (topic_index_*.json) below indexDir. Index
+// files live in per sub-forum directories named forum_{subforum_id}, as the archiver writes them;
+// files elsewhere take the sub-forum ID from their name, e.g. topic_index_66.json.
+func LoadTopicIndex(indexDir string) ([]data.Topic, error) {
+	var topics []data.Topic
+	err := filepath.WalkDir(indexDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "topic_index_") || filepath.Ext(name) != ".json" {
+			return nil
+		}
+		subForumID := strings.TrimPrefix(filepath.Base(filepath.Dir(path)), "forum_")
+		if !strings.HasPrefix(filepath.Base(filepath.Dir(path)), "forum_") {
+			subForumID = strings.TrimPrefix(strings.TrimSuffix(strings.TrimPrefix(name, "topic_index_"), ".json"), "forum_")
+		}
+		indexTopics, err := indexerlogic.ReadTopicIndexJSON(path, subForumID)
+		if err != nil {
+			return err
+		}
+		topics = append(topics, indexTopics...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load topic index from %s: %w", indexDir, err)
+	}
+	log.Printf("[INFO] COMPLETENESS: Loaded %d topics from the topic index in %s", len(topics), indexDir)
+	return topics, nil
+}
@@ -0,0 +1,144 @@
+// Package dataset exports the extraction output as a machine-learning dataset: one JSON line per
+// topic, holding the topic's posts as ordered conversation turns. Quoted text is taken out of the
+// reply that quotes it and replaced by a reference to the quoted turn, so the same text is not
+// repeated through a thread; the quote structure comes from the extracted content blocks rather
+// than from parsing the text again.
+package dataset
+
+import (
+	"sort"
+	"strings"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree"
+	"project-waypoint/pkg/users"
+)
+
+// quoteMatchLength is how much of a quote's normalised text is looked for in earlier turns.
+const quoteMatchLength = 60
+
+// QuoteRef stands for a quote in a turn.
+type QuoteRef struct {
+	Author string `json:"author,omitempty"` // Quoted user as written in the quote header
+	// Turn is the index of the quoted turn in the conversation, nil if the quote could not be
+	// matched to an earlier turn (e.g. it quotes another topic).
+	Turn *int `json:"turn,omitempty"`
+	// Text is the quoted text, kept unless the quote was matched to a turn by that text.
+	Text string `json:"text,omitempty"`
+}
+
+// Turn is one post of a conversation.
+type Turn struct {
+	Index     int        `json:"index"`
+	PostID    string     `json:"post_id,omitempty"`
+	Author    string     `json:"author"`
+	Timestamp string     `json:"timestamp,omitempty"` // "YYYY-MM-DD HH:MM:SS"
+	Text      string     `json:"text"`                // The author's own text, without quotes
+	Quotes    []QuoteRef `json:"quotes,omitempty"`    // In the order they appear in the post
+}
+
+// Conversation is a topic as a dataset record.
+type Conversation struct {
+	ID         string `json:"id"` // {subforum_id}_{topic_id}
+	SubForumID string `json:"subforum_id"`
+	TopicID    string `json:"topic_id"`
+	Title      string `json:"title,omitempty"`
+	Turns      []Turn `json:"turns"`
+}
+
+// NewConversation turns the posts of a topic into a conversation, with turns in page and post
+// order. Each quote is matched to the latest earlier turn of the quoted user that contains the
+// start of the quoted text, else to that user's turn at the quote's timestamp, else to the latest
+// earlier turn of anyone containing the text. A quote without text is matched to the quoted user's
+// latest earlier turn. Quoted text not found in the matched turn is kept in the QuoteRef.
+func NewConversation(title string, posts []data.PostMetadata) Conversation {
+	ordered := make([]data.PostMetadata, len(posts))
+	copy(ordered, posts)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].PageNumber != ordered[j].PageNumber {
+			return ordered[i].PageNumber < ordered[j].PageNumber
+		}
+		return ordered[i].PostOrderOnPage < ordered[j].PostOrderOnPage
+	})
+
+	var c Conversation
+	if len(ordered) > 0 {
+		c.SubForumID = ordered[0].SubForumID
+		c.TopicID = ordered[0].TopicID
+		c.ID = c.SubForumID + "_" + c.TopicID
+	}
+	c.Title = title
+	c.Turns = make([]Turn, 0, len(ordered))
+	normalised := make([]string, 0, len(ordered)) // Normalised text of each turn, for matching quotes
+
+	for i, post := range ordered {
+		turn := Turn{Index: i, PostID: post.PostID, Author: post.AuthorUsername, Timestamp: post.Timestamp}
+		var paragraphs []string
+		for _, block := range post.ParsedContent {
+			switch block.Type {
+			case data.ContentBlockTypeNewText:
+				if text := outputtree.PlainText(block.Content); text != "" {
+					paragraphs = append(paragraphs, text)
+				}
+			case data.ContentBlockTypeQuote:
+				quote := QuoteRef{Author: block.QuotedUser}
+				j, onText, ok := matchQuote(c.Turns, normalised, block)
+				if ok {
+					quote.Turn = &j
+				}
+				if !onText {
+					quote.Text = outputtree.PlainText(block.QuotedText)
+				}
+				turn.Quotes = append(turn.Quotes, quote)
+			}
+		}
+		turn.Text = strings.Join(paragraphs, "\n\n")
+		c.Turns = append(c.Turns, turn)
+		normalised = append(normalised, normalise(turn.Text))
+	}
+	return c
+}
+
+// matchQuote finds the earlier turn a quote block quotes, and whether the turn was found by the
+// quoted text (so the turn holds it).
+func matchQuote(turns []Turn, normalised []string, block data.ContentBlock) (turn int, onText bool, ok bool) {
+	quoted := normalise(outputtree.PlainText(block.QuotedText))
+	if len(quoted) > quoteMatchLength {
+		quoted = quoted[:quoteMatchLength]
+	}
+	author := users.Key(block.QuotedUser)
+
+	byAuthor, byText, atTimestamp := -1, -1, -1
+	for j := len(turns) - 1; j >= 0; j-- {
+		containsQuote := quoted != "" && strings.Contains(normalised[j], quoted)
+		if author == "" || users.Key(turns[j].Author) != author {
+			if containsQuote && byText < 0 {
+				byText = j
+			}
+			continue
+		}
+		if containsQuote {
+			return j, true, true
+		}
+		if atTimestamp < 0 && block.QuotedTimestamp != "" && turns[j].Timestamp == block.QuotedTimestamp {
+			atTimestamp = j
+		}
+		if byAuthor < 0 {
+			byAuthor = j
+		}
+	}
+	switch {
+	case atTimestamp >= 0:
+		return atTimestamp, false, true
+	case byText >= 0:
+		return byText, true, true
+	case quoted == "" && byAuthor >= 0:
+		return byAuthor, false, true // Nothing to match on but the author
+	}
+	return 0, false, false
+}
+
+// normalise lower-cases text and collapses all whitespace, for matching quotes against turns.
+func normalise(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
@@ -0,0 +1,163 @@
+package dataset
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"project-waypoint/pkg/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func text(content string) data.ContentBlock {
+	return data.ContentBlock{Type: data.ContentBlockTypeNewText, Content: content}
+}
+
+func quote(user, timestamp, quoted string) data.ContentBlock {
+	return data.ContentBlock{Type: data.ContentBlockTypeQuote, QuotedUser: user, QuotedTimestamp: timestamp, QuotedText: quoted}
+}
+
+func turnIndex(i int) *int { return &i }
+
+func TestNewConversation(t *testing.T) {
+	posts := []data.PostMetadata{
+		{PostID: "4", TopicID: "100", SubForumID: "66", PageNumber: 2, AuthorUsername: "carol", Timestamp: "2019-03-04 10:00:00",
+			ParsedContent: []data.ContentBlock{
+				quote("Bob", "", "Who invented it?"),
+				quote("Alice", "2019-03-01 10:00:00", "Something else entirely"),
+				quote("dave", "", "A post from another topic"),
+				text("Nobody knows."),
+			}},
+		{PostID: "1", TopicID: "100", SubForumID: "66", PageNumber: 1, AuthorUsername: "alice", Timestamp: "2019-03-01 10:00:00",
+			ParsedContent: []data.ContentBlock{text("<div>The <b>ambitious</b> card<br/>is a classic &amp; a favourite</div>")}},
+		{PostID: "2", TopicID: "100", SubForumID: "66", PageNumber: 1, PostOrderOnPage: 1, AuthorUsername: "bob", Timestamp: "2019-03-02 10:00:00",
+			ParsedContent: []data.ContentBlock{quote("alice", "", "the ambitious   card is a classic"), text("Who invented it?")}},
+		{PostID: "3", TopicID: "100", SubForumID: "66", PageNumber: 1, PostOrderOnPage: 2, AuthorUsername: "alice", Timestamp: "2019-03-03 10:00:00",
+			ParsedContent: []data.ContentBlock{text("Still a classic.")}},
+	}
+
+	c := NewConversation("Ambitious card", posts)
+	assert.Equal(t, "66_100", c.ID)
+	assert.Equal(t, "Ambitious card", c.Title)
+	require.Len(t, c.Turns, 4)
+	assert.Equal(t, Turn{Index: 0, PostID: "1", Author: "alice", Timestamp: "2019-03-01 10:00:00",
+		Text: "The ambitious card\nis a classic & a favourite"}, c.Turns[0])
+	assert.Equal(t, Turn{Index: 1, PostID: "2", Author: "bob", Timestamp: "2019-03-02 10:00:00", Text: "Who invented it?",
+		Quotes: []QuoteRef{{Author: "alice", Turn: turnIndex(0)}}}, c.Turns[1], "the quoted text matches across the line break")
+	assert.Equal(t, []QuoteRef{
+		{Author: "Bob", Turn: turnIndex(1)},
+		{Author: "Alice", Turn: turnIndex(0), Text: "Something else entirely"}, // No text match: the turn at the quoted timestamp, not alice's latest
+		{Author: "dave", Text: "A post from another topic"},
+	}, c.Turns[3].Quotes)
+	assert.Equal(t, "Nobody knows.", c.Turns[3].Text)
+}
+
+func TestNewConversation_AuthorFallback(t *testing.T) {
+	posts := []data.PostMetadata{
+		{PostOrderOnPage: 0, AuthorUsername: "alice", ParsedContent: []data.ContentBlock{text("First thoughts on the pass.")}},
+		{PostOrderOnPage: 1, AuthorUsername: "alice", ParsedContent: []data.ContentBlock{text("More on the pass.")}},
+		{PostOrderOnPage: 2, AuthorUsername: "bob", ParsedContent: []data.ContentBlock{
+			quote("alice", "", "As I said in the other thread, palm it"), // Her turns here do not hold it
+			quote("Alice", "", ""), // Nothing to match but the author
+			text("Agreed."),
+		}},
+		{PostOrderOnPage: 3, AuthorUsername: "carol", ParsedContent: []data.ContentBlock{quote("dave", "", "Agreed.")}},
+	}
+
+	c := NewConversation("", posts)
+	require.Len(t, c.Turns, 4)
+	assert.Equal(t, []QuoteRef{
+		{Author: "alice", Text: "As I said in the other thread, palm it"},
+		{Author: "Alice", Turn: turnIndex(1)},
+	}, c.Turns[2].Quotes, "the author's earlier turns are not taken for a quote they do not contain")
+	assert.Equal(t, []QuoteRef{{Author: "dave", Turn: turnIndex(2)}}, c.Turns[3].Quotes, "the text found in another user's turn")
+}
+
+func writeTopics(t *testing.T, dir string) {
+	t.Helper()
+	for i := 0; i < 20; i++ {
+		subForum := "66"
+		if i%5 == 0 {
+			subForum = "54"
+		}
+		var posts []data.PostMetadata
+		for j := 0; j <= i%4; j++ {
+			posts = append(posts, data.PostMetadata{TopicID: fmt.Sprint(100 + i), SubForumID: subForum, PostOrderOnPage: j,
+				AuthorUsername: "alice", ParsedContent: []data.ContentBlock{text(fmt.Sprintf("Post %d", j))}})
+		}
+		content, err := json.Marshal(posts)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("%s_%d.json", subForum, 100+i)), content, 0644))
+	}
+}
+
+func readSplit(t *testing.T, path string) []string {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var ids []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var c Conversation
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &c))
+		ids = append(ids, c.ID)
+	}
+	require.NoError(t, scanner.Err())
+	return ids
+}
+
+func TestExport(t *testing.T) {
+	outputDir := t.TempDir()
+	writeTopics(t, outputDir)
+
+	options := Options{OutputDir: outputDir, DestDir: t.TempDir(), MinTurns: 2, ExcludeSubForums: []string{"54"},
+		Train: 0.5, Validation: 0.25, Test: 0.25, Seed: 7}
+	stats, err := Export(options)
+	require.NoError(t, err)
+	assert.Equal(t, 20, stats.Topics)
+	assert.Equal(t, 4, stats.Filtered)
+	assert.Equal(t, 4, stats.TooShort, "topics with one post")
+	assert.Equal(t, SplitStats{Conversations: 6, Turns: stats.Splits[SplitTrain].Turns}, stats.Splits[SplitTrain])
+	assert.Equal(t, 3, stats.Splits[SplitValidation].Conversations)
+	assert.Equal(t, 3, stats.Splits[SplitTest].Conversations)
+
+	train := readSplit(t, filepath.Join(options.DestDir, "train.jsonl"))
+	validation := readSplit(t, filepath.Join(options.DestDir, "validation.jsonl"))
+	test := readSplit(t, filepath.Join(options.DestDir, "test.jsonl"))
+	seen := make(map[string]bool)
+	for _, id := range append(append(append([]string{}, train...), validation...), test...) {
+		assert.False(t, seen[id], "topic %s is in one split only", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, 12)
+
+	// The same seed gives the same splits in the same order; another seed shuffles differently
+	again := options
+	again.DestDir = t.TempDir()
+	_, err = Export(again)
+	require.NoError(t, err)
+	assert.Equal(t, train, readSplit(t, filepath.Join(again.DestDir, "train.jsonl")))
+
+	again.Seed = 8
+	_, err = Export(again)
+	require.NoError(t, err)
+	assert.NotEqual(t, train, readSplit(t, filepath.Join(again.DestDir, "train.jsonl")))
+}
+
+func TestExport_SubForumsAndSingleSplit(t *testing.T) {
+	outputDir := t.TempDir()
+	writeTopics(t, outputDir)
+
+	options := Options{OutputDir: outputDir, DestDir: t.TempDir(), SubForums: []string{"54"}}
+	stats, err := Export(options)
+	require.NoError(t, err)
+	assert.Equal(t, 16, stats.Filtered)
+	assert.Len(t, readSplit(t, filepath.Join(options.DestDir, "train.jsonl")), 4)
+	assert.NoFileExists(t, filepath.Join(options.DestDir, "validation.jsonl"))
+}
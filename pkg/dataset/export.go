@@ -0,0 +1,208 @@
+package dataset
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree"
+)
+
+// Splits, in the order topics are assigned to them.
+const (
+	SplitTrain      = "train"
+	SplitValidation = "validation"
+	SplitTest       = "test"
+)
+
+// Options configures Export.
+type Options struct {
+	OutputDir string            // Extraction output tree to read
+	DestDir   string            // Directory to write {split}.jsonl to
+	Titles    map[string]string // Topic ID to title; may be nil
+	MinTurns  int               // Topics with fewer posts are left out
+	// SubForums, if not empty, keeps only topics of these sub-forum IDs; ExcludeSubForums leaves
+	// out topics of these.
+	SubForums        []string
+	ExcludeSubForums []string
+	// Train, Validation and Test are the shares of topics in each split. They need not add up to
+	// 1; a split with a zero share is not written. All zero puts every topic in train.
+	Train      float64
+	Validation float64
+	Test       float64
+	Seed       int64 // Seeds the shuffle of topics before they are split
+}
+
+// SplitStats counts what went into one split.
+type SplitStats struct {
+	Conversations int `json:"conversations"`
+	Turns         int `json:"turns"`
+}
+
+// Stats summarises an export.
+type Stats struct {
+	Topics         int                   `json:"topics"`          // Output files read
+	Filtered       int                   `json:"filtered"`        // Left out by the sub-forum filters
+	TooShort       int                   `json:"too_short"`       // Left out for having fewer than MinTurns posts
+	Quotes         int                   `json:"quotes"`          // Quotes in exported turns
+	ResolvedQuotes int                   `json:"resolved_quotes"` // Quotes replaced by a reference to a turn
+	Splits         map[string]SplitStats `json:"splits"`
+}
+
+// candidate is a topic that passed the filters, read again when its split is written.
+type candidate struct {
+	id    string
+	path  string
+	split string
+}
+
+// Export writes the dataset. Output files are read twice, once to choose the topics and once to
+// write them, so the whole archive never has to be held in memory. Topics are ordered by ID and
+// shuffled with options.Seed, so the same seed and output tree always give the same splits, and
+// each split's lines come in shuffled order.
+func Export(options Options) (Stats, error) {
+	stats := Stats{Splits: make(map[string]SplitStats)}
+	if options.DestDir == "" {
+		return stats, fmt.Errorf("no dataset directory given")
+	}
+	shares := []struct {
+		name  string
+		share float64
+	}{{SplitTrain, options.Train}, {SplitValidation, options.Validation}, {SplitTest, options.Test}}
+	total := options.Train + options.Validation + options.Test
+	if options.Train < 0 || options.Validation < 0 || options.Test < 0 {
+		return stats, fmt.Errorf("split shares must not be negative")
+	}
+	if total == 0 {
+		shares[0].share, total = 1, 1
+	}
+	include := set(options.SubForums)
+	exclude := set(options.ExcludeSubForums)
+
+	var candidates []candidate
+	err := outputtree.WalkPosts(options.OutputDir, func(path string, posts []data.PostMetadata) error {
+		if len(posts) == 0 {
+			return nil
+		}
+		stats.Topics++
+		subForumID := posts[0].SubForumID
+		if (len(include) > 0 && !include[subForumID]) || exclude[subForumID] {
+			stats.Filtered++
+			return nil
+		}
+		if len(posts) < options.MinTurns {
+			stats.TooShort++
+			return nil
+		}
+		candidates = append(candidates, candidate{id: subForumID + "_" + posts[0].TopicID, path: path})
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to read extraction output %s: %w", options.OutputDir, err)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].id < candidates[j].id })
+	random := rand.New(rand.NewSource(options.Seed))
+	random.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	// Each split takes its share of the shuffled topics, rounded; the last split with a share
+	// takes the rest
+	start, cumulative := 0, 0.0
+	for i, split := range shares {
+		if split.share == 0 {
+			continue
+		}
+		cumulative += split.share
+		end := int(float64(len(candidates))*cumulative/total + 0.5)
+		if cumulative >= total || i == len(shares)-1 {
+			end = len(candidates)
+		}
+		for ; start < end; start++ {
+			candidates[start].split = split.name
+		}
+	}
+
+	if err := os.MkdirAll(options.DestDir, 0755); err != nil {
+		return stats, fmt.Errorf("failed to create dataset directory %s: %w", options.DestDir, err)
+	}
+	for _, split := range shares {
+		if split.share == 0 {
+			continue
+		}
+		if err := writeSplit(options, split.name, candidates, &stats); err != nil {
+			return stats, err
+		}
+	}
+	log.Printf("[INFO] DATASET: Exported %d of %d topics to %s (%d filtered, %d too short)",
+		len(candidates), stats.Topics, options.DestDir, stats.Filtered, stats.TooShort)
+	return stats, nil
+}
+
+// writeSplit writes the conversations of one split to {split}.jsonl, replacing it atomically.
+func writeSplit(options Options, split string, candidates []candidate, stats *Stats) error {
+	path := filepath.Join(options.DestDir, split+".jsonl")
+	tempPath := path + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to create dataset file %s: %w", tempPath, err)
+	}
+	fail := func(err error) error {
+		file.Close()
+		_ = os.Remove(tempPath)
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	splitStats := stats.Splits[split]
+	for _, c := range candidates {
+		if c.split != split {
+			continue
+		}
+		posts, err := outputtree.Read(c.path)
+		if err != nil {
+			return fail(err)
+		}
+		conversation := NewConversation(options.Titles[posts[0].TopicID], posts)
+		if err := encoder.Encode(conversation); err != nil {
+			return fail(fmt.Errorf("failed to write dataset file %s: %w", tempPath, err))
+		}
+		splitStats.Conversations++
+		splitStats.Turns += len(conversation.Turns)
+		for _, turn := range conversation.Turns {
+			for _, quote := range turn.Quotes {
+				stats.Quotes++
+				if quote.Turn != nil {
+					stats.ResolvedQuotes++
+				}
+			}
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fail(fmt.Errorf("failed to write dataset file %s: %w", tempPath, err))
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to close dataset file %s: %w", tempPath, err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to rename dataset file %s to %s: %w", tempPath, path, err)
+	}
+	stats.Splits[split] = splitStats
+	return nil
+}
+
+func set(values []string) map[string]bool {
+	s := make(map[string]bool, len(values))
+	for _, value := range values {
+		s[value] = true
+	}
+	return s
+}
@@ -0,0 +1,169 @@
+// Package errorledger records posts that the extraction pipeline dropped or could only partially
+// extract. Each problem becomes one JSON line in an append-only ledger file, carrying the topic,
+// page file, post index, failing stage, error and the offending post HTML, so failures can be
+// counted and reproduced without searching the extraction logs.
+package errorledger
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Stages of the per-post pipeline at which a problem is recorded.
+const (
+	StageLoadPage      = "load_page"      // the page file could not be read or parsed
+	StagePostBlocks    = "post_blocks"    // the post blocks of the page could not be located
+	StageOuterHTML     = "outer_html"     // the post block could not be serialized or re-parsed
+	StageMetadata      = "metadata"       // ExtractPostMetadata failed
+	StageContentBlock  = "content_block"  // the adapter found no content container
+	StageContentBlocks = "content_blocks" // ParseContentBlocks failed
+	StageCleanText     = "clean_text"     // CleanNewTextBlock failed for a new_text block
+	StagePostURL       = "post_url"       // the post URL could not be built
+)
+
+// Outcomes of a recorded problem.
+const (
+	// OutcomeDropped means the post (or, for page-level stages, every post of the page) is
+	// missing from the topic's JSON output.
+	OutcomeDropped = "dropped"
+	// OutcomeDegraded means the post was written but some of its fields are empty or raw.
+	OutcomeDegraded = "degraded"
+)
+
+// MaxHTMLBytes is the amount of post HTML stored inline in an entry. Longer HTML is cut; the
+// hash of the full HTML and the page file plus post index still identify it.
+const MaxHTMLBytes = 16 * 1024
+
+// Entry is one dropped or degraded post. Page-level failures have a PostIndex of -1.
+type Entry struct {
+	RecordedAt    time.Time `json:"recorded_at"`
+	SubForumID    string    `json:"subforum_id,omitempty"`
+	TopicID       string    `json:"topic_id"`
+	PageFile      string    `json:"page_file"`
+	PostIndex     int       `json:"post_index"` // 0-based position of the post block on the page
+	PostID        string    `json:"post_id,omitempty"`
+	Stage         string    `json:"stage"`
+	Outcome       string    `json:"outcome"`
+	Error         string    `json:"error"`
+	HTMLSHA256    string    `json:"html_sha256,omitempty"`
+	HTMLBytes     int       `json:"html_bytes,omitempty"`
+	HTML          string    `json:"html,omitempty"`
+	HTMLTruncated bool      `json:"html_truncated,omitempty"`
+}
+
+// Ledger appends entries to a JSON Lines file. A nil *Ledger is valid and records nothing, so
+// callers can pass one through unconditionally.
+type Ledger struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	now  func() time.Time
+}
+
+// Open opens the ledger at path for appending, creating it and its directory if needed.
+// Entries from earlier runs are kept.
+func Open(path string) (*Ledger, error) {
+	if path == "" {
+		return nil, fmt.Errorf("error ledger path cannot be empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for error ledger %s: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open error ledger %s: %w", path, err)
+	}
+	log.Printf("[INFO] ERRORLEDGER: Recording dropped and degraded posts to %s", path)
+	return &Ledger{path: path, file: file, now: time.Now}, nil
+}
+
+// Path returns the file the ledger appends to.
+func (l *Ledger) Path() string {
+	if l == nil {
+		return ""
+	}
+	return l.path
+}
+
+// Record appends entry to the ledger. postHTML is the offending post block; its hash and size
+// are always stored, the HTML itself up to MaxHTMLBytes. A zero RecordedAt is set to now.
+func (l *Ledger) Record(entry Entry, postHTML string) error {
+	if l == nil {
+		return nil
+	}
+	if postHTML != "" {
+		sum := sha256.Sum256([]byte(postHTML))
+		entry.HTMLSHA256 = hex.EncodeToString(sum[:])
+		entry.HTMLBytes = len(postHTML)
+		entry.HTML = postHTML
+		if len(postHTML) > MaxHTMLBytes {
+			entry.HTML = postHTML[:MaxHTMLBytes]
+			entry.HTMLTruncated = true
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if entry.RecordedAt.IsZero() {
+		entry.RecordedAt = l.now().UTC()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal error ledger entry: %w", err)
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append to error ledger %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// Close closes the ledger file.
+func (l *Ledger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close error ledger %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// Load reads every entry of the ledger at path.
+func Load(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open error ledger %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	// Entries carry up to MaxHTMLBytes of HTML, which JSON escaping can grow several times
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*MaxHTMLBytes)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal error ledger %s line %d: %w", path, lineNumber, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read error ledger %s: %w", path, err)
+	}
+	return entries, nil
+}
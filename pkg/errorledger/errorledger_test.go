@@ -0,0 +1,92 @@
+package errorledger
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedger_RecordAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "errors.jsonl")
+	ledger, err := Open(path)
+	require.NoError(t, err)
+	recordedAt := time.Date(2026, time.October, 18, 9, 0, 0, 0, time.UTC)
+	ledger.now = func() time.Time { return recordedAt }
+
+	postHTML := "<tr><td>" + strings.Repeat("x", MaxHTMLBytes) + "</td></tr>"
+	require.NoError(t, ledger.Record(Entry{TopicID: "19618", PageFile: "66/19618/page_1.html", PostIndex: 2,
+		Stage: StageMetadata, Outcome: OutcomeDropped, Error: "failed to extract post ID"}, postHTML))
+	require.NoError(t, ledger.Record(Entry{TopicID: "19618", PageFile: "66/19618/page_2.html", PostIndex: -1,
+		Stage: StageLoadPage, Outcome: OutcomeDropped, Error: "no such file"}, ""))
+	require.NoError(t, ledger.Close())
+
+	// Reopening appends to the entries of the earlier run
+	ledger, err = Open(path)
+	require.NoError(t, err)
+	require.NoError(t, ledger.Record(Entry{TopicID: "20001", Stage: StagePostURL, Outcome: OutcomeDegraded}, "<tr></tr>"))
+	require.NoError(t, ledger.Close())
+
+	entries, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, recordedAt, entries[0].RecordedAt)
+	assert.Equal(t, len(postHTML), entries[0].HTMLBytes)
+	assert.Len(t, entries[0].HTML, MaxHTMLBytes)
+	assert.True(t, entries[0].HTMLTruncated)
+	assert.Len(t, entries[0].HTMLSHA256, 64)
+	assert.Empty(t, entries[1].HTMLSHA256, "page-level entries have no post HTML")
+	assert.Equal(t, "<tr></tr>", entries[2].HTML)
+	assert.False(t, entries[2].HTMLTruncated)
+}
+
+func TestLedger_Nil(t *testing.T) {
+	var ledger *Ledger
+	assert.NoError(t, ledger.Record(Entry{Stage: StageMetadata}, "<tr></tr>"))
+	assert.NoError(t, ledger.Close())
+	assert.Empty(t, ledger.Path())
+}
+
+func TestLoad_InvalidLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.jsonl")
+	ledger, err := Open(path)
+	require.NoError(t, err)
+	_, err = ledger.file.WriteString("{not json\n")
+	require.NoError(t, err)
+	require.NoError(t, ledger.Close())
+
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestSummarize(t *testing.T) {
+	entries := []Entry{
+		{SubForumID: "66", TopicID: "1", PageFile: "a/66/1/page_1.html", PostIndex: 0, Stage: StageMetadata, Outcome: OutcomeDropped,
+			Error: "failed to extract post ID: no element in a/66/1/page_1.html"},
+		{SubForumID: "66", TopicID: "2", PageFile: "a/66/2/page_12.html", PostIndex: 7, Stage: StageMetadata, Outcome: OutcomeDropped,
+			Error: "failed to extract post ID: no element in a/66/2/page_12.html"},
+		{SubForumID: "54", TopicID: "3", PageFile: "a/54/3/page_1.html", PostIndex: 1, Stage: StageCleanText, Outcome: OutcomeDegraded,
+			Error: "content block 2: unbalanced tags"},
+	}
+
+	summary := Summarize(entries)
+	assert.Equal(t, 3, summary.Entries)
+	assert.Equal(t, 3, summary.Topics)
+	assert.Equal(t, Counts{Dropped: 2, Degraded: 1}, summary.Totals)
+	assert.Equal(t, &Counts{Dropped: 2}, summary.ByStage[StageMetadata])
+	assert.Equal(t, &Counts{Degraded: 1}, summary.BySubForum["54"])
+	assert.Equal(t, []ErrorCount{
+		{Stage: StageMetadata, Error: "failed to extract post ID: no element in <page>", Count: 2, Example: "a/66/1/page_1.html#0"},
+		{Stage: StageCleanText, Error: "content block N: unbalanced tags", Count: 1, Example: "a/54/3/page_1.html#1"},
+	}, summary.ByError)
+
+	var text bytes.Buffer
+	require.NoError(t, WriteText(&text, summary, 1))
+	assert.Contains(t, text.String(), "dropped 2, degraded 1")
+	assert.Contains(t, text.String(), "Errors (first 1 of 2 kinds):")
+	assert.Contains(t, text.String(), "metadata: failed to extract post ID: no element in <page>")
+}
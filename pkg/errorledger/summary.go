@@ -0,0 +1,170 @@
+package errorledger
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// Counts tallies entries by outcome.
+type Counts struct {
+	Dropped  int `json:"dropped"`
+	Degraded int `json:"degraded"`
+}
+
+func (c *Counts) add(outcome string) {
+	if outcome == OutcomeDropped {
+		c.Dropped++
+	} else {
+		c.Degraded++
+	}
+}
+
+// ErrorCount is one kind of error: entries of a stage whose errors are equal once post-specific
+// details (numbers and the page file) are masked.
+type ErrorCount struct {
+	Stage   string `json:"stage"`
+	Error   string `json:"error"`
+	Count   int    `json:"count"`
+	Example string `json:"example"` // page file and post index of the first entry, e.g. "66/19618/page_2.html#3"
+}
+
+// Summary aggregates a ledger.
+type Summary struct {
+	Entries    int                `json:"entries"`
+	Totals     Counts             `json:"totals"`
+	Topics     int                `json:"topics"`
+	ByStage    map[string]*Counts `json:"by_stage"`
+	BySubForum map[string]*Counts `json:"by_subforum"`
+	ByError    []ErrorCount       `json:"by_error"` // most frequent first
+}
+
+// Summarize aggregates entries by outcome, stage, sub-forum and error kind.
+func Summarize(entries []Entry) *Summary {
+	summary := &Summary{
+		Entries:    len(entries),
+		ByStage:    make(map[string]*Counts),
+		BySubForum: make(map[string]*Counts),
+	}
+	topics := make(map[string]bool)
+	errorIndex := make(map[string]int)
+
+	for _, entry := range entries {
+		summary.Totals.add(entry.Outcome)
+		topics[entry.SubForumID+"/"+entry.TopicID] = true
+
+		if summary.ByStage[entry.Stage] == nil {
+			summary.ByStage[entry.Stage] = &Counts{}
+		}
+		summary.ByStage[entry.Stage].add(entry.Outcome)
+		if summary.BySubForum[entry.SubForumID] == nil {
+			summary.BySubForum[entry.SubForumID] = &Counts{}
+		}
+		summary.BySubForum[entry.SubForumID].add(entry.Outcome)
+
+		kind := ErrorKind(entry)
+		key := entry.Stage + "\x00" + kind
+		if i, ok := errorIndex[key]; ok {
+			summary.ByError[i].Count++
+			continue
+		}
+		errorIndex[key] = len(summary.ByError)
+		summary.ByError = append(summary.ByError, ErrorCount{
+			Stage:   entry.Stage,
+			Error:   kind,
+			Count:   1,
+			Example: fmt.Sprintf("%s#%d", entry.PageFile, entry.PostIndex),
+		})
+	}
+	summary.Topics = len(topics)
+
+	sort.SliceStable(summary.ByError, func(i, j int) bool {
+		return summary.ByError[i].Count > summary.ByError[j].Count
+	})
+	return summary
+}
+
+// ErrorKind returns the entry's error with the page file replaced by "<page>" and every run of
+// digits replaced by "N", so the same failure on different posts groups together.
+func ErrorKind(entry Entry) string {
+	message := entry.Error
+	if entry.PageFile != "" {
+		message = strings.ReplaceAll(message, entry.PageFile, "<page>")
+	}
+	var b strings.Builder
+	inDigits := false
+	for _, r := range message {
+		if unicode.IsDigit(r) {
+			if !inDigits {
+				b.WriteByte('N')
+			}
+			inDigits = true
+			continue
+		}
+		inDigits = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// WriteText writes a human-readable summary listing at most maxErrors error kinds (all of them
+// if maxErrors is negative).
+func WriteText(w io.Writer, summary *Summary, maxErrors int) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Entries:\t%d (dropped %d, degraded %d) in %d topics\n",
+		summary.Entries, summary.Totals.Dropped, summary.Totals.Degraded, summary.Topics)
+	if summary.Entries == 0 {
+		return tw.Flush()
+	}
+
+	fmt.Fprintf(tw, "\nBy stage:\n  stage\tdropped\tdegraded\n")
+	for _, stage := range sortedKeys(summary.ByStage) {
+		c := summary.ByStage[stage]
+		fmt.Fprintf(tw, "  %s\t%d\t%d\n", stage, c.Dropped, c.Degraded)
+	}
+
+	fmt.Fprintf(tw, "\nBy sub-forum:\n  sub-forum\tdropped\tdegraded\n")
+	for _, subForumID := range sortedKeys(summary.BySubForum) {
+		c := summary.BySubForum[subForumID]
+		name := subForumID
+		if name == "" {
+			name = "(unknown)"
+		}
+		fmt.Fprintf(tw, "  %s\t%d\t%d\n", name, c.Dropped, c.Degraded)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	errors := summary.ByError
+	if maxErrors >= 0 && len(errors) > maxErrors {
+		fmt.Fprintf(w, "\nErrors (first %d of %d kinds):\n", maxErrors, len(errors))
+		errors = errors[:maxErrors]
+	} else {
+		fmt.Fprintf(w, "\nErrors:\n")
+	}
+	for _, e := range errors {
+		fmt.Fprintf(w, "  %6d  %s: %s\n          e.g. %s\n", e.Count, e.Stage, e.Error, e.Example)
+	}
+	return nil
+}
+
+// sortedKeys orders the keys by total count, most frequent first.
+func sortedKeys(counts map[string]*Counts) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ci, cj := counts[keys[i]], counts[keys[j]]
+		if ci.Dropped+ci.Degraded != cj.Dropped+cj.Degraded {
+			return ci.Dropped+ci.Degraded > cj.Dropped+cj.Degraded
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
@@ -0,0 +1,206 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree"
+	"project-waypoint/pkg/users"
+
+	wdata "waypoint_archive_scripts/pkg/data"
+)
+
+// Column is a field of an exported row. Values are strings, ints or bools, so NDJSON keeps their
+// type and CSV writes them as text.
+type Column struct {
+	Name        string
+	Description string
+	value       func(r *row) any
+}
+
+// row is what a column reads: a post with its topic for post rows, a topic alone for topic rows.
+type row struct {
+	post  *data.PostMetadata
+	topic *topicSummary
+}
+
+// topicSummary is a topic as the index describes it, with what its output file holds.
+type topicSummary struct {
+	topicID    string
+	subForumID string
+	index      *wdata.Topic // Nil when the topic is not in the topic index
+	extracted  bool         // Whether the topic has an output file
+	posts      int
+	pages      int
+	quotes     int
+	authors    map[string]bool // users.Key of each author
+	firstPost  string          // Earliest and latest post timestamps ("YYYY-MM-DD HH:MM:SS" sort by time)
+	lastPost   string
+}
+
+func newTopicSummary(topicID string, subForumID string, index *wdata.Topic) *topicSummary {
+	return &topicSummary{topicID: topicID, subForumID: subForumID, index: index, authors: make(map[string]bool)}
+}
+
+// add counts a post of the topic.
+func (t *topicSummary) add(post *data.PostMetadata) {
+	t.posts++
+	if post.PageNumber > t.pages {
+		t.pages = post.PageNumber
+	}
+	for _, block := range post.ParsedContent {
+		if block.Type == data.ContentBlockTypeQuote {
+			t.quotes++
+		}
+	}
+	if key := users.Key(post.AuthorUsername); key != "" {
+		t.authors[key] = true
+	}
+	if post.Timestamp != "" {
+		if t.firstPost == "" || post.Timestamp < t.firstPost {
+			t.firstPost = post.Timestamp
+		}
+		if post.Timestamp > t.lastPost {
+			t.lastPost = post.Timestamp
+		}
+	}
+}
+
+// indexed returns a field of the topic's index entry, or its zero value when there is none.
+func indexed[T any](r *row, field func(topic *wdata.Topic) T) T {
+	var zero T
+	if r.topic == nil || r.topic.index == nil {
+		return zero
+	}
+	return field(r.topic.index)
+}
+
+// PostColumns are the columns of post rows, in their default order; DefaultPostColumns names
+// those exported when none are selected.
+var PostColumns = []Column{
+	{"post_id", "Post ID", func(r *row) any { return r.post.PostID }},
+	{"topic_id", "Topic ID", func(r *row) any { return r.post.TopicID }},
+	{"subforum_id", "Sub-forum ID", func(r *row) any { return r.post.SubForumID }},
+	{"page_number", "Page of the topic the post is on", func(r *row) any { return r.post.PageNumber }},
+	{"post_order_on_page", "Position of the post on its page", func(r *row) any { return r.post.PostOrderOnPage }},
+	{"post_url", "Canonical URL of the post", func(r *row) any { return r.post.PostURL }},
+	{"author_username", "Author", func(r *row) any { return r.post.AuthorUsername }},
+	{"timestamp", "Posting time, YYYY-MM-DD HH:MM:SS", func(r *row) any { return r.post.Timestamp }},
+	{"text", "The author's own text, as plain text", func(r *row) any { return postText(r.post, false) }},
+	{"content", "All content as plain text, quotes as lines starting with \"> \"", func(r *row) any { return postText(r.post, true) }},
+	{"quoted_users", "Users quoted, separated by \"; \"", func(r *row) any { return quotedUsers(r.post) }},
+	{"quote_count", "Number of quotes", func(r *row) any { return countBlocks(r.post, data.ContentBlockTypeQuote) }},
+	{"word_count", "Words in the author's own text", func(r *row) any { return len(strings.Fields(postText(r.post, false))) }},
+	{"topic_title", "Topic title (topic index)", func(r *row) any { return indexed(r, func(t *wdata.Topic) string { return t.Title }) }},
+	{"topic_author", "Topic starter (topic index)", func(r *row) any { return indexed(r, func(t *wdata.Topic) string { return t.AuthorUsername }) }},
+	{"topic_url", "Topic URL (topic index)", func(r *row) any { return indexed(r, func(t *wdata.Topic) string { return t.URL }) }},
+	{"topic_replies", "Topic replies (topic index)", func(r *row) any { return indexed(r, func(t *wdata.Topic) int { return t.Replies }) }},
+	{"topic_views", "Topic views (topic index)", func(r *row) any { return indexed(r, func(t *wdata.Topic) int { return t.Views }) }},
+}
+
+// DefaultPostColumns are the post columns exported when none are selected.
+var DefaultPostColumns = []string{"post_id", "topic_id", "subforum_id", "page_number", "post_order_on_page",
+	"author_username", "timestamp", "topic_title", "quote_count", "text"}
+
+// TopicColumns are the columns of topic rows. Index columns are empty for topics not in the topic
+// index; extraction columns are zero for topics without an output file.
+var TopicColumns = []Column{
+	{"topic_id", "Topic ID", func(r *row) any { return r.topic.topicID }},
+	{"subforum_id", "Sub-forum ID", func(r *row) any { return r.topic.subForumID }},
+	{"title", "Title (topic index)", func(r *row) any { return indexed(r, func(t *wdata.Topic) string { return t.Title }) }},
+	{"url", "URL (topic index)", func(r *row) any { return indexed(r, func(t *wdata.Topic) string { return t.URL }) }},
+	{"author_username", "Topic starter (topic index)", func(r *row) any { return indexed(r, func(t *wdata.Topic) string { return t.AuthorUsername }) }},
+	{"replies", "Replies (topic index)", func(r *row) any { return indexed(r, func(t *wdata.Topic) int { return t.Replies }) }},
+	{"views", "Views (topic index)", func(r *row) any { return indexed(r, func(t *wdata.Topic) int { return t.Views }) }},
+	{"sticky", "Sticky (topic index)", func(r *row) any { return indexed(r, func(t *wdata.Topic) bool { return t.IsSticky }) }},
+	{"locked", "Locked (topic index)", func(r *row) any { return indexed(r, func(t *wdata.Topic) bool { return t.IsLocked }) }},
+	{"last_post_username", "Last poster (topic index)", func(r *row) any { return indexed(r, func(t *wdata.Topic) string { return t.LastPostUsername }) }},
+	{"last_post_timestamp", "Last post time as the index has it (topic index)", func(r *row) any {
+		return indexed(r, func(t *wdata.Topic) string { return t.LastPostTimestampRaw })
+	}},
+	{"extracted", "Whether the topic has an output file", func(r *row) any { return r.topic.extracted }},
+	{"posts", "Posts extracted", func(r *row) any { return r.topic.posts }},
+	{"pages", "Highest page extracted", func(r *row) any { return r.topic.pages }},
+	{"authors", "Distinct authors of extracted posts", func(r *row) any { return len(r.topic.authors) }},
+	{"quotes", "Quotes in extracted posts", func(r *row) any { return r.topic.quotes }},
+	{"first_post_at", "Time of the earliest extracted post", func(r *row) any { return r.topic.firstPost }},
+	{"last_post_at", "Time of the latest extracted post", func(r *row) any { return r.topic.lastPost }},
+}
+
+// DefaultTopicColumns are the topic columns exported when none are selected.
+var DefaultTopicColumns = []string{"topic_id", "subforum_id", "title", "author_username", "replies", "views",
+	"posts", "authors", "first_post_at", "last_post_at"}
+
+// selectColumns returns the columns named, in the order named.
+func selectColumns(available []Column, names []string) ([]Column, error) {
+	byName := make(map[string]Column, len(available))
+	for _, column := range available {
+		byName[column.Name] = column
+	}
+	selected := make([]Column, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		column, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("column %q selected twice", name)
+		}
+		seen[name] = true
+		selected = append(selected, column)
+	}
+	return selected, nil
+}
+
+// postText flattens a post's content to plain text, one paragraph per block. With quotes, quote
+// blocks are included as "user wrote:" and the quoted lines prefixed "> ".
+func postText(post *data.PostMetadata, quotes bool) string {
+	var paragraphs []string
+	for _, block := range post.ParsedContent {
+		switch block.Type {
+		case data.ContentBlockTypeNewText:
+			if text := outputtree.PlainText(block.Content); text != "" {
+				paragraphs = append(paragraphs, text)
+			}
+		case data.ContentBlockTypeQuote:
+			if !quotes {
+				continue
+			}
+			var lines []string
+			if block.QuotedUser != "" {
+				lines = append(lines, block.QuotedUser+" wrote:")
+			}
+			for _, line := range strings.Split(outputtree.PlainText(block.QuotedText), "\n") {
+				if line != "" {
+					lines = append(lines, "> "+line)
+				}
+			}
+			if len(lines) > 0 {
+				paragraphs = append(paragraphs, strings.Join(lines, "\n"))
+			}
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func quotedUsers(post *data.PostMetadata) string {
+	var names []string
+	for _, block := range post.ParsedContent {
+		if block.Type == data.ContentBlockTypeQuote && block.QuotedUser != "" {
+			names = append(names, block.QuotedUser)
+		}
+	}
+	return strings.Join(names, "; ")
+}
+
+func countBlocks(post *data.PostMetadata, blockType data.ContentBlockType) int {
+	count := 0
+	for _, block := range post.ParsedContent {
+		if block.Type == blockType {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,296 @@
+// Package export writes the extraction output as flat files for spreadsheets, DuckDB or pandas:
+// one row per post or one row per topic, as NDJSON or CSV, optionally gzipped. Columns are chosen
+// from the post fields, the topic index and content flattened to plain text (see PostColumns and
+// TopicColumns).
+//
+// Output files are streamed one post at a time, so the whole archive can be exported without
+// holding it in memory; only the topic index and, for topic rows, one topic's summary are kept.
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"project-waypoint/pkg/completeness"
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree"
+
+	wdata "waypoint_archive_scripts/pkg/data"
+)
+
+// Formats.
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// Row kinds.
+const (
+	KindPosts  = "posts"
+	KindTopics = "topics"
+)
+
+// Options configures Export.
+type Options struct {
+	OutputDir     string // Extraction output tree to read
+	TopicIndexDir string // Topic index for the topic columns; empty leaves them empty
+	Kind          string // KindPosts (default) or KindTopics
+	Format        string // FormatNDJSON (default) or FormatCSV
+	// Columns names the columns to write, in order; empty writes the kind's default columns.
+	Columns []string
+	Gzip    bool // Compress the output with gzip
+}
+
+// Stats summarises an export.
+type Stats struct {
+	Files int // Output files read
+	Posts int // Posts read
+	Rows  int // Rows written
+}
+
+// Export writes the rows of options.Kind to w. Post rows come in output file order, and in each
+// file in the order of its posts. Topic rows come in output file order too; with a topic index,
+// indexed topics without an output file follow, by sub-forum and topic ID.
+func Export(w io.Writer, options Options) (Stats, error) {
+	var stats Stats
+	kind := options.Kind
+	if kind == "" {
+		kind = KindPosts
+	}
+	var available []Column
+	names := options.Columns
+	switch kind {
+	case KindPosts:
+		available = PostColumns
+		if len(names) == 0 {
+			names = DefaultPostColumns
+		}
+	case KindTopics:
+		available = TopicColumns
+		if len(names) == 0 {
+			names = DefaultTopicColumns
+		}
+	default:
+		return stats, fmt.Errorf("unknown export kind %q (use %s or %s)", kind, KindPosts, KindTopics)
+	}
+	columns, err := selectColumns(available, names)
+	if err != nil {
+		return stats, err
+	}
+
+	var compressed *gzip.Writer
+	if options.Gzip {
+		compressed = gzip.NewWriter(w)
+		w = compressed
+	}
+	buffered := bufio.NewWriter(w)
+	encoder, err := newEncoder(buffered, options.Format, columns)
+	if err != nil {
+		return stats, err
+	}
+
+	index := make(map[string]*wdata.Topic)
+	if options.TopicIndexDir != "" {
+		topics, err := completeness.LoadTopicIndex(options.TopicIndexDir)
+		if err != nil {
+			return stats, err
+		}
+		for i := range topics {
+			index[topics[i].ID] = &topics[i]
+		}
+	}
+
+	writeRow := func(r *row) error {
+		values := make([]any, len(columns))
+		for i, column := range columns {
+			values[i] = column.value(r)
+		}
+		stats.Rows++
+		return encoder.write(values)
+	}
+	exported := make(map[string]bool)
+	err = outputtree.Walk(options.OutputDir, func(path string) error {
+		stats.Files++
+		var topic *topicSummary
+		err := outputtree.Stream(path, func(post *data.PostMetadata) error {
+			stats.Posts++
+			if topic == nil {
+				topic = newTopicSummary(post.TopicID, post.SubForumID, index[post.TopicID])
+				topic.extracted = true
+			}
+			if kind == KindTopics {
+				topic.add(post)
+				return nil
+			}
+			return writeRow(&row{post: post, topic: topic})
+		})
+		if err != nil || kind != KindTopics || topic == nil {
+			return err
+		}
+		exported[topic.topicID] = true
+		return writeRow(&row{topic: topic})
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to export extraction output %s: %w", options.OutputDir, err)
+	}
+
+	if kind == KindTopics {
+		var missing []*wdata.Topic
+		for id, topic := range index {
+			if !exported[id] {
+				missing = append(missing, topic)
+			}
+		}
+		sort.Slice(missing, func(i, j int) bool {
+			if missing[i].SubForumID != missing[j].SubForumID {
+				return completeness.IDLess(missing[i].SubForumID, missing[j].SubForumID)
+			}
+			return completeness.IDLess(missing[i].ID, missing[j].ID)
+		})
+		for _, topic := range missing {
+			if err := writeRow(&row{topic: newTopicSummary(topic.ID, topic.SubForumID, topic)}); err != nil {
+				return stats, fmt.Errorf("failed to write export: %w", err)
+			}
+		}
+	}
+
+	if err := encoder.flush(); err != nil {
+		return stats, fmt.Errorf("failed to write export: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return stats, fmt.Errorf("failed to write export: %w", err)
+	}
+	if compressed != nil {
+		if err := compressed.Close(); err != nil {
+			return stats, fmt.Errorf("failed to write export: %w", err)
+		}
+	}
+	log.Printf("[INFO] EXPORT: Wrote %d %s rows from %d posts in %d files", stats.Rows, kind, stats.Posts, stats.Files)
+	return stats, nil
+}
+
+// ExportFile is Export to the file at path, which is replaced atomically via a temporary file.
+func ExportFile(path string, options Options) (Stats, error) {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return Stats{}, fmt.Errorf("failed to create directory for export %s: %w", path, err)
+		}
+	}
+	tempPath := path + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to create temporary export %s: %w", tempPath, err)
+	}
+	stats, err := Export(file, options)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write export %s: %w", tempPath, closeErr)
+	}
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return stats, err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return stats, fmt.Errorf("failed to rename temporary export %s to %s: %w", tempPath, path, err)
+	}
+	return stats, nil
+}
+
+// encoder writes rows in one format.
+type encoder interface {
+	write(values []any) error
+	flush() error
+}
+
+func newEncoder(w io.Writer, format string, columns []Column) (encoder, error) {
+	switch format {
+	case FormatNDJSON, "":
+		names := make([][]byte, len(columns))
+		for i, column := range columns {
+			names[i], _ = json.Marshal(column.Name)
+		}
+		encoder := &ndjsonEncoder{w: w, names: names}
+		encoder.values = json.NewEncoder(&encoder.line)
+		encoder.values.SetEscapeHTML(false)
+		return encoder, nil
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.Name
+		}
+		if err := writer.Write(header); err != nil {
+			return nil, fmt.Errorf("failed to write CSV header: %w", err)
+		}
+		return &csvEncoder{writer: writer, record: make([]string, len(columns))}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q (use %s or %s)", format, FormatNDJSON, FormatCSV)
+	}
+}
+
+// ndjsonEncoder writes each row as a JSON object on one line, keys in column order. Text is not
+// HTML-escaped, so "<" and "&" stay readable.
+type ndjsonEncoder struct {
+	w      io.Writer
+	names  [][]byte
+	line   bytes.Buffer
+	values *json.Encoder // Encodes into line
+}
+
+func (e *ndjsonEncoder) write(values []any) error {
+	e.line.Reset()
+	e.line.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			e.line.WriteByte(',')
+		}
+		e.line.Write(e.names[i])
+		e.line.WriteByte(':')
+		if err := e.values.Encode(value); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", e.names[i], err)
+		}
+		e.line.Truncate(e.line.Len() - 1) // Encode ends each value with a newline
+	}
+	e.line.WriteString("}\n")
+	_, err := e.w.Write(e.line.Bytes())
+	return err
+}
+
+func (e *ndjsonEncoder) flush() error { return nil }
+
+// csvEncoder writes rows as RFC 4180 CSV under a header of the column names.
+type csvEncoder struct {
+	writer *csv.Writer
+	record []string
+}
+
+func (e *csvEncoder) write(values []any) error {
+	for i, value := range values {
+		switch v := value.(type) {
+		case string:
+			e.record[i] = v
+		case int:
+			e.record[i] = strconv.Itoa(v)
+		case bool:
+			e.record[i] = strconv.FormatBool(v)
+		default:
+			e.record[i] = fmt.Sprint(v)
+		}
+	}
+	return e.writer.Write(e.record)
+}
+
+func (e *csvEncoder) flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
@@ -0,0 +1,107 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"project-waypoint/pkg/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeArchive(t *testing.T) (outputDir string, indexDir string) {
+	t.Helper()
+	dir := t.TempDir()
+	outputDir, indexDir = filepath.Join(dir, "output"), filepath.Join(dir, "index", "forum_66")
+	require.NoError(t, os.MkdirAll(outputDir, 0755))
+	require.NoError(t, os.MkdirAll(indexDir, 0755))
+
+	posts := []data.PostMetadata{
+		{PostID: "1", TopicID: "100", SubForumID: "66", PageNumber: 1, PostOrderOnPage: 1, AuthorUsername: "Dai Vernon", Timestamp: "2004-01-02 10:00:00",
+			ParsedContent: []data.ContentBlock{{Type: data.ContentBlockTypeNewText, Content: "The <b>Ambitious</b> Card,<br>again &amp; again."}}},
+		{PostID: "2", TopicID: "100", SubForumID: "66", PageNumber: 2, PostOrderOnPage: 1, AuthorUsername: "slydini", Timestamp: "2004-01-01 09:00:00",
+			ParsedContent: []data.ContentBlock{
+				{Type: data.ContentBlockTypeQuote, QuotedUser: "Dai Vernon", QuotedText: "The Ambitious Card"},
+				{Type: data.ContentBlockTypeNewText, Content: "Agreed, \"fooled\" me."},
+			}},
+	}
+	content, err := json.MarshalIndent(posts, "", "  ")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "66_100.json"), content, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "66_101.json"), []byte("[]"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "topic_index_66.json"),
+		[]byte(`[{"ID":"100","Title":"Ambitious, again","AuthorUsername":"Dai Vernon","Replies":1,"Views":40},{"ID":"102","Title":"Not extracted","Replies":3}]`), 0644))
+	return outputDir, filepath.Dir(indexDir)
+}
+
+func TestExport_PostsNDJSON(t *testing.T) {
+	outputDir, indexDir := writeArchive(t)
+	var out bytes.Buffer
+	stats, err := Export(&out, Options{OutputDir: outputDir, TopicIndexDir: indexDir,
+		Columns: []string{"post_id", "page_number", "topic_title", "text", "content", "quoted_users", "quote_count", "word_count"}})
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Files: 2, Posts: 2, Rows: 2}, stats)
+
+	assert.Equal(t, `{"post_id":"1","page_number":1,"topic_title":"Ambitious, again","text":"The Ambitious Card,\nagain & again.","content":"The Ambitious Card,\nagain & again.","quoted_users":"","quote_count":0,"word_count":6}`+"\n"+
+		`{"post_id":"2","page_number":2,"topic_title":"Ambitious, again","text":"Agreed, \"fooled\" me.","content":"Dai Vernon wrote:\n> The Ambitious Card\n\nAgreed, \"fooled\" me.","quoted_users":"Dai Vernon","quote_count":1,"word_count":3}`+"\n",
+		out.String())
+}
+
+func TestExport_TopicsCSVGzip(t *testing.T) {
+	outputDir, indexDir := writeArchive(t)
+	path := filepath.Join(t.TempDir(), "exports", "topics.csv.gz")
+	stats, err := ExportFile(path, Options{OutputDir: outputDir, TopicIndexDir: indexDir, Kind: KindTopics, Format: FormatCSV, Gzip: true,
+		Columns: []string{"topic_id", "title", "replies", "extracted", "posts", "pages", "authors", "quotes", "first_post_at", "last_post_at"}})
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Files: 2, Posts: 2, Rows: 2}, stats)
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	records, err := csv.NewReader(reader).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"topic_id", "title", "replies", "extracted", "posts", "pages", "authors", "quotes", "first_post_at", "last_post_at"},
+		{"100", "Ambitious, again", "1", "true", "2", "2", "2", "1", "2004-01-01 09:00:00", "2004-01-02 10:00:00"},
+		{"102", "Not extracted", "3", "false", "0", "0", "0", "0", "", ""},
+	}, records)
+	assert.NoFileExists(t, path+".tmp")
+}
+
+func TestExport_DefaultsWithoutIndex(t *testing.T) {
+	outputDir, _ := writeArchive(t)
+	var out bytes.Buffer
+	_, err := Export(&out, Options{OutputDir: outputDir, Format: FormatCSV})
+	require.NoError(t, err)
+	records, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, DefaultPostColumns, records[0])
+	assert.Equal(t, []string{"1", "100", "66", "1", "1", "Dai Vernon", "2004-01-02 10:00:00", "", "0", "The Ambitious Card,\nagain & again."}, records[1])
+}
+
+func TestExport_Errors(t *testing.T) {
+	outputDir, _ := writeArchive(t)
+	_, err := Export(io.Discard, Options{OutputDir: outputDir, Columns: []string{"post_id", "title"}})
+	assert.ErrorContains(t, err, `unknown column "title"`)
+	_, err = Export(io.Discard, Options{OutputDir: outputDir, Columns: []string{"post_id", "post_id"}})
+	assert.ErrorContains(t, err, `column "post_id" selected twice`)
+	_, err = Export(io.Discard, Options{OutputDir: outputDir, Format: "xlsx"})
+	assert.ErrorContains(t, err, `unknown export format "xlsx"`)
+	_, err = Export(io.Discard, Options{OutputDir: outputDir, Kind: "users"})
+	assert.ErrorContains(t, err, `unknown export kind "users"`)
+
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "66_102.json"), []byte(`{"post_id":"1"}`), 0644))
+	_, err = Export(io.Discard, Options{OutputDir: outputDir})
+	assert.ErrorContains(t, err, "does not hold a JSON array of posts")
+}
@@ -2,32 +2,32 @@ package extractorlogic
 
 import (
 	"fmt"
-	"log"
 	"path/filepath"
 	"strconv"
 	"strings"
 
 	"project-waypoint/pkg/data"
-	"project-waypoint/pkg/parser"
 
 	"github.com/PuerkitoBio/goquery"
+
+	"waypoint_archive_scripts/pkg/forumadapter"
 )
 
-// ExtractPostMetadata extracts all core metadata from a post HTML block and its file path.
+// ExtractPostMetadata extracts all core metadata from a post HTML block and its file path,
+// using the process-wide forum adapter.
 func ExtractPostMetadata(postHTMLBlock *goquery.Document, filePath string) (data.PostMetadata, error) {
+	return ExtractPostMetadataWith(forumadapter.Default(), postHTMLBlock, filePath)
+}
+
+// ExtractPostMetadataWith is ExtractPostMetadata for a post archived from the given forum engine.
+// PostOrderOnPage is -1 when the engine does not mark it; callers then use the block's position.
+func ExtractPostMetadataWith(adapter forumadapter.ForumAdapter, postHTMLBlock *goquery.Document, filePath string) (data.PostMetadata, error) {
 	if postHTMLBlock == nil {
 		return data.PostMetadata{}, fmt.Errorf("postHTMLBlock is nil")
 	}
-	// Log the outer HTML of the received document's selection to see its full structure
-	docHTML, err := goquery.OuterHtml(postHTMLBlock.Selection)
-	if err != nil {
-		log.Printf("[DEBUG Extractor] Error getting OuterHtml for postHTMLBlock: %v", err)
-	} else {
-		log.Printf("[DEBUG Extractor] Received postHTMLBlock OuterHtml:\n%s", docHTML)
-	}
-
 	var metadata data.PostMetadata
 	var errs []string // To collect multiple errors
+	var err error
 
 	// --- Contextual Metadata from filePath (Subtasks 6.2, 6.3) ---
 	cleanPath := filepath.ToSlash(filePath)
@@ -52,31 +52,18 @@ func ExtractPostMetadata(postHTMLBlock *goquery.Document, filePath string) (data
 	}
 
 	// --- Metadata from HTML content (Subtask 7.1) ---
-
-	// AuthorUsername (Task 2)
-	metadata.AuthorUsername, err = parser.ExtractAuthorUsername(postHTMLBlock)
-	if err != nil {
-		errs = append(errs, fmt.Sprintf("failed to extract author username: %s", err.Error()))
-		// AC8: Log warning/error but attempt to extract as much as possible.
-		// Depending on logging strategy, this could be logged here or by the caller.
-	}
-
-	// Timestamp (Task 3)
-	metadata.Timestamp, err = parser.ExtractTimestamp(postHTMLBlock)
-	if err != nil {
-		errs = append(errs, fmt.Sprintf("failed to extract timestamp: %s", err.Error()))
+	// The forum adapter knows where the author (Task 2), timestamp (Task 3), post ID (Task 4)
+	// and post order (Task 5) are in a post block. Fields it could not extract come back as
+	// separate errors so as much as possible is still extracted (AC8).
+	postMetadata, err := adapter.PostMetadata(postHTMLBlock.Selection)
+	metadata.AuthorUsername = postMetadata.AuthorUsername
+	metadata.PostID = postMetadata.PostID
+	metadata.PostOrderOnPage = postMetadata.OrderOnPage
+	if !postMetadata.Timestamp.IsZero() {
+		metadata.Timestamp = postMetadata.Timestamp.Format("2006-01-02 15:04:05")
 	}
-
-	// PostID (Task 4)
-	metadata.PostID, err = parser.ExtractPostID(postHTMLBlock)
-	if err != nil {
-		errs = append(errs, fmt.Sprintf("failed to extract post ID: %s", err.Error()))
-	}
-
-	// PostOrderOnPage (Task 5)
-	metadata.PostOrderOnPage, err = parser.ExtractPostOrderOnPage(postHTMLBlock)
-	if err != nil {
-		errs = append(errs, fmt.Sprintf("failed to extract post order on page: %s", err.Error()))
+	for _, fieldErr := range forumadapter.FieldErrors(err) {
+		errs = append(errs, fmt.Sprintf("failed to extract %s", fieldErr.Error()))
 	}
 
 	// Subtask 7.2: Comprehensive error handling and aggregation
@@ -0,0 +1,201 @@
+package graph
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree"
+	"project-waypoint/pkg/users"
+
+	"waypoint_archive_scripts/pkg/canonurl"
+	"waypoint_archive_scripts/pkg/forumadapter"
+)
+
+// Kinds of interaction.
+const (
+	KindQuote = "quote" // A post quotes another user
+	KindReply = "reply" // A post directly follows another user's post in a topic
+	KindLink  = "link"  // A post links to another topic
+)
+
+// UserAttrs and TopicAttrs are the node attributes of the two graphs.
+var (
+	UserAttrs  = []AttrDef{{"posts", TypeInt}}
+	TopicAttrs = []AttrDef{{"subforum", TypeString}, {"posts", TypeInt}, {"archived", TypeBool}}
+)
+
+// Options configures Build.
+type Options struct {
+	OutputDir string            // Extraction output tree
+	Titles    map[string]string // Topic ID to title, for topic labels; may be nil
+	// Adapter reads topic links; nil uses forumadapter.Default(). Links to other hosts than the
+	// adapter's forum are ignored.
+	Adapter forumadapter.ForumAdapter
+}
+
+// topicLink matches links to topic and post pages in post content, relative or absolute.
+var topicLink = regexp.MustCompile(`(?i)(?:https?://[^\s"'<>]*?)?viewtopic\.php\?[^\s"'<>]*`)
+
+// link is a topic link found in a post, resolved once all posts are known.
+type link struct {
+	source    string // Topic ID
+	ref       canonurl.Ref
+	timestamp string
+}
+
+// builder accumulates both graphs over the output files.
+type builder struct {
+	options   Options
+	forumHost string
+	users     *Graph
+	topics    *Graph
+	spellings map[string]map[string]int // User key to author spellings to posts
+	quoted    map[string]string         // User key to a quoted spelling, for users who never posted
+	postTopic map[string]string         // Post ID to topic ID
+	links     []link
+}
+
+// Build reads every output JSON file under options.OutputDir and returns the user graph and the
+// topic graph.
+func Build(options Options) (*Graph, *Graph, error) {
+	if options.Adapter == nil {
+		options.Adapter = forumadapter.Default()
+	}
+	b := &builder{
+		options:   options,
+		users:     New("users", UserAttrs, []string{KindQuote, KindReply}),
+		topics:    New("topics", TopicAttrs, []string{KindLink}),
+		spellings: make(map[string]map[string]int),
+		quoted:    make(map[string]string),
+		postTopic: make(map[string]string),
+	}
+	if home, err := options.Adapter.ParseURL(options.Adapter.FormatURL(canonurl.TopicPage("1", "1", 0))); err == nil {
+		b.forumHost = home.Host
+	}
+
+	err := outputtree.WalkPosts(options.OutputDir, func(_ string, posts []data.PostMetadata) error {
+		b.addTopic(posts)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build interaction graphs from %s: %w", options.OutputDir, err)
+	}
+	b.finish()
+	return b.users, b.topics, nil
+}
+
+func (b *builder) addTopic(posts []data.PostMetadata) {
+	if len(posts) == 0 {
+		return
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		if posts[i].PageNumber != posts[j].PageNumber {
+			return posts[i].PageNumber < posts[j].PageNumber
+		}
+		return posts[i].PostOrderOnPage < posts[j].PostOrderOnPage
+	})
+	topic := b.topics.Node(posts[0].TopicID)
+	topic.Values["subforum"] = posts[0].SubForumID
+	topic.Values["archived"] = "true"
+	topic.Values["posts"] = strconv.Itoa(len(posts))
+
+	for i, post := range posts {
+		if post.PostID != "" {
+			b.postTopic[post.PostID] = post.TopicID
+		}
+		author := users.Key(post.AuthorUsername)
+		if author == "" {
+			continue
+		}
+		b.users.Node(author)
+		if b.spellings[author] == nil {
+			b.spellings[author] = make(map[string]int)
+		}
+		b.spellings[author][post.AuthorUsername]++
+
+		// A reply to the post before it, unless pages in between are missing from the output
+		if i > 0 && posts[i-1].PageNumber >= post.PageNumber-1 {
+			if previous := users.Key(posts[i-1].AuthorUsername); previous != "" {
+				b.users.AddInteraction(author, previous, KindReply, post.Timestamp)
+			}
+		}
+		for _, block := range post.ParsedContent {
+			switch block.Type {
+			case data.ContentBlockTypeQuote:
+				if quoted := users.Key(block.QuotedUser); quoted != "" {
+					b.users.AddInteraction(author, quoted, KindQuote, post.Timestamp)
+					if _, ok := b.quoted[quoted]; !ok {
+						b.quoted[quoted] = block.QuotedUser
+					}
+				}
+			case data.ContentBlockTypeNewText:
+				b.addLinks(post, block.Content)
+			}
+		}
+	}
+}
+
+// addLinks records the topic and post links in a post's own text; links inside quotes are the
+// quoted author's.
+func (b *builder) addLinks(post data.PostMetadata, content string) {
+	for _, match := range topicLink.FindAllString(content, -1) {
+		ref, err := b.options.Adapter.ParseURL(html.UnescapeString(match))
+		if err != nil || (ref.Kind != canonurl.KindTopic && ref.Kind != canonurl.KindPost) {
+			continue
+		}
+		if ref.Host != "" && ref.Host != b.forumHost {
+			continue
+		}
+		b.links = append(b.links, link{source: post.TopicID, ref: ref, timestamp: post.Timestamp})
+	}
+}
+
+// finish resolves links to posts into links to their topics, and labels the nodes.
+func (b *builder) finish() {
+	for _, l := range b.links {
+		target := string(l.ref.TopicID)
+		if l.ref.Kind == canonurl.KindPost {
+			if topic, ok := b.postTopic[string(l.ref.PostID)]; ok {
+				target = topic
+			}
+		}
+		if target == "" {
+			continue // A post outside the archive, linked without its topic
+		}
+		b.topics.AddInteraction(l.source, target, KindLink, l.timestamp)
+		if node := b.topics.Node(target); node.Values["archived"] == "" {
+			node.Values["archived"] = "false"
+			node.Values["posts"] = "0"
+			if l.ref.SubForumID != "" {
+				node.Values["subforum"] = string(l.ref.SubForumID)
+			}
+		}
+	}
+
+	for _, node := range b.topics.Nodes() {
+		if title := b.options.Titles[node.ID]; title != "" {
+			node.Label = title
+		} else {
+			node.Label = "Topic " + node.ID
+		}
+	}
+	for _, node := range b.users.Nodes() {
+		posts := 0
+		best, bestCount := "", -1
+		for spelling, count := range b.spellings[node.ID] {
+			posts += count
+			if count > bestCount || (count == bestCount && spelling < best) {
+				best, bestCount = spelling, count
+			}
+		}
+		if best == "" {
+			best = b.quoted[node.ID]
+		}
+		node.Label = best
+		node.Values["posts"] = strconv.Itoa(posts)
+	}
+}
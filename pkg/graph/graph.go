@@ -0,0 +1,132 @@
+// Package graph builds interaction graphs from the extraction output, for network analysis in
+// tools such as Gephi and networkx:
+//
+//   - the user graph, a directed graph of who responds to whom: an edge from A to B for every post
+//     of A quoting B, and for every post of A directly following a post of B in a topic;
+//   - the topic graph, a directed graph of topics linking to other topics from their posts.
+//
+// Edges are weighted by the number of interactions and carry the timestamps of the first and
+// last one. Graphs are written as GraphML, GEXF or an edge-list CSV.
+package graph
+
+import (
+	"sort"
+)
+
+// Attribute types, as named in GraphML; GEXF uses the same names except for "long".
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeBool   = "boolean"
+)
+
+// AttrDef declares a node attribute.
+type AttrDef struct {
+	Name string
+	Type string
+}
+
+// Node is a user or a topic.
+type Node struct {
+	ID     string
+	Label  string
+	Values map[string]string // Attribute name to value, formatted
+}
+
+// Edge is the interactions from one node to another.
+type Edge struct {
+	Source string
+	Target string
+	Weight int            // Total number of interactions
+	Counts map[string]int // Interactions per kind, e.g. "quote" and "reply"
+	First  string         // Timestamp of the first interaction, "YYYY-MM-DD HH:MM:SS"; empty if none had one
+	Last   string
+}
+
+// Graph is a directed, weighted graph.
+type Graph struct {
+	Name      string
+	NodeAttrs []AttrDef
+	EdgeKinds []string // Kinds of interaction, each counted per edge
+
+	nodes map[string]*Node
+	edges map[[2]string]*Edge
+}
+
+// New creates an empty graph with the given node attributes and edge kinds.
+func New(name string, nodeAttrs []AttrDef, edgeKinds []string) *Graph {
+	return &Graph{
+		Name:      name,
+		NodeAttrs: nodeAttrs,
+		EdgeKinds: edgeKinds,
+		nodes:     make(map[string]*Node),
+		edges:     make(map[[2]string]*Edge),
+	}
+}
+
+// Node returns the node with the given ID, adding it if missing.
+func (g *Graph) Node(id string) *Node {
+	node, ok := g.nodes[id]
+	if !ok {
+		node = &Node{ID: id, Label: id, Values: make(map[string]string)}
+		g.nodes[id] = node
+	}
+	return node
+}
+
+// AddInteraction counts an interaction of a kind from source to target at timestamp (which may be
+// empty), adding the nodes and the edge as needed. Self-loops are ignored.
+func (g *Graph) AddInteraction(source string, target string, kind string, timestamp string) {
+	if source == target {
+		return
+	}
+	g.Node(source)
+	g.Node(target)
+	key := [2]string{source, target}
+	edge, ok := g.edges[key]
+	if !ok {
+		edge = &Edge{Source: source, Target: target, Counts: make(map[string]int)}
+		g.edges[key] = edge
+	}
+	edge.Weight++
+	edge.Counts[kind]++
+	if timestamp != "" {
+		if edge.First == "" || timestamp < edge.First {
+			edge.First = timestamp
+		}
+		if timestamp > edge.Last {
+			edge.Last = timestamp
+		}
+	}
+}
+
+// Nodes returns the nodes ordered by ID.
+func (g *Graph) Nodes() []*Node {
+	nodes := make([]*Node, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// Edges returns the edges ordered by source and target.
+func (g *Graph) Edges() []*Edge {
+	edges := make([]*Edge, 0, len(g.edges))
+	for _, edge := range g.edges {
+		edges = append(edges, edge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Source != edges[j].Source {
+			return edges[i].Source < edges[j].Source
+		}
+		return edges[i].Target < edges[j].Target
+	})
+	return edges
+}
+
+// Edge returns the edge from source to target.
+func (g *Graph) Edge(source string, target string) (*Edge, bool) {
+	edge, ok := g.edges[[2]string{source, target}]
+	return edge, ok
+}
@@ -0,0 +1,120 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree/outputtreetest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func text(content string) data.ContentBlock {
+	return data.ContentBlock{Type: data.ContentBlockTypeNewText, Content: content}
+}
+
+// buildFixture builds the graphs of two topics: 100, where Vernon opens, Slydini replies quoting
+// him and Vernon answers, and 200, whose posts link to topics and posts.
+func buildFixture(t *testing.T) (*Graph, *Graph) {
+	t.Helper()
+	dir := t.TempDir()
+	outputtreetest.WriteTopic(t, dir, "66_100.json", []data.PostMetadata{
+		{PostID: "3", TopicID: "100", SubForumID: "66", PageNumber: 2, AuthorUsername: "Dai Vernon", Timestamp: "2004-02-01 10:00:00"},
+		{PostID: "1", TopicID: "100", SubForumID: "66", PageNumber: 1, AuthorUsername: "Dai Vernon", Timestamp: "2004-01-01 10:00:00"},
+		{PostID: "2", TopicID: "100", SubForumID: "66", PageNumber: 1, PostOrderOnPage: 1, AuthorUsername: "Slydini", Timestamp: "2004-01-02 10:00:00",
+			ParsedContent: []data.ContentBlock{
+				{Type: data.ContentBlockTypeQuote, QuotedUser: "dai vernon", QuotedText: `See <a href="viewtopic.php?topic=999&amp;forum=1">this</a>`},
+				{Type: data.ContentBlockTypeQuote, QuotedUser: "Houdini"},
+				text("Agreed."),
+			}},
+	})
+	outputtreetest.WriteTopic(t, dir, "12_200.json", []data.PostMetadata{
+		{PostID: "4", TopicID: "200", SubForumID: "12", PageNumber: 1, AuthorUsername: "Slydini", Timestamp: "2005-03-01 10:00:00",
+			ParsedContent: []data.ContentBlock{text(`See <a href="viewtopic.php?topic=100&amp;forum=66">Vernon's topic</a>,
+https://www.themagiccafe.com/forums/viewtopic.php?post=3 and <a href="viewtopic.php?topic=300&forum=12&start=20">an old one</a>.
+Not <a href="http://other.example.com/viewtopic.php?t=100">elsewhere</a>, nor <a href="viewtopic.php?topic=200&forum=12&start=20">here</a>.`)}},
+		// Page 3 follows a missing page 2: not a reply to Slydini's post
+		{PostID: "5", TopicID: "200", SubForumID: "12", PageNumber: 3, AuthorUsername: "Dai Vernon", Timestamp: "2005-04-01 10:00:00",
+			ParsedContent: []data.ContentBlock{text(`Also viewtopic.php?topic=100`)}},
+	})
+	users, topics, err := Build(Options{OutputDir: dir, Titles: map[string]string{"100": "Card tricks"}})
+	require.NoError(t, err)
+	return users, topics
+}
+
+func TestBuild_Users(t *testing.T) {
+	users, _ := buildFixture(t)
+
+	nodes := users.Nodes()
+	require.Len(t, nodes, 3)
+	assert.Equal(t, &Node{ID: "dai vernon", Label: "Dai Vernon", Values: map[string]string{"posts": "3"}}, nodes[0])
+	assert.Equal(t, &Node{ID: "houdini", Label: "Houdini", Values: map[string]string{"posts": "0"}}, nodes[1])
+
+	edge, ok := users.Edge("slydini", "dai vernon")
+	require.True(t, ok)
+	assert.Equal(t, 2, edge.Weight)
+	assert.Equal(t, map[string]int{KindQuote: 1, KindReply: 1}, edge.Counts)
+	assert.Equal(t, "2004-01-02 10:00:00", edge.First)
+
+	edge, ok = users.Edge("dai vernon", "slydini")
+	require.True(t, ok)
+	assert.Equal(t, map[string]int{KindReply: 1}, edge.Counts, "the reply across the missing page is not counted")
+	assert.Equal(t, "2004-02-01 10:00:00", edge.Last)
+	assert.Len(t, users.Edges(), 3)
+}
+
+func TestBuild_Topics(t *testing.T) {
+	_, topics := buildFixture(t)
+
+	edge, ok := topics.Edge("200", "100")
+	require.True(t, ok)
+	assert.Equal(t, 3, edge.Weight, "a topic link, a post link resolved to its topic and a bare URL")
+	assert.Equal(t, "2005-04-01 10:00:00", edge.Last)
+	_, ok = topics.Edge("200", "300")
+	assert.True(t, ok)
+	assert.Len(t, topics.Edges(), 2, "self-links, links in quotes and links to other sites are not counted")
+
+	nodes := topics.Nodes()
+	require.Len(t, nodes, 3)
+	assert.Equal(t, &Node{ID: "100", Label: "Card tricks", Values: map[string]string{"subforum": "66", "posts": "3", "archived": "true"}}, nodes[0])
+	assert.Equal(t, &Node{ID: "300", Label: "Topic 300", Values: map[string]string{"subforum": "12", "posts": "0", "archived": "false"}}, nodes[2])
+}
+
+func TestWriters(t *testing.T) {
+	users, _ := buildFixture(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteGraphML(&buf, users))
+	var graphml struct {
+		Keys  []graphMLKey `xml:"key"`
+		Edges []struct {
+			Source string        `xml:"source,attr"`
+			Data   []graphMLData `xml:"data"`
+		} `xml:"graph>edge"`
+	}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &graphml))
+	assert.Contains(t, graphml.Keys, graphMLKey{"e_quote", "edge", "quote", "int"})
+	require.Len(t, graphml.Edges, 3)
+	assert.Contains(t, buf.String(), `<graph id="users" edgedefault="directed">`)
+	assert.Contains(t, buf.String(), `<node id="dai vernon">`)
+
+	buf.Reset()
+	require.NoError(t, WriteGEXF(&buf, users))
+	assert.Contains(t, buf.String(), `<graph defaultedgetype="directed" mode="dynamic" timeformat="datetime">`)
+	assert.Contains(t, buf.String(), `<edge id="1" source="slydini" target="dai vernon" weight="2" start="2004-01-02T10:00:00" end="2004-01-02T10:00:00">`)
+	assert.Contains(t, buf.String(), `<attribute id="posts" title="posts" type="integer"></attribute>`)
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), new(gexf)))
+
+	buf.Reset()
+	require.NoError(t, WriteEdgeCSV(&buf, users))
+	assert.Equal(t, []string{
+		"source,target,source_label,target_label,weight,quote,reply,first,last",
+		"dai vernon,slydini,Dai Vernon,Slydini,1,0,1,2004-02-01 10:00:00,2004-02-01 10:00:00",
+		"slydini,dai vernon,Slydini,Dai Vernon,2,1,1,2004-01-02 10:00:00,2004-01-02 10:00:00",
+		"slydini,houdini,Slydini,Houdini,1,1,0,2004-01-02 10:00:00,2004-01-02 10:00:00",
+	}, strings.Split(strings.TrimSpace(buf.String()), "\n"))
+}
@@ -0,0 +1,226 @@
+package graph
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Formats lists the output formats by file extension.
+var Formats = map[string]func(io.Writer, *Graph) error{
+	"graphml": WriteGraphML,
+	"gexf":    WriteGEXF,
+	"csv":     WriteEdgeCSV,
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+// WriteGraphML writes g as GraphML. Node labels and attributes, and edge weights, per-kind counts
+// and first and last timestamps, are GraphML data keys.
+func WriteGraphML(w io.Writer, g *Graph) error {
+	doc := graphML{XMLNS: "http://graphml.graphdrawing.org/xmlns"}
+	doc.Keys = append(doc.Keys, graphMLKey{"label", "node", "label", TypeString})
+	for _, attr := range g.NodeAttrs {
+		doc.Keys = append(doc.Keys, graphMLKey{"n_" + attr.Name, "node", attr.Name, attr.Type})
+	}
+	doc.Keys = append(doc.Keys, graphMLKey{"weight", "edge", "weight", TypeInt})
+	for _, kind := range g.EdgeKinds {
+		doc.Keys = append(doc.Keys, graphMLKey{"e_" + kind, "edge", kind, TypeInt})
+	}
+	doc.Keys = append(doc.Keys, graphMLKey{"first", "edge", "first", TypeString}, graphMLKey{"last", "edge", "last", TypeString})
+
+	doc.Graph.ID = g.Name
+	doc.Graph.EdgeDefault = "directed"
+	for _, node := range g.Nodes() {
+		element := graphMLNode{ID: node.ID, Data: []graphMLData{{"label", node.Label}}}
+		for _, attr := range g.NodeAttrs {
+			if value, ok := node.Values[attr.Name]; ok {
+				element.Data = append(element.Data, graphMLData{"n_" + attr.Name, value})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, element)
+	}
+	for _, edge := range g.Edges() {
+		element := graphMLEdge{Source: edge.Source, Target: edge.Target, Data: []graphMLData{{"weight", strconv.Itoa(edge.Weight)}}}
+		for _, kind := range g.EdgeKinds {
+			element.Data = append(element.Data, graphMLData{"e_" + kind, strconv.Itoa(edge.Counts[kind])})
+		}
+		if edge.First != "" {
+			element.Data = append(element.Data, graphMLData{"first", edge.First}, graphMLData{"last", edge.Last})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, element)
+	}
+	return writeXML(w, doc)
+}
+
+type gexf struct {
+	XMLName xml.Name `xml:"gexf"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Graph   struct {
+		DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+		Mode            string           `xml:"mode,attr"`
+		TimeFormat      string           `xml:"timeformat,attr"`
+		Attributes      []gexfAttributes `xml:"attributes"`
+		Nodes           []gexfNode       `xml:"nodes>node"`
+		Edges           []gexfEdge       `xml:"edges>edge"`
+	} `xml:"graph"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfNode struct {
+	ID     string      `xml:"id,attr"`
+	Label  string      `xml:"label,attr"`
+	Values []gexfValue `xml:"attvalues>attvalue,omitempty"`
+}
+
+type gexfEdge struct {
+	ID     string      `xml:"id,attr"`
+	Source string      `xml:"source,attr"`
+	Target string      `xml:"target,attr"`
+	Weight int         `xml:"weight,attr"`
+	Start  string      `xml:"start,attr,omitempty"`
+	End    string      `xml:"end,attr,omitempty"`
+	Values []gexfValue `xml:"attvalues>attvalue,omitempty"`
+}
+
+// WriteGEXF writes g as a dynamic GEXF 1.3 graph: each edge spans the time from its first to
+// its last interaction, so Gephi's timeline can show the network as it grew.
+func WriteGEXF(w io.Writer, g *Graph) error {
+	doc := gexf{XMLNS: "http://gexf.net/1.3", Version: "1.3"}
+	doc.Graph.DefaultEdgeType = "directed"
+	doc.Graph.Mode = "dynamic"
+	doc.Graph.TimeFormat = "datetime"
+
+	nodeAttrs := gexfAttributes{Class: "node"}
+	for _, attr := range g.NodeAttrs {
+		nodeAttrs.Attributes = append(nodeAttrs.Attributes, gexfAttribute{attr.Name, attr.Name, gexfType(attr.Type)})
+	}
+	edgeAttrs := gexfAttributes{Class: "edge"}
+	for _, kind := range g.EdgeKinds {
+		edgeAttrs.Attributes = append(edgeAttrs.Attributes, gexfAttribute{kind, kind, "integer"})
+	}
+	doc.Graph.Attributes = []gexfAttributes{nodeAttrs, edgeAttrs}
+
+	for _, node := range g.Nodes() {
+		element := gexfNode{ID: node.ID, Label: node.Label}
+		for _, attr := range g.NodeAttrs {
+			if value, ok := node.Values[attr.Name]; ok {
+				element.Values = append(element.Values, gexfValue{attr.Name, value})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, element)
+	}
+	for i, edge := range g.Edges() {
+		element := gexfEdge{ID: strconv.Itoa(i), Source: edge.Source, Target: edge.Target, Weight: edge.Weight,
+			Start: gexfTime(edge.First), End: gexfTime(edge.Last)}
+		for _, kind := range g.EdgeKinds {
+			element.Values = append(element.Values, gexfValue{kind, strconv.Itoa(edge.Counts[kind])})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, element)
+	}
+	return writeXML(w, doc)
+}
+
+func gexfType(graphMLType string) string {
+	if graphMLType == TypeInt {
+		return "integer"
+	}
+	return graphMLType
+}
+
+// gexfTime turns "YYYY-MM-DD HH:MM:SS" into the xsd:dateTime GEXF expects.
+func gexfTime(timestamp string) string {
+	return strings.Replace(timestamp, " ", "T", 1)
+}
+
+func writeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write graph: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteEdgeCSV writes g's edges, one per line, with the labels of both ends, the weight, the
+// count of each kind of interaction and the first and last timestamps.
+func WriteEdgeCSV(w io.Writer, g *Graph) error {
+	writer := csv.NewWriter(w)
+	header := []string{"source", "target", "source_label", "target_label", "weight"}
+	header = append(header, g.EdgeKinds...)
+	header = append(header, "first", "last")
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write edge list: %w", err)
+	}
+	for _, edge := range g.Edges() {
+		record := []string{edge.Source, edge.Target, g.nodes[edge.Source].Label, g.nodes[edge.Target].Label, strconv.Itoa(edge.Weight)}
+		for _, kind := range g.EdgeKinds {
+			record = append(record, strconv.Itoa(edge.Counts[kind]))
+		}
+		record = append(record, edge.First, edge.Last)
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write edge list: %w", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write edge list: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package mirror
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"waypoint_archive_scripts/pkg/assets"
+)
+
+// assetsDir is where the mirror keeps the assets it copies from the asset store, in the store's
+// own layout: assets/{sha256[:2]}/{sha256}{ext}.
+const assetsDir = "assets/"
+
+// localAsset returns the path of an archived asset relative to the mirror's assets directory,
+// copying the asset into the mirror on first use. It reports false if the asset was not archived
+// (or could not be copied), in which case pages keep loading it from the live site.
+func (s *site) localAsset(assetURL string) (string, bool) {
+	if s.assets == nil {
+		return "", false
+	}
+	if file, ok := s.copiedAssets[assetURL]; ok {
+		return file, file != ""
+	}
+	entry, ok := s.assets.Lookup(assetURL)
+	if !ok || entry.Status != assets.StatusStored || entry.File == "" {
+		s.copiedAssets[assetURL] = ""
+		return "", false
+	}
+	// Recorded before copying, so stylesheets importing each other do not recurse forever
+	s.copiedAssets[assetURL] = entry.File
+	if err := s.copyAsset(entry); err != nil {
+		log.Printf("[WARNING] MIRROR: %v. Pages load it from %s.", err, assetURL)
+		s.copiedAssets[assetURL] = ""
+		return "", false
+	}
+	return entry.File, true
+}
+
+// copyAsset copies a stored asset into the mirror. Several URLs can share one stored file; it is
+// copied once.
+func (s *site) copyAsset(entry assets.Entry) error {
+	target := filepath.Join(s.options.OutputDir, filepath.FromSlash(assetsDir+entry.File))
+	if _, err := os.Stat(target); err == nil && entry.Kind != assets.KindStylesheet {
+		return nil
+	}
+	content, err := os.ReadFile(filepath.Join(s.options.AssetDir, filepath.FromSlash(entry.File)))
+	if err != nil {
+		return fmt.Errorf("failed to read archived asset %s: %w", entry.URL, err)
+	}
+	if entry.Kind == assets.KindStylesheet {
+		// The stylesheet lives in assets/xx/, one directory below the assets it references
+		base := entry.URL
+		if entry.FinalURL != "" {
+			base = entry.FinalURL
+		}
+		content = []byte(s.rewriteCSS(string(content), base, "../"))
+	}
+	if err := s.writeFile(assetsDir+entry.File, string(content)); err != nil {
+		return err
+	}
+	s.stats.Assets++
+	return nil
+}
+
+// rewriteCSS points the references in CSS text, resolved against base, at local copies of
+// archived assets, and at the live site otherwise. prefix leads from where the CSS is used to the
+// mirror's assets directory.
+func (s *site) rewriteCSS(css string, base string, prefix string) string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return css
+	}
+	return assets.RewriteCSS(css, func(ref assets.Ref) string {
+		resolved, ok := assets.Resolve(baseURL, ref.URL)
+		if !ok {
+			return ""
+		}
+		if file, ok := s.localAsset(resolved); ok {
+			return prefix + file
+		}
+		return resolved
+	})
+}
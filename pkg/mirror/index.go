@@ -0,0 +1,147 @@
+package mirror
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+
+	"project-waypoint/pkg/viewer"
+
+	"waypoint_archive_scripts/pkg/canonurl"
+)
+
+type indexSubForum struct {
+	viewer.SubForum
+	Link string
+}
+
+type listingTopic struct {
+	*viewer.Topic
+	Link     string
+	External bool // The topic was not archived; Link points at the live forum
+}
+
+type listingPage struct {
+	SubForum viewer.SubForum
+	Name     string
+	Topics   []listingTopic
+	Page     int
+	Pages    int
+	Prev     string
+	Next     string
+}
+
+// writeIndexPages writes the mirror index, the sub-forum listings and the stylesheet.
+func (s *site) writeIndexPages() error {
+	if err := s.writeFile("mirror.css", mirrorCSS); err != nil {
+		return err
+	}
+
+	var subForums []indexSubForum
+	for _, subForum := range s.archive.SubForums() {
+		subForums = append(subForums, indexSubForum{SubForum: subForum, Link: forumPath(subForum.ID, 1)})
+	}
+	if err := s.writeTemplate("index.html", "index", subForums); err != nil {
+		return err
+	}
+
+	perPage := max(s.adapter.TopicsPerPage(), 1)
+	for _, subForum := range s.archive.SubForums() {
+		_, topics, _ := s.archive.SubForum(subForum.ID)
+		pages := s.subForums[subForum.ID]
+		for page := 1; page <= pages; page++ {
+			listing := listingPage{SubForum: subForum, Name: subForum.Name, Page: page, Pages: pages}
+			if listing.Name == "" {
+				listing.Name = "Sub-forum " + subForum.ID
+			}
+			if page > 1 {
+				listing.Prev = fmt.Sprintf("page_%d.html", page-1)
+			}
+			if page < pages {
+				listing.Next = fmt.Sprintf("page_%d.html", page+1)
+			}
+			start := (page - 1) * perPage
+			for _, topic := range topics[start:min(start+perPage, len(topics))] {
+				entry := listingTopic{Topic: topic}
+				if len(topic.PageFiles) > 0 {
+					entry.Link = fromPage(topicPath(topic.ID, topic.PageFiles[0]))
+				} else {
+					entry.External = true
+					entry.Link = s.adapter.FormatURL(canonurl.TopicPage(canonurl.SubForumID(topic.SubForumID), canonurl.TopicID(topic.ID), 0))
+				}
+				listing.Topics = append(listing.Topics, entry)
+			}
+			if err := s.writeTemplate(forumPath(subForum.ID, page), "listing", listing); err != nil {
+				return err
+			}
+			s.stats.SubForumPages++
+		}
+	}
+	return nil
+}
+
+func (s *site) writeTemplate(relPath string, name string, data any) error {
+	var buf bytes.Buffer
+	if err := indexTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		return fmt.Errorf("failed to render mirror page %s: %w", relPath, err)
+	}
+	return s.writeFile(relPath, buf.String())
+}
+
+var indexTemplates = template.Must(template.New("mirror").Parse(`
+{{define "index"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Forum archive</title>
+<link rel="stylesheet" href="mirror.css">
+</head>
+<body class="mirror-page">
+<h1>Forum archive</h1>
+<table>
+<tr><th>Sub-forum</th><th>Topics</th></tr>
+{{range .}}<tr><td><a href="{{.Link}}">{{if .Name}}{{.Name}}{{else}}Sub-forum {{.ID}}{{end}}</a></td><td>{{.Topics}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+{{end}}
+
+{{define "listing"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} - Forum archive</title>
+<link rel="stylesheet" href="../../mirror.css">
+</head>
+<body class="mirror-page">
+<p><a href="../../index.html">Forum archive</a></p>
+<h1>{{.Name}}</h1>
+{{template "pager" .}}
+<table>
+<tr><th>Topic</th><th>Author</th><th>Replies</th><th>Views</th><th>Last post</th></tr>
+{{range .Topics}}<tr>
+<td>{{if .External}}<a class="mirror-external" rel="external nofollow" title="Not in the archive: opens the live forum" href="{{.Link}}">{{else}}<a href="{{.Link}}">{{end}}{{if .Title}}{{.Title}}{{else}}Topic {{.ID}}{{end}}</a></td>
+<td>{{.AuthorUsername}}</td><td>{{if .InIndex}}{{.Replies}}{{end}}</td><td>{{if .InIndex}}{{.Views}}{{end}}</td>
+<td>{{.LastPostTimestampRaw}}{{if .LastPostUsername}} by {{.LastPostUsername}}{{end}}</td>
+</tr>
+{{end}}</table>
+{{template "pager" .}}
+</body>
+</html>
+{{end}}
+
+{{define "pager"}}{{if gt .Pages 1}}<p class="mirror-pager">
+{{if .Prev}}<a href="{{.Prev}}">&larr; Previous</a>{{end}}
+Page {{.Page}} of {{.Pages}}
+{{if .Next}}<a href="{{.Next}}">Next &rarr;</a>{{end}}
+</p>{{end}}{{end}}
+`))
+
+const mirrorCSS = `.mirror-banner { font: 13px sans-serif; background: #fff8dc; border-bottom: 1px solid #d9c98a; padding: 4px 8px; color: #333; }
+.mirror-banner a { color: #1a5490; }
+a.mirror-external::after { content: " \2197"; font-size: 0.8em; }
+.mirror-dead { color: inherit; }
+body.mirror-page { font-family: sans-serif; margin: 0 auto; max-width: 60em; padding: 1em; color: #222; }
+body.mirror-page table { border-collapse: collapse; width: 100%; }
+body.mirror-page th, body.mirror-page td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; }
+`
@@ -0,0 +1,239 @@
+// Package mirror turns the raw HTML archive into a static, self-contained offline mirror that can
+// be published as a plain folder. Archived topic pages keep the forum's markup and look; links
+// between them are rewritten to relative paths, links to pages that were not archived point at
+// the live forum and are marked external, and dead navigation, scripts and ads are removed.
+// Sub-forum listings are generated from the topic index.
+//
+// Mirror layout:
+//
+//	index.html                       Sub-forums
+//	mirror.css                       Styles of the generated pages and mirror markers
+//	forum/{subforum_id}/page_N.html  Topic listings, from the topic index
+//	topic/{topic_id}/page_N.html     Archived topic pages, rewritten
+//	assets/{sha256[:2]}/{sha256}     Images, stylesheets and attachments copied from the asset store
+//
+// Given the asset store and its manifest, pages load the images, avatars and stylesheets they
+// reference from local copies; assets that were not archived still load from the live site.
+package mirror
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"project-waypoint/pkg/viewer"
+
+	"github.com/PuerkitoBio/goquery"
+	"waypoint_archive_scripts/pkg/assets"
+	"waypoint_archive_scripts/pkg/forumadapter"
+)
+
+// DefaultStripSelectors match the elements removed from archived pages: scripts (which would call
+// home or fail offline), forms that post to the live forum, and ad containers.
+var DefaultStripSelectors = []string{
+	"script", "noscript", "iframe", "object", "embed", "form", "base",
+	"ins.adsbygoogle", "[id*=google_ads]", "[id^=div-gpt-ad]", "[class*=advert]", "[id*=advert]",
+}
+
+// Options configures Generate.
+type Options struct {
+	ArchiveDir       string // Raw HTML archive, {subforum_id}/{topic_id}/page_N.html
+	TopicIndexDir    string // Topic index JSON files, for sub-forum listings and topic titles
+	SubForumListPath string // Sub-forum list (CSV or JSON), for sub-forum names
+	OutputDir        string // Mirror root; created if missing
+	// Adapter knows the forum's URL scheme and markup; nil uses forumadapter.Default().
+	Adapter forumadapter.ForumAdapter
+	// StripSelectors match elements removed from archived pages; nil uses DefaultStripSelectors.
+	StripSelectors []string
+	// AssetDir and AssetManifestPath locate the archived assets; pages reference the live site's
+	// assets if empty.
+	AssetDir          string
+	AssetManifestPath string
+}
+
+// Stats counts what Generate did.
+type Stats struct {
+	SubForumPages int
+	TopicPages    int
+	LocalLinks    int // Forum links rewritten to mirror pages
+	ExternalLinks int // Forum links to pages not in the mirror, marked external
+	DeadLinks     int // Forum navigation links (login, profiles, posting, ...) removed
+	Stripped      int // Elements removed by the strip selectors
+	Assets        int // Archived assets copied into the mirror
+}
+
+// location is where a post is in the mirror.
+type location struct {
+	TopicID string
+	Page    int
+}
+
+// site is what the mirror holds, for resolving links.
+type site struct {
+	options   Options
+	adapter   forumadapter.ForumAdapter
+	archive   *viewer.Archive
+	pages     map[string]map[int]bool // Topic ID to archived page numbers
+	subForums map[string]int          // Sub-forum ID to number of listing pages
+	posts     map[string]location     // Post ID to the page holding it
+	stats     Stats
+
+	assets       *assets.Manifest  // Archived assets; nil if none
+	copiedAssets map[string]string // Asset URL to its file in the mirror's assets directory, "" if not archived
+}
+
+// Generate writes the mirror of the archive to options.OutputDir.
+func Generate(options Options) (Stats, error) {
+	if options.ArchiveDir == "" || options.OutputDir == "" {
+		return Stats{}, fmt.Errorf("both the archive directory and the mirror output directory are required")
+	}
+	if options.Adapter == nil {
+		options.Adapter = forumadapter.Default()
+	}
+	if options.StripSelectors == nil {
+		options.StripSelectors = DefaultStripSelectors
+	}
+	archive, err := viewer.Load(viewer.Config{
+		ArchiveDir:       options.ArchiveDir,
+		TopicIndexDir:    options.TopicIndexDir,
+		SubForumListPath: options.SubForumListPath,
+	})
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to load the archive: %w", err)
+	}
+	s := &site{
+		options:   options,
+		adapter:   options.Adapter,
+		archive:   archive,
+		pages:     make(map[string]map[int]bool),
+		subForums: make(map[string]int),
+		posts:     make(map[string]location),
+
+		copiedAssets: make(map[string]string),
+	}
+	if options.AssetDir != "" && options.AssetManifestPath != "" {
+		if s.assets, err = assets.LoadManifest(options.AssetManifestPath); err != nil {
+			return Stats{}, err
+		}
+	}
+
+	// First pass: what the mirror holds, so every link can be resolved
+	for _, subForum := range archive.SubForums() {
+		_, topics, _ := archive.SubForum(subForum.ID)
+		s.subForums[subForum.ID] = listingPages(len(topics), s.adapter.TopicsPerPage())
+		for _, topic := range topics {
+			if len(topic.PageFiles) == 0 {
+				continue
+			}
+			s.pages[topic.ID] = make(map[int]bool, len(topic.PageFiles))
+			for _, page := range topic.PageFiles {
+				s.pages[topic.ID][page] = true
+				if err := s.collectPosts(topic, page); err != nil {
+					return s.stats, err
+				}
+			}
+		}
+	}
+	log.Printf("[INFO] MIRROR: %d sub-forums, %d archived topics, %d posts located.", len(s.subForums), len(s.pages), len(s.posts))
+
+	// Second pass: write the pages
+	if err := os.MkdirAll(options.OutputDir, 0755); err != nil {
+		return s.stats, fmt.Errorf("failed to create mirror directory %s: %w", options.OutputDir, err)
+	}
+	if err := s.writeIndexPages(); err != nil {
+		return s.stats, err
+	}
+	for _, subForum := range archive.SubForums() {
+		_, topics, _ := archive.SubForum(subForum.ID)
+		for _, topic := range topics {
+			for _, page := range topic.PageFiles {
+				if err := s.writeTopicPage(topic, page); err != nil {
+					return s.stats, err
+				}
+			}
+		}
+	}
+	return s.stats, nil
+}
+
+// collectPosts records the posts of an archived page.
+func (s *site) collectPosts(topic *viewer.Topic, page int) error {
+	doc, err := s.loadPage(topic, page)
+	if err != nil {
+		return err
+	}
+	s.adapter.PostBlocks(doc).Each(func(_ int, block *goquery.Selection) {
+		if metadata, _ := s.adapter.PostMetadata(block); metadata.PostID != "" {
+			s.posts[metadata.PostID] = location{TopicID: topic.ID, Page: page}
+		}
+	})
+	return nil
+}
+
+func (s *site) loadPage(topic *viewer.Topic, page int) (*goquery.Document, error) {
+	path := s.archive.RawPagePath(topic, page)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archived page %s: %w", path, err)
+	}
+	defer file.Close()
+	doc, err := goquery.NewDocumentFromReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse archived page %s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// writeTopicPage rewrites an archived page into the mirror.
+func (s *site) writeTopicPage(topic *viewer.Topic, page int) error {
+	doc, err := s.loadPage(topic, page)
+	if err != nil {
+		return err
+	}
+	s.rewritePage(doc, topic, page)
+	content, err := goquery.OuterHtml(doc.Selection)
+	if err != nil {
+		return fmt.Errorf("failed to render page %d of topic %s: %w", page, topic.ID, err)
+	}
+	if err := s.writeFile(topicPath(topic.ID, page), content); err != nil {
+		return err
+	}
+	s.stats.TopicPages++
+	return nil
+}
+
+// writeFile writes a mirror file, relPath being relative to the mirror root.
+func (s *site) writeFile(relPath string, content string) error {
+	path := filepath.Join(s.options.OutputDir, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create mirror directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write mirror page %s: %w", path, err)
+	}
+	return nil
+}
+
+// topicPath is the mirror path of a topic page.
+func topicPath(topicID string, page int) string {
+	return fmt.Sprintf("topic/%s/page_%d.html", topicID, page)
+}
+
+// forumPath is the mirror path of a sub-forum listing page.
+func forumPath(subForumID string, page int) string {
+	return fmt.Sprintf("forum/%s/page_%d.html", subForumID, page)
+}
+
+// fromPage returns target, a path relative to the mirror root, as a link from a page two
+// directories deep (forum and topic pages).
+func fromPage(target string) string {
+	return "../../" + target
+}
+
+func listingPages(topics int, perPage int) int {
+	if perPage <= 0 {
+		perPage = viewer.TopicsPerPage
+	}
+	return max(1, (topics+perPage-1)/perPage)
+}
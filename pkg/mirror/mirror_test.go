@@ -0,0 +1,129 @@
+package mirror
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"waypoint_archive_scripts/pkg/assets"
+)
+
+// linkingPage is an archived page of topic 555 with one link of each kind.
+const linkingPage = `<html><head><title>Topic 555</title><script src="tracker.js"></script></head>
+<body onload="track()">
+<div class="advert">Buy now</div>
+<a id="next" href="viewtopic.php?topic=19618&amp;forum=66&amp;start=20#3">Next page of another topic</a>
+<a id="missing" href="viewtopic.php?topic=777&amp;forum=66">Topic not archived</a>
+<a id="post" href="viewtopic.php?post=165858">A post</a>
+<a id="forum" href="viewforum.php?forum=66">This sub-forum</a>
+<a id="otherforum" href="https://themagiccafe.com/forums/viewforum.php?forum=12">Another sub-forum</a>
+<a id="profile" href="bb_profile.php?mode=view&amp;user=5"><b>Maxim</b></a>
+<a id="home" href="index.php">Home</a>
+<a id="offsite" href="http://www.example.com/">Elsewhere</a>
+<img src="images/logo.gif">
+<form action="login.php"><input name="user"></form>
+</body></html>`
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	archiveDir := filepath.Join(dir, "archive")
+	for _, page := range []string{"page_1.html", "page_2.html"} {
+		content, err := os.ReadFile(filepath.Join("..", "..", "test-data", "regress", "corpus", "66", "19618", page))
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Join(archiveDir, "66", "19618"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(archiveDir, "66", "19618", page), content, 0644))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(archiveDir, "66", "555"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(archiveDir, "66", "555", "page_1.html"), []byte(linkingPage), 0644))
+	indexDir := filepath.Join(dir, "index", "forum_66")
+	require.NoError(t, os.MkdirAll(indexDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "topic_index_66.json"),
+		[]byte(`[{"ID":"19618","Title":"Dai Vernon and Houdini","Replies":24},{"ID":"555","Title":"Links"},{"ID":"777","Title":"Never archived"}]`), 0644))
+
+	outputDir := filepath.Join(dir, "mirror")
+	stats, err := Generate(Options{ArchiveDir: archiveDir, TopicIndexDir: filepath.Join(dir, "index"), OutputDir: outputDir})
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.TopicPages)
+	assert.Equal(t, 1, stats.SubForumPages)
+
+	read := func(relPath string) string {
+		content, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(relPath)))
+		require.NoError(t, err)
+		return string(content)
+	}
+	page := read("topic/555/page_1.html")
+	assert.Contains(t, page, `<a id="next" href="../../topic/19618/page_2.html#3">`)
+	assert.Contains(t, page, `<a id="missing" href="https://www.themagiccafe.com/forums/viewtopic.php?forum=66&amp;topic=777" class="mirror-external" rel="external nofollow"`)
+	assert.Contains(t, page, `<a id="post" href="../../topic/19618/page_1.html#p165858">`)
+	assert.Contains(t, page, `<a id="forum" href="../../forum/66/page_1.html">`)
+	assert.Contains(t, page, `<a id="otherforum" href="https://www.themagiccafe.com/forums/viewforum.php?forum=12" class="mirror-external"`)
+	assert.Contains(t, page, `<span class="mirror-dead"><b>Maxim</b></span>`)
+	assert.Contains(t, page, `<a id="home" href="../../index.html">`)
+	assert.Contains(t, page, `<a id="offsite" href="http://www.example.com/">`)
+	assert.Contains(t, page, `<img src="https://www.themagiccafe.com/forums/images/logo.gif"/>`)
+	assert.Contains(t, page, `<link rel="stylesheet" href="../../mirror.css"/>`)
+	assert.Contains(t, page, `<a href="../../forum/66/page_1.html">Sub-forum 66</a>`)
+	for _, gone := range []string{"<script", "tracker", "onload", "Buy now", "<form", "<input"} {
+		assert.NotContains(t, page, gone)
+	}
+
+	// The post linked to is anchored on the rewritten page, and the topic's own pagination is local
+	page = read("topic/19618/page_1.html")
+	assert.Contains(t, page, `id="p165858"`)
+	assert.Contains(t, page, `href="../../topic/19618/page_2.html"`)
+	assert.NotContains(t, page, `href="viewtopic.php`)
+
+	listing := read("forum/66/page_1.html")
+	assert.Contains(t, listing, `<a href="../../topic/19618/page_1.html">Dai Vernon and Houdini</a>`)
+	assert.Contains(t, listing, `href="https://www.themagiccafe.com/forums/viewtopic.php?forum=66&amp;topic=777">Never archived</a>`)
+	assert.Contains(t, read("index.html"), `<a href="forum/66/page_1.html">Sub-forum 66</a>`)
+	assert.FileExists(t, filepath.Join(outputDir, "mirror.css"))
+}
+
+func TestGenerate_Assets(t *testing.T) {
+	dir := t.TempDir()
+	archiveDir := filepath.Join(dir, "archive")
+	require.NoError(t, os.MkdirAll(filepath.Join(archiveDir, "66", "555"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(archiveDir, "66", "555", "page_1.html"), []byte(`<html><head>
+<link rel="stylesheet" type="text/css" href="cafe.css"></head><body>
+<img id="logo" src="images/logo.gif"><img id="missing" src="images/missing.gif">
+<div id="styled" style="background: url(images/logo.gif)"></div>
+<a id="attachment" href="files/routine.pdf">Routine</a>
+</body></html>`), 0644))
+
+	// An asset store holding the stylesheet, the logo and the attachment
+	assetDir := filepath.Join(dir, "assets")
+	manifest := assets.NewManifest()
+	store := func(url string, kind assets.Kind, file string, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(assetDir, file)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(assetDir, file), []byte(content), 0644))
+		manifest.Put(assets.Entry{URL: url, Kind: kind, Status: assets.StatusStored, File: file})
+	}
+	base := "https://www.themagiccafe.com/forums/"
+	store(base+"cafe.css", assets.KindStylesheet, "aa/aa11.css", `body { background: url("images/logo.gif") } td { background: url(images/cell.gif) }`)
+	store(base+"images/logo.gif", assets.KindImage, "bb/bb22.gif", "GIF89a")
+	store(base+"files/routine.pdf", assets.KindAttachment, "cc/cc33.pdf", "%PDF-1.4")
+	manifest.Put(assets.Entry{URL: base + "images/missing.gif", Kind: assets.KindImage, Status: assets.StatusGone})
+	manifestPath := filepath.Join(dir, "asset_manifest.json")
+	require.NoError(t, manifest.Save(manifestPath))
+
+	outputDir := filepath.Join(dir, "mirror")
+	stats, err := Generate(Options{ArchiveDir: archiveDir, OutputDir: outputDir, AssetDir: assetDir, AssetManifestPath: manifestPath})
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Assets)
+
+	page, err := os.ReadFile(filepath.Join(outputDir, "topic", "555", "page_1.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `<link rel="stylesheet" type="text/css" href="../../assets/aa/aa11.css"/>`)
+	assert.Contains(t, string(page), `<img id="logo" src="../../assets/bb/bb22.gif"/>`)
+	assert.Contains(t, string(page), `<img id="missing" src="https://www.themagiccafe.com/forums/images/missing.gif"/>`)
+	assert.Contains(t, string(page), `style="background: url(&#34;../../assets/bb/bb22.gif&#34;)"`)
+	assert.Contains(t, string(page), `<a id="attachment" href="../../assets/cc/cc33.pdf">`)
+
+	css, err := os.ReadFile(filepath.Join(outputDir, "assets", "aa", "aa11.css"))
+	require.NoError(t, err)
+	assert.Equal(t, `body { background: url("../bb/bb22.gif") } td { background: url("https://www.themagiccafe.com/forums/images/cell.gif") }`, string(css))
+	assert.FileExists(t, filepath.Join(outputDir, "assets", "cc", "cc33.pdf"))
+}
@@ -0,0 +1,207 @@
+package mirror
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"strings"
+
+	"project-waypoint/pkg/viewer"
+
+	"github.com/PuerkitoBio/goquery"
+	"waypoint_archive_scripts/pkg/assets"
+	"waypoint_archive_scripts/pkg/canonurl"
+)
+
+// Classes added to rewritten links, styled by mirror.css.
+const (
+	externalClass = "mirror-external"
+	deadClass     = "mirror-dead"
+)
+
+// rewritePage turns an archived topic page into a mirror page.
+func (s *site) rewritePage(doc *goquery.Document, topic *viewer.Topic, page int) {
+	pageURL := s.adapter.FormatURL(canonurl.TopicPage(canonurl.SubForumID(topic.ArchiveSubForumID), canonurl.TopicID(topic.ID),
+		canonurl.StartForPage(page, s.adapter.PostsPerPage())))
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		base = &url.URL{}
+	}
+
+	for _, selector := range s.options.StripSelectors {
+		stripped := doc.Find(selector)
+		s.stats.Stripped += stripped.Length()
+		stripped.Remove()
+	}
+	// Inline event handlers are scripts too
+	doc.Find("*").Each(func(_ int, element *goquery.Selection) {
+		var handlers []string
+		for _, attr := range element.Nodes[0].Attr {
+			if strings.HasPrefix(strings.ToLower(attr.Key), "on") {
+				handlers = append(handlers, attr.Key)
+			}
+		}
+		for _, handler := range handlers {
+			element.RemoveAttr(handler)
+		}
+	})
+
+	// Anchor every post by its ID, as links to single posts expect
+	s.adapter.PostBlocks(doc).Each(func(_ int, block *goquery.Selection) {
+		if metadata, _ := s.adapter.PostMetadata(block); metadata.PostID != "" {
+			if _, ok := block.Attr("id"); !ok {
+				block.SetAttr("id", "p"+metadata.PostID)
+			}
+		}
+	})
+
+	doc.Find("a[href]").Each(func(_ int, link *goquery.Selection) {
+		s.rewriteLink(link, base)
+	})
+	// Stylesheets and images load from local copies if archived, else from the forum
+	for _, attr := range []struct{ selector, name string }{{"link[href]", "href"}, {"img[src]", "src"}, {"input[src]", "src"}, {"[background]", "background"}} {
+		doc.Find(attr.selector).Each(func(_ int, element *goquery.Selection) {
+			resolved, err := base.Parse(element.AttrOr(attr.name, ""))
+			if err != nil {
+				return
+			}
+			if file, ok := s.localAsset(resolved.String()); ok {
+				element.SetAttr(attr.name, fromPage(assetsDir+file))
+				return
+			}
+			element.SetAttr(attr.name, resolved.String())
+		})
+	}
+	doc.Find("[style]").Each(func(_ int, element *goquery.Selection) {
+		element.SetAttr("style", s.rewriteCSS(element.AttrOr("style", ""), base.String(), fromPage(assetsDir)))
+	})
+	doc.Find("style").Each(func(_ int, element *goquery.Selection) {
+		element.SetText(s.rewriteCSS(element.Text(), base.String(), fromPage(assetsDir)))
+	})
+
+	s.addBanner(doc, topic, pageURL)
+}
+
+// rewriteLink points a link at the mirror if it can, at the live forum (marked external) if it
+// is a topic, post or sub-forum page the mirror does not hold, and removes forum navigation that
+// cannot work offline. Links off the forum are left alone.
+func (s *site) rewriteLink(link *goquery.Selection, base *url.URL) {
+	href := strings.TrimSpace(link.AttrOr("href", ""))
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "mailto:") {
+		return
+	}
+	if strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		s.markDead(link)
+		return
+	}
+	target, err := base.Parse(href)
+	if err != nil {
+		return
+	}
+	if assets.IsAttachment(target.String()) {
+		if file, ok := s.localAsset(target.String()); ok {
+			s.markLocal(link, fromPage(assetsDir+file))
+			return
+		}
+	}
+	if _, host := canonurl.CanonicalOrigin(target.Scheme, target.Host); host != base.Host {
+		return
+	}
+
+	ref, err := s.adapter.ParseURL(target.String())
+	if err != nil {
+		s.markExternal(link, target.String())
+		return
+	}
+	fragment := ""
+	if target.Fragment != "" {
+		fragment = "#" + target.Fragment
+	}
+	switch ref.Kind {
+	case canonurl.KindTopic:
+		page := ref.Page(s.adapter.PostsPerPage())
+		if s.pages[string(ref.TopicID)][page] {
+			s.markLocal(link, fromPage(topicPath(string(ref.TopicID), page))+fragment)
+			return
+		}
+	case canonurl.KindPost:
+		if loc, ok := s.posts[string(ref.PostID)]; ok {
+			s.markLocal(link, fromPage(topicPath(loc.TopicID, loc.Page))+"#p"+string(ref.PostID))
+			return
+		}
+		if page := ref.Page(s.adapter.PostsPerPage()); ref.TopicID != "" && s.pages[string(ref.TopicID)][page] {
+			s.markLocal(link, fromPage(topicPath(string(ref.TopicID), page))+"#p"+string(ref.PostID))
+			return
+		}
+	case canonurl.KindForum:
+		if pages, ok := s.subForums[string(ref.SubForumID)]; ok {
+			page := min(ref.Start/max(s.adapter.TopicsPerPage(), 1)+1, pages)
+			s.markLocal(link, fromPage(forumPath(string(ref.SubForumID), page)))
+			return
+		}
+	default:
+		// The forum's front page becomes the mirror index. Other forum scripts (profiles, login,
+		// posting, search, ...) need the live forum; files such as images are left to load from it
+		script := path.Base(target.Path)
+		if script == "index.php" || strings.HasSuffix(target.Path, "/") {
+			s.markLocal(link, fromPage("index.html"))
+			return
+		}
+		if strings.HasSuffix(script, ".php") {
+			s.markDead(link)
+			return
+		}
+		link.SetAttr("href", target.String())
+		return
+	}
+	s.markExternal(link, s.adapter.FormatURL(ref)+fragment)
+}
+
+func (s *site) markLocal(link *goquery.Selection, href string) {
+	link.SetAttr("href", href)
+	s.stats.LocalLinks++
+}
+
+func (s *site) markExternal(link *goquery.Selection, href string) {
+	link.SetAttr("href", href)
+	link.AddClass(externalClass)
+	link.SetAttr("rel", "external nofollow")
+	link.SetAttr("title", "Not in the archive: opens the live forum")
+	s.stats.ExternalLinks++
+}
+
+// markDead replaces a link by its content, keeping the text (e.g. a user name) readable.
+func (s *site) markDead(link *goquery.Selection) {
+	inner, _ := link.Html()
+	link.ReplaceWithHtml(fmt.Sprintf(`<span class="%s">%s</span>`, deadClass, inner))
+	s.stats.DeadLinks++
+}
+
+// addBanner links the mirror stylesheet and puts a bar above the page saying it is an archived
+// copy, with links to the mirror's sub-forum listing and index.
+func (s *site) addBanner(doc *goquery.Document, topic *viewer.Topic, pageURL string) {
+	doc.Find("head").AppendHtml(fmt.Sprintf(`<link rel="stylesheet" href="%s"/>`, fromPage("mirror.css")))
+
+	subForum, _, _ := s.archive.SubForum(topic.SubForumID)
+	subForumName := subForum.Name
+	if subForumName == "" {
+		subForumName = "Sub-forum " + topic.SubForumID
+	}
+	banner := fmt.Sprintf(`<div class="mirror-banner">Archived copy of <a class="%s" href="%s">this page</a> &middot; <a href="%s">%s</a> &middot; <a href="%s">Archive index</a></div>`,
+		externalClass, html.EscapeString(pageURL),
+		html.EscapeString(fromPage(forumPath(topic.SubForumID, s.listingPageOf(topic)))), html.EscapeString(subForumName),
+		fromPage("index.html"))
+	doc.Find("body").PrependHtml(banner)
+}
+
+// listingPageOf returns the sub-forum listing page a topic is listed on.
+func (s *site) listingPageOf(topic *viewer.Topic) int {
+	_, topics, _ := s.archive.SubForum(topic.SubForumID)
+	for i, listed := range topics {
+		if listed.ID == topic.ID {
+			return i/max(s.adapter.TopicsPerPage(), 1) + 1
+		}
+	}
+	return 1
+}
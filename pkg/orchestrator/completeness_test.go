@@ -0,0 +1,66 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"project-waypoint/pkg/completeness"
+	"project-waypoint/pkg/search"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"waypoint_archive_scripts/pkg/rearchive"
+)
+
+func TestRunExtractionOrchestrator_Completeness(t *testing.T) {
+	dir := t.TempDir()
+	// Only the first of the topic's two pages was archived
+	page, err := os.ReadFile(filepath.Join("..", "..", "test-data", "regress", "corpus", "66", "19618", "page_1.html"))
+	require.NoError(t, err)
+	topicDir := filepath.Join(dir, "archive", "66", "19618")
+	require.NoError(t, os.MkdirAll(topicDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(topicDir, "page_1.html"), page, 0644))
+
+	indexDir := filepath.Join(dir, "index", "forum_66")
+	require.NoError(t, os.MkdirAll(indexDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "topic_index_66.json"), []byte(`[{"ID":"19618","Title":"Dai Vernon and Houdini","Replies":24}]`), 0644))
+	topicList := filepath.Join(dir, "topics.json")
+	require.NoError(t, os.WriteFile(topicList, []byte(`[{"topic_id":"19618","subforum_id":"66"}]`), 0644))
+
+	config := OrchestratorConfig{
+		TopicListPath:          topicList,
+		ArchivePath:            filepath.Join(dir, "archive"),
+		OutputJSONPath:         filepath.Join(dir, "output"),
+		StateFilePath:          filepath.Join(dir, "state.json"),
+		LogLevel:               "INFO",
+		TopicIndexDir:          filepath.Join(dir, "index"),
+		CompletenessReportPath: filepath.Join(dir, "completeness.json"),
+		RearchiveQueuePath:     filepath.Join(dir, "rearchive_queue.json"),
+		SearchIndexPath:        filepath.Join(dir, "search_index.gob.gz"),
+	}
+	require.NoError(t, RunExtractionOrchestrator(config))
+
+	content, err := os.ReadFile(config.CompletenessReportPath)
+	require.NoError(t, err)
+	var report completeness.Report
+	require.NoError(t, json.Unmarshal(content, &report))
+	assert.Equal(t, completeness.Counts{Short: 1, WithGaps: 1}, report.Totals)
+	require.Len(t, report.Topics, 1)
+	assert.Equal(t, 25, report.Topics[0].Expected)
+	assert.Equal(t, 20, report.Topics[0].Extracted)
+	assert.Equal(t, []int{2}, report.Topics[0].MissingPages)
+
+	queue, err := rearchive.LoadQueue(config.RearchiveQueuePath)
+	require.NoError(t, err)
+	require.True(t, queue.Contains("19618"))
+	assert.Equal(t, "short: 20 of 25 posts extracted, pages [2] missing", queue.Topics["19618"].Reason)
+
+	// The search index is updated with the output, titles taken from the topic index
+	index, err := search.Load(config.SearchIndexPath)
+	require.NoError(t, err)
+	hits := index.Search(search.ParseQuery("vernon houdini"), 0)
+	require.Len(t, hits, 20)
+	assert.Equal(t, "Dai Vernon and Houdini", hits[0].Doc.Title)
+}
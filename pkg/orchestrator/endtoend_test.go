@@ -0,0 +1,184 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"project-waypoint/pkg/completeness"
+	"project-waypoint/pkg/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"waypoint_archive_scripts/pkg/canonurl"
+	"waypoint_archive_scripts/pkg/fakeforum"
+	"waypoint_archive_scripts/pkg/indexerlogic"
+)
+
+// endToEndFixture has two sub-forums, a listing split over two pages and a topic spanning two pages.
+func endToEndFixture() fakeforum.Fixture {
+	posted := time.Date(2003, time.January, 10, 9, 5, 0, 0, time.UTC)
+	post := func(id, author string, minutes int) fakeforum.Post {
+		return fakeforum.Post{ID: id, Author: author, Posted: posted.Add(time.Duration(minutes) * time.Minute), Body: "Body of post " + id + "."}
+	}
+
+	longTopic := fakeforum.Topic{ID: "19618", Title: "Dai Vernon and Houdini", Views: 812}
+	for i := 0; i < 23; i++ {
+		longTopic.Posts = append(longTopic.Posts, post(fmt.Sprintf("1661%02d", i), fmt.Sprintf("Member%d", i%4), 60+i))
+	}
+
+	return fakeforum.Fixture{
+		TopicsPerPage: 2,
+		SubForums: []fakeforum.SubForum{
+			{ID: "66", Name: "What happened, was this...", Topics: []fakeforum.Topic{
+				longTopic,
+				{ID: "20001", Title: "Cups and balls", Posts: []fakeforum.Post{post("170001", "Slide", 5), post("170002", "Maxim", 6)}},
+				{ID: "20002", Title: "Linking rings", Posts: []fakeforum.Post{post("170003", "Steve", 1)}},
+			}},
+			{ID: "54", Name: "Silk and rope", Topics: []fakeforum.Topic{
+				{ID: "30001", Title: "Silk sizes explained", Posts: []fakeforum.Post{post("180001", "Ann", 2)}},
+			}},
+		},
+	}
+}
+
+// buildTool builds the main package pkgPath of the module in moduleDir (relative to the
+// repository root) into binDir under name.
+func buildTool(t *testing.T, moduleDir string, pkgPath string, binDir string, name string) string {
+	t.Helper()
+	dir, err := filepath.Abs(filepath.Join("..", "..", moduleDir))
+	require.NoError(t, err)
+	binPath := filepath.Join(binDir, name)
+	build := exec.Command("go", "build", "-o", binPath, pkgPath)
+	build.Dir = dir
+	output, err := build.CombinedOutput()
+	require.NoError(t, err, "building %s:\n%s", pkgPath, output)
+	return binPath
+}
+
+// runTool runs a built tool in workDir and fails the test with its output if it fails.
+func runTool(t *testing.T, workDir string, binPath string, args ...string) {
+	t.Helper()
+	run := exec.Command(binPath, args...)
+	run.Dir = workDir
+	output, err := run.CombinedOutput()
+	require.NoError(t, err, "running %s %v:\n%s", filepath.Base(binPath), args, output)
+}
+
+// TestEndToEnd_FakeForum runs generate_subforum_list, the master and core indexers, the archiver
+// and the extraction orchestrator against the fake forum, each with its own command line and in
+// its default layout under one working directory, and checks the extracted JSON against the
+// fixture it was generated from.
+func TestEndToEnd_FakeForum(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the pipeline's tools")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go tool is needed to build the pipeline's tools")
+	}
+	fixture := endToEndFixture()
+	srv := fakeforum.NewServer(fixture)
+	defer srv.Close()
+
+	workDir := t.TempDir()
+	generateSubForumList := buildTool(t, "cmd", "./generate_subforum_list", workDir, "generate_subforum_list")
+	masterIndexer := buildTool(t, "cmd", "./master_indexer", workDir, "master_indexer")
+	buildTool(t, "cmd", "./indexer", workDir, "core_indexer.exe") // The name master_indexer runs it by
+	archiver := buildTool(t, "waypoint_archive_scripts", "./cmd/archiver", workDir, "archiver")
+
+	// The front page is saved by hand before generate_subforum_list reads it
+	response, err := http.Get(srv.FrontPageURL())
+	require.NoError(t, err)
+	frontPage, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "forum_front_page.html"), frontPage, 0644))
+
+	runTool(t, workDir, generateSubForumList, "-inputFile", "forum_front_page.html", "-baseURL", srv.BaseURL())
+	subForumList := filepath.Join(workDir, "data", "subforum_list.csv")
+	subForums, err := indexerlogic.ReadSubForumList(subForumList)
+	require.NoError(t, err)
+	require.Len(t, subForums, len(fixture.SubForums))
+	for _, sf := range subForums {
+		assert.Equal(t, srv.ForumURL(sf.ID), sf.URL)
+	}
+
+	runTool(t, workDir, masterIndexer)
+	indexDir := filepath.Join(workDir, "master_output", "indexed_data")
+	indexed, err := completeness.LoadTopicIndex(indexDir)
+	require.NoError(t, err)
+	require.Len(t, indexed, 4, "every fixture topic should be indexed exactly once")
+
+	runTool(t, workDir, archiver,
+		"-subForumListFile", subForumList,
+		"-topicIndexDir", indexDir,
+		"-topicIndexFilePattern", "topic_index_*.json",
+		"-archiveOutputRootDir", "archive",
+		"-forumBaseURL", srv.BaseURL(),
+		"-politenessDelay", "0s",
+		"-jitRefreshPages", "0",
+		"-postsPerPage", fmt.Sprint(fakeforum.DefaultPostsPerPage))
+
+	// The orchestrator's topic list is every indexed topic
+	var topicList []TopicEntry
+	for _, topic := range indexed {
+		topicList = append(topicList, TopicEntry{TopicID: topic.ID, SubForumID: topic.SubForumID})
+	}
+	content, err := json.Marshal(topicList)
+	require.NoError(t, err)
+	topicListPath := filepath.Join(workDir, "topic_list.json")
+	require.NoError(t, os.WriteFile(topicListPath, content, 0644))
+
+	outputDir := filepath.Join(workDir, "output")
+	reportPath := filepath.Join(workDir, "completeness_report.json")
+	require.NoError(t, RunExtractionOrchestrator(OrchestratorConfig{
+		TopicListPath:          topicListPath,
+		ArchivePath:            filepath.Join(workDir, "archive"),
+		OutputJSONPath:         outputDir,
+		StateFilePath:          filepath.Join(workDir, "extraction_state.json"),
+		LogLevel:               "ERROR",
+		TopicIndexDir:          indexDir,
+		PostsPerPage:           fakeforum.DefaultPostsPerPage,
+		CompletenessReportPath: reportPath,
+	}))
+
+	content, err = os.ReadFile(reportPath)
+	require.NoError(t, err)
+	var report completeness.Report
+	require.NoError(t, json.Unmarshal(content, &report))
+	assert.Equal(t, 4, report.Checked)
+	assert.Equal(t, 4, report.Totals.Complete, "topics that are not complete: %+v", report.Topics)
+
+	for _, sf := range fixture.SubForums {
+		for _, topic := range sf.Topics {
+			content, err := os.ReadFile(filepath.Join(outputDir, fmt.Sprintf("%s_%s.json", sf.ID, topic.ID)))
+			require.NoError(t, err)
+			var posts []data.PostMetadata
+			require.NoError(t, json.Unmarshal(content, &posts))
+			require.Len(t, posts, len(topic.Posts), "topic %s", topic.ID)
+
+			for i, want := range topic.Posts {
+				got := posts[i]
+				page := i/fakeforum.DefaultPostsPerPage + 1
+				assert.Equal(t, want.ID, got.PostID, "topic %s post %d", topic.ID, i)
+				assert.Equal(t, topic.ID, got.TopicID)
+				assert.Equal(t, sf.ID, got.SubForumID)
+				assert.Equal(t, want.Author, got.AuthorUsername)
+				assert.Equal(t, want.Posted.Format("2006-01-02 15:04:05"), got.Timestamp)
+				assert.Equal(t, page, got.PageNumber)
+				assert.Equal(t, i%fakeforum.DefaultPostsPerPage, got.PostOrderOnPage)
+				assert.Equal(t, canonurl.Post(canonurl.SubForumID(sf.ID), canonurl.TopicID(topic.ID), canonurl.PostID(want.ID),
+					canonurl.StartForPage(page, fakeforum.DefaultPostsPerPage)).String(), got.PostURL)
+				if assert.NotEmpty(t, got.ParsedContent, "topic %s post %s", topic.ID, want.ID) {
+					assert.Contains(t, got.ParsedContent[len(got.ParsedContent)-1].Content, want.Body)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"project-waypoint/pkg/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// phpbbTopicPage is a phpBB 3 (prosilver) topic page with two posts.
+const phpbbTopicPage = `<html><body>
+<div id="p101" class="post has-profile bg2"><div class="inner">
+	<dl class="postprofile"><dt><a href="./memberlist.php?mode=viewprofile&amp;u=3" class="username">carol</a></dt></dl>
+	<div class="postbody">
+		<p class="author">by <strong><a href="./memberlist.php?mode=viewprofile&amp;u=3" class="username">carol</a></strong> &raquo; <time datetime="2023-01-05T10:00:00+00:00">Thu Jan 05, 2023 10:00 am</time></p>
+		<div class="content">First post text</div>
+	</div>
+</div></div>
+<div id="p102" class="post bg1"><div class="inner"><div class="postbody">
+	<p class="author">by <strong><span class="username">erin</span></strong> &raquo; Thu Jan 05, 2023 11:30 pm</p>
+	<div class="content">Reply text</div>
+</div></div></div>
+</body></html>`
+
+func TestRunExtractionOrchestrator_Engine(t *testing.T) {
+	dir := t.TempDir()
+	topicDir := filepath.Join(dir, "archive", "2", "10")
+	require.NoError(t, os.MkdirAll(topicDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(topicDir, "page_1.html"), []byte(phpbbTopicPage), 0644))
+	topicList := filepath.Join(dir, "topics.json")
+	require.NoError(t, os.WriteFile(topicList, []byte(`[{"topic_id":"10","subforum_id":"2"}]`), 0644))
+
+	config := OrchestratorConfig{
+		TopicListPath:  topicList,
+		ArchivePath:    filepath.Join(dir, "archive"),
+		OutputJSONPath: filepath.Join(dir, "output"),
+		StateFilePath:  filepath.Join(dir, "state.json"),
+		LogLevel:       "ERROR",
+		Engine:         "phpbb3",
+	}
+	require.NoError(t, RunExtractionOrchestrator(config))
+
+	content, err := os.ReadFile(filepath.Join(config.OutputJSONPath, "2_10.json"))
+	require.NoError(t, err)
+	var posts []data.PostMetadata
+	require.NoError(t, json.Unmarshal(content, &posts))
+	require.Len(t, posts, 2)
+	assert.Equal(t, "101", posts[0].PostID)
+	assert.Equal(t, "carol", posts[0].AuthorUsername)
+	assert.Equal(t, "102", posts[1].PostID)
+	assert.Equal(t, "erin", posts[1].AuthorUsername)
+
+	config.Engine = "vbulletin"
+	assert.ErrorContains(t, RunExtractionOrchestrator(config), "unknown forum engine")
+
+	config.Engine = "phpbb3"
+	config.ProfilesPath = filepath.Join(dir, "profiles.json")
+	assert.ErrorContains(t, RunExtractionOrchestrator(config), "not supported by the phpbb3 adapter")
+}
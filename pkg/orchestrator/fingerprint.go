@@ -0,0 +1,130 @@
+package orchestrator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"waypoint_archive_scripts/pkg/forumadapter"
+)
+
+// ExtractorVersion identifies the extraction logic in topic fingerprints. Bump it whenever a change
+// alters the extracted JSON (new fields, parser or cleaning fixes, selector changes), so the next
+// run re-extracts every topic; topics whose inputs and extractor are unchanged are skipped.
+const ExtractorVersion = "2026.10.1"
+
+// FingerprintRecord is what the orchestrator remembers about the last extraction of a topic.
+type FingerprintRecord struct {
+	Fingerprint string    `json:"fingerprint"` // Hash of the extractor and the topic's page files
+	Extractor   string    `json:"extractor"`   // Extractor the topic was processed with (see extractorID)
+	PageFiles   int       `json:"page_files"`  // Number of page files hashed
+	Status      string    `json:"status"`      // StateCompleted or StateFailed
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// Fingerprints maps topic IDs to the record of their last extraction. Unlike State it survives a
+// "full" run, for which the state file is deleted.
+type Fingerprints map[string]FingerprintRecord
+
+// extractorID is ExtractorVersion plus the forum adapter in use and the fingerprint of the
+// selector profiles loaded into it, if any, since different adapters or profiles extract
+// different output from the same pages.
+func extractorID(adapter forumadapter.ForumAdapter) string {
+	id := ExtractorVersion + "/" + adapter.Name()
+	if magicCafe, ok := adapter.(forumadapter.MagicCafe); ok && magicCafe.Profiles != nil {
+		id += "/" + magicCafe.Profiles.Fingerprint()
+	}
+	return id
+}
+
+// TopicFingerprint hashes the extractor together with the names and contents of a topic's page
+// files, in the order given.
+func TopicFingerprint(extractor string, pageFiles []string) (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "extractor %s\n", extractor)
+	for _, pageFile := range pageFiles {
+		file, err := os.Open(pageFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to open page file %s for fingerprinting: %w", pageFile, err)
+		}
+		info, err := file.Stat()
+		if err == nil {
+			fmt.Fprintf(hash, "file %s %d\n", filepath.Base(pageFile), info.Size())
+			_, err = io.Copy(hash, file)
+		}
+		file.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to read page file %s for fingerprinting: %w", pageFile, err)
+		}
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fingerprintFilePath returns the configured fingerprint file, or one next to the state file
+// (e.g. state.json -> state.fingerprints.json).
+func fingerprintFilePath(config OrchestratorConfig) string {
+	if config.FingerprintFilePath != "" {
+		return config.FingerprintFilePath
+	}
+	ext := filepath.Ext(config.StateFilePath)
+	return strings.TrimSuffix(config.StateFilePath, ext) + ".fingerprints" + ext
+}
+
+// LoadFingerprints reads the fingerprint file (JSON format) from the given path.
+// If the file doesn't exist, it returns empty fingerprints and no error.
+func LoadFingerprints(filePath string) (Fingerprints, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(Fingerprints), nil
+		}
+		return nil, fmt.Errorf("failed to read fingerprint file %s: %w", filePath, err)
+	}
+	fingerprints := make(Fingerprints)
+	if len(content) == 0 {
+		return fingerprints, nil
+	}
+	if err := json.Unmarshal(content, &fingerprints); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal fingerprints JSON from %s: %w", filePath, err)
+	}
+	return fingerprints, nil
+}
+
+// SaveFingerprints atomically saves the fingerprints to the given file path as JSON,
+// writing to a temporary file first like SaveState.
+func SaveFingerprints(filePath string, fingerprints Fingerprints) error {
+	content, err := json.MarshalIndent(fingerprints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal fingerprints to JSON: %w", err)
+	}
+	tempFilePath := filePath + ".tmp"
+	if err := os.WriteFile(tempFilePath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write fingerprints to temporary file %s: %w", tempFilePath, err)
+	}
+	if err := os.Rename(tempFilePath, filePath); err != nil {
+		_ = os.Remove(tempFilePath)
+		return fmt.Errorf("failed to rename temporary fingerprint file %s to %s: %w", tempFilePath, filePath, err)
+	}
+	return nil
+}
+
+// changeReason explains why a topic with the given fingerprint must be processed, or returns ""
+// if its last extraction used the same inputs and extractor.
+func changeReason(record FingerprintRecord, found bool, fingerprint string, extractor string) string {
+	switch {
+	case !found:
+		return "no fingerprint recorded"
+	case record.Fingerprint == fingerprint:
+		return ""
+	case record.Extractor != extractor:
+		return fmt.Sprintf("extractor changed from %s to %s", record.Extractor, extractor)
+	default:
+		return "page files changed"
+	}
+}
@@ -0,0 +1,140 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"waypoint_archive_scripts/pkg/forumadapter"
+)
+
+func TestTopicFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	page1, page2 := filepath.Join(dir, "page_1.html"), filepath.Join(dir, "page_2.html")
+	require.NoError(t, os.WriteFile(page1, []byte("<html>one</html>"), 0644))
+	require.NoError(t, os.WriteFile(page2, []byte("<html>two</html>"), 0644))
+
+	fingerprint, err := TopicFingerprint("v1", []string{page1, page2})
+	require.NoError(t, err)
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, fingerprint)
+
+	again, err := TopicFingerprint("v1", []string{page1, page2})
+	require.NoError(t, err)
+	assert.Equal(t, fingerprint, again)
+
+	otherExtractor, err := TopicFingerprint("v2", []string{page1, page2})
+	require.NoError(t, err)
+	assert.NotEqual(t, fingerprint, otherExtractor)
+
+	onePage, err := TopicFingerprint("v1", []string{page1})
+	require.NoError(t, err)
+	assert.NotEqual(t, fingerprint, onePage)
+
+	require.NoError(t, os.WriteFile(page2, []byte("<html>two, edited</html>"), 0644))
+	edited, err := TopicFingerprint("v1", []string{page1, page2})
+	require.NoError(t, err)
+	assert.NotEqual(t, fingerprint, edited)
+
+	_, err = TopicFingerprint("v1", []string{filepath.Join(dir, "page_3.html")})
+	assert.Error(t, err)
+}
+
+func TestFingerprints_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.fingerprints.json")
+	fingerprints, err := LoadFingerprints(path)
+	require.NoError(t, err)
+	assert.Empty(t, fingerprints)
+
+	fingerprints["t1"] = FingerprintRecord{Fingerprint: "sha256:ab", Extractor: "v1/magiccafe", PageFiles: 2, Status: StateCompleted,
+		ProcessedAt: time.Date(2026, time.October, 18, 9, 0, 0, 0, time.UTC)}
+	require.NoError(t, SaveFingerprints(path, fingerprints))
+
+	loaded, err := LoadFingerprints(path)
+	require.NoError(t, err)
+	assert.Equal(t, fingerprints, loaded)
+
+	assert.Equal(t, filepath.Join("data", "run_state.fingerprints.json"), fingerprintFilePath(OrchestratorConfig{StateFilePath: filepath.Join("data", "run_state.json")}))
+	assert.Equal(t, "custom.json", fingerprintFilePath(OrchestratorConfig{StateFilePath: "state.json", FingerprintFilePath: "custom.json"}))
+}
+
+func TestExtractorID(t *testing.T) {
+	assert.Equal(t, ExtractorVersion+"/magiccafe", extractorID(forumadapter.MagicCafe{}))
+	assert.Equal(t, ExtractorVersion+"/phpbb3", extractorID(forumadapter.PhpBB3{}))
+
+	// Loaded selector profiles are part of the extractor, so editing them re-extracts every topic
+	profiles := forumadapter.BuiltinProfiles().Profiles()
+	profilesPath := filepath.Join(t.TempDir(), "profiles.json")
+	withProfiles := func() string {
+		content, err := json.Marshal(map[string][]forumadapter.Profile{"profiles": profiles})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(profilesPath, content, 0644))
+		adapter, err := forumAdapter(OrchestratorConfig{ProfilesPath: profilesPath})
+		require.NoError(t, err)
+		return extractorID(adapter)
+	}
+	loaded := withProfiles()
+	assert.Equal(t, ExtractorVersion+"/magiccafe/"+forumadapter.BuiltinProfiles().Fingerprint(), loaded)
+	profiles[0].TimestampLayouts = append(profiles[0].TimestampLayouts, "2006-01-02 15:04")
+	assert.NotEqual(t, loaded, withProfiles())
+}
+
+func TestChangeReason(t *testing.T) {
+	record := FingerprintRecord{Fingerprint: "sha256:ab", Extractor: "v1/magiccafe"}
+	assert.Equal(t, "no fingerprint recorded", changeReason(FingerprintRecord{}, false, "sha256:ab", "v1/magiccafe"))
+	assert.Empty(t, changeReason(record, true, "sha256:ab", "v1/magiccafe"))
+	assert.Equal(t, "extractor changed from v1/magiccafe to v2/magiccafe", changeReason(record, true, "sha256:cd", "v2/magiccafe"))
+	assert.Equal(t, "page files changed", changeReason(record, true, "sha256:cd", "v1/magiccafe"))
+}
+
+func TestRunExtractionOrchestrator_Fingerprints(t *testing.T) {
+	dir := t.TempDir()
+	page, err := os.ReadFile(filepath.Join("..", "..", "test-data", "regress", "corpus", "66", "19618", "page_1.html"))
+	require.NoError(t, err)
+	topicDir := filepath.Join(dir, "archive", "66", "19618")
+	require.NoError(t, os.MkdirAll(topicDir, 0755))
+	pagePath := filepath.Join(topicDir, "page_1.html")
+	require.NoError(t, os.WriteFile(pagePath, page, 0644))
+	topicList := filepath.Join(dir, "topics.json")
+	require.NoError(t, os.WriteFile(topicList, []byte(`[{"topic_id":"19618","subforum_id":"66"}]`), 0644))
+
+	config := OrchestratorConfig{
+		TopicListPath:  topicList,
+		ArchivePath:    filepath.Join(dir, "archive"),
+		OutputJSONPath: filepath.Join(dir, "output"),
+		StateFilePath:  filepath.Join(dir, "state.json"),
+		LogLevel:       "INFO",
+	}
+	outputPath := filepath.Join(config.OutputJSONPath, "66_19618.json")
+	fingerprintPath := filepath.Join(dir, "state.fingerprints.json")
+
+	processedAt := func() time.Time {
+		fingerprints, err := LoadFingerprints(fingerprintPath)
+		require.NoError(t, err)
+		return fingerprints["19618"].ProcessedAt
+	}
+
+	require.NoError(t, RunExtractionOrchestrator(config))
+	require.FileExists(t, outputPath)
+	first := processedAt()
+	require.False(t, first.IsZero())
+
+	// A full run (state deleted) skips the unchanged topic and restores its state
+	require.NoError(t, os.Remove(config.StateFilePath))
+	require.NoError(t, os.Remove(outputPath))
+	require.NoError(t, RunExtractionOrchestrator(config))
+	assert.NoFileExists(t, outputPath, "an unchanged topic is not extracted again")
+	assert.Equal(t, first, processedAt())
+	state, err := LoadState(config.StateFilePath)
+	require.NoError(t, err)
+	assert.Equal(t, StateCompleted, state["19618"])
+
+	// Refreshed HTML is extracted again although the state says completed
+	require.NoError(t, os.WriteFile(pagePath, append(page, []byte("\n<!-- refreshed -->\n")...), 0644))
+	require.NoError(t, RunExtractionOrchestrator(config))
+	assert.FileExists(t, outputPath)
+	assert.True(t, processedAt().After(first))
+}
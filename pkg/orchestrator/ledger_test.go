@@ -0,0 +1,52 @@
+package orchestrator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"project-waypoint/pkg/errorledger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessTopicWithLedger(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("..", "..", "test-data", "regress", "corpus", "66", "19618", "page_1.html"))
+	require.NoError(t, err)
+	// The first post loses its post ID, which drops it from the output
+	broken := strings.Replace(string(page), `<span id="p_165858">`, `<span>`, 1)
+
+	archiveDir := t.TempDir()
+	topicDir := filepath.Join(archiveDir, "66", "19618")
+	require.NoError(t, os.MkdirAll(topicDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(topicDir, "page_1.html"), []byte(broken), 0644))
+	// A page that cannot be opened is skipped as a whole
+	require.NoError(t, os.Symlink(filepath.Join(topicDir, "missing.html"), filepath.Join(topicDir, "page_2.html")))
+
+	ledgerPath := filepath.Join(t.TempDir(), "errors.jsonl")
+	ledger, err := errorledger.Open(ledgerPath)
+	require.NoError(t, err)
+	require.NoError(t, ProcessTopicWithLedger("19618", archiveDir, t.TempDir(), ledger))
+	require.NoError(t, ledger.Close())
+
+	entries, err := errorledger.Load(ledgerPath)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	dropped := entries[0]
+	assert.Equal(t, "66", dropped.SubForumID)
+	assert.Equal(t, "19618", dropped.TopicID)
+	assert.Equal(t, filepath.Join(topicDir, "page_1.html"), dropped.PageFile)
+	assert.Equal(t, 0, dropped.PostIndex)
+	assert.Equal(t, errorledger.StageMetadata, dropped.Stage)
+	assert.Equal(t, errorledger.OutcomeDropped, dropped.Outcome)
+	assert.Contains(t, dropped.Error, "post")
+	assert.Contains(t, dropped.HTML, "Maxim")
+	assert.NotEmpty(t, dropped.HTMLSHA256)
+
+	assert.Equal(t, errorledger.StageLoadPage, entries[1].Stage)
+	assert.Equal(t, -1, entries[1].PostIndex)
+	assert.Equal(t, filepath.Join(topicDir, "page_2.html"), entries[1].PageFile)
+}
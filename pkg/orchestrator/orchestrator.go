@@ -7,6 +7,16 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+
+	"project-waypoint/pkg/completeness"
+	"project-waypoint/pkg/errorledger"
+	"project-waypoint/pkg/redact"
+	"project-waypoint/pkg/search"
+
+	wdata "waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/forumadapter"
+	"waypoint_archive_scripts/pkg/rearchive"
 )
 
 // OrchestratorConfig holds the configuration for the extraction orchestrator.
@@ -17,6 +27,47 @@ type OrchestratorConfig struct {
 	OutputJSONPath string `json:"outputJsonPath"` // Path to the directory where JSON files will be saved (used by ProcessTopic)
 	StateFilePath  string `json:"stateFilePath"`  // Path to the state file for resumability
 	LogLevel       string `json:"logLevel"`       // Logging level (e.g., "DEBUG", "INFO", "WARN", "ERROR")
+	// ErrorLedgerPath is the JSON Lines file dropped and degraded posts are appended to (see
+	// package errorledger). Empty disables the ledger.
+	ErrorLedgerPath string `json:"errorLedgerPath"`
+	// TopicIndexDir holds the topic index JSON files (see completeness.LoadTopicIndex). When set,
+	// every processed topic is checked against its indexed reply count.
+	TopicIndexDir string `json:"topicIndexDir"`
+	// PostsPerPage is the forum's topic page size for the completeness check; 0 uses the adapter's.
+	PostsPerPage int `json:"postsPerPage"`
+	// CompletenessReportPath is where the completeness report is written as JSON. Empty only logs it.
+	CompletenessReportPath string `json:"completenessReportPath"`
+	// RearchiveQueuePath is the archiver's re-archive queue. When set, short topics and topics with
+	// missing pages are queued for re-archiving.
+	RearchiveQueuePath string `json:"rearchiveQueuePath"`
+	// FingerprintFilePath records a fingerprint of each processed topic's page files and extractor
+	// version. Topics whose fingerprint is unchanged are skipped and all others are processed,
+	// whatever the state says. Defaults to the state file name with ".fingerprints" before the extension.
+	FingerprintFilePath string `json:"fingerprintFilePath"`
+	// SearchIndexPath is the full-text search index (see package search). When set, it is updated
+	// from the output directory at the end of the run, with topic titles from TopicIndexDir.
+	SearchIndexPath string `json:"searchIndexPath"`
+	// RedactionConfigPath is a redaction config (see package redact). When set, every extracted
+	// post is redacted before it is saved, and changing the config re-extracts every topic.
+	RedactionConfigPath string `json:"redactionConfigPath"`
+	// Engine is the forum engine the archive was made from (see forumadapter.Lookup); empty means
+	// Magic Cafe.
+	Engine string `json:"engine"`
+	// ProfilesPath is a selector profiles file for the magiccafe engine (see
+	// forumadapter.WithProfilesFile). Empty uses the built-in profile.
+	ProfilesPath string `json:"profilesPath"`
+}
+
+// forumAdapter returns the adapter for the engine and selector profiles configured in config.
+func forumAdapter(config OrchestratorConfig) (forumadapter.ForumAdapter, error) {
+	adapter, err := forumadapter.Lookup(config.Engine)
+	if err != nil {
+		return nil, err
+	}
+	if config.ProfilesPath == "" {
+		return adapter, nil
+	}
+	return forumadapter.WithProfilesFile(adapter, config.ProfilesPath)
 }
 
 // TopicEntry defines the structure of an entry in the input topic list.
@@ -183,7 +234,84 @@ func RunExtractionOrchestrator(config OrchestratorConfig) error {
 		log.Printf("No existing state file found at %s or state file is empty. Starting a fresh run.", config.StateFilePath)
 	}
 
+	var ledger *errorledger.Ledger
+	if config.ErrorLedgerPath != "" {
+		ledger, err = errorledger.Open(config.ErrorLedgerPath)
+		if err != nil {
+			return fmt.Errorf("failed to open error ledger: %w", err)
+		}
+		defer func() {
+			if closeErr := ledger.Close(); closeErr != nil {
+				log.Printf("ERROR: %v", closeErr)
+			}
+		}()
+	}
+
+	// Markup and URL scheme of the forum the archive was made from
+	adapter, err := forumAdapter(config)
+	if err != nil {
+		return fmt.Errorf("failed to set up forum adapter: %w", err)
+	}
+	log.Printf("Forum adapter: %s", adapter.Name())
+
+	// Fingerprints of earlier extractions, kept apart from the state so they survive a full run
+	fingerprintPath := fingerprintFilePath(config)
+	fingerprints, err := LoadFingerprints(fingerprintPath)
+	if err != nil {
+		log.Printf("CRITICAL ERROR: Failed to load fingerprint file from %s: %v. Cannot proceed.", fingerprintPath, err)
+		return fmt.Errorf("failed to load fingerprints: %w", err)
+	}
+	// Redaction after content cleaning, if configured; its settings are part of the extractor so
+	// output redacted differently is not taken as current
+	extractor := extractorID(adapter)
+	var redactor *redact.Redactor
+	if config.RedactionConfigPath != "" {
+		redactionConfig, err := redact.LoadConfig(config.RedactionConfigPath)
+		if err != nil {
+			return err
+		}
+		redactor, err = redact.New(redactionConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create redactor: %w", err)
+		}
+		defer func() {
+			if closeErr := redactor.Close(); closeErr != nil {
+				log.Printf("ERROR: %v", closeErr)
+			}
+		}()
+		extractor += "/" + redactor.Fingerprint()
+	}
+	log.Printf("Loaded fingerprints for %d topics from %s. Extractor: %s", len(fingerprints), fingerprintPath, extractor)
+
+	// Completeness check against the topic index, if configured
+	var indexedTopics map[string]wdata.Topic
+	var completenessReport *completeness.Report
+	postsPerPage := config.PostsPerPage
+	if postsPerPage <= 0 {
+		postsPerPage = adapter.PostsPerPage()
+	}
+	if config.TopicIndexDir != "" {
+		topics, err := completeness.LoadTopicIndex(config.TopicIndexDir)
+		if err != nil {
+			return fmt.Errorf("failed to load topic index for the completeness check: %w", err)
+		}
+		indexedTopics = make(map[string]wdata.Topic, len(topics))
+		for _, topic := range topics {
+			indexedTopics[topic.ID] = topic
+		}
+		completenessReport = completeness.NewReport()
+	}
+
+	// One scan of the archive serves every topic of the run
+	archivedTopics, scanErr := ScanArchive(config.ArchivePath)
+	if scanErr != nil {
+		log.Printf("ERROR: %v", scanErr)
+	} else {
+		log.Printf("Found %d archived topics in %s", len(archivedTopics), config.ArchivePath)
+	}
+
 	var topicsProcessedThisRun int
+	var statesRestored int // Topics skipped as unchanged whose status came from their fingerprint
 	var topicsFailedThisRun int
 	var topicsSkipped int
 
@@ -196,7 +324,34 @@ func RunExtractionOrchestrator(config OrchestratorConfig) error {
 
 		log.Printf("Processing topic %d/%d: ID=%s, SubForumID=%s", i+1, len(allTopics), topicEntry.TopicID, topicEntry.SubForumID)
 
-		if status, found := state[topicEntry.TopicID]; found && status == StateCompleted {
+		// The fingerprint decides whether the topic needs processing. Without one (the archive
+		// could not be scanned or read) the state decides, as it did before fingerprints.
+		archived := archivedTopics[topicEntry.TopicID]
+		pageFiles, subForumID, findErr := archived.PageFiles, archived.SubForumID, scanErr
+		fingerprint := ""
+		if findErr == nil {
+			fingerprint, err = TopicFingerprint(extractor, pageFiles)
+			if err != nil {
+				log.Printf("WARNING: Could not fingerprint topic %s: %v", topicEntry.TopicID, err)
+				fingerprint = ""
+			}
+		}
+
+		status, found := state[topicEntry.TopicID]
+		if fingerprint != "" {
+			record, recorded := fingerprints[topicEntry.TopicID]
+			reason := changeReason(record, recorded, fingerprint, extractor)
+			if reason == "" {
+				if !found {
+					state[topicEntry.TopicID] = record.Status // Full run: the fingerprint remembers the outcome
+					statesRestored++
+				}
+				log.Printf("Topic %s is unchanged since it was processed (%s). Skipping.", topicEntry.TopicID, state[topicEntry.TopicID])
+				topicsSkipped++
+				continue
+			}
+			log.Printf("Topic %s needs processing: %s.", topicEntry.TopicID, reason)
+		} else if found && status == StateCompleted {
 			log.Printf("Topic %s already marked as '%s'. Skipping.", topicEntry.TopicID, StateCompleted)
 			topicsSkipped++
 			continue
@@ -207,9 +362,12 @@ func RunExtractionOrchestrator(config OrchestratorConfig) error {
 		}
 
 		log.Printf("Attempting to process topic ID: %s", topicEntry.TopicID)
-		// The actual ProcessTopic function (from topic_processor.go) expects 3 arguments:
-		// func ProcessTopic(topicID string, archivePath string, outputPath string) error
-		err = ProcessTopic(topicEntry.TopicID, config.ArchivePath, config.OutputJSONPath) // Ensure this is 3 arguments
+		// Dropped and degraded posts go to the error ledger when one is configured (nil records nothing)
+		if findErr != nil {
+			err = findErr
+		} else {
+			err = processTopicFiles(adapter, topicEntry.TopicID, pageFiles, subForumID, config.ArchivePath, config.OutputJSONPath, ledger, redactor)
+		}
 
 		if err != nil {
 			log.Printf("ERROR: Failed to process topic ID %s: %v", topicEntry.TopicID, err)
@@ -219,6 +377,7 @@ func RunExtractionOrchestrator(config OrchestratorConfig) error {
 			log.Printf("Successfully processed topic ID %s.", topicEntry.TopicID)
 			state[topicEntry.TopicID] = StateCompleted
 			topicsProcessedThisRun++
+			checkCompleteness(completenessReport, indexedTopics, topicEntry, config.OutputJSONPath, postsPerPage)
 		}
 
 		log.Printf("Saving current state after processing topic %s...", topicEntry.TopicID)
@@ -227,10 +386,28 @@ func RunExtractionOrchestrator(config OrchestratorConfig) error {
 		} else {
 			log.Printf("State saved successfully to %s.", config.StateFilePath)
 		}
+		if fingerprint != "" {
+			fingerprints[topicEntry.TopicID] = FingerprintRecord{
+				Fingerprint: fingerprint,
+				Extractor:   extractor,
+				PageFiles:   len(pageFiles),
+				Status:      state[topicEntry.TopicID],
+				ProcessedAt: time.Now().UTC(),
+			}
+			if saveErr := SaveFingerprints(fingerprintPath, fingerprints); saveErr != nil {
+				log.Printf("ERROR: Failed to save fingerprints to %s after processing topic %s: %v. The topic will be processed again next run.", fingerprintPath, topicEntry.TopicID, saveErr)
+			}
+		}
 		log.Printf("Progress: %d/%d topics attempted in this run. Current stats - Processed: %d, Failed: %d, Skipped (already done/failed): %d",
 			i+1, len(allTopics), topicsProcessedThisRun, topicsFailedThisRun, topicsSkipped)
 	}
 
+	if statesRestored > 0 {
+		if saveErr := SaveState(config.StateFilePath, state); saveErr != nil {
+			log.Printf("ERROR: Failed to save state file to %s with %d statuses restored from fingerprints: %v", config.StateFilePath, statesRestored, saveErr)
+		}
+	}
+
 	if shutdownRequested {
 		log.Println("Orchestration run interrupted by signal.")
 	} else {
@@ -241,13 +418,99 @@ func RunExtractionOrchestrator(config OrchestratorConfig) error {
 	log.Printf("  Total topics from input list: %d", len(allTopics))
 	log.Printf("  Topics processed successfully in this run: %d", topicsProcessedThisRun)
 	log.Printf("  Topics failed to process in this run: %d", topicsFailedThisRun)
-	log.Printf("  Topics skipped (previously completed or failed): %d", topicsSkipped)
+	log.Printf("  Topics skipped (unchanged, or previously completed or failed): %d", topicsSkipped)
 	log.Printf("  Total topics now marked as '%s' in state: %d", StateCompleted, countStatus(state, StateCompleted))
 	log.Printf("  Total topics now marked as '%s' in state: %d", StateFailed, countStatus(state, StateFailed))
+	if ledger != nil {
+		log.Printf("  Dropped and degraded posts recorded in: %s", ledger.Path())
+	}
+	if completenessReport != nil {
+		finishCompletenessReport(completenessReport, config)
+	}
+	if config.SearchIndexPath != "" {
+		updateSearchIndex(config, indexedTopics)
+	}
 
 	return nil
 }
 
+// checkCompleteness checks a processed topic against its index entry and adds the result to
+// report. Topics missing from the index cannot be checked and are only logged.
+func checkCompleteness(report *completeness.Report, indexedTopics map[string]wdata.Topic, entry TopicEntry, outputPath string, postsPerPage int) {
+	if report == nil {
+		return
+	}
+	topic, ok := indexedTopics[entry.TopicID]
+	if !ok {
+		log.Printf("WARNING: Topic %s is not in the topic index. Skipping completeness check.", entry.TopicID)
+		return
+	}
+	result, err := completeness.CheckOutput(outputPath, topic, postsPerPage)
+	if err != nil {
+		log.Printf("ERROR: Completeness check of topic %s failed: %v", entry.TopicID, err)
+		return
+	}
+	report.Add(result)
+	if !result.Clean() {
+		log.Printf("WARNING: Topic %s is incomplete: %s", entry.TopicID, result.Reason())
+	}
+}
+
+// finishCompletenessReport logs the completeness totals, writes the report and queues topics
+// for re-archiving as configured.
+func finishCompletenessReport(report *completeness.Report, config OrchestratorConfig) {
+	t := report.Totals
+	log.Printf("  Completeness of %d topics checked: %d complete, %d short, %d over-counted, %d with gaps",
+		report.Checked, t.Complete, t.Short, t.OverCounted, t.WithGaps)
+
+	if config.CompletenessReportPath != "" {
+		if err := report.Save(config.CompletenessReportPath); err != nil {
+			log.Printf("ERROR: %v", err)
+		} else {
+			log.Printf("  Completeness report written to: %s", config.CompletenessReportPath)
+		}
+	}
+
+	if config.RearchiveQueuePath != "" {
+		queue, err := rearchive.LoadQueue(config.RearchiveQueuePath)
+		if err != nil {
+			log.Printf("ERROR: Topics not queued for re-archiving: %v", err)
+			return
+		}
+		queued := report.QueueRearchive(queue)
+		if err := queue.Save(config.RearchiveQueuePath); err != nil {
+			log.Printf("ERROR: Failed to save re-archive queue: %v", err)
+			return
+		}
+		log.Printf("  Topics newly queued for re-archiving: %d (queue: %s)", queued, config.RearchiveQueuePath)
+	}
+}
+
+// updateSearchIndex brings the search index up to date with the output directory. Only output
+// files written since the last update are indexed again.
+func updateSearchIndex(config OrchestratorConfig, indexedTopics map[string]wdata.Topic) {
+	index, err := search.Load(config.SearchIndexPath)
+	if err != nil {
+		log.Printf("ERROR: Search index not updated: %v", err)
+		return
+	}
+	titles := make(map[string]string, len(indexedTopics))
+	for id, topic := range indexedTopics {
+		titles[id] = topic.Title
+	}
+	stats, err := index.Update(config.OutputJSONPath, titles)
+	if err != nil {
+		log.Printf("ERROR: Search index not updated: %v", err)
+		return
+	}
+	if err := index.Save(config.SearchIndexPath); err != nil {
+		log.Printf("ERROR: Failed to save search index: %v", err)
+		return
+	}
+	log.Printf("  Search index updated: %d files added, %d updated, %d removed (index: %s)",
+		stats.Added, stats.Updated, stats.Removed, config.SearchIndexPath)
+}
+
 // countStatus is a helper to count topics with a specific status in the state map.
 func countStatus(state State, status string) int {
 	count := 0
@@ -0,0 +1,58 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"project-waypoint/pkg/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunExtractionOrchestrator_Redaction(t *testing.T) {
+	dir := t.TempDir()
+	page, err := os.ReadFile(filepath.Join("..", "..", "test-data", "regress", "corpus", "66", "19618", "page_1.html"))
+	require.NoError(t, err)
+	topicDir := filepath.Join(dir, "archive", "66", "19618")
+	require.NoError(t, os.MkdirAll(topicDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(topicDir, "page_1.html"), page, 0644))
+	topicList := filepath.Join(dir, "topics.json")
+	require.NoError(t, os.WriteFile(topicList, []byte(`[{"topic_id":"19618","subforum_id":"66"}]`), 0644))
+
+	redactionConfig := filepath.Join(dir, "redaction.json")
+	auditLog := filepath.Join(dir, "redactions.jsonl")
+	require.NoError(t, os.WriteFile(redactionConfig, []byte(`{"pseudonymiseUsers":true,"secret":"s3cret","auditLogPath":"`+filepath.ToSlash(auditLog)+`"}`), 0644))
+	config := OrchestratorConfig{
+		TopicListPath:       topicList,
+		ArchivePath:         filepath.Join(dir, "archive"),
+		OutputJSONPath:      filepath.Join(dir, "output"),
+		StateFilePath:       filepath.Join(dir, "state.json"),
+		LogLevel:            "INFO",
+		RedactionConfigPath: redactionConfig,
+	}
+	require.NoError(t, RunExtractionOrchestrator(config))
+
+	content, err := os.ReadFile(filepath.Join(config.OutputJSONPath, "66_19618.json"))
+	require.NoError(t, err)
+	var posts []data.PostMetadata
+	require.NoError(t, json.Unmarshal(content, &posts))
+	require.Len(t, posts, 20)
+	for _, post := range posts {
+		assert.Regexp(t, `^user-[0-9a-f]{10}$`, post.AuthorUsername)
+	}
+	audit, err := os.ReadFile(auditLog)
+	require.NoError(t, err)
+	assert.Contains(t, string(audit), `"field":"author_username"`)
+
+	// Other redaction settings re-extract the topic although its pages are unchanged
+	require.NoError(t, os.WriteFile(redactionConfig, []byte(`{"secret":"s3cret"}`), 0644))
+	require.NoError(t, RunExtractionOrchestrator(config))
+	content, err = os.ReadFile(filepath.Join(config.OutputJSONPath, "66_19618.json"))
+	require.NoError(t, err)
+	posts = nil
+	require.NoError(t, json.Unmarshal(content, &posts))
+	assert.NotRegexp(t, `^user-`, posts[0].AuthorUsername)
+}
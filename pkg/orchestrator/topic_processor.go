@@ -11,11 +11,15 @@ import (
 	"strings"
 
 	"project-waypoint/pkg/data" // Assuming PostMetadata is here
+	"project-waypoint/pkg/errorledger"
 	"project-waypoint/pkg/extractorlogic"
 	"project-waypoint/pkg/htmlparser"
 	"project-waypoint/pkg/parser" // Added for content parsing
+	"project-waypoint/pkg/redact"
 
 	"github.com/PuerkitoBio/goquery"
+	"waypoint_archive_scripts/pkg/canonurl"
+	"waypoint_archive_scripts/pkg/forumadapter"
 )
 
 // TopicInfo might be needed to carry subforum_id or other relevant topic-level details.
@@ -28,8 +32,24 @@ type TopicInfo struct {
 // ProcessTopic orchestrates the processing of all pages for a given topic ID.
 // It identifies HTML files, processes them in order, and prepares for data extraction.
 func ProcessTopic(topicID string, archivePath string, outputPath string /*, topicMetadata data.TopicInfo */) error {
+	return ProcessTopicWithLedger(topicID, archivePath, outputPath, nil)
+}
+
+// ProcessTopicWithLedger is ProcessTopic recording every skipped page and every dropped or
+// degraded post to ledger. A nil ledger records nothing.
+func ProcessTopicWithLedger(topicID string, archivePath string, outputPath string, ledger *errorledger.Ledger) error {
 	log.Printf("[INFO] Starting processing for Topic ID: %s", topicID)
 
+	topicFiles, derivedSubforumID, err := FindTopicFiles(topicID, archivePath)
+	if err != nil {
+		return err
+	}
+	return processTopicFiles(forumadapter.Default(), topicID, topicFiles, derivedSubforumID, archivePath, outputPath, ledger, nil)
+}
+
+// FindTopicFiles returns the page files (page_N.html) of a topic in ascending page order, and the
+// sub-forum ID derived from the directory holding them.
+func FindTopicFiles(topicID string, archivePath string) ([]string, string, error) {
 	var topicFiles []string
 	var derivedSubforumID string // Renamed from subforumID to avoid conflict if TopicInfo is introduced
 
@@ -61,19 +81,70 @@ func ProcessTopic(topicID string, archivePath string, outputPath string /*, topi
 	})
 
 	if err != nil {
-		return fmt.Errorf("error scanning archive for topic %s: %w", topicID, err)
+		return nil, "", fmt.Errorf("error scanning archive for topic %s: %w", topicID, err)
 	}
 
-	if len(topicFiles) == 0 {
-		return fmt.Errorf("no HTML files found for topic ID %s in %s (derived subforum: %s)", topicID, archivePath, derivedSubforumID)
+	sortPageFiles(topicFiles)
+	return topicFiles, derivedSubforumID, nil
+}
+
+// ArchivedTopic is a topic's page files as found by ScanArchive.
+type ArchivedTopic struct {
+	SubForumID string   // Name of the directory holding the topic directory
+	PageFiles  []string // page_N.html files in ascending page order
+}
+
+// ScanArchive walks archivePath once and returns every topic directory (one holding page_N.html
+// files, named after its topic ID) by topic ID. A run looks its topics up here instead of calling
+// FindTopicFiles, which walks the whole archive, for each one. Directory names must equal the
+// topic ID; when a topic ID is found under two sub-forums, the first in walk order is kept.
+func ScanArchive(archivePath string) (map[string]ArchivedTopic, error) {
+	topics := make(map[string]ArchivedTopic)
+	topicDirs := make(map[string]string) // Topic ID to the directory its files come from
+	err := filepath.WalkDir(archivePath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), "page_") || !strings.HasSuffix(entry.Name(), ".html") {
+			return nil
+		}
+		topicDir := filepath.Dir(path)
+		topicID := filepath.Base(topicDir)
+		if dir, seen := topicDirs[topicID]; seen && dir != topicDir {
+			return nil
+		}
+		topicDirs[topicID] = topicDir
+		topic := topics[topicID]
+		topic.SubForumID = filepath.Base(filepath.Dir(topicDir))
+		topic.PageFiles = append(topic.PageFiles, path)
+		topics[topicID] = topic
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning archive %s: %w", archivePath, err)
 	}
+	for _, topic := range topics {
+		sortPageFiles(topic.PageFiles)
+	}
+	return topics, nil
+}
 
-	// Subtask 1.3: Ensure page files are processed in ascending order of page_number.
-	sort.SliceStable(topicFiles, func(i, j int) bool {
-		pageNumberI := extractPageNumber(filepath.Base(topicFiles[i]))
-		pageNumberJ := extractPageNumber(filepath.Base(topicFiles[j]))
+// sortPageFiles sorts page files in ascending order of page number.
+func sortPageFiles(pageFiles []string) {
+	sort.SliceStable(pageFiles, func(i, j int) bool {
+		pageNumberI := extractPageNumber(filepath.Base(pageFiles[i]))
+		pageNumberJ := extractPageNumber(filepath.Base(pageFiles[j]))
 		return pageNumberI < pageNumberJ
 	})
+}
+
+// processTopicFiles extracts the posts of the topic's page files, as found by FindTopicFiles, with
+// adapter, the markup of the forum the topic was archived from, and saves them as
+// {subforum_id}_{topic_id}.json in outputPath. A non-nil redactor redacts each post before it is saved.
+func processTopicFiles(adapter forumadapter.ForumAdapter, topicID string, topicFiles []string, derivedSubforumID string, archivePath string, outputPath string, ledger *errorledger.Ledger, redactor *redact.Redactor) error {
+	if len(topicFiles) == 0 {
+		return fmt.Errorf("no HTML files found for topic ID %s in %s (derived subforum: %s)", topicID, archivePath, derivedSubforumID)
+	}
 
 	log.Printf("[INFO] Found %d HTML files for topic %s (Subforum: %s). Processing in order.", len(topicFiles), topicID, derivedSubforumID)
 
@@ -86,13 +157,16 @@ func ProcessTopic(topicID string, archivePath string, outputPath string /*, topi
 		page, err := htmlparser.LoadHTMLPage(filePath)
 		if err != nil {
 			log.Printf("[WARNING] Error loading HTML page %s: %v. Skipping page.", filePath, err)
+			recordProblem(ledger, filePath, -1, errorledger.StageLoadPage, errorledger.OutcomeDropped, err, "")
 			continue
 		}
+		page.Adapter = adapter
 
 		// Task 2.1 (part 2): Identify post blocks
 		postBlocks, err := page.GetPostBlocks()
 		if err != nil {
 			log.Printf("[WARNING] Error getting post blocks from %s: %v. Skipping page.", filePath, err)
+			recordProblem(ledger, filePath, -1, errorledger.StagePostBlocks, errorledger.OutcomeDropped, err, "")
 			continue
 		}
 
@@ -104,76 +178,22 @@ func ProcessTopic(topicID string, archivePath string, outputPath string /*, topi
 		log.Printf("[INFO] Found %d post blocks on page %s.", len(postBlocks), filePath)
 
 		for j, postBlock := range postBlocks {
-			// Debugging postBlock.Selection itself
-			log.Printf("[DEBUG ProcessTopic] PostBlock %d: Selection Length: %d, NodeName: %s",
-				j+1, postBlock.Selection.Length(), goquery.NodeName(postBlock.Selection))
-
-			// Get the outer HTML of the <tr> element itself
-			postHTML, outerHTMLErr := goquery.OuterHtml(postBlock.Selection)
-			if outerHTMLErr != nil {
-				log.Printf("[WARNING] Error getting outer HTML for post block %d on page %s: %v", j+1, filePath, outerHTMLErr)
-				continue
-			}
-
-			// Log the actual string obtained from OuterHtml
-			log.Printf("[DEBUG ProcessTopic] OuterHTML string for post %d on page %s:\n%s\n--------------------",
-				j+1, filepath.Base(filePath), postHTML)
-
-			// Create a new goquery document from the outer HTML, ensuring it's wrapped for consistent parsing
-			// Match the wrapping style of extractor_test.go (no explicit tbody)
-			wrappedPostHTML := "<!DOCTYPE html><html><body><table>" + postHTML + "</table></body></html>"
-			postDoc, docErr := goquery.NewDocumentFromReader(strings.NewReader(wrappedPostHTML))
-			if docErr != nil {
-				log.Printf("[WARNING] Error creating wrapped goquery document for post block %d on page %s: %v. Skipping post.",
-					j+1, filePath, docErr)
-				continue
-			}
-			// Task 2.2: Extract post metadata
-			// Note: The original Story 3.2 signature was ExtractPostMetadata(postHTML HTMLBlock, topicContext Context)
-			// The actual function in extractorlogic is ExtractPostMetadata(postHTMLBlock *goquery.Document, filePath string)
-			// We are using the latter. `filePath` is used by extractor to get subforum_id, topic_id, page_number.
-			metadata, extractErr := extractorlogic.ExtractPostMetadata(postDoc, filePath)
-			if extractErr != nil {
-				log.Printf("[WARNING] Error extracting metadata for post %d on page %s: %v. Some metadata might be missing.", j+1, filePath, extractErr)
+			metadata, err := ExtractPost(adapter, postBlock, filePath, j, ledger)
+			if err != nil {
+				log.Printf("[WARNING] Error extracting metadata for post %d on page %s: %v. Post dropped.", j+1, filePath, err)
 				// Instead of returning a fatal error, log and continue to the next post.
 				// The error from extractorlogic.ExtractPostMetadata might contain multiple errors.
-				// We log it and mark this specific post as problematic, but don't stop the whole topic.
+				// ExtractPost has recorded the post and its HTML in the ledger, but we don't stop the whole topic.
 				// If needed, a counter for failed posts per topic could be added and a threshold set
 				// for when to abort a topic entirely. For now, we try to get as much as possible.
 				continue
 			}
 
-			// Task 3.1: Parse Post Content into Structured Blocks
-			// Find the main content cell. The selector for the post content cell is typically td.normal.bgc1.vat.w90
-			// ParseContentBlocks expects the selection of the content container itself.
-			postContentSelection := postDoc.Find("td.normal.bgc1.vat.w90").First() // Assuming this is the direct container of mixed content nodes.
-			if postContentSelection.Length() == 0 {
-				log.Printf("[WARNING] Could not find post content cell (td.normal.bgc1.vat.w90) for post %d on page %s. Skipping content parsing.", j+1, filePath)
-			} else {
-				parsedBlocks, err := parser.ParseContentBlocks(postContentSelection)
-				if err != nil {
-					log.Printf("[WARNING] Error parsing content blocks for post %d on page %s: %v. Content may be incomplete.", j+1, filePath, err)
+			if redactor != nil {
+				// Nothing unredacted is saved: a post that cannot be redacted and audited fails the topic
+				if _, err := redactor.RedactPost(&metadata, filePath); err != nil {
+					return fmt.Errorf("failed to redact post %d on page %s: %w", j+1, filePath, err)
 				}
-
-				// Task 3.2: Clean NewText Blocks
-				for k, block := range parsedBlocks {
-					if block.Type == data.ContentBlockTypeNewText {
-						cleanedText, cleanErr := parser.CleanNewTextBlock(block.Content) // block.Content is raw HTML here
-						if cleanErr != nil {
-							log.Printf("[WARNING] Error cleaning new_text block for post %d, block %d: %v. Using raw content.", j+1, k, cleanErr)
-						} else {
-							parsedBlocks[k].Content = cleanedText
-						}
-					}
-				}
-				metadata.ParsedContent = parsedBlocks
-			}
-
-			// Task 4.2: Construct PostURL
-			if metadata.TopicID != "" && metadata.PostID != "" {
-				metadata.PostURL = fmt.Sprintf("viewtopic.php?t=%s&p=%s#p%s", metadata.TopicID, metadata.PostID, metadata.PostID)
-			} else {
-				log.Printf("[WARNING] Could not construct PostURL for post %d on page %s due to missing TopicID or PostID.", j+1, filePath)
 			}
 
 			// Store the extracted metadata (now including parsed content and PostURL)
@@ -221,6 +241,126 @@ func ProcessTopic(topicID string, archivePath string, outputPath string /*, topi
 	return nil
 }
 
+// ExtractPost runs the extraction pipeline on one post block of the page at filePath: metadata
+// extraction, content block parsing, new_text cleaning and PostURL construction. index is the
+// block's 0-based position on the page. On a metadata error the partially filled metadata is
+// returned with the error and the content is not parsed.
+//
+// Failures that drop the post, and those that leave it with empty or raw fields, are recorded to
+// ledger together with the post's HTML; ledger may be nil.
+func ExtractPost(adapter forumadapter.ForumAdapter, postBlock htmlparser.PostBlock, filePath string, index int, ledger *errorledger.Ledger) (data.PostMetadata, error) {
+	// Get the outer HTML of the <tr> element itself. The HTML of posts that fail is kept in the
+	// error ledger, so it is no longer dumped to the log for every post.
+	postHTML, err := goquery.OuterHtml(postBlock.Selection)
+	if err != nil {
+		err = fmt.Errorf("failed to get outer HTML of post block: %w", err)
+		recordProblem(ledger, filePath, index, errorledger.StageOuterHTML, errorledger.OutcomeDropped, err, "")
+		return data.PostMetadata{}, err
+	}
+
+	// Create a new goquery document from the outer HTML, ensuring it's wrapped for consistent parsing
+	// Match the wrapping style of extractor_test.go (no explicit tbody)
+	wrappedPostHTML := "<!DOCTYPE html><html><body><table>" + postHTML + "</table></body></html>"
+	postDoc, err := goquery.NewDocumentFromReader(strings.NewReader(wrappedPostHTML))
+	if err != nil {
+		err = fmt.Errorf("failed to create wrapped goquery document for post block: %w", err)
+		recordProblem(ledger, filePath, index, errorledger.StageOuterHTML, errorledger.OutcomeDropped, err, postHTML)
+		return data.PostMetadata{}, err
+	}
+
+	// Task 2.2: Extract post metadata
+	// Note: The original Story 3.2 signature was ExtractPostMetadata(postHTML HTMLBlock, topicContext Context)
+	// The actual function in extractorlogic is ExtractPostMetadata(postHTMLBlock *goquery.Document, filePath string)
+	// We are using the latter. `filePath` is used by extractor to get subforum_id, topic_id, page_number.
+	metadata, err := extractorlogic.ExtractPostMetadataWith(adapter, postDoc, filePath)
+	if err != nil {
+		recordPostProblem(ledger, filePath, index, metadata.PostID, errorledger.StageMetadata, errorledger.OutcomeDropped, err, postHTML)
+		return metadata, err
+	}
+
+	// Engines that do not number posts on the page leave the order to the block's position
+	if metadata.PostOrderOnPage < 0 {
+		metadata.PostOrderOnPage = index
+	}
+
+	// Task 3.1: Parse Post Content into Structured Blocks
+	// The adapter finds the content container (td.normal.bgc1.vat.w90 on Magic Cafe);
+	// ParseContentBlocks expects the selection of the content container itself.
+	postContentSelection := adapter.ContentBlock(postDoc.Selection)
+	if postContentSelection.Length() == 0 {
+		log.Printf("[WARNING] Could not find post content for post %d on page %s with the %s adapter. Skipping content parsing.", index+1, filePath, adapter.Name())
+		recordPostProblem(ledger, filePath, index, metadata.PostID, errorledger.StageContentBlock, errorledger.OutcomeDegraded,
+			fmt.Errorf("no content container found with the %s adapter", adapter.Name()), postHTML)
+	} else {
+		parsedBlocks, err := parser.ParseContentBlocksWith(adapter, postContentSelection)
+		if err != nil {
+			log.Printf("[WARNING] Error parsing content blocks for post %d on page %s: %v. Content may be incomplete.", index+1, filePath, err)
+			recordPostProblem(ledger, filePath, index, metadata.PostID, errorledger.StageContentBlocks, errorledger.OutcomeDegraded, err, postHTML)
+		}
+
+		// Task 3.2: Clean NewText Blocks
+		for k, block := range parsedBlocks {
+			if block.Type == data.ContentBlockTypeNewText {
+				cleanedText, cleanErr := parser.CleanNewTextBlock(block.Content) // block.Content is raw HTML here
+				if cleanErr != nil {
+					log.Printf("[WARNING] Error cleaning new_text block for post %d, block %d: %v. Using raw content.", index+1, k, cleanErr)
+					recordPostProblem(ledger, filePath, index, metadata.PostID, errorledger.StageCleanText, errorledger.OutcomeDegraded,
+						fmt.Errorf("content block %d: %w", k, cleanErr), postHTML)
+				} else {
+					parsedBlocks[k].Content = cleanedText
+				}
+			}
+		}
+		metadata.ParsedContent = parsedBlocks
+	}
+
+	// Task 4.2: Construct PostURL
+	// Built by canonurl and formatted by the forum adapter so post links use the same parameter
+	// names, host and page offsets as the crawler.
+	if metadata.TopicID != "" && metadata.PostID != "" {
+		metadata.PostURL = adapter.FormatURL(canonurl.Post(
+			canonurl.SubForumID(metadata.SubForumID),
+			canonurl.TopicID(metadata.TopicID),
+			canonurl.PostID(metadata.PostID),
+			canonurl.StartForPage(metadata.PageNumber, adapter.PostsPerPage()),
+		))
+	} else {
+		log.Printf("[WARNING] Could not construct PostURL for post %d on page %s due to missing TopicID or PostID.", index+1, filePath)
+		recordPostProblem(ledger, filePath, index, metadata.PostID, errorledger.StagePostURL, errorledger.OutcomeDegraded,
+			fmt.Errorf("missing TopicID or PostID"), postHTML)
+	}
+
+	return metadata, nil
+}
+
+// recordProblem records a problem with the post at index on the page at filePath (-1 for the
+// whole page). Failing to write the ledger is logged but does not stop the extraction.
+func recordProblem(ledger *errorledger.Ledger, filePath string, index int, stage string, outcome string, problem error, postHTML string) {
+	recordPostProblem(ledger, filePath, index, "", stage, outcome, problem, postHTML)
+}
+
+// recordPostProblem is recordProblem for a post whose ID is already known.
+func recordPostProblem(ledger *errorledger.Ledger, filePath string, index int, postID string, stage string, outcome string, problem error, postHTML string) {
+	if ledger == nil {
+		return
+	}
+	// Archive pages live at <archive>/<subforum_id>/<topic_id>/page_N.html
+	topicDir := filepath.Dir(filePath)
+	entry := errorledger.Entry{
+		SubForumID: filepath.Base(filepath.Dir(topicDir)),
+		TopicID:    filepath.Base(topicDir),
+		PageFile:   filePath,
+		PostIndex:  index,
+		PostID:     postID,
+		Stage:      stage,
+		Outcome:    outcome,
+		Error:      problem.Error(),
+	}
+	if err := ledger.Record(entry, postHTML); err != nil {
+		log.Printf("[ERROR] Failed to record %s problem for post %d on page %s: %v", stage, index+1, filePath, err)
+	}
+}
+
 // extractPageNumber extracts the page number from a filename like "page_1.html" or "page_123.html".
 // Returns -1 if parsing fails.
 func extractPageNumber(filename string) int {
@@ -318,6 +318,8 @@ func TestProcessTopic(t *testing.T) {
 					assert.Equal(t, "post111_p1", posts[0].PostID)
 					assert.Equal(t, "post222_p1", posts[1].PostID)
 					assert.Equal(t, "post333", posts[2].PostID)
+					// Post URLs use the crawler's parameter names and the offset of the page holding the post
+					assert.Equal(t, fmt.Sprintf("https://www.themagiccafe.com/forums/viewtopic.php?forum=%s&post=post333&start=20&topic=%s", subforumID, topicID), posts[2].PostURL)
 				}
 			},
 		},
@@ -440,3 +442,35 @@ func TestProcessTopic(t *testing.T) {
 func getTestSubforumDir(baseArchivePath string, testName string, subforumNamePart string) string {
 	return filepath.Join(baseArchivePath, testName+"_archive", subforumNamePart)
 }
+
+func TestScanArchive(t *testing.T) {
+	archive := t.TempDir()
+	write := func(parts ...string) string {
+		path := filepath.Join(append([]string{archive}, parts...)...)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("<html></html>"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		return path
+	}
+	page10 := write("66", "100", "page_10.html")
+	page2 := write("66", "100", "page_2.html")
+	page1 := write("66", "100", "page_1.html")
+	write("66", "100", "notes.txt")
+	other := write("66", "1100", "page_1.html") // Its ID ends with the first topic's ID
+	moved := write("23", "200", "page_1.html")
+	write("41", "200", "page_1.html") // Same topic under a later sub-forum is ignored
+
+	topics, err := ScanArchive(archive)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]ArchivedTopic{
+		"100":  {SubForumID: "66", PageFiles: []string{page1, page2, page10}},
+		"1100": {SubForumID: "66", PageFiles: []string{other}},
+		"200":  {SubForumID: "23", PageFiles: []string{moved}},
+	}, topics)
+
+	_, err = ScanArchive(filepath.Join(archive, "missing"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,277 @@
+// Package outputdiff compares two output trees written by orchestrator.ProcessTopic (the
+// OutputJSONPath directories of two extraction runs). Posts are matched by PostID across the
+// whole tree, so posts that moved to another topic file are reported as changed, not as
+// missing and added. Differences are aggregated by field and by sub-forum.
+package outputdiff
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"project-waypoint/pkg/completeness"
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree"
+	"project-waypoint/pkg/postdiff"
+)
+
+// Status of a post in the new tree relative to the old one.
+const (
+	StatusAdded   = "added"
+	StatusMissing = "missing"
+	StatusChanged = "changed"
+)
+
+// PostDiff is a post that was added, is missing or changed between the two trees.
+type PostDiff struct {
+	Status     string            `json:"status"`
+	PostID     string            `json:"post_id"`
+	SubForumID string            `json:"subforum_id"`
+	TopicID    string            `json:"topic_id"`
+	File       string            `json:"file"` // Tree-relative file holding the post (the new file unless missing)
+	Changes    []postdiff.Change `json:"changes,omitempty"`
+}
+
+// Counts are the per-status post counts of a tree comparison or one of its sub-forums.
+type Counts struct {
+	Unchanged int `json:"unchanged"`
+	Changed   int `json:"changed"`
+	Added     int `json:"added"`
+	Missing   int `json:"missing"`
+}
+
+func (c *Counts) add(status string) {
+	switch status {
+	case StatusAdded:
+		c.Added++
+	case StatusMissing:
+		c.Missing++
+	case StatusChanged:
+		c.Changed++
+	default:
+		c.Unchanged++
+	}
+}
+
+// Report is the result of comparing two output trees.
+type Report struct {
+	OldDir     string             `json:"old_dir"`
+	NewDir     string             `json:"new_dir"`
+	OldPosts   int                `json:"old_posts"`
+	NewPosts   int                `json:"new_posts"`
+	Totals     Counts             `json:"totals"`
+	ByField    map[string]int     `json:"by_field"`    // Changed posts per field group, see postdiff.FieldGroup
+	BySubForum map[string]*Counts `json:"by_subforum"` // Keyed by sub-forum ID
+	Posts      []PostDiff         `json:"posts"`       // Sorted by sub-forum, topic and post ID
+	Duplicates []string           `json:"duplicates"`  // Post IDs found more than once in one tree; only the first copy is compared
+}
+
+// HasDifferences reports whether any post was added, is missing or changed.
+func (r *Report) HasDifferences() bool {
+	return r.Totals.Added > 0 || r.Totals.Missing > 0 || r.Totals.Changed > 0
+}
+
+// treePost is a post read from an output tree, with the file it was read from.
+type treePost struct {
+	file string
+	post data.PostMetadata
+}
+
+// Compare compares the output trees under oldDir and newDir. Topic files with the same path are
+// compared first and only the posts left unmatched are kept in memory for matching across
+// files, so trees of hundreds of thousands of posts can be compared.
+func Compare(oldDir string, newDir string) (*Report, error) {
+	report := &Report{
+		OldDir:     oldDir,
+		NewDir:     newDir,
+		ByField:    make(map[string]int),
+		BySubForum: make(map[string]*Counts),
+		Posts:      []PostDiff{},
+		Duplicates: []string{},
+	}
+
+	oldFiles, err := outputFiles(oldDir)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := outputFiles(newDir)
+	if err != nil {
+		return nil, err
+	}
+
+	unmatchedOld := make(map[string]treePost)
+	unmatchedNew := make(map[string]treePost)
+	seenOld := make(map[string]bool)
+	seenNew := make(map[string]bool)
+	duplicates := make(map[string]bool)
+	for _, file := range unionSorted(oldFiles, newFiles) {
+		oldPosts, err := readPosts(oldDir, file, oldFiles[file])
+		if err != nil {
+			return nil, err
+		}
+		newPosts, err := readPosts(newDir, file, newFiles[file])
+		if err != nil {
+			return nil, err
+		}
+		report.OldPosts += len(oldPosts)
+		report.NewPosts += len(newPosts)
+
+		oldByKey := keyPosts(oldPosts, seenOld, duplicates)
+		for _, newPost := range keyPostList(newPosts, seenNew, duplicates) {
+			key := postKey(newPost)
+			oldPost, ok := oldByKey[key]
+			if !ok {
+				unmatchedNew[key] = newPost
+				continue
+			}
+			delete(oldByKey, key)
+			report.record(oldPost, newPost)
+		}
+		for key, oldPost := range oldByKey {
+			unmatchedOld[key] = oldPost
+		}
+	}
+
+	// Posts that moved between files
+	for key, newPost := range unmatchedNew {
+		if oldPost, ok := unmatchedOld[key]; ok {
+			delete(unmatchedOld, key)
+			report.record(oldPost, newPost)
+			continue
+		}
+		report.addDiff(PostDiff{Status: StatusAdded, File: newPost.file}, newPost.post)
+	}
+	for _, oldPost := range unmatchedOld {
+		report.addDiff(PostDiff{Status: StatusMissing, File: oldPost.file}, oldPost.post)
+	}
+
+	for key := range duplicates {
+		report.Duplicates = append(report.Duplicates, key)
+	}
+	sort.Strings(report.Duplicates)
+	sort.Slice(report.Posts, func(i, j int) bool {
+		a, b := report.Posts[i], report.Posts[j]
+		if a.SubForumID != b.SubForumID {
+			return a.SubForumID < b.SubForumID
+		}
+		if a.TopicID != b.TopicID {
+			return completeness.IDLess(a.TopicID, b.TopicID)
+		}
+		return completeness.IDLess(a.PostID, b.PostID)
+	})
+	return report, nil
+}
+
+// record compares the two extractions of a post and counts the result.
+func (r *Report) record(oldPost treePost, newPost treePost) {
+	changes := postdiff.Post(oldPost.post, newPost.post)
+	if len(changes) == 0 {
+		r.Totals.add("")
+		r.subForum(newPost.post.SubForumID).add("")
+		return
+	}
+	groups := make(map[string]bool)
+	for _, change := range changes {
+		groups[postdiff.FieldGroup(change.Field)] = true
+	}
+	for group := range groups {
+		r.ByField[group]++
+	}
+	r.addDiff(PostDiff{Status: StatusChanged, File: newPost.file, Changes: changes}, newPost.post)
+}
+
+func (r *Report) addDiff(diff PostDiff, post data.PostMetadata) {
+	diff.PostID, diff.SubForumID, diff.TopicID = post.PostID, post.SubForumID, post.TopicID
+	r.Posts = append(r.Posts, diff)
+	r.Totals.add(diff.Status)
+	r.subForum(post.SubForumID).add(diff.Status)
+}
+
+func (r *Report) subForum(subForumID string) *Counts {
+	counts, ok := r.BySubForum[subForumID]
+	if !ok {
+		counts = &Counts{}
+		r.BySubForum[subForumID] = counts
+	}
+	return counts
+}
+
+// outputFiles returns the tree-relative paths of the .json files under dir.
+func outputFiles(dir string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	err := outputtree.Walk(dir, func(path string) error {
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[relPath] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan output directory %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// readPosts reads a topic file of the tree under dir, or returns nil if the tree does not have it.
+func readPosts(dir string, file string, exists bool) ([]treePost, error) {
+	if !exists {
+		return nil, nil
+	}
+	posts, err := outputtree.Read(filepath.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+	treePosts := make([]treePost, len(posts))
+	for i, post := range posts {
+		treePosts[i] = treePost{file: filepath.ToSlash(file), post: post}
+	}
+	return treePosts, nil
+}
+
+// keyPosts indexes the posts of one file by key, skipping duplicates (see keyPostList).
+func keyPosts(posts []treePost, seen map[string]bool, duplicates map[string]bool) map[string]treePost {
+	byKey := make(map[string]treePost, len(posts))
+	for _, post := range keyPostList(posts, seen, duplicates) {
+		byKey[postKey(post)] = post
+	}
+	return byKey
+}
+
+// keyPostList returns the posts whose key has not been seen before in the tree. Later copies
+// of a post are recorded in duplicates and left out of the comparison.
+func keyPostList(posts []treePost, seen map[string]bool, duplicates map[string]bool) []treePost {
+	unique := make([]treePost, 0, len(posts))
+	for _, post := range posts {
+		key := postKey(post)
+		if seen[key] {
+			duplicates[key] = true
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, post)
+	}
+	return unique
+}
+
+// postKey is the PostID, or the post's position for posts whose ID could not be extracted.
+func postKey(p treePost) string {
+	if p.post.PostID != "" {
+		return p.post.PostID
+	}
+	return fmt.Sprintf("%s@%d.%d", p.file, p.post.PageNumber, p.post.PostOrderOnPage)
+}
+
+func unionSorted(a map[string]bool, b map[string]bool) []string {
+	var keys []string
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if !a[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
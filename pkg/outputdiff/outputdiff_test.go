@@ -0,0 +1,109 @@
+package outputdiff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree/outputtreetest"
+	"project-waypoint/pkg/postdiff"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func post(subForumID, topicID, postID, author string) data.PostMetadata {
+	return data.PostMetadata{
+		PostID:         postID,
+		TopicID:        topicID,
+		SubForumID:     subForumID,
+		PageNumber:     1,
+		AuthorUsername: author,
+		Timestamp:      "2003-01-10 07:24:00",
+		ParsedContent:  []data.ContentBlock{{Type: data.ContentBlockTypeNewText, Content: "text " + postID}},
+	}
+}
+
+func TestCompare(t *testing.T) {
+	oldDir, newDir := t.TempDir(), t.TempDir()
+
+	changed := post("66", "100", "2", "bob")
+	changed.AuthorUsername = "Bob"
+	changed.ParsedContent[0].Content = "cleaned text 2"
+
+	moved := post("66", "100", "3", "carol")
+	movedNew := moved
+	movedNew.TopicID = "200"
+
+	outputtreetest.WriteTopic(t, oldDir, "66_100.json", []data.PostMetadata{post("66", "100", "1", "alice"), post("66", "100", "2", "bob"), moved})
+	outputtreetest.WriteTopic(t, oldDir, "12_300.json", []data.PostMetadata{post("12", "300", "9", "erin")})
+	outputtreetest.WriteTopic(t, newDir, "66_100.json", []data.PostMetadata{post("66", "100", "1", "alice"), changed, post("66", "100", "4", "dave")})
+	outputtreetest.WriteTopic(t, newDir, "66_200.json", []data.PostMetadata{movedNew})
+
+	report, err := Compare(oldDir, newDir)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, report.OldPosts)
+	assert.Equal(t, 4, report.NewPosts)
+	assert.Equal(t, Counts{Unchanged: 1, Changed: 2, Added: 1, Missing: 1}, report.Totals)
+	assert.True(t, report.HasDifferences())
+	assert.Equal(t, map[string]int{"author_username": 1, "content_blocks[].content": 1, "topic_id": 1}, report.ByField)
+	assert.Equal(t, &Counts{Unchanged: 1, Changed: 2, Added: 1}, report.BySubForum["66"])
+	assert.Equal(t, &Counts{Missing: 1}, report.BySubForum["12"])
+
+	require.Len(t, report.Posts, 4)
+	assert.Equal(t, PostDiff{Status: StatusMissing, PostID: "9", SubForumID: "12", TopicID: "300", File: "12_300.json"}, report.Posts[0])
+	assert.Equal(t, StatusChanged, report.Posts[1].Status)
+	assert.Equal(t, "2", report.Posts[1].PostID)
+	assert.Equal(t, []postdiff.Change{
+		{Field: "author_username", Old: "bob", New: "Bob"},
+		{Field: "content_blocks[0].content", Old: "text 2", New: "cleaned text 2"},
+	}, report.Posts[1].Changes)
+	assert.Equal(t, StatusAdded, report.Posts[2].Status)
+	assert.Equal(t, "4", report.Posts[2].PostID)
+	assert.Equal(t, StatusChanged, report.Posts[3].Status, "a post moved to another topic file is changed, not missing and added")
+	assert.Equal(t, "66_200.json", report.Posts[3].File)
+	assert.Equal(t, []postdiff.Change{{Field: "topic_id", Old: "100", New: "200"}}, report.Posts[3].Changes)
+
+	var text bytes.Buffer
+	require.NoError(t, WriteText(&text, report, 1))
+	assert.Contains(t, text.String(), "Unchanged 1, changed 2, added 1, missing 1")
+	assert.Contains(t, text.String(), "Posts (first 1 of 4):")
+	assert.Contains(t, text.String(), "missing post 9 (sub-forum 12, topic 300, 12_300.json)")
+}
+
+func TestCompare_IdenticalTreesAndDuplicates(t *testing.T) {
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	p := post("66", "100", "1", "alice")
+	outputtreetest.WriteTopic(t, oldDir, "66_100.json", []data.PostMetadata{p, p})
+	outputtreetest.WriteTopic(t, newDir, "66_100.json", []data.PostMetadata{p})
+
+	report, err := Compare(oldDir, newDir)
+	require.NoError(t, err)
+	assert.False(t, report.HasDifferences())
+	assert.Equal(t, Counts{Unchanged: 1}, report.Totals)
+	assert.Equal(t, []string{"1"}, report.Duplicates)
+	assert.Empty(t, report.Posts)
+}
+
+func TestCompare_PostsWithoutID(t *testing.T) {
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	noID := post("66", "100", "", "alice")
+	noID.PostOrderOnPage = 3
+	outputtreetest.WriteTopic(t, oldDir, "66_100.json", []data.PostMetadata{noID})
+	outputtreetest.WriteTopic(t, newDir, "66_100.json", []data.PostMetadata{noID})
+
+	report, err := Compare(oldDir, newDir)
+	require.NoError(t, err)
+	assert.Equal(t, Counts{Unchanged: 1}, report.Totals, "posts without an ID are matched by position")
+}
+
+func TestCompare_InvalidFile(t *testing.T) {
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(newDir, "66_100.json"), []byte("{not json"), 0644))
+
+	_, err := Compare(oldDir, newDir)
+	assert.ErrorContains(t, err, "failed to unmarshal output file")
+}
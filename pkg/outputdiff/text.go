@@ -0,0 +1,83 @@
+package outputdiff
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"project-waypoint/pkg/completeness"
+)
+
+// maxValueLen is the length after which old and new values are cut in the text report.
+const maxValueLen = 120
+
+// WriteText writes a human-readable summary of the report followed by at most maxPosts post
+// differences (all of them if maxPosts is negative).
+func WriteText(w io.Writer, report *Report, maxPosts int) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Old tree:\t%s (%d posts)\n", report.OldDir, report.OldPosts)
+	fmt.Fprintf(tw, "New tree:\t%s (%d posts)\n", report.NewDir, report.NewPosts)
+	t := report.Totals
+	fmt.Fprintf(tw, "Unchanged %d, changed %d, added %d, missing %d\n", t.Unchanged, t.Changed, t.Added, t.Missing)
+	if len(report.Duplicates) > 0 {
+		fmt.Fprintf(tw, "Duplicate post IDs (first copy compared): %d\n", len(report.Duplicates))
+	}
+
+	if len(report.ByField) > 0 {
+		fmt.Fprintf(tw, "\nChanged posts by field:\n")
+		fields := make([]string, 0, len(report.ByField))
+		for field := range report.ByField {
+			fields = append(fields, field)
+		}
+		sort.Slice(fields, func(i, j int) bool {
+			if report.ByField[fields[i]] != report.ByField[fields[j]] {
+				return report.ByField[fields[i]] > report.ByField[fields[j]]
+			}
+			return fields[i] < fields[j]
+		})
+		for _, field := range fields {
+			fmt.Fprintf(tw, "  %s\t%d\n", field, report.ByField[field])
+		}
+	}
+
+	if report.HasDifferences() {
+		fmt.Fprintf(tw, "\nBy sub-forum:\n  sub-forum\tunchanged\tchanged\tadded\tmissing\n")
+		subForums := make([]string, 0, len(report.BySubForum))
+		for subForumID := range report.BySubForum {
+			subForums = append(subForums, subForumID)
+		}
+		sort.Slice(subForums, func(i, j int) bool { return completeness.IDLess(subForums[i], subForums[j]) })
+		for _, subForumID := range subForums {
+			c := report.BySubForum[subForumID]
+			fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\t%d\n", subForumID, c.Unchanged, c.Changed, c.Added, c.Missing)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	posts := report.Posts
+	if maxPosts >= 0 && len(posts) > maxPosts {
+		fmt.Fprintf(w, "\nPosts (first %d of %d):\n", maxPosts, len(posts))
+		posts = posts[:maxPosts]
+	} else if len(posts) > 0 {
+		fmt.Fprintf(w, "\nPosts:\n")
+	}
+	for _, post := range posts {
+		fmt.Fprintf(w, "  %s post %s (sub-forum %s, topic %s, %s)\n", post.Status, post.PostID, post.SubForumID, post.TopicID, post.File)
+		for _, change := range post.Changes {
+			fmt.Fprintf(w, "    %s: %q -> %q\n", change.Field, shorten(change.Old), shorten(change.New))
+		}
+	}
+	return nil
+}
+
+func shorten(value string) string {
+	runes := []rune(value)
+	if len(runes) <= maxValueLen {
+		return value
+	}
+	return string(runes[:maxValueLen]) + "..."
+}
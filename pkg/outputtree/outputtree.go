@@ -0,0 +1,98 @@
+// Package outputtree reads the output tree written by orchestrator.ProcessTopic: one JSON array
+// of posts per topic, {subforum_id}_{topic_id}.json, possibly in sub-directories. Posts are
+// decoded one at a time, so a file is never held in memory twice.
+package outputtree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"project-waypoint/pkg/data"
+)
+
+// Walk calls fn with the path of each output file under dir, in lexical order.
+func Walk(dir string, fn func(path string) error) error {
+	return filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		return fn(path)
+	})
+}
+
+// WalkPosts calls fn with the path and posts of each output file under dir, in lexical order.
+func WalkPosts(dir string, fn func(path string, posts []data.PostMetadata) error) error {
+	return Walk(dir, func(path string) error {
+		posts, err := Read(path)
+		if err != nil {
+			return err
+		}
+		return fn(path, posts)
+	})
+}
+
+// Read reads the posts of an output file. An empty file or null holds no posts.
+func Read(path string) ([]data.PostMetadata, error) {
+	var posts []data.PostMetadata
+	err := Stream(path, func(post *data.PostMetadata) error {
+		posts = append(posts, *post)
+		return nil
+	})
+	return posts, err
+}
+
+// ReadBytes is Read for the content of an output file already in memory; path names it in errors.
+func ReadBytes(path string, content []byte) ([]data.PostMetadata, error) {
+	var posts []data.PostMetadata
+	err := Decode(path, bytes.NewReader(content), func(post *data.PostMetadata) error {
+		posts = append(posts, *post)
+		return nil
+	})
+	return posts, err
+}
+
+// Stream calls fn with each post of an output file in turn, decoding one post at a time.
+func Stream(path string, fn func(post *data.PostMetadata) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open output file %s: %w", path, err)
+	}
+	defer file.Close()
+	return Decode(path, bufio.NewReader(file), fn)
+}
+
+// Decode is Stream for an output file read from r; path names it in errors.
+func Decode(path string, r io.Reader, fn func(post *data.PostMetadata) error) error {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err == io.EOF || (err == nil && token == nil) {
+		return nil // Empty file or null
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal output file %s: %w", path, err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("failed to unmarshal output file %s: it does not hold a JSON array of posts", path)
+	}
+	for decoder.More() {
+		var post data.PostMetadata
+		if err := decoder.Decode(&post); err != nil {
+			return fmt.Errorf("failed to unmarshal post in output file %s: %w", path, err)
+		}
+		if err := fn(&post); err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("failed to unmarshal output file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package outputtree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree/outputtreetest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "66_100.json")
+	outputtreetest.WriteFile(t, path, `[
+  {"post_id": "1001", "topic_id": "100", "subforum_id": "66", "author_username": "alice"},
+  {"post_id": "1002", "topic_id": "100", "subforum_id": "66", "author_username": "bob"}
+]`)
+	posts, err := Read(path)
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	assert.Equal(t, "1001", posts[0].PostID)
+	assert.Equal(t, "bob", posts[1].AuthorUsername)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	fromBytes, err := ReadBytes(path, content)
+	require.NoError(t, err)
+	assert.Equal(t, posts, fromBytes)
+
+	for name, content := range map[string]string{"empty.json": "", "null.json": "null", "none.json": "[]"} {
+		outputtreetest.WriteFile(t, filepath.Join(dir, name), content)
+		posts, err := Read(filepath.Join(dir, name))
+		require.NoError(t, err, name)
+		assert.Empty(t, posts, name)
+	}
+
+	for name, content := range map[string]string{"object.json": `{"post_id": "1"}`, "truncated.json": `[{"post_id": "1"}`, "bad.json": `[{"post_id": 1}]`} {
+		outputtreetest.WriteFile(t, filepath.Join(dir, name), content)
+		_, err := Read(filepath.Join(dir, name))
+		assert.ErrorContains(t, err, name)
+	}
+
+	_, err = Read(filepath.Join(dir, "missing.json"))
+	assert.ErrorContains(t, err, "failed to open output file")
+}
+
+func TestStream_StopsAtError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "66_100.json")
+	outputtreetest.WriteFile(t, path, `[{"post_id": "1"}, {"post_id": "2"}, {"post_id": "3"}]`)
+	var seen []string
+	err := Stream(path, func(post *data.PostMetadata) error {
+		seen = append(seen, post.PostID)
+		if post.PostID == "2" {
+			return os.ErrInvalid
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, os.ErrInvalid)
+	assert.Equal(t, []string{"1", "2"}, seen)
+}
+
+func TestWalkPosts(t *testing.T) {
+	dir := t.TempDir()
+	outputtreetest.WriteFile(t, filepath.Join(dir, "66_101.json"), `[{"post_id": "2"}]`)
+	outputtreetest.WriteFile(t, filepath.Join(dir, "66_100.json"), `[{"post_id": "1"}]`)
+	outputtreetest.WriteFile(t, filepath.Join(dir, "moved", "54_200.json"), `[{"post_id": "3"}]`)
+	outputtreetest.WriteFile(t, filepath.Join(dir, "notes.txt"), "not output")
+
+	var files []string
+	var ids []string
+	err := WalkPosts(dir, func(path string, posts []data.PostMetadata) error {
+		relPath, err := filepath.Rel(dir, path)
+		require.NoError(t, err)
+		files = append(files, filepath.ToSlash(relPath))
+		for _, post := range posts {
+			ids = append(ids, post.PostID)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"66_100.json", "66_101.json", "moved/54_200.json"}, files)
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+
+	outputtreetest.WriteFile(t, filepath.Join(dir, "66_102.json"), "{")
+	err = WalkPosts(dir, func(string, []data.PostMetadata) error { return nil })
+	assert.ErrorContains(t, err, "66_102.json")
+}
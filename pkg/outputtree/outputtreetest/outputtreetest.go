@@ -0,0 +1,30 @@
+// Package outputtreetest writes output trees, as orchestrator.ProcessTopic does, for the tests of
+// the packages that read them.
+package outputtreetest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"project-waypoint/pkg/data"
+
+	"github.com/stretchr/testify/require"
+)
+
+// WriteTopic writes posts to the output file name under dir, such as "66_100.json".
+func WriteTopic(t testing.TB, dir string, name string, posts []data.PostMetadata) {
+	t.Helper()
+	content, err := json.MarshalIndent(posts, "", "  ")
+	require.NoError(t, err)
+	WriteFile(t, filepath.Join(dir, name), string(content))
+}
+
+// WriteFile writes content to path, creating its directory, for the other files a test reads
+// next to the output tree: topic indexes, sub-forum lists and archived pages.
+func WriteFile(t testing.TB, path string, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
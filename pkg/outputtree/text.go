@@ -0,0 +1,81 @@
+package outputtree
+
+import (
+	"html"
+	"strings"
+)
+
+// lineBreakTags end a line of text in extracted content.
+var lineBreakTags = map[string]bool{
+	"br": true, "p": true, "div": true, "li": true, "tr": true, "blockquote": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// PlainText turns extracted content, which can still carry markup from the forum page, into plain
+// text. Tags that break lines become line breaks, other tags spaces; entities are unescaped, runs
+// of spaces collapsed and blank lines dropped.
+func PlainText(content string) string {
+	return strings.Join(textLines(content, false), "\n")
+}
+
+// Paragraphs is PlainText keeping one blank line wherever the content had blank lines, such as
+// between paragraphs.
+func Paragraphs(content string) string {
+	return strings.Join(textLines(content, true), "\n")
+}
+
+// OneLine is PlainText with its lines joined by spaces, for text that is searched rather than read.
+func OneLine(content string) string {
+	return strings.Join(textLines(content, false), " ")
+}
+
+// textLines returns the lines of text in content, keeping one empty line for each run of blank
+// lines between them if keepBlank is set.
+func textLines(content string, keepBlank bool) []string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(content, '<')
+		end := -1
+		if start >= 0 {
+			end = strings.IndexByte(content[start:], '>')
+		}
+		if end < 0 {
+			b.WriteString(content)
+			break
+		}
+		b.WriteString(content[:start])
+		tag := strings.ToLower(strings.TrimLeft(content[start+1:start+end], "/"))
+		if name, _, _ := strings.Cut(tag, " "); lineBreakTags[strings.TrimRight(name, "/")] {
+			b.WriteByte('\n')
+		} else {
+			b.WriteByte(' ')
+		}
+		content = content[start+end+1:]
+	}
+
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(html.UnescapeString(b.String()), "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			blank = keepBlank && len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// DateOf returns the date part of a post's "YYYY-MM-DD HH:MM:SS" timestamp, or missing if the
+// timestamp is empty.
+func DateOf(timestamp string, missing string) string {
+	if timestamp == "" {
+		return missing
+	}
+	date, _, _ := strings.Cut(timestamp, " ")
+	return date
+}
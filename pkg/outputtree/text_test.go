@@ -0,0 +1,29 @@
+package outputtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlainText(t *testing.T) {
+	assert.Equal(t, "One\ntwo\nThree & four", PlainText("<p>One<br/>two</p>\n\n<P>Three &amp; <i>four</i></p>"))
+	assert.Equal(t, "Fish &amp; chips\nnow", PlainText(`<div class="x">Fish &amp;amp; <b>chips</b></div>now`))
+	assert.Equal(t, "a < b", PlainText("a < b"))
+	assert.Equal(t, "", PlainText("<br><br>"))
+}
+
+func TestParagraphs(t *testing.T) {
+	assert.Equal(t, "One\ntwo\n\nThree & four", Paragraphs("<p>One<br>two</p>\n\n<p>Three &amp; <i>four</i></p>"))
+	assert.Equal(t, "a < b", Paragraphs("\n\na < b\n\n"))
+}
+
+func TestOneLine(t *testing.T) {
+	assert.Equal(t, "Fish &amp; chips now", OneLine(`<div class="x">Fish &amp;amp; <b>chips</b></div>now`))
+	assert.Equal(t, "One two", OneLine("<p>One</p>\n<p>two</p>"))
+}
+
+func TestDateOf(t *testing.T) {
+	assert.Equal(t, "2003-01-10", DateOf("2003-01-10 09:05:00", "-"))
+	assert.Equal(t, "-", DateOf("", "-"))
+}
@@ -10,6 +10,8 @@ import (
 	// "golang.org/x/net/html" // No longer needed as extractText is commented out
 
 	"github.com/PuerkitoBio/goquery"
+
+	"waypoint_archive_scripts/pkg/forumadapter"
 )
 
 var (
@@ -21,8 +23,14 @@ var (
 
 // ParseContentBlocks takes a goquery selection representing the direct children
 // of a post's content area and parses it into an ordered list of ContentBlock structs.
-// It identifies sequences of the author's new_text and distinct quote blocks.
+// It identifies sequences of the author's new_text and distinct quote blocks, using the
+// process-wide forum adapter to recognise quotes.
 func ParseContentBlocks(contentNodes *goquery.Selection) ([]data.ContentBlock, error) {
+	return ParseContentBlocksWith(forumadapter.Default(), contentNodes)
+}
+
+// ParseContentBlocksWith is ParseContentBlocks for the markup of a given forum adapter.
+func ParseContentBlocksWith(adapter forumadapter.ForumAdapter, contentNodes *goquery.Selection) ([]data.ContentBlock, error) {
 	var blocks []data.ContentBlock
 	var currentNewText string
 
@@ -37,8 +45,8 @@ func ParseContentBlocks(contentNodes *goquery.Selection) ([]data.ContentBlock, e
 	}
 
 	contentNodes.Contents().Each(func(i int, s *goquery.Selection) {
-		// Check if the node is a quote table
-		if s.Is("table.cfq") {
+		// Check if the node is a quote (a table.cfq on Magic Cafe)
+		if adapter.IsQuote(s) {
 			// Flush any pending new_text
 			trimmedNewText := strings.TrimSpace(currentNewText)
 			if len(trimmedNewText) > 0 {
@@ -46,7 +54,7 @@ func ParseContentBlocks(contentNodes *goquery.Selection) ([]data.ContentBlock, e
 			}
 			currentNewText = ""
 
-			quotedUser, quotedTimestamp, quotedText, err := ExtractQuoteDetails(s)
+			quote, err := adapter.QuoteDetails(s)
 			if err != nil {
 				log.Printf("Error extracting quote details: %v. Post ID or other identifier would be useful here.", err)
 				// AC10: Log error and continue. Add a block indicating error.
@@ -54,9 +62,9 @@ func ParseContentBlocks(contentNodes *goquery.Selection) ([]data.ContentBlock, e
 			} else {
 				blocks = append(blocks, data.ContentBlock{
 					Type:            data.ContentBlockTypeQuote,
-					QuotedUser:      quotedUser,
-					QuotedTimestamp: quotedTimestamp,
-					QuotedText:      quotedText,
+					QuotedUser:      quote.User,
+					QuotedTimestamp: quote.Timestamp,
+					QuotedText:      quote.Text,
 				})
 			}
 		} else {
@@ -80,101 +88,14 @@ func ParseContentBlocks(contentNodes *goquery.Selection) ([]data.ContentBlock, e
 	return blocks, nil
 }
 
-// ExtractQuoteDetails parses a quote HTML element (expected to be a table.cfq)
-// and extracts the quoted user, timestamp (if available), and the quote text.
+// ExtractQuoteDetails parses a quote HTML element (a table.cfq on Magic Cafe) with the
+// process-wide forum adapter and extracts the quoted user, timestamp (if available), and the quote text.
 func ExtractQuoteDetails(quoteElement *goquery.Selection) (quotedUser string, quotedTimestamp string, quotedText string, err error) {
-	// Selector for the attribution cell (contains user and timestamp)
-	// Typically the first <td> within the table.cfq that has a <b> tag for the username.
-	attributionCell := quoteElement.Find("td:has(b)").First()
-	if attributionCell.Length() == 0 {
-		// Fallback or log error: No clear attribution cell found
-		// This could happen if the quote structure is different than expected.
-		// Per AC10, errors should be logged by the caller.
-		return "", "", "", fmt.Errorf("could not find attribution cell in quote element")
-	}
-
-	// Extract Quoted User (Subtask 3.2 & 3.5)
-	userSelection := attributionCell.Find("b").First()
-	rawUserText := strings.TrimSpace(userSelection.Text())
-
-	// Handle variations like "Username wrote:" or "Quote: Username"
-	if strings.HasSuffix(rawUserText, " wrote:") {
-		quotedUser = strings.TrimSuffix(rawUserText, " wrote:")
-	} else if strings.HasPrefix(rawUserText, "Quote: ") {
-		quotedUser = strings.TrimPrefix(rawUserText, "Quote: ")
-	} else {
-		quotedUser = rawUserText // Assume it's just the username
-	}
-	quotedUser = strings.TrimSpace(quotedUser)
-
-	// Extract Quoted Timestamp (Subtask 3.3 & 3.5)
-	// Timestamp is often in the same cell, sometimes after a <br> or as part of the text.
-	// We'll take the full text of the attribution cell and try to parse out the timestamp.
-	plainAttributionText := strings.TrimSpace(attributionCell.Text())
-
-	// Regex to find a pattern like "On Jan 23, 2003, 07:22 AM" or just the date/time
-	// This regex is a starting point and might need refinement for various forum date formats.
-	// Example formats: "Jan 23, 2003, 07:22 AM", "Today at 02:10 PM", "Yesterday at 02:10 PM"
-	// AC5: "parse into a consistent format (quoted_timestamp). If not found, this field should be null/empty."
-	// AC7: "handle variations in quote attribution formats"
-
-	// More complex regex might be needed. For now, a simple placeholder for structure.
-	// This part is highly dependent on the actual timestamp formats encountered.
-	// Example: trying to find something like Month Day, Year, HH:MM AM/PM
-	reTimestamp := regexp.MustCompile(`(?i)(Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)\s+\d{1,2},\s+\d{4},\s+\d{1,2}:\d{2}\s+(AM|PM)`)
-	match := reTimestamp.FindStringSubmatch(plainAttributionText)
-	if len(match) > 0 {
-		rawTimestamp := match[0]
-		// TODO: Parse rawTimestamp into "YYYY-MM-DD HH:MM:SS" format.
-		// This requires time.Parse with the correct layout string.
-		// Handling "Today", "Yesterday" would require a reference date (e.g., post's main timestamp or processing date).
-		// For now, we'll store the extracted raw timestamp if found.
-		quotedTimestamp = rawTimestamp // Placeholder - actual parsing needed
-	} else {
-		quotedTimestamp = "" // Or null, as per AC5
+	quote, err := forumadapter.Default().QuoteDetails(quoteElement)
+	if err != nil {
+		return "", "", "", err
 	}
-
-	// Extract Quoted Text (Subtask 3.4)
-	// The quoted text is usually in the next <td> sibling to the attributionCell\'s parent <tr>, or a td not being the attribution cell.
-	// Simpler: find all <td>s in the quoteElement, the one that is not attributionCell is the text cell.
-	quoteElement.Find("td").Each(func(i int, td *goquery.Selection) {
-		if len(td.Nodes) > 0 && len(attributionCell.Nodes) > 0 &&
-			td.Nodes[0] != attributionCell.Nodes[0] &&
-			td.Parent().Nodes[0] == attributionCell.Parent().Nodes[0] {
-
-			if !strings.Contains(td.Text(), quotedUser) {
-				html, err := td.Html()
-				if err == nil {
-					quotedText = strings.TrimSpace(html)
-				}
-			}
-		}
-	})
-	if quotedText == "" {
-		quoteElement.Find("td").FilterFunction(func(i int, s *goquery.Selection) bool {
-			return len(s.Nodes) > 0 && len(attributionCell.Nodes) > 0 && s.Nodes[0] != attributionCell.Nodes[0]
-		}).EachWithBreak(func(i int, td *goquery.Selection) bool {
-			if td.ParentsFiltered("table.cfq").Length() == 1 {
-				html, err := td.Html()
-				if err == nil {
-					quotedText = strings.TrimSpace(html)
-					return false // Break the loop
-				}
-			}
-			return true
-		})
-	}
-
-	// If quotedUser is empty and we have text, it might be a simple blockquote without clear attribution
-	if quotedUser == "" && quotedText == "" && attributionCell.Length() == 0 {
-		// Could be a simple <blockquote> or similar not matching table.cfq structure
-		// For now, if we reached here with `quoteElement` being `table.cfq`, this case is less likely.
-		// If quoteElement could be other things, this might be a plain quote.
-		text, _ := quoteElement.Html()
-		return "", "", strings.TrimSpace(text), nil // Treat whole content as text if no user
-	}
-
-	return strings.TrimSpace(quotedUser), strings.TrimSpace(quotedTimestamp), quotedText, nil
+	return quote.User, quote.Timestamp, quote.Text, nil
 }
 
 // processBBCodes removes common BBCode tags from a string and logs actions.
@@ -0,0 +1,89 @@
+// Package postdiff compares two extractions of the same post field by field. It is shared by
+// the golden-corpus regression runner and the output tree diff so both name fields the same way.
+package postdiff
+
+import (
+	"fmt"
+	"strconv"
+
+	"project-waypoint/pkg/data"
+)
+
+// Missing stands in for a post or content block that exists in only one extraction.
+const Missing = "(missing)"
+
+// Change is one field whose value differs between the old and the new extraction of a post.
+// Fields are named after their JSON keys, e.g. "author_username" or "content_blocks[1].quoted_user".
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Post returns the fields that differ between two extractions of a post. Content blocks are
+// matched by position; a block present on one side only is reported once as "content_blocks[i]".
+func Post(oldPost data.PostMetadata, newPost data.PostMetadata) []Change {
+	var changes []Change
+	add := func(field string, oldValue string, newValue string) {
+		if oldValue != newValue {
+			changes = append(changes, Change{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+
+	add("post_id", oldPost.PostID, newPost.PostID)
+	add("topic_id", oldPost.TopicID, newPost.TopicID)
+	add("subforum_id", oldPost.SubForumID, newPost.SubForumID)
+	add("page_number", strconv.Itoa(oldPost.PageNumber), strconv.Itoa(newPost.PageNumber))
+	add("post_order_on_page", strconv.Itoa(oldPost.PostOrderOnPage), strconv.Itoa(newPost.PostOrderOnPage))
+	add("post_url", oldPost.PostURL, newPost.PostURL)
+	add("author_username", oldPost.AuthorUsername, newPost.AuthorUsername)
+	add("timestamp", oldPost.Timestamp, newPost.Timestamp)
+	add("content_block_count", strconv.Itoa(len(oldPost.ParsedContent)), strconv.Itoa(len(newPost.ParsedContent)))
+
+	for i := 0; i < len(oldPost.ParsedContent) || i < len(newPost.ParsedContent); i++ {
+		prefix := fmt.Sprintf("content_blocks[%d]", i)
+		switch {
+		case i >= len(newPost.ParsedContent):
+			add(prefix, BlockSummary(oldPost.ParsedContent[i]), Missing)
+			continue
+		case i >= len(oldPost.ParsedContent):
+			add(prefix, Missing, BlockSummary(newPost.ParsedContent[i]))
+			continue
+		}
+		o, n := oldPost.ParsedContent[i], newPost.ParsedContent[i]
+		add(prefix+".type", string(o.Type), string(n.Type))
+		add(prefix+".content", o.Content, n.Content)
+		add(prefix+".quoted_user", o.QuotedUser, n.QuotedUser)
+		add(prefix+".quoted_timestamp", o.QuotedTimestamp, n.QuotedTimestamp)
+		add(prefix+".quoted_text", o.QuotedText, n.QuotedText)
+	}
+	return changes
+}
+
+// Summary describes a post in one line, for posts present in only one extraction.
+func Summary(p data.PostMetadata) string {
+	return fmt.Sprintf("post %s by %s at %s", p.PostID, p.AuthorUsername, p.Timestamp)
+}
+
+// BlockSummary describes a content block in one line.
+func BlockSummary(b data.ContentBlock) string {
+	if b.Type == data.ContentBlockTypeQuote {
+		return fmt.Sprintf("quote of %s", b.QuotedUser)
+	}
+	return fmt.Sprintf("%s: %s", b.Type, b.Content)
+}
+
+// FieldGroup returns the field a change is aggregated under: content block fields are grouped
+// across positions, e.g. "content_blocks[3].quoted_user" becomes "content_blocks[].quoted_user".
+func FieldGroup(field string) string {
+	const prefix = "content_blocks["
+	if len(field) <= len(prefix) || field[:len(prefix)] != prefix {
+		return field
+	}
+	for i := len(prefix); i < len(field); i++ {
+		if field[i] == ']' {
+			return prefix + field[i:]
+		}
+	}
+	return field
+}
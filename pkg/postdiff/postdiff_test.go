@@ -0,0 +1,44 @@
+package postdiff
+
+import (
+	"testing"
+
+	"project-waypoint/pkg/data"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPost(t *testing.T) {
+	old := data.PostMetadata{
+		PostID:         "1",
+		AuthorUsername: "bob",
+		ParsedContent: []data.ContentBlock{
+			{Type: data.ContentBlockTypeQuote, QuotedUser: "alice", QuotedText: "hi"},
+			{Type: data.ContentBlockTypeNewText, Content: "reply"},
+		},
+	}
+	updated := old
+	updated.Timestamp = "2003-01-10 07:24:00"
+	updated.ParsedContent = []data.ContentBlock{{Type: data.ContentBlockTypeQuote, QuotedUser: "Quote: alice", QuotedText: "hi"}}
+
+	assert.Equal(t, []Change{
+		{Field: "timestamp", Old: "", New: "2003-01-10 07:24:00"},
+		{Field: "content_block_count", Old: "2", New: "1"},
+		{Field: "content_blocks[0].quoted_user", Old: "alice", New: "Quote: alice"},
+		{Field: "content_blocks[1]", Old: "new_text: reply", New: Missing},
+	}, Post(old, updated))
+	assert.Empty(t, Post(old, old))
+}
+
+func TestFieldGroup(t *testing.T) {
+	tests := map[string]string{
+		"author_username":                "author_username",
+		"content_blocks[12].quoted_user": "content_blocks[].quoted_user",
+		"content_blocks[3]":              "content_blocks[]",
+		"content_block_count":            "content_block_count",
+		"content_blocks[unterminated":    "content_blocks[unterminated",
+	}
+	for field, want := range tests {
+		assert.Equal(t, want, FieldGroup(field), field)
+	}
+}
@@ -0,0 +1,91 @@
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditEntry records one redacted value. Field is the JSON path of the redacted field in the
+// post, e.g. "content_blocks[2].quoted_text"; Start and End are byte offsets of the value in the
+// field's text before redaction.
+type AuditEntry struct {
+	RecordedAt  time.Time `json:"recorded_at"`
+	Source      string    `json:"source,omitempty"`
+	SubForumID  string    `json:"subforum_id,omitempty"`
+	TopicID     string    `json:"topic_id"`
+	PostID      string    `json:"post_id,omitempty"`
+	Field       string    `json:"field"`
+	Kind        string    `json:"kind"`
+	Placeholder string    `json:"placeholder"`
+	Start       int       `json:"start"`
+	End         int       `json:"end"`
+	Value       string    `json:"value,omitempty"` // Only with Config.AuditValues
+}
+
+// AuditLog appends entries to a JSON Lines file. A nil *AuditLog is valid and records nothing.
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	now  func() time.Time
+}
+
+// OpenAuditLog opens the audit log at path for appending, creating it and its directory if
+// needed. Entries from earlier runs are kept.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for redaction audit log %s: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open redaction audit log %s: %w", path, err)
+	}
+	log.Printf("[INFO] REDACT: Recording redactions to %s", path)
+	return &AuditLog{path: path, file: file, now: time.Now}, nil
+}
+
+// Path returns the file the audit log appends to.
+func (a *AuditLog) Path() string {
+	if a == nil {
+		return ""
+	}
+	return a.path
+}
+
+// Record appends entry to the audit log. A zero RecordedAt is set to now.
+func (a *AuditLog) Record(entry AuditEntry) error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if entry.RecordedAt.IsZero() {
+		entry.RecordedAt = a.now().UTC()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal redaction audit entry: %w", err)
+	}
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append to redaction audit log %s: %w", a.path, err)
+	}
+	return nil
+}
+
+// Close closes the audit log file.
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.file.Close(); err != nil {
+		return fmt.Errorf("failed to close redaction audit log %s: %w", a.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Built-in detector names.
+const (
+	DetectorEmail         = "email"
+	DetectorPhone         = "phone"
+	DetectorPostalAddress = "postal_address"
+)
+
+// Match is a span of text a detector found, as byte offsets.
+type Match struct {
+	Start int
+	End   int
+}
+
+// Detector finds one kind of personal information in text.
+type Detector interface {
+	// Name names the kind of information; it is used in placeholders and the audit log.
+	Name() string
+	// Find returns the spans of text to redact, in order and not overlapping.
+	Find(text string) []Match
+	// Normalise maps a found value to the form that gets the same placeholder wherever it
+	// appears, e.g. an email address in lower case.
+	Normalise(value string) string
+}
+
+// RegexDetector finds the matches of one or more regular expressions, optionally checked by
+// Accept.
+type RegexDetector struct {
+	Kind     string
+	Patterns []*regexp.Regexp
+	// Accept, if set, decides whether a match at text[start:end] is kept.
+	Accept func(text string, start int, end int) bool
+	// Normaliser, if set, normalises values; the default lower-cases them and collapses spaces.
+	Normaliser func(value string) string
+}
+
+// Name implements Detector.
+func (d *RegexDetector) Name() string { return d.Kind }
+
+// Find implements Detector.
+func (d *RegexDetector) Find(text string) []Match {
+	var matches []Match
+	for _, pattern := range d.Patterns {
+		for _, span := range pattern.FindAllStringIndex(text, -1) {
+			if d.Accept == nil || d.Accept(text, span[0], span[1]) {
+				matches = append(matches, Match{Start: span[0], End: span[1]})
+			}
+		}
+	}
+	return withoutOverlaps(matches)
+}
+
+// Normalise implements Detector.
+func (d *RegexDetector) Normalise(value string) string {
+	if d.Normaliser != nil {
+		return d.Normaliser(value)
+	}
+	return strings.ToLower(strings.Join(strings.Fields(value), " "))
+}
+
+// NewPatternDetector creates a RegexDetector named kind from a regular expression, for detectors
+// configured by pattern.
+func NewPatternDetector(kind string, pattern string) (*RegexDetector, error) {
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern for detector %s: %w", kind, err)
+	}
+	return &RegexDetector{Kind: kind, Patterns: []*regexp.Regexp{compiled}}, nil
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	// Optional country code, optional area code, then two or three groups of digits
+	phonePattern = regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{1,4}\)[ .-]?|\d{2,4}[ .-])?\d{3,4}[ .-]\d{3,4}(?:[ .-]\d{2,4})?`)
+	// A house number, up to four capitalised words and a street type, with an optional
+	// direction, unit, town, and state and ZIP code
+	streetPattern = regexp.MustCompile(`\b\d{1,5}[A-Za-z]?\s+(?:[A-Z][A-Za-z]*\.?\s+){1,4}(?:Street|St|Avenue|Ave|Road|Rd|Boulevard|Blvd|Lane|Ln|Drive|Dr|Court|Ct|Place|Pl|Square|Sq|Way|Terrace|Parkway|Pkwy|Circle|Cir|Highway|Hwy)\b\.?` +
+		`(?:\s+(?:N|S|E|W|NE|NW|SE|SW)\b)?` +
+		`(?:,?\s+(?:Apt|Apartment|Suite|Ste|Unit|#)\.?\s*[A-Za-z0-9-]+)?` +
+		`(?:,\s*[A-Z][a-z]+(?:\s[A-Z][a-z]+){0,2})?` +
+		`(?:,?\s+[A-Z]{2}\s+\d{5}(?:-\d{4})?\b)?`)
+	poBoxPattern    = regexp.MustCompile(`(?i)\bP\.?\s?O\.?\s+Box\s+\d+\b`)
+	postcodePattern = regexp.MustCompile(`\b[A-Z]{1,2}\d[A-Z\d]?\s+\d[A-Z]{2}\b`) // UK postcodes
+)
+
+// EmailDetector finds email addresses.
+func EmailDetector() Detector {
+	return &RegexDetector{Kind: DetectorEmail, Patterns: []*regexp.Regexp{emailPattern}}
+}
+
+// PhoneDetector finds phone numbers of 7 to 15 digits. To leave other numbers alone, a number
+// must start with a country code or an area code in parentheses, or have three groups of
+// digits, or be two groups joined by a hyphen or dot (555-1234). Two four-digit groups that
+// both look like years (1975-1982) are a range of years, not a number.
+func PhoneDetector() Detector {
+	return &RegexDetector{
+		Kind:     DetectorPhone,
+		Patterns: []*regexp.Regexp{phonePattern},
+		Accept: func(text string, start int, end int) bool {
+			if !standsAlone(text, start, end) {
+				return false
+			}
+			value := text[start:end]
+			digits := len(digitsOf(value))
+			if digits < 7 || digits > 15 {
+				return false
+			}
+			groups := strings.FieldsFunc(value, func(r rune) bool { return !unicode.IsDigit(r) })
+			if len(groups) == 2 && looksLikeYear(groups[0]) && looksLikeYear(groups[1]) {
+				return false
+			}
+			return strings.HasPrefix(value, "+") || strings.HasPrefix(value, "(") || len(groups) >= 3 || strings.ContainsAny(value, "-.")
+		},
+		Normaliser: digitsOf,
+	}
+}
+
+// PostalAddressDetector finds street addresses (house number, street name and type, and what
+// follows of unit, town, state and ZIP code), PO boxes and UK postcodes.
+func PostalAddressDetector() Detector {
+	return &RegexDetector{Kind: DetectorPostalAddress, Patterns: []*regexp.Regexp{streetPattern, poBoxPattern, postcodePattern}}
+}
+
+// BuiltinDetectors returns the built-in detectors by name.
+func BuiltinDetectors() map[string]func() Detector {
+	return map[string]func() Detector{
+		DetectorEmail:         EmailDetector,
+		DetectorPhone:         PhoneDetector,
+		DetectorPostalAddress: PostalAddressDetector,
+	}
+}
+
+// looksLikeYear reports whether a group of digits could be a year from 1800 to 2099.
+func looksLikeYear(group string) bool {
+	return len(group) == 4 && (strings.HasPrefix(group, "18") || strings.HasPrefix(group, "19") || strings.HasPrefix(group, "20"))
+}
+
+// standsAlone reports whether text[start:end] is not part of a longer word or number.
+func standsAlone(text string, start int, end int) bool {
+	isWord := func(b byte) bool {
+		return b == '_' || (b >= '0' && b <= '9') || (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')
+	}
+	return (start == 0 || !isWord(text[start-1])) && (end == len(text) || !isWord(text[end]))
+}
+
+func digitsOf(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, value)
+}
+
+// withoutOverlaps sorts matches and drops each one that overlaps an earlier one; of matches
+// starting at the same place the longest is kept.
+func withoutOverlaps(matches []Match) []Match {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End > matches[j].End
+	})
+	var kept []Match
+	for _, match := range matches {
+		if len(kept) > 0 && match.Start < kept[len(kept)-1].End {
+			continue
+		}
+		kept = append(kept, match)
+	}
+	return kept
+}
@@ -0,0 +1,329 @@
+// Package redact removes personal information from extracted posts before derived datasets are
+// shared. Detectors find email addresses, phone numbers, postal addresses and anything matching
+// configured patterns in new text, quoted text and quote headers; each found value is replaced by
+// a placeholder such as [EMAIL-3f2a9c1b0d]. Usernames can be pseudonymised too.
+//
+// Placeholders and pseudonyms are keyed hashes of the normalised value, so the same address or
+// user gets the same placeholder in every post of every topic and in every run with the same
+// secret, without a mapping table to keep. Every redaction is written to an audit log.
+//
+// A Redactor runs in the extraction pipeline (see orchestrator.OrchestratorConfig) or as a
+// standalone pass over an output tree (RedactTree).
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/users"
+)
+
+// SecretEnv is the environment variable the pseudonym secret is read from when the config has
+// none.
+const SecretEnv = "WAYPOINT_REDACTION_SECRET"
+
+// hashLength is the number of hex digits of a placeholder's hash.
+const hashLength = 10
+
+// UserKind is the kind recorded in the audit log for pseudonymised usernames.
+const UserKind = "username"
+
+// Placeholders and pseudonyms as this package writes them. Values already redacted are left
+// alone, so redacting redacted output again changes nothing.
+var (
+	placeholderPattern = regexp.MustCompile(fmt.Sprintf(`\[[A-Z0-9_]+-[0-9a-f]{%d}\]`, hashLength))
+	pseudonymPattern   = regexp.MustCompile(fmt.Sprintf(`^user-[0-9a-f]{%d}$`, hashLength))
+)
+
+// isRedacted reports whether value is a pseudonym or a placeholder.
+func isRedacted(value string) bool {
+	if pseudonymPattern.MatchString(value) {
+		return true
+	}
+	loc := placeholderPattern.FindStringIndex(value)
+	return loc != nil && loc[0] == 0 && loc[1] == len(value)
+}
+
+// PatternConfig configures a detector by regular expression.
+type PatternConfig struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// Config configures a Redactor.
+type Config struct {
+	// Detectors names the built-in detectors to run (email, phone, postal_address). Nil runs
+	// them all; an empty list runs none.
+	Detectors []string `json:"detectors"`
+	// Patterns adds detectors by regular expression, e.g. for account numbers.
+	Patterns []PatternConfig `json:"patterns"`
+	// PseudonymiseUsers replaces author and quoted usernames by pseudonyms, user-3f2a9c1b0d.
+	PseudonymiseUsers bool `json:"pseudonymiseUsers"`
+	// Secret keys the placeholder hashes; without one, anyone can confirm a guessed value by
+	// hashing it. Empty uses SecretEnv.
+	Secret string `json:"secret"`
+	// AuditLogPath is the JSON Lines file every redaction is appended to. Empty disables it.
+	AuditLogPath string `json:"auditLogPath"`
+	// AuditValues also writes the redacted values to the audit log. The log then holds the
+	// personal information itself and must not be shared.
+	AuditValues bool `json:"auditValues"`
+}
+
+// LoadConfig reads a Config from a JSON file.
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read redaction config %s: %w", path, err)
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return config, fmt.Errorf("failed to unmarshal redaction config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Redactor redacts posts.
+type Redactor struct {
+	detectors   []Detector
+	users       bool
+	secret      []byte
+	audit       *AuditLog
+	auditValues bool
+	fingerprint string
+}
+
+// New creates a Redactor from config, opening its audit log if one is configured.
+func New(config Config) (*Redactor, error) {
+	names := config.Detectors
+	if names == nil {
+		names = []string{DetectorEmail, DetectorPhone, DetectorPostalAddress}
+	}
+	var detectors []Detector
+	builtins := BuiltinDetectors()
+	for _, name := range names {
+		detector, ok := builtins[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction detector %q", name)
+		}
+		detectors = append(detectors, detector())
+	}
+	for _, pattern := range config.Patterns {
+		if pattern.Name == "" {
+			return nil, fmt.Errorf("redaction pattern %q has no name", pattern.Pattern)
+		}
+		detector, err := NewPatternDetector(pattern.Name, pattern.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		detectors = append(detectors, detector)
+	}
+	return NewWithDetectors(config, detectors)
+}
+
+// NewWithDetectors is New with the given detectors instead of those config names.
+func NewWithDetectors(config Config, detectors []Detector) (*Redactor, error) {
+	secret := config.Secret
+	if secret == "" {
+		secret = os.Getenv(SecretEnv)
+	}
+	if secret == "" {
+		log.Printf("[WARNING] REDACT: No secret set (config or %s); placeholders can be matched to guessed values", SecretEnv)
+	}
+	r := &Redactor{detectors: detectors, users: config.PseudonymiseUsers, secret: []byte(secret), auditValues: config.AuditValues}
+
+	// The fingerprint changes whenever the same post would be redacted differently
+	hash := sha256.New()
+	for _, detector := range detectors {
+		fmt.Fprintf(hash, "detector %s\n", detector.Name())
+	}
+	for _, pattern := range config.Patterns {
+		fmt.Fprintf(hash, "pattern %s %s\n", pattern.Name, pattern.Pattern)
+	}
+	fmt.Fprintf(hash, "users %t\nsecret %s\n", config.PseudonymiseUsers, r.hash("secret", ""))
+	r.fingerprint = hex.EncodeToString(hash.Sum(nil))[:12]
+
+	if config.AuditLogPath != "" {
+		audit, err := OpenAuditLog(config.AuditLogPath)
+		if err != nil {
+			return nil, err
+		}
+		r.audit = audit
+	}
+	return r, nil
+}
+
+// Fingerprint identifies the redaction settings, so extraction can tell output redacted with
+// other settings (or a different secret) from current output.
+func (r *Redactor) Fingerprint() string {
+	return "redact-" + r.fingerprint
+}
+
+// Close closes the audit log.
+func (r *Redactor) Close() error {
+	return r.audit.Close()
+}
+
+// hash returns the keyed hash of a normalised value of a kind.
+func (r *Redactor) hash(kind string, normalised string) string {
+	mac := hmac.New(sha256.New, r.secret)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(normalised))
+	return hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
+
+// Placeholder returns the placeholder of a value found by a detector.
+func (r *Redactor) Placeholder(detector Detector, value string) string {
+	kind := detector.Name()
+	return "[" + strings.ToUpper(kind) + "-" + r.hash(kind, detector.Normalise(value)) + "]"
+}
+
+// Pseudonym returns the pseudonym of a username. Spellings that are the same user (see
+// users.Key) get the same pseudonym; an empty name, a pseudonym and a placeholder stay as they are.
+func (r *Redactor) Pseudonym(username string) string {
+	key := users.Key(username)
+	if key == "" || isRedacted(username) {
+		return username
+	}
+	return "user-" + r.hash(UserKind, key)
+}
+
+// Redaction is one value replaced in a text.
+type Redaction struct {
+	Kind        string
+	Placeholder string
+	Start       int // Byte offsets of the value in the original text
+	End         int
+	Value       string
+}
+
+// RedactText replaces everything the detectors find in text by placeholders. Placeholders already
+// in text are kept.
+func (r *Redactor) RedactText(text string) (string, []Redaction) {
+	var redactions []Redaction
+	redacted := placeholderPattern.FindAllStringIndex(text, -1)
+	for _, detector := range r.detectors {
+		for _, match := range detector.Find(text) {
+			if overlapsAny(match.Start, match.End, redacted) {
+				continue
+			}
+			value := text[match.Start:match.End]
+			redactions = append(redactions, Redaction{
+				Kind:        detector.Name(),
+				Placeholder: r.Placeholder(detector, value),
+				Start:       match.Start,
+				End:         match.End,
+				Value:       value,
+			})
+		}
+	}
+	if len(redactions) == 0 {
+		return text, nil
+	}
+
+	// Where detectors overlap, the earliest and then longest match wins
+	sort.SliceStable(redactions, func(i, j int) bool {
+		if redactions[i].Start != redactions[j].Start {
+			return redactions[i].Start < redactions[j].Start
+		}
+		return redactions[i].End > redactions[j].End
+	})
+	var kept []Redaction
+	var b strings.Builder
+	last := 0
+	for _, redaction := range redactions {
+		if redaction.Start < last {
+			continue
+		}
+		b.WriteString(text[last:redaction.Start])
+		b.WriteString(redaction.Placeholder)
+		last = redaction.End
+		kept = append(kept, redaction)
+	}
+	b.WriteString(text[last:])
+	return b.String(), kept
+}
+
+// overlapsAny reports whether text[start:end] overlaps one of the spans.
+func overlapsAny(start int, end int, spans [][]int) bool {
+	for _, span := range spans {
+		if start < span[1] && span[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactPost redacts a post in place: new text, quoted text and, when usernames are
+// pseudonymised, the author and the quoted users. Each redaction is written to the audit log with
+// source (the page or output file the post came from). It returns the number of redactions.
+func (r *Redactor) RedactPost(post *data.PostMetadata, source string) (int, error) {
+	count := 0
+	record := func(field string, redactions []Redaction) error {
+		count += len(redactions)
+		for _, redaction := range redactions {
+			entry := AuditEntry{
+				Source:      source,
+				SubForumID:  post.SubForumID,
+				TopicID:     post.TopicID,
+				PostID:      post.PostID,
+				Field:       field,
+				Kind:        redaction.Kind,
+				Placeholder: redaction.Placeholder,
+				Start:       redaction.Start,
+				End:         redaction.End,
+			}
+			if r.auditValues {
+				entry.Value = redaction.Value
+			}
+			if err := r.audit.Record(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	pseudonymise := func(field string, name *string) error {
+		if !r.users || users.Key(*name) == "" || isRedacted(*name) {
+			return nil
+		}
+		pseudonym := r.Pseudonym(*name)
+		redaction := Redaction{Kind: UserKind, Placeholder: pseudonym, End: len(*name), Value: *name}
+		*name = pseudonym
+		return record(field, []Redaction{redaction})
+	}
+
+	if err := pseudonymise("author_username", &post.AuthorUsername); err != nil {
+		return count, err
+	}
+	for i := range post.ParsedContent {
+		block := &post.ParsedContent[i]
+		field := fmt.Sprintf("content_blocks[%d]", i)
+		switch block.Type {
+		case data.ContentBlockTypeNewText:
+			var redactions []Redaction
+			block.Content, redactions = r.RedactText(block.Content)
+			if err := record(field+".content", redactions); err != nil {
+				return count, err
+			}
+		case data.ContentBlockTypeQuote:
+			var redactions []Redaction
+			block.QuotedText, redactions = r.RedactText(block.QuotedText)
+			if err := record(field+".quoted_text", redactions); err != nil {
+				return count, err
+			}
+			if err := pseudonymise(field+".quoted_user", &block.QuotedUser); err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, nil
+}
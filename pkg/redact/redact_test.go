@@ -0,0 +1,181 @@
+package redact
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"project-waypoint/pkg/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func find(detector Detector, text string) []string {
+	var values []string
+	for _, match := range detector.Find(text) {
+		values = append(values, text[match.Start:match.End])
+	}
+	return values
+}
+
+func TestDetectors(t *testing.T) {
+	assert.Equal(t, []string{"john.doe@example.co.uk", "a_b@mail.example.com"},
+		find(EmailDetector(), `Mail john.doe@example.co.uk or <a href="mailto:a_b@mail.example.com">me</a>.`))
+
+	assert.Equal(t, []string{"+44 20 7946 0958", "(555) 123-4567", "555-1234", "020 7946 0958"},
+		find(PhoneDetector(), "Call +44 20 7946 0958, (555) 123-4567, 555-1234 or 020 7946 0958."))
+	assert.Empty(t, find(PhoneDetector(), "Posted 2003-01-09 11:20, in 1995 2000 people came; order 12345678; ISBN 978-0-12-345678-9"))
+	assert.Empty(t, find(PhoneDetector(), "He toured 1975-1982, and again 1995-1996 (or 1899.1901)."))
+	assert.Equal(t, []string{"1975-1234", "555-1982"}, find(PhoneDetector(), "Call 1975-1234 or 555-1982."), "only ranges of two years are left alone")
+
+	assert.Equal(t, []string{"221B Baker Street, London", "1600 Pennsylvania Ave NW", "PO Box 123", "SW1A 1AA"},
+		find(PostalAddressDetector(), "Write to 221B Baker Street, London or 1600 Pennsylvania Ave NW, or PO Box 123, SW1A 1AA."))
+	assert.Equal(t, []string{"42 Wallaby Way, Sydney", "350 Fifth Avenue, New York, NY 10118"},
+		find(PostalAddressDetector(), "At 42 Wallaby Way, Sydney and 350 Fifth Avenue, New York, NY 10118 there are 3 Card tricks."))
+}
+
+func TestRedactText_ConsistentPlaceholders(t *testing.T) {
+	r, err := New(Config{Secret: "s3cret"})
+	require.NoError(t, err)
+
+	text, redactions := r.RedactText("Email Bob@Example.com, then bob@example.com; phone 555-123-4567 or 555.123.4567.")
+	require.Len(t, redactions, 4)
+	email, phone := redactions[0].Placeholder, redactions[2].Placeholder
+	assert.Regexp(t, `^\[EMAIL-[0-9a-f]{10}\]$`, email)
+	assert.Regexp(t, `^\[PHONE-[0-9a-f]{10}\]$`, phone)
+	assert.Equal(t, "Email "+email+", then "+email+"; phone "+phone+" or "+phone+".", text)
+	assert.Equal(t, Redaction{Kind: DetectorEmail, Placeholder: email, Start: 6, End: 21, Value: "Bob@Example.com"}, redactions[0])
+
+	// The same secret gives the same placeholders; another secret different ones
+	again, err := New(Config{Secret: "s3cret"})
+	require.NoError(t, err)
+	other, err := New(Config{Secret: "other"})
+	require.NoError(t, err)
+	assert.Equal(t, email, again.Placeholder(EmailDetector(), "bob@example.com"))
+	assert.NotEqual(t, email, other.Placeholder(EmailDetector(), "bob@example.com"))
+	assert.Equal(t, r.Fingerprint(), again.Fingerprint())
+	assert.NotEqual(t, r.Fingerprint(), other.Fingerprint())
+
+	unchanged, redactions := r.RedactText("Nothing personal here.")
+	assert.Equal(t, "Nothing personal here.", unchanged)
+	assert.Empty(t, redactions)
+}
+
+func TestNew_Config(t *testing.T) {
+	r, err := New(Config{Detectors: []string{}, Patterns: []PatternConfig{{Name: "member_id", Pattern: `\bMC-\d{6}\b`}}})
+	require.NoError(t, err)
+	text, _ := r.RedactText("Member MC-123456, mail a@b.com")
+	assert.Regexp(t, `^Member \[MEMBER_ID-[0-9a-f]{10}\], mail a@b.com$`, text)
+
+	_, err = New(Config{Detectors: []string{"ssn"}})
+	assert.ErrorContains(t, err, `unknown redaction detector "ssn"`)
+	_, err = New(Config{Patterns: []PatternConfig{{Name: "bad", Pattern: "("}}})
+	assert.ErrorContains(t, err, "invalid pattern for detector bad")
+}
+
+func readAudit(t *testing.T, path string) []AuditEntry {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry AuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestRedactPost(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "audit", "redactions.jsonl")
+	r, err := New(Config{Secret: "s3cret", PseudonymiseUsers: true, AuditLogPath: auditPath})
+	require.NoError(t, err)
+
+	post := data.PostMetadata{PostID: "7", TopicID: "100", SubForumID: "66", AuthorUsername: "Dai Vernon",
+		ParsedContent: []data.ContentBlock{
+			{Type: data.ContentBlockTypeQuote, QuotedUser: "dai  vernon", QuotedText: "Reach me at dai@example.com"},
+			{Type: data.ContentBlockTypeNewText, Content: "Or not. Call 555-1234."},
+		}}
+	count, err := r.RedactPost(&post, "66_100.json")
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, 4, count)
+
+	assert.Regexp(t, `^user-[0-9a-f]{10}$`, post.AuthorUsername)
+	assert.Equal(t, post.AuthorUsername, post.ParsedContent[0].QuotedUser, "spellings of one user share a pseudonym")
+	assert.Equal(t, "Reach me at "+r.Placeholder(EmailDetector(), "dai@example.com"), post.ParsedContent[0].QuotedText)
+	assert.Equal(t, "Or not. Call "+r.Placeholder(PhoneDetector(), "555-1234")+".", post.ParsedContent[1].Content)
+
+	entries := readAudit(t, auditPath)
+	require.Len(t, entries, 4)
+	assert.Equal(t, "author_username", entries[0].Field)
+	assert.Equal(t, AuditEntry{RecordedAt: entries[1].RecordedAt, Source: "66_100.json", SubForumID: "66", TopicID: "100", PostID: "7",
+		Field: "content_blocks[0].quoted_text", Kind: DetectorEmail, Placeholder: r.Placeholder(EmailDetector(), "dai@example.com"), Start: 12, End: 27},
+		entries[1], "values are not audited by default")
+	assert.Equal(t, "content_blocks[0].quoted_user", entries[2].Field)
+	assert.Equal(t, "content_blocks[1].content", entries[3].Field)
+}
+
+func TestRedactTree(t *testing.T) {
+	srcDir, destDir := t.TempDir(), t.TempDir()
+	posts := []data.PostMetadata{{TopicID: "100", SubForumID: "66", AuthorUsername: "alice",
+		ParsedContent: []data.ContentBlock{{Type: data.ContentBlockTypeNewText, Content: "alice@example.com"}}}}
+	content, err := json.Marshal(posts)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "66_100.json"), content, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "66_101.json"), []byte(`[{"topic_id":"101","author_username":"bob"}]`), 0644))
+
+	r, err := New(Config{Secret: "s3cret", AuditLogPath: filepath.Join(t.TempDir(), "audit.jsonl"), AuditValues: true})
+	require.NoError(t, err)
+	stats, err := RedactTree(r, srcDir, destDir)
+	require.NoError(t, err)
+	assert.Equal(t, TreeStats{Files: 2, Posts: 2, Redactions: 1, Changed: 1}, stats)
+	require.NoError(t, r.Close())
+
+	redacted, err := os.ReadFile(filepath.Join(destDir, "66_100.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(redacted), "alice@example.com")
+	assert.Contains(t, string(redacted), `"author_username": "alice"`, "usernames are kept unless pseudonymised")
+	original, err := os.ReadFile(filepath.Join(srcDir, "66_100.json"))
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(original), "alice@example.com"), "the source tree is left alone")
+	assert.FileExists(t, filepath.Join(destDir, "66_101.json"))
+	assert.Equal(t, "alice@example.com", readAudit(t, r.audit.Path())[0].Value)
+}
+
+func TestRedact_Idempotent(t *testing.T) {
+	r, err := New(Config{Secret: "s3cret", PseudonymiseUsers: true,
+		Patterns: []PatternConfig{{Name: "hex", Pattern: `\b[0-9a-f]{10}\b`}}})
+	require.NoError(t, err)
+
+	pseudonym := r.Pseudonym("Dai Vernon")
+	assert.Equal(t, pseudonym, r.Pseudonym(pseudonym), "a pseudonym is not pseudonymised again")
+	placeholder := r.Placeholder(EmailDetector(), "dai@example.com")
+	assert.Equal(t, placeholder, r.Pseudonym(placeholder))
+
+	text, redactions := r.RedactText("Mail " + placeholder + " or call 555-1234.")
+	require.Len(t, redactions, 1, "the hash inside the placeholder is not redacted again")
+	again, redactions := r.RedactText(text)
+	assert.Equal(t, text, again)
+	assert.Empty(t, redactions)
+
+	post := data.PostMetadata{AuthorUsername: "Dai Vernon", ParsedContent: []data.ContentBlock{
+		{Type: data.ContentBlockTypeQuote, QuotedUser: "slydini", QuotedText: "dai@example.com"},
+		{Type: data.ContentBlockTypeNewText, Content: "Call 555-1234."},
+	}}
+	count, err := r.RedactPost(&post, "66_100.json")
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+	redacted := post
+	redacted.ParsedContent = append([]data.ContentBlock(nil), post.ParsedContent...)
+	count, err = r.RedactPost(&post, "66_100.json")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, redacted, post)
+}
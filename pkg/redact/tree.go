@@ -0,0 +1,73 @@
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree"
+)
+
+// TreeStats summarises a RedactTree pass.
+type TreeStats struct {
+	Files      int
+	Posts      int
+	Redactions int
+	Changed    int // Files with at least one redaction
+}
+
+// RedactTree redacts every output JSON file under srcDir and writes it to the same relative path
+// under destDir, formatted as the extraction writes it. destDir may be srcDir to redact in place;
+// each file is replaced atomically.
+func RedactTree(r *Redactor, srcDir string, destDir string) (TreeStats, error) {
+	var stats TreeStats
+	err := outputtree.WalkPosts(srcDir, func(path string, posts []data.PostMetadata) error {
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+
+		redactions := 0
+		for i := range posts {
+			count, err := r.RedactPost(&posts[i], filepath.ToSlash(relPath))
+			if err != nil {
+				return err
+			}
+			redactions += count
+		}
+		stats.Files++
+		stats.Posts += len(posts)
+		stats.Redactions += redactions
+		if redactions > 0 {
+			stats.Changed++
+		}
+		return writePosts(filepath.Join(destDir, relPath), posts)
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to redact output tree %s: %w", srcDir, err)
+	}
+	log.Printf("[INFO] REDACT: %d redactions in %d of %d files (%d posts) written to %s", stats.Redactions, stats.Changed, stats.Files, stats.Posts, destDir)
+	return stats, nil
+}
+
+func writePosts(path string, posts []data.PostMetadata) error {
+	content, err := json.MarshalIndent(posts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal redacted posts for %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write redacted posts to %s: %w", tempPath, err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to rename %s to %s: %w", tempPath, path, err)
+	}
+	return nil
+}
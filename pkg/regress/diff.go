@@ -0,0 +1,43 @@
+package regress
+
+import (
+	"strconv"
+
+	"project-waypoint/pkg/postdiff"
+)
+
+// Diff compares the current output of a page with its golden. Posts and content blocks are
+// matched by position; a post or block present on one side only is reported once.
+func Diff(golden PageResult, actual PageResult) []FieldDiff {
+	var diffs []FieldDiff
+	add := func(post int, postID string, field string, goldenValue string, actualValue string) {
+		if goldenValue != actualValue {
+			diffs = append(diffs, FieldDiff{Page: actual.Page, Post: post, PostID: postID, Field: field, Golden: goldenValue, Actual: actualValue})
+		}
+	}
+
+	add(-1, "", "error", golden.Error, actual.Error)
+	add(-1, "", "post_count", strconv.Itoa(len(golden.Posts)), strconv.Itoa(len(actual.Posts)))
+
+	for i := 0; i < len(golden.Posts) || i < len(actual.Posts); i++ {
+		switch {
+		case i >= len(actual.Posts):
+			add(i, golden.Posts[i].Metadata.PostID, "post", postdiff.Summary(golden.Posts[i].Metadata), postdiff.Missing)
+			continue
+		case i >= len(golden.Posts):
+			add(i, actual.Posts[i].Metadata.PostID, "post", postdiff.Missing, postdiff.Summary(actual.Posts[i].Metadata))
+			continue
+		}
+
+		g, a := golden.Posts[i], actual.Posts[i]
+		postID := a.Metadata.PostID
+		if postID == "" {
+			postID = g.Metadata.PostID
+		}
+		add(i, postID, "error", g.Error, a.Error)
+		for _, change := range postdiff.Post(g.Metadata, a.Metadata) {
+			add(i, postID, change.Field, change.Old, change.New)
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,212 @@
+// Package regress runs the post extraction pipeline over a corpus of archived topic pages and
+// compares the results with stored golden JSON files, reporting differences per post and field.
+// Goldens mirror the corpus layout: corpus/66/19618/page_1.html is checked against
+// golden/66/19618/page_1.json. In accept mode the goldens are rewritten from the current output.
+package regress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/htmlparser"
+	"project-waypoint/pkg/orchestrator"
+
+	"waypoint_archive_scripts/pkg/forumadapter"
+)
+
+// PageResult is the extraction output of one archived page, as stored in its golden file.
+type PageResult struct {
+	Page  string       `json:"page"`            // Corpus-relative path, with forward slashes
+	Error string       `json:"error,omitempty"` // Set if the page could not be loaded
+	Posts []PostResult `json:"posts"`
+}
+
+// PostResult is the extraction output of one post block.
+type PostResult struct {
+	Index    int               `json:"index"`           // 0-based position of the block on the page
+	Error    string            `json:"error,omitempty"` // Metadata extraction error, if any
+	Metadata data.PostMetadata `json:"metadata"`
+}
+
+// FieldDiff is one field of one post whose current output differs from the golden.
+type FieldDiff struct {
+	Page   string `json:"page"`
+	Post   int    `json:"post"` // Block index, or -1 for page-level differences
+	PostID string `json:"post_id,omitempty"`
+	Field  string `json:"field"` // e.g. "author_username" or "content_blocks[1].quoted_user"
+	Golden string `json:"golden"`
+	Actual string `json:"actual"`
+}
+
+func (d FieldDiff) String() string {
+	post := "page"
+	if d.Post >= 0 {
+		post = fmt.Sprintf("post %d", d.Post)
+		if d.PostID != "" {
+			post += " (" + d.PostID + ")"
+		}
+	}
+	return fmt.Sprintf("%s %s %s:\n  golden: %q\n  actual: %q", d.Page, post, d.Field, d.Golden, d.Actual)
+}
+
+// Options configure a regression run.
+type Options struct {
+	CorpusDir string
+	GoldenDir string
+	Accept    bool                      // Rewrite the goldens instead of comparing against them
+	Adapter   forumadapter.ForumAdapter // forumadapter.Default() if nil
+}
+
+// Report summarizes a regression run.
+type Report struct {
+	Pages          int         `json:"pages"`
+	Posts          int         `json:"posts"`
+	Diffs          []FieldDiff `json:"diffs"`
+	MissingGoldens []string    `json:"missing_goldens,omitempty"` // Pages without a golden file
+	Accepted       int         `json:"accepted,omitempty"`        // Goldens written in accept mode
+}
+
+// Passed reports whether every page matched its golden.
+func (r Report) Passed() bool {
+	return len(r.Diffs) == 0 && len(r.MissingGoldens) == 0
+}
+
+// Run extracts every .html page under opts.CorpusDir and compares it with, or in accept
+// mode writes, its golden file.
+func Run(opts Options) (Report, error) {
+	var report Report
+	adapter := opts.Adapter
+	if adapter == nil {
+		adapter = forumadapter.Default()
+	}
+
+	pages, err := corpusPages(opts.CorpusDir)
+	if err != nil {
+		return report, err
+	}
+
+	for _, relPath := range pages {
+		actual := ExtractPage(adapter, opts.CorpusDir, relPath)
+		report.Pages++
+		report.Posts += len(actual.Posts)
+		goldenPath := GoldenPath(opts.GoldenDir, relPath)
+
+		if opts.Accept {
+			if err := writeGolden(goldenPath, actual); err != nil {
+				return report, err
+			}
+			report.Accepted++
+			continue
+		}
+
+		golden, err := readGolden(goldenPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			report.MissingGoldens = append(report.MissingGoldens, actual.Page)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Diffs = append(report.Diffs, Diff(golden, actual)...)
+	}
+	return report, nil
+}
+
+// corpusPages returns the corpus-relative paths of the .html files under corpusDir, sorted.
+func corpusPages(corpusDir string) ([]string, error) {
+	var pages []string
+	err := filepath.WalkDir(corpusDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".html") {
+			return nil
+		}
+		relPath, err := filepath.Rel(corpusDir, path)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, relPath)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan corpus directory %s: %w", corpusDir, err)
+	}
+	sort.Strings(pages)
+	return pages, nil
+}
+
+// GoldenPath returns the golden file for a corpus-relative page path.
+func GoldenPath(goldenDir string, relPath string) string {
+	return filepath.Join(goldenDir, strings.TrimSuffix(relPath, filepath.Ext(relPath))+".json")
+}
+
+// ExtractPage runs the extraction pipeline on a corpus page. Posts whose metadata could not be
+// extracted are kept with their error, so changes in what fails are caught as well.
+func ExtractPage(adapter forumadapter.ForumAdapter, corpusDir string, relPath string) PageResult {
+	result := PageResult{Page: filepath.ToSlash(relPath), Posts: []PostResult{}}
+	filePath := filepath.Join(corpusDir, relPath)
+
+	page, err := htmlparser.LoadHTMLPage(filePath)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	page.Adapter = adapter
+
+	postBlocks, err := page.GetPostBlocks()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	for i, postBlock := range postBlocks {
+		metadata, err := orchestrator.ExtractPost(adapter, postBlock, filePath, i, nil)
+		post := PostResult{Index: i, Metadata: metadata}
+		if err != nil {
+			// The corpus location is not part of the golden
+			post.Error = strings.ReplaceAll(err.Error(), filePath, relPath)
+		}
+		result.Posts = append(result.Posts, post)
+	}
+	return result
+}
+
+func readGolden(goldenPath string) (PageResult, error) {
+	var golden PageResult
+	content, err := os.ReadFile(goldenPath)
+	if err != nil {
+		return golden, fmt.Errorf("failed to read golden file %s: %w", goldenPath, err)
+	}
+	if err := json.Unmarshal(content, &golden); err != nil {
+		return golden, fmt.Errorf("failed to unmarshal golden file %s: %w", goldenPath, err)
+	}
+	return golden, nil
+}
+
+// writeGolden writes a golden file. HTML is left unescaped so goldens read well in reviews.
+func writeGolden(goldenPath string, result PageResult) error {
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return fmt.Errorf("failed to marshal golden for %s: %w", result.Page, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(goldenPath), 0755); err != nil {
+		return fmt.Errorf("failed to create golden directory for %s: %w", goldenPath, err)
+	}
+	if err := os.WriteFile(goldenPath, content.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write golden file %s: %w", goldenPath, err)
+	}
+	log.Printf("[INFO] REGRESS: Wrote golden %s", goldenPath)
+	return nil
+}
@@ -0,0 +1,109 @@
+package regress
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"project-waypoint/pkg/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	corpusDir = "../../test-data/regress/corpus"
+	goldenDir = "../../test-data/regress/golden"
+)
+
+// TestGoldenCorpus fails whenever the extraction output of the checked-in corpus changes.
+// Review the diff and run `parser_regress -accept` from the repository root if it is intended.
+func TestGoldenCorpus(t *testing.T) {
+	report, err := Run(Options{CorpusDir: corpusDir, GoldenDir: goldenDir})
+	require.NoError(t, err)
+
+	assert.Greater(t, report.Posts, 0, "corpus should contain posts")
+	assert.Empty(t, report.MissingGoldens)
+	for _, diff := range report.Diffs {
+		t.Errorf("golden mismatch: %s", diff)
+	}
+}
+
+func TestRun_AcceptThenCompare(t *testing.T) {
+	tmpGolden := t.TempDir()
+
+	report, err := Run(Options{CorpusDir: corpusDir, GoldenDir: tmpGolden})
+	require.NoError(t, err)
+	assert.False(t, report.Passed())
+	assert.Equal(t, []string{"66/19618/page_1.html", "66/19618/page_2.html"}, report.MissingGoldens)
+
+	report, err = Run(Options{CorpusDir: corpusDir, GoldenDir: tmpGolden, Accept: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Accepted)
+
+	// Change one field in a golden to simulate a parser regression
+	goldenPath := GoldenPath(tmpGolden, filepath.Join("66", "19618", "page_1.html"))
+	golden, err := readGolden(goldenPath)
+	require.NoError(t, err)
+	wantAuthor := golden.Posts[1].Metadata.AuthorUsername
+	golden.Posts[1].Metadata.AuthorUsername = "someone else"
+	require.NoError(t, writeGolden(goldenPath, golden))
+
+	report, err = Run(Options{CorpusDir: corpusDir, GoldenDir: tmpGolden})
+	require.NoError(t, err)
+	require.Len(t, report.Diffs, 1)
+	diff := report.Diffs[0]
+	assert.Equal(t, "66/19618/page_1.html", diff.Page)
+	assert.Equal(t, 1, diff.Post)
+	assert.Equal(t, "author_username", diff.Field)
+	assert.Equal(t, "someone else", diff.Golden)
+	assert.Equal(t, wantAuthor, diff.Actual)
+}
+
+func TestDiff(t *testing.T) {
+	post := func(id string, blocks ...data.ContentBlock) PostResult {
+		return PostResult{Metadata: data.PostMetadata{PostID: id, AuthorUsername: "bob", ParsedContent: blocks}}
+	}
+	quote := data.ContentBlock{Type: data.ContentBlockTypeQuote, QuotedUser: "alice", QuotedText: "hi"}
+	text := data.ContentBlock{Type: data.ContentBlockTypeNewText, Content: "reply"}
+
+	changedQuote := quote
+	changedQuote.QuotedUser = "Quote: alice"
+
+	golden := PageResult{Page: "p.html", Posts: []PostResult{post("1", quote, text), post("2")}}
+	actual := PageResult{Page: "p.html", Posts: []PostResult{post("1", changedQuote), post("2"), post("3")}}
+
+	diffs := Diff(golden, actual)
+	fields := make([]string, len(diffs))
+	for i, d := range diffs {
+		fields[i] = d.Field
+	}
+	assert.Equal(t, []string{
+		"post_count",
+		"content_block_count",
+		"content_blocks[0].quoted_user",
+		"content_blocks[1]",
+		"post",
+	}, fields)
+	assert.Equal(t, "(missing)", diffs[3].Actual)
+	assert.Equal(t, "(missing)", diffs[4].Golden)
+	assert.Equal(t, 2, diffs[4].Post)
+
+	assert.Empty(t, Diff(golden, golden))
+}
+
+func TestGoldensRoundTrip(t *testing.T) {
+	// Goldens must unmarshal to exactly what was written, or every run would report diffs
+	result := PageResult{Page: "a/b/page_1.html", Posts: []PostResult{{Index: 0, Error: "x", Metadata: data.PostMetadata{PostID: "1", ParsedContent: []data.ContentBlock{{Type: data.ContentBlockTypeNewText, Content: "<b>&amp;</b>"}}}}}}
+	goldenPath := filepath.Join(t.TempDir(), "page_1.json")
+	require.NoError(t, writeGolden(goldenPath, result))
+
+	content, err := os.ReadFile(goldenPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "<b>&amp;</b>", "HTML should not be escaped in goldens")
+
+	var decoded PageResult
+	require.NoError(t, json.Unmarshal(content, &decoded))
+	assert.Equal(t, result, decoded)
+}
@@ -0,0 +1,319 @@
+// Package search is a full-text index over the extracted posts (the JSON files written by
+// orchestrator.ProcessTopic). It indexes post text, quoted text, authors and topic titles with
+// term positions, so phrase queries work, and is updated incrementally: only output files whose
+// content changed since the last update are indexed again.
+package search
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree"
+)
+
+// Field is the part of a post a term occurs in.
+type Field uint8
+
+const (
+	FieldText   Field = iota // new_text blocks
+	FieldQuote               // Quoted text and quoted user names
+	FieldAuthor              // Author username
+	FieldTitle               // Topic title, from the topic index
+)
+
+// fieldWeights scale term frequencies per field when ranking: a match in the title or author
+// counts more than one in the post text, a match in quoted text less.
+var fieldWeights = [...]float64{FieldText: 1, FieldQuote: 0.5, FieldAuthor: 2, FieldTitle: 2}
+
+// Doc is an indexed post.
+type Doc struct {
+	PostID     string `json:"post_id"`
+	TopicID    string `json:"topic_id"`
+	SubForumID string `json:"subforum_id"`
+	Author     string `json:"author"`
+	Timestamp  string `json:"timestamp"` // "YYYY-MM-DD HH:MM:SS", as extracted
+	PostURL    string `json:"post_url"`
+	Title      string `json:"title,omitempty"`
+	Text       string `json:"-"`    // Plain text of the post's new_text blocks, for snippets
+	Quote      string `json:"-"`    // Quoted users and plain quoted text
+	File       string `json:"file"` // Output file the post was read from, relative to the output tree
+	Length     int    `json:"-"`    // Number of indexed terms, for length normalisation
+}
+
+// Posting lists the positions of a term in one field of a document.
+type Posting struct {
+	Doc       int
+	Field     Field
+	Positions []int
+}
+
+// FileEntry remembers what was indexed from an output file.
+type FileEntry struct {
+	Hash  string // SHA-256 of the file content
+	Title string // Topic title the posts were indexed with
+	Docs  []int
+}
+
+// Index is an inverted index of posts. Fields are exported for persistence only; use the methods.
+type Index struct {
+	Docs        map[int]*Doc
+	Postings    map[string][]Posting // Term to postings, in ascending Doc order
+	Files       map[string]*FileEntry
+	NextDoc     int
+	TotalLength int
+}
+
+// UpdateStats counts the output files handled by Update.
+type UpdateStats struct {
+	Added     int
+	Updated   int
+	Removed   int
+	Unchanged int
+	Posts     int // Posts indexed from added and updated files
+}
+
+// New creates an empty Index.
+func New() *Index {
+	return &Index{
+		Docs:     make(map[int]*Doc),
+		Postings: make(map[string][]Posting),
+		Files:    make(map[string]*FileEntry),
+	}
+}
+
+// Load reads an index saved with Save. A missing file yields an empty index.
+func Load(path string) (*Index, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("[INFO] SEARCH: Index file %s not found. Starting with an empty index.", path)
+			return New(), nil
+		}
+		return nil, fmt.Errorf("failed to open search index %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search index %s: %w", path, err)
+	}
+	index := New()
+	if err := gob.NewDecoder(reader).Decode(index); err != nil {
+		return nil, fmt.Errorf("failed to decode search index %s: %w", path, err)
+	}
+	return index, nil
+}
+
+// Save writes the index to path (gzipped gob) atomically via a temporary file.
+func (idx *Index) Save(path string) error {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory for search index %s: %w", dir, err)
+		}
+	}
+	tempPath := path + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary search index %s: %w", tempPath, err)
+	}
+	writer := gzip.NewWriter(file)
+	err = gob.NewEncoder(writer).Encode(idx)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write search index %s: %w", tempPath, err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to rename temporary search index %s to %s: %w", tempPath, path, err)
+	}
+	return nil
+}
+
+// Update brings the index in line with the output tree in outputDir: files that are new or whose
+// content or topic title changed are indexed again, files that disappeared are removed. titles
+// maps topic IDs to topic titles (e.g. from the topic index) and may be nil.
+func (idx *Index) Update(outputDir string, titles map[string]string) (UpdateStats, error) {
+	var stats UpdateStats
+	seen := make(map[string]bool)
+
+	err := outputtree.Walk(outputDir, func(path string) error {
+		relPath, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		seen[relPath] = true
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read output file %s: %w", path, err)
+		}
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])
+		title := titles[topicIDFromFile(relPath)]
+
+		existing, indexed := idx.Files[relPath]
+		if indexed && existing.Hash == hash && existing.Title == title {
+			stats.Unchanged++
+			return nil
+		}
+
+		posts, err := outputtree.ReadBytes(path, content)
+		if err != nil {
+			return err
+		}
+		idx.RemoveFile(relPath)
+		idx.AddFile(relPath, hash, title, posts)
+		stats.Posts += len(posts)
+		if indexed {
+			stats.Updated++
+		} else {
+			stats.Added++
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to update search index from %s: %w", outputDir, err)
+	}
+
+	for relPath := range idx.Files {
+		if !seen[relPath] {
+			idx.RemoveFile(relPath)
+			stats.Removed++
+		}
+	}
+	return stats, nil
+}
+
+// AddFile indexes the posts of an output file. hash identifies the file content for Update.
+func (idx *Index) AddFile(relPath string, hash string, title string, posts []data.PostMetadata) {
+	entry := &FileEntry{Hash: hash, Title: title}
+	for _, post := range posts {
+		docID := idx.NextDoc
+		idx.NextDoc++
+
+		var text, quote []string
+		for _, block := range post.ParsedContent {
+			switch block.Type {
+			case data.ContentBlockTypeNewText:
+				text = append(text, outputtree.OneLine(block.Content))
+			case data.ContentBlockTypeQuote:
+				quote = append(quote, block.QuotedUser, outputtree.OneLine(block.QuotedText))
+			}
+		}
+		doc := &Doc{
+			PostID:     post.PostID,
+			TopicID:    post.TopicID,
+			SubForumID: post.SubForumID,
+			Author:     post.AuthorUsername,
+			Timestamp:  post.Timestamp,
+			PostURL:    post.PostURL,
+			Title:      title,
+			Text:       strings.Join(text, "\n"),
+			Quote:      strings.Join(quote, "\n"),
+			File:       relPath,
+		}
+		doc.Length += idx.addField(docID, FieldText, doc.Text)
+		doc.Length += idx.addField(docID, FieldQuote, doc.Quote)
+		doc.Length += idx.addField(docID, FieldAuthor, doc.Author)
+		doc.Length += idx.addField(docID, FieldTitle, title)
+
+		idx.Docs[docID] = doc
+		idx.TotalLength += doc.Length
+		entry.Docs = append(entry.Docs, docID)
+	}
+	idx.Files[relPath] = entry
+}
+
+// addField indexes the terms of one field of a document and returns how many there were.
+func (idx *Index) addField(docID int, field Field, text string) int {
+	terms := Tokenize(text)
+	positions := make(map[string][]int)
+	for i, term := range terms {
+		positions[term] = append(positions[term], i)
+	}
+	for term, termPositions := range positions {
+		idx.Postings[term] = append(idx.Postings[term], Posting{Doc: docID, Field: field, Positions: termPositions})
+	}
+	return len(terms)
+}
+
+// RemoveFile removes the posts indexed from an output file.
+func (idx *Index) RemoveFile(relPath string) {
+	entry, ok := idx.Files[relPath]
+	if !ok {
+		return
+	}
+	removed := make(map[int]bool, len(entry.Docs))
+	terms := make(map[string]bool)
+	for _, docID := range entry.Docs {
+		doc := idx.Docs[docID]
+		if doc == nil {
+			continue
+		}
+		removed[docID] = true
+		idx.TotalLength -= doc.Length
+		for _, text := range []string{doc.Text, doc.Quote, doc.Author, doc.Title} {
+			for _, term := range Tokenize(text) {
+				terms[term] = true
+			}
+		}
+		delete(idx.Docs, docID)
+	}
+	for term := range terms {
+		idx.Postings[term] = withoutDocs(idx.Postings[term], removed)
+		if len(idx.Postings[term]) == 0 {
+			delete(idx.Postings, term)
+		}
+	}
+	delete(idx.Files, relPath)
+}
+
+func withoutDocs(postings []Posting, removed map[int]bool) []Posting {
+	kept := postings[:0]
+	for _, posting := range postings {
+		if !removed[posting.Doc] {
+			kept = append(kept, posting)
+		}
+	}
+	return kept
+}
+
+// topicIDFromFile returns the topic ID of an output file named {subforum_id}_{topic_id}.json.
+func topicIDFromFile(relPath string) string {
+	name := strings.TrimSuffix(filepath.Base(relPath), ".json")
+	if i := strings.LastIndex(name, "_"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// Stats returns the number of indexed files, posts and distinct terms.
+func (idx *Index) Stats() (files int, posts int, terms int) {
+	return len(idx.Files), len(idx.Docs), len(idx.Postings)
+}
+
+// sortedDocIDs returns the IDs of all documents in ascending order.
+func (idx *Index) sortedDocIDs() []int {
+	ids := make([]int, 0, len(idx.Docs))
+	for id := range idx.Docs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
@@ -0,0 +1,266 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Query is a parsed search. A post matches if it contains every term and every phrase (in any
+// field) and passes the filters. A query without terms or phrases lists the posts passing the
+// filters, newest first.
+type Query struct {
+	Terms      []string
+	Phrases    [][]string
+	Author     string // Exact author username, case-insensitive
+	SubForumID string
+	From       string // Earliest timestamp, "YYYY-MM-DD" or "YYYY-MM-DD HH:MM:SS", inclusive
+	To         string // Latest timestamp, "YYYY-MM-DD" (the whole day) or "YYYY-MM-DD HH:MM:SS", inclusive
+}
+
+// Hit is a matching post.
+type Hit struct {
+	Doc     Doc     `json:"post"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet,omitempty"`
+}
+
+// ParseQuery parses search text: "double-quoted" parts are phrases, the rest are single terms.
+func ParseQuery(text string) Query {
+	var query Query
+	parts := strings.Split(text, `"`)
+	for i, part := range parts {
+		terms := Tokenize(part)
+		// Odd parts are inside quotes; an unterminated quote makes the rest plain terms
+		if i%2 == 1 && i < len(parts)-1 && len(terms) > 1 {
+			query.Phrases = append(query.Phrases, terms)
+			continue
+		}
+		query.Terms = append(query.Terms, terms...)
+	}
+	return query
+}
+
+// allTerms returns the distinct terms and phrase terms of the query.
+func (q Query) allTerms() []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	for _, term := range q.Terms {
+		add(term)
+	}
+	for _, phrase := range q.Phrases {
+		for _, term := range phrase {
+			add(term)
+		}
+	}
+	return terms
+}
+
+// accepts reports whether a document passes the query's filters.
+func (q Query) accepts(doc *Doc) bool {
+	if q.Author != "" && !strings.EqualFold(doc.Author, q.Author) {
+		return false
+	}
+	if q.SubForumID != "" && doc.SubForumID != q.SubForumID {
+		return false
+	}
+	if q.From != "" && (doc.Timestamp == "" || doc.Timestamp < q.From) {
+		return false
+	}
+	if q.To != "" {
+		timestamp := doc.Timestamp
+		if len(q.To) < len(timestamp) {
+			timestamp = timestamp[:len(q.To)]
+		}
+		if timestamp == "" || timestamp > q.To {
+			return false
+		}
+	}
+	return true
+}
+
+// Search returns the best matches for the query, at most limit of them (all if limit <= 0).
+// Matches are ranked with BM25 over the field-weighted term frequencies.
+func (idx *Index) Search(q Query, limit int) []Hit {
+	terms := q.allTerms()
+	var hits []Hit
+	if len(terms) == 0 {
+		for _, docID := range idx.sortedDocIDs() {
+			if doc := idx.Docs[docID]; q.accepts(doc) {
+				hits = append(hits, Hit{Doc: *doc, Snippet: snippet(doc, nil)})
+			}
+		}
+		sort.SliceStable(hits, func(i, j int) bool { return hits[i].Doc.Timestamp > hits[j].Doc.Timestamp })
+		return truncate(hits, limit)
+	}
+
+	// Postings of every term by document; the candidates are the documents containing all terms
+	byDoc := make(map[string]map[int][]Posting, len(terms))
+	var candidates map[int]bool
+	for _, term := range terms {
+		docs := make(map[int][]Posting)
+		for _, posting := range idx.Postings[term] {
+			docs[posting.Doc] = append(docs[posting.Doc], posting)
+		}
+		byDoc[term] = docs
+		next := make(map[int]bool)
+		for docID := range docs {
+			if candidates == nil || candidates[docID] {
+				next[docID] = true
+			}
+		}
+		candidates = next
+	}
+
+	docCount := float64(len(idx.Docs))
+	averageLength := 1.0
+	if len(idx.Docs) > 0 && idx.TotalLength > 0 {
+		averageLength = float64(idx.TotalLength) / docCount
+	}
+	for docID := range candidates {
+		doc := idx.Docs[docID]
+		if doc == nil || !q.accepts(doc) || !containsPhrases(byDoc, docID, q.Phrases) {
+			continue
+		}
+		score := 0.0
+		for _, term := range terms {
+			frequency := 0.0
+			for _, posting := range byDoc[term][docID] {
+				frequency += fieldWeights[posting.Field] * float64(len(posting.Positions))
+			}
+			documents := float64(len(byDoc[term]))
+			idf := math.Log(1 + (docCount-documents+0.5)/(documents+0.5))
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(doc.Length)/averageLength)
+			score += idf * frequency * (bm25K1 + 1) / (frequency + norm)
+		}
+		hits = append(hits, Hit{Doc: *doc, Score: score, Snippet: snippet(doc, terms)})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Doc.Timestamp != hits[j].Doc.Timestamp {
+			return hits[i].Doc.Timestamp < hits[j].Doc.Timestamp
+		}
+		return hits[i].Doc.PostID < hits[j].Doc.PostID
+	})
+	return truncate(hits, limit)
+}
+
+// containsPhrases reports whether every phrase occurs, as consecutive terms within one field, in
+// the document.
+func containsPhrases(byDoc map[string]map[int][]Posting, docID int, phrases [][]string) bool {
+	for _, phrase := range phrases {
+		if !containsPhrase(byDoc, docID, phrase) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsPhrase(byDoc map[string]map[int][]Posting, docID int, phrase []string) bool {
+	positionsIn := func(term string, field Field) map[int]bool {
+		for _, posting := range byDoc[term][docID] {
+			if posting.Field == field {
+				positions := make(map[int]bool, len(posting.Positions))
+				for _, position := range posting.Positions {
+					positions[position] = true
+				}
+				return positions
+			}
+		}
+		return nil
+	}
+	for _, first := range byDoc[phrase[0]][docID] {
+		following := make([]map[int]bool, len(phrase))
+		for k := 1; k < len(phrase); k++ {
+			following[k] = positionsIn(phrase[k], first.Field)
+		}
+	start:
+		for _, start := range first.Positions {
+			for k := 1; k < len(phrase); k++ {
+				if !following[k][start+k] {
+					continue start
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(hits []Hit, limit int) []Hit {
+	if limit > 0 && len(hits) > limit {
+		return hits[:limit]
+	}
+	return hits
+}
+
+// snippetLength is the approximate length of a snippet in bytes.
+const snippetLength = 200
+
+// snippet returns an excerpt of the post text around the first occurrence of one of the terms,
+// or the start of the text if none occurs in it (e.g. the match is in a quote or the title).
+func snippet(doc *Doc, terms []string) string {
+	text := doc.Text
+	if text == "" {
+		text = doc.Quote
+	}
+	if len(text) <= snippetLength {
+		return text
+	}
+	lower := strings.ToLower(text)
+	match := -1
+	for _, term := range terms {
+		if i := strings.Index(lower, term); i >= 0 && (match < 0 || i < match) {
+			match = i
+		}
+	}
+	start := 0
+	if match > snippetLength/4 && len(lower) == len(text) {
+		start = match - snippetLength/4
+	}
+	end := start + snippetLength
+	if end > len(text) {
+		end = len(text)
+		start = max(0, end-snippetLength)
+	}
+	// Cut at spaces so words are not split
+	if start > 0 {
+		if i := strings.IndexByte(text[start:end], ' '); i >= 0 {
+			start += i + 1
+		}
+	}
+	if end < len(text) {
+		if i := strings.LastIndexByte(text[start:end], ' '); i > 0 {
+			end = start + i
+		}
+	}
+	for start > 0 && start < end && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	for end < len(text) && end > start && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	excerpt := text[start:end]
+	if start > 0 {
+		excerpt = "…" + excerpt
+	}
+	if end < len(text) {
+		excerpt += "…"
+	}
+	return excerpt
+}
@@ -0,0 +1,130 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree"
+	"project-waypoint/pkg/outputtree/outputtreetest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func post(id, topicID, author, timestamp, text string) data.PostMetadata {
+	return data.PostMetadata{
+		PostID:         id,
+		TopicID:        topicID,
+		SubForumID:     "66",
+		AuthorUsername: author,
+		Timestamp:      timestamp,
+		PostURL:        "https://forum.example/viewtopic.php?p=" + id + "#p" + id,
+		ParsedContent:  []data.ContentBlock{{Type: data.ContentBlockTypeNewText, Content: text}},
+	}
+}
+
+func postIDs(hits []Hit) []string {
+	var ids []string
+	for _, hit := range hits {
+		ids = append(ids, hit.Doc.PostID)
+	}
+	return ids
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"don", "t", "panic", "42"}, Tokenize("Don 't PANIC, 42!"))
+	assert.Equal(t, []string{"dont", "panic"}, Tokenize("don't panic"))
+	assert.Equal(t, []string{"fish", "chips", "now"}, Tokenize(outputtree.OneLine(`<div>Fish &amp; chips</div><div>now</div>`)))
+}
+
+func TestParseQuery(t *testing.T) {
+	query := ParseQuery(`Engine "cold start" trouble "single" "unterminated phrase`)
+	assert.Equal(t, []string{"engine", "trouble", "single", "unterminated", "phrase"}, query.Terms)
+	assert.Equal(t, [][]string{{"cold", "start"}}, query.Phrases)
+}
+
+func TestSearch(t *testing.T) {
+	outputDir := t.TempDir()
+	quoted := post("1003", "100", "carol", "2019-03-02 08:00:00", "Agreed.")
+	quoted.ParsedContent = append(quoted.ParsedContent, data.ContentBlock{
+		Type: data.ContentBlockTypeQuote, QuotedUser: "alice", QuotedText: "The engine has a cold start problem",
+	})
+	outputtreetest.WriteTopic(t, outputDir, "66_100.json", []data.PostMetadata{
+		post("1001", "100", "alice", "2019-03-01 10:00:00", "The engine has a cold start problem"),
+		post("1002", "100", "bob", "2019-03-01 11:30:00", "Start it cold and the engine stalls"),
+		quoted,
+	})
+	outputtreetest.WriteTopic(t, outputDir, "54_200.json", []data.PostMetadata{
+		post("2001", "200", "bob", "2020-01-05 09:00:00", "<div>Engine swap done</div>"),
+	})
+
+	index := New()
+	stats, err := index.Update(outputDir, map[string]string{"200": "Engine swap diary"})
+	require.NoError(t, err)
+	assert.Equal(t, UpdateStats{Added: 2, Posts: 4}, stats)
+
+	// Every post mentions the engine; the title and a short post rank the swap first
+	hits := index.Search(ParseQuery("engine"), 0)
+	assert.Equal(t, "2001", hits[0].Doc.PostID)
+	assert.Len(t, hits, 4)
+
+	// The phrase occurs in alice's post and in carol's quote of it, not in bob's post
+	hits = index.Search(ParseQuery(`"cold start"`), 0)
+	assert.Equal(t, []string{"1001", "1003"}, postIDs(hits))
+	assert.Equal(t, "https://forum.example/viewtopic.php?p=1001#p1001", hits[0].Doc.PostURL)
+	assert.Equal(t, "The engine has a cold start problem", hits[0].Snippet)
+
+	query := ParseQuery("engine")
+	query.Author = "BOB"
+	assert.Equal(t, []string{"2001", "1002"}, postIDs(index.Search(query, 0)))
+	query.To = "2019-03-01"
+	assert.Equal(t, []string{"1002"}, postIDs(index.Search(query, 0)))
+	query = Query{From: "2019-03-01 11:00:00", To: "2019-12-31"}
+	assert.Equal(t, []string{"1003", "1002"}, postIDs(index.Search(query, 0)), "filters alone list posts newest first")
+
+	assert.Empty(t, index.Search(ParseQuery("engine gearbox"), 0))
+	assert.Len(t, index.Search(ParseQuery("engine"), 2), 2)
+}
+
+func TestUpdateIncremental(t *testing.T) {
+	outputDir := t.TempDir()
+	outputtreetest.WriteTopic(t, outputDir, "66_100.json", []data.PostMetadata{post("1001", "100", "alice", "2019-03-01 10:00:00", "original wording")})
+	outputtreetest.WriteTopic(t, outputDir, "66_101.json", []data.PostMetadata{post("1101", "101", "bob", "2019-03-01 10:00:00", "untouched topic")})
+
+	index := New()
+	_, err := index.Update(outputDir, nil)
+	require.NoError(t, err)
+	indexPath := filepath.Join(t.TempDir(), "search", "index.gob.gz")
+	require.NoError(t, index.Save(indexPath))
+
+	// Topic 100 is re-extracted with different text, topic 101 disappears, topic 102 is new
+	outputtreetest.WriteTopic(t, outputDir, "66_100.json", []data.PostMetadata{post("1001", "100", "alice", "2019-03-01 10:00:00", "corrected wording")})
+	require.NoError(t, os.Remove(filepath.Join(outputDir, "66_101.json")))
+	outputtreetest.WriteTopic(t, outputDir, "66_102.json", []data.PostMetadata{post("1201", "102", "carol", "2019-03-02 10:00:00", "fresh wording")})
+
+	index, err = Load(indexPath)
+	require.NoError(t, err)
+	stats, err := index.Update(outputDir, map[string]string{})
+	require.NoError(t, err)
+	assert.Equal(t, UpdateStats{Added: 1, Updated: 1, Removed: 1, Posts: 2}, stats)
+
+	assert.Empty(t, index.Search(ParseQuery("original"), 0))
+	assert.Empty(t, index.Search(ParseQuery("untouched"), 0))
+	assert.Equal(t, []string{"1001"}, postIDs(index.Search(ParseQuery("corrected"), 0)))
+	assert.Len(t, index.Search(ParseQuery("wording"), 0), 2)
+	assert.NotContains(t, index.Postings, "original", "postings of removed posts are dropped")
+	assert.NotContains(t, index.Postings, "bob")
+
+	// A title from the topic index re-indexes an unchanged file
+	stats, err = index.Update(outputDir, map[string]string{"102": "Welcome thread"})
+	require.NoError(t, err)
+	assert.Equal(t, UpdateStats{Updated: 1, Unchanged: 1, Posts: 1}, stats)
+	assert.Equal(t, []string{"1201"}, postIDs(index.Search(ParseQuery("welcome"), 0)))
+
+	missing, err := Load(filepath.Join(t.TempDir(), "none.gob.gz"))
+	require.NoError(t, err)
+	files, posts, terms := missing.Stats()
+	assert.Zero(t, files+posts+terms)
+}
@@ -0,0 +1,28 @@
+package search
+
+import "unicode"
+
+// Tokenize splits text into lower-case terms: runs of letters and digits. Apostrophes inside a
+// word are dropped, so "don't" and "dont" are the same term.
+func Tokenize(text string) []string {
+	var terms []string
+	var term []rune
+	runes := []rune(text)
+	for i, r := range runes {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			term = append(term, unicode.ToLower(r))
+		case (r == '\'' || r == '’') && len(term) > 0 && i+1 < len(runes) && unicode.IsLetter(runes[i+1]):
+			// Apostrophe within a word
+		default:
+			if len(term) > 0 {
+				terms = append(terms, string(term))
+				term = term[:0]
+			}
+		}
+	}
+	if len(term) > 0 {
+		terms = append(terms, string(term))
+	}
+	return terms
+}
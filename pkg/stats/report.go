@@ -0,0 +1,343 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+
+	"project-waypoint/pkg/outputtree"
+)
+
+// WriteJSON writes the report as indented JSON.
+func WriteJSON(w io.Writer, report *Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("failed to write statistics: %w", err)
+	}
+	return nil
+}
+
+// WriteMarkdown writes the report as Markdown, with ASCII bar charts: the archive overview, a
+// table of sub-forums, then the details of the archive and of each sub-forum. Posts per month are
+// left to the HTML and JSON reports.
+func WriteMarkdown(w io.Writer, report *Report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Archive statistics\n\nGenerated %s.\n\n", report.GeneratedAt.Format("2006-01-02 15:04 MST"))
+	writeOverview(&b, report.Archive)
+
+	if len(report.SubForums) > 0 {
+		fmt.Fprintf(&b, "## Sub-forums\n\n")
+		fmt.Fprintf(&b, "| Sub-forum | Topics | Posts | Authors | First post | Last post | Median thread | Quotes per post |\n")
+		fmt.Fprintf(&b, "|---|--:|--:|--:|---|---|--:|--:|\n")
+		for _, s := range report.SubForums {
+			fmt.Fprintf(&b, "| %s | %d | %d | %d | %s | %s | %s | %.2f |\n", markdownCell(sectionName(s)), s.Topics, s.Posts, s.Authors,
+				dateOf(s.FirstPost), dateOf(s.LastPost), number(s.ThreadLength.Median), s.Quotes.PerPost)
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "## Whole archive\n\n")
+	writeDetails(&b, report.Archive)
+	for _, s := range report.SubForums {
+		fmt.Fprintf(&b, "## %s\n\n", sectionName(s))
+		writeOverview(&b, s)
+		writeDetails(&b, s)
+	}
+	_, err := io.WriteString(w, b.String())
+	if err != nil {
+		return fmt.Errorf("failed to write statistics: %w", err)
+	}
+	return nil
+}
+
+func writeOverview(b *strings.Builder, s Section) {
+	fmt.Fprintf(b, "| | |\n|---|--:|\n")
+	fmt.Fprintf(b, "| Topics indexed | %d |\n", s.IndexedTopics)
+	if s.IndexedPosts != nil {
+		fmt.Fprintf(b, "| Posts indexed (replies + 1) | %d |\n", *s.IndexedPosts)
+	} else {
+		fmt.Fprintf(b, "| Topics indexed without a reply count | %d |\n", s.TopicsWithoutReplies)
+	}
+	fmt.Fprintf(b, "| Views | %d |\n", s.Views)
+	fmt.Fprintf(b, "| Topics extracted | %d |\n", s.Topics)
+	fmt.Fprintf(b, "| Posts extracted | %d |\n", s.Posts)
+	fmt.Fprintf(b, "| Authors | %d |\n", s.Authors)
+	fmt.Fprintf(b, "| First post | %s |\n", dateOf(s.FirstPost))
+	fmt.Fprintf(b, "| Last post | %s |\n", dateOf(s.LastPost))
+	if s.UndatedPosts > 0 {
+		fmt.Fprintf(b, "| Posts without a timestamp | %d |\n", s.UndatedPosts)
+	}
+	b.WriteString("\n")
+}
+
+func writeDetails(b *strings.Builder, s Section) {
+	if s.Posts == 0 {
+		fmt.Fprintf(b, "Nothing extracted.\n\n")
+		return
+	}
+	fmt.Fprintf(b, "### Posts per year\n\n```\n%s```\n\n", asciiBars(s.PostsByYear))
+
+	fmt.Fprintf(b, "### Most active authors\n\n| Author | Posts |\n|---|--:|\n")
+	for _, author := range s.TopAuthors {
+		fmt.Fprintf(b, "| %s | %d |\n", markdownCell(author.Key), author.Count)
+	}
+	fmt.Fprintf(b, "\n### Longest threads\n\n| Topic | Posts | First post | Last post |\n|---|--:|---|---|\n")
+	for _, thread := range s.LongestThreads {
+		fmt.Fprintf(b, "| %s | %d | %s | %s |\n", markdownCell(threadName(thread)), thread.Posts, dateOf(thread.FirstPost), dateOf(thread.LastPost))
+	}
+
+	d := s.ThreadLength
+	fmt.Fprintf(b, "\n### Thread length\n\n| Topics | Min | Median | Mean | P90 | Max |\n|--:|--:|--:|--:|--:|--:|\n")
+	fmt.Fprintf(b, "| %d | %s | %s | %s | %s | %s |\n\n", d.Count, number(d.Min), number(d.Median), number(d.Mean), number(d.P90), number(d.Max))
+
+	fmt.Fprintf(b, "### Reply latency\n\n| | Measured | Median | Mean | P90 |\n|---|--:|--:|--:|--:|\n")
+	for _, row := range []struct {
+		name    string
+		latency Latency
+	}{{"Between consecutive posts", s.ReplyLatency}, {"To the first reply", s.FirstReply}} {
+		d := row.latency.Seconds
+		fmt.Fprintf(b, "| %s | %d | %s | %s | %s |\n", row.name, d.Count, duration(d.Median), duration(d.Mean), duration(d.P90))
+	}
+	fmt.Fprintf(b, "\nBetween consecutive posts:\n\n```\n%s```\n\n", asciiBars(s.ReplyLatency.Buckets))
+
+	q := s.Quotes
+	fmt.Fprintf(b, "### Quotes\n\n%d quotes in %d posts: %.2f quotes per post, %.1f%% of posts quote.\n\n", q.Quotes, q.PostsWithQuotes, q.PerPost, q.PostShare*100)
+}
+
+// asciiBars draws counts as a horizontal bar chart, the longest bar 40 characters wide.
+func asciiBars(counts []Count) string {
+	const width = 40
+	labelWidth, most := 0, 0
+	for _, count := range counts {
+		labelWidth = max(labelWidth, len(count.Key))
+		most = max(most, count.Count)
+	}
+	var b strings.Builder
+	for _, count := range counts {
+		bar := 0
+		if most > 0 {
+			bar = int(math.Round(float64(count.Count) * width / float64(most)))
+		}
+		if bar == 0 && count.Count > 0 {
+			bar = 1
+		}
+		fmt.Fprintf(&b, "%-*s %s %d\n", labelWidth, count.Key, strings.Repeat("#", bar), count.Count)
+	}
+	return b.String()
+}
+
+// svgBars draws counts as a horizontal bar chart.
+func svgBars(counts []Count) template.HTML {
+	const rowHeight, labelWidth, barWidth = 20, 110, 360
+	most := 0
+	for _, count := range counts {
+		most = max(most, count.Count)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" width="%d" height="%d" xmlns="http://www.w3.org/2000/svg">`, labelWidth+barWidth+70, rowHeight*len(counts))
+	for i, count := range counts {
+		width := 0.0
+		if most > 0 {
+			width = float64(count.Count) * barWidth / float64(most)
+		}
+		y := i * rowHeight
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, labelWidth-6, y+14, html.EscapeString(count.Key))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d"></rect>`, labelWidth, y+3, width, rowHeight-6)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d">%d</text>`, float64(labelWidth)+width+4, y+14, count.Count)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// svgColumns draws counts as a column chart, for long series such as posts per month; every
+// column has a tooltip and the first column of each year is labelled.
+func svgColumns(counts []Count) template.HTML {
+	const height, axis, maxWidth = 160, 18, 720
+	most := 0
+	for _, count := range counts {
+		most = max(most, count.Count)
+	}
+	columnWidth := 12.0
+	if len(counts) > 0 {
+		columnWidth = math.Min(columnWidth, float64(maxWidth)/float64(len(counts)))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" width="%.0f" height="%d" xmlns="http://www.w3.org/2000/svg">`, columnWidth*float64(len(counts))+30, height+axis)
+	year := ""
+	for i, count := range counts {
+		columnHeight := 0.0
+		if most > 0 {
+			columnHeight = float64(count.Count) * height / float64(most)
+		}
+		x := float64(i) * columnWidth
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f"><title>%s: %d</title></rect>`,
+			x, height-columnHeight, math.Max(columnWidth-1, 0.5), columnHeight, html.EscapeString(count.Key), count.Count)
+		if label, _, _ := strings.Cut(count.Key, "-"); label != year {
+			year = label
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d">%s</text>`, x, height+axis-4, html.EscapeString(label))
+		}
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// WriteHTML writes the report as a standalone HTML page with SVG charts.
+func WriteHTML(w io.Writer, report *Report) error {
+	if err := reportTemplate.Execute(w, report); err != nil {
+		return fmt.Errorf("failed to write statistics: %w", err)
+	}
+	return nil
+}
+
+var reportTemplate = template.Must(template.New("stats").Funcs(template.FuncMap{
+	"bars":     svgBars,
+	"columns":  svgColumns,
+	"date":     dateOf,
+	"duration": duration,
+	"number":   number,
+	"percent":  func(share float64) string { return fmt.Sprintf("%.1f%%", share*100) },
+	"name":     sectionName,
+	"thread":   threadName,
+}).Parse(htmlTemplate))
+
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Archive statistics - Waypoint Archive</title>
+<style>
+body { font-family: sans-serif; margin: 0 auto; max-width: 60em; padding: 1em; color: #222; }
+a { color: #1a5490; }
+table { border-collapse: collapse; margin: 0.5em 0 1em; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; }
+td.n, th.n { text-align: right; }
+.chart rect { fill: #1a5490; }
+.chart text { font-size: 11px; fill: #444; }
+.muted { color: #777; font-size: 0.9em; }
+section { border-top: 2px solid #ddd; margin-top: 2em; }
+</style>
+</head>
+<body>
+<h1>Archive statistics</h1>
+<p class="muted">Generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}.</p>
+{{template "overview" .Archive}}
+{{if .Archive.PostsByMonth}}<h3>Posts per month</h3>
+{{columns .Archive.PostsByMonth}}{{end}}
+{{if .SubForums}}<h2>Sub-forums</h2>
+<table>
+<tr><th>Sub-forum</th><th class="n">Topics</th><th class="n">Posts</th><th class="n">Authors</th><th>First post</th><th>Last post</th><th class="n">Median thread</th><th class="n">Quotes per post</th></tr>
+{{range .SubForums}}<tr><td><a href="#subforum-{{.SubForumID}}">{{name .}}</a></td><td class="n">{{.Topics}}</td><td class="n">{{.Posts}}</td><td class="n">{{.Authors}}</td>
+<td>{{date .FirstPost}}</td><td>{{date .LastPost}}</td><td class="n">{{number .ThreadLength.Median}}</td><td class="n">{{printf "%.2f" .Quotes.PerPost}}</td></tr>
+{{end}}</table>{{end}}
+<section><h2>Whole archive</h2>
+{{template "details" .Archive}}</section>
+{{range .SubForums}}<section id="subforum-{{.SubForumID}}"><h2>{{name .}}</h2>
+{{template "overview" .}}
+{{if .PostsByMonth}}<h3>Posts per month</h3>
+{{columns .PostsByMonth}}{{end}}
+{{template "details" .}}</section>
+{{end}}
+</body>
+</html>
+{{/* Sections */ -}}
+
+{{define "overview"}}<table>
+<tr><td>Topics indexed</td><td class="n">{{.IndexedTopics}}</td></tr>
+{{with .IndexedPosts}}<tr><td>Posts indexed (replies + 1)</td><td class="n">{{.}}</td></tr>
+{{else}}<tr><td>Topics indexed without a reply count</td><td class="n">{{.TopicsWithoutReplies}}</td></tr>
+{{end}}<tr><td>Views</td><td class="n">{{.Views}}</td></tr>
+<tr><td>Topics extracted</td><td class="n">{{.Topics}}</td></tr>
+<tr><td>Posts extracted</td><td class="n">{{.Posts}}</td></tr>
+<tr><td>Authors</td><td class="n">{{.Authors}}</td></tr>
+<tr><td>First post</td><td class="n">{{date .FirstPost}}</td></tr>
+<tr><td>Last post</td><td class="n">{{date .LastPost}}</td></tr>
+{{if .UndatedPosts}}<tr><td>Posts without a timestamp</td><td class="n">{{.UndatedPosts}}</td></tr>{{end}}
+</table>{{end -}}
+
+{{define "details"}}{{if not .Posts}}<p>Nothing extracted.</p>{{else}}
+<h3>Posts per year</h3>
+{{bars .PostsByYear}}
+<h3>Most active authors</h3>
+<table>
+<tr><th>Author</th><th class="n">Posts</th></tr>
+{{range .TopAuthors}}<tr><td>{{.Key}}</td><td class="n">{{.Count}}</td></tr>
+{{end}}</table>
+<h3>Longest threads</h3>
+<table>
+<tr><th>Topic</th><th class="n">Posts</th><th>First post</th><th>Last post</th></tr>
+{{range .LongestThreads}}<tr><td>{{thread .}}</td><td class="n">{{.Posts}}</td><td>{{date .FirstPost}}</td><td>{{date .LastPost}}</td></tr>
+{{end}}</table>
+<h3>Thread length</h3>
+{{with .ThreadLength}}<table>
+<tr><th class="n">Topics</th><th class="n">Min</th><th class="n">Median</th><th class="n">Mean</th><th class="n">P90</th><th class="n">Max</th></tr>
+<tr><td class="n">{{.Count}}</td><td class="n">{{number .Min}}</td><td class="n">{{number .Median}}</td><td class="n">{{number .Mean}}</td><td class="n">{{number .P90}}</td><td class="n">{{number .Max}}</td></tr>
+</table>{{end}}
+<h3>Reply latency</h3>
+<table>
+<tr><th></th><th class="n">Measured</th><th class="n">Median</th><th class="n">Mean</th><th class="n">P90</th></tr>
+{{with .ReplyLatency.Seconds}}<tr><td>Between consecutive posts</td><td class="n">{{.Count}}</td><td class="n">{{duration .Median}}</td><td class="n">{{duration .Mean}}</td><td class="n">{{duration .P90}}</td></tr>{{end}}
+{{with .FirstReply.Seconds}}<tr><td>To the first reply</td><td class="n">{{.Count}}</td><td class="n">{{duration .Median}}</td><td class="n">{{duration .Mean}}</td><td class="n">{{duration .P90}}</td></tr>{{end}}
+</table>
+{{bars .ReplyLatency.Buckets}}
+<h3>Quotes</h3>
+<p>{{.Quotes.Quotes}} quotes in {{.Quotes.PostsWithQuotes}} posts: {{printf "%.2f" .Quotes.PerPost}} quotes per post, {{percent .Quotes.PostShare}} of posts quote.</p>
+{{end}}{{end -}}
+`
+
+func sectionName(s Section) string {
+	switch {
+	case s.SubForumID == "":
+		return "Whole archive"
+	case s.Name != "":
+		return fmt.Sprintf("%s (sub-forum %s)", s.Name, s.SubForumID)
+	default:
+		return "Sub-forum " + s.SubForumID
+	}
+}
+
+func threadName(thread Thread) string {
+	if thread.Title != "" {
+		return fmt.Sprintf("%s (topic %s)", thread.Title, thread.TopicID)
+	}
+	return "Topic " + thread.TopicID
+}
+
+// markdownCell escapes text for a Markdown table cell.
+func markdownCell(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	return strings.ReplaceAll(text, "|", `\|`)
+}
+
+// dateOf returns the date part of a "YYYY-MM-DD HH:MM:SS" timestamp, or "-" if there is none.
+func dateOf(timestamp string) string {
+	return outputtree.DateOf(timestamp, "-")
+}
+
+// number formats a statistic without decimals when it is whole.
+func number(value float64) string {
+	if value == math.Trunc(value) {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.1f", value)
+}
+
+// duration formats seconds in the largest unit that keeps the number readable.
+func duration(seconds float64) string {
+	switch {
+	case seconds < 60:
+		return fmt.Sprintf("%.0fs", seconds)
+	case seconds < 3600:
+		return fmt.Sprintf("%.0fm", seconds/60)
+	case seconds < 48*3600:
+		return fmt.Sprintf("%.1fh", seconds/3600)
+	case seconds < 365*24*3600:
+		return fmt.Sprintf("%.1fd", seconds/(24*3600))
+	default:
+		return fmt.Sprintf("%.1fy", seconds/(365*24*3600))
+	}
+}
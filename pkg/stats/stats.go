@@ -0,0 +1,359 @@
+// Package stats computes archive-wide and per sub-forum statistics from the topic index and the
+// extraction output: how many topics and posts there are, how activity spread over the years,
+// who posted most, how long threads run, how quickly replies came and how much posts quote.
+// Reports are written as Markdown, HTML with SVG charts, or JSON.
+package stats
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/users"
+	"project-waypoint/pkg/viewer"
+
+	"waypoint_archive_scripts/pkg/pageplan"
+)
+
+// DefaultTop is how many authors and threads a section lists by default.
+const DefaultTop = 10
+
+// timestampLayout is the layout of PostMetadata.Timestamp.
+const timestampLayout = "2006-01-02 15:04:05"
+
+// LatencyBuckets are the reply latency ranges, by upper bound.
+var LatencyBuckets = []struct {
+	Label string
+	Below time.Duration // Zero for the last, open-ended bucket
+}{
+	{"< 1 hour", time.Hour},
+	{"1-6 hours", 6 * time.Hour},
+	{"6-24 hours", 24 * time.Hour},
+	{"1-7 days", 7 * 24 * time.Hour},
+	{"1-4 weeks", 28 * 24 * time.Hour},
+	{"1-12 months", 365 * 24 * time.Hour},
+	{"> 1 year", 0},
+}
+
+// Options configures Compute.
+type Options struct {
+	OutputDir        string // Extraction output, {subforum_id}_{topic_id}.json
+	TopicIndexDir    string // Topic index JSON files; may be empty
+	SubForumListPath string // Sub-forum list (CSV or JSON), for sub-forum names; may be empty
+	Top              int    // Authors and threads listed per section; 0 uses DefaultTop
+}
+
+// Count is a count under a key: a year, a month, an author or a latency bucket.
+type Count struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// Thread is a topic with its extracted post count.
+type Thread struct {
+	SubForumID string `json:"subforum_id"`
+	TopicID    string `json:"topic_id"`
+	Title      string `json:"title,omitempty"`
+	Posts      int    `json:"posts"`
+	FirstPost  string `json:"first_post,omitempty"`
+	LastPost   string `json:"last_post,omitempty"`
+}
+
+// Distribution summarises a set of values.
+type Distribution struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	Mean   float64 `json:"mean"`
+	P90    float64 `json:"p90"`
+	Max    float64 `json:"max"`
+}
+
+// Latency is the distribution of the time between posts, in seconds, with counts per
+// LatencyBuckets range.
+type Latency struct {
+	Seconds Distribution `json:"seconds"`
+	Buckets []Count      `json:"buckets"`
+}
+
+// Quotes measures quoting.
+type Quotes struct {
+	Quotes          int     `json:"quotes"`            // Quote blocks
+	PostsWithQuotes int     `json:"posts_with_quotes"` // Posts with at least one quote block
+	PerPost         float64 `json:"per_post"`          // Quote blocks per post
+	PostShare       float64 `json:"post_share"`        // Share of posts that quote, 0 to 1
+}
+
+// Section is the statistics of the whole archive or of one sub-forum.
+type Section struct {
+	SubForumID           string       `json:"subforum_id,omitempty"` // Empty for the whole archive
+	Name                 string       `json:"name,omitempty"`
+	IndexedTopics        int          `json:"indexed_topics"`
+	IndexedPosts         *int         `json:"indexed_posts,omitempty"`          // Replies+1 of every indexed topic; nil if one lacks a reply count
+	TopicsWithoutReplies int          `json:"topics_without_replies,omitempty"` // Indexed topics without a reply count
+	Views                int          `json:"views"`                            // Views of every indexed topic
+	Topics               int          `json:"topics"`                           // Extracted topics
+	Posts                int          `json:"posts"`                            // Extracted posts
+	UndatedPosts         int          `json:"undated_posts"`                    // Posts without a readable timestamp
+	Authors              int          `json:"authors"`
+	FirstPost            string       `json:"first_post,omitempty"`
+	LastPost             string       `json:"last_post,omitempty"`
+	PostsByYear          []Count      `json:"posts_by_year"`
+	PostsByMonth         []Count      `json:"posts_by_month"`
+	TopAuthors           []Count      `json:"top_authors"`
+	LongestThreads       []Thread     `json:"longest_threads"`
+	ThreadLength         Distribution `json:"thread_length"` // Posts per extracted topic
+	ReplyLatency         Latency      `json:"reply_latency"` // Time from each post to the next in its topic
+	FirstReply           Latency      `json:"first_reply"`   // Time from each topic's first post to the second
+	Quotes               Quotes       `json:"quotes"`
+}
+
+// Report is the statistics of the archive and of each of its sub-forums.
+type Report struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Archive     Section   `json:"archive"`
+	SubForums   []Section `json:"subforums"`
+}
+
+// tally accumulates a section.
+type tally struct {
+	section       Section
+	byYear        map[string]int
+	byMonth       map[string]int
+	authors       map[string]int // User key to posts
+	threads       []Thread
+	threadLengths []float64
+	latencies     []float64
+	firstReplies  []float64
+	indexedPosts  int // Replies+1 of the indexed topics with a reply count
+}
+
+func newTally(id string, name string) *tally {
+	return &tally{
+		section: Section{SubForumID: id, Name: name},
+		byYear:  make(map[string]int),
+		byMonth: make(map[string]int),
+		authors: make(map[string]int),
+	}
+}
+
+// Compute reads the topic index and every extracted topic and returns their statistics.
+func Compute(options Options) (*Report, error) {
+	if options.Top <= 0 {
+		options.Top = DefaultTop
+	}
+	archive, err := viewer.Load(viewer.Config{
+		OutputDir:        options.OutputDir,
+		TopicIndexDir:    options.TopicIndexDir,
+		SubForumListPath: options.SubForumListPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the archive: %w", err)
+	}
+
+	spellings := make(map[string]map[string]int) // User key to author spellings to posts
+	total := newTally("", "")
+	report := &Report{GeneratedAt: time.Now().UTC()}
+	var subForums []*tally
+	for _, subForum := range archive.SubForums() {
+		_, topics, _ := archive.SubForum(subForum.ID)
+		t := newTally(subForum.ID, subForum.Name)
+		for _, topic := range topics {
+			posts, err := archive.Posts(topic)
+			if err != nil {
+				return nil, err
+			}
+			for _, into := range []*tally{t, total} {
+				into.addTopic(topic, posts)
+			}
+			for _, post := range posts {
+				if key := users.Key(post.AuthorUsername); key != "" {
+					if spellings[key] == nil {
+						spellings[key] = make(map[string]int)
+					}
+					spellings[key][post.AuthorUsername]++
+				}
+			}
+		}
+		subForums = append(subForums, t)
+	}
+
+	report.Archive = total.finish(options.Top, spellings)
+	for _, t := range subForums {
+		report.SubForums = append(report.SubForums, t.finish(options.Top, spellings))
+	}
+	log.Printf("[INFO] STATS: %d topics and %d posts extracted in %d sub-forums", report.Archive.Topics, report.Archive.Posts, len(report.SubForums))
+	return report, nil
+}
+
+// addTopic counts a topic and its posts, in page and post order.
+func (t *tally) addTopic(topic *viewer.Topic, posts []data.PostMetadata) {
+	s := &t.section
+	if topic.InIndex {
+		s.IndexedTopics++
+		if pageplan.CountsLookStale(topic.Topic) {
+			s.TopicsWithoutReplies++ // Indexed without counts, e.g. by an older indexer
+		} else {
+			t.indexedPosts += topic.Replies + 1
+		}
+		s.Views += topic.Views
+	}
+	if len(posts) == 0 {
+		return
+	}
+	s.Topics++
+	thread := Thread{SubForumID: topic.SubForumID, TopicID: topic.ID, Title: topic.Title, Posts: len(posts)}
+
+	var previous time.Time
+	for i, post := range posts {
+		s.Posts++
+		if key := users.Key(post.AuthorUsername); key != "" {
+			t.authors[key]++
+		}
+		quotes := 0
+		for _, block := range post.ParsedContent {
+			if block.Type == data.ContentBlockTypeQuote {
+				quotes++
+			}
+		}
+		s.Quotes.Quotes += quotes
+		if quotes > 0 {
+			s.Quotes.PostsWithQuotes++
+		}
+
+		timestamp, err := time.Parse(timestampLayout, post.Timestamp)
+		if err != nil {
+			s.UndatedPosts++
+			previous = time.Time{}
+			continue
+		}
+		t.byYear[timestamp.Format("2006")]++
+		t.byMonth[timestamp.Format("2006-01")]++
+		if s.FirstPost == "" || post.Timestamp < s.FirstPost {
+			s.FirstPost = post.Timestamp
+		}
+		if post.Timestamp > s.LastPost {
+			s.LastPost = post.Timestamp
+		}
+		if thread.FirstPost == "" || post.Timestamp < thread.FirstPost {
+			thread.FirstPost = post.Timestamp
+		}
+		if post.Timestamp > thread.LastPost {
+			thread.LastPost = post.Timestamp
+		}
+		// Replies are measured between consecutive dated posts; a clock gone backwards is skipped
+		if !previous.IsZero() && !timestamp.Before(previous) {
+			latency := timestamp.Sub(previous).Seconds()
+			t.latencies = append(t.latencies, latency)
+			if i == 1 {
+				t.firstReplies = append(t.firstReplies, latency)
+			}
+		}
+		previous = timestamp
+	}
+	t.threads = append(t.threads, thread)
+	t.threadLengths = append(t.threadLengths, float64(len(posts)))
+}
+
+// finish sorts and summarises what the tally accumulated. Authors are named by their most
+// frequent spelling across the archive.
+func (t *tally) finish(top int, spellings map[string]map[string]int) Section {
+	s := t.section
+	if s.TopicsWithoutReplies == 0 {
+		indexedPosts := t.indexedPosts
+		s.IndexedPosts = &indexedPosts
+	}
+	s.PostsByYear = sortedCounts(t.byYear)
+	s.PostsByMonth = sortedCounts(t.byMonth)
+	s.Authors = len(t.authors)
+
+	authors := make([]Count, 0, len(t.authors))
+	for key, posts := range t.authors {
+		authors = append(authors, Count{Key: key, Count: posts})
+	}
+	sort.Slice(authors, func(i, j int) bool {
+		if authors[i].Count != authors[j].Count {
+			return authors[i].Count > authors[j].Count
+		}
+		return authors[i].Key < authors[j].Key
+	})
+	s.TopAuthors = authors[:min(top, len(authors))]
+	for i := range s.TopAuthors {
+		s.TopAuthors[i].Key = name(s.TopAuthors[i].Key, spellings)
+	}
+
+	sort.SliceStable(t.threads, func(i, j int) bool { return t.threads[i].Posts > t.threads[j].Posts })
+	s.LongestThreads = t.threads[:min(top, len(t.threads))]
+	s.ThreadLength = distribution(t.threadLengths)
+	s.ReplyLatency = latency(t.latencies)
+	s.FirstReply = latency(t.firstReplies)
+	if s.Posts > 0 {
+		s.Quotes.PerPost = float64(s.Quotes.Quotes) / float64(s.Posts)
+		s.Quotes.PostShare = float64(s.Quotes.PostsWithQuotes) / float64(s.Posts)
+	}
+	return s
+}
+
+// name returns the most frequent spelling of a user key.
+func name(key string, spellings map[string]map[string]int) string {
+	best, bestCount := key, 0
+	for spelling, count := range spellings[key] {
+		if count > bestCount || (count == bestCount && spelling < best) {
+			best, bestCount = spelling, count
+		}
+	}
+	return best
+}
+
+func sortedCounts(counts map[string]int) []Count {
+	sorted := make([]Count, 0, len(counts))
+	for key, count := range counts {
+		sorted = append(sorted, Count{Key: key, Count: count})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	return sorted
+}
+
+// distribution summarises values; the median of an even number of values is the mean of the two
+// middle ones, and P90 is the nearest-rank 90th percentile.
+func distribution(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	d := Distribution{Count: len(sorted), Min: sorted[0], Max: sorted[len(sorted)-1]}
+	sum := 0.0
+	for _, value := range sorted {
+		sum += value
+	}
+	d.Mean = sum / float64(len(sorted))
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		d.Median = (sorted[middle-1] + sorted[middle]) / 2
+	} else {
+		d.Median = sorted[middle]
+	}
+	rank := (len(sorted)*90 + 99) / 100
+	d.P90 = sorted[max(rank, 1)-1]
+	return d
+}
+
+func latency(seconds []float64) Latency {
+	l := Latency{Seconds: distribution(seconds), Buckets: make([]Count, len(LatencyBuckets))}
+	for i, bucket := range LatencyBuckets {
+		l.Buckets[i].Key = bucket.Label
+	}
+	for _, value := range seconds {
+		i := 0
+		for ; i < len(LatencyBuckets)-1; i++ {
+			if value < LatencyBuckets[i].Below.Seconds() {
+				break
+			}
+		}
+		l.Buckets[i].Count++
+	}
+	return l
+}
@@ -0,0 +1,157 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree/outputtreetest"
+	"project-waypoint/pkg/viewer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	wdata "waypoint_archive_scripts/pkg/data"
+)
+
+func computeFixture(t *testing.T) *Report {
+	t.Helper()
+	dir := t.TempDir()
+	options := Options{
+		OutputDir:        filepath.Join(dir, "output"),
+		TopicIndexDir:    filepath.Join(dir, "index"),
+		SubForumListPath: filepath.Join(dir, "subforum_list.csv"),
+		Top:              2,
+	}
+	outputtreetest.WriteFile(t, filepath.Join(options.TopicIndexDir, "forum_66", "topic_index_66.json"),
+		`[{"ID":"100","Title":"Card tricks","Replies":3,"Views":50},{"ID":"101","Title":"Coin tricks","Replies":1,"Views":10},{"ID":"102","Title":"Rope tricks","Views":5}]`)
+	outputtreetest.WriteFile(t, options.SubForumListPath, "SubForumID,SubForumName,SubForumURL\n66,Close-up Magic,https://forum.example/viewforum.php?f=66\n")
+
+	quote := data.ContentBlock{Type: data.ContentBlockTypeQuote, QuotedUser: "alice"}
+	outputtreetest.WriteTopic(t, options.OutputDir, "66_100.json", []data.PostMetadata{
+		{TopicID: "100", SubForumID: "66", PageNumber: 2, AuthorUsername: "Alice", Timestamp: "2020-01-10 10:00:00"},
+		{TopicID: "100", SubForumID: "66", PageNumber: 1, AuthorUsername: "alice", Timestamp: "2019-12-31 10:00:00"},
+		{TopicID: "100", SubForumID: "66", PageNumber: 1, PostOrderOnPage: 1, AuthorUsername: "bob", Timestamp: "2019-12-31 10:30:00",
+			ParsedContent: []data.ContentBlock{quote, quote}},
+		{TopicID: "100", SubForumID: "66", PageNumber: 1, PostOrderOnPage: 2, AuthorUsername: "carol", Timestamp: "2019-12-31 13:30:00",
+			ParsedContent: []data.ContentBlock{quote}},
+	})
+	outputtreetest.WriteTopic(t, options.OutputDir, "66_101.json", []data.PostMetadata{
+		{TopicID: "101", SubForumID: "66", PageNumber: 1, AuthorUsername: "bob", Timestamp: "2018-05-01 09:00:00"},
+		{TopicID: "101", SubForumID: "66", PageNumber: 1, PostOrderOnPage: 1, AuthorUsername: "alice", Timestamp: "not a date"},
+	})
+	// Not in the index
+	outputtreetest.WriteTopic(t, options.OutputDir, "54_300.json", []data.PostMetadata{
+		{TopicID: "300", SubForumID: "54", PageNumber: 1, AuthorUsername: "dave", Timestamp: "2021-07-04 12:00:00"},
+	})
+
+	report, err := Compute(options)
+	require.NoError(t, err)
+	return report
+}
+
+func TestCompute(t *testing.T) {
+	report := computeFixture(t)
+
+	archive := report.Archive
+	assert.Equal(t, 3, archive.IndexedTopics)
+	assert.Nil(t, archive.IndexedPosts, "topic 102 has no reply count, so the posts indexed are not known")
+	assert.Equal(t, 1, archive.TopicsWithoutReplies)
+	assert.Equal(t, 65, archive.Views)
+	assert.Equal(t, 3, archive.Topics)
+	assert.Equal(t, 7, archive.Posts)
+	assert.Equal(t, 1, archive.UndatedPosts)
+	assert.Equal(t, 4, archive.Authors, "alice and Alice are one author")
+	assert.Equal(t, "2018-05-01 09:00:00", archive.FirstPost)
+	assert.Equal(t, "2021-07-04 12:00:00", archive.LastPost)
+	assert.Equal(t, []Count{{"2018", 1}, {"2019", 3}, {"2020", 1}, {"2021", 1}}, archive.PostsByYear)
+	assert.Equal(t, Count{"2019-12", 3}, archive.PostsByMonth[1])
+	assert.Equal(t, []Count{{"alice", 3}, {"bob", 2}}, archive.TopAuthors)
+
+	require.Len(t, archive.LongestThreads, 2)
+	assert.Equal(t, Thread{SubForumID: "66", TopicID: "100", Title: "Card tricks", Posts: 4,
+		FirstPost: "2019-12-31 10:00:00", LastPost: "2020-01-10 10:00:00"}, archive.LongestThreads[0])
+	assert.Equal(t, Distribution{Count: 3, Min: 1, Median: 2, Mean: 7.0 / 3, P90: 4, Max: 4}, archive.ThreadLength)
+
+	// 30 minutes, 3 hours and 10 days apart in topic 100; topic 101's second post is undated
+	assert.Equal(t, 3, archive.ReplyLatency.Seconds.Count)
+	assert.Equal(t, float64(3*3600), archive.ReplyLatency.Seconds.Median)
+	assert.Equal(t, []Count{{"< 1 hour", 1}, {"1-6 hours", 1}, {"6-24 hours", 0}, {"1-7 days", 0}, {"1-4 weeks", 1}, {"1-12 months", 0}, {"> 1 year", 0}},
+		archive.ReplyLatency.Buckets)
+	assert.Equal(t, Distribution{Count: 1, Min: 1800, Median: 1800, Mean: 1800, P90: 1800, Max: 1800}, archive.FirstReply.Seconds)
+
+	assert.Equal(t, Quotes{Quotes: 3, PostsWithQuotes: 2, PerPost: 3.0 / 7, PostShare: 2.0 / 7}, archive.Quotes)
+
+	require.Len(t, report.SubForums, 2)
+	assert.Equal(t, "54", report.SubForums[0].SubForumID)
+	assert.Equal(t, 0, report.SubForums[0].IndexedTopics)
+	require.NotNil(t, report.SubForums[0].IndexedPosts)
+	assert.Equal(t, 0, *report.SubForums[0].IndexedPosts)
+	assert.Equal(t, 1, report.SubForums[0].Posts)
+	closeUp := report.SubForums[1]
+	assert.Equal(t, "Close-up Magic", closeUp.Name)
+	assert.Equal(t, 3, closeUp.IndexedTopics)
+	assert.Equal(t, 2, closeUp.Topics, "the topic without posts is indexed, not extracted")
+	assert.Equal(t, 6, closeUp.Posts)
+}
+
+func TestAddTopic_IndexedPosts(t *testing.T) {
+	indexed := func(id string, replies int, lastPost string) *viewer.Topic {
+		return &viewer.Topic{Topic: wdata.Topic{ID: id, Replies: replies, LastPostTimestampRaw: lastPost}, InIndex: true}
+	}
+	known := newTally("66", "")
+	known.addTopic(indexed("100", 3, ""), nil)
+	known.addTopic(indexed("101", 0, "Jan 10, 2003 11:42 am"), nil) // No replies, but a last post: counted
+	section := known.finish(DefaultTop, nil)
+	require.NotNil(t, section.IndexedPosts)
+	assert.Equal(t, 4+1, *section.IndexedPosts)
+
+	missing := newTally("66", "")
+	missing.addTopic(indexed("100", 3, ""), nil)
+	missing.addTopic(indexed("102", 0, ""), nil)
+	section = missing.finish(DefaultTop, nil)
+	assert.Nil(t, section.IndexedPosts)
+	assert.Equal(t, 1, section.TopicsWithoutReplies)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteMarkdown(&buf, &Report{Archive: section}))
+	assert.Contains(t, buf.String(), "| Topics indexed without a reply count | 1 |")
+	assert.NotContains(t, buf.String(), "Posts indexed")
+}
+
+func TestDistribution(t *testing.T) {
+	assert.Equal(t, Distribution{}, distribution(nil))
+	d := distribution([]float64{10, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	assert.Equal(t, 5.5, d.Median)
+	assert.Equal(t, 9.0, d.P90)
+	assert.Equal(t, 5.5, d.Mean)
+}
+
+func TestWriters(t *testing.T) {
+	report := computeFixture(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteMarkdown(&buf, report))
+	markdown := buf.String()
+	assert.Contains(t, markdown, "| Close-up Magic (sub-forum 66) | 2 | 6 | 3 | 2018-05-01 | 2020-01-10 | 3 | 0.50 |")
+	assert.Contains(t, markdown, "2019 ######################################## 3\n")
+	assert.Contains(t, markdown, "| Card tricks (topic 100) | 4 | 2019-12-31 | 2020-01-10 |")
+	assert.Contains(t, markdown, "| Between consecutive posts | 3 | 3.0h | 3.3d | 9.9d |")
+
+	buf.Reset()
+	require.NoError(t, WriteHTML(&buf, report))
+	page := buf.String()
+	assert.Contains(t, page, `<section id="subforum-66"><h2>Close-up Magic (sub-forum 66)</h2>`)
+	assert.Contains(t, page, `<rect x="12.0" y="0.0" width="11.0" height="160.0"><title>2019-12: 3</title></rect>`)
+	assert.Contains(t, page, `<text x="104" y="34" text-anchor="end">2019</text>`)
+	assert.True(t, strings.HasSuffix(page, "</html>\n"))
+
+	buf.Reset()
+	require.NoError(t, WriteJSON(&buf, report))
+	var decoded Report
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, report.Archive.PostsByYear, decoded.Archive.PostsByYear)
+}
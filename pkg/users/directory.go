@@ -0,0 +1,285 @@
+// Package users builds a directory of the forum's users from the extraction output: for each
+// author, when they posted, where and how much, the topics they started, who they quote and who
+// quotes them, and the spellings their name appears under.
+//
+// Users are identified by their name folded to lower case with runs of whitespace collapsed
+// (Key), so "Steve Smith" and "steve  smith" are one user, with two name variants. Quotes count
+// towards a user when the quoted name folds to the same key.
+package users
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree"
+)
+
+// UnknownYear is the year posts without a timestamp are counted under.
+const UnknownYear = "unknown"
+
+// PostRef identifies a post.
+type PostRef struct {
+	PostID     string `json:"post_id"`
+	TopicID    string `json:"topic_id"`
+	SubForumID string `json:"subforum_id"`
+	Timestamp  string `json:"timestamp"`
+	PostURL    string `json:"post_url,omitempty"`
+}
+
+// TopicRef is a topic a user started.
+type TopicRef struct {
+	TopicID    string `json:"topic_id"`
+	SubForumID string `json:"subforum_id"`
+	Title      string `json:"title,omitempty"`
+	Timestamp  string `json:"timestamp"` // Of the opening post
+}
+
+// NameVariant is a spelling of a user's name and how often it occurs.
+type NameVariant struct {
+	Name   string `json:"name"`
+	Posts  int    `json:"posts"`  // Posts signed with this spelling
+	Quoted int    `json:"quoted"` // Quotes by others naming the user with this spelling
+}
+
+// QuoteCount is how often a user quoted, or was quoted by, another user.
+type QuoteCount struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Profile is a user's activity across the archive.
+type Profile struct {
+	Key             string         `json:"key"`
+	Name            string         `json:"name"` // The most used spelling
+	NameVariants    []NameVariant  `json:"name_variants"`
+	PostCount       int            `json:"post_count"`
+	FirstPost       *PostRef       `json:"first_post,omitempty"` // Earliest timestamped post
+	LastPost        *PostRef       `json:"last_post,omitempty"`
+	PostsBySubForum map[string]int `json:"posts_by_subforum"`
+	PostsByYear     map[string]int `json:"posts_by_year"` // Posts without a timestamp count under UnknownYear
+	TopicsPostedIn  int            `json:"topics_posted_in"`
+	TopicsStarted   []TopicRef     `json:"topics_started"` // Oldest first
+	Quotes          []QuoteCount   `json:"quotes"`         // Users they quote, most quoted first
+	QuotedBy        []QuoteCount   `json:"quoted_by"`      // Users quoting them, most frequent first
+}
+
+// Directory is the set of user profiles built from an extraction output tree.
+type Directory struct {
+	profiles map[string]*Profile // Key to profile
+
+	variants map[string]map[string]*NameVariant // Key to spelling to counts
+	topics   map[string]map[string]bool         // Key to topic IDs posted in
+	quotes   map[string]map[string]int          // Quoting key to quoted key to count
+}
+
+func newDirectory() *Directory {
+	return &Directory{
+		profiles: make(map[string]*Profile),
+		variants: make(map[string]map[string]*NameVariant),
+		topics:   make(map[string]map[string]bool),
+		quotes:   make(map[string]map[string]int),
+	}
+}
+
+// Key identifies the user a name refers to: the name in lower case, with surrounding whitespace
+// removed and inner runs of whitespace collapsed to one space.
+func Key(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// Build reads every output JSON file under outputDir into a new Directory. titles maps topic IDs
+// to titles for the topics users started; it may be nil.
+func Build(outputDir string, titles map[string]string) (*Directory, error) {
+	directory := newDirectory()
+	err := outputtree.WalkPosts(outputDir, func(_ string, posts []data.PostMetadata) error {
+		directory.addTopic(posts, titles)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build user directory from %s: %w", outputDir, err)
+	}
+	directory.finish()
+	return directory, nil
+}
+
+// addTopic adds the posts of one topic's output file. The topic's opening post, the first post on
+// page 1, counts as its author starting the topic.
+func (d *Directory) addTopic(posts []data.PostMetadata, titles map[string]string) {
+	var opening *data.PostMetadata
+	for i := range posts {
+		post := &posts[i]
+		d.addPost(post)
+		if post.PageNumber <= 1 && (opening == nil || post.PostOrderOnPage < opening.PostOrderOnPage) {
+			opening = post
+		}
+	}
+	if opening == nil {
+		return
+	}
+	if profile, ok := d.profiles[Key(opening.AuthorUsername)]; ok {
+		profile.TopicsStarted = append(profile.TopicsStarted, TopicRef{
+			TopicID:    opening.TopicID,
+			SubForumID: opening.SubForumID,
+			Title:      titles[opening.TopicID],
+			Timestamp:  opening.Timestamp,
+		})
+	}
+}
+
+func (d *Directory) addPost(post *data.PostMetadata) {
+	key := Key(post.AuthorUsername)
+	if key == "" {
+		return
+	}
+	profile, ok := d.profiles[key]
+	if !ok {
+		profile = &Profile{Key: key, PostsBySubForum: make(map[string]int), PostsByYear: make(map[string]int)}
+		d.profiles[key] = profile
+		d.topics[key] = make(map[string]bool)
+	}
+	d.variant(key, spelling(post.AuthorUsername)).Posts++
+	profile.PostCount++
+	profile.PostsBySubForum[post.SubForumID]++
+	d.topics[key][post.TopicID] = true
+
+	year := UnknownYear
+	if len(post.Timestamp) >= 4 {
+		year = post.Timestamp[:4]
+		ref := &PostRef{PostID: post.PostID, TopicID: post.TopicID, SubForumID: post.SubForumID, Timestamp: post.Timestamp, PostURL: post.PostURL}
+		if profile.FirstPost == nil || post.Timestamp < profile.FirstPost.Timestamp {
+			profile.FirstPost = ref
+		}
+		if profile.LastPost == nil || post.Timestamp > profile.LastPost.Timestamp {
+			profile.LastPost = ref
+		}
+	}
+	profile.PostsByYear[year]++
+
+	for _, block := range post.ParsedContent {
+		quotedKey := Key(block.QuotedUser)
+		if block.Type != data.ContentBlockTypeQuote || quotedKey == "" || quotedKey == key {
+			continue // Quoting oneself says nothing about who one talks to
+		}
+		if d.quotes[key] == nil {
+			d.quotes[key] = make(map[string]int)
+		}
+		d.quotes[key][quotedKey]++
+		d.variant(quotedKey, spelling(block.QuotedUser)).Quoted++
+	}
+}
+
+// spelling is a name as written, with whitespace tidied.
+func spelling(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+func (d *Directory) variant(key string, name string) *NameVariant {
+	if d.variants[key] == nil {
+		d.variants[key] = make(map[string]*NameVariant)
+	}
+	variant, ok := d.variants[key][name]
+	if !ok {
+		variant = &NameVariant{Name: name}
+		d.variants[key][name] = variant
+	}
+	return variant
+}
+
+// finish fills in the per-user lists once all topics are added.
+func (d *Directory) finish() {
+	quotedBy := make(map[string]map[string]int)
+	for quoting, quoted := range d.quotes {
+		for quotedKey, count := range quoted {
+			if quotedBy[quotedKey] == nil {
+				quotedBy[quotedKey] = make(map[string]int)
+			}
+			quotedBy[quotedKey][quoting] = count
+		}
+	}
+
+	for key, profile := range d.profiles {
+		profile.NameVariants = profile.NameVariants[:0]
+		for _, variant := range d.variants[key] {
+			profile.NameVariants = append(profile.NameVariants, *variant)
+		}
+		sort.Slice(profile.NameVariants, func(i, j int) bool {
+			a, b := profile.NameVariants[i], profile.NameVariants[j]
+			if a.Posts != b.Posts {
+				return a.Posts > b.Posts
+			}
+			if a.Quoted != b.Quoted {
+				return a.Quoted > b.Quoted
+			}
+			return a.Name < b.Name
+		})
+		profile.Name = profile.NameVariants[0].Name
+		profile.TopicsPostedIn = len(d.topics[key])
+		sort.Slice(profile.TopicsStarted, func(i, j int) bool {
+			a, b := profile.TopicsStarted[i], profile.TopicsStarted[j]
+			if a.Timestamp != b.Timestamp {
+				return a.Timestamp < b.Timestamp
+			}
+			return a.TopicID < b.TopicID
+		})
+	}
+	for key, profile := range d.profiles {
+		profile.Quotes = d.quoteCounts(d.quotes[key])
+		profile.QuotedBy = d.quoteCounts(quotedBy[key])
+	}
+}
+
+// quoteCounts lists counts by user key, most frequent first, named by each user's main spelling.
+func (d *Directory) quoteCounts(counts map[string]int) []QuoteCount {
+	list := make([]QuoteCount, 0, len(counts))
+	for key, count := range counts {
+		name := key
+		if profile, ok := d.profiles[key]; ok {
+			name = profile.Name
+		} else if variants := d.variants[key]; len(variants) > 0 {
+			// Quoted but never posted in the archive: the most quoted spelling
+			best := NameVariant{}
+			for _, variant := range variants {
+				if variant.Quoted > best.Quoted || (variant.Quoted == best.Quoted && (best.Name == "" || variant.Name < best.Name)) {
+					best = *variant
+				}
+			}
+			name = best.Name
+		}
+		list = append(list, QuoteCount{Key: key, Name: name, Count: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Key < list[j].Key
+	})
+	return list
+}
+
+// Profile returns the profile of the user a name refers to.
+func (d *Directory) Profile(name string) (*Profile, bool) {
+	profile, ok := d.profiles[Key(name)]
+	return profile, ok
+}
+
+// Profiles returns all profiles, most posts first.
+func (d *Directory) Profiles() []*Profile {
+	profiles := make([]*Profile, 0, len(d.profiles))
+	for _, profile := range d.profiles {
+		profiles = append(profiles, profile)
+	}
+	sortProfiles(profiles)
+	return profiles
+}
+
+func sortProfiles(profiles []*Profile) {
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].PostCount != profiles[j].PostCount {
+			return profiles[i].PostCount > profiles[j].PostCount
+		}
+		return profiles[i].Key < profiles[j].Key
+	})
+}
@@ -0,0 +1,100 @@
+package users
+
+import (
+	"path/filepath"
+	"testing"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree/outputtreetest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func quote(user string) data.ContentBlock {
+	return data.ContentBlock{Type: data.ContentBlockTypeQuote, QuotedUser: user, QuotedText: "..."}
+}
+
+// writeOutput writes two topics: 100, started by Dai Vernon, and 200, started by Slydini, whose
+// opening page was not extracted.
+func writeOutput(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	outputtreetest.WriteTopic(t, dir, "66_100.json", []data.PostMetadata{
+		{PostID: "2", TopicID: "100", SubForumID: "66", PageNumber: 1, PostOrderOnPage: 1, AuthorUsername: "Slydini", Timestamp: "2004-05-02 10:00:00",
+			ParsedContent: []data.ContentBlock{quote("dai vernon"), quote("dai  Vernon"), quote("Slydini")}},
+		{PostID: "1", TopicID: "100", SubForumID: "66", PageNumber: 1, PostOrderOnPage: 0, AuthorUsername: "Dai Vernon", Timestamp: "2003-11-30 09:00:00",
+			PostURL: "https://forum.example/viewtopic.php?p=1"},
+		{PostID: "3", TopicID: "100", SubForumID: "66", PageNumber: 2, AuthorUsername: "dai vernon", Timestamp: "2005-01-01 12:00:00",
+			ParsedContent: []data.ContentBlock{quote("Slydini"), quote("Houdini")}},
+	})
+	outputtreetest.WriteTopic(t, dir, "12_200.json", []data.PostMetadata{
+		{PostID: "5", TopicID: "200", SubForumID: "12", PageNumber: 2, AuthorUsername: "Slydini", Timestamp: ""},
+		{PostID: "4", TopicID: "200", SubForumID: "12", PageNumber: 2, AuthorUsername: "Dai Vernon", Timestamp: "2003-01-01 08:00:00"},
+	})
+	outputtreetest.WriteFile(t, filepath.Join(dir, "notes.txt"), "not output")
+	return dir
+}
+
+func TestBuild(t *testing.T) {
+	directory, err := Build(writeOutput(t), map[string]string{"100": "The Trick That Fooled Houdini"})
+	require.NoError(t, err)
+
+	profiles := directory.Profiles()
+	require.Len(t, profiles, 2)
+	vernon := profiles[0]
+	assert.Equal(t, "dai vernon", vernon.Key)
+	assert.Equal(t, "Dai Vernon", vernon.Name)
+	assert.Equal(t, []NameVariant{{Name: "Dai Vernon", Posts: 2}, {Name: "dai vernon", Posts: 1, Quoted: 1}, {Name: "dai Vernon", Quoted: 1}}, vernon.NameVariants)
+	assert.Equal(t, 3, vernon.PostCount)
+	assert.Equal(t, "4", vernon.FirstPost.PostID)
+	assert.Equal(t, "3", vernon.LastPost.PostID)
+	assert.Equal(t, map[string]int{"66": 2, "12": 1}, vernon.PostsBySubForum)
+	assert.Equal(t, map[string]int{"2003": 2, "2005": 1}, vernon.PostsByYear)
+	assert.Equal(t, 2, vernon.TopicsPostedIn)
+	assert.Equal(t, []TopicRef{{TopicID: "100", SubForumID: "66", Title: "The Trick That Fooled Houdini", Timestamp: "2003-11-30 09:00:00"}}, vernon.TopicsStarted)
+	assert.Equal(t, []QuoteCount{{Key: "houdini", Name: "Houdini", Count: 1}, {Key: "slydini", Name: "Slydini", Count: 1}}, vernon.Quotes)
+	assert.Equal(t, []QuoteCount{{Key: "slydini", Name: "Slydini", Count: 2}}, vernon.QuotedBy)
+
+	slydini, ok := directory.Profile(" SLYDINI ")
+	require.True(t, ok)
+	assert.Equal(t, map[string]int{"2004": 1, UnknownYear: 1}, slydini.PostsByYear)
+	assert.Empty(t, slydini.TopicsStarted, "topic 200's opening page was not extracted")
+	assert.Equal(t, []QuoteCount{{Key: "dai vernon", Name: "Dai Vernon", Count: 2}}, slydini.Quotes, "self-quotes are not counted")
+	assert.Equal(t, "2004-05-02 10:00:00", slydini.LastPost.Timestamp)
+}
+
+func TestWriteAndLoad(t *testing.T) {
+	directory, err := Build(writeOutput(t), nil)
+	require.NoError(t, err)
+	dir := filepath.Join(t.TempDir(), "users")
+	require.NoError(t, directory.Write(dir))
+
+	rows, err := ReadSummary(dir)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, SummaryRow{
+		Name: "Dai Vernon", Key: "dai vernon", PostCount: 3, FirstPost: "2003-01-01 08:00:00", LastPost: "2005-01-01 12:00:00",
+		TopicsStarted: 1, TopicsPostedIn: 2, SubForums: 2, NameVariants: []string{"Dai Vernon", "dai vernon", "dai Vernon"}, File: FileName("dai vernon"),
+	}, rows[0])
+
+	profile, ok, err := LoadProfile(dir, "DAI VERNON")
+	require.NoError(t, err)
+	require.True(t, ok)
+	expected, _ := directory.Profile("Dai Vernon")
+	assert.Equal(t, expected, profile)
+
+	_, ok, err = LoadProfile(dir, "Houdini")
+	require.NoError(t, err)
+	assert.False(t, ok, "only authors get profiles")
+
+	assert.Equal(t, []SummaryRow{rows[0]}, Match(rows, "vern"))
+	assert.Len(t, Match(rows, "i"), 2)
+}
+
+func TestFileName(t *testing.T) {
+	assert.Equal(t, "slydini.json", FileName("slydini"))
+	assert.NotEqual(t, FileName("dai vernon"), FileName("dai_vernon"), "sanitised names stay distinct")
+	assert.Regexp(t, `^dai_vernon-[0-9a-f]{8}\.json$`, FileName("dai vernon"))
+	assert.Regexp(t, `^_\.\._-[0-9a-f]{8}\.json$`, FileName("/../"))
+}
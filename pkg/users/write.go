@@ -0,0 +1,183 @@
+package users
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SummaryFile is the name of the summary CSV in a written directory.
+const SummaryFile = "users.csv"
+
+// summaryHeader are the columns of the summary CSV.
+var summaryHeader = []string{"name", "key", "post_count", "first_post", "last_post", "topics_started", "topics_posted_in", "subforums", "name_variants", "file"}
+
+// SummaryRow is a user's line in the summary CSV.
+type SummaryRow struct {
+	Name           string
+	Key            string
+	PostCount      int
+	FirstPost      string // Timestamp
+	LastPost       string
+	TopicsStarted  int
+	TopicsPostedIn int
+	SubForums      int
+	NameVariants   []string
+	File           string // Profile JSON, relative to the directory
+}
+
+// FileName is the name of a user's profile JSON. Keys that are not safe as file names are
+// sanitised and suffixed with a digest of the key, so no two users share a file.
+func FileName(key string) string {
+	var name strings.Builder
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.' && name.Len() > 0:
+			name.WriteRune(r)
+		default:
+			name.WriteRune('_')
+		}
+	}
+	if name.String() != key {
+		sum := sha256.Sum256([]byte(key))
+		name.WriteString("-" + hex.EncodeToString(sum[:4]))
+	}
+	return name.String() + ".json"
+}
+
+// Write writes a JSON profile per user and the summary CSV to dir, creating dir if missing.
+func (d *Directory) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create user directory %s: %w", dir, err)
+	}
+
+	file, err := os.Create(filepath.Join(dir, SummaryFile))
+	if err != nil {
+		return fmt.Errorf("failed to create user summary: %w", err)
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	if err := writer.Write(summaryHeader); err != nil {
+		return fmt.Errorf("failed to write user summary: %w", err)
+	}
+
+	for _, profile := range d.Profiles() {
+		content, err := json.MarshalIndent(profile, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal profile of %s: %w", profile.Name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, FileName(profile.Key)), content, 0644); err != nil {
+			return fmt.Errorf("failed to write profile of %s: %w", profile.Name, err)
+		}
+		if err := writer.Write(summaryRow(profile).record()); err != nil {
+			return fmt.Errorf("failed to write user summary: %w", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write user summary: %w", err)
+	}
+	return file.Close()
+}
+
+func summaryRow(profile *Profile) SummaryRow {
+	row := SummaryRow{
+		Name:           profile.Name,
+		Key:            profile.Key,
+		PostCount:      profile.PostCount,
+		TopicsStarted:  len(profile.TopicsStarted),
+		TopicsPostedIn: profile.TopicsPostedIn,
+		SubForums:      len(profile.PostsBySubForum),
+		File:           FileName(profile.Key),
+	}
+	if profile.FirstPost != nil {
+		row.FirstPost = profile.FirstPost.Timestamp
+		row.LastPost = profile.LastPost.Timestamp
+	}
+	for _, variant := range profile.NameVariants {
+		row.NameVariants = append(row.NameVariants, variant.Name)
+	}
+	return row
+}
+
+func (row SummaryRow) record() []string {
+	return []string{row.Name, row.Key, strconv.Itoa(row.PostCount), row.FirstPost, row.LastPost,
+		strconv.Itoa(row.TopicsStarted), strconv.Itoa(row.TopicsPostedIn), strconv.Itoa(row.SubForums),
+		strings.Join(row.NameVariants, "|"), row.File}
+}
+
+// ReadSummary reads the summary CSV of a written directory.
+func ReadSummary(dir string) ([]SummaryRow, error) {
+	file, err := os.Open(filepath.Join(dir, SummaryFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open user summary: %w", err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user summary %s: %w", file.Name(), err)
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(summaryHeader, ",") {
+		return nil, fmt.Errorf("user summary %s does not start with the expected header", file.Name())
+	}
+
+	rows := make([]SummaryRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		numbers := make([]int, 4)
+		for j, column := range []int{2, 5, 6, 7} {
+			if numbers[j], err = strconv.Atoi(record[column]); err != nil {
+				return nil, fmt.Errorf("user summary %s, line %d: invalid %s %q", file.Name(), i+2, summaryHeader[column], record[column])
+			}
+		}
+		row := SummaryRow{
+			Name: record[0], Key: record[1], PostCount: numbers[0], FirstPost: record[3], LastPost: record[4],
+			TopicsStarted: numbers[1], TopicsPostedIn: numbers[2], SubForums: numbers[3], File: record[9],
+		}
+		if record[8] != "" {
+			row.NameVariants = strings.Split(record[8], "|")
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// LoadProfile reads the profile of the user a name refers to from a written directory. It
+// reports false if there is no such user.
+func LoadProfile(dir string, name string) (*Profile, bool, error) {
+	content, err := os.ReadFile(filepath.Join(dir, FileName(Key(name))))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to read profile of %s: %w", name, err)
+	}
+	var profile Profile
+	if err := json.Unmarshal(content, &profile); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal profile of %s: %w", name, err)
+	}
+	return &profile, true, nil
+}
+
+// Match returns the summary rows of users any of whose name variants contain text, ignoring
+// case, most posts first.
+func Match(rows []SummaryRow, text string) []SummaryRow {
+	text = Key(text)
+	var matches []SummaryRow
+	for _, row := range rows {
+		for _, variant := range append([]string{row.Key}, row.NameVariants...) {
+			if strings.Contains(Key(variant), text) {
+				matches = append(matches, row)
+				break
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].PostCount > matches[j].PostCount })
+	return matches
+}
@@ -0,0 +1,328 @@
+// Package viewer is a read-only browser over the archive: sub-forums and topics from the topic
+// index, the archiver's progress state, the raw HTML pages and the extracted posts. NewServer
+// serves it over HTTP for people who do not want to read JSON files.
+package viewer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"project-waypoint/pkg/completeness"
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree"
+
+	wdata "waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/indexerlogic"
+	"waypoint_archive_scripts/pkg/state"
+)
+
+// Config locates the parts of the archive. Any part may be empty or missing (e.g. nothing has
+// been extracted yet); the viewer shows what there is.
+type Config struct {
+	OutputDir        string // Extracted posts, {subforum_id}_{topic_id}.json as written by ProcessTopic
+	ArchiveDir       string // Raw HTML archive, {subforum_id}/{topic_id}/page_N.html
+	TopicIndexDir    string // Topic index JSON files (see completeness.LoadTopicIndex)
+	SubForumListPath string // Sub-forum list, CSV (ID, name, URL) or JSON, for sub-forum names
+	StatePath        string // The archiver's progress state (state.ArchiveProgressState)
+}
+
+// TopicStatus is the archive status of a topic according to the archiver's state.
+type TopicStatus string
+
+const (
+	StatusArchived    TopicStatus = "archived"     // Marked archived by the archiver
+	StatusPartial     TopicStatus = "partial"      // Some pages archived, the topic not finished
+	StatusNotArchived TopicStatus = "not archived" // Not in the archiver's state
+)
+
+// Topic is a topic as the viewer shows it.
+type Topic struct {
+	wdata.Topic
+	InIndex           bool        // Whether the topic is in the topic index
+	Status            TopicStatus // Archive status from the archiver's state
+	ArchivedAt        time.Time   // When the archiver finished the topic
+	ArchivedPages     int         // Pages recorded in the archiver's state
+	PageFiles         []int       // Page numbers found in the raw archive
+	ArchiveSubForumID string      // Sub-forum directory the raw pages are stored under
+	OutputFile        string      // Extracted posts, "" if the topic has not been extracted
+}
+
+// SubForum is a sub-forum with counts of its topics.
+type SubForum struct {
+	ID        string
+	Name      string
+	Topics    int
+	Archived  int
+	Extracted int
+}
+
+// Archive is a snapshot of the archive, loaded by Load.
+type Archive struct {
+	config     Config
+	LoadedAt   time.Time
+	subForums  []SubForum
+	topics     map[string]*Topic
+	bySubForum map[string][]*Topic // Topics of each sub-forum in topic index order
+}
+
+// Load reads the topic index, sub-forum list and archiver state and scans the raw archive and
+// output directories.
+func Load(config Config) (*Archive, error) {
+	archive := &Archive{
+		config:     config,
+		LoadedAt:   time.Now(),
+		topics:     make(map[string]*Topic),
+		bySubForum: make(map[string][]*Topic),
+	}
+	names := make(map[string]string)
+
+	if exists(config.TopicIndexDir) {
+		topics, err := completeness.LoadTopicIndex(config.TopicIndexDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, indexed := range topics {
+			if _, ok := archive.topics[indexed.ID]; ok {
+				continue // Listed in more than one index file; the first listing wins
+			}
+			archive.addTopic(&Topic{Topic: indexed, InIndex: true})
+		}
+	}
+
+	if config.SubForumListPath != "" {
+		var err error
+		names, err = loadSubForumNames(config.SubForumListPath)
+		if err != nil {
+			log.Printf("[WARNING] VIEWER: Sub-forum names not available: %v", err)
+			names = make(map[string]string)
+		}
+	}
+
+	if exists(config.ArchiveDir) {
+		if err := archive.scanRawArchive(); err != nil {
+			return nil, err
+		}
+	}
+	if exists(config.OutputDir) {
+		if err := archive.scanOutput(); err != nil {
+			return nil, err
+		}
+	}
+
+	progress := state.NewArchiveProgressState()
+	if config.StatePath != "" {
+		var err error
+		if progress, err = state.LoadState(config.StatePath); err != nil {
+			return nil, err
+		}
+	}
+	for _, topic := range archive.topics {
+		topic.Status = StatusNotArchived
+		detail, ok := progress.ArchivedTopics[topic.ID]
+		if !ok {
+			continue
+		}
+		topic.ArchivedPages = len(detail.ArchivedPages)
+		topic.ArchivedAt = detail.ArchivedAt
+		if detail.ArchivedAt.IsZero() {
+			topic.Status = StatusPartial
+		} else {
+			topic.Status = StatusArchived
+		}
+	}
+
+	for id, topics := range archive.bySubForum {
+		subForum := SubForum{ID: id, Name: names[id], Topics: len(topics)}
+		for _, topic := range topics {
+			if topic.Status == StatusArchived {
+				subForum.Archived++
+			}
+			if topic.OutputFile != "" {
+				subForum.Extracted++
+			}
+		}
+		archive.subForums = append(archive.subForums, subForum)
+	}
+	sort.Slice(archive.subForums, func(i, j int) bool {
+		return completeness.IDLess(archive.subForums[i].ID, archive.subForums[j].ID)
+	})
+	return archive, nil
+}
+
+// exists reports whether a configured path exists, logging configured paths that do not.
+func exists(path string) bool {
+	if path == "" {
+		return false
+	}
+	if _, err := os.Stat(path); err != nil {
+		log.Printf("[WARNING] VIEWER: %s is not available: %v", path, err)
+		return false
+	}
+	return true
+}
+
+// addTopic adds a topic to the archive and its sub-forum.
+func (a *Archive) addTopic(topic *Topic) {
+	a.topics[topic.ID] = topic
+	a.bySubForum[topic.SubForumID] = append(a.bySubForum[topic.SubForumID], topic)
+}
+
+// topic returns the topic with the given ID, adding it to subForumID if it is not in the index.
+func (a *Archive) topic(id string, subForumID string) *Topic {
+	if topic, ok := a.topics[id]; ok {
+		return topic
+	}
+	topic := &Topic{Topic: wdata.Topic{ID: id, SubForumID: subForumID}}
+	a.addTopic(topic)
+	return topic
+}
+
+// scanRawArchive records the page files of every topic directory in the raw archive.
+func (a *Archive) scanRawArchive() error {
+	subForumDirs, err := os.ReadDir(a.config.ArchiveDir)
+	if err != nil {
+		return fmt.Errorf("failed to read archive directory %s: %w", a.config.ArchiveDir, err)
+	}
+	for _, subForumDir := range subForumDirs {
+		if !subForumDir.IsDir() {
+			continue
+		}
+		topicDirs, err := os.ReadDir(filepath.Join(a.config.ArchiveDir, subForumDir.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read archive directory %s: %w", subForumDir.Name(), err)
+		}
+		for _, topicDir := range topicDirs {
+			if !topicDir.IsDir() {
+				continue
+			}
+			pageEntries, err := os.ReadDir(filepath.Join(a.config.ArchiveDir, subForumDir.Name(), topicDir.Name()))
+			if err != nil {
+				return fmt.Errorf("failed to read topic directory %s/%s: %w", subForumDir.Name(), topicDir.Name(), err)
+			}
+			var pages []int
+			for _, pageEntry := range pageEntries {
+				if page, ok := pageNumber(pageEntry.Name()); ok && !pageEntry.IsDir() {
+					pages = append(pages, page)
+				}
+			}
+			if len(pages) == 0 {
+				continue
+			}
+			sort.Ints(pages)
+			topic := a.topic(topicDir.Name(), subForumDir.Name())
+			topic.PageFiles = pages
+			topic.ArchiveSubForumID = subForumDir.Name()
+		}
+	}
+	return nil
+}
+
+// scanOutput records the output file of every extracted topic.
+func (a *Archive) scanOutput() error {
+	entries, err := os.ReadDir(a.config.OutputDir)
+	if err != nil {
+		return fmt.Errorf("failed to read output directory %s: %w", a.config.OutputDir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		subForumID, topicID, ok := strings.Cut(strings.TrimSuffix(name, ".json"), "_")
+		if !ok || topicID == "" {
+			continue
+		}
+		topic := a.topic(topicID, subForumID)
+		if topic.OutputFile == "" || subForumID == topic.SubForumID {
+			topic.OutputFile = name
+		}
+	}
+	return nil
+}
+
+// SubForums returns every sub-forum with topics, by ID.
+func (a *Archive) SubForums() []SubForum {
+	return a.subForums
+}
+
+// SubForum returns a sub-forum and its topics.
+func (a *Archive) SubForum(id string) (SubForum, []*Topic, bool) {
+	for _, subForum := range a.subForums {
+		if subForum.ID == id {
+			return subForum, a.bySubForum[id], true
+		}
+	}
+	return SubForum{}, nil, false
+}
+
+// Topic returns the topic with the given ID.
+func (a *Archive) Topic(id string) (*Topic, bool) {
+	topic, ok := a.topics[id]
+	return topic, ok
+}
+
+// Posts reads the extracted posts of a topic, in page and post order.
+func (a *Archive) Posts(topic *Topic) ([]data.PostMetadata, error) {
+	if topic.OutputFile == "" {
+		return nil, nil
+	}
+	posts, err := outputtree.Read(filepath.Join(a.config.OutputDir, topic.OutputFile))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		if posts[i].PageNumber != posts[j].PageNumber {
+			return posts[i].PageNumber < posts[j].PageNumber
+		}
+		return posts[i].PostOrderOnPage < posts[j].PostOrderOnPage
+	})
+	return posts, nil
+}
+
+// RawPagePath returns the file of a raw archived page of a topic, or "" if it was not archived.
+func (a *Archive) RawPagePath(topic *Topic, page int) string {
+	for _, pageFile := range topic.PageFiles {
+		if pageFile == page {
+			return filepath.Join(a.config.ArchiveDir, topic.ArchiveSubForumID, topic.ID, fmt.Sprintf("page_%d.html", page))
+		}
+	}
+	return ""
+}
+
+// loadSubForumNames reads sub-forum names from a CSV or JSON sub-forum list.
+func loadSubForumNames(path string) (map[string]string, error) {
+	names := make(map[string]string)
+	if filepath.Ext(path) == ".json" {
+		subForums, err := indexerlogic.ReadSubForumListJSON(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, subForum := range subForums {
+			names[subForum.ID] = subForum.Name
+		}
+		return names, nil
+	}
+	subForums, err := indexerlogic.ReadSubForumListCSV(path)
+	if err != nil {
+		return nil, err
+	}
+	for id, subForum := range subForums {
+		names[id] = subForum.Name
+	}
+	return names, nil
+}
+
+// pageNumber parses a raw page file name, page_N.html.
+func pageNumber(name string) (int, bool) {
+	if !strings.HasPrefix(name, "page_") || !strings.HasSuffix(name, ".html") {
+		return 0, false
+	}
+	page, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "page_"), ".html"))
+	return page, err == nil && page > 0
+}
@@ -0,0 +1,304 @@
+package viewer
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"sort"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree"
+)
+
+// TopicsPerPage is the number of topics on a page of a sub-forum's topic list.
+const TopicsPerPage = 50
+
+// Links builds the URLs of the viewer's pages, so the same pages can be served or written out.
+type Links struct {
+	Home     func() string
+	SubForum func(id string, page int) string
+	Topic    func(id string, page int) string
+	RawPage  func(topic *Topic, page int) string // "" if the page cannot be linked
+}
+
+// ServerLinks are the URLs of the pages served by NewServer.
+func ServerLinks() Links {
+	return Links{
+		Home: func() string { return "/" },
+		SubForum: func(id string, page int) string {
+			return withPage("/f/"+url.PathEscape(id), page)
+		},
+		Topic: func(id string, page int) string {
+			return withPage("/t/"+url.PathEscape(id), page)
+		},
+		RawPage: func(topic *Topic, page int) string {
+			return fmt.Sprintf("/raw/%s/%d", url.PathEscape(topic.ID), page)
+		},
+	}
+}
+
+func withPage(path string, page int) string {
+	if page <= 1 {
+		return path
+	}
+	return fmt.Sprintf("%s?page=%d", path, page)
+}
+
+// Pager is the position in a paginated list.
+type Pager struct {
+	Page  int
+	Pages int
+	Prev  string // Link to the previous page, "" on the first
+	Next  string // Link to the next page, "" on the last
+}
+
+func newPager(page, pages int, link func(page int) string) Pager {
+	pager := Pager{Page: page, Pages: pages}
+	if page > 1 {
+		pager.Prev = link(page - 1)
+	}
+	if page < pages {
+		pager.Next = link(page + 1)
+	}
+	return pager
+}
+
+// Renderer renders the viewer's pages as HTML.
+type Renderer struct {
+	links     Links
+	templates *template.Template
+}
+
+// NewRenderer creates a Renderer whose pages link to each other with links.
+func NewRenderer(links Links) *Renderer {
+	funcs := template.FuncMap{
+		"subForumURL": links.SubForum,
+		"topicURL":    links.Topic,
+		"rawURL":      links.RawPage,
+		"homeURL":     links.Home,
+	}
+	return &Renderer{
+		links:     links,
+		templates: template.Must(template.New("viewer").Funcs(funcs).Parse(pageTemplates)),
+	}
+}
+
+type homePage struct {
+	SubForums []SubForum
+	LoadedAt  string
+}
+
+// RenderHome renders the list of sub-forums.
+func (r *Renderer) RenderHome(w io.Writer, archive *Archive) error {
+	return r.templates.ExecuteTemplate(w, "home", homePage{
+		SubForums: archive.SubForums(),
+		LoadedAt:  archive.LoadedAt.Format("2006-01-02 15:04:05"),
+	})
+}
+
+type subForumPage struct {
+	SubForum SubForum
+	Topics   []*Topic
+	Pager    Pager
+}
+
+// RenderSubForum renders a page of a sub-forum's topic list, TopicsPerPage topics to a page.
+// page is clamped to the available pages.
+func (r *Renderer) RenderSubForum(w io.Writer, subForum SubForum, topics []*Topic, page int) error {
+	pages := max(1, (len(topics)+TopicsPerPage-1)/TopicsPerPage)
+	page = min(max(page, 1), pages)
+	start := (page - 1) * TopicsPerPage
+	end := min(start+TopicsPerPage, len(topics))
+	return r.templates.ExecuteTemplate(w, "subforum", subForumPage{
+		SubForum: subForum,
+		Topics:   topics[start:end],
+		Pager:    newPager(page, pages, func(p int) string { return r.links.SubForum(subForum.ID, p) }),
+	})
+}
+
+type blockView struct {
+	Quote           bool
+	Text            string
+	QuotedUser      string
+	QuotedTimestamp string
+}
+
+type postView struct {
+	data.PostMetadata
+	Blocks []blockView
+	RawURL string
+}
+
+type topicPage struct {
+	Topic    *Topic
+	SubForum SubForum
+	Page     int
+	Posts    []postView
+	Pager    Pager
+}
+
+// TopicPages returns the page numbers of a topic: those with extracted posts or archived files.
+func TopicPages(topic *Topic, posts []data.PostMetadata) []int {
+	seen := make(map[int]bool)
+	var pages []int
+	add := func(page int) {
+		if !seen[page] {
+			seen[page] = true
+			pages = append(pages, page)
+		}
+	}
+	for _, post := range posts {
+		add(post.PageNumber)
+	}
+	for _, page := range topic.PageFiles {
+		add(page)
+	}
+	sort.Ints(pages)
+	return pages
+}
+
+// RenderTopic renders the posts of one page of a topic (a page of the forum, as archived). page
+// is the position in TopicPages and is clamped to the available pages.
+func (r *Renderer) RenderTopic(w io.Writer, subForum SubForum, topic *Topic, posts []data.PostMetadata, page int) error {
+	pageNumbers := TopicPages(topic, posts)
+	pages := max(1, len(pageNumbers))
+	page = min(max(page, 1), pages)
+	view := topicPage{
+		Topic:    topic,
+		SubForum: subForum,
+		Page:     page,
+		Pager:    newPager(page, pages, func(p int) string { return r.links.Topic(topic.ID, p) }),
+	}
+	if len(pageNumbers) > 0 {
+		view.Page = pageNumbers[page-1]
+	}
+	for _, post := range posts {
+		if post.PageNumber != view.Page {
+			continue
+		}
+		postView := postView{PostMetadata: post, RawURL: r.links.RawPage(topic, post.PageNumber)}
+		for _, block := range post.ParsedContent {
+			switch block.Type {
+			case data.ContentBlockTypeQuote:
+				postView.Blocks = append(postView.Blocks, blockView{
+					Quote:           true,
+					Text:            outputtree.Paragraphs(block.QuotedText),
+					QuotedUser:      block.QuotedUser,
+					QuotedTimestamp: block.QuotedTimestamp,
+				})
+			default:
+				postView.Blocks = append(postView.Blocks, blockView{Text: outputtree.Paragraphs(block.Content)})
+			}
+		}
+		view.Posts = append(view.Posts, postView)
+	}
+	return r.templates.ExecuteTemplate(w, "topic", view)
+}
+
+// RenderError renders an error page.
+func (r *Renderer) RenderError(w io.Writer, message string) error {
+	return r.templates.ExecuteTemplate(w, "error", message)
+}
+
+const pageTemplates = `
+{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.}} - Waypoint Archive</title>
+<style>
+body { font-family: sans-serif; margin: 0 auto; max-width: 60em; padding: 1em; color: #222; }
+a { color: #1a5490; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; }
+.status { font-size: 0.85em; padding: 0.1em 0.4em; border-radius: 0.3em; white-space: nowrap; }
+.status-archived { background: #d7f0d7; }
+.status-partial { background: #fbeac0; }
+.status-not-archived { background: #eee; }
+.post { border: 1px solid #ddd; border-radius: 0.3em; margin: 1em 0; }
+.post-header { background: #f4f4f4; padding: 0.4em 0.6em; font-size: 0.9em; }
+.post-body { padding: 0.6em; }
+.text { white-space: pre-wrap; margin: 0.4em 0; }
+blockquote { border-left: 3px solid #bbb; margin: 0.4em 0; padding: 0.2em 0.8em; background: #fafafa; }
+.quote-header { font-size: 0.85em; color: #555; }
+.pager { margin: 1em 0; }
+.muted { color: #777; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<p class="muted"><a href="{{homeURL}}">Waypoint Archive</a></p>
+{{end}}
+
+{{define "foot"}}</body>
+</html>
+{{end}}
+
+{{define "pager"}}{{if gt .Pages 1}}<p class="pager">
+{{if .Prev}}<a href="{{.Prev}}">&larr; Previous</a>{{end}}
+Page {{.Page}} of {{.Pages}}
+{{if .Next}}<a href="{{.Next}}">Next &rarr;</a>{{end}}
+</p>{{end}}{{end}}
+
+{{define "status"}}<span class="status status-{{if eq . "archived"}}archived{{else if eq . "partial"}}partial{{else}}not-archived{{end}}">{{.}}</span>{{end}}
+
+{{define "home"}}{{template "head" "Sub-forums"}}
+<h1>Sub-forums</h1>
+{{if .SubForums}}<table>
+<tr><th>Sub-forum</th><th>Topics</th><th>Archived</th><th>Extracted</th></tr>
+{{range .SubForums}}<tr>
+<td><a href="{{subForumURL .ID 1}}">{{if .Name}}{{.Name}}{{else}}Sub-forum {{.ID}}{{end}}</a></td>
+<td>{{.Topics}}</td><td>{{.Archived}}</td><td>{{.Extracted}}</td>
+</tr>
+{{end}}</table>{{else}}<p>Nothing has been indexed, archived or extracted yet.</p>{{end}}
+<p class="muted">Loaded {{.LoadedAt}}.</p>
+{{template "foot"}}{{end}}
+
+{{define "subforum"}}{{template "head" (or .SubForum.Name (printf "Sub-forum %s" .SubForum.ID))}}
+<h1>{{if .SubForum.Name}}{{.SubForum.Name}}{{else}}Sub-forum {{.SubForum.ID}}{{end}}</h1>
+<p class="muted">{{.SubForum.Topics}} topics, {{.SubForum.Archived}} archived, {{.SubForum.Extracted}} extracted.</p>
+{{template "pager" .Pager}}
+<table>
+<tr><th>Topic</th><th>Author</th><th>Replies</th><th>Archive status</th><th>Extracted</th></tr>
+{{range .Topics}}<tr>
+<td><a href="{{topicURL .ID 1}}">{{if .Title}}{{.Title}}{{else}}Topic {{.ID}}{{end}}</a>{{if not .InIndex}} <span class="muted">(not in topic index)</span>{{end}}</td>
+<td>{{.AuthorUsername}}</td>
+<td>{{if .InIndex}}{{.Replies}}{{end}}</td>
+<td>{{template "status" .Status}}{{if .ArchivedPages}} <span class="muted">{{.ArchivedPages}} pages</span>{{end}}</td>
+<td>{{if .OutputFile}}yes{{else}}no{{end}}</td>
+</tr>
+{{end}}</table>
+{{template "pager" .Pager}}
+{{template "foot"}}{{end}}
+
+{{define "topic"}}{{template "head" (or .Topic.Title (printf "Topic %s" .Topic.ID))}}
+<p class="muted"><a href="{{subForumURL .SubForum.ID 1}}">{{if .SubForum.Name}}{{.SubForum.Name}}{{else}}Sub-forum {{.SubForum.ID}}{{end}}</a></p>
+<h1>{{if .Topic.Title}}{{.Topic.Title}}{{else}}Topic {{.Topic.ID}}{{end}}</h1>
+<p>{{template "status" .Topic.Status}}
+{{if not .Topic.ArchivedAt.IsZero}}Archived {{.Topic.ArchivedAt.Format "2006-01-02 15:04"}} UTC.{{end}}
+{{if .Topic.ArchivedPages}}{{.Topic.ArchivedPages}} pages in the archiver's state.{{end}}
+{{if .Topic.PageFiles}}{{len .Topic.PageFiles}} page files in the archive.{{end}}
+{{if .Topic.InIndex}}{{.Topic.Replies}} replies in the topic index.{{end}}</p>
+{{template "pager" .Pager}}
+{{if not .Topic.OutputFile}}<p>This topic has not been extracted yet.{{with rawURL .Topic .Page}} <a href="{{.}}">View the archived page</a>.{{end}}</p>
+{{else if not .Posts}}<p>No posts were extracted from page {{.Page}}.{{with rawURL .Topic .Page}} <a href="{{.}}">View the archived page</a>.{{end}}</p>{{end}}
+{{range .Posts}}<div class="post" id="p{{.PostID}}">
+<div class="post-header"><strong>{{.AuthorUsername}}</strong> &middot; {{.Timestamp}}
+&middot; {{if .RawURL}}<a href="{{.RawURL}}#p{{.PostID}}">archived page</a>{{end}}
+{{if .PostURL}}&middot; <a href="{{.PostURL}}">original post</a>{{end}}</div>
+<div class="post-body">
+{{range .Blocks}}{{if .Quote}}<blockquote>
+{{if .QuotedUser}}<div class="quote-header">{{.QuotedUser}} wrote{{if .QuotedTimestamp}} ({{.QuotedTimestamp}}){{end}}:</div>{{end}}
+<div class="text">{{.Text}}</div>
+</blockquote>{{else}}<div class="text">{{.Text}}</div>{{end}}
+{{end}}</div>
+</div>
+{{end}}
+{{template "pager" .Pager}}
+{{template "foot"}}{{end}}
+
+{{define "error"}}{{template "head" "Not found"}}
+<h1>Not found</h1>
+<p>{{.}}</p>
+{{template "foot"}}{{end}}
+`
@@ -0,0 +1,174 @@
+package viewer
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Server serves the viewer over HTTP. The archive is loaded again in the background when it is
+// older than the refresh interval, so a running archiver's progress shows up without a restart
+// and without holding up requests.
+type Server struct {
+	config   Config
+	refresh  time.Duration
+	renderer *Renderer
+	mux      *http.ServeMux
+
+	archive atomic.Pointer[Archive] // The snapshot requests are served from
+
+	reloadMux   sync.Mutex
+	reloading   bool      // A reload is running
+	attemptedAt time.Time // When the last reload started (or the first load finished)
+}
+
+// NewServer loads the archive and returns a Server for it. A refresh interval of 0 never reloads.
+func NewServer(config Config, refresh time.Duration) (*Server, error) {
+	archive, err := Load(config)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		config:   config,
+		refresh:  refresh,
+		renderer: NewRenderer(ServerLinks()),
+		mux:      http.NewServeMux(),
+	}
+	s.archive.Store(archive)
+	s.attemptedAt = archive.LoadedAt
+	s.mux.HandleFunc("GET /{$}", s.handleHome)
+	s.mux.HandleFunc("GET /f/{id}", s.handleSubForum)
+	s.mux.HandleFunc("GET /t/{id}", s.handleTopic)
+	s.mux.HandleFunc("GET /raw/{id}/{page}", s.handleRawPage)
+	return s, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// currentArchive returns the current snapshot of the archive. If the last load is older than the
+// refresh interval a reload is started in the background; the request is served from the snapshot
+// it already has.
+func (s *Server) currentArchive() *Archive {
+	archive := s.archive.Load()
+	if s.refresh > 0 {
+		s.startReload()
+	}
+	return archive
+}
+
+// startReload starts a reload unless one is running or the last one started within the refresh
+// interval.
+func (s *Server) startReload() {
+	s.reloadMux.Lock()
+	defer s.reloadMux.Unlock()
+	if s.reloading || time.Since(s.attemptedAt) <= s.refresh {
+		return
+	}
+	s.reloading = true
+	s.attemptedAt = time.Now()
+	go s.reload()
+}
+
+// reload loads the archive and swaps it in for the current snapshot. If loading fails the previous
+// snapshot is kept and the next reload is tried after another interval.
+func (s *Server) reload() {
+	defer func() {
+		s.reloadMux.Lock()
+		s.reloading = false
+		s.reloadMux.Unlock()
+	}()
+	archive, err := Load(s.config)
+	if err != nil {
+		log.Printf("[ERROR] VIEWER: Failed to reload the archive, showing the snapshot from %s: %v", s.archive.Load().LoadedAt.Format(time.RFC3339), err)
+		return
+	}
+	s.archive.Store(archive)
+}
+
+func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
+	s.render(w, func(buf *bytes.Buffer) error {
+		return s.renderer.RenderHome(buf, s.currentArchive())
+	})
+}
+
+func (s *Server) handleSubForum(w http.ResponseWriter, r *http.Request) {
+	subForum, topics, ok := s.currentArchive().SubForum(r.PathValue("id"))
+	if !ok {
+		s.notFound(w, fmt.Sprintf("There is no sub-forum %s in the archive.", r.PathValue("id")))
+		return
+	}
+	s.render(w, func(buf *bytes.Buffer) error {
+		return s.renderer.RenderSubForum(buf, subForum, topics, pageParam(r))
+	})
+}
+
+func (s *Server) handleTopic(w http.ResponseWriter, r *http.Request) {
+	archive := s.currentArchive()
+	topic, ok := archive.Topic(r.PathValue("id"))
+	if !ok {
+		s.notFound(w, fmt.Sprintf("There is no topic %s in the archive.", r.PathValue("id")))
+		return
+	}
+	posts, err := archive.Posts(topic)
+	if err != nil {
+		log.Printf("[ERROR] VIEWER: %v", err)
+		http.Error(w, "Failed to read the extracted posts of this topic.", http.StatusInternalServerError)
+		return
+	}
+	subForum, _, _ := archive.SubForum(topic.SubForumID)
+	s.render(w, func(buf *bytes.Buffer) error {
+		return s.renderer.RenderTopic(buf, subForum, topic, posts, pageParam(r))
+	})
+}
+
+// handleRawPage serves an archived page as it was fetched from the forum. Scripts in it are
+// blocked; the forum's stylesheets and images still load if they are reachable.
+func (s *Server) handleRawPage(w http.ResponseWriter, r *http.Request) {
+	archive := s.currentArchive()
+	topic, ok := archive.Topic(r.PathValue("id"))
+	page, err := strconv.Atoi(r.PathValue("page"))
+	if !ok || err != nil || archive.RawPagePath(topic, page) == "" {
+		s.notFound(w, fmt.Sprintf("Page %s of topic %s was not archived.", r.PathValue("page"), r.PathValue("id")))
+		return
+	}
+	w.Header().Set("Content-Security-Policy", "script-src 'none'")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeFile(w, r, archive.RawPagePath(topic, page))
+}
+
+// render buffers a page so a template error can still be reported with a proper status.
+func (s *Server) render(w http.ResponseWriter, render func(buf *bytes.Buffer) error) {
+	var buf bytes.Buffer
+	if err := render(&buf); err != nil {
+		log.Printf("[ERROR] VIEWER: Failed to render page: %v", err)
+		http.Error(w, "Failed to render page.", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+func (s *Server) notFound(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	if err := s.renderer.RenderError(w, message); err != nil {
+		log.Printf("[ERROR] VIEWER: Failed to render error page: %v", err)
+	}
+}
+
+// pageParam returns the page query parameter, 1 if it is missing or invalid.
+func pageParam(r *http.Request) int {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		return 1
+	}
+	return page
+}
@@ -0,0 +1,156 @@
+package viewer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"project-waypoint/pkg/data"
+	"project-waypoint/pkg/outputtree/outputtreetest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"waypoint_archive_scripts/pkg/state"
+)
+
+// writeFixture writes a small archive: topic 100 archived with two pages and extracted, topic 101
+// partly archived, topic 102 only in the index and topic 300 archived but not indexed.
+func writeFixture(t *testing.T) Config {
+	t.Helper()
+	dir := t.TempDir()
+	config := Config{
+		OutputDir:        filepath.Join(dir, "output"),
+		ArchiveDir:       filepath.Join(dir, "archive"),
+		TopicIndexDir:    filepath.Join(dir, "index"),
+		SubForumListPath: filepath.Join(dir, "subforum_list.csv"),
+		StatePath:        filepath.Join(dir, "archive_progress.json"),
+	}
+	outputtreetest.WriteFile(t, filepath.Join(config.TopicIndexDir, "forum_66", "topic_index_66.json"),
+		`[{"ID":"100","Title":"Card tricks","AuthorUsername":"alice","Replies":2},{"ID":"101","Title":"Coin tricks","Replies":40},{"ID":"102","Title":"Rope tricks"}]`)
+	outputtreetest.WriteFile(t, config.SubForumListPath, "SubForumID,SubForumName,SubForumURL\n66,Close-up Magic,https://forum.example/viewforum.php?f=66\n")
+	outputtreetest.WriteFile(t, filepath.Join(config.ArchiveDir, "66", "100", "page_1.html"), `<html><body><div id="p1001">Raw page</div><script>alert(1)</script></body></html>`)
+	outputtreetest.WriteFile(t, filepath.Join(config.ArchiveDir, "66", "100", "page_2.html"), `<html><body>Second page</body></html>`)
+	outputtreetest.WriteFile(t, filepath.Join(config.ArchiveDir, "66", "101", "page_1.html"), `<html></html>`)
+	outputtreetest.WriteFile(t, filepath.Join(config.ArchiveDir, "54", "300", "page_1.html"), `<html></html>`)
+
+	outputtreetest.WriteTopic(t, config.OutputDir, "66_100.json", []data.PostMetadata{
+		{PostID: "1003", TopicID: "100", SubForumID: "66", PageNumber: 2, AuthorUsername: "carol", Timestamp: "2019-03-02 08:00:00",
+			ParsedContent: []data.ContentBlock{{Type: data.ContentBlockTypeNewText, Content: "Last word"}}},
+		{PostID: "1001", TopicID: "100", SubForumID: "66", PageNumber: 1, AuthorUsername: "alice", Timestamp: "2019-03-01 10:00:00",
+			PostURL:       "https://forum.example/viewtopic.php?p=1001#p1001",
+			ParsedContent: []data.ContentBlock{{Type: data.ContentBlockTypeNewText, Content: `<div>First line<br/>second &amp; <b>last</b></div><script>alert(1)</script>`}}},
+		{PostID: "1002", TopicID: "100", SubForumID: "66", PageNumber: 1, PostOrderOnPage: 1, AuthorUsername: "bob", Timestamp: "2019-03-01 11:00:00",
+			ParsedContent: []data.ContentBlock{
+				{Type: data.ContentBlockTypeQuote, QuotedUser: "alice", QuotedTimestamp: "2019-03-01 10:00:00", QuotedText: "First line"},
+				{Type: data.ContentBlockTypeNewText, Content: "Agreed"},
+			}},
+	})
+
+	progress := state.NewArchiveProgressState()
+	progress.MarkPageAsArchived("100", 1, "https://forum.example/viewtopic.php?t=100")
+	progress.MarkPageAsArchived("100", 2, "https://forum.example/viewtopic.php?t=100&start=2")
+	progress.MarkTopicAsArchived("100")
+	progress.MarkPageAsArchived("101", 1, "https://forum.example/viewtopic.php?t=101")
+	require.NoError(t, progress.Save(config.StatePath))
+	return config
+}
+
+func get(t *testing.T, handler http.Handler, path string) (int, http.Header, string) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	body, err := io.ReadAll(recorder.Result().Body)
+	require.NoError(t, err)
+	return recorder.Code, recorder.Header(), string(body)
+}
+
+func TestLoad(t *testing.T) {
+	archive, err := Load(writeFixture(t))
+	require.NoError(t, err)
+
+	assert.Equal(t, []SubForum{
+		{ID: "54", Topics: 1},
+		{ID: "66", Name: "Close-up Magic", Topics: 3, Archived: 1, Extracted: 1},
+	}, archive.SubForums())
+
+	topic, ok := archive.Topic("100")
+	require.True(t, ok)
+	assert.Equal(t, StatusArchived, topic.Status)
+	assert.Equal(t, []int{1, 2}, topic.PageFiles)
+	assert.Equal(t, "66_100.json", topic.OutputFile)
+	posts, err := archive.Posts(topic)
+	require.NoError(t, err)
+	require.Len(t, posts, 3)
+	assert.Equal(t, "1001", posts[0].PostID, "posts are in page order")
+
+	topic, _ = archive.Topic("101")
+	assert.Equal(t, StatusPartial, topic.Status)
+	topic, _ = archive.Topic("102")
+	assert.Equal(t, StatusNotArchived, topic.Status)
+	assert.Empty(t, archive.RawPagePath(topic, 1))
+	topic, _ = archive.Topic("300")
+	assert.False(t, topic.InIndex)
+	assert.Equal(t, "54", topic.SubForumID)
+}
+
+func TestServer(t *testing.T) {
+	server, err := NewServer(writeFixture(t), 0)
+	require.NoError(t, err)
+
+	code, _, body := get(t, server, "/")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `<a href="/f/66">Close-up Magic</a>`)
+
+	_, _, body = get(t, server, "/f/66")
+	assert.Contains(t, body, `<a href="/t/100">Card tricks</a>`)
+	assert.Contains(t, body, `status-archived">archived</span>`)
+	assert.Contains(t, body, `status-partial">partial</span>`)
+	assert.Contains(t, body, `status-not-archived">not archived</span>`)
+
+	_, _, body = get(t, server, "/t/100")
+	assert.Contains(t, body, "First line\nsecond &amp; last")
+	assert.NotContains(t, body, "<script>", "archived markup is shown as text")
+	assert.Contains(t, body, `alice wrote (2019-03-01 10:00:00):`)
+	assert.Contains(t, body, `<a href="/raw/100/1#p1001">archived page</a>`)
+	assert.Contains(t, body, `<a href="https://forum.example/viewtopic.php?p=1001#p1001">original post</a>`)
+	assert.Contains(t, body, `<a href="/t/100?page=2">Next &rarr;</a>`)
+	assert.NotContains(t, body, "Last word")
+
+	_, _, body = get(t, server, "/t/100?page=2")
+	assert.Contains(t, body, "Last word")
+	assert.Contains(t, body, "Page 2 of 2")
+
+	_, _, body = get(t, server, "/t/102")
+	assert.Contains(t, body, "This topic has not been extracted yet.")
+
+	code, header, body := get(t, server, "/raw/100/1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "script-src 'none'", header.Get("Content-Security-Policy"))
+	assert.Contains(t, body, "Raw page")
+
+	for _, path := range []string{"/raw/100/3", "/raw/102/1", "/t/999", "/f/12", "/raw/100/..%2f..%2fstate.json"} {
+		code, _, _ = get(t, server, path)
+		assert.Equal(t, http.StatusNotFound, code, path)
+	}
+}
+
+func TestServerRefresh(t *testing.T) {
+	config := writeFixture(t)
+	server, err := NewServer(config, time.Nanosecond)
+	require.NoError(t, err)
+
+	progress, err := state.LoadState(config.StatePath)
+	require.NoError(t, err)
+	progress.MarkTopicAsArchived("101")
+	require.NoError(t, progress.Save(config.StatePath))
+
+	// The reload runs in the background, so a later request sees the new state
+	assert.Eventually(t, func() bool {
+		_, _, body := get(t, server, "/t/101")
+		return strings.Contains(body, `status-archived">archived</span>`)
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -14,68 +14,23 @@ import (
 	"syscall"
 	"time"
 
+	"waypoint_archive_scripts/pkg/cassette"
 	"waypoint_archive_scripts/pkg/config"
 	"waypoint_archive_scripts/pkg/data"
 	"waypoint_archive_scripts/pkg/downloader"
+	"waypoint_archive_scripts/pkg/forumadapter"
 	"waypoint_archive_scripts/pkg/htmlutil"
 	"waypoint_archive_scripts/pkg/indexerlogic"
 	"waypoint_archive_scripts/pkg/jitrefresh"
+	"waypoint_archive_scripts/pkg/lifecycle"
 	"waypoint_archive_scripts/pkg/metrics"
+	"waypoint_archive_scripts/pkg/pageplan"
+	"waypoint_archive_scripts/pkg/rearchive"
+	"waypoint_archive_scripts/pkg/respcache"
 	"waypoint_archive_scripts/pkg/state"
 	"waypoint_archive_scripts/pkg/storer"
-	"waypoint_archive_scripts/pkg/util"
 )
 
-// getAllPageURLsForTopic retrieves all unique page URLs for a given topic.
-// It fetches the topic's first page, parses pagination links, and collects all URLs.
-func getAllPageURLsForTopic(topic data.Topic, cfg *config.Config, fetcher func(pageURL string, delay time.Duration, userAgent string) (string, error), parser func(htmlContent string, pageURL string) ([]string, error)) ([]string, error) {
-	log.Printf("[INFO] NAV: Getting all page URLs for Topic ID: %s (%s), Base URL: %s", topic.ID, topic.Title, topic.URL)
-	if topic.URL == "" {
-		log.Printf("[WARNING] NAV: Topic %s has no base URL. Cannot get page URLs.", topic.ID)
-		return nil, fmt.Errorf("topic %s has no base URL", topic.ID)
-	}
-
-	// Fetch the first page (base URL of the topic)
-	firstPageHTML, err := fetcher(topic.URL, cfg.PolitenessDelay, cfg.UserAgent)
-	if err != nil {
-		log.Printf("[ERROR] NAV: Failed to fetch first page %s for topic %s: %v", topic.URL, topic.ID, err)
-		return nil, fmt.Errorf("failed to fetch first page for topic %s: %w", topic.ID, err)
-	}
-
-	// Parse pagination links from the first page
-	// Note: ParsePaginationLinks is expected to return absolute URLs
-	pageLinks, err := parser(firstPageHTML, topic.URL)
-	if err != nil {
-		log.Printf("[ERROR] NAV: Failed to parse pagination links from %s for topic %s: %v", topic.URL, topic.ID, err)
-		// Proceed with just the base URL if parsing fails but fetching succeeded
-		return []string{topic.URL}, nil
-	}
-
-	// Collect all unique URLs, ensuring the base URL is included
-	uniqueURLs := make(map[string]struct{})
-	allPageURLs := []string{}
-
-	// Add the base URL first
-	if _, exists := uniqueURLs[topic.URL]; !exists {
-		allPageURLs = append(allPageURLs, topic.URL)
-		uniqueURLs[topic.URL] = struct{}{}
-	}
-
-	// Add links found by the parser
-	for _, link := range pageLinks {
-		if _, exists := uniqueURLs[link]; !exists {
-			allPageURLs = append(allPageURLs, link)
-			uniqueURLs[link] = struct{}{}
-		}
-	}
-
-	// Further sorting or filtering of URLs might be needed here if `parser` is too broad
-	// For now, assume all links returned by parser are valid page URLs for the topic.
-
-	log.Printf("[INFO] NAV: Topic %s - found %d unique page URLs.", topic.ID, len(allPageURLs))
-	return allPageURLs, nil
-}
-
 // downloadTopicPageHTML_Placeholder simulates downloading HTML content for a given page URL.
 // It includes placeholders for politeness delay and user agent usage.
 func downloadTopicPageHTML_Placeholder(pageURL string, topicID string, pageNum int, cfg *config.Config) (string, error) {
@@ -159,6 +114,29 @@ func main() {
 	metrics.InitPerformanceLogger(cfg.PerformanceLogPath)
 	log.Println("[DEBUG] main: InitPerformanceLogger completed.")
 
+	if _, err := forumadapter.Setup(cfg); err != nil {
+		log.Fatalf("[FATAL] Failed to set up forum adapter: %v", err)
+	}
+	httpCassette, err := cassette.Setup(cfg)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to set up HTTP cassette: %v", err)
+	}
+	defer func() {
+		if errClose := httpCassette.Close(); errClose != nil {
+			log.Printf("[ERROR] main: Failed to close HTTP cassette: %v", errClose)
+		}
+	}()
+	responseCache, err := respcache.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to open response cache: %v", err)
+	}
+	defer func() {
+		if responseCache != nil {
+			hits, misses := responseCache.Stats()
+			log.Printf("[INFO] main: Response cache served %d page(s) and missed %d.", hits, misses)
+		}
+	}()
+
 	// Determine currentArchiveRoot BEFORE initializing storer
 	currentArchiveRoot := cfg.ArchiveOutputRootDir // Default, might be overridden by test config
 	log.Printf("[DEBUG] main: Initial currentArchiveRoot set from cfg.ArchiveOutputRootDir: %s", currentArchiveRoot)
@@ -183,14 +161,17 @@ func main() {
 	log.Println("[DEBUG] main: htmlStorer created.")
 
 	// Create instances for JIT refresh dependencies
-	htmlFetcher := htmlutil.NewHTMLFetcher(cfg.UserAgent, cfg.PolitenessDelay)
+	htmlFetcher := htmlutil.NewCachedHTMLFetcher(cfg.UserAgent, cfg.PolitenessDelay, responseCache)
 	log.Println("[DEBUG] main: htmlFetcher created.")
 	htmlParser := htmlutil.NewPaginationParser(cfg.ForumBaseURL)
 	log.Println("[DEBUG] main: htmlParser created.")
 	htmlExtractor := htmlutil.NewTopicExtractor(cfg.ForumBaseURL)
 	log.Println("[DEBUG] main: htmlExtractor created.")
+	topicPagePlanner := pageplan.NewPlanner(cfg, htmlFetcher, htmlParser)
+	log.Println("[DEBUG] main: topicPagePlanner created.")
 
 	pageDownloader := downloader.NewDownloader(cfg)
+	pageDownloader.Cache = responseCache // Topic first pages fetched for pagination are stored without a second request
 	log.Println("[DEBUG] main: pageDownloader created.")
 	currentBatchMetrics := metrics.NewBatchMetrics()
 	log.Println("[DEBUG] main: currentBatchMetrics created.")
@@ -199,12 +180,12 @@ func main() {
 
 	// --- Topic Index Loading ---
 	log.Printf("[INFO] Loading sub-forum list from: %s", cfg.SubForumListFile)
-	// Changed from ReadSubForumListCSV to ReadSubForumListJSON
-	initialSubForums, err := indexerlogic.ReadSubForumListJSON(cfg.SubForumListFile)
+	// JSON, or the CSV written by generate_subforum_list
+	initialSubForums, err := indexerlogic.ReadSubForumList(cfg.SubForumListFile)
 	if err != nil {
 		log.Fatalf("[FATAL] Failed to read sub-forum list %s: %v", cfg.SubForumListFile, err)
 	}
-	log.Printf("[DEBUG] main: SubForumListJSON read, %d entries.", len(initialSubForums))
+	log.Printf("[DEBUG] main: Sub-forum list read, %d entries.", len(initialSubForums))
 
 	var allTopicsMasterList []data.Topic
 	var allSubForumsList []data.SubForum
@@ -212,13 +193,7 @@ func main() {
 	// Loop changed to iterate over initialSubForums (slice of data.SubForum)
 	for _, sfBase := range initialSubForums {
 		// Construct path to specific topic index JSON
-		// Assumes TopicIndexDir is like ".../indexed_data/"
-		// And TopicIndexFilePattern is like "topic_index_*.json"
-		// And actual files are in ".../indexed_data/forum_XX/topic_index_XX.json"
-		forumDirComponent := "forum_" + sfBase.ID
-		// Use sfBase.ID for the wildcard replacement in the pattern
-		topicIndexFilename := strings.Replace(cfg.TopicIndexFilePattern, "*", sfBase.ID, 1)
-		topicDataPath := filepath.Join(cfg.TopicIndexDir, forumDirComponent, topicIndexFilename)
+		topicDataPath := topicIndexPath(cfg, sfBase.ID)
 
 		log.Printf("[DEBUG] Loading topics for SubForum ID: %s (Name: %s) from %s", sfBase.ID, sfBase.Name, topicDataPath)
 		topicsForThisSF, err := indexerlogic.ReadTopicIndexJSON(topicDataPath, sfBase.ID)
@@ -287,6 +262,25 @@ func main() {
 	// This is handled by LoadState and NewArchiveProgressState now.
 	log.Printf("[INFO] Initial state loaded. %d topics marked as archived.", len(archivalState.ArchivedTopics)) // Corrected: ArchivedTopics, removed TopicErrors
 
+	// Load the topic lifecycle ledger (moved/merged/relocated/deleted topics).
+	// A ledger that exists but cannot be read is fatal, so it is never overwritten with an empty one.
+	lifecycleLedger, err := lifecycle.LoadLedger(cfg.LifecycleLedgerPath)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to load topic lifecycle ledger %s: %v", cfg.LifecycleLedgerPath, err)
+	}
+	log.Printf("[INFO] Topic lifecycle ledger loaded. %d topics tracked.", len(lifecycleLedger.Topics))
+
+	// Topics queued for re-archiving (e.g. by the extraction completeness check) are fetched again
+	// even though the state marks them as archived.
+	rearchiveQueue, err := rearchive.LoadQueue(cfg.RearchiveQueuePath)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to load re-archive queue %s: %v", cfg.RearchiveQueuePath, err)
+	}
+	log.Printf("[INFO] Re-archive queue loaded. %d topics queued.", len(rearchiveQueue.Topics))
+
+	// Topic ID -> sub-forum it is indexed under, to spot topics that reappear in another sub-forum
+	indexedSubForumByTopic := lifecycle.IndexedSubForumByTopic(allSubForumsList)
+
 	// Calculate total topics for progress tracking (only for selected sub-forums)
 	totalTopicsOverallForRun := 0
 	for _, sf := range sortedSubForums {
@@ -341,7 +335,9 @@ func main() {
 		log.Printf("[INFO] >>> Processing Sub-Forum ID: %s, Name: %s, URL: %s", currentSubForum.ID, currentSubForum.Name, currentSubForum.URL)
 
 		// --- JIT Topic Index Refresh (Story 2.8 AC9) ---
-		topicsForSubForum := currentSubForum.Topics // Work with a copy that can be modified by JIT
+		topicsForSubForum := append([]data.Topic(nil), currentSubForum.Topics...) // Work with a copy that can be modified by JIT
+		var newlyDiscoveredTopics []data.Topic
+		bumpedTopics := make(map[string]jitrefresh.BumpedTopic) // Topic ID -> bump info from JIT refresh
 
 		// Conditions for attempting JIT refresh:
 		if cfg.JITRefreshPages > 0 && currentSubForum.URL != "" {
@@ -351,7 +347,7 @@ func main() {
 			if jitrefresh.ShouldPerformJITRefresh(currentSubForum, archivalState, cfg.JITRefreshPages > 0, jitIntervalDuration) {
 				log.Printf("[INFO] JITREFRESH: Performing JIT index refresh for SubForum %s (URL: %s, first %d pages)", currentSubForum.ID, currentSubForum.URL, cfg.JITRefreshPages)
 				// Corrected JIT Call: Pass instances, not methods
-				newlyDiscoveredTopics, errJIT := jitrefresh.PerformJITRefresh(
+				jitResult, errJIT := jitrefresh.PerformJITRefresh(
 					currentSubForum, // Pass the current subForum from the loop
 					cfg,
 					htmlFetcher,   // Pass the instance
@@ -359,6 +355,27 @@ func main() {
 					htmlExtractor, // Pass the instance
 				)
 
+				if errJIT == nil {
+					// Topics "new" to this sub-forum but indexed elsewhere were relocated; they are
+					// recorded in the ledger instead of being archived or indexed a second time.
+					newlyDiscoveredTopics = lifecycleLedger.FilterRelocated(htmlStorer, jitResult.NewTopics, currentSubForum.ID, indexedSubForumByTopic, archivalState.IsTopicArchived)
+					// Bumped topics replace their indexed entry so page planning sees the live reply count.
+					for _, bumped := range jitResult.BumpedTopics {
+						bumpedTopics[bumped.Live.ID] = bumped
+					}
+					for i, t := range topicsForSubForum {
+						if bumped, ok := bumpedTopics[t.ID]; ok {
+							topicsForSubForum[i] = bumped.Live
+						}
+					}
+					if len(jitResult.BumpedTopics) > 0 {
+						log.Printf("[INFO] JITREFRESH: %d bumped topics in %s will have their changed pages re-archived.", len(jitResult.BumpedTopics), currentSubForum.ID)
+					}
+					for _, vanished := range jitResult.VanishedTopics {
+						log.Printf("[WARNING] JITREFRESH: Topic %s (%s) in %s no longer appears in the live listing. It may have been moved, merged or deleted.", vanished.ID, vanished.Title, currentSubForum.ID)
+					}
+				}
+
 				if errJIT != nil {
 					log.Printf("[WARNING] JITREFRESH: Error during JIT refresh for %s: %v. Proceeding with initially indexed topics.", currentSubForum.ID, errJIT)
 				} else if len(newlyDiscoveredTopics) > 0 {
@@ -375,6 +392,7 @@ func main() {
 							addedCount++
 						}
 					}
+					persistJITTopics(cfg, currentSubForum.ID, newlyDiscoveredTopics, currentBatchMetrics)
 					if addedCount > 0 {
 						log.Printf("[INFO] JITREFRESH: Added %d unique new topics to process for subforum %s.", addedCount, currentSubForum.ID)
 						totalTopicsOverallForRun += addedCount // Adjust total for ETC
@@ -421,6 +439,8 @@ func main() {
 			case <-ctx.Done():
 				log.Println("[INFO] ARCHIVER: Shutdown signal received. Saving state and exiting...")
 				state.SaveProgress(cfg.StateFilePath)
+				saveLifecycleLedger(lifecycleLedger, cfg.LifecycleLedgerPath)
+				saveRearchiveQueue(rearchiveQueue, cfg.RearchiveQueuePath)
 				metrics.SaveDetailMetricsLog() // Save metrics on graceful shutdown too
 				return
 			default:
@@ -428,83 +448,69 @@ func main() {
 
 			log.Printf("[PROGRESS] Sub-forum %s (%s): Processing topic %d/%d (ID: %s, Title: %s)", currentSubForum.ID, currentSubForum.Name, topicIndex+1, len(topicsForSubForum), topic.ID, topic.Title)
 
-			// Check if topic already completed or has a persistent error
-			if archivalState.IsTopicArchived(topic.ID) {
+			// Check if topic already completed or has a persistent error.
+			// Bumped topics are archived but have new posts, so their changed pages are fetched again.
+			bumped, isBumped := bumpedTopics[topic.ID]
+			if status, ended := lifecycleLedger.Ended(topic.ID); ended {
+				log.Printf("[INFO] ARCHIVAL: Topic %s is %s according to the lifecycle ledger. Skipping.", topic.ID, status)
+				continue
+			}
+			queued := rearchiveQueue.Contains(topic.ID)
+			if archivalState.IsTopicArchived(topic.ID) && !isBumped && !queued {
 				log.Printf("[INFO] ARCHIVAL: Topic %s is already archived. Skipping.", topic.ID)
 				continue // Next topic
 			}
+			if queued && !isBumped {
+				log.Printf("[INFO] REARCHIVE: Topic %s is queued for re-archiving. Fetching all pages again.", topic.ID)
+			}
 			topicStartTime := time.Now() // For timing individual topic processing
 
 			// --- Page Loop for the current Topic ---
 			// pagesInTopic := 0 // Removed, use pagesProcessedThisRunForTopic
 			pagesProcessedThisRunForTopic := 0
 
-			// For each topic, discover all its pages - This logic is from the user's "clean slate"
-			topicPageURLs := make([]string, 0, 5) // Pre-allocate a bit
-			allTopicPages := make(map[string]bool)
-			pagesToScan := make([]string, 0, 5)
-
-			normalizedInitialURL, errNormInitial := util.NormalizeTopicPageURL(topic.URL, topic.SubForumID)
-			if errNormInitial != nil {
-				log.Printf("[ERROR] NAV: Failed to normalize initial topic URL '%s' for topic %s: %v. Skipping this topic.", topic.URL, topic.ID, errNormInitial)
+			// For each topic, plan its page URLs from the indexed reply count.
+			// The planner only fetches the first page when the counts look stale.
+			topicPageURLs, errPlan := topicPagePlanner.TopicPageURLs(topic)
+			if errPlan != nil {
+				log.Printf("[ERROR] NAV: Failed to plan page URLs for topic %s (URL: %s): %v. Skipping this topic.", topic.ID, topic.URL, errPlan)
 				currentBatchMetrics.ErrorsEncountered++
-				// archivalState.RecordTopicError(topic.ID, fmt.Sprintf("Failed to normalize initial URL: %v", errNormInitial)) // Removed
 				continue // to the next topic
 			}
-			topicPageURLs = append(topicPageURLs, normalizedInitialURL)
-			allTopicPages[normalizedInitialURL] = true
-			pagesToScan = append(pagesToScan, normalizedInitialURL)
-			log.Printf("[DEBUG] NAV: Added initial normalized page %s to scan queue for topic %s", normalizedInitialURL, topic.ID)
-
-			for len(pagesToScan) > 0 {
-				currentScanURL := pagesToScan[0]
-				pagesToScan = pagesToScan[1:]
-
-				log.Printf("[DEBUG] NAV: Fetching %s for pagination (topic %s)", currentScanURL, topic.ID)
-				pageHTML, errFetch := htmlFetcher.FetchHTML(currentScanURL)
-				if errFetch != nil {
-					log.Printf("[ERROR] NAV: Error fetching %s for pagination: %v", currentScanURL, errFetch)
-					currentBatchMetrics.ErrorsEncountered++
-					// archivalState.RecordTopicError(topic.ID, fmt.Sprintf("NAV: Error fetching %s for pagination: %v", currentScanURL, errFetch)) // Removed
-					continue // Skip this page if fetch fails, but attempt to process other pages of the topic
-				}
-
-				log.Printf("[DEBUG] NAV: Parsing %s for pagination links (topic %s)", currentScanURL, topic.ID)
-				paginationLinks, errParse := htmlParser.ParsePaginationLinks(pageHTML, currentScanURL)
-				if errParse != nil {
-					log.Printf("[ERROR] NAV: Error parsing pagination links from %s: %v", currentScanURL, errParse)
-					currentBatchMetrics.ErrorsEncountered++
-					// archivalState.RecordTopicError(topic.ID, fmt.Sprintf("NAV: Error parsing pagination links from %s: %v", currentScanURL, errParse)) // Removed
-					continue // Skip if parsing fails
-				}
-
-				for _, link := range paginationLinks {
-					normalizedLink, errNorm := util.NormalizeTopicPageURL(link, topic.SubForumID)
-					if errNorm != nil {
-						log.Printf("[WARNING] NAV: Failed to normalize pagination link '%s': %v. Skipping.", link, errNorm)
-						continue
-					}
+			log.Printf("[INFO] NAV: Found %d unique pages for Topic ID %s.", len(topicPageURLs), topic.ID)
 
-					if !allTopicPages[normalizedLink] {
-						allTopicPages[normalizedLink] = true
-						topicPageURLs = append(topicPageURLs, normalizedLink)
-						pagesToScan = append(pagesToScan, normalizedLink)
-						log.Printf("[DEBUG] NAV: Added unique normalized page %s to scan queue for topic %s", normalizedLink, topic.ID)
-					}
-				}
+			firstPageToArchive := 1
+			if isBumped {
+				firstPageToArchive = bumped.FirstAffectedPage
+				log.Printf("[INFO] NAV: Topic %s was bumped (%d new replies). Archiving pages %d-%d and any earlier pages not yet archived.", topic.ID, bumped.NewReplies, firstPageToArchive, len(topicPageURLs))
+			}
+			pagesOnDisk := 0 // Pages stored this run, or skipped because the state records them
+			fetchPage := pageDownloader.FetchPageWithResult
+			if isBumped || queued {
+				fetchPage = pageDownloader.RefreshPageWithResult // The cached copy is what changed
 			}
-			log.Printf("[INFO] NAV: Found %d unique pages for Topic ID %s.", len(topicPageURLs), topic.ID)
-			// End of "clean slate" page discovery logic
+
+			// Moved topics keep being stored where they were first archived.
+			storageSubForumID := lifecycleLedger.StorageSubForumID(topic.ID, currentSubForum.ID)
+			topicLifecycleRecorded, topicLifecycleEnded := false, false
 
 			for pageNum0Based, pageURL := range topicPageURLs { // Iterate using topicPageURLs
 				actualPageNum := pageNum0Based + 1 // 1-based for logging and storage
 				pageID := fmt.Sprintf("%s_p%d", topic.ID, actualPageNum)
 
+				// Unchanged pages of a bumped topic are only skipped once they are archived
+				if actualPageNum < firstPageToArchive && archivalState.IsPageArchived(topic.ID, actualPageNum) {
+					pagesOnDisk++
+					continue
+				}
+
 				// Resume logic for pages within a topic
 				if currentSubForum.ID == skipToSubForumID &&
 					topic.ID == skipToTopicID &&
-					actualPageNum < startPageForTopic {
+					actualPageNum < startPageForTopic &&
+					archivalState.IsPageArchived(topic.ID, actualPageNum) {
 					log.Printf("[INFO] Resume: SF %s, Topic %s: Skipping already processed page %d (resuming from page %d).", currentSubForum.ID, topic.ID, actualPageNum, startPageForTopic)
+					pagesOnDisk++
 					continue
 				}
 
@@ -512,7 +518,15 @@ func main() {
 				pageProcessStartTime := time.Now()
 
 				// Download page HTML
-				htmlContentBytes, err := pageDownloader.FetchPage(pageURL)
+				htmlContentBytes, fetchResult, err := fetchPage(pageURL)
+				if event, ok := lifecycle.ClassifyFetch(topic, actualPageNum, fetchResult, err); ok && !topicLifecycleRecorded {
+					topicLifecycleRecorded = true // One event per topic per run, even if every page redirects
+					topicLifecycleEnded = lifecycleLedger.RecordWithAlias(htmlStorer, topic.ID, storageSubForumID, event, archivalState.IsTopicArchived(topic.ID))
+					if topicLifecycleEnded {
+						metrics.AppendDetailMetric(metrics.PerformanceMetric{Timestamp: time.Now(), ResourceType: metrics.ResourceTypeTopicPage, ResourceID: pageID, Action: metrics.ActionSkipped, Duration: time.Since(pageProcessStartTime), Notes: fmt.Sprintf("topic %s", event.Status)})
+						break // No further pages of a gone or merged topic
+					}
+				}
 				if err != nil {
 					log.Printf("[ERROR] DOWNLOAD: Failed to download page %s for topic %s: %v", pageURL, topic.ID, err)
 					// archivalState.RecordTopicError(topic.ID, fmt.Sprintf("Failed to download page %s: %v", pageURL, err)) // Removed
@@ -524,9 +538,12 @@ func main() {
 					metrics.AppendDetailMetric(metrics.PerformanceMetric{Timestamp: time.Now(), ResourceType: metrics.ResourceTypeTopicPage, ResourceID: pageID, Action: metrics.ActionSkipped, Size: 0, Duration: time.Since(pageProcessStartTime), Notes: fmt.Sprintf("download error: %v", err)})
 					continue // Continue to the next page of the current topic
 				}
+				if actualPageNum == 1 {
+					lifecycleLedger.RecordReachable(topic.ID) // Ends a run of 404/410 answers, if any
+				}
 
 				// Store page HTML
-				savedPath, err := storePageHTML(htmlStorer, topic, currentSubForum.ID, actualPageNum, htmlContentBytes) // Converted currentSubForum.ID
+				savedPath, err := storePageHTML(htmlStorer, topic, storageSubForumID, actualPageNum, htmlContentBytes)
 				if err != nil {
 					log.Printf("[ERROR] STORAGE: Failed to store page %s for topic %s: %v", pageURL, topic.ID, err)
 					// archivalState.RecordTopicError(topic.ID, fmt.Sprintf("Failed to store page %s: %v", pageURL, err)) // Removed
@@ -538,6 +555,7 @@ func main() {
 				log.Printf("[INFO] ARCHIVER: Saved HTML for topic %s, page %d to %s", topic.ID, actualPageNum, savedPath)
 				currentBatchMetrics.PagesArchived++
 				currentBatchMetrics.BytesArchived += int64(len(htmlContentBytes))
+				pagesOnDisk++
 				pagesArchivedInSubForum++                                          // Increment for sub-forum summary
 				pagesProcessedThisRunForTopic++                                    // Increment for topic summary
 				archivalState.MarkPageAsArchived(topic.ID, actualPageNum, pageURL) // Mark page in state
@@ -545,15 +563,20 @@ func main() {
 				metrics.AppendDetailMetric(metrics.PerformanceMetric{Timestamp: time.Now(), ResourceType: metrics.ResourceTypeTopicPage, ResourceID: pageID, Action: metrics.ActionArchived, Size: int64(len(htmlContentBytes)), Duration: time.Since(pageProcessStartTime)})
 			} // End page loop
 
-			if pagesProcessedThisRunForTopic == len(topicPageURLs) && len(topicPageURLs) > 0 { // All pages fetched and stored successfully
+			if topicLifecycleEnded {
+				log.Printf("[INFO] ARCHIVER: Topic %s ended its lifecycle on the forum (see %s). Existing archived pages are kept.", topic.ID, cfg.LifecycleLedgerPath)
+			} else if pagesOnDisk == len(topicPageURLs) && pagesOnDisk > 0 { // Every page is archived
 				archivalState.MarkTopicAsArchived(topic.ID)
 				log.Printf("[INFO] ARCHIVER: Topic %s marked as archived. Pages processed in this run: %d. Total duration for topic: %s", topic.ID, pagesProcessedThisRunForTopic, time.Since(topicStartTime).Round(time.Second))
 				currentBatchMetrics.TopicsArchived++
 				processedTopicsSoFar++ // For ETC
+				if queued && rearchiveQueue.Remove(topic.ID) {
+					log.Printf("[INFO] REARCHIVE: Topic %s re-archived and removed from the queue.", topic.ID)
+				}
 			} else if len(topicPageURLs) == 0 {
 				log.Printf("[INFO] ARCHIVER: Topic %s has no pages to archive (or URL was invalid). Skipping topic archival marking.", topic.ID)
 			} else {
-				log.Printf("[WARNING] ARCHIVER: Topic %s completed processing, but only %d out of %d pages were successfully archived. Topic not marked as fully archived.", topic.ID, pagesProcessedThisRunForTopic, len(topicPageURLs))
+				log.Printf("[WARNING] ARCHIVER: Topic %s completed processing, but only %d out of %d pages are archived. Topic not marked as fully archived.", topic.ID, pagesOnDisk, len(topicPageURLs))
 			}
 
 			archivalState.LastProcessedTopicID = topic.ID
@@ -565,6 +588,8 @@ func main() {
 			if saveIntervalInSeconds <= 0 || (topicIndex+1)%saveIntervalInSeconds == 0 { // Use topicIndex for periodic save
 				log.Printf("[DEBUG] ARCHIVER: Saving state post-topic %s (interval: %d topics, current index: %d).", topic.ID, saveIntervalInSeconds, topicIndex+1)
 				state.SaveProgress(cfg.StateFilePath)
+				saveLifecycleLedger(lifecycleLedger, cfg.LifecycleLedgerPath)
+				saveRearchiveQueue(rearchiveQueue, cfg.RearchiveQueuePath)
 			}
 			currentBatchMetrics.UpdateRates()
 			displayProgressAndETC(processedTopicsSoFar, totalTopicsOverallForRun, currentBatchMetrics) // Update and display progress
@@ -615,6 +640,8 @@ func main() {
 	// Final state save
 	log.Println("[INFO] ARCHIVER: Final state save before exiting...")
 	state.SaveProgress(cfg.StateFilePath)
+	saveLifecycleLedger(lifecycleLedger, cfg.LifecycleLedgerPath)
+	saveRearchiveQueue(rearchiveQueue, cfg.RearchiveQueuePath)
 
 	// Save all buffered performance metrics
 	log.Println("[INFO] ARCHIVER: Saving all performance metrics to CSV...")
@@ -635,11 +662,59 @@ func main() {
 	log.Printf("[INFO] ARCHIVER: Average Topics/Hour: %.2f", finalMetricsSummary.AvgTopicsPerHour)
 	log.Printf("[INFO] ARCHIVER: Average Pages/Min: %.2f", finalMetricsSummary.AvgPagesPerMin)
 	log.Printf("[INFO] ARCHIVER: Average MB/Min: %.2f", finalMetricsSummary.AvgMBPerMin)
+	log.Printf("[INFO] ARCHIVER: Topics Added to Index by JIT Refresh: %d", finalMetricsSummary.IndexTopicsAdded)
+	lifecycleCounts := lifecycleLedger.CountByStatus()
+	log.Printf("[INFO] ARCHIVER: Topic Lifecycle: %d gone, %d moved, %d merged, %d relocated", lifecycleCounts[lifecycle.StatusGone], lifecycleCounts[lifecycle.StatusMoved], lifecycleCounts[lifecycle.StatusMerged], lifecycleCounts[lifecycle.StatusRelocated])
 	log.Printf("[INFO] ARCHIVER: ===================")
 
 	log.Println("[INFO] ARCHIVER: Archival run finished.") // True final operational message
 }
 
+// topicIndexPath returns the topic index JSON path for a sub-forum.
+// Assumes TopicIndexDir is like ".../indexed_data/"
+// And TopicIndexFilePattern is like "topic_index_*.json"
+// And actual files are in ".../indexed_data/forum_XX/topic_index_XX.json"
+func topicIndexPath(cfg *config.Config, subForumID string) string {
+	forumDirComponent := "forum_" + subForumID
+	// Use subForumID for the wildcard replacement in the pattern
+	topicIndexFilename := strings.Replace(cfg.TopicIndexFilePattern, "*", subForumID, 1)
+	return filepath.Join(cfg.TopicIndexDir, forumDirComponent, topicIndexFilename)
+}
+
+// persistJITTopics merges topics discovered by JIT refresh into the sub-forum's topic index file,
+// so the extractor and later runs see them without a full re-index. The change is recorded in
+// the detail metrics log and in the run's batch metrics.
+func persistJITTopics(cfg *config.Config, subForumID string, newTopics []data.Topic, batchMetrics *metrics.BatchMetrics) {
+	indexPath := topicIndexPath(cfg, subForumID)
+	startTime := time.Now()
+	added, err := indexerlogic.MergeDiscoveredTopicsJSON(indexPath, newTopics, indexerlogic.DiscoveredViaJITRefresh, startTime)
+	if err != nil {
+		log.Printf("[ERROR] JITREFRESH: Failed to persist %d discovered topics to %s: %v", len(newTopics), indexPath, err)
+		batchMetrics.ErrorsEncountered++
+		return
+	}
+	if added == 0 {
+		return
+	}
+	batchMetrics.IndexTopicsAdded += int64(added)
+	metrics.AppendDetailMetric(metrics.PerformanceMetric{Timestamp: time.Now(), ResourceType: metrics.ResourceTypeSubForum, ResourceID: subForumID, Action: metrics.ActionJITIndexUpdated, Duration: time.Since(startTime), Notes: fmt.Sprintf("added %d topics to %s", added, indexPath)})
+	log.Printf("[INFO] JITREFRESH: Persisted %d new topics for subforum %s to %s.", added, subForumID, indexPath)
+}
+
+// saveLifecycleLedger saves the ledger, logging rather than failing the run on error.
+func saveLifecycleLedger(ledger *lifecycle.Ledger, filePath string) {
+	if err := ledger.Save(filePath); err != nil {
+		log.Printf("[ERROR] LIFECYCLE: Failed to save topic lifecycle ledger to %s: %v", filePath, err)
+	}
+}
+
+// saveRearchiveQueue saves the re-archive queue, logging rather than failing the run on error.
+func saveRearchiveQueue(queue *rearchive.Queue, filePath string) {
+	if err := queue.Save(filePath); err != nil {
+		log.Printf("[ERROR] REARCHIVE: Failed to save re-archive queue to %s: %v", filePath, err)
+	}
+}
+
 // initLogging configures the global logger and returns the log file if successful.
 func initLogging(cfg *config.Config) *os.File {
 	var logFileHandle *os.File = nil
@@ -694,9 +769,8 @@ func displayProgressAndETC(processedTopics, totalTopics int, bm *metrics.BatchMe
 	)
 }
 
-// getAllPageURLsForTopic is no longer used directly here, logic integrated into main loop.
-// If it were to be kept separate, its signature would need to match:
-// func getAllPageURLsForTopic(topic data.Topic, cfg *config.Config, fetcher func(string, time.Duration, string) (string, error), parser func(string, string) ([]string, error)) ([]string, error) { ... }
+// Topic page discovery is handled by pageplan.Planner, which derives page URLs from reply counts
+// and only fetches the first page when the indexed counts look stale.
 
 // main entry point
 // func main() {
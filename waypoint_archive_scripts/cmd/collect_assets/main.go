@@ -0,0 +1,124 @@
+// Command collect_assets archives the images, avatars, stylesheets, icons and attachments that
+// archived pages reference. It scans every page under the archive root, downloads each asset not
+// yet in the manifest with the archiver's User-Agent and politeness delay, and stores it in the
+// content-addressed asset store. Runs are incremental: stored and gone assets are skipped, and
+// failed ones are retried a few times across runs.
+//
+// Usage:
+//
+//	collect_assets -archiveRootDir archive_output -assetDir archive_assets -assetManifestPath asset_manifest.json
+//	collect_assets -assetHosts www.themagiccafe.com
+//
+// All settings come from the shared configuration (config.json, WAYPOINT_* environment variables
+// and flags).
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"waypoint_archive_scripts/pkg/assets"
+	"waypoint_archive_scripts/pkg/canonurl"
+	"waypoint_archive_scripts/pkg/cassette"
+	"waypoint_archive_scripts/pkg/config"
+	"waypoint_archive_scripts/pkg/downloader"
+	"waypoint_archive_scripts/pkg/extractorlogic"
+	"waypoint_archive_scripts/pkg/forumadapter"
+	"waypoint_archive_scripts/pkg/htmlprocessor"
+	"waypoint_archive_scripts/pkg/state"
+)
+
+func main() {
+	cfg, err := config.LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	adapter, err := forumadapter.Setup(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up forum adapter: %v", err)
+	}
+	httpCassette, err := cassette.Setup(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up HTTP cassette: %v", err)
+	}
+	defer func() {
+		if errClose := httpCassette.Close(); errClose != nil {
+			log.Printf("[ERROR] ASSETS: Failed to close HTTP cassette: %v", errClose)
+		}
+	}()
+
+	pages, err := extractorlogic.DiscoverArchivedPages(cfg.ArchiveRootDir)
+	if err != nil {
+		log.Fatalf("[ERROR] ASSETS: Failed to discover archived pages in %s: %v", cfg.ArchiveRootDir, err)
+	}
+	// Archived page URLs come from the progress state where known, as pages are resolved
+	// against the URL they were served from
+	progress, err := state.LoadState(cfg.StateFilePath)
+	if err != nil {
+		log.Printf("[WARNING] ASSETS: Failed to load archive state %s: %v. Page URLs are derived from the archive layout.", cfg.StateFilePath, err)
+		progress = state.NewArchiveProgressState()
+	}
+	manifest, err := assets.LoadManifest(cfg.AssetManifestPath)
+	if err != nil {
+		log.Fatalf("[ERROR] ASSETS: %v", err)
+	}
+
+	collector := assets.NewCollector(downloader.NewDownloader(cfg), cfg.AssetDir, manifest)
+	for _, host := range strings.Split(cfg.AssetHosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			collector.Hosts = append(collector.Hosts, host)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("[INFO] ASSETS: Scanning %d archived page(s) in %s; storing assets in %s.", len(pages), cfg.ArchiveRootDir, cfg.AssetDir)
+	var stats assets.CollectStats
+	lastSave := time.Now()
+	for i, page := range pages {
+		if ctx.Err() != nil {
+			log.Printf("[INFO] ASSETS: Interrupted after %d of %d page(s).", i, len(pages))
+			break
+		}
+		htmlPage, err := htmlprocessor.LoadHTMLPage(page.Path)
+		if err != nil {
+			log.Printf("[ERROR] ASSETS: Failed to load %s: %v. Skipping page.", page.Path, err)
+			continue
+		}
+		collector.Collect(assets.ExtractFromPage(htmlPage.Content, pageURL(adapter, progress, page, cfg.PostsPerPage)), &stats)
+
+		if time.Since(lastSave) >= cfg.SaveStateInterval {
+			if err := manifest.Save(cfg.AssetManifestPath); err != nil {
+				log.Printf("[ERROR] ASSETS: %v", err)
+			}
+			lastSave = time.Now()
+		}
+	}
+	if err := manifest.Save(cfg.AssetManifestPath); err != nil {
+		log.Fatalf("[ERROR] ASSETS: %v", err)
+	}
+
+	log.Printf("[INFO] ASSETS: %d asset URL(s) referenced: %d downloaded (%d duplicate content), %d already known, %d gone, %d failed, %d on other hosts.",
+		stats.Referenced, stats.Downloaded, stats.Deduplicated, stats.Known, stats.Gone, stats.Failed, stats.SkippedHost)
+}
+
+// pageURL returns the URL an archived page was fetched from: the one recorded in the progress
+// state, or else the topic page URL the page's place in the archive stands for.
+func pageURL(adapter forumadapter.ForumAdapter, progress *state.ArchiveProgressState, page extractorlogic.ArchivedPageInfo, postsPerPage int) string {
+	if topic, ok := progress.ArchivedTopics[page.TopicID]; ok {
+		if archived, ok := topic.ArchivedPages[page.PageNumber]; ok && archived.URL != "" {
+			return archived.URL
+		}
+	}
+	if postsPerPage <= 0 {
+		postsPerPage = adapter.PostsPerPage()
+	}
+	return adapter.FormatURL(canonurl.TopicPage(canonurl.SubForumID(page.SubForumID), canonurl.TopicID(page.TopicID),
+		canonurl.StartForPage(page.PageNumber, postsPerPage)))
+}
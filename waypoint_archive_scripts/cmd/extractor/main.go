@@ -7,6 +7,7 @@ import (
 
 	"waypoint_archive_scripts/pkg/config"
 	"waypoint_archive_scripts/pkg/extractorlogic"
+	"waypoint_archive_scripts/pkg/forumadapter"
 	"waypoint_archive_scripts/pkg/htmlprocessor"
 )
 
@@ -24,6 +25,10 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	if _, err := forumadapter.Setup(cfg); err != nil {
+		log.Fatalf("Failed to set up forum adapter: %v", err)
+	}
+
 	if cfg.ArchiveRootDir == "" {
 		log.Fatalf("ArchiveRootDir is not set in the configuration. Please configure the path to the Waypoint Archive.")
 	}
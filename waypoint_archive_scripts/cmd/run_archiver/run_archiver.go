@@ -15,13 +15,19 @@ import (
 	"syscall"
 	"time"
 
+	"waypoint_archive_scripts/pkg/cassette"
 	"waypoint_archive_scripts/pkg/config"
 	"waypoint_archive_scripts/pkg/data"
 	"waypoint_archive_scripts/pkg/downloader"
+	"waypoint_archive_scripts/pkg/forumadapter"
 	"waypoint_archive_scripts/pkg/htmlutil"
 	"waypoint_archive_scripts/pkg/indexerlogic"
 	"waypoint_archive_scripts/pkg/jitrefresh"
+	"waypoint_archive_scripts/pkg/lifecycle"
 	"waypoint_archive_scripts/pkg/metrics"
+	"waypoint_archive_scripts/pkg/pageplan"
+	"waypoint_archive_scripts/pkg/rearchive"
+	"waypoint_archive_scripts/pkg/respcache"
 	"waypoint_archive_scripts/pkg/state"
 	"waypoint_archive_scripts/pkg/storer"
 )
@@ -30,46 +36,6 @@ var (
 	cfg *config.Config // Global config instance
 )
 
-// getAllPageURLsForTopic fetches the first page of a topic, parses pagination links,
-// and returns a list of all page URLs for that topic.
-// It now uses htmlutil.FetchHTMLer and htmlutil.ParsePaginationLinker interfaces.
-func getAllPageURLsForTopic(topicURL string, fetcher htmlutil.FetchHTMLer, parser htmlutil.ParsePaginationLinker) ([]string, error) {
-	if topicURL == "" {
-		return nil, fmt.Errorf("getAllPageURLsForTopic: topicURL cannot be empty")
-	}
-	if fetcher == nil {
-		return nil, fmt.Errorf("getAllPageURLsForTopic: fetcher cannot be nil")
-	}
-	if parser == nil {
-		return nil, fmt.Errorf("getAllPageURLsForTopic: parser cannot be nil")
-	}
-
-	log.Printf("[DEBUG] getAllPageURLsForTopic: Fetching initial page for URL: %s", topicURL)
-	htmlContent, err := fetcher.FetchHTML(topicURL)
-	if err != nil {
-		log.Printf("[ERROR] getAllPageURLsForTopic: Failed to fetch HTML for %s: %v", topicURL, err)
-		return nil, fmt.Errorf("failed to fetch initial topic page %s: %w", topicURL, err)
-	}
-
-	pageURLs, err := parser.ParsePaginationLinks(htmlContent, topicURL)
-	if err != nil {
-		log.Printf("[ERROR] getAllPageURLsForTopic: Failed to parse pagination links for %s: %v", topicURL, err)
-		// If parsing fails but we have the first page, we can at least archive that.
-		// Consider if this should be a fatal error for the topic or just return the base URL.
-		// For now, if parsing fails, we assume it's a single-page topic or the structure is unexpected.
-		// Return just the base URL.
-		return []string{topicURL}, nil // Or return the error: fmt.Errorf("failed to parse pagination for %s: %w", topicURL, err)
-	}
-
-	if len(pageURLs) == 0 { // Should always include the base URL itself
-		log.Printf("[WARN] getAllPageURLsForTopic: No page URLs (not even base) returned from parser for %s. Defaulting to topicURL.", topicURL)
-		return []string{topicURL}, nil
-	}
-
-	log.Printf("[DEBUG] getAllPageURLsForTopic: Found %d page(s) for topic %s", len(pageURLs), topicURL)
-	return pageURLs, nil
-}
-
 func displayProgressAndETC(batchMetrics *metrics.BatchMetrics, totalTopicsToProcess, totalPagesToProcess int, processingStartTime time.Time) {
 	processedTopics := batchMetrics.TopicsArchived      // Direct field access
 	processedPages := batchMetrics.PagesArchived        // Direct field access
@@ -145,6 +111,29 @@ func main() {
 
 	log.Printf("[INFO] Configuration loaded. UserAgent: %s, PolitenessDelay: %s", cfg.UserAgent, cfg.PolitenessDelay)
 
+	if _, err := forumadapter.Setup(cfg); err != nil {
+		log.Fatalf("[FATAL] Failed to set up forum adapter: %v", err)
+	}
+	httpCassette, err := cassette.Setup(cfg)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to set up HTTP cassette: %v", err)
+	}
+	defer func() {
+		if errClose := httpCassette.Close(); errClose != nil {
+			log.Printf("[ERROR] main: Failed to close HTTP cassette: %v", errClose)
+		}
+	}()
+	responseCache, err := respcache.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to open response cache: %v", err)
+	}
+	defer func() {
+		if responseCache != nil {
+			hits, misses := responseCache.Stats()
+			log.Printf("[INFO] main: Response cache served %d page(s) and missed %d.", hits, misses)
+		}
+	}()
+
 	// Initialize Metrics Logger (uses cfg.PerformanceLogPath)
 	metrics.InitPerformanceLogger(cfg.PerformanceLogPath)
 	batchMetrics := metrics.NewBatchMetrics()
@@ -156,8 +145,11 @@ func main() {
 	}
 
 	// Initialize components
+	// Topic first pages fetched for pagination come out of the response cache instead of being fetched twice
 	dl := downloader.NewDownloader(cfg)
+	dl.Cache = responseCache
 	htmlStore := storer.NewStorer(cfg.ArchiveOutputRootDir) // Uses cfg.ArchiveOutputRootDir
+	topicPagePlanner := pageplan.NewPlanner(cfg, htmlutil.NewCachedHTMLFetcher(cfg.UserAgent, cfg.PolitenessDelay, responseCache), htmlutil.NewPaginationParser(cfg.ForumBaseURL))
 
 	// --- Load SubForum List and Topic Indices ---
 	log.Printf("[INFO] Loading sub-forum list from: %s", cfg.SubForumListFile)
@@ -191,16 +183,7 @@ func main() {
 		var topicsForSubForum []data.Topic
 		var errReadTopics error
 
-		var topicIndexFilename string
-		// Check if TopicIndexFilePattern contains a path separator, implying a nested structure like "forum_%s/topic_index_%s.json"
-		if strings.Contains(cfg.TopicIndexFilePattern, string(os.PathSeparator)) || strings.Contains(cfg.TopicIndexFilePattern, "/") {
-			// Assumes pattern like "forum_%s/topic_index_%s.json" where both %s are sfID
-			topicIndexFilename = fmt.Sprintf(cfg.TopicIndexFilePattern, sfID, sfID)
-		} else {
-			// Assumes pattern like "topic_index_forum_%s.csv" or "topic_index_%s.json"
-			topicIndexFilename = fmt.Sprintf(cfg.TopicIndexFilePattern, sfID)
-		}
-		topicIndexFilePath := filepath.Join(cfg.TopicIndexDir, topicIndexFilename)
+		topicIndexFilePath := topicIndexFilePathFor(cfg, sfID)
 
 		log.Printf("[INFO] Attempting to load topic index for SubForumID %s using path: %s", sfID, topicIndexFilePath)
 
@@ -271,6 +254,18 @@ func main() {
 			cfg.StateFilePath, len(archivalState.ArchivedTopics), archivalState.TotalPagesArchived())
 	}
 
+	lifecycleLedger, err := lifecycle.LoadLedger(cfg.LifecycleLedgerPath)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to load topic lifecycle ledger from %s: %v", cfg.LifecycleLedgerPath, err)
+	}
+
+	// Topics queued for re-archiving (e.g. by the extraction completeness check) are fetched again
+	// even though the state marks them as archived.
+	rearchiveQueue, err := rearchive.LoadQueue(cfg.RearchiveQueuePath)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to load re-archive queue from %s: %v", cfg.RearchiveQueuePath, err)
+	}
+
 	// Initialize Metrics
 	// batchMetrics := metrics.NewBatchMetrics() // This line is now removed
 	// Assuming PerformanceLogPath is for detailed, line-by-line metrics.
@@ -309,23 +304,91 @@ func main() {
 
 	}()
 
+	// --- JIT Topic Index Refresh ---
+	// Each sub-forum is refreshed before any of its topics are walked, so bumped topics of
+	// sub-forums that are already fully archived are picked up too.
+	// Bumped topics reported by JIT refresh, keyed by topic ID. Only their changed pages are re-archived.
+	bumpedTopics := make(map[string]jitrefresh.BumpedTopic)
+	if cfg.JITRefreshPages > 0 {
+		// Prepare interfaces for JIT refresh using new constructors from htmlutil
+		htmlFetcherForJIT := htmlutil.NewCachedHTMLFetcher(cfg.UserAgent, cfg.PolitenessDelay, responseCache)
+		paginationParserForJIT := htmlutil.NewPaginationParser(cfg.ForumBaseURL)
+		topicExtractorForJIT := htmlutil.NewTopicExtractor(cfg.ForumBaseURL) // Provides htmlutil.ExtractTopicser
+		// Topic ID -> sub-forum it is indexed under, to spot topics that reappear in another sub-forum
+		indexedSubForumByTopic := lifecycle.IndexedSubForumByTopic(allSubForumsList)
+		loadedTopicIDs := make(map[string]bool, len(allTopicsMasterList))
+		for _, topic := range allTopicsMasterList {
+			loadedTopicIDs[topic.ID] = true
+		}
+
+		for _, subForum := range allSubForumsList {
+			if ctx.Err() != nil {
+				break
+			}
+			if !jitrefresh.ShouldPerformJITRefresh(subForum, archivalState, cfg.JITRefreshPages > 0, cfg.JITRefreshInterval) {
+				continue
+			}
+			log.Printf("[INFO] Performing JIT Refresh for SubForum %s", subForum.ID)
+			jitResult, err := jitrefresh.PerformJITRefresh(
+				subForum,
+				cfg, // Pass the whole config object
+				htmlFetcherForJIT,
+				paginationParserForJIT,
+				topicExtractorForJIT,
+			)
+			if err != nil {
+				log.Printf("[ERROR] JIT Refresh for SubForum %s failed: %v", subForum.ID, err)
+				metrics.AppendDetailMetric(metrics.PerformanceMetric{
+					Timestamp:    time.Now(),
+					ResourceType: metrics.ResourceTypeSubForum,
+					ResourceID:   subForum.ID,
+					Action:       metrics.ActionJITRefresh,
+					Notes:        fmt.Sprintf("Status: Error, JIT Refresh failed: %v", err),
+				})
+				continue
+			}
+			log.Printf("[INFO] JIT Refresh for SubForum %s completed. Found %d new topics, %d bumped topics, %d vanished topics.", subForum.ID, len(jitResult.NewTopics), len(jitResult.BumpedTopics), len(jitResult.VanishedTopics))
+			for _, b := range jitResult.BumpedTopics {
+				bumpedTopics[b.Live.ID] = b
+			}
+			// Topics "new" to this sub-forum but indexed elsewhere were relocated; they are
+			// recorded in the ledger instead of being archived or indexed a second time.
+			newTopics := lifecycleLedger.FilterRelocated(htmlStore, jitResult.NewTopics, subForum.ID, indexedSubForumByTopic, archivalState.IsTopicArchived)
+			indexTopicsAdded := persistJITTopics(cfg, subForum.ID, newTopics)
+			// New topics are archived in this run too, not only picked up from the index by the next one
+			for _, newTopic := range newTopics {
+				if !loadedTopicIDs[newTopic.ID] {
+					loadedTopicIDs[newTopic.ID] = true
+					allTopicsMasterList = append(allTopicsMasterList, newTopic)
+				}
+			}
+			batchMetrics.IndexTopicsAdded += int64(indexTopicsAdded)
+			for _, vanished := range jitResult.VanishedTopics {
+				log.Printf("[WARNING] Topic %s (ID: %s) no longer appears in the live listing of SubForum %s. It may have been moved, merged or deleted.", vanished.Title, vanished.ID, subForum.ID)
+			}
+			// Mark that JIT refresh was attempted/done for this sub-forum in state
+			archivalState.MarkJITRefreshAttempted(subForum.ID, time.Now())
+			// Log successful JIT refresh completion
+			metrics.AppendDetailMetric(metrics.PerformanceMetric{
+				Timestamp:    time.Now(),
+				ResourceType: metrics.ResourceTypeSubForum,
+				ResourceID:   subForum.ID,
+				Action:       metrics.ActionJITRefresh,
+				Notes:        fmt.Sprintf("Status: Success, Found %d new topics, %d bumped topics, %d topics added to index", len(newTopics), len(jitResult.BumpedTopics), indexTopicsAdded),
+			})
+		}
+	}
+	// --- End JIT Topic Index Refresh ---
+
 	// --- Main Archival Loop ---
 	log.Printf("[INFO] Starting main archival loop for %d topics...", len(allTopicsMasterList))
 	processingStartTime := time.Now()
 	lastStateSaveTime := time.Now()
 	var totalPagesEstimated int = 0 // Basic estimation, will be refined if possible
 
-	// Pre-calculate totalPagesEstimated if possible (very rough)
+	// Pre-calculate totalPagesEstimated from indexed reply counts (same arithmetic as the page planner)
 	for _, topic := range allTopicsMasterList {
-		// Rough estimate: average 1 page for new topics, or actual pages if known
-		// This is not accurate without fetching, so it's a placeholder.
-		// We could improve this if Topic struct had a PageCount field from indexer.
-		if topic.Replies > 0 && cfg.ForumBaseURL != "" { // Assuming some posts per page
-			// A very rough estimate, e.g., 20 posts per page
-			totalPagesEstimated += (topic.Replies / 20) + 1
-		} else {
-			totalPagesEstimated++
-		}
+		totalPagesEstimated += pageplan.PageCount(topic.Replies, cfg.PostsPerPage)
 	}
 
 	for i, topic := range allTopicsMasterList {
@@ -341,7 +404,19 @@ func main() {
 
 		log.Printf("[INFO] Processing topic %d/%d: ID %s, Title: %s", i+1, len(allTopicsMasterList), topic.ID, topic.Title)
 
-		if archivalState.IsTopicArchived(topic.ID) {
+		bumped, isBumped := bumpedTopics[topic.ID]
+		if isBumped {
+			topic = bumped.Live // Live reply count, so the planner includes the new pages
+		}
+
+		if status, ended := lifecycleLedger.Ended(topic.ID); ended {
+			log.Printf("[INFO] Topic %s (ID: %s) is %s according to the lifecycle ledger. Skipping.", topic.Title, topic.ID, status)
+			batchMetrics.TopicsSkipped++
+			continue
+		}
+
+		queued := rearchiveQueue.Contains(topic.ID)
+		if archivalState.IsTopicArchived(topic.ID) && !isBumped && !queued {
 			log.Printf("[INFO] Topic %s (ID: %s) already archived. Skipping.", topic.Title, topic.ID)
 			batchMetrics.TopicsSkipped++ // Direct field increment
 			// Ensure it counts towards "processed" for ETC calculation stability
@@ -400,73 +475,13 @@ func main() {
 			continue
 		}
 
-		// JIT Refresh Logic (Placeholder - needs actual SubForum context for the topic)
-		// This needs to find the SubForum object to which this topic belongs to use subForum.JITRefreshCandidate
-		// For now, this is simplified. A more robust solution would involve mapping topics back to their subforums
-		// or passing subforum context through.
-		// We'll assume that if JITRefreshPages > 0, we *might* do it.
-		if cfg.JITRefreshPages > 0 { // Basic check, refined by ShouldPerformJITRefresh
-			// Find the subforum for the current topic
-			var parentSubForum data.SubForum
-			foundSF := false
-			for _, sf := range allSubForumsList {
-				if sf.ID == topic.SubForumID {
-					parentSubForum = sf
-					foundSF = true
-					break
-				}
-			}
-
-			if foundSF && jitrefresh.ShouldPerformJITRefresh(parentSubForum, archivalState, cfg.JITRefreshPages > 0, cfg.JITRefreshInterval) {
-				log.Printf("[INFO] Performing JIT Refresh for SubForum %s (topic %s is part of it)", parentSubForum.ID, topic.ID)
-				// Prepare interfaces for JIT refresh using new constructors from htmlutil
-				htmlFetcherForJIT := htmlutil.NewHTMLFetcher(cfg.UserAgent, cfg.PolitenessDelay)
-				paginationParserForJIT := htmlutil.NewPaginationParser(cfg.ForumBaseURL)
-				topicExtractorForJIT := htmlutil.NewTopicExtractor(cfg.ForumBaseURL) // Provides htmlutil.ExtractTopicser
-
-				refreshedTopics, err := jitrefresh.PerformJITRefresh(
-					parentSubForum,
-					cfg, // Pass the whole config object
-					htmlFetcherForJIT,
-					paginationParserForJIT,
-					topicExtractorForJIT,
-				)
-				if err != nil {
-					log.Printf("[ERROR] JIT Refresh for SubForum %s failed: %v", parentSubForum.ID, err)
-					metrics.AppendDetailMetric(metrics.PerformanceMetric{
-						Timestamp:    time.Now(),
-						ResourceType: metrics.ResourceTypeSubForum,
-						ResourceID:   parentSubForum.ID,
-						Action:       metrics.ActionJITRefresh,
-						Notes:        fmt.Sprintf("Status: Error, JIT Refresh failed: %v", err),
-					})
-				} else {
-					log.Printf("[INFO] JIT Refresh for SubForum %s completed. Found %d new/updated topics.", parentSubForum.ID, len(refreshedTopics))
-					// TODO: Integrate refreshedTopics back into allTopicsMasterList or handle them
-					// This might involve updating existing topic entries or adding new ones.
-					// For now, we just log. A proper merge/update is complex.
-					// Mark that JIT refresh was attempted/done for this sub-forum in state
-					archivalState.MarkJITRefreshAttempted(parentSubForum.ID, time.Now())
-					// Log successful JIT refresh completion
-					metrics.AppendDetailMetric(metrics.PerformanceMetric{
-						Timestamp:    time.Now(),
-						ResourceType: metrics.ResourceTypeSubForum,
-						ResourceID:   parentSubForum.ID,
-						Action:       metrics.ActionJITRefresh,
-						Notes:        fmt.Sprintf("Status: Success, Found %d new/updated topics", len(refreshedTopics)),
-					})
-				}
-			}
-		}
-		// End JIT Refresh Logic
-
-		// Use the new htmlutil constructors for fetching and parsing pagination for this specific task
-		topicSpecificFetcher := htmlutil.NewHTMLFetcher(cfg.UserAgent, cfg.PolitenessDelay)
-		topicSpecificPaginationParser := htmlutil.NewPaginationParser(cfg.ForumBaseURL)
-		topicPageURLs, err := getAllPageURLsForTopic(currentTopicURL, topicSpecificFetcher, topicSpecificPaginationParser)
+		// Plan the topic's page URLs from its reply count. The first page is only fetched
+		// for live pagination when the indexed counts look stale.
+		topicForPlanning := topic
+		topicForPlanning.URL = currentTopicURL
+		topicPageURLs, err := topicPagePlanner.TopicPageURLs(topicForPlanning)
 		if err != nil {
 			log.Printf("[ERROR] Failed to get all page URLs for topic %s (ID: %s, URL: %s): %v. Skipping topic.", topic.Title, topic.ID, currentTopicURL, err)
-			// metrics.RecordPerformance(detailMetricsLog, "GetPageURLs", "Error", time.Since(processingStartTime), topic.ID, fmt.Sprintf("Failed to get page URLs: %v", err)) - Old way
 			metrics.AppendDetailMetric(metrics.PerformanceMetric{
 				Timestamp:    time.Now(),
 				ResourceType: metrics.ResourceTypeTopicPage,
@@ -481,6 +496,13 @@ func main() {
 
 		log.Printf("[INFO] Topic %s (ID: %s) has %d page(s) to archive.", topic.Title, topic.ID, len(topicPageURLs))
 
+		topicLifecycleRecorded, topicLifecycleEnded := false, false
+		pagesOnDisk := 0 // Pages stored this run, or skipped because the state records them
+		fetchPage := dl.FetchPageWithResult
+		if isBumped || queued {
+			fetchPage = dl.RefreshPageWithResult // The cached copy is what changed
+		}
+
 		for pageIdx, pageURL := range topicPageURLs {
 			select {
 			case <-ctx.Done():
@@ -493,16 +515,28 @@ func main() {
 			pageNum := pageIdx + 1 // 1-indexed page number
 			pageFetchStartTime := time.Now()
 
-			if archivalState.IsPageArchived(topic.ID, pageNum) {
+			// Pages of a bumped topic before its first changed page are skipped only once archived
+			if archivalState.IsPageArchived(topic.ID, pageNum) && (!isBumped || pageNum < bumped.FirstAffectedPage) {
 				log.Printf("[DEBUG] Page %d of topic %s (ID: %s) already archived. Skipping.", pageNum, topic.Title, topic.ID)
 				// batchMetrics.PagesSkipped++ // No, this should count towards total pages for ETC.
+				pagesOnDisk++
 				continue
 			}
 
 			log.Printf("[DEBUG] Fetching page %d/%d for topic %s (ID: %s) from %s", pageNum, len(topicPageURLs), topic.Title, topic.ID, pageURL)
 			var htmlContent []byte
-			htmlContent, err = dl.FetchPage(pageURL)
+			var fetchResult *downloader.FetchResult
+			htmlContent, fetchResult, err = fetchPage(pageURL)
 			fetchDuration := time.Since(pageFetchStartTime)
+			if event, ok := lifecycle.ClassifyFetch(topic, pageNum, fetchResult, err); ok && !topicLifecycleRecorded {
+				topicLifecycleRecorded = true
+				storageSubForumID := lifecycleLedger.StorageSubForumID(topic.ID, topic.SubForumID)
+				topicLifecycleEnded = lifecycleLedger.RecordWithAlias(htmlStore, topic.ID, storageSubForumID, event, archivalState.IsTopicArchived(topic.ID))
+				if topicLifecycleEnded {
+					log.Printf("[INFO] Topic %s (ID: %s) is %s on the forum. Stopping page fetches; archived pages are kept.", topic.Title, topic.ID, event.Status)
+					break
+				}
+			}
 			if err != nil {
 				log.Printf("[ERROR] Failed to fetch page %d of topic %s (URL: %s): %v", pageNum, topic.Title, pageURL, err)
 				// metrics.RecordPerformance(detailMetricsLog, "FetchPage", "Error", fetchDuration, topic.ID, fmt.Sprintf("URL: %s, Page: %d, Error: %v", pageURL, pageNum, err)) - Old way
@@ -529,6 +563,9 @@ func main() {
 				Duration:     fetchDuration,
 				Notes:        fmt.Sprintf("Status: Success, URL: %s, Page: %d", pageURL, pageNum),
 			})
+			if pageNum == 1 {
+				lifecycleLedger.RecordReachable(topic.ID) // Ends a run of 404/410 answers, if any
+			}
 
 			// Store the HTML content
 			storageStartTime := time.Now()
@@ -537,6 +574,7 @@ func main() {
 				log.Printf("[WARN] Topic ID %s has no SubForumID associated. Using 'unknown_subforum' for storage path.", topic.ID)
 				sfIDForStorage = "unknown_subforum"
 			}
+			sfIDForStorage = lifecycleLedger.StorageSubForumID(topic.ID, sfIDForStorage) // Moved topics stay where they were first archived
 
 			_, err = htmlStore.SaveTopicHTML(sfIDForStorage, topic.ID, pageNum, htmlContent)
 			storeDuration := time.Since(storageStartTime)
@@ -567,14 +605,26 @@ func main() {
 
 			archivalState.MarkPageAsArchived(topic.ID, pageNum, pageURL)
 			batchMetrics.PagesArchived++ // Direct field increment
+			pagesOnDisk++
 
 			// Optional: Politeness delay already handled by downloader, but an additional one here if needed.
 			// time.Sleep(cfg.PolitenessDelay) // This might be too much if downloader already does it.
 		}
 
-		archivalState.MarkTopicAsArchived(topic.ID)
-		batchMetrics.TopicsArchived++ // Direct field increment
-		log.Printf("[INFO] Finished archiving all pages for topic %s (ID: %s).", topic.Title, topic.ID)
+		if topicLifecycleEnded {
+			continue // Gone or merged topics are tracked in the lifecycle ledger, not marked archived
+		}
+
+		if pagesOnDisk == len(topicPageURLs) && pagesOnDisk > 0 {
+			archivalState.MarkTopicAsArchived(topic.ID)
+			batchMetrics.TopicsArchived++ // Direct field increment
+			log.Printf("[INFO] Finished archiving all pages for topic %s (ID: %s).", topic.Title, topic.ID)
+			if queued && rearchiveQueue.Remove(topic.ID) {
+				log.Printf("[INFO] REARCHIVE: Topic %s re-archived and removed from the queue.", topic.ID)
+			}
+		} else {
+			log.Printf("[WARN] Only %d of %d pages of topic %s (ID: %s) are archived. Topic not marked as archived.", pagesOnDisk, len(topicPageURLs), topic.Title, topic.ID)
+		}
 
 		// Save state periodically
 		if time.Since(lastStateSaveTime) >= cfg.SaveStateInterval {
@@ -586,6 +636,12 @@ func main() {
 				log.Printf("[INFO] State saved successfully to %s.", cfg.StateFilePath)
 				lastStateSaveTime = time.Now()
 			}
+			if err := lifecycleLedger.Save(cfg.LifecycleLedgerPath); err != nil {
+				log.Printf("[ERROR] Failed to save topic lifecycle ledger: %v", err)
+			}
+			if err := rearchiveQueue.Save(cfg.RearchiveQueuePath); err != nil {
+				log.Printf("[ERROR] Failed to save re-archive queue: %v", err)
+			}
 			// if err := detailMetricsLog.Save(); err != nil { // Persist metrics log too - old way
 			if err := metrics.SaveDetailMetricsLog(); err != nil { // New way
 				log.Printf("[ERROR] Failed to save detail metrics log: %v", err)
@@ -609,6 +665,12 @@ endLoop:
 	} else {
 		log.Printf("[INFO] Final state saved successfully to %s.", cfg.StateFilePath)
 	}
+	if err := lifecycleLedger.Save(cfg.LifecycleLedgerPath); err != nil {
+		log.Printf("[ERROR] Final topic lifecycle ledger save failed: %v", err)
+	}
+	if err := rearchiveQueue.Save(cfg.RearchiveQueuePath); err != nil {
+		log.Printf("[ERROR] Final re-archive queue save failed: %v", err)
+	}
 
 	log.Printf("[INFO] Performing final detail metrics log save...")
 	// if err := detailMetricsLog.Save(); err != nil { // Ensure metrics are flushed - old way
@@ -627,6 +689,9 @@ endLoop:
 	log.Printf("[INFO] Successfully archived topics: %d", batchMetrics.TopicsArchived)
 	log.Printf("[INFO] Skipped topics (already archived): %d", batchMetrics.TopicsSkipped)
 	log.Printf("[INFO] Total pages archived: %d", batchMetrics.PagesArchived)
+	log.Printf("[INFO] Topics added to index by JIT refresh: %d", batchMetrics.IndexTopicsAdded)
+	lifecycleCounts := lifecycleLedger.CountByStatus()
+	log.Printf("[INFO] Topic lifecycle ledger: %d gone, %d moved, %d merged, %d relocated", lifecycleCounts[lifecycle.StatusGone], lifecycleCounts[lifecycle.StatusMoved], lifecycleCounts[lifecycle.StatusMerged], lifecycleCounts[lifecycle.StatusRelocated])
 	log.Printf("[INFO] Errors encountered: %d", batchMetrics.ErrorsEncountered)
 	log.Println("----------------------------------------------------")
 
@@ -637,3 +702,49 @@ endLoop:
 
 	log.Println("[INFO] Waypoint Archiver finished successfully.")
 }
+
+// topicIndexFilePathFor returns the topic index file path for a sub-forum based on TopicIndexFilePattern.
+func topicIndexFilePathFor(cfg *config.Config, sfID string) string {
+	var topicIndexFilename string
+	// Check if TopicIndexFilePattern contains a path separator, implying a nested structure like "forum_%s/topic_index_%s.json"
+	if strings.Contains(cfg.TopicIndexFilePattern, string(os.PathSeparator)) || strings.Contains(cfg.TopicIndexFilePattern, "/") {
+		// Assumes pattern like "forum_%s/topic_index_%s.json" where both %s are sfID
+		topicIndexFilename = fmt.Sprintf(cfg.TopicIndexFilePattern, sfID, sfID)
+	} else {
+		// Assumes pattern like "topic_index_forum_%s.csv" or "topic_index_%s.json"
+		topicIndexFilename = fmt.Sprintf(cfg.TopicIndexFilePattern, sfID)
+	}
+	return filepath.Join(cfg.TopicIndexDir, topicIndexFilename)
+}
+
+// persistJITTopics merges topics discovered by JIT refresh into the sub-forum's topic index file
+// and records the update in the detail metrics log. Only JSON indices are updated.
+// It returns the number of topics added to the index.
+func persistJITTopics(cfg *config.Config, sfID string, newTopics []data.Topic) int {
+	if len(newTopics) == 0 {
+		return 0
+	}
+	indexPath := topicIndexFilePathFor(cfg, sfID)
+	if !strings.HasSuffix(strings.ToLower(indexPath), ".json") {
+		log.Printf("[WARN] Topic index %s is not JSON. %d JIT-discovered topics for SubForum %s were not persisted.", indexPath, len(newTopics), sfID)
+		return 0
+	}
+
+	startTime := time.Now()
+	added, err := indexerlogic.MergeDiscoveredTopicsJSON(indexPath, newTopics, indexerlogic.DiscoveredViaJITRefresh, startTime)
+	if err != nil {
+		log.Printf("[ERROR] Failed to persist JIT-discovered topics for SubForum %s to %s: %v", sfID, indexPath, err)
+		return 0
+	}
+	if added > 0 {
+		metrics.AppendDetailMetric(metrics.PerformanceMetric{
+			Timestamp:    time.Now(),
+			ResourceType: metrics.ResourceTypeSubForum,
+			ResourceID:   sfID,
+			Action:       metrics.ActionJITIndexUpdated,
+			Duration:     time.Since(startTime),
+			Notes:        fmt.Sprintf("Status: Success, Added %d topics to %s", added, indexPath),
+		})
+	}
+	return added
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/fakeforum"
+	"waypoint_archive_scripts/pkg/htmlutil"
+)
+
+// buildArchiver builds the archiver into workDir.
+func buildArchiver(t *testing.T, workDir string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds and runs the archiver")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go tool is needed to build the archiver")
+	}
+	binPath := filepath.Join(workDir, "run_archiver")
+	build := exec.Command("go", "build", "-o", binPath, ".")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("building the archiver failed: %v\n%s", err, output)
+	}
+	return binPath
+}
+
+// runArchiverBinary runs the built archiver in workDir against srv, reading the sub-forum list and
+// topic index written by writeIndex, and fails the test with its output if it fails.
+func runArchiverBinary(t *testing.T, binPath string, workDir string, srv *fakeforum.Server, jitRefreshPages int) {
+	t.Helper()
+	run := exec.Command(binPath,
+		"-subForumListFile", "subforum_list.csv",
+		"-topicIndexDir", ".",
+		"-topicIndexFilePattern", "topic_index_%s.json",
+		"-archiveOutputRootDir", "archive",
+		"-forumBaseURL", srv.BaseURL(),
+		"-politenessDelay", "0s",
+		"-jitRefreshPages", strconv.Itoa(jitRefreshPages))
+	run.Dir = workDir
+	if output, err := run.CombinedOutput(); err != nil {
+		t.Fatalf("running the archiver failed: %v\n%s", err, output)
+	}
+}
+
+// writeIndex writes the sub-forum list for sub-forum 66 of srv and its topic index, holding the
+// live listing's topics with the given IDs, as the indexer does.
+func writeIndex(t *testing.T, workDir string, srv *fakeforum.Server, topicIDs ...string) {
+	t.Helper()
+	response, err := http.Get(srv.ForumURL("66"))
+	if err != nil {
+		t.Fatalf("fetching the listing failed: %v", err)
+	}
+	listing, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		t.Fatalf("reading the listing failed: %v", err)
+	}
+	topics, err := htmlutil.NewTopicExtractor(srv.BaseURL()).ExtractTopics(string(listing), srv.ForumURL("66"), "66")
+	if err != nil {
+		t.Fatalf("ExtractTopics() error = %v", err)
+	}
+	var indexed []data.Topic
+	for _, topic := range topics {
+		for _, id := range topicIDs {
+			if topic.ID == id {
+				indexed = append(indexed, topic)
+			}
+		}
+	}
+	if len(indexed) != len(topicIDs) {
+		t.Fatalf("the listing holds %d of the topics %v", len(indexed), topicIDs)
+	}
+	topicIndex, err := json.Marshal(indexed)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, "topic_index_66.json"), topicIndex, 0644); err != nil {
+		t.Fatal(err)
+	}
+	subForumList := "SubForumID,SubForumName,SubForumURL\n66,What happened,\"" + srv.ForumURL("66") + "\"\n"
+	if err := os.WriteFile(filepath.Join(workDir, "subforum_list.csv"), []byte(subForumList), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var posted = time.Date(2003, time.January, 10, 9, 5, 0, 0, time.UTC)
+
+// testForum serves sub-forum 66 with a two-post topic 100 and a one-post topic 101.
+func testForum() *fakeforum.Server {
+	return fakeforum.NewServer(fakeforum.Fixture{SubForums: []fakeforum.SubForum{{ID: "66", Name: "What happened, was this...", Topics: []fakeforum.Topic{
+		{ID: "100", Title: "Cups and balls", Posts: []fakeforum.Post{
+			{ID: "1001", Author: "Slide", Posted: posted, Body: "Opening post."},
+			{ID: "1002", Author: "Maxim", Posted: posted.Add(time.Hour), Body: "First reply."},
+		}},
+		{ID: "101", Title: "Linking rings", Posts: []fakeforum.Post{
+			{ID: "1003", Author: "Steve", Posted: posted.Add(time.Minute), Body: "Only post."},
+		}},
+	}}}})
+}
+
+// TestJITRefresh_FullyArchivedSubForum archives a sub-forum completely, adds a reply to one of its
+// topics and runs again with JIT refresh on: the bumped topic must be fetched again although every
+// topic of the sub-forum is already marked archived.
+func TestJITRefresh_FullyArchivedSubForum(t *testing.T) {
+	workDir := t.TempDir()
+	binPath := buildArchiver(t, workDir)
+	srv := testForum()
+	defer srv.Close()
+	writeIndex(t, workDir, srv, "100", "101")
+	runArchiverBinary(t, binPath, workDir, srv, 0)
+
+	if err := srv.AddPost("100", fakeforum.Post{ID: "1004", Author: "Ann", Posted: posted.Add(2 * time.Hour), Body: "A late reply."}); err != nil {
+		t.Fatalf("AddPost() error = %v", err)
+	}
+	fetchesBefore := srv.RequestCount("topic=100")
+	runArchiverBinary(t, binPath, workDir, srv, 1)
+
+	if got := srv.RequestCount("topic=100"); got <= fetchesBefore {
+		t.Errorf("bumped topic 100 was not fetched again: %d requests before the second run, %d after", fetchesBefore, got)
+	}
+	if got := srv.RequestCount("topic=101"); got != 1 {
+		t.Errorf("topic 101 was fetched %d times, want 1", got)
+	}
+	page, err := os.ReadFile(filepath.Join(workDir, "archive", "66", "100", "page_1.html"))
+	if err != nil {
+		t.Fatalf("reading the archived page failed: %v", err)
+	}
+	if !strings.Contains(string(page), "A late reply.") {
+		t.Errorf("archived page 1 of topic 100 does not hold the new reply")
+	}
+}
+
+// TestJITRefresh_NewTopicArchivedInSameRun checks that a topic the JIT refresh finds missing from
+// the index is archived in the run that found it, and added to the index.
+func TestJITRefresh_NewTopicArchivedInSameRun(t *testing.T) {
+	workDir := t.TempDir()
+	binPath := buildArchiver(t, workDir)
+	srv := testForum()
+	defer srv.Close()
+	writeIndex(t, workDir, srv, "100")
+	runArchiverBinary(t, binPath, workDir, srv, 1)
+
+	if _, err := os.Stat(filepath.Join(workDir, "archive", "66", "101", "page_1.html")); err != nil {
+		t.Errorf("new topic 101 was not archived: %v", err)
+	}
+	topicIndex, err := os.ReadFile(filepath.Join(workDir, "topic_index_66.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(topicIndex), `"101"`) {
+		t.Errorf("new topic 101 was not added to the topic index")
+	}
+}
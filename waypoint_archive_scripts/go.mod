@@ -4,10 +4,12 @@ go 1.23.0
 
 toolchain go1.24.3
 
-require github.com/PuerkitoBio/goquery v1.9.1
+require (
+	github.com/PuerkitoBio/goquery v1.9.1
+	github.com/andybalholm/cascadia v1.3.2
+)
 
 require (
-	github.com/andybalholm/cascadia v1.3.2 // indirect
 	golang.org/x/net v0.21.0
 	golang.org/x/text v0.25.0
 )
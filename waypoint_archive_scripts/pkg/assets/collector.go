@@ -0,0 +1,231 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"waypoint_archive_scripts/pkg/downloader"
+)
+
+// DefaultMaxAttempts is how often a failing asset is tried before the collector gives up on it.
+const DefaultMaxAttempts = 3
+
+// Collector downloads assets into a content-addressed store and records them in a manifest.
+// Assets already stored or gone are not downloaded again, so a collector can be run over the
+// whole archive repeatedly and only fetches what is new.
+type Collector struct {
+	Downloader  *downloader.Downloader
+	Dir         string    // Root of the asset store
+	Manifest    *Manifest // URL to stored file
+	Hosts       []string  // If set, only assets on these hosts are downloaded
+	MaxAttempts int       // Failed downloads are retried on later runs up to this many attempts
+
+	queued map[string]bool // URLs seen in this run, deduplicating references across pages
+}
+
+// CollectStats counts what a collector did.
+type CollectStats struct {
+	Referenced   int // Distinct asset URLs seen
+	Downloaded   int // Assets downloaded and stored
+	Deduplicated int // Downloaded assets whose content was already stored under another URL
+	Known        int // Assets already stored, gone or out of attempts, not downloaded again
+	SkippedHost  int // Assets on hosts not in Hosts
+	Gone         int // Assets the server answered 404 or 410 for
+	Failed       int
+}
+
+// NewCollector creates a Collector storing assets under dir.
+func NewCollector(d *downloader.Downloader, dir string, manifest *Manifest) *Collector {
+	return &Collector{
+		Downloader:  d,
+		Dir:         dir,
+		Manifest:    manifest,
+		MaxAttempts: DefaultMaxAttempts,
+		queued:      make(map[string]bool),
+	}
+}
+
+// Collect downloads the referenced assets not yet stored. Stylesheets are scanned for the images
+// and stylesheets they reference in turn, whether downloaded now or on an earlier run.
+func (c *Collector) Collect(refs []Ref, stats *CollectStats) {
+	for len(refs) > 0 {
+		ref := refs[0]
+		refs = refs[1:]
+		if c.queued[ref.URL] {
+			continue
+		}
+		c.queued[ref.URL] = true
+		stats.Referenced++
+
+		if !c.allowedHost(ref.URL) {
+			stats.SkippedHost++
+			continue
+		}
+		entry, known := c.Manifest.Lookup(ref.URL)
+		if known && !c.retry(entry) {
+			stats.Known++
+		} else {
+			entry = c.download(ref, entry, stats)
+		}
+		if entry.Status == StatusStored && entry.Kind == KindStylesheet {
+			refs = append(refs, c.stylesheetRefs(entry)...)
+		}
+	}
+}
+
+// retry reports whether an asset in the manifest is downloaded again: it failed with attempts
+// left, or its stored file has gone missing.
+func (c *Collector) retry(entry Entry) bool {
+	switch entry.Status {
+	case StatusFailed:
+		return entry.Attempts < c.MaxAttempts
+	case StatusStored:
+		_, err := os.Stat(c.Path(entry))
+		return err != nil
+	}
+	return false
+}
+
+// download fetches an asset, stores its content unless already stored, and records the outcome.
+func (c *Collector) download(ref Ref, entry Entry, stats *CollectStats) Entry {
+	entry.URL = ref.URL
+	entry.Kind = ref.Kind
+	entry.FetchedAt = time.Now().UTC()
+	entry.Attempts++
+
+	content, result, err := c.Downloader.FetchAsset(ref.URL)
+	if err == nil {
+		err = c.store(&entry, content, result, stats)
+	}
+	if err != nil {
+		entry.Status = StatusFailed
+		entry.Error = err.Error()
+		if downloader.IsGone(err) {
+			entry.Status = StatusGone
+			stats.Gone++
+		} else {
+			stats.Failed++
+			log.Printf("[WARNING] ASSETS: Failed to download %s (attempt %d of %d): %v", ref.URL, entry.Attempts, c.MaxAttempts, err)
+		}
+	}
+	c.Manifest.Put(entry)
+	return entry
+}
+
+// store writes downloaded content to the store, named by its digest, and fills in the entry.
+func (c *Collector) store(entry *Entry, content []byte, result *downloader.FetchResult, stats *CollectStats) error {
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+	file := digest[:2] + "/" + digest + extension(entry.URL, result.ContentType)
+	storePath := filepath.Join(c.Dir, filepath.FromSlash(file))
+
+	if _, err := os.Stat(storePath); err == nil {
+		stats.Deduplicated++
+	} else {
+		if err := os.MkdirAll(filepath.Dir(storePath), 0755); err != nil {
+			return fmt.Errorf("failed to create asset directory %s: %w", filepath.Dir(storePath), err)
+		}
+		tempPath := storePath + ".tmp"
+		if err := os.WriteFile(tempPath, content, 0644); err != nil {
+			return fmt.Errorf("failed to write asset %s: %w", tempPath, err)
+		}
+		if err := os.Rename(tempPath, storePath); err != nil {
+			return fmt.Errorf("failed to rename temporary asset %s to %s: %w", tempPath, storePath, err)
+		}
+	}
+
+	entry.Status = StatusStored
+	entry.File = file
+	entry.SHA256 = digest
+	entry.Size = int64(len(content))
+	entry.ContentType = result.ContentType
+	entry.FinalURL = ""
+	if result.Redirected {
+		entry.FinalURL = result.FinalURL
+	}
+	entry.Error = ""
+	stats.Downloaded++
+	return nil
+}
+
+// stylesheetRefs reads a stored stylesheet and returns what it references, resolved against the
+// URL it was served from.
+func (c *Collector) stylesheetRefs(entry Entry) []Ref {
+	css, err := os.ReadFile(c.Path(entry))
+	if err != nil {
+		log.Printf("[WARNING] ASSETS: Failed to read stored stylesheet %s: %v", entry.URL, err)
+		return nil
+	}
+	base := entry.URL
+	if entry.FinalURL != "" {
+		base = entry.FinalURL
+	}
+	return ExtractFromCSS(string(css), base)
+}
+
+// Path returns where an entry's content is stored.
+func (c *Collector) Path(entry Entry) string {
+	return filepath.Join(c.Dir, filepath.FromSlash(entry.File))
+}
+
+func (c *Collector) allowedHost(assetURL string) bool {
+	if len(c.Hosts) == 0 {
+		return true
+	}
+	parsed, err := url.Parse(assetURL)
+	if err != nil {
+		return false
+	}
+	for _, host := range c.Hosts {
+		if strings.EqualFold(parsed.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeExtensions are the extensions of common asset types; the URL's own extension is used for
+// others. The served type wins, as URLs such as download/file.php?id=1 say nothing of the file.
+var typeExtensions = map[string]string{
+	"image/gif":                ".gif",
+	"image/jpeg":               ".jpg",
+	"image/png":                ".png",
+	"image/webp":               ".webp",
+	"image/svg+xml":            ".svg",
+	"image/bmp":                ".bmp",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+	"text/css":                 ".css",
+	"application/pdf":          ".pdf",
+	"application/zip":          ".zip",
+}
+
+// scriptExtensions name server scripts, not the files they serve.
+var scriptExtensions = map[string]bool{".php": true, ".asp": true, ".aspx": true, ".cgi": true, ".pl": true, ".jsp": true}
+
+// extension picks the file extension of a stored asset.
+func extension(assetURL string, contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if ext, ok := typeExtensions[strings.ToLower(mediaType)]; ok {
+			return ext
+		}
+	}
+	parsed, err := url.Parse(assetURL)
+	if err != nil {
+		return ""
+	}
+	ext := strings.ToLower(path.Ext(parsed.Path))
+	if len(ext) < 2 || len(ext) > 6 || scriptExtensions[ext] || strings.ContainsAny(ext, "%+") {
+		return ""
+	}
+	return ext
+}
@@ -0,0 +1,149 @@
+package assets
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"waypoint_archive_scripts/pkg/config"
+	"waypoint_archive_scripts/pkg/downloader"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// assetServer serves a stylesheet referencing an image, the same image under two URLs, an
+// attachment that fails once and a missing file. It counts requests per path.
+func assetServer(t *testing.T) (*httptest.Server, map[string]int) {
+	t.Helper()
+	var mux sync.Mutex
+	requests := make(map[string]int)
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		requests[r.URL.Path]++
+		mux.Unlock()
+		switch r.URL.Path {
+		case "/cafe.css":
+			w.Header().Set("Content-Type", "text/css")
+			fmt.Fprint(w, `body { background: url(images/bg.gif) }`)
+		case "/images/bg.gif", "/images/bg_copy.gif":
+			w.Header().Set("Content-Type", "image/gif")
+			w.Write([]byte("GIF89a\x01\x00\x01\x00\xff"))
+		case "/download/file.php":
+			if !failed {
+				failed = true
+				http.Error(w, "busy", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/pdf")
+			fmt.Fprint(w, "%PDF-1.4")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestCollector(t *testing.T) {
+	server, requests := assetServer(t)
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "asset_manifest.json")
+	d := downloader.NewDownloader(&config.Config{UserAgent: "TestAgent/1.0"})
+	refs := []Ref{
+		{server.URL + "/cafe.css", KindStylesheet},
+		{server.URL + "/images/bg_copy.gif", KindImage},
+		{server.URL + "/download/file.php?id=7", KindAttachment},
+		{server.URL + "/images/missing.gif", KindImage},
+		{server.URL + "/images/bg_copy.gif", KindImage},
+		{"http://elsewhere.example.com/a.gif", KindImage},
+	}
+
+	collector := NewCollector(d, filepath.Join(dir, "assets"), NewManifest())
+	collector.Hosts = []string{"127.0.0.1"}
+	var stats CollectStats
+	collector.Collect(refs, &stats)
+	want := CollectStats{Referenced: 6, Downloaded: 3, Deduplicated: 1, SkippedHost: 1, Gone: 1, Failed: 1}
+	if stats != want {
+		t.Errorf("first run stats = %+v, want %+v", stats, want)
+	}
+
+	css, ok := collector.Manifest.Lookup(server.URL + "/cafe.css")
+	if !ok || css.Status != StatusStored || !strings.HasSuffix(css.File, ".css") || css.File[:2] != css.SHA256[:2] {
+		t.Errorf("stylesheet entry = %+v, want it stored under its digest", css)
+	}
+	image, _ := collector.Manifest.Lookup(server.URL + "/images/bg.gif")
+	imageCopy, _ := collector.Manifest.Lookup(server.URL + "/images/bg_copy.gif")
+	if image.File == "" || image.File != imageCopy.File || !strings.HasSuffix(image.File, ".gif") {
+		t.Errorf("identical images stored as %q and %q, want one file", image.File, imageCopy.File)
+	}
+	if content, err := os.ReadFile(collector.Path(image)); err != nil || string(content) != "GIF89a\x01\x00\x01\x00\xff" {
+		t.Errorf("stored image = %q, %v", content, err)
+	}
+	if missing, _ := collector.Manifest.Lookup(server.URL + "/images/missing.gif"); missing.Status != StatusGone {
+		t.Errorf("missing image entry = %+v, want gone", missing)
+	}
+	if attachment, _ := collector.Manifest.Lookup(server.URL + "/download/file.php?id=7"); attachment.Status != StatusFailed || attachment.Attempts != 1 {
+		t.Errorf("attachment entry = %+v, want failed after one attempt", attachment)
+	}
+	if err := collector.Manifest.Save(manifestPath); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// A second run only retries the failed attachment
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	collector = NewCollector(d, filepath.Join(dir, "assets"), manifest)
+	stats = CollectStats{}
+	collector.Collect(refs[:4], &stats)
+	want = CollectStats{Referenced: 5, Downloaded: 1, Known: 4}
+	if stats != want {
+		t.Errorf("second run stats = %+v, want %+v", stats, want)
+	}
+	if file, ok := manifest.LocalFile(server.URL + "/download/file.php?id=7"); !ok || !strings.HasSuffix(file, ".pdf") {
+		t.Errorf("LocalFile(attachment) = %q, %v, want a stored .pdf", file, ok)
+	}
+	if _, ok := manifest.LocalFile(server.URL + "/images/missing.gif"); ok {
+		t.Error("LocalFile(missing image) should report false")
+	}
+	if requests["/images/bg.gif"] != 1 || requests["/images/missing.gif"] != 1 || requests["/download/file.php"] != 2 {
+		t.Errorf("unexpected requests %v", requests)
+	}
+}
+
+func TestLoadManifest_Missing(t *testing.T) {
+	manifest, err := LoadManifest(filepath.Join(t.TempDir(), "none.json"))
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if len(manifest.Entries()) != 0 {
+		t.Errorf("expected an empty manifest, got %v", manifest.Entries())
+	}
+}
+
+func TestExtension(t *testing.T) {
+	tests := []struct{ url, contentType, want string }{
+		{"http://example.com/images/a.GIF", "", ".gif"},
+		{"http://example.com/download/file.php?id=1", "image/jpeg", ".jpg"},
+		{"http://example.com/download/file.php?id=1", "application/octet-stream", ""},
+		{"http://example.com/files/trick.mp3", "application/octet-stream", ".mp3"},
+		{"http://example.com/style", "text/css; charset=utf-8", ".css"},
+	}
+	for _, tt := range tests {
+		if got := extension(tt.url, tt.contentType); got != tt.want {
+			t.Errorf("extension(%q, %q) = %q, want %q", tt.url, tt.contentType, got, tt.want)
+		}
+	}
+}
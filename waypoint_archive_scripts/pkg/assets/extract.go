@@ -0,0 +1,241 @@
+// Package assets archives the files archived pages reference: images, avatars, smilies,
+// stylesheets, icons and attachments. Asset URLs are extracted from the pages (and from the
+// stylesheets themselves), deduplicated across the whole archive, downloaded politely and stored
+// content-addressed, so a file referenced under many URLs is kept once. A manifest maps every
+// URL to its stored file, for the offline mirror and the extractor to use local copies.
+//
+// Store layout:
+//
+//	{asset_dir}/{sha256[:2]}/{sha256}{ext}
+package assets
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Kind is what an asset is used as.
+type Kind string
+
+const (
+	KindImage      Kind = "image"      // Images in posts and page chrome (smilies, buttons, ...)
+	KindAvatar     Kind = "avatar"     // User avatars
+	KindStylesheet Kind = "stylesheet" // CSS, including stylesheets imported by other stylesheets
+	KindIcon       Kind = "icon"       // Favicons
+	KindAttachment Kind = "attachment" // Files linked from posts (documents, archives, full-size images)
+)
+
+// Ref is an asset URL found in a page or stylesheet.
+type Ref struct {
+	URL  string
+	Kind Kind
+}
+
+// attachmentExtensions are the file types a link is taken to download rather than navigate to.
+var attachmentExtensions = map[string]bool{
+	".zip": true, ".rar": true, ".7z": true, ".gz": true, ".pdf": true, ".doc": true, ".docx": true,
+	".txt": true, ".rtf": true, ".mp3": true, ".wav": true, ".mp4": true, ".avi": true, ".mov": true,
+	".wmv": true, ".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true, ".webp": true,
+}
+
+// ExtractFromPage returns the assets an archived page references, resolved against pageURL (or
+// the page's <base href>) and deduplicated, in document order. Only http and https URLs are
+// returned; data: URIs and the like have nothing to download.
+func ExtractFromPage(doc *goquery.Document, pageURL string) []Ref {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		base = &url.URL{}
+	}
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if resolved, err := base.Parse(strings.TrimSpace(href)); err == nil {
+			base = resolved
+		}
+	}
+
+	var refs []Ref
+	seen := make(map[string]bool)
+	add := func(raw string, kind Kind) {
+		resolved, ok := Resolve(base, raw)
+		if !ok || seen[resolved] {
+			return
+		}
+		seen[resolved] = true
+		refs = append(refs, Ref{URL: resolved, Kind: kind})
+	}
+
+	doc.Find("img[src], input[type=image][src]").Each(func(_ int, element *goquery.Selection) {
+		src := element.AttrOr("src", "")
+		add(src, imageKind(src))
+	})
+	doc.Find("link[href]").Each(func(_ int, element *goquery.Selection) {
+		if kind, ok := linkKind(element.AttrOr("rel", "")); ok {
+			add(element.AttrOr("href", ""), kind)
+		}
+	})
+	doc.Find("[background]").Each(func(_ int, element *goquery.Selection) {
+		add(element.AttrOr("background", ""), KindImage)
+	})
+	doc.Find("[style]").Each(func(_ int, element *goquery.Selection) {
+		for _, ref := range cssRefs(element.AttrOr("style", "")) {
+			add(ref.URL, ref.Kind)
+		}
+	})
+	doc.Find("style").Each(func(_ int, element *goquery.Selection) {
+		for _, ref := range cssRefs(element.Text()) {
+			add(ref.URL, ref.Kind)
+		}
+	})
+	doc.Find("a[href]").Each(func(_ int, element *goquery.Selection) {
+		if resolved, ok := Resolve(base, element.AttrOr("href", "")); ok && IsAttachment(resolved) {
+			add(resolved, KindAttachment)
+		}
+	})
+	return refs
+}
+
+// ExtractFromCSS returns the images, fonts and imported stylesheets a stylesheet references,
+// resolved against the stylesheet's own URL.
+func ExtractFromCSS(css string, cssURL string) []Ref {
+	base, err := url.Parse(cssURL)
+	if err != nil {
+		return nil
+	}
+	var refs []Ref
+	seen := make(map[string]bool)
+	for _, ref := range cssRefs(css) {
+		if resolved, ok := Resolve(base, ref.URL); ok && !seen[resolved] {
+			seen[resolved] = true
+			refs = append(refs, Ref{URL: resolved, Kind: ref.Kind})
+		}
+	}
+	return refs
+}
+
+// cssReference matches url(...) and @import "..." in CSS. Groups: 1 @import, 2-4 the url()
+// argument (double-quoted, single-quoted, bare), 5-6 a quoted string, meaningful after @import.
+var cssReference = regexp.MustCompile(`(?i)(@import\s+)?(?:url\(\s*(?:"([^"]*)"|'([^']*)'|([^'"\)\s]*))\s*\)|"([^"]*)"|'([^']*)')`)
+
+// cssRefs returns the unresolved references in CSS text.
+func cssRefs(css string) []Ref {
+	var refs []Ref
+	for _, match := range cssReference.FindAllStringSubmatch(css, -1) {
+		if ref, ok := cssMatchRef(match); ok {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+func cssMatchRef(match []string) (Ref, bool) {
+	imported := match[1] != ""
+	target := match[2] + match[3] + match[4]
+	if !isURLMatch(match) {
+		if !imported {
+			return Ref{}, false // A string that is not an import, e.g. a font name
+		}
+		target = match[5] + match[6]
+	}
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return Ref{}, false
+	}
+	if imported {
+		return Ref{URL: target, Kind: KindStylesheet}, true
+	}
+	return Ref{URL: target, Kind: imageKind(target)}, true
+}
+
+// RewriteCSS replaces the references in CSS text by what rewrite returns for them, e.g. the
+// paths of local copies. References rewrite returns "" for are left unchanged.
+func RewriteCSS(css string, rewrite func(ref Ref) string) string {
+	var out strings.Builder
+	last := 0
+	for _, indexes := range cssReference.FindAllStringSubmatchIndex(css, -1) {
+		match := make([]string, len(indexes)/2)
+		for i := range match {
+			if indexes[2*i] >= 0 {
+				match[i] = css[indexes[2*i]:indexes[2*i+1]]
+			}
+		}
+		ref, ok := cssMatchRef(match)
+		if !ok {
+			continue
+		}
+		replacement := rewrite(ref)
+		if replacement == "" {
+			continue
+		}
+		out.WriteString(css[last:indexes[0]])
+		out.WriteString(match[1])
+		quoted := strings.ReplaceAll(replacement, `"`, "%22")
+		if isURLMatch(match) {
+			out.WriteString(`url("` + quoted + `")`)
+		} else {
+			out.WriteString(`"` + quoted + `"`)
+		}
+		last = indexes[1]
+	}
+	out.WriteString(css[last:])
+	return out.String()
+}
+
+// isURLMatch reports whether a cssReference match is a url(...) rather than a quoted string.
+func isURLMatch(match []string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimPrefix(match[0], match[1])), "url(")
+}
+
+// Resolve resolves an asset reference against base. It reports false for references that do not
+// name a downloadable http or https URL. The fragment is dropped, as it does not change the file.
+func Resolve(base *url.URL, raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.HasPrefix(raw, "#") {
+		return "", false
+	}
+	resolved, err := base.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return "", false
+	}
+	resolved.Fragment = ""
+	return resolved.String(), true
+}
+
+// IsAttachment reports whether a link downloads a file rather than opening a page: it names a
+// file type in attachmentExtensions, or is a phpBB attachment download.
+func IsAttachment(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	if strings.HasSuffix(parsed.Path, "/download/file.php") && parsed.Query().Get("id") != "" {
+		return true
+	}
+	return attachmentExtensions[strings.ToLower(path.Ext(parsed.Path))]
+}
+
+func imageKind(src string) Kind {
+	if strings.Contains(strings.ToLower(src), "avatar") {
+		return KindAvatar
+	}
+	return KindImage
+}
+
+// linkKind classifies a <link> by its rel; links that do not load an asset (canonical, alternate
+// feeds, ...) report false.
+func linkKind(rel string) (Kind, bool) {
+	for _, value := range strings.Fields(strings.ToLower(rel)) {
+		switch value {
+		case "stylesheet":
+			return KindStylesheet, true
+		case "icon", "apple-touch-icon":
+			return KindIcon, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,107 @@
+package assets
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractFromPage(t *testing.T) {
+	page := `<html><head>
+<link rel="stylesheet" type="text/css" href="cafe.css">
+<link rel="SHORTCUT ICON" href="../cafe.ico">
+<link rel="canonical" href="viewtopic.php?topic=1">
+<style>td.header { background: url('images/header_bg.gif') }</style>
+</head><body background="images/bg.gif">
+<img src="images/avatars/1234.jpg"><img src="images/smiles/smile.gif"><img src="images/smiles/smile.gif#again">
+<img src="data:image/gif;base64,R0lGOD"><img src="">
+<input type="image" src="images/go.gif">
+<div style="background-image: url(&quot;images/quote.gif&quot;)">
+<a href="viewtopic.php?topic=2&forum=66">Topic</a>
+<a href="http://example.com/files/routine.pdf">Routine</a>
+<a href="https://example.com/forum/download/file.php?id=7">Attachment</a>
+</div></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("failed to parse page: %v", err)
+	}
+
+	got := ExtractFromPage(doc, "https://www.themagiccafe.com/forums/viewtopic.php?topic=1&forum=66")
+	base := "https://www.themagiccafe.com/forums/"
+	want := []Ref{
+		{base + "images/avatars/1234.jpg", KindAvatar},
+		{base + "images/smiles/smile.gif", KindImage},
+		{base + "images/go.gif", KindImage},
+		{base + "cafe.css", KindStylesheet},
+		{"https://www.themagiccafe.com/cafe.ico", KindIcon},
+		{base + "images/bg.gif", KindImage},
+		{base + "images/quote.gif", KindImage},
+		{base + "images/header_bg.gif", KindImage},
+		{"http://example.com/files/routine.pdf", KindAttachment},
+		{"https://example.com/forum/download/file.php?id=7", KindAttachment},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractFromPage() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestExtractFromPage_BaseHref(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><head><base href="http://cdn.example.com/static/"></head><body><img src="a.png"></body></html>`))
+	if err != nil {
+		t.Fatalf("failed to parse page: %v", err)
+	}
+	got := ExtractFromPage(doc, "http://forum.example.com/viewtopic.php?t=1")
+	if len(got) != 1 || got[0].URL != "http://cdn.example.com/static/a.png" {
+		t.Errorf("ExtractFromPage() = %v, want the image resolved against <base href>", got)
+	}
+}
+
+func TestExtractFromCSS(t *testing.T) {
+	css := `@import "print.css";
+@import url(theme/colors.css);
+body { background: url( "../images/bg.gif" ) no-repeat; font-family: "Verdana", sans-serif; }
+.logo { background-image: url(data:image/png;base64,AAAA); }
+.avatar { background: url('/img/avatar_default.png'); }`
+
+	got := ExtractFromCSS(css, "http://forum.example.com/styles/cafe.css")
+	want := []Ref{
+		{"http://forum.example.com/styles/print.css", KindStylesheet},
+		{"http://forum.example.com/styles/theme/colors.css", KindStylesheet},
+		{"http://forum.example.com/images/bg.gif", KindImage},
+		{"http://forum.example.com/img/avatar_default.png", KindAvatar},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractFromCSS() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestRewriteCSS(t *testing.T) {
+	css := `@import "print.css"; @import url(other.css); body { background: url('bg.gif'); font-family: "Verdana"; } a { background: url(keep.gif) }`
+	got := RewriteCSS(css, func(ref Ref) string {
+		if ref.URL == "keep.gif" {
+			return ""
+		}
+		return "../local/" + ref.URL
+	})
+	want := `@import "../local/print.css"; @import url("../local/other.css"); body { background: url("../local/bg.gif"); font-family: "Verdana"; } a { background: url(keep.gif) }`
+	if got != want {
+		t.Errorf("RewriteCSS() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestIsAttachment(t *testing.T) {
+	tests := map[string]bool{
+		"http://example.com/a/Routine.PDF":                true,
+		"http://example.com/forum/download/file.php?id=3": true,
+		"http://example.com/forum/download/file.php":      false,
+		"http://example.com/viewtopic.php?topic=1":        false,
+		"http://example.com/pictures/":                    false,
+	}
+	for link, want := range tests {
+		if got := IsAttachment(link); got != want {
+			t.Errorf("IsAttachment(%q) = %v, want %v", link, got, want)
+		}
+	}
+}
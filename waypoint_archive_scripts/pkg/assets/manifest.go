@@ -0,0 +1,138 @@
+package assets
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status is the outcome of downloading an asset.
+type Status string
+
+const (
+	StatusStored Status = "stored" // Downloaded; File holds the content
+	StatusGone   Status = "gone"   // The server answered 404 or 410; not retried
+	StatusFailed Status = "failed" // Other errors; retried until the attempts run out
+)
+
+// Entry records an asset URL and, once downloaded, the file holding its content.
+type Entry struct {
+	URL         string    `json:"url"`
+	Kind        Kind      `json:"kind"`
+	Status      Status    `json:"status"`
+	File        string    `json:"file,omitempty"`   // Path in the asset store, slash-separated: {sha256[:2]}/{sha256}{ext}
+	SHA256      string    `json:"sha256,omitempty"` // Hex digest of the content
+	Size        int64     `json:"size,omitempty"`
+	ContentType string    `json:"content_type,omitempty"` // As served
+	FinalURL    string    `json:"final_url,omitempty"`    // Set if the download was redirected
+	FetchedAt   time.Time `json:"fetched_at"`             // Last attempt
+	Attempts    int       `json:"attempts"`
+	Error       string    `json:"error,omitempty"` // Last error, for gone and failed assets
+}
+
+// Manifest maps asset URLs to their stored files.
+type Manifest struct {
+	Assets map[string]*Entry `json:"assets"` // URL to entry
+
+	mux sync.Mutex
+}
+
+// NewManifest creates an empty Manifest.
+func NewManifest() *Manifest {
+	return &Manifest{Assets: make(map[string]*Entry)}
+}
+
+// LoadManifest loads a manifest from filePath. A missing file yields an empty manifest.
+func LoadManifest(filePath string) (*Manifest, error) {
+	manifestBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("[INFO] ASSETS: Manifest %s not found. Starting with an empty manifest.", filePath)
+			return NewManifest(), nil
+		}
+		return nil, fmt.Errorf("failed to read asset manifest %s: %w", filePath, err)
+	}
+
+	manifest := NewManifest()
+	if len(manifestBytes) == 0 {
+		return manifest, nil
+	}
+	if err := json.Unmarshal(manifestBytes, manifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal asset manifest %s: %w", filePath, err)
+	}
+	if manifest.Assets == nil {
+		manifest.Assets = make(map[string]*Entry)
+	}
+	return manifest, nil
+}
+
+// Save writes the manifest to filePath atomically via a temporary file.
+func (m *Manifest) Save(filePath string) error {
+	m.mux.Lock()
+	manifestBytes, err := json.MarshalIndent(m, "", "  ")
+	m.mux.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal asset manifest: %w", err)
+	}
+
+	dir := filepath.Dir(filePath)
+	if dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory for asset manifest %s: %w", dir, err)
+		}
+	}
+
+	tempFilePath := filePath + ".tmp"
+	if err := os.WriteFile(tempFilePath, manifestBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write asset manifest to temporary file %s: %w", tempFilePath, err)
+	}
+	if err := os.Rename(tempFilePath, filePath); err != nil {
+		return fmt.Errorf("failed to rename temporary asset manifest %s to %s: %w", tempFilePath, filePath, err)
+	}
+	return nil
+}
+
+// Lookup returns the entry for an asset URL.
+func (m *Manifest) Lookup(assetURL string) (Entry, bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	entry, ok := m.Assets[assetURL]
+	if !ok {
+		return Entry{}, false
+	}
+	return *entry, true
+}
+
+// LocalFile returns the stored file of an asset URL, slash-separated and relative to the asset
+// store, if the asset was downloaded.
+func (m *Manifest) LocalFile(assetURL string) (string, bool) {
+	entry, ok := m.Lookup(assetURL)
+	if !ok || entry.Status != StatusStored || entry.File == "" {
+		return "", false
+	}
+	return entry.File, true
+}
+
+// Put records an entry, replacing any entry for the same URL.
+func (m *Manifest) Put(entry Entry) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.Assets[entry.URL] = &entry
+}
+
+// Entries returns all entries, ordered by URL.
+func (m *Manifest) Entries() []Entry {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	entries := make([]Entry, 0, len(m.Assets))
+	for _, entry := range m.Assets {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].URL < entries[j].URL })
+	return entries
+}
@@ -0,0 +1,325 @@
+// Package canonurl is the single place where forum URLs are parsed, normalized and formatted.
+// The indexer, archiver, JIT refresh and extractor all derive sub-forum, topic, page and post
+// identifiers and dedup keys from it, so the same page always gets the same ID and the same URL
+// regardless of which component saw it first or which link variant it came from.
+package canonurl
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// SubForumID identifies a sub-forum (the 'forum' query parameter).
+type SubForumID string
+
+// TopicID identifies a topic (the 'topic' query parameter).
+type TopicID string
+
+// PostID identifies a single post (the 'post' query parameter).
+type PostID string
+
+// DefaultPostsPerPage is the number of posts Magic Cafe shows on each topic page.
+const DefaultPostsPerPage = 20
+
+// DefaultBaseURL is used for URLs built from identifiers alone (e.g. post links in extracted data).
+const DefaultBaseURL = "https://www.themagiccafe.com/forums/"
+
+const (
+	forumScript = "viewforum.php"
+	topicScript = "viewtopic.php"
+)
+
+// Query parameter names accepted for each identifier. The first name is the canonical one
+// (the one the crawler uses); the others are phpBB-style aliases seen in older links.
+var (
+	forumParams = []string{"forum", "f"}
+	topicParams = []string{"topic", "t"}
+	postParams  = []string{"post", "p"}
+)
+
+// sessionParams are query parameters carrying session state. They never identify content
+// and are always stripped.
+var sessionParams = map[string]bool{
+	"sid":       true,
+	"s":         true,
+	"phpsessid": true,
+	"sessionid": true,
+}
+
+// sites lists the canonical hosts of known forums. Bare and "www." variants of a known host
+// are aliases of it, and known hosts are always served over https.
+var sites = map[string]bool{
+	"www.themagiccafe.com": true,
+}
+
+// Kind classifies what a URL points at.
+type Kind int
+
+const (
+	KindOther Kind = iota // Not a forum listing, topic or post URL
+	KindForum             // A sub-forum listing page (viewforum.php?forum=)
+	KindTopic             // A topic page (viewtopic.php?topic=)
+	KindPost              // A single post (viewtopic.php?post=)
+)
+
+// String returns a lower-case name for the kind, used in logs and dedup keys.
+func (k Kind) String() string {
+	switch k {
+	case KindForum:
+		return "forum"
+	case KindTopic:
+		return "topic"
+	case KindPost:
+		return "post"
+	default:
+		return "other"
+	}
+}
+
+// Ref is a parsed forum URL reduced to the parts that identify content.
+// Start is the 'start' post (or topic) offset of the page; 0 is the first page.
+type Ref struct {
+	Kind       Kind
+	Scheme     string
+	Host       string
+	Dir        string // Path up to and including the last '/', e.g. "/forums/"
+	SubForumID SubForumID
+	TopicID    TopicID
+	PostID     PostID
+	Start      int
+
+	other *url.URL // Cleaned URL for KindOther
+}
+
+// Parse parses an absolute forum URL. Session parameters and fragments are dropped,
+// the host is aliased to its canonical form, and 't'/'f'/'p' are read as 'topic'/'forum'/'post'.
+// URLs that are not listing, topic or post pages parse as KindOther.
+func Parse(rawURL string) (Ref, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return Ref{}, fmt.Errorf("canonurl: failed to parse URL '%s': %w", rawURL, err)
+	}
+	return FromURL(u)
+}
+
+// FromURL is Parse for an already parsed URL. u is not modified.
+func FromURL(u *url.URL) (Ref, error) {
+	if u == nil {
+		return Ref{}, fmt.Errorf("canonurl: nil URL")
+	}
+	scheme, host := CanonicalOrigin(u.Scheme, u.Host)
+	dir, script := path.Split(u.Path)
+	q := u.Query()
+
+	ref := Ref{
+		Scheme:     scheme,
+		Host:       host,
+		Dir:        dir,
+		SubForumID: SubForumID(firstParam(q, forumParams)),
+		TopicID:    TopicID(firstParam(q, topicParams)),
+		PostID:     PostID(firstParam(q, postParams)),
+	}
+	if startVal := q.Get("start"); startVal != "" {
+		start, err := strconv.Atoi(startVal)
+		if err != nil || start < 0 {
+			return Ref{}, fmt.Errorf("canonurl: invalid 'start' value '%s' in URL '%s'", startVal, u.String())
+		}
+		ref.Start = start
+	}
+
+	switch {
+	case strings.EqualFold(script, topicScript) && ref.PostID != "":
+		ref.Kind = KindPost
+	case strings.EqualFold(script, topicScript) && ref.TopicID != "":
+		ref.Kind = KindTopic
+	case strings.EqualFold(script, forumScript) && ref.SubForumID != "":
+		ref.Kind = KindForum
+		ref.TopicID, ref.PostID = "", ""
+	default:
+		other := *u
+		other.Scheme, other.Host = scheme, host
+		other.User = nil
+		other.Fragment = ""
+		other.RawQuery = stripSessionParams(q).Encode()
+		return Ref{Kind: KindOther, Scheme: scheme, Host: host, Dir: dir, other: &other}, nil
+	}
+	return ref, nil
+}
+
+// Canonicalize returns the canonical string form of rawURL. Non-forum URLs keep their
+// path and parameters but still lose session parameters and have their host aliased.
+func Canonicalize(rawURL string) (string, error) {
+	ref, err := Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return ref.String(), nil
+}
+
+// ForumPage returns a reference to a sub-forum listing page on the default site.
+func ForumPage(subForumID SubForumID, start int) Ref {
+	return defaultRef(Ref{Kind: KindForum, SubForumID: subForumID, Start: start})
+}
+
+// TopicPage returns a reference to a topic page on the default site.
+func TopicPage(subForumID SubForumID, topicID TopicID, start int) Ref {
+	return defaultRef(Ref{Kind: KindTopic, SubForumID: subForumID, TopicID: topicID, Start: start})
+}
+
+// Post returns a reference to a single post on the default site. start is the offset of the
+// topic page holding the post, or 0 if unknown.
+func Post(subForumID SubForumID, topicID TopicID, postID PostID, start int) Ref {
+	return defaultRef(Ref{Kind: KindPost, SubForumID: subForumID, TopicID: topicID, PostID: postID, Start: start})
+}
+
+// String formats the reference as its canonical URL. Query parameters are sorted and a
+// zero 'start' is omitted, so equal references always format identically.
+func (r Ref) String() string {
+	if r.Kind == KindOther {
+		if r.other == nil {
+			return ""
+		}
+		return r.other.String()
+	}
+
+	q := url.Values{}
+	script := topicScript
+	switch r.Kind {
+	case KindForum:
+		script = forumScript
+		q.Set("forum", string(r.SubForumID))
+	case KindTopic, KindPost:
+		if r.SubForumID != "" {
+			q.Set("forum", string(r.SubForumID))
+		}
+		if r.TopicID != "" {
+			q.Set("topic", string(r.TopicID))
+		}
+		if r.Kind == KindPost {
+			q.Set("post", string(r.PostID))
+		}
+	}
+	if r.Start > 0 {
+		q.Set("start", strconv.Itoa(r.Start))
+	}
+
+	u := url.URL{Scheme: r.Scheme, Host: r.Host, Path: r.Dir + script, RawQuery: q.Encode()}
+	return u.String()
+}
+
+// Key returns a host- and scheme-independent dedup key for the referenced content.
+// Topic and post keys deliberately leave out the sub-forum, because topics keep their ID when
+// they are moved between sub-forums. Non-forum URLs use their canonical URL as the key.
+func (r Ref) Key() string {
+	switch r.Kind {
+	case KindForum:
+		return fmt.Sprintf("forum:%s:%d", r.SubForumID, r.Start)
+	case KindTopic:
+		return fmt.Sprintf("topic:%s:%d", r.TopicID, r.Start)
+	case KindPost:
+		return fmt.Sprintf("post:%s", r.PostID)
+	default:
+		return r.String()
+	}
+}
+
+// Page returns the 1-based page number of the reference for the given page size.
+func (r Ref) Page(perPage int) int {
+	return PageForStart(r.Start, perPage)
+}
+
+// WithStart returns a copy of the reference pointing at the page starting at offset start.
+func (r Ref) WithStart(start int) Ref {
+	if start < 0 {
+		start = 0
+	}
+	r.Start = start
+	return r
+}
+
+// StartForPage returns the 'start' offset of a 1-based page number. Pages below 1 map to 0.
+func StartForPage(pageNum int, perPage int) int {
+	if perPage <= 0 {
+		perPage = DefaultPostsPerPage
+	}
+	if pageNum < 1 {
+		return 0
+	}
+	return (pageNum - 1) * perPage
+}
+
+// PageForStart returns the 1-based page number holding offset start.
+func PageForStart(start int, perPage int) int {
+	if perPage <= 0 {
+		perPage = DefaultPostsPerPage
+	}
+	if start < 0 {
+		start = 0
+	}
+	return start/perPage + 1
+}
+
+// CanonicalOrigin lower-cases scheme and host, drops default ports and maps known forum
+// hosts (and their bare or "www." aliases) to their canonical https origin.
+func CanonicalOrigin(scheme string, host string) (string, string) {
+	scheme = strings.ToLower(scheme)
+	host = strings.ToLower(host)
+	if h, port, err := net.SplitHostPort(host); err == nil && (port == "80" || port == "443") {
+		host = h
+	}
+	if canonical, ok := canonicalSiteHost(host); ok {
+		return "https", canonical
+	}
+	return scheme, host
+}
+
+// canonicalSiteHost returns the canonical host for host if it is a known forum host or an alias of one.
+func canonicalSiteHost(host string) (string, bool) {
+	if sites[host] {
+		return host, true
+	}
+	if sites["www."+host] {
+		return "www." + host, true
+	}
+	if bare := strings.TrimPrefix(host, "www."); bare != host && sites[bare] {
+		return bare, true
+	}
+	return "", false
+}
+
+// firstParam returns the first non-empty value among names.
+func firstParam(q url.Values, names []string) string {
+	for _, name := range names {
+		if v := strings.TrimSpace(q.Get(name)); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// stripSessionParams returns q without session parameters.
+func stripSessionParams(q url.Values) url.Values {
+	cleaned := url.Values{}
+	for k, vs := range q {
+		if sessionParams[strings.ToLower(k)] {
+			continue
+		}
+		cleaned[k] = vs
+	}
+	return cleaned
+}
+
+// defaultRef fills the origin of r from DefaultBaseURL.
+func defaultRef(r Ref) Ref {
+	base, _ := url.Parse(DefaultBaseURL)
+	r.Scheme, r.Host = CanonicalOrigin(base.Scheme, base.Host)
+	r.Dir = base.Path
+	if r.Start < 0 {
+		r.Start = 0
+	}
+	return r
+}
@@ -0,0 +1,117 @@
+package canonurl
+
+import "testing"
+
+func TestParse_TopicVariantsShareCanonicalForm(t *testing.T) {
+	want := "https://www.themagiccafe.com/forums/viewtopic.php?forum=66&start=20&topic=19618"
+	variants := []string{
+		"https://www.themagiccafe.com/forums/viewtopic.php?topic=19618&forum=66&start=20",
+		"http://themagiccafe.com/forums/viewtopic.php?t=19618&f=66&start=20",
+		"HTTPS://WWW.TheMagicCafe.com:443/forums/viewtopic.php?topic=19618&forum=66&start=20&sid=abc123#p5",
+		"http://www.themagiccafe.com:80/forums/viewtopic.php?forum=66&start=20&topic=19618&PHPSESSID=deadbeef",
+	}
+	for _, raw := range variants {
+		ref, err := Parse(raw)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", raw, err)
+		}
+		if ref.Kind != KindTopic {
+			t.Errorf("Parse(%q).Kind = %v, want topic", raw, ref.Kind)
+		}
+		if got := ref.String(); got != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", raw, got, want)
+		}
+		if got := ref.Key(); got != "topic:19618:20" {
+			t.Errorf("Parse(%q).Key() = %q, want topic:19618:20", raw, got)
+		}
+	}
+}
+
+func TestParse_Kinds(t *testing.T) {
+	tests := []struct {
+		raw   string
+		kind  Kind
+		forum SubForumID
+		topic TopicID
+		post  PostID
+		start int
+	}{
+		{"https://www.themagiccafe.com/forums/viewforum.php?forum=66&start=30", KindForum, "66", "", "", 30},
+		{"https://www.themagiccafe.com/forums/viewtopic.php?topic=19618&forum=66", KindTopic, "66", "19618", "", 0},
+		{"https://www.themagiccafe.com/forums/viewtopic.php?topic=19618&post=123", KindPost, "", "19618", "123", 0},
+		{"https://forum.example.com/viewtopic.php?p=77", KindPost, "", "", "77", 0},
+		{"https://www.themagiccafe.com/forums/index.php", KindOther, "", "", "", 0},
+		{"https://www.themagiccafe.com/forums/viewforum.php", KindOther, "", "", "", 0},
+	}
+	for _, tt := range tests {
+		ref, err := Parse(tt.raw)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.raw, err)
+		}
+		if ref.Kind != tt.kind || ref.SubForumID != tt.forum || ref.TopicID != tt.topic || ref.PostID != tt.post || ref.Start != tt.start {
+			t.Errorf("Parse(%q) = %+v, want kind %v forum %q topic %q post %q start %d", tt.raw, ref, tt.kind, tt.forum, tt.topic, tt.post, tt.start)
+		}
+	}
+}
+
+func TestParse_InvalidStart(t *testing.T) {
+	if _, err := Parse("https://www.themagiccafe.com/forums/viewtopic.php?topic=1&start=abc"); err == nil {
+		t.Error("Expected an error for a non-numeric start offset")
+	}
+}
+
+func TestParse_OtherURLsLoseSessionParams(t *testing.T) {
+	got, err := Canonicalize("http://themagiccafe.com/forums/profile.php?mode=viewprofile&s=abc&user=42#top")
+	if err != nil {
+		t.Fatalf("Canonicalize returned error: %v", err)
+	}
+	want := "https://www.themagiccafe.com/forums/profile.php?mode=viewprofile&user=42"
+	if got != want {
+		t.Errorf("Canonicalize() = %q, want %q", got, want)
+	}
+}
+
+func TestUnknownHostsKeepTheirOrigin(t *testing.T) {
+	got, err := Canonicalize("http://127.0.0.1:8080/forums/viewtopic.php?t=5&f=2")
+	if err != nil {
+		t.Fatalf("Canonicalize returned error: %v", err)
+	}
+	if want := "http://127.0.0.1:8080/forums/viewtopic.php?forum=2&topic=5"; got != want {
+		t.Errorf("Canonicalize() = %q, want %q", got, want)
+	}
+}
+
+func TestConstructors(t *testing.T) {
+	if got, want := ForumPage("66", 0).String(), "https://www.themagiccafe.com/forums/viewforum.php?forum=66"; got != want {
+		t.Errorf("ForumPage() = %q, want %q", got, want)
+	}
+	if got, want := TopicPage("66", "19618", 40).String(), "https://www.themagiccafe.com/forums/viewtopic.php?forum=66&start=40&topic=19618"; got != want {
+		t.Errorf("TopicPage() = %q, want %q", got, want)
+	}
+	post := Post("66", "19618", "123", 20)
+	if got, want := post.String(), "https://www.themagiccafe.com/forums/viewtopic.php?forum=66&post=123&start=20&topic=19618"; got != want {
+		t.Errorf("Post() = %q, want %q", got, want)
+	}
+	reparsed, err := Parse(post.String())
+	if err != nil {
+		t.Fatalf("Parse of formatted post URL returned error: %v", err)
+	}
+	if reparsed != post {
+		t.Errorf("Round trip of post reference = %+v, want %+v", reparsed, post)
+	}
+}
+
+func TestPageOffsets(t *testing.T) {
+	if got := StartForPage(3, 20); got != 40 {
+		t.Errorf("StartForPage(3, 20) = %d, want 40", got)
+	}
+	if got := StartForPage(0, 20); got != 0 {
+		t.Errorf("StartForPage(0, 20) = %d, want 0", got)
+	}
+	if got := PageForStart(59, 20); got != 3 {
+		t.Errorf("PageForStart(59, 20) = %d, want 3", got)
+	}
+	if got := TopicPage("66", "1", 60).Page(0); got != 4 {
+		t.Errorf("Page(0) with default page size = %d, want 4", got)
+	}
+}
@@ -0,0 +1,346 @@
+// Package cassette records the HTTP traffic of a run into an on-disk cassette and replays it
+// later without touching the network. A production problem on one sub-forum can be captured
+// once with ModeRecord and then reproduced deterministically, in a test or a debugging
+// session, with ModeReplay.
+//
+// A cassette is a JSON Lines file with one Interaction per line. Interactions are appended as
+// they happen, so a cassette recorded by a run that crashed is still usable.
+package cassette
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"waypoint_archive_scripts/pkg/canonurl"
+	"waypoint_archive_scripts/pkg/config"
+)
+
+// Mode selects what a Transport does with requests.
+type Mode string
+
+const (
+	ModeOff    Mode = "off"    // Pass requests through untouched
+	ModeRecord Mode = "record" // Pass requests through and append every interaction to the cassette
+	ModeReplay Mode = "replay" // Answer requests from the cassette only; never touch the network
+)
+
+// ErrNotRecorded is returned in replay mode for a request the cassette has no interaction for.
+var ErrNotRecorded = errors.New("cassette: request was not recorded")
+
+// Interaction is one recorded request and how it was answered.
+type Interaction struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Key        string      `json:"key"` // Replay match key, see RequestKey
+	StatusCode int         `json:"status_code,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	BodyError  string      `json:"body_error,omitempty"` // Error hit while reading Body (e.g. a truncated response); Body holds what was read
+	Error      string      `json:"error,omitempty"`      // Transport error; no response was received
+	RecordedAt time.Time   `json:"recorded_at"`
+}
+
+// Transport is an http.RoundTripper that records to or replays from a cassette.
+type Transport struct {
+	mode Mode
+	path string
+	next http.RoundTripper
+
+	mux      sync.Mutex
+	file     *os.File                 // Open cassette in record mode
+	recorded map[string][]Interaction // Interactions by key in replay mode, in recorded order
+	replayed map[string]int           // Number of times each key has been replayed
+	count    int                      // Interactions recorded or replayed so far
+}
+
+// ParseMode parses a mode name. The empty string means ModeOff.
+func ParseMode(name string) (Mode, error) {
+	switch Mode(strings.ToLower(strings.TrimSpace(name))) {
+	case "", ModeOff:
+		return ModeOff, nil
+	case ModeRecord:
+		return ModeRecord, nil
+	case ModeReplay:
+		return ModeReplay, nil
+	}
+	return ModeOff, fmt.Errorf("cassette: unknown mode %q (want off, record or replay)", name)
+}
+
+// Open returns a Transport for the cassette at filePath. In record mode the cassette is
+// created, replacing any existing one, and requests are sent through next (http.DefaultTransport
+// if nil). In replay mode the cassette is loaded and next is never used.
+func Open(filePath string, mode Mode, next http.RoundTripper) (*Transport, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t := &Transport{mode: mode, path: filePath, next: next}
+
+	switch mode {
+	case ModeOff:
+		return t, nil
+	case ModeRecord:
+		if filePath == "" {
+			return nil, errors.New("cassette: record mode needs a cassette path")
+		}
+		if dir := filepath.Dir(filePath); dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create cassette directory %s: %w", dir, err)
+			}
+		}
+		file, err := os.Create(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cassette %s: %w", filePath, err)
+		}
+		t.file = file
+		log.Printf("[INFO] CASSETTE: Recording HTTP interactions to %s", filePath)
+	case ModeReplay:
+		interactions, err := Load(filePath)
+		if err != nil {
+			return nil, err
+		}
+		t.recorded = make(map[string][]Interaction)
+		t.replayed = make(map[string]int)
+		for _, interaction := range interactions {
+			t.recorded[interaction.Key] = append(t.recorded[interaction.Key], interaction)
+		}
+		log.Printf("[INFO] CASSETTE: Replaying %d HTTP interactions from %s", len(interactions), filePath)
+	default:
+		return nil, fmt.Errorf("cassette: unknown mode %q", mode)
+	}
+	return t, nil
+}
+
+// Setup opens the cassette configured in cfg and installs it as http.DefaultTransport, so the
+// downloader, htmlutil.FetchHTML and every other client without its own transport go through
+// it. It returns nil when cassettes are off. Callers must Close the transport at the end of the run.
+func Setup(cfg *config.Config) (*Transport, error) {
+	mode, err := ParseMode(cfg.CassetteMode)
+	if err != nil {
+		return nil, err
+	}
+	if mode == ModeOff {
+		return nil, nil
+	}
+	t, err := Open(cfg.CassettePath, mode, nil)
+	if err != nil {
+		return nil, err
+	}
+	Install(t)
+	return t, nil
+}
+
+// Install makes t the process-wide http.DefaultTransport and returns a function restoring the
+// previous one. t keeps sending its own requests through the transport it was opened with.
+func Install(t *Transport) (restore func()) {
+	previous := http.DefaultTransport
+	http.DefaultTransport = t
+	return func() { http.DefaultTransport = previous }
+}
+
+// Mode returns the transport's mode.
+func (t *Transport) Mode() Mode {
+	return t.mode
+}
+
+// Count returns the number of interactions recorded or replayed so far.
+func (t *Transport) Count() int {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.count
+}
+
+// Close flushes and closes the cassette. It is safe to call on a nil Transport.
+func (t *Transport) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.file == nil {
+		return nil
+	}
+	err := t.file.Close()
+	t.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close cassette %s: %w", t.path, err)
+	}
+	log.Printf("[INFO] CASSETTE: Recorded %d HTTP interactions to %s", t.count, t.path)
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch t.mode {
+	case ModeRecord:
+		return t.record(req)
+	case ModeReplay:
+		return t.replay(req)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// record sends req through the next transport, buffers the whole response and appends it to the cassette.
+func (t *Transport) record(req *http.Request) (*http.Response, error) {
+	interaction := Interaction{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Key:        RequestKey(req.Method, req.URL.String()),
+		RecordedAt: time.Now().UTC(),
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		interaction.Error = err.Error()
+		t.append(interaction)
+		return nil, err
+	}
+
+	body, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	interaction.StatusCode = resp.StatusCode
+	interaction.Header = resp.Header.Clone()
+	interaction.Body = body
+	if readErr != nil {
+		interaction.BodyError = readErr.Error()
+	}
+	t.append(interaction)
+
+	resp.Body = newReplayBody(body, readErr)
+	return resp, nil
+}
+
+// append writes one interaction to the cassette. Write failures are logged rather than
+// returned, so a full disk does not fail the request being recorded.
+func (t *Transport) append(interaction Interaction) {
+	line, err := json.Marshal(interaction)
+	if err != nil {
+		log.Printf("[ERROR] CASSETTE: Failed to encode interaction for %s: %v", interaction.URL, err)
+		return
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.file == nil {
+		log.Printf("[WARNING] CASSETTE: Cassette %s is closed; not recording %s", t.path, interaction.URL)
+		return
+	}
+	if _, err := t.file.Write(append(line, '\n')); err != nil {
+		log.Printf("[ERROR] CASSETTE: Failed to write interaction for %s to %s: %v", interaction.URL, t.path, err)
+		return
+	}
+	t.count++
+}
+
+// replay answers req from the cassette. Repeated requests for the same key get the recorded
+// answers in order; once those run out the last one is repeated.
+func (t *Transport) replay(req *http.Request) (*http.Response, error) {
+	key := RequestKey(req.Method, req.URL.String())
+
+	t.mux.Lock()
+	candidates := t.recorded[key]
+	if len(candidates) == 0 {
+		t.mux.Unlock()
+		return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, req.Method, req.URL)
+	}
+	index := t.replayed[key]
+	if index >= len(candidates) {
+		index = len(candidates) - 1
+	}
+	t.replayed[key]++
+	t.count++
+	interaction := candidates[index]
+	t.mux.Unlock()
+
+	if interaction.Error != "" {
+		return nil, fmt.Errorf("cassette: recorded error for %s %s: %s", req.Method, req.URL, interaction.Error)
+	}
+
+	var bodyErr error
+	if interaction.BodyError != "" {
+		bodyErr = fmt.Errorf("cassette: recorded body error: %s: %w", interaction.BodyError, io.ErrUnexpectedEOF)
+	}
+	header := interaction.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.StatusCode, http.StatusText(interaction.StatusCode)),
+		StatusCode:    interaction.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          newReplayBody(interaction.Body, bodyErr),
+		ContentLength: int64(len(interaction.Body)),
+		Request:       req,
+	}, nil
+}
+
+// RequestKey returns the key requests are matched on during replay: the method and the
+// canonical form of the URL, so session IDs and parameter order do not cause misses.
+func RequestKey(method string, rawURL string) string {
+	if canonical, err := canonurl.Canonicalize(rawURL); err == nil {
+		rawURL = canonical
+	}
+	return strings.ToUpper(method) + " " + rawURL
+}
+
+// Load reads every interaction from the cassette at filePath, in recorded order.
+func Load(filePath string) ([]Interaction, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cassette %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	var interactions []Interaction
+	reader := bufio.NewReader(file)
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var interaction Interaction
+			if jsonErr := json.Unmarshal(line, &interaction); jsonErr != nil {
+				return nil, fmt.Errorf("failed to parse cassette %s line %d: %w", filePath, lineNum, jsonErr)
+			}
+			interactions = append(interactions, interaction)
+		}
+		if errors.Is(err, io.EOF) {
+			return interactions, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette %s: %w", filePath, err)
+		}
+	}
+}
+
+// replayBody serves a recorded body and then, if the original read failed, the same kind of error.
+type replayBody struct {
+	reader *bytes.Reader
+	err    error
+}
+
+func newReplayBody(body []byte, err error) io.ReadCloser {
+	return &replayBody{reader: bytes.NewReader(body), err: err}
+}
+
+func (b *replayBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	if errors.Is(err, io.EOF) && b.err != nil {
+		return n, b.err
+	}
+	return n, err
+}
+
+func (b *replayBody) Close() error {
+	return nil
+}
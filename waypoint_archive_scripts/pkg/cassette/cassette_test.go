@@ -0,0 +1,167 @@
+package cassette
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"waypoint_archive_scripts/pkg/config"
+	"waypoint_archive_scripts/pkg/downloader"
+	"waypoint_archive_scripts/pkg/fakeforum"
+	"waypoint_archive_scripts/pkg/htmlutil"
+)
+
+func testFixture() fakeforum.Fixture {
+	posted := time.Date(2003, time.January, 10, 11, 42, 0, 0, time.UTC)
+	return fakeforum.Fixture{SubForums: []fakeforum.SubForum{{ID: "66", Name: "History", Topics: []fakeforum.Topic{
+		{ID: "19618", Title: "Vernon", Posts: []fakeforum.Post{{ID: "1001", Author: "Ann", Posted: posted, Body: "First post."}}},
+	}}}}
+}
+
+func newTestDownloader(t *Transport) *downloader.Downloader {
+	d := downloader.NewDownloader(&config.Config{UserAgent: "CassetteTest/1.0"})
+	d.Client.Transport = t
+	return d
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "cassettes", "forum66.jsonl")
+	srv := fakeforum.NewServer(testFixture())
+	srv.AddFault(fakeforum.Fault{Match: "topic=19618", Times: 1, StatusCode: http.StatusServiceUnavailable})
+	topicURL := srv.TopicURL("66", "19618")
+
+	recorder, err := Open(cassettePath, ModeRecord, nil)
+	if err != nil {
+		t.Fatalf("Open(record) returned error: %v", err)
+	}
+	d := newTestDownloader(recorder)
+	if _, err := d.FetchPage(topicURL); err == nil {
+		t.Fatal("Expected the injected 503 while recording")
+	}
+	recordedPage, err := d.FetchPage(topicURL)
+	if err != nil {
+		t.Fatalf("FetchPage returned error while recording: %v", err)
+	}
+	recordedListing, err := d.FetchPage(srv.ForumURL("66"))
+	if err != nil {
+		t.Fatalf("FetchPage returned error while recording: %v", err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	srv.Close() // Replay must not need the server
+
+	interactions, err := Load(cassettePath)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(interactions) != 3 || interactions[0].StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Unexpected recorded interactions: %+v", interactions)
+	}
+
+	player, err := Open(cassettePath, ModeReplay, nil)
+	if err != nil {
+		t.Fatalf("Open(replay) returned error: %v", err)
+	}
+	d = newTestDownloader(player)
+	var httpErr *downloader.HTTPError
+	if _, err := d.FetchPage(topicURL); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected the recorded 503 first, got %v", err)
+	}
+	for i := 0; i < 2; i++ { // The last answer repeats once the recorded ones run out
+		page, err := d.FetchPage(topicURL)
+		if err != nil {
+			t.Fatalf("FetchPage returned error while replaying: %v", err)
+		}
+		if string(page) != string(recordedPage) {
+			t.Errorf("Replayed topic page %d differs from the recorded one", i)
+		}
+	}
+
+	// Matching is on the canonical URL, so parameter order and session IDs do not matter
+	restore := Install(player)
+	listing, err := htmlutil.FetchHTML(srv.BaseURL()+"viewforum.php?sid=abc&forum=66", 0, "")
+	restore()
+	if err != nil {
+		t.Fatalf("FetchHTML returned error while replaying: %v", err)
+	}
+	if listing != string(recordedListing) {
+		t.Error("Replayed listing differs from the recorded one")
+	}
+
+	if _, err := d.FetchPage(srv.ForumURL("54")); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("Expected ErrNotRecorded for an unrecorded URL, got %v", err)
+	}
+	if got := player.Count(); got != 4 {
+		t.Errorf("Count = %d, want 4", got)
+	}
+}
+
+func TestReplayTruncatedBody(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "truncated.jsonl")
+	srv := fakeforum.NewServer(testFixture())
+	srv.AddFault(fakeforum.Fault{Match: "topic=19618", TruncateAt: 100})
+	topicURL := srv.TopicURL("66", "19618")
+
+	recorder, err := Open(cassettePath, ModeRecord, nil)
+	if err != nil {
+		t.Fatalf("Open(record) returned error: %v", err)
+	}
+	if _, err := newTestDownloader(recorder).FetchPage(topicURL); err == nil {
+		t.Fatal("Expected an error for the truncated body while recording")
+	}
+	recorder.Close()
+	srv.Close()
+
+	player, err := Open(cassettePath, ModeReplay, nil)
+	if err != nil {
+		t.Fatalf("Open(replay) returned error: %v", err)
+	}
+	if _, err := newTestDownloader(player).FetchPage(topicURL); err == nil {
+		t.Error("Expected the truncated body to fail again on replay")
+	}
+}
+
+func TestSetupInstallsDefaultTransport(t *testing.T) {
+	original := http.DefaultTransport
+	defer func() { http.DefaultTransport = original }()
+
+	off, err := Setup(&config.Config{})
+	if err != nil || off != nil {
+		t.Fatalf("Setup with cassettes off returned %v, %v", off, err)
+	}
+	if _, err := Setup(&config.Config{CassetteMode: "rewind"}); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+	if _, err := Setup(&config.Config{CassetteMode: "replay", CassettePath: filepath.Join(t.TempDir(), "missing.jsonl")}); err == nil {
+		t.Error("Expected an error replaying a missing cassette")
+	}
+
+	srv := fakeforum.NewServer(testFixture())
+	defer srv.Close()
+	cassettePath := filepath.Join(t.TempDir(), "setup.jsonl")
+	recorder, err := Setup(&config.Config{CassetteMode: "RECORD", CassettePath: cassettePath})
+	if err != nil {
+		t.Fatalf("Setup returned error: %v", err)
+	}
+	if http.DefaultTransport != recorder {
+		t.Fatal("Expected Setup to install the cassette as http.DefaultTransport")
+	}
+	if _, err := htmlutil.FetchHTML(srv.FrontPageURL(), 0, ""); err != nil {
+		t.Fatalf("FetchHTML returned error: %v", err)
+	}
+	if _, err := downloader.NewDownloader(&config.Config{}).FetchPage(srv.ForumURL("66")); err != nil {
+		t.Fatalf("FetchPage returned error: %v", err)
+	}
+	recorder.Close()
+
+	interactions, err := Load(cassettePath)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(interactions) != 2 {
+		t.Errorf("Expected both fetches to be recorded, got %d interactions", len(interactions))
+	}
+}
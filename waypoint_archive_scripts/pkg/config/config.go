@@ -36,6 +36,33 @@ type Config struct {
 	ForumBaseURL         string        `json:"forumBaseURL"`         // Base URL of the forum, e.g., http://forum.example.com/
 	ArchiveOutputRootDir string        `json:"archiveOutputRootDir"` // Corrected JSON tag for consistency
 
+	// Topic page planning
+	PostsPerPage           int  `json:"postsPerPage"`           // Posts shown per topic page by the forum; used to derive page URLs from reply counts
+	AlwaysVerifyTopicPages bool `json:"alwaysVerifyTopicPages"` // If true, always confirm planned page URLs against live pagination
+
+	// Topic lifecycle tracking
+	LifecycleLedgerPath string `json:"lifecycleLedgerPath"` // Ledger of moved, merged, relocated and deleted topics
+	RearchiveQueuePath  string `json:"rearchiveQueuePath"`  // Topics to archive again although marked archived (e.g. found incomplete on extraction)
+
+	// HTTP record/replay
+	CassetteMode string `json:"cassetteMode"` // "off" (default), "record" or "replay"
+	CassettePath string `json:"cassettePath"` // Cassette file HTTP interactions are recorded to or replayed from
+
+	// Shared on-disk response cache
+	ResponseCacheDir        string        `json:"responseCacheDir"`        // Directory of cached pages; caching is off if empty
+	ResponseCacheListingTTL time.Duration `json:"responseCacheListingTTL"` // How long cached sub-forum listing pages stay fresh
+	ResponseCacheTopicTTL   time.Duration `json:"responseCacheTopicTTL"`   // How long cached topic pages stay fresh
+	ResponseCacheOtherTTL   time.Duration `json:"responseCacheOtherTTL"`   // How long other cached pages (e.g. the forum index) stay fresh
+
+	// Referenced assets (images, avatars, stylesheets, attachments)
+	AssetDir          string `json:"assetDir"`          // Content-addressed store of downloaded assets
+	AssetManifestPath string `json:"assetManifestPath"` // Asset URL to stored file manifest
+	AssetHosts        string `json:"assetHosts"`        // Comma-separated hosts assets are downloaded from; empty means any host
+
+	// Forum software
+	ForumEngine          string `json:"forumEngine"`          // Adapter for the forum's markup and URLs: "magiccafe" (default) or "phpbb3"
+	SelectorProfilesFile string `json:"selectorProfilesFile"` // JSON file of Magic Cafe selector profiles; empty uses the built-in profile
+
 	// TestConfiguration specific fields
 	TestSubForumIDs       []string `json:"TestSubForumIDs,omitempty"`       // Match JSON key
 	TestArchiveOutputRoot string   `json:"TestArchiveOutputRoot,omitempty"` // Match JSON key
@@ -52,6 +79,8 @@ func DefaultConfig() *Config {
 		UserAgent:             "WaypointArchiveAgent/1.0", // Default User-Agent
 		ArchiveRootDir:        "archive_output",           // Default archive root
 		StateFilePath:         "archive_progress.json",    // Default state file path (Story 2.6)
+		LifecycleLedgerPath:   "topic_lifecycle.json",     // Moved/merged/deleted topic ledger
+		RearchiveQueuePath:    "rearchive_queue.json",     // Topics queued for re-archiving
 		SaveStateInterval:     5 * time.Minute,            // Default save state interval (Story 2.6), re-adding
 		PerformanceLogPath:    "logs/performance_log.csv",
 		LogLevel:              "INFO",                  // Default log level
@@ -61,7 +90,18 @@ func DefaultConfig() *Config {
 		ConfigFilePath:        configFile,              // Default config file path
 		ForumBaseURL:          "http://localhost:8080", // Placeholder, replace with actual default or leave empty
 		ArchiveOutputRootDir:  "archive_output",        // This was `archive_output_root_dir` in JSON. Ensuring consistency.
+		PostsPerPage:          20,                      // Magic Cafe shows 20 posts per topic page
 		TestArchiveOutputRoot: "./test_archive_output", // Default for test runs
+
+		// Listings change with every new reply; topic pages only grow at the end
+		ResponseCacheListingTTL: 30 * time.Minute,
+		ResponseCacheTopicTTL:   6 * time.Hour,
+		ResponseCacheOtherTTL:   30 * time.Minute,
+
+		AssetDir:          "archive_assets",
+		AssetManifestPath: "asset_manifest.json",
+
+		ForumEngine: "magiccafe",
 	}
 }
 
@@ -144,6 +184,8 @@ func LoadConfig(arguments []string) (*Config, error) {
 	cliUserAgent := configFlags.String("userAgent", cfg.UserAgent, "Custom User-Agent string")
 	cliArchiveRootDir := configFlags.String("archiveRootDir", cfg.ArchiveRootDir, "Root directory for storing archived files")
 	cliStateFilePath := configFlags.String("stateFilePath", cfg.StateFilePath, "Path to the archive progress state file")
+	cliLifecycleLedgerPath := configFlags.String("lifecycleLedgerPath", cfg.LifecycleLedgerPath, "Path to the moved/merged/deleted topic lifecycle ledger")
+	cliRearchiveQueuePath := configFlags.String("rearchiveQueuePath", cfg.RearchiveQueuePath, "Path to the queue of topics to archive again")
 	cliSaveStateInterval := configFlags.String("saveStateInterval", cfg.SaveStateInterval.String(), "Interval for saving state (e.g., '5m', '30s')")
 	cliPerformanceLogPath := configFlags.String("performanceLogPath", cfg.PerformanceLogPath, "Path to the performance log file")
 	cliLogLevel := configFlags.String("logLevel", cfg.LogLevel, "Logging level (DEBUG, INFO, WARN, ERROR)")
@@ -152,6 +194,19 @@ func LoadConfig(arguments []string) (*Config, error) {
 	cliJITRefreshInterval := configFlags.String("jitRefreshInterval", cfg.JITRefreshInterval.String(), "How often to consider JIT refresh (e.g., '24h', '1h30m')")
 	cliForumBaseURL := configFlags.String("forumBaseURL", cfg.ForumBaseURL, "Base URL of the target forum (e.g., http://forum.example.com)")
 	cliArchiveOutputRootDir := configFlags.String("archiveOutputRootDir", cfg.ArchiveOutputRootDir, "Root directory for storing archived files")
+	cliPostsPerPage := configFlags.Int("postsPerPage", cfg.PostsPerPage, "Number of posts per topic page, used to plan page URLs from reply counts")
+	cliAlwaysVerifyTopicPages := configFlags.Bool("alwaysVerifyTopicPages", cfg.AlwaysVerifyTopicPages, "Always verify planned topic page URLs against live pagination")
+	cliCassetteMode := configFlags.String("cassetteMode", cfg.CassetteMode, "HTTP cassette mode: off, record or replay")
+	cliCassettePath := configFlags.String("cassettePath", cfg.CassettePath, "Path to the HTTP cassette to record to or replay from")
+	cliResponseCacheDir := configFlags.String("responseCacheDir", cfg.ResponseCacheDir, "Directory for the shared response cache (disabled if empty)")
+	cliResponseCacheListingTTL := configFlags.String("responseCacheListingTTL", cfg.ResponseCacheListingTTL.String(), "How long cached listing pages stay fresh (e.g., '30m')")
+	cliResponseCacheTopicTTL := configFlags.String("responseCacheTopicTTL", cfg.ResponseCacheTopicTTL.String(), "How long cached topic pages stay fresh (e.g., '6h')")
+	cliResponseCacheOtherTTL := configFlags.String("responseCacheOtherTTL", cfg.ResponseCacheOtherTTL.String(), "How long other cached pages stay fresh (e.g., '30m')")
+	cliAssetDir := configFlags.String("assetDir", cfg.AssetDir, "Directory of the content-addressed asset store")
+	cliAssetManifestPath := configFlags.String("assetManifestPath", cfg.AssetManifestPath, "Path to the asset URL to stored file manifest")
+	cliAssetHosts := configFlags.String("assetHosts", cfg.AssetHosts, "Comma-separated hosts to download assets from (any host if empty)")
+	cliForumEngine := configFlags.String("forumEngine", cfg.ForumEngine, "Forum software the target forum runs: magiccafe or phpbb3")
+	cliSelectorProfilesFile := configFlags.String("selectorProfilesFile", cfg.SelectorProfilesFile, "JSON file of selector profiles for the magiccafe adapter")
 
 	err := configFlags.Parse(arguments)
 	if err != nil {
@@ -203,6 +258,14 @@ func LoadConfig(arguments []string) (*Config, error) {
 		cfg.StateFilePath = *cliStateFilePath
 		log.Printf("[INFO] StateFilePath overridden by CLI flag: %s", cfg.StateFilePath)
 	}
+	if userSet["lifecycleLedgerPath"] {
+		cfg.LifecycleLedgerPath = *cliLifecycleLedgerPath
+		log.Printf("[INFO] LifecycleLedgerPath overridden by CLI flag: %s", cfg.LifecycleLedgerPath)
+	}
+	if userSet["rearchiveQueuePath"] {
+		cfg.RearchiveQueuePath = *cliRearchiveQueuePath
+		log.Printf("[INFO] RearchiveQueuePath overridden by CLI flag: %s", cfg.RearchiveQueuePath)
+	}
 	if userSet["saveStateInterval"] {
 		parsedDuration, err := time.ParseDuration(*cliSaveStateInterval)
 		if err != nil {
@@ -245,6 +308,67 @@ func LoadConfig(arguments []string) (*Config, error) {
 		cfg.ArchiveOutputRootDir = *cliArchiveOutputRootDir
 		log.Printf("[INFO] ArchiveOutputRootDir overridden by CLI flag: %s", cfg.ArchiveOutputRootDir)
 	}
+	if userSet["postsPerPage"] {
+		cfg.PostsPerPage = *cliPostsPerPage
+		log.Printf("[INFO] PostsPerPage overridden by CLI flag: %d", cfg.PostsPerPage)
+	}
+	if userSet["alwaysVerifyTopicPages"] {
+		cfg.AlwaysVerifyTopicPages = *cliAlwaysVerifyTopicPages
+		log.Printf("[INFO] AlwaysVerifyTopicPages overridden by CLI flag: %t", cfg.AlwaysVerifyTopicPages)
+	}
+	if userSet["cassetteMode"] {
+		cfg.CassetteMode = strings.ToLower(*cliCassetteMode)
+		log.Printf("[INFO] CassetteMode overridden by CLI flag: %s", cfg.CassetteMode)
+	}
+	if userSet["cassettePath"] {
+		cfg.CassettePath = *cliCassettePath
+		log.Printf("[INFO] CassettePath overridden by CLI flag: %s", cfg.CassettePath)
+	}
+	if userSet["responseCacheDir"] {
+		cfg.ResponseCacheDir = *cliResponseCacheDir
+		log.Printf("[INFO] ResponseCacheDir overridden by CLI flag: %s", cfg.ResponseCacheDir)
+	}
+	responseCacheTTLs := []struct {
+		flagName string
+		value    *string
+		target   *time.Duration
+	}{
+		{"responseCacheListingTTL", cliResponseCacheListingTTL, &cfg.ResponseCacheListingTTL},
+		{"responseCacheTopicTTL", cliResponseCacheTopicTTL, &cfg.ResponseCacheTopicTTL},
+		{"responseCacheOtherTTL", cliResponseCacheOtherTTL, &cfg.ResponseCacheOtherTTL},
+	}
+	for _, ttl := range responseCacheTTLs {
+		if !userSet[ttl.flagName] {
+			continue
+		}
+		parsedDuration, err := time.ParseDuration(*ttl.value)
+		if err != nil {
+			log.Printf("[WARNING] Invalid %s format from CLI '%s': %v. Using previous value: %s", ttl.flagName, *ttl.value, err, *ttl.target)
+			continue
+		}
+		*ttl.target = parsedDuration
+		log.Printf("[INFO] %s overridden by CLI flag: %s", ttl.flagName, parsedDuration)
+	}
+	if userSet["assetDir"] {
+		cfg.AssetDir = *cliAssetDir
+		log.Printf("[INFO] AssetDir overridden by CLI flag: %s", cfg.AssetDir)
+	}
+	if userSet["assetManifestPath"] {
+		cfg.AssetManifestPath = *cliAssetManifestPath
+		log.Printf("[INFO] AssetManifestPath overridden by CLI flag: %s", cfg.AssetManifestPath)
+	}
+	if userSet["assetHosts"] {
+		cfg.AssetHosts = *cliAssetHosts
+		log.Printf("[INFO] AssetHosts overridden by CLI flag: %s", cfg.AssetHosts)
+	}
+	if userSet["forumEngine"] {
+		cfg.ForumEngine = strings.ToLower(*cliForumEngine)
+		log.Printf("[INFO] ForumEngine overridden by CLI flag: %s", cfg.ForumEngine)
+	}
+	if userSet["selectorProfilesFile"] {
+		cfg.SelectorProfilesFile = *cliSelectorProfilesFile
+		log.Printf("[INFO] SelectorProfilesFile overridden by CLI flag: %s", cfg.SelectorProfilesFile)
+	}
 
 	// log.Printf("[DEBUG] config.LoadConfig: Skipping final CLI flag parsing. Current cfg.SubForumListFile: %s", cfg.SubForumListFile)
 
@@ -288,6 +412,18 @@ func loadFromEnv(cfg *Config) {
 		return currentVal // Return current if env var not set, empty, or invalid format
 	}
 
+	loadBoolEnv := func(envKey string, currentVal bool) bool {
+		if valStr, exists := os.LookupEnv(envKey); exists && valStr != "" {
+			valBool, err := strconv.ParseBool(valStr)
+			if err == nil {
+				log.Printf("[INFO] Loading '%s' from environment variable '%s'", envKey, valStr)
+				return valBool
+			}
+			log.Printf("[WARNING] Invalid boolean format for env var %s='%s': %v. Using previous value: %t", envKey, valStr, err, currentVal)
+		}
+		return currentVal // Return current if env var not set, empty, or invalid format
+	}
+
 	cfg.TopicIndexDir = loadStrEnv("WAYPOINT_TOPIC_INDEX_DIR", cfg.TopicIndexDir)
 	cfg.SubForumListFile = loadStrEnv("WAYPOINT_SUBFORUM_LIST_FILE", cfg.SubForumListFile)
 	cfg.TopicIndexFilePattern = loadStrEnv("WAYPOINT_TOPIC_INDEX_FILE_PATTERN", cfg.TopicIndexFilePattern)
@@ -296,12 +432,27 @@ func loadFromEnv(cfg *Config) {
 	cfg.ArchiveRootDir = loadStrEnv("WAYPOINT_ARCHIVE_ROOT_DIR", cfg.ArchiveRootDir)                    // Used by storer
 	cfg.ArchiveOutputRootDir = loadStrEnv("WAYPOINT_ARCHIVE_OUTPUT_ROOT_DIR", cfg.ArchiveOutputRootDir) // Added for this task
 	cfg.StateFilePath = loadStrEnv("WAYPOINT_STATE_FILE_PATH", cfg.StateFilePath)
+	cfg.LifecycleLedgerPath = loadStrEnv("WAYPOINT_LIFECYCLE_LEDGER_PATH", cfg.LifecycleLedgerPath)
+	cfg.RearchiveQueuePath = loadStrEnv("WAYPOINT_REARCHIVE_QUEUE_PATH", cfg.RearchiveQueuePath)
 	cfg.PerformanceLogPath = loadStrEnv("WAYPOINT_PERFORMANCE_LOG_PATH", cfg.PerformanceLogPath)
 	cfg.JITRefreshPages = loadIntEnv("WAYPOINT_JIT_REFRESH_PAGES", cfg.JITRefreshPages)
 	cfg.JITRefreshInterval = loadDurationEnv("WAYPOINT_JIT_REFRESH_INTERVAL", cfg.JITRefreshInterval)
 	cfg.LogFilePath = loadStrEnv("WAYPOINT_LOG_FILE_PATH", cfg.LogFilePath) // Handles empty string correctly by design
 	cfg.ForumBaseURL = loadStrEnv("WAYPOINT_FORUM_BASE_URL", cfg.ForumBaseURL)
 	cfg.SaveStateInterval = loadDurationEnv("WAYPOINT_SAVE_STATE_INTERVAL", cfg.SaveStateInterval)
+	cfg.PostsPerPage = loadIntEnv("WAYPOINT_POSTS_PER_PAGE", cfg.PostsPerPage)
+	cfg.AlwaysVerifyTopicPages = loadBoolEnv("WAYPOINT_ALWAYS_VERIFY_TOPIC_PAGES", cfg.AlwaysVerifyTopicPages)
+	cfg.CassetteMode = loadStrEnv("WAYPOINT_CASSETTE_MODE", cfg.CassetteMode)
+	cfg.CassettePath = loadStrEnv("WAYPOINT_CASSETTE_PATH", cfg.CassettePath)
+	cfg.ResponseCacheDir = loadStrEnv("WAYPOINT_RESPONSE_CACHE_DIR", cfg.ResponseCacheDir)
+	cfg.ResponseCacheListingTTL = loadDurationEnv("WAYPOINT_RESPONSE_CACHE_LISTING_TTL", cfg.ResponseCacheListingTTL)
+	cfg.ResponseCacheTopicTTL = loadDurationEnv("WAYPOINT_RESPONSE_CACHE_TOPIC_TTL", cfg.ResponseCacheTopicTTL)
+	cfg.ResponseCacheOtherTTL = loadDurationEnv("WAYPOINT_RESPONSE_CACHE_OTHER_TTL", cfg.ResponseCacheOtherTTL)
+	cfg.AssetDir = loadStrEnv("WAYPOINT_ASSET_DIR", cfg.AssetDir)
+	cfg.AssetManifestPath = loadStrEnv("WAYPOINT_ASSET_MANIFEST_PATH", cfg.AssetManifestPath)
+	cfg.AssetHosts = loadStrEnv("WAYPOINT_ASSET_HOSTS", cfg.AssetHosts)
+	cfg.ForumEngine = loadStrEnv("WAYPOINT_FORUM_ENGINE", cfg.ForumEngine)
+	cfg.SelectorProfilesFile = loadStrEnv("WAYPOINT_SELECTOR_PROFILES_FILE", cfg.SelectorProfilesFile)
 
 	// Handle LogLevel with validation
 	if logLevelStr, exists := os.LookupEnv("WAYPOINT_LOG_LEVEL"); exists && logLevelStr != "" {
@@ -13,6 +13,8 @@ type Topic struct {
 	LastPostTimestampRaw string // Raw timestamp string from CSV
 	IsSticky             bool
 	IsLocked             bool
+	DiscoveredVia        string `json:"discovered_via,omitempty"` // Set when the topic was added to the index outside a full re-index (e.g. "jit_refresh")
+	DiscoveredAt         string `json:"discovered_at,omitempty"`  // RFC3339 UTC time the topic was added by DiscoveredVia
 	// Add other relevant metadata fields here if needed
 }
 
@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"waypoint_archive_scripts/pkg/config"
+	"waypoint_archive_scripts/pkg/respcache"
 
 	"golang.org/x/net/html/charset"
 	"golang.org/x/text/encoding/unicode"
@@ -20,6 +22,7 @@ type Downloader struct {
 	Client          *http.Client
 	UserAgent       string
 	PolitenessDelay time.Duration
+	Cache           *respcache.Cache // Optional shared response cache; pages served from it skip the politeness delay
 }
 
 // NewDownloader creates and returns a new Downloader instance.
@@ -34,57 +37,52 @@ func NewDownloader(cfg *config.Config) *Downloader {
 	}
 }
 
+// FetchResult describes how a request was answered, so callers can tell
+// a redirected (moved or merged) topic apart from a normal response.
+type FetchResult struct {
+	RequestedURL string
+	FinalURL     string // URL of the response after following redirects
+	StatusCode   int
+	Redirected   bool
+	ContentType  string // Content-Type header of the response
+}
+
 // FetchPage downloads the raw HTML content for a given URL.
 // It respects the politeness delay and uses the configured User-Agent.
 // It handles character encoding based on HTTP headers or defaults to UTF-8.
 // Returns the raw HTML as a byte slice and an error if any occurs.
 func (d *Downloader) FetchPage(url string) ([]byte, error) {
-	if d.PolitenessDelay > 0 {
-		time.Sleep(d.PolitenessDelay)
-	}
-
-	// log.Printf("[DEBUG] DOWNLOADER_URL_RECEIVED: %s (UserAgent: %s)", url, d.UserAgent) // DEBUG
-	// Simulate a download error for a specific URL pattern for testing
-	// This URL should be the *normalized* version if normalization happens before FetchPage
-	if d.UserAgent == "TEST_DOWNLOAD_ERROR_USER_AGENT" && url == "http://forum.example.com/download_error_test_page.html?forum=39&topic=10646" {
-		log.Printf("[ERROR] DOWNLOADER: Simulated download error for URL: %s", url)
-		return nil, fmt.Errorf("simulated download error for %s via user agent trigger", url)
-	}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Printf("Error creating request for URL %s: %v", url, err)
-		return nil, err // AC6
-	}
+	rawHTML, _, err := d.FetchPageWithResult(url)
+	return rawHTML, err
+}
 
-	// AC8: Set custom User-Agent
-	if d.UserAgent != "" {
-		req.Header.Set("User-Agent", d.UserAgent)
-	} else {
-		// Explicitly set an empty User-Agent if none is configured
-		// to prevent Go's default HTTP client from adding its own.
-		req.Header.Set("User-Agent", "")
+// FetchPageWithResult behaves like FetchPage but also reports the final URL and status code.
+// The result is non-nil whenever a response was received, including HTTP error statuses.
+func (d *Downloader) FetchPageWithResult(url string) ([]byte, *FetchResult, error) {
+	if entry, ok := d.Cache.Get(url); ok {
+		log.Printf("[DEBUG] DOWNLOADER: Serving %s from response cache (fetched %s)", url, entry.FetchedAt.Format(time.RFC3339))
+		result := &FetchResult{RequestedURL: url, FinalURL: url, StatusCode: http.StatusOK, Redirected: entry.Redirected()}
+		if entry.Redirected() {
+			result.FinalURL = entry.FinalURL
+		}
+		return entry.Body, result, nil
 	}
+	return d.RefreshPageWithResult(url)
+}
 
-	// AC1: Execute HTTP GET request
-	resp, err := d.Client.Do(req)
+// RefreshPageWithResult behaves like FetchPageWithResult but always requests the page from the
+// forum, replacing any response cache entry for it. It is for pages known to have changed, such
+// as the new pages of a bumped topic.
+func (d *Downloader) RefreshPageWithResult(url string) ([]byte, *FetchResult, error) {
+	resp, result, err := d.get(url)
 	if err != nil {
-		log.Printf("Error fetching URL %s: %v", url, err) // AC6: Network-related issues
-		return nil, err
+		return nil, result, err
 	}
 	defer resp.Body.Close()
 
-	// AC7: Handle HTTP error status codes
-	if resp.StatusCode >= 400 {
-		log.Printf("HTTP error for URL %s: Status %s", url, resp.Status)
-		// Note: Retry logic as per Story 2.5 will be handled by the calling orchestrator or a higher-level retry mechanism.
-		// This function focuses on the download attempt and reporting the outcome.
-		return nil, &HTTPError{StatusCode: resp.StatusCode, URL: url}
-	}
-
 	// AC2: Retrieve full HTTP response
 	// AC3, AC5: Extract raw HTML content and handle character encoding
-	contentType := resp.Header.Get("Content-Type")
+	contentType := result.ContentType
 	var bodyReader io.Reader = resp.Body
 
 	// Determine encoding from Content-Type header
@@ -104,12 +102,81 @@ func (d *Downloader) FetchPage(url string) ([]byte, error) {
 	rawHTML, err := io.ReadAll(bodyReader)
 	if err != nil {
 		log.Printf("Error reading response body for URL %s: %v", url, err)
-		return nil, err
+		return nil, result, err
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		d.Cache.Put(url, result.FinalURL, rawHTML)
 	}
 
 	// AC4: Ensure downloaded HTML is preserved exactly as received (handled by reading directly)
 	// AC9: Return raw HTML content
-	return rawHTML, nil
+	return rawHTML, result, nil
+}
+
+// FetchAsset downloads a file referenced by archived pages (an image, avatar, stylesheet or
+// attachment) with the same politeness delay and User-Agent as FetchPage. Unlike FetchPage, the
+// body is returned byte for byte, without charset decoding, and never goes through the response
+// cache, which only holds pages.
+func (d *Downloader) FetchAsset(url string) ([]byte, *FetchResult, error) {
+	resp, result, err := d.get(url)
+	if err != nil {
+		return nil, result, err
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("Error reading response body for URL %s: %v", url, err)
+		return nil, result, err
+	}
+	return content, result, nil
+}
+
+// get waits out the politeness delay and sends a GET request for url. The response body is the
+// caller's to close; on an HTTP error status it is closed already and an *HTTPError returned.
+func (d *Downloader) get(url string) (*http.Response, *FetchResult, error) {
+	if d.PolitenessDelay > 0 {
+		time.Sleep(d.PolitenessDelay)
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		log.Printf("Error creating request for URL %s: %v", url, err)
+		return nil, nil, err // AC6
+	}
+
+	// AC8: Set custom User-Agent
+	if d.UserAgent != "" {
+		req.Header.Set("User-Agent", d.UserAgent)
+	} else {
+		// Explicitly set an empty User-Agent if none is configured
+		// to prevent Go's default HTTP client from adding its own.
+		req.Header.Set("User-Agent", "")
+	}
+
+	// AC1: Execute HTTP GET request
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		log.Printf("Error fetching URL %s: %v", url, err) // AC6: Network-related issues
+		return nil, nil, err
+	}
+
+	result := &FetchResult{RequestedURL: url, FinalURL: url, StatusCode: resp.StatusCode, ContentType: resp.Header.Get("Content-Type")}
+	if resp.Request != nil && resp.Request.URL != nil {
+		result.FinalURL = resp.Request.URL.String()
+		result.Redirected = result.FinalURL != url
+	}
+
+	// AC7: Handle HTTP error status codes
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		log.Printf("HTTP error for URL %s: Status %s", url, resp.Status)
+		// Note: Retry logic as per Story 2.5 will be handled by the calling orchestrator or a higher-level retry mechanism.
+		// This function focuses on the download attempt and reporting the outcome.
+		return nil, result, &HTTPError{StatusCode: resp.StatusCode, URL: url}
+	}
+	return resp, result, nil
 }
 
 // HTTPError represents an error related to an HTTP status code.
@@ -121,3 +188,12 @@ type HTTPError struct {
 func (e *HTTPError) Error() string {
 	return fmt.Sprintf("HTTP error %d fetching URL %s", e.StatusCode, e.URL)
 }
+
+// IsGone reports whether err is an HTTP 404 or 410 response, meaning the resource no longer exists.
+func IsGone(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusNotFound || httpErr.StatusCode == http.StatusGone
+	}
+	return false
+}
@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"waypoint_archive_scripts/pkg/config"
+	"waypoint_archive_scripts/pkg/htmlutil"
+	"waypoint_archive_scripts/pkg/respcache"
 
 	"golang.org/x/text/encoding/charmap"
 )
@@ -178,6 +180,97 @@ func TestFetchPage_HTTPError_404(t *testing.T) {
 	}
 }
 
+func TestFetchPageWithResult_Redirect(t *testing.T) {
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("forum") == "1" {
+			http.Redirect(w, r, "/viewtopic.php?topic=42&forum=2", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body>Moved topic</body></html>")
+	})
+	defer server.Close()
+
+	d := NewDownloader(newTestConfig())
+	requested := server.URL + "/viewtopic.php?topic=42&forum=1"
+	content, result, err := d.FetchPageWithResult(requested)
+	if err != nil {
+		t.Fatalf("FetchPageWithResult failed: %v", err)
+	}
+	if string(content) != "<html><body>Moved topic</body></html>" {
+		t.Errorf("Unexpected content: %s", content)
+	}
+	if result == nil || !result.Redirected {
+		t.Fatalf("Expected a redirected result, got %#v", result)
+	}
+	if result.RequestedURL != requested || result.FinalURL != server.URL+"/viewtopic.php?topic=42&forum=2" {
+		t.Errorf("Unexpected URLs in result: %#v", result)
+	}
+}
+
+func TestFetchAsset_BinarySafe(t *testing.T) {
+	// Bytes that are not valid UTF-8 and would be altered by a charset transform
+	body := []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0xe9, 0xff, 0x00}
+	var userAgent string
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.gif" {
+			http.NotFound(w, r)
+			return
+		}
+		userAgent = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "image/png; charset=iso-8859-1")
+		w.Write(body)
+	})
+	defer server.Close()
+
+	d := NewDownloader(newTestConfig())
+	cache, err := respcache.NewCache(t.TempDir(), map[respcache.Class]time.Duration{respcache.ClassOther: time.Hour})
+	if err != nil {
+		t.Fatalf("respcache.New failed: %v", err)
+	}
+	d.Cache = cache
+	content, result, err := d.FetchAsset(server.URL + "/images/avatars/1.png")
+	if err != nil {
+		t.Fatalf("FetchAsset failed: %v", err)
+	}
+	if !bytes.Equal(content, body) {
+		t.Errorf("FetchAsset altered the body: got %x, want %x", content, body)
+	}
+	if result.ContentType != "image/png; charset=iso-8859-1" || result.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected result: %#v", result)
+	}
+	if userAgent != "TestAgent/1.0" {
+		t.Errorf("Expected User-Agent TestAgent/1.0, got %q", userAgent)
+	}
+	if _, ok := cache.Get(server.URL + "/images/avatars/1.png"); ok {
+		t.Error("Assets should not be stored in the response cache")
+	}
+
+	_, result, err = d.FetchAsset(server.URL + "/missing.gif")
+	if !IsGone(err) || result == nil || result.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a 404 HTTPError with result, got %v, %#v", err, result)
+	}
+}
+
+func TestIsGone(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&HTTPError{StatusCode: http.StatusNotFound}, true},
+		{&HTTPError{StatusCode: http.StatusGone}, true},
+		{fmt.Errorf("wrapped: %w", &HTTPError{StatusCode: http.StatusGone}), true},
+		{&HTTPError{StatusCode: http.StatusInternalServerError}, false},
+		{fmt.Errorf("network down"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsGone(tt.err); got != tt.want {
+			t.Errorf("IsGone(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
 func TestFetchPage_HTTPError_500(t *testing.T) {
 	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -338,3 +431,95 @@ func TestHTTPError_ErrorMethod(t *testing.T) {
 		t.Errorf("Expected error message '%s', got '%s'", expectedMsg, err.Error())
 	}
 }
+
+// cacheableTopicPage is a topic page with one post, so the response cache stores it.
+const cacheableTopicPage = `<html><body><div id="container">
+<table class="normal"><tr><td class="w99 mltext"><a href="index.php">Index</a></td></tr></table>
+<table class="normal"><tr>
+<td class="normal bgc1 c w13 vat"><strong>Bob</strong></td>
+<td class="normal bgc1 vat w90"><div class="vt1 liketext"><div class="like_left">Posted: <span class="b">Jan 23, 2003 02:45 pm</span></div></div><div class="w100">Topic page</div></td>
+</tr></table></div></body></html>`
+
+func TestFetchPageWithResult_ResponseCache(t *testing.T) {
+	requests := 0
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, cacheableTopicPage)
+	})
+	defer server.Close()
+
+	cache, err := respcache.NewCache(t.TempDir(), map[respcache.Class]time.Duration{respcache.ClassTopic: time.Hour})
+	if err != nil {
+		t.Fatalf("NewCache returned error: %v", err)
+	}
+	topicURL := server.URL + "/forums/viewtopic.php?topic=5&forum=2"
+
+	// The page planner's pagination fetch fills the cache...
+	fetcher := htmlutil.NewCachedHTMLFetcher("TestAgent/1.0", 0, cache)
+	if _, err := fetcher.FetchHTML(topicURL); err != nil {
+		t.Fatalf("FetchHTML returned error: %v", err)
+	}
+
+	// ...so storing the same page, under any spelling of its URL, needs no second request
+	cfg := newTestConfig()
+	cfg.PolitenessDelay = time.Hour
+	d := NewDownloader(cfg)
+	d.Cache = cache
+	body, result, err := d.FetchPageWithResult(server.URL + "/forums/viewtopic.php?forum=2&topic=5&sid=abc")
+	if err != nil {
+		t.Fatalf("FetchPageWithResult returned error: %v", err)
+	}
+	if string(body) != cacheableTopicPage {
+		t.Errorf("Unexpected cached body %q", body)
+	}
+	if result.StatusCode != http.StatusOK || result.Redirected {
+		t.Errorf("Unexpected result for a cached page: %+v", result)
+	}
+	if requests != 1 {
+		t.Errorf("Expected a single request to the server, got %d", requests)
+	}
+}
+
+func TestRefreshPageWithResult_BypassesCache(t *testing.T) {
+	requests, busy := 0, false
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if busy {
+			fmt.Fprint(w, `<html><body><div id="container"><table class="normal"><tr><td>Sorry, the Magic Cafe is very busy right now.</td></tr></table></div></body></html>`)
+			return
+		}
+		fmt.Fprint(w, strings.Replace(cacheableTopicPage, "Topic page", fmt.Sprintf("Request %d", requests), 1))
+	})
+	defer server.Close()
+
+	cache, err := respcache.NewCache(t.TempDir(), map[respcache.Class]time.Duration{respcache.ClassTopic: time.Hour})
+	if err != nil {
+		t.Fatalf("NewCache returned error: %v", err)
+	}
+	d := NewDownloader(newTestConfig())
+	d.PolitenessDelay = 0
+	d.Cache = cache
+	topicURL := server.URL + "/forums/viewtopic.php?topic=5&forum=2"
+
+	if _, _, err := d.FetchPageWithResult(topicURL); err != nil {
+		t.Fatalf("FetchPageWithResult returned error: %v", err)
+	}
+	body, _, err := d.RefreshPageWithResult(topicURL)
+	if err != nil || !strings.Contains(string(body), "Request 2") {
+		t.Fatalf("Expected RefreshPageWithResult to request the page again, got %q, %v", body, err)
+	}
+	if body, _, _ := d.FetchPageWithResult(topicURL); !strings.Contains(string(body), "Request 2") || requests != 2 {
+		t.Errorf("Expected the refreshed page to replace the cached one, got %q after %d requests", body, requests)
+	}
+
+	// A 200 OK error page is returned but not cached, so the cached page stays
+	busy = true
+	if body, _, err := d.RefreshPageWithResult(topicURL); err != nil || !strings.Contains(string(body), "very busy") {
+		t.Fatalf("Expected the busy page, got %q, %v", body, err)
+	}
+	if entry, ok := cache.Get(topicURL); !ok || !strings.Contains(string(entry.Body), "Request 2") {
+		t.Errorf("Expected the busy page not to replace the cached page, got %q, %t", entry.Body, ok)
+	}
+}
@@ -0,0 +1,123 @@
+// Package fakeforum serves a local, httptest-based imitation of the Magic Cafe forum.
+// Pages are generated from a declarative Fixture in the same markup the indexer, archiver and
+// extractor parse, and faults (latency, 429/503, truncated bodies, soft-error pages, new replies
+// arriving mid-run) can be injected, so whole pipelines can be exercised offline.
+package fakeforum
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"waypoint_archive_scripts/pkg/canonurl"
+)
+
+// Defaults used when a Fixture leaves the page sizes unset. They match the live forum.
+const (
+	DefaultTopicsPerPage = 30
+	DefaultPostsPerPage  = canonurl.DefaultPostsPerPage
+)
+
+// TimestampLayout is the forum's display format for post times, e.g. "Jan 23, 2003 02:45 pm".
+const TimestampLayout = "Jan 02, 2006 03:04 pm"
+
+// Fixture declares the content of the fake forum.
+type Fixture struct {
+	SubForums     []SubForum `json:"sub_forums"`
+	TopicsPerPage int        `json:"topics_per_page,omitempty"` // Topics per listing page; DefaultTopicsPerPage if 0
+	PostsPerPage  int        `json:"posts_per_page,omitempty"`  // Posts per topic page; DefaultPostsPerPage if 0
+}
+
+// SubForum is one sub-forum and its topics.
+type SubForum struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Topics      []Topic `json:"topics"`
+}
+
+// Topic is one topic. Its first post is the opening post; the rest are replies.
+type Topic struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Views int    `json:"views,omitempty"`
+	Posts []Post `json:"posts"`
+}
+
+// Post is one post of a topic.
+type Post struct {
+	ID     string    `json:"id"`
+	Author string    `json:"author"`
+	Posted time.Time `json:"posted"`
+	Body   string    `json:"body"` // HTML fragment placed inside the post text
+}
+
+// LoadFixture reads a Fixture from a JSON file.
+func LoadFixture(filePath string) (Fixture, error) {
+	var fixture Fixture
+	fixtureBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		return fixture, fmt.Errorf("failed to read fake forum fixture %s: %w", filePath, err)
+	}
+	if err := json.Unmarshal(fixtureBytes, &fixture); err != nil {
+		return fixture, fmt.Errorf("failed to unmarshal fake forum fixture %s: %w", filePath, err)
+	}
+	return fixture, nil
+}
+
+// Replies returns the number of replies to the topic, i.e. all posts but the opening one.
+func (t Topic) Replies() int {
+	if len(t.Posts) == 0 {
+		return 0
+	}
+	return len(t.Posts) - 1
+}
+
+// LastPost returns the most recent post of the topic, or false if it has none.
+func (t Topic) LastPost() (Post, bool) {
+	if len(t.Posts) == 0 {
+		return Post{}, false
+	}
+	return t.Posts[len(t.Posts)-1], true
+}
+
+// PostCount returns the total number of posts in the sub-forum.
+func (sf SubForum) PostCount() int {
+	count := 0
+	for _, topic := range sf.Topics {
+		count += len(topic.Posts)
+	}
+	return count
+}
+
+// topicsPerPage returns the configured listing page size or its default.
+func (f Fixture) topicsPerPage() int {
+	if f.TopicsPerPage > 0 {
+		return f.TopicsPerPage
+	}
+	return DefaultTopicsPerPage
+}
+
+// postsPerPage returns the configured topic page size or its default.
+func (f Fixture) postsPerPage() int {
+	if f.PostsPerPage > 0 {
+		return f.PostsPerPage
+	}
+	return DefaultPostsPerPage
+}
+
+// clone returns a deep copy of the fixture so callers never share slices with the server.
+func (f Fixture) clone() Fixture {
+	copied := f
+	copied.SubForums = make([]SubForum, len(f.SubForums))
+	for i, sf := range f.SubForums {
+		copied.SubForums[i] = sf
+		copied.SubForums[i].Topics = make([]Topic, len(sf.Topics))
+		for j, topic := range sf.Topics {
+			copied.SubForums[i].Topics[j] = topic
+			copied.SubForums[i].Topics[j].Posts = append([]Post(nil), topic.Posts...)
+		}
+	}
+	return copied
+}
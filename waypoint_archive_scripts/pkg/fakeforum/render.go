@@ -0,0 +1,177 @@
+package fakeforum
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// pageHeader opens a page the way the live forum does: a header table inside div#container.
+func pageHeader(b *strings.Builder, title string) {
+	fmt.Fprintf(b, `<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
+<html><head><meta http-equiv="Content-Type" content="text/html; charset=UTF-8" />
+<title>The Magic Cafe Forums - %s</title></head>
+<body bgcolor="#000000"><div id="container">
+<table class="normalnb" cellpadding="4" cellspacing="0"><tr><td><a href="index.php">The Magic Cafe</a></td></tr></table>
+`, html.EscapeString(title))
+}
+
+func pageFooter(b *strings.Builder) {
+	b.WriteString(`<table class="normalnb c b" cellpadding="10" cellspacing="0"><tr><td class="c">[ <a href="#">Top of Page</a> ]</td></tr></table>
+</div></body></html>
+`)
+}
+
+// renderFrontPage renders the forum index with one row per sub-forum.
+func renderFrontPage(fixture Fixture) string {
+	var b strings.Builder
+	pageHeader(&b, "Forum Index")
+	b.WriteString(`<table class="normal" cellpadding="4" cellspacing="1">
+<tr><th class="bgc3">Forum</th><th class="bgc3">Topics</th><th class="bgc3">Posts</th><th class="bgc3">Last Post</th></tr>
+`)
+	for _, sf := range fixture.SubForums {
+		fmt.Fprintf(&b, `<tr>
+	<td class="bgc2"><a class="b" href="viewforum.php?forum=%s">%s</a><br /><span class="smalltext">%s</span></td>
+	<td class="bgc2 w5 c normal midtext">%d</td>
+	<td class="bgc2 w5 c normal midtext">%d</td>
+	<td class="bgc2 w22 c normal">%s</td>
+</tr>
+`, sf.ID, html.EscapeString(sf.Name), html.EscapeString(sf.Description), len(sf.Topics), sf.PostCount(), frontPageLastPost(sf))
+	}
+	b.WriteString("</table>\n")
+	pageFooter(&b)
+	return b.String()
+}
+
+// frontPageLastPost renders the "last active" cell of a front page row.
+func frontPageLastPost(sf SubForum) string {
+	latest := sortedByLastPost(sf)
+	if len(latest.Topics) == 0 {
+		return `<span class="midtext">No Posts</span>`
+	}
+	topic := latest.Topics[0]
+	post, ok := topic.LastPost()
+	if !ok {
+		return `<span class="midtext">No Posts</span>`
+	}
+	return fmt.Sprintf(`<span class="midtext">%s</span><br /><span class="smalltext">by %s <a class="b" href="viewtopic.php?topic=%s&amp;forum=%s&amp;post=%s">&raquo;</a></span>`,
+		post.Posted.Format(TimestampLayout), html.EscapeString(post.Author), topic.ID, sf.ID, post.ID)
+}
+
+// renderForumPage renders one listing page of a sub-forum starting at topic offset start.
+func renderForumPage(fixture Fixture, sf SubForum, start int) string {
+	perPage := fixture.topicsPerPage()
+	var b strings.Builder
+	pageHeader(&b, sf.Name)
+	fmt.Fprintf(&b, `<table class="normal" cellpadding="4" cellspacing="1">
+<tr><td class="w99 mltext"><a href="index.php">The Magic Cafe Forum Index</a> &raquo; &raquo; %s</td></tr>
+</table>
+<table class="normal" cellpadding="4" cellspacing="1">
+<tr><td class="normal bgc1 b midtext" colspan="6">%s</td></tr>
+<tr><th class="bgc3">&nbsp;</th><th class="bgc3">Topic</th><th class="bgc3">Author</th><th class="bgc3">Replies</th><th class="bgc3">Views</th><th class="bgc3">Last Post</th></tr>
+`, html.EscapeString(sf.Name), pageLinks(len(sf.Topics), perPage, start, fmt.Sprintf("viewforum.php?forum=%s", sf.ID)))
+
+	for i := start; i < len(sf.Topics) && i < start+perPage; i++ {
+		topic := sf.Topics[i]
+		author, lastPosted, lastAuthor := "", "", ""
+		if len(topic.Posts) > 0 {
+			author = topic.Posts[0].Author
+		}
+		if last, ok := topic.LastPost(); ok {
+			lastPosted, lastAuthor = last.Posted.Format(TimestampLayout), last.Author
+		}
+		fmt.Fprintf(&b, `<tr>
+	<td class="normal bgc1 c w5"><img src="images/folder.gif" alt="" /></td>
+	<td class="normal bgc2"><a class="b" href="viewtopic.php?topic=%s&amp;forum=%s">%s</a></td>
+	<td class="normal bgc1 c midtext">%s</td>
+	<td class="normal bgc2 c midtext">%d</td>
+	<td class="normal bgc1 c midtext">%d</td>
+	<td class="normal bgc2 c midtext">%s<br />by %s</td>
+</tr>
+`, topic.ID, sf.ID, html.EscapeString(topic.Title), html.EscapeString(author), topic.Replies(), topic.Views, lastPosted, html.EscapeString(lastAuthor))
+	}
+	b.WriteString("</table>\n")
+	pageFooter(&b)
+	return b.String()
+}
+
+// renderTopicPage renders one page of a topic starting at post offset start.
+// The second table.normal inside div#container holds the posts, as on the live forum.
+func renderTopicPage(fixture Fixture, sf SubForum, topic Topic, start int) string {
+	perPage := fixture.postsPerPage()
+	var b strings.Builder
+	pageHeader(&b, topic.Title)
+	fmt.Fprintf(&b, `<table class="normal" cellpadding="4" cellspacing="1">
+<tr><td class="w99 mltext"><a href="index.php">The Magic Cafe Forum Index</a> &raquo; &raquo; <a href="viewforum.php?forum=%s">%s</a> &raquo; &raquo; %s</td></tr>
+</table>
+<table class="normal" cellpadding="4" cellspacing="1">
+<tr><td class="normal bgc2 b midtext" colspan="11">%s</td></tr>
+`, sf.ID, html.EscapeString(sf.Name), html.EscapeString(topic.Title),
+		pageLinks(len(topic.Posts), perPage, start, fmt.Sprintf("viewtopic.php?topic=%s&amp;forum=%s", topic.ID, sf.ID)))
+
+	for i := start; i < len(topic.Posts) && i < start+perPage; i++ {
+		post := topic.Posts[i]
+		fmt.Fprintf(&b, `<tr>
+	<td class="normal bgc1 c w13 vat"><strong>%s</strong><br /><span class="smalltext">Regular user</span></td>
+	<td class="normal bgc1 vat w90"><div class="vt1 liketext"><div class="like_left">Posted: <span class="b">%s</span> <a name="%d"></a></div><div class="like_right"><span id="p_%s">0</span></div></div><div class="w100">
+<!-- POST TEXT -->
+%s
+<!-- END POST TEXT -->
+</div></td>
+</tr>
+`, html.EscapeString(post.Author), post.Posted.Format(TimestampLayout), i-start, post.ID, post.Body)
+	}
+	b.WriteString("</table>\n")
+	pageFooter(&b)
+	return b.String()
+}
+
+// pageLinks renders the "Go to page" navigation for itemCount items split into pages of perPage.
+// baseHref must already be HTML-escaped.
+func pageLinks(itemCount int, perPage int, start int, baseHref string) string {
+	pages := (itemCount + perPage - 1) / perPage
+	if pages <= 1 {
+		return "&nbsp;"
+	}
+	var b strings.Builder
+	b.WriteString("&nbsp;Go to page ")
+	currentPage := start/perPage + 1
+	for page := 1; page <= pages; page++ {
+		if page > 1 {
+			b.WriteString("~")
+		}
+		if page == currentPage {
+			fmt.Fprintf(&b, `<span class="on_page">%d</span>`, page)
+			continue
+		}
+		fmt.Fprintf(&b, `<a href="%s&amp;start=%d" title="Page %d">%d</a>`, baseHref, (page-1)*perPage, page, page)
+	}
+	if currentPage < pages {
+		fmt.Fprintf(&b, ` [<a href="%s&amp;start=%d" title="Next Page">Next</a>]`, baseHref, currentPage*perPage)
+	}
+	return b.String()
+}
+
+// renderSoftError renders the 200 OK "busy" page the forum sometimes returns instead of content.
+func renderSoftError() string {
+	var b strings.Builder
+	pageHeader(&b, "Error")
+	b.WriteString(`<table class="normal" cellpadding="4" cellspacing="1">
+<tr><td class="normal bgc1 c">Sorry, the Magic Cafe is very busy right now. Please try again in a few moments.</td></tr>
+</table>
+`)
+	pageFooter(&b)
+	return b.String()
+}
+
+// renderNotFound renders the page served for unknown forums and topics.
+func renderNotFound() string {
+	var b strings.Builder
+	pageHeader(&b, "Not Found")
+	b.WriteString(`<table class="normal" cellpadding="4" cellspacing="1">
+<tr><td class="normal bgc1 c">The topic or forum you requested does not exist.</td></tr>
+</table>
+`)
+	pageFooter(&b)
+	return b.String()
+}
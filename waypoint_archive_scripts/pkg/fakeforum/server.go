@@ -0,0 +1,263 @@
+package fakeforum
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ForumPath is the path prefix the fake forum is served under, as on the live site.
+const ForumPath = "/forums/"
+
+// Fault describes a misbehaviour injected into matching requests.
+type Fault struct {
+	Match      string        // Substring of the request URI (path and query); empty matches every request
+	Times      int           // Number of matching requests to affect; 0 affects all of them
+	Latency    time.Duration // Delay before answering
+	StatusCode int           // Answer with this status (e.g. 429 or 503) instead of the page
+	RetryAfter int           // Retry-After seconds sent with StatusCode, if positive
+	TruncateAt int           // Cut the page body after this many bytes, if positive
+	SoftError  bool          // Answer 200 with the forum's "busy" error page instead of the page
+}
+
+// ScheduledReply adds a post to a topic once the server has answered AfterRequests requests,
+// simulating a reply arriving in the middle of a run.
+type ScheduledReply struct {
+	AfterRequests int
+	TopicID       string
+	Post          Post
+}
+
+// Server is a running fake forum.
+type Server struct {
+	httpServer *httptest.Server
+
+	mux       sync.Mutex
+	fixture   Fixture
+	faults    []*activeFault
+	scheduled []ScheduledReply
+	requests  []string
+}
+
+type activeFault struct {
+	Fault
+	hits int
+}
+
+// NewServer starts a fake forum serving fixture. The fixture is copied, so later changes
+// must go through AddPost or ScheduleReply. Call Close when done.
+func NewServer(fixture Fixture) *Server {
+	s := &Server{fixture: fixture.clone()}
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.handle))
+	log.Printf("[INFO] FAKEFORUM: Serving %d sub-forums at %s", len(fixture.SubForums), s.BaseURL())
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// BaseURL returns the forum root, e.g. "http://127.0.0.1:12345/forums/".
+func (s *Server) BaseURL() string {
+	return s.httpServer.URL + ForumPath
+}
+
+// FrontPageURL returns the URL of the forum index page.
+func (s *Server) FrontPageURL() string {
+	return s.BaseURL() + "index.php"
+}
+
+// ForumURL returns the URL of the first listing page of a sub-forum.
+func (s *Server) ForumURL(subForumID string) string {
+	return s.BaseURL() + "viewforum.php?forum=" + subForumID
+}
+
+// TopicURL returns the URL of the first page of a topic.
+func (s *Server) TopicURL(subForumID string, topicID string) string {
+	return s.BaseURL() + "viewtopic.php?forum=" + subForumID + "&topic=" + topicID
+}
+
+// AddFault injects a fault. Faults are checked in the order they were added and the first
+// one that matches and has not used up its Times is applied.
+func (s *Server) AddFault(fault Fault) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.faults = append(s.faults, &activeFault{Fault: fault})
+}
+
+// ScheduleReply registers a reply to be added after the given number of requests.
+func (s *Server) ScheduleReply(reply ScheduledReply) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.scheduled = append(s.scheduled, reply)
+}
+
+// AddPost appends a post to a topic right away.
+func (s *Server) AddPost(topicID string, post Post) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.addPostLocked(topicID, post)
+}
+
+// Fixture returns a copy of the forum's current content, including any added posts.
+func (s *Server) Fixture() Fixture {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.fixture.clone()
+}
+
+// Requests returns the request URIs (path and query) received so far, in order.
+func (s *Server) Requests() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// RequestCount returns the number of requests whose URI contains substr.
+func (s *Server) RequestCount(substr string) int {
+	count := 0
+	for _, uri := range s.Requests() {
+		if strings.Contains(uri, substr) {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Server) addPostLocked(topicID string, post Post) error {
+	for i := range s.fixture.SubForums {
+		for j := range s.fixture.SubForums[i].Topics {
+			topic := &s.fixture.SubForums[i].Topics[j]
+			if topic.ID == topicID {
+				topic.Posts = append(topic.Posts, post)
+				log.Printf("[INFO] FAKEFORUM: Added post %s to topic %s (%d replies).", post.ID, topicID, topic.Replies())
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("fakeforum: topic %s not found", topicID)
+}
+
+// handle serves one request: it logs it, applies scheduled replies and faults, then renders the page.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	uri := r.URL.RequestURI()
+
+	s.mux.Lock()
+	s.requests = append(s.requests, uri)
+	s.applyScheduledLocked()
+	fault := s.matchFaultLocked(uri)
+	fixture := s.fixture.clone()
+	s.mux.Unlock()
+
+	if fault.Latency > 0 {
+		time.Sleep(fault.Latency)
+	}
+	if fault.StatusCode != 0 {
+		if fault.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+		}
+		http.Error(w, http.StatusText(fault.StatusCode), fault.StatusCode)
+		return
+	}
+
+	var page string
+	status := http.StatusOK
+	if fault.SoftError {
+		page = renderSoftError()
+	} else {
+		page, status = route(fixture, r)
+	}
+
+	body := []byte(page)
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if fault.TruncateAt > 0 && fault.TruncateAt < len(body) {
+		// The declared Content-Length stays at the full size, so clients see an unexpected EOF
+		body = body[:fault.TruncateAt]
+	}
+	w.Write(body)
+}
+
+// applyScheduledLocked adds every scheduled reply whose request threshold has been reached.
+func (s *Server) applyScheduledLocked() {
+	remaining := s.scheduled[:0]
+	for _, reply := range s.scheduled {
+		if len(s.requests) > reply.AfterRequests {
+			if err := s.addPostLocked(reply.TopicID, reply.Post); err != nil {
+				log.Printf("[WARNING] FAKEFORUM: Scheduled reply dropped: %v", err)
+			}
+			continue
+		}
+		remaining = append(remaining, reply)
+	}
+	s.scheduled = remaining
+}
+
+// matchFaultLocked returns the fault to apply to uri, or the zero Fault.
+func (s *Server) matchFaultLocked(uri string) Fault {
+	for _, fault := range s.faults {
+		if fault.Match != "" && !strings.Contains(uri, fault.Match) {
+			continue
+		}
+		if fault.Times > 0 && fault.hits >= fault.Times {
+			continue
+		}
+		fault.hits++
+		return fault.Fault
+	}
+	return Fault{}
+}
+
+// route renders the page for r and its status code.
+func route(fixture Fixture, r *http.Request) (string, int) {
+	if !strings.HasPrefix(r.URL.Path, ForumPath) {
+		return renderNotFound(), http.StatusNotFound
+	}
+	script := strings.TrimPrefix(r.URL.Path, ForumPath)
+	q := r.URL.Query()
+	start, _ := strconv.Atoi(q.Get("start"))
+	if start < 0 {
+		start = 0
+	}
+
+	switch script {
+	case "", "index.php":
+		return renderFrontPage(fixture), http.StatusOK
+	case "viewforum.php":
+		for _, sf := range fixture.SubForums {
+			if sf.ID == q.Get("forum") {
+				return renderForumPage(fixture, sortedByLastPost(sf), start), http.StatusOK
+			}
+		}
+	case "viewtopic.php":
+		topicID := q.Get("topic")
+		for _, sf := range fixture.SubForums {
+			for _, topic := range sf.Topics {
+				if topic.ID == topicID {
+					return renderTopicPage(fixture, sf, topic, start), http.StatusOK
+				}
+			}
+		}
+	}
+	return renderNotFound(), http.StatusNotFound
+}
+
+// sortedByLastPost returns sf with its topics ordered like the live listing: most recent activity first.
+func sortedByLastPost(sf SubForum) SubForum {
+	topics := append([]Topic(nil), sf.Topics...)
+	sort.SliceStable(topics, func(i, j int) bool {
+		lastI, _ := topics[i].LastPost()
+		lastJ, _ := topics[j].LastPost()
+		return lastI.Posted.After(lastJ.Posted)
+	})
+	sf.Topics = topics
+	return sf
+}
@@ -0,0 +1,219 @@
+package fakeforum
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"waypoint_archive_scripts/pkg/config"
+	"waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/downloader"
+	"waypoint_archive_scripts/pkg/htmlutil"
+	"waypoint_archive_scripts/pkg/pageplan"
+)
+
+var baseTime = time.Date(2003, time.January, 10, 11, 42, 0, 0, time.UTC)
+
+// testFixture builds a sub-forum with topicCount topics; topic N has N+1 posts and
+// its last post N hours after baseTime, so higher-numbered topics sort first.
+func testFixture(topicCount int) Fixture {
+	sf := SubForum{ID: "66", Name: "What happened, was this...", Description: "History of magic"}
+	postID := 1000
+	for i := 0; i < topicCount; i++ {
+		topic := Topic{ID: fmt.Sprintf("%d", 19600+i), Title: fmt.Sprintf("Topic %d", i), Views: 100 + i}
+		for j := 0; j <= i; j++ {
+			postID++
+			topic.Posts = append(topic.Posts, Post{
+				ID:     fmt.Sprintf("%d", postID),
+				Author: fmt.Sprintf("User%d", j),
+				Posted: baseTime.Add(time.Duration(i)*time.Hour + time.Duration(j)*time.Minute),
+				Body:   fmt.Sprintf("Post %d of topic %d.", j, i),
+			})
+		}
+		sf.Topics = append(sf.Topics, topic)
+	}
+	return Fixture{SubForums: []SubForum{sf}}
+}
+
+func newTestDownloader() *downloader.Downloader {
+	return downloader.NewDownloader(&config.Config{UserAgent: "FakeForumTest/1.0"})
+}
+
+// pageplanTopic describes a fixture topic the way the index does.
+func pageplanTopic(srv *Server, topic Topic) data.Topic {
+	return data.Topic{ID: topic.ID, SubForumID: "66", URL: srv.TopicURL("66", topic.ID), Replies: topic.Replies()}
+}
+
+func TestServer_ListingPagesParse(t *testing.T) {
+	srv := NewServer(testFixture(35))
+	defer srv.Close()
+
+	body, err := newTestDownloader().FetchPage(srv.ForumURL("66"))
+	if err != nil {
+		t.Fatalf("FetchPage returned error: %v", err)
+	}
+	topics, err := htmlutil.ExtractTopicsFromHTMLInUtil(string(body), srv.ForumURL("66"), "66")
+	if err != nil {
+		t.Fatalf("ExtractTopicsFromHTMLInUtil returned error: %v", err)
+	}
+	if len(topics) != DefaultTopicsPerPage {
+		t.Fatalf("Expected %d topics on the first listing page, got %d", DefaultTopicsPerPage, len(topics))
+	}
+	first := topics[0]
+	if first.ID != "19634" || first.Replies != 34 || first.Views != 134 || first.LastPostUsername != "User34" {
+		t.Errorf("Most recently active topic parsed as %+v", first)
+	}
+	if want := "Jan 11, 2003 10:16 pm"; first.LastPostTimestampRaw != want {
+		t.Errorf("LastPostTimestampRaw = %q, want %q", first.LastPostTimestampRaw, want)
+	}
+
+	links, err := htmlutil.ParsePaginationLinks(string(body), srv.ForumURL("66"))
+	if err != nil {
+		t.Fatalf("ParsePaginationLinks returned error: %v", err)
+	}
+	if len(links) != 1 || !strings.Contains(links[0], "start=30") {
+		t.Errorf("Expected a single link to the second listing page, got %v", links)
+	}
+}
+
+func TestServer_TopicPagesMatchPagePlan(t *testing.T) {
+	fixture := testFixture(45)
+	srv := NewServer(fixture)
+	defer srv.Close()
+
+	topic := fixture.SubForums[0].Topics[44] // 45 posts, 3 pages
+	planned, err := pageplan.PlanTopicPageURLs(pageplanTopic(srv, topic), DefaultPostsPerPage)
+	if err != nil {
+		t.Fatalf("PlanTopicPageURLs returned error: %v", err)
+	}
+	if len(planned) != 3 {
+		t.Fatalf("Expected 3 planned pages, got %v", planned)
+	}
+
+	d := newTestDownloader()
+	wantPostsPerPage := []int{20, 20, 5}
+	for i, pageURL := range planned {
+		body, err := d.FetchPage(pageURL)
+		if err != nil {
+			t.Fatalf("FetchPage(%s) returned error: %v", pageURL, err)
+		}
+		if got := strings.Count(string(body), `<span id="p_`); got != wantPostsPerPage[i] {
+			t.Errorf("Page %d has %d posts, want %d", i+1, got, wantPostsPerPage[i])
+		}
+	}
+}
+
+func TestServer_Faults(t *testing.T) {
+	srv := NewServer(testFixture(3))
+	defer srv.Close()
+	d := newTestDownloader()
+	topicURL := srv.TopicURL("66", "19602")
+
+	srv.AddFault(Fault{Match: "topic=19602", Times: 1, StatusCode: http.StatusServiceUnavailable})
+	srv.AddFault(Fault{Match: "topic=19602", Times: 1, StatusCode: http.StatusTooManyRequests, RetryAfter: 7})
+	srv.AddFault(Fault{Match: "topic=19602", Times: 1, TruncateAt: 100})
+	srv.AddFault(Fault{Match: "topic=19602", Times: 1, SoftError: true})
+	srv.AddFault(Fault{Match: "topic=19602", Times: 1, Latency: 50 * time.Millisecond})
+
+	var httpErr *downloader.HTTPError
+	if _, err := d.FetchPage(topicURL); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected a 503 HTTPError, got %v", err)
+	}
+
+	resp, err := http.Get(topicURL)
+	if err != nil {
+		t.Fatalf("http.Get returned error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "7" {
+		t.Errorf("Expected 429 with Retry-After 7, got %d and %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	if _, err := d.FetchPage(topicURL); err == nil {
+		t.Error("Expected an error for a truncated body")
+	}
+
+	body, err := d.FetchPage(topicURL)
+	if err != nil {
+		t.Fatalf("Soft error page returned error: %v", err)
+	}
+	if !strings.Contains(string(body), "very busy") || strings.Contains(string(body), `<span id="p_`) {
+		t.Error("Expected the soft error page instead of the topic")
+	}
+
+	started := time.Now()
+	body, err = d.FetchPage(topicURL)
+	if err != nil {
+		t.Fatalf("Delayed page returned error: %v", err)
+	}
+	if time.Since(started) < 50*time.Millisecond {
+		t.Error("Expected the injected latency to delay the response")
+	}
+	if got := strings.Count(string(body), `<span id="p_`); got != 3 {
+		t.Errorf("Expected the real topic page once faults are used up, got %d posts", got)
+	}
+	if got := srv.RequestCount("topic=19602"); got != 5 {
+		t.Errorf("RequestCount = %d, want 5", got)
+	}
+}
+
+func TestServer_ScheduledReplyBumpsTopic(t *testing.T) {
+	srv := NewServer(testFixture(3))
+	defer srv.Close()
+	srv.ScheduleReply(ScheduledReply{
+		AfterRequests: 1,
+		TopicID:       "19600",
+		Post:          Post{ID: "9999", Author: "LateUser", Posted: baseTime.Add(48 * time.Hour), Body: "A late reply."},
+	})
+	d := newTestDownloader()
+
+	listing := func() string {
+		body, err := d.FetchPage(srv.ForumURL("66"))
+		if err != nil {
+			t.Fatalf("FetchPage returned error: %v", err)
+		}
+		topics, err := htmlutil.ExtractTopicsFromHTMLInUtil(string(body), srv.ForumURL("66"), "66")
+		if err != nil || len(topics) == 0 {
+			t.Fatalf("ExtractTopicsFromHTMLInUtil returned %v, %v", topics, err)
+		}
+		return fmt.Sprintf("%s/%d", topics[0].ID, topics[0].Replies)
+	}
+
+	if got := listing(); got != "19602/2" {
+		t.Errorf("Before the reply the first topic is %s, want 19602/2", got)
+	}
+	if got := listing(); got != "19600/1" {
+		t.Errorf("After the reply the first topic is %s, want 19600/1", got)
+	}
+	if got := len(srv.Fixture().SubForums[0].Topics[0].Posts); got != 2 {
+		t.Errorf("Fixture snapshot has %d posts in the replied topic, want 2", got)
+	}
+}
+
+func TestLoadFixture(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "fixture.json")
+	content := `{"posts_per_page": 10, "sub_forums": [{"id": "7", "name": "Seven", "topics": [
+		{"id": "70", "title": "Only topic", "posts": [{"id": "700", "author": "Ann", "posted": "2003-01-10T11:42:00Z", "body": "Hi"}]}
+	]}]}`
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+	fixture, err := LoadFixture(filePath)
+	if err != nil {
+		t.Fatalf("LoadFixture returned error: %v", err)
+	}
+	if fixture.postsPerPage() != 10 || fixture.topicsPerPage() != DefaultTopicsPerPage {
+		t.Errorf("Unexpected page sizes: %d posts, %d topics", fixture.postsPerPage(), fixture.topicsPerPage())
+	}
+	if len(fixture.SubForums) != 1 || fixture.SubForums[0].Topics[0].Posts[0].Author != "Ann" {
+		t.Errorf("Unexpected fixture: %+v", fixture)
+	}
+	if _, err := LoadFixture(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error for a missing fixture file")
+	}
+}
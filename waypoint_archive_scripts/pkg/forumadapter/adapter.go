@@ -0,0 +1,330 @@
+// Package forumadapter isolates everything that depends on the forum software being archived:
+// the markup of listing and topic pages, how pagination, posts, post metadata and quotes are
+// found in it, and the shape of listing, topic and post URLs. The indexer, archiver, JIT refresh
+// and extractor only talk to a ForumAdapter, so supporting another forum engine means adding an
+// adapter here rather than changing selectors across the tree.
+//
+// MagicCafe is the adapter for The Magic Cafe and the default. PhpBB3 covers a stock phpBB 3
+// board with the prosilver style.
+package forumadapter
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"waypoint_archive_scripts/pkg/canonurl"
+	"waypoint_archive_scripts/pkg/config"
+	"waypoint_archive_scripts/pkg/data"
+)
+
+// ForumAdapter knows the markup and URL scheme of one forum engine.
+type ForumAdapter interface {
+	// Name is the engine name used in configuration, e.g. "magiccafe".
+	Name() string
+	// TopicsPerPage is the number of topics on a full sub-forum listing page.
+	TopicsPerPage() int
+	// PostsPerPage is the number of posts on a full topic page.
+	PostsPerPage() int
+
+	// ParseURL parses a listing, topic or post URL of this engine.
+	ParseURL(rawURL string) (canonurl.Ref, error)
+	// FormatURL formats ref as a URL this engine serves.
+	FormatURL(ref canonurl.Ref) string
+
+	// ExtractTopics returns the topics listed on a sub-forum listing page, with their URLs
+	// formatted by FormatURL.
+	ExtractTopics(doc *goquery.Document, pageURL string, subForumID string) ([]data.Topic, error)
+	// PaginationLinks returns the unique listing or topic page links found in the page's
+	// pagination controls, resolved against pageURL and formatted by FormatURL.
+	PaginationLinks(doc *goquery.Document, pageURL string) ([]string, error)
+
+	// PostBlocks returns one element per post on a topic page, in page order.
+	PostBlocks(doc *goquery.Document) *goquery.Selection
+	// PostMetadata extracts the metadata of a post block. Fields that could not be extracted are
+	// left at their zero values and reported as *FieldError values joined into the error.
+	PostMetadata(block *goquery.Selection) (PostMetadata, error)
+	// ContentBlock returns the element holding a post's body, or an empty selection.
+	ContentBlock(block *goquery.Selection) *goquery.Selection
+	// IsQuote reports whether a node inside a content block is a quote.
+	IsQuote(node *goquery.Selection) bool
+	// QuoteDetails extracts the attribution and text of a node for which IsQuote is true.
+	QuoteDetails(node *goquery.Selection) (Quote, error)
+	// ParseTimestamp parses a post or last-post timestamp as shown by this engine.
+	ParseTimestamp(raw string) (time.Time, error)
+}
+
+// PostMetadata is the metadata an adapter extracts from a single post block.
+type PostMetadata struct {
+	PostID         string
+	AuthorUsername string
+	TimestampRaw   string    // Timestamp as shown on the page
+	Timestamp      time.Time // Zero if TimestampRaw could not be parsed
+	OrderOnPage    int       // 0-based position marked on the page, or -1 if the engine does not mark it
+}
+
+// Quote is the attribution and text of a quote inside a post.
+type Quote struct {
+	User      string
+	Timestamp string // Raw timestamp from the attribution, empty if there is none
+	Text      string // Inner HTML of the quoted text
+}
+
+// Names of the post metadata fields used in FieldError.
+const (
+	FieldAuthorUsername = "author username"
+	FieldTimestamp      = "timestamp"
+	FieldPostID         = "post ID"
+	FieldOrderOnPage    = "post order on page"
+)
+
+// FieldError reports a post metadata field that could not be extracted.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErr returns the error recorded for field in an error returned by PostMetadata, or nil.
+func FieldErr(err error, field string) error {
+	for _, fieldErr := range FieldErrors(err) {
+		if fieldErr.Field == field {
+			return fieldErr.Err
+		}
+	}
+	return nil
+}
+
+// FieldErrors returns every *FieldError joined into err, in the order they were recorded.
+func FieldErrors(err error) []*FieldError {
+	var fieldErrs []*FieldError
+	var walk func(error)
+	walk = func(err error) {
+		if err == nil {
+			return
+		}
+		if fieldErr, ok := err.(*FieldError); ok {
+			fieldErrs = append(fieldErrs, fieldErr)
+			return
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, inner := range joined.Unwrap() {
+				walk(inner)
+			}
+		}
+	}
+	walk(err)
+	return fieldErrs
+}
+
+// Engine names accepted in config.Config.ForumEngine.
+const (
+	MagicCafeName = "magiccafe"
+	PhpBB3Name    = "phpbb3"
+)
+
+// adapters holds every known adapter by name.
+var adapters = map[string]ForumAdapter{
+	MagicCafeName: MagicCafe{},
+	PhpBB3Name:    PhpBB3{},
+}
+
+// Lookup returns the adapter for an engine name. The empty string means Magic Cafe.
+func Lookup(name string) (ForumAdapter, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = MagicCafeName
+	}
+	adapter, ok := adapters[name]
+	if !ok {
+		return nil, fmt.Errorf("forumadapter: unknown forum engine %q (want %s)", name, strings.Join(Names(), " or "))
+	}
+	return adapter, nil
+}
+
+// Names returns the known engine names, sorted.
+func Names() []string {
+	names := make([]string, 0, len(adapters))
+	for name := range adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	defaultMux     sync.RWMutex
+	defaultAdapter ForumAdapter = MagicCafe{}
+)
+
+// Default returns the process-wide adapter used by packages that are not handed one explicitly.
+func Default() ForumAdapter {
+	defaultMux.RLock()
+	defer defaultMux.RUnlock()
+	return defaultAdapter
+}
+
+// SetDefault makes adapter the process-wide default and returns a function restoring the previous one.
+func SetDefault(adapter ForumAdapter) (restore func()) {
+	defaultMux.Lock()
+	defer defaultMux.Unlock()
+	previous := defaultAdapter
+	defaultAdapter = adapter
+	return func() { SetDefault(previous) }
+}
+
+// FromConfig returns the adapter for the engine configured in cfg, using the selector profiles
+// file configured in cfg if there is one.
+func FromConfig(cfg *config.Config) (ForumAdapter, error) {
+	adapter, err := Lookup(cfg.ForumEngine)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SelectorProfilesFile == "" {
+		return adapter, nil
+	}
+	return WithProfilesFile(adapter, cfg.SelectorProfilesFile)
+}
+
+// WithProfilesFile returns adapter using the selector profiles in profilesFile. Only the
+// magiccafe adapter is driven by selector profiles.
+func WithProfilesFile(adapter ForumAdapter, profilesFile string) (ForumAdapter, error) {
+	if _, ok := adapter.(MagicCafe); !ok {
+		return nil, fmt.Errorf("forumadapter: selector profiles are not supported by the %s adapter", adapter.Name())
+	}
+	magicCafe, err := NewMagicCafe(profilesFile)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] FORUMADAPTER: Loaded selector profiles %s from %s", strings.Join(magicCafe.Profiles.Names(), ", "), profilesFile)
+	return magicCafe, nil
+}
+
+// Setup looks up the adapter configured in cfg and makes it the process-wide default.
+// A PostsPerPage left at the Magic Cafe default is replaced by the adapter's page size.
+func Setup(cfg *config.Config) (ForumAdapter, error) {
+	adapter, err := FromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PostsPerPage == config.DefaultConfig().PostsPerPage && adapter.PostsPerPage() != cfg.PostsPerPage {
+		cfg.PostsPerPage = adapter.PostsPerPage()
+		log.Printf("[INFO] FORUMADAPTER: Using %d posts per page for the %s forum adapter", cfg.PostsPerPage, adapter.Name())
+	}
+	SetDefault(adapter)
+	log.Printf("[INFO] FORUMADAPTER: Using the %s forum adapter", adapter.Name())
+	return adapter, nil
+}
+
+// ParseHTML parses a page for the adapter methods that take a document.
+func ParseHTML(pageHTML string) (*goquery.Document, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create goquery document: %w", err)
+	}
+	return doc, nil
+}
+
+// collectLinks resolves the href of every element in links against pageURL and returns the
+// unique ones formatted by the adapter. Topic and post links inherit the forum of the page they were found
+// on when they do not carry one.
+func collectLinks(adapter ForumAdapter, links *goquery.Selection, pageURL string) ([]string, error) {
+	parsedPageURL, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page URL %s: %w", pageURL, err)
+	}
+	baseRef, err := canonurl.FromURL(parsedPageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var resolved []string
+	seen := make(map[string]bool)
+	links.Each(func(i int, s *goquery.Selection) {
+		href, exists := s.Attr("href")
+		if !exists || href == "" || href == "#" || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return
+		}
+		absURL, err := parsedPageURL.Parse(href)
+		if err != nil {
+			log.Printf("[WARNING] FORUMADAPTER: Error parsing pagination link '%s' on page %s: %v", href, pageURL, err)
+			return
+		}
+		ref, err := canonurl.FromURL(absURL)
+		if err != nil {
+			log.Printf("[WARNING] FORUMADAPTER: Skipping pagination link '%s' on page %s: %v", href, pageURL, err)
+			return
+		}
+		if (ref.Kind == canonurl.KindTopic || ref.Kind == canonurl.KindPost) && ref.SubForumID == "" {
+			ref.SubForumID = baseRef.SubForumID
+		}
+		link := adapter.FormatURL(ref)
+		if !seen[link] {
+			resolved = append(resolved, link)
+			seen[link] = true
+		}
+	})
+	return resolved, nil
+}
+
+// topicRef resolves a topic link from a listing page to the reference of the topic's first page.
+// ok is false for links that do not identify a topic, such as links to a single post.
+func topicRef(parsedPageURL *url.URL, href string) (ref canonurl.Ref, ok bool) {
+	topicURL, err := parsedPageURL.Parse(href)
+	if err != nil {
+		return canonurl.Ref{}, false
+	}
+	ref, err = canonurl.FromURL(topicURL)
+	if err != nil || ref.TopicID == "" {
+		return canonurl.Ref{}, false
+	}
+	ref.Kind, ref.PostID, ref.Start = canonurl.KindTopic, "", 0
+	return ref, true
+}
+
+// leadingInt parses the number at the start of text, ignoring thousands separators.
+func leadingInt(text string) (int, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.ReplaceAll(fields[0], ",", ""))
+	return n, err == nil
+}
+
+// parseWithLayouts tries each layout in turn and returns the last error if none matches.
+func parseWithLayouts(raw string, layouts []string) (time.Time, error) {
+	raw = strings.TrimSpace(strings.ReplaceAll(raw, "\u00a0", " "))
+	var parseErr error
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, raw)
+		if err == nil {
+			return t, nil
+		}
+		parseErr = err
+	}
+	return time.Time{}, fmt.Errorf("failed to parse timestamp '%s' with known layouts: %w", raw, parseErr)
+}
+
+// joinFieldErrors joins the recorded field errors, or returns nil if there are none.
+func joinFieldErrors(fieldErrs []error) error {
+	if len(fieldErrs) == 0 {
+		return nil
+	}
+	return errors.Join(fieldErrs...)
+}
@@ -0,0 +1,97 @@
+package forumadapter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"waypoint_archive_scripts/pkg/config"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name     string
+		wantName string
+		wantErr  bool
+	}{
+		{name: "", wantName: MagicCafeName},
+		{name: "magiccafe", wantName: MagicCafeName},
+		{name: " PhpBB3 ", wantName: PhpBB3Name},
+		{name: "vbulletin", wantErr: true},
+	}
+	for _, tt := range tests {
+		adapter, err := Lookup(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Lookup(%q) expected error, got adapter %s", tt.name, adapter.Name())
+			} else if !strings.Contains(err.Error(), "magiccafe or phpbb3") {
+				t.Errorf("Lookup(%q) error %q does not list the known engines", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Lookup(%q) returned error: %v", tt.name, err)
+			continue
+		}
+		if adapter.Name() != tt.wantName {
+			t.Errorf("Lookup(%q) = %s, want %s", tt.name, adapter.Name(), tt.wantName)
+		}
+	}
+}
+
+func TestSetup(t *testing.T) {
+	defer SetDefault(Default())
+
+	cfg := config.DefaultConfig()
+	cfg.ForumEngine = PhpBB3Name
+	adapter, err := Setup(cfg)
+	if err != nil {
+		t.Fatalf("Setup returned error: %v", err)
+	}
+	if Default() != adapter || adapter.Name() != PhpBB3Name {
+		t.Errorf("Default() = %s after Setup, want %s", Default().Name(), PhpBB3Name)
+	}
+	if cfg.PostsPerPage != phpbbPostsPerPage {
+		t.Errorf("PostsPerPage = %d, want the phpBB page size %d", cfg.PostsPerPage, phpbbPostsPerPage)
+	}
+
+	// An explicitly configured page size is kept
+	cfg = config.DefaultConfig()
+	cfg.ForumEngine = PhpBB3Name
+	cfg.PostsPerPage = 15
+	if _, err := Setup(cfg); err != nil {
+		t.Fatalf("Setup returned error: %v", err)
+	}
+	if cfg.PostsPerPage != 15 {
+		t.Errorf("PostsPerPage = %d, want the configured 15", cfg.PostsPerPage)
+	}
+
+	cfg.ForumEngine = "unknown"
+	if _, err := Setup(cfg); err == nil {
+		t.Error("Setup with an unknown engine expected error, got nil")
+	}
+}
+
+func TestFieldErr(t *testing.T) {
+	authorErr := errors.New("no author")
+	err := joinFieldErrors([]error{
+		&FieldError{Field: FieldAuthorUsername, Err: authorErr},
+		&FieldError{Field: FieldPostID, Err: errors.New("no ID")},
+	})
+
+	if got := FieldErr(err, FieldAuthorUsername); got != authorErr {
+		t.Errorf("FieldErr(author) = %v, want %v", got, authorErr)
+	}
+	if got := FieldErr(err, FieldTimestamp); got != nil {
+		t.Errorf("FieldErr(timestamp) = %v, want nil", got)
+	}
+	if got := len(FieldErrors(err)); got != 2 {
+		t.Errorf("len(FieldErrors) = %d, want 2", got)
+	}
+	if !errors.Is(err, authorErr) {
+		t.Error("errors.Is did not find the wrapped field error")
+	}
+	if FieldErrors(nil) != nil || joinFieldErrors(nil) != nil {
+		t.Error("expected no field errors for a nil error")
+	}
+}
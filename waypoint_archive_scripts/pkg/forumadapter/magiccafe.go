@@ -0,0 +1,308 @@
+package forumadapter
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"waypoint_archive_scripts/pkg/canonurl"
+	"waypoint_archive_scripts/pkg/data"
+)
+
+// mcTopicsPerPage is the number of topics on a full Magic Cafe listing page.
+const mcTopicsPerPage = 30
+
+// listingTimestampRegex matches forum timestamps such as "Jan 23, 2003 02:45 pm" inside listing cells.
+var listingTimestampRegex = regexp.MustCompile(`[A-Z][a-z]{2} \d{1,2}, \d{4} \d{1,2}:\d{2} [ap]m`)
+
+// quoteTimestampRegex matches the timestamp in a quote attribution, e.g. "Jan 23, 2003, 07:22 AM".
+var quoteTimestampRegex = regexp.MustCompile(`(?i)(Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)\s+\d{1,2},\s+\d{4},\s+\d{1,2}:\d{2}\s+(AM|PM)`)
+
+// MagicCafe is the adapter for The Magic Cafe. Its selectors and timestamp layouts come from
+// selector profiles, detected per page, so archives spanning several forum skins extract with
+// one adapter. A nil Profiles uses the built-in profiles.
+type MagicCafe struct {
+	Profiles *ProfileSet
+}
+
+// NewMagicCafe returns a Magic Cafe adapter using the profiles in a selector profiles file.
+func NewMagicCafe(profilesFile string) (MagicCafe, error) {
+	profiles, err := LoadProfiles(profilesFile)
+	if err != nil {
+		return MagicCafe{}, err
+	}
+	return MagicCafe{Profiles: profiles}, nil
+}
+
+func (a MagicCafe) profiles() *ProfileSet {
+	if a.Profiles == nil {
+		return builtinProfiles
+	}
+	return a.Profiles
+}
+
+func (MagicCafe) Name() string       { return MagicCafeName }
+func (MagicCafe) TopicsPerPage() int { return mcTopicsPerPage }
+func (MagicCafe) PostsPerPage() int  { return canonurl.DefaultPostsPerPage }
+
+// ParseURL parses a Magic Cafe URL. phpBB-style 'f'/'t'/'p' parameters are accepted as aliases.
+func (MagicCafe) ParseURL(rawURL string) (canonurl.Ref, error) {
+	return canonurl.Parse(rawURL)
+}
+
+// FormatURL formats ref with Magic Cafe's 'forum', 'topic', 'post' and 'start' parameters.
+func (MagicCafe) FormatURL(ref canonurl.Ref) string {
+	return ref.String()
+}
+
+// ExtractTopics reads the topic rows of a listing page. Replies, views and the last post are
+// taken from the cells following the title cell when the row provides them.
+func (a MagicCafe) ExtractTopics(doc *goquery.Document, pageURL string, subForumID string) ([]data.Topic, error) {
+	parsedPageURL, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page URL %s: %w", pageURL, err)
+	}
+
+	profile, _ := a.profiles().Detect(doc.Selection)
+	topics := make([]data.Topic, 0)
+	doc.Find(profile.Selectors.ListingRow).Each(func(i int, tr *goquery.Selection) {
+		tr.Find(profile.Selectors.TopicLink).Each(func(j int, link *goquery.Selection) {
+			href, exists := link.Attr("href")
+			if !exists {
+				return
+			}
+
+			topicTitle := strings.TrimSpace(link.Text())
+			if topicTitle == "" {
+				// Attempt to get title from a 'title' attribute if text is empty
+				if titleAttr, ok := link.Attr("title"); ok {
+					topicTitle = strings.TrimSpace(strings.TrimPrefix(titleAttr, "Topic:")) // Example for "Topic: Actual Title"
+				}
+				if topicTitle == "" {
+					return
+				}
+			}
+
+			// Links that only identify a post cannot be mapped to the topic ID used by the index
+			ref, ok := topicRef(parsedPageURL, href)
+			if !ok {
+				log.Printf("[WARNING] FORUMADAPTER: Topic ID not found for link '%s' with title '%s' on page %s. Skipping topic.", href, topicTitle, pageURL)
+				return
+			}
+
+			topic := data.Topic{
+				ID:         string(ref.TopicID),
+				SubForumID: subForumID,
+				Title:      topicTitle,
+				URL:        a.FormatURL(ref),
+			}
+			populateListingRowCounts(&topic, link.Closest("td"))
+			topics = append(topics, topic)
+		})
+	})
+	return topics, nil
+}
+
+// populateListingRowCounts fills Replies, Views, LastPostTimestampRaw and LastPostUsername on a topic
+// from the cells that follow its title cell in a sub-forum listing row.
+// The first two purely numeric cells are taken as replies and views; the last cell holding a
+// timestamp is taken as the last-post cell. Missing cells leave the fields at their zero values.
+func populateListingRowCounts(topic *data.Topic, titleCell *goquery.Selection) {
+	if titleCell == nil || titleCell.Length() == 0 {
+		return
+	}
+
+	var numbers []int
+	titleCell.NextAllFiltered("td").Each(func(i int, cell *goquery.Selection) {
+		text := strings.TrimSpace(cell.Text())
+		if n, err := strconv.Atoi(strings.ReplaceAll(text, ",", "")); err == nil && len(numbers) < 2 {
+			numbers = append(numbers, n)
+			return
+		}
+		if ts := listingTimestampRegex.FindString(text); ts != "" {
+			topic.LastPostTimestampRaw = ts
+			topic.LastPostUsername = ""
+			if idx := strings.LastIndex(text, "by "); idx >= 0 {
+				topic.LastPostUsername = strings.TrimSpace(text[idx+len("by "):])
+			} else if profileLink := cell.Find("a[href*='profile']").Last(); profileLink.Length() > 0 {
+				topic.LastPostUsername = strings.TrimSpace(profileLink.Text())
+			}
+		}
+	})
+
+	if len(numbers) > 0 {
+		topic.Replies = numbers[0]
+	}
+	if len(numbers) > 1 {
+		topic.Views = numbers[1]
+	}
+}
+
+// PaginationLinks returns the "Go to page" links of a listing or topic page.
+func (a MagicCafe) PaginationLinks(doc *goquery.Document, pageURL string) ([]string, error) {
+	profile, _ := a.profiles().Detect(doc.Selection)
+	if profile.Selectors.Pagination == "" {
+		return nil, nil
+	}
+	return collectLinks(a, doc.Find(profile.Selectors.Pagination), pageURL)
+}
+
+// PostBlocks returns the post rows of a topic page. On the current skin the first table.normal
+// holds the breadcrumbs and the second one holds the posts.
+func (a MagicCafe) PostBlocks(doc *goquery.Document) *goquery.Selection {
+	profile, _ := a.profiles().Detect(doc.Selection)
+	selectors := profile.Selectors
+	if selectors.PostsTable == "" {
+		return doc.Find(selectors.PostRow)
+	}
+	return doc.Find(selectors.PostsTable).Eq(selectors.PostsTableIndex).Find(selectors.PostRow)
+}
+
+// PostMetadata reads the author cell and the header of the content cell of a post row.
+func (a MagicCafe) PostMetadata(block *goquery.Selection) (PostMetadata, error) {
+	selectors := a.profiles().detectPost(block).Selectors
+	metadata := PostMetadata{OrderOnPage: -1}
+	var fieldErrs []error
+	fail := func(field string, err error) {
+		fieldErrs = append(fieldErrs, &FieldError{Field: field, Err: err})
+	}
+
+	authorCell := block.Find(selectors.AuthorCell).First()
+	if authorCell.Length() == 0 {
+		fail(FieldAuthorUsername, fmt.Errorf("author cell ('%s') not found", selectors.AuthorCell))
+	} else if nameEl := authorCell.ChildrenFiltered(selectors.AuthorName).First(); nameEl.Length() == 0 {
+		fail(FieldAuthorUsername, fmt.Errorf("username element ('%s') not found as a child of the author cell", selectors.AuthorName))
+	} else {
+		metadata.AuthorUsername = strings.TrimSpace(nameEl.Text())
+	}
+
+	contentCell := block.Find(selectors.ContentCell).First()
+	if contentCell.Length() == 0 {
+		err := fmt.Errorf("content cell ('%s') not found", selectors.ContentCell)
+		fail(FieldTimestamp, err)
+		fail(FieldPostID, err)
+		if selectors.PostOrder != "" {
+			fail(FieldOrderOnPage, err)
+		}
+		return metadata, joinFieldErrors(fieldErrs)
+	}
+
+	if timestampEl := contentCell.Find(selectors.Timestamp).First(); timestampEl.Length() == 0 {
+		fail(FieldTimestamp, fmt.Errorf("timestamp span.b not found with selector '%s' within timestamp cell", selectors.Timestamp))
+	} else {
+		raw := strings.TrimSpace(strings.ReplaceAll(timestampEl.Text(), "\u00a0", " "))
+		if selectors.TimestampPrefix != "" {
+			raw = strings.TrimSpace(strings.TrimPrefix(raw, selectors.TimestampPrefix))
+		}
+		metadata.TimestampRaw = raw
+		if raw == "" {
+			fail(FieldTimestamp, fmt.Errorf("timestamp string was empty after trimming"))
+		} else if t, err := a.ParseTimestamp(raw); err != nil {
+			fail(FieldTimestamp, err)
+		} else {
+			metadata.Timestamp = t
+		}
+	}
+
+	if idEl := contentCell.Find(selectors.PostID).First(); idEl.Length() == 0 {
+		fail(FieldPostID, fmt.Errorf("post ID element not found with selector: %s in timestamp cell", selectors.PostID))
+	} else if id := strings.TrimPrefix(idEl.AttrOr(selectors.PostIDAttr, ""), selectors.PostIDPrefix); id == "" {
+		fail(FieldPostID, fmt.Errorf("extracted post ID from attribute '%s' was empty", idEl.AttrOr(selectors.PostIDAttr, "")))
+	} else {
+		metadata.PostID = id
+	}
+
+	// Skins that do not mark the post order leave it to the caller
+	if selectors.PostOrder == "" {
+		return metadata, joinFieldErrors(fieldErrs)
+	}
+	if anchor := contentCell.Find(selectors.PostOrder).First(); anchor.Length() == 0 {
+		fail(FieldOrderOnPage, fmt.Errorf("post order anchor element not found with selector: %s in timestamp cell", selectors.PostOrder))
+	} else if order, err := strconv.Atoi(anchor.AttrOr(selectors.PostOrderAttr, "")); err != nil {
+		fail(FieldOrderOnPage, fmt.Errorf("failed to convert post order '%s' to integer: %w", anchor.AttrOr(selectors.PostOrderAttr, ""), err))
+	} else {
+		metadata.OrderOnPage = order
+	}
+
+	return metadata, joinFieldErrors(fieldErrs)
+}
+
+// ContentBlock returns the content cell of a post row.
+func (a MagicCafe) ContentBlock(block *goquery.Selection) *goquery.Selection {
+	return block.Find(a.profiles().detectPost(block).Selectors.ContentCell).First()
+}
+
+// IsQuote reports whether node is a quote table of any profile.
+func (a MagicCafe) IsQuote(node *goquery.Selection) bool {
+	return a.profiles().detectQuote(node) != nil
+}
+
+// QuoteDetails reads a quote table. The attribution cell holds the quoted user (a <b> tag on the
+// current skin) and sometimes a timestamp; the quoted text is in the other cell of the attribution's row.
+func (a MagicCafe) QuoteDetails(node *goquery.Selection) (Quote, error) {
+	profile := a.profiles().detectQuote(node)
+	if profile == nil {
+		return Quote{}, fmt.Errorf("element is not a quote of any selector profile")
+	}
+	selectors := profile.Selectors
+
+	attributionCell := node.Find(selectors.QuoteAttribution).First()
+	if attributionCell.Length() == 0 {
+		return Quote{}, fmt.Errorf("could not find attribution cell in quote element")
+	}
+
+	// Handle variations like "Username wrote:" or "Quote: Username"
+	var quote Quote
+	rawUserText := strings.TrimSpace(attributionCell.Find(selectors.QuoteUser).First().Text())
+	switch {
+	case strings.HasSuffix(rawUserText, " wrote:"):
+		quote.User = strings.TrimSuffix(rawUserText, " wrote:")
+	case strings.HasPrefix(rawUserText, "Quote: "):
+		quote.User = strings.TrimPrefix(rawUserText, "Quote: ")
+	default:
+		quote.User = rawUserText
+	}
+	quote.User = strings.TrimSpace(quote.User)
+
+	// The timestamp is kept raw: "Today" and "Yesterday" forms would need a reference date to parse
+	if match := quoteTimestampRegex.FindString(strings.TrimSpace(attributionCell.Text())); match != "" {
+		quote.Timestamp = strings.TrimSpace(match)
+	}
+
+	node.Find("td").Each(func(i int, td *goquery.Selection) {
+		if td.Nodes[0] != attributionCell.Nodes[0] && td.Parent().Nodes[0] == attributionCell.Parent().Nodes[0] {
+			if !strings.Contains(td.Text(), quote.User) {
+				if html, err := td.Html(); err == nil {
+					quote.Text = strings.TrimSpace(html)
+				}
+			}
+		}
+	})
+	if quote.Text == "" {
+		// Otherwise take the first other cell belonging to this quote rather than a nested one
+		node.Find("td").FilterFunction(func(i int, s *goquery.Selection) bool {
+			return s.Nodes[0] != attributionCell.Nodes[0]
+		}).EachWithBreak(func(i int, td *goquery.Selection) bool {
+			if td.ParentsFiltered(selectors.Quote).Length() == 1 {
+				if html, err := td.Html(); err == nil {
+					quote.Text = strings.TrimSpace(html)
+					return false
+				}
+			}
+			return true
+		})
+	}
+	return quote, nil
+}
+
+// ParseTimestamp parses a Magic Cafe timestamp such as "Jan 23, 2003 02:45 pm" with the
+// timestamp layouts of every profile.
+func (a MagicCafe) ParseTimestamp(raw string) (time.Time, error) {
+	return parseWithLayouts(raw, a.profiles().layouts)
+}
@@ -0,0 +1,148 @@
+package forumadapter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"waypoint_archive_scripts/pkg/canonurl"
+)
+
+const mcListingHTML = `<html><body><div id="container">
+<table class="normal"><tr><td class="w99 mltext"><a href="index.php">The Magic Cafe Forum Index</a></td></tr></table>
+<table class="normal">
+<tr><td class="normal bgc1 b midtext" colspan="6">&nbsp;Go to page <span class="on_page">1</span>~<a href="viewforum.php?forum=66&amp;start=30">2</a> [<a href="viewforum.php?forum=66&amp;start=30">Next</a>]</td></tr>
+<tr>
+	<td class="normal bgc1 c w5"></td>
+	<td class="normal bgc2"><a class="b" href="viewtopic.php?topic=19600&amp;forum=66">First topic</a></td>
+	<td class="normal bgc1 c midtext">User0</td>
+	<td class="normal bgc2 c midtext">1,204</td>
+	<td class="normal bgc1 c midtext">5000</td>
+	<td class="normal bgc2 c midtext">Jan 10, 2003 11:42 am<br />by User3</td>
+</tr>
+<tr>
+	<td class="normal bgc1 c w5"></td>
+	<td class="normal bgc2"><a class="b" href="viewtopic.php?post=5&amp;forum=66">Post-only link</a></td>
+</tr>
+</table>
+</div></body></html>`
+
+const mcTopicHTML = `<html><body><div id="container">
+<table class="normal"><tr><td class="w99 mltext"><a href="index.php">Index</a></td></tr></table>
+<table class="normal">
+<tr><td class="normal bgc2 b midtext" colspan="11">&nbsp;</td></tr>
+<tr>
+	<td class="normal bgc1 c w13 vat"><strong>Bob</strong><br /><span class="smalltext">Regular user</span></td>
+	<td class="normal bgc1 vat w90"><div class="vt1 liketext"><div class="like_left">Posted: <span class="b">Jan 23, 2003 02:45 pm</span> <a name="0"></a></div><div class="like_right"><span id="p_175716">0</span></div></div><div class="w100">
+<table class="cfq"><tr><td><b>Alice wrote:</b> Jan 22, 2003, 07:22 AM</td><td>Quoted text</td></tr></table>
+Reply text
+</div></td>
+</tr>
+<tr>
+	<td class="normal bgc1 c w13 vat"><span>No strong tag</span></td>
+	<td class="normal bgc1 vat w90"><div class="vt1 liketext"><div class="like_left">Posted: <span class="b">not a date</span></div></div></td>
+</tr>
+</table>
+</div></body></html>`
+
+func TestMagicCafe_ExtractTopics(t *testing.T) {
+	doc, err := ParseHTML(mcListingHTML)
+	if err != nil {
+		t.Fatalf("ParseHTML returned error: %v", err)
+	}
+	pageURL := "https://www.themagiccafe.com/forums/viewforum.php?forum=66"
+
+	topics, err := MagicCafe{}.ExtractTopics(doc, pageURL, "66")
+	if err != nil {
+		t.Fatalf("ExtractTopics returned error: %v", err)
+	}
+	if len(topics) != 1 {
+		t.Fatalf("expected 1 topic (the post-only link is skipped), got %d: %+v", len(topics), topics)
+	}
+	topic := topics[0]
+	if topic.ID != "19600" || topic.Title != "First topic" || topic.SubForumID != "66" {
+		t.Errorf("unexpected topic identity: %+v", topic)
+	}
+	if want := "https://www.themagiccafe.com/forums/viewtopic.php?forum=66&topic=19600"; topic.URL != want {
+		t.Errorf("URL = %s, want %s", topic.URL, want)
+	}
+	if topic.Replies != 1204 || topic.Views != 5000 {
+		t.Errorf("Replies/Views = %d/%d, want 1204/5000", topic.Replies, topic.Views)
+	}
+	if topic.LastPostTimestampRaw != "Jan 10, 2003 11:42 am" || topic.LastPostUsername != "User3" {
+		t.Errorf("unexpected last post: %q by %q", topic.LastPostTimestampRaw, topic.LastPostUsername)
+	}
+
+	links, err := MagicCafe{}.PaginationLinks(doc, pageURL)
+	if err != nil {
+		t.Fatalf("PaginationLinks returned error: %v", err)
+	}
+	if len(links) != 1 || links[0] != "https://www.themagiccafe.com/forums/viewforum.php?forum=66&start=30" {
+		t.Errorf("PaginationLinks = %v, want the single deduplicated page 2 link", links)
+	}
+}
+
+func TestMagicCafe_PostMetadata(t *testing.T) {
+	doc, err := ParseHTML(mcTopicHTML)
+	if err != nil {
+		t.Fatalf("ParseHTML returned error: %v", err)
+	}
+	adapter := MagicCafe{}
+	blocks := adapter.PostBlocks(doc)
+	if blocks.Length() != 2 {
+		t.Fatalf("expected 2 post blocks, got %d", blocks.Length())
+	}
+
+	metadata, err := adapter.PostMetadata(blocks.Eq(0))
+	if err != nil {
+		t.Fatalf("PostMetadata returned error for a valid post: %v", err)
+	}
+	want := PostMetadata{
+		PostID:         "175716",
+		AuthorUsername: "Bob",
+		TimestampRaw:   "Jan 23, 2003 02:45 pm",
+		Timestamp:      time.Date(2003, time.January, 23, 14, 45, 0, 0, time.UTC),
+		OrderOnPage:    0,
+	}
+	if metadata != want {
+		t.Errorf("PostMetadata = %+v, want %+v", metadata, want)
+	}
+
+	_, err = adapter.PostMetadata(blocks.Eq(1))
+	for _, field := range []string{FieldAuthorUsername, FieldTimestamp, FieldPostID, FieldOrderOnPage} {
+		if FieldErr(err, field) == nil {
+			t.Errorf("expected a %s error for a broken post, got %v", field, err)
+		}
+	}
+	if fieldErr := FieldErr(err, FieldTimestamp); fieldErr != nil && !strings.Contains(fieldErr.Error(), "failed to parse timestamp") {
+		t.Errorf("unexpected timestamp error: %v", fieldErr)
+	}
+}
+
+func TestMagicCafe_QuoteDetails(t *testing.T) {
+	doc, err := ParseHTML(mcTopicHTML)
+	if err != nil {
+		t.Fatalf("ParseHTML returned error: %v", err)
+	}
+	adapter := MagicCafe{}
+	quoteNode := adapter.ContentBlock(adapter.PostBlocks(doc).First()).Find("table").First()
+	if !adapter.IsQuote(quoteNode) {
+		t.Fatal("IsQuote returned false for table.cfq")
+	}
+
+	quote, err := adapter.QuoteDetails(quoteNode)
+	if err != nil {
+		t.Fatalf("QuoteDetails returned error: %v", err)
+	}
+	want := Quote{User: "Alice", Timestamp: "Jan 22, 2003, 07:22 AM", Text: "Quoted text"}
+	if quote != want {
+		t.Errorf("QuoteDetails = %+v, want %+v", quote, want)
+	}
+}
+
+func TestMagicCafe_FormatURL(t *testing.T) {
+	ref := canonurl.TopicPage("66", "19600", 40)
+	if got, want := (MagicCafe{}).FormatURL(ref), "https://www.themagiccafe.com/forums/viewtopic.php?forum=66&start=40&topic=19600"; got != want {
+		t.Errorf("FormatURL = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,241 @@
+package forumadapter
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"waypoint_archive_scripts/pkg/canonurl"
+	"waypoint_archive_scripts/pkg/data"
+)
+
+// Selectors for a stock phpBB 3 board with the prosilver style (3.0 to 3.3). Listings are
+// lists of topic rows; each post is a div.post with an id of "p" followed by the post ID.
+const (
+	phpbbTopicRowSelector   = "ul.topiclist.topics > li.row"
+	phpbbTopicLinkSelector  = "a.topictitle"
+	phpbbPaginationSelector = "div.pagination a[href]"
+	phpbbPostSelector       = "div.post[id^=p]"
+	phpbbAuthorSelector     = "p.author .username, p.author .username-coloured, .postprofile .username, .postprofile .username-coloured"
+	phpbbContentSelector    = "div.content"
+	phpbbQuoteSelector      = "blockquote"
+	phpbbUsernameSelector   = ".username, .username-coloured"
+)
+
+// Board defaults for the number of topics per listing page and posts per topic page.
+const (
+	phpbbTopicsPerPage = 25
+	phpbbPostsPerPage  = 10
+)
+
+// phpbbTimestampLayouts cover the ISO timestamps of <time datetime> (phpBB 3.2+) and the
+// board's default "D M d, Y g:i a" date format shown as text by phpBB 3.0.
+var phpbbTimestampLayouts = []string{
+	time.RFC3339,
+	"Mon Jan 02, 2006 3:04 pm",
+	"Mon Jan 02, 2006 15:04",
+}
+
+// phpbbTimestampRegex finds a default-format timestamp such as "Thu Jan 05, 2023 10:00 am" in text.
+var phpbbTimestampRegex = regexp.MustCompile(`[A-Z][a-z]{2} [A-Z][a-z]{2} \d{2}, \d{4} \d{1,2}:\d{2}(?: [ap]m)?`)
+
+// PhpBB3 is the adapter for a stock phpBB 3 board.
+type PhpBB3 struct{}
+
+func (PhpBB3) Name() string       { return PhpBB3Name }
+func (PhpBB3) TopicsPerPage() int { return phpbbTopicsPerPage }
+func (PhpBB3) PostsPerPage() int  { return phpbbPostsPerPage }
+
+// ParseURL parses a phpBB URL ('f', 't' and 'p' parameters).
+func (PhpBB3) ParseURL(rawURL string) (canonurl.Ref, error) {
+	return canonurl.Parse(rawURL)
+}
+
+// FormatURL formats ref with phpBB's parameters. Posts are linked as viewtopic.php?p=ID#pID,
+// which phpBB redirects to the right page of the topic.
+func (PhpBB3) FormatURL(ref canonurl.Ref) string {
+	if ref.Kind == canonurl.KindOther {
+		return ref.String()
+	}
+
+	q := url.Values{}
+	script := "viewtopic.php"
+	fragment := ""
+	switch ref.Kind {
+	case canonurl.KindForum:
+		script = "viewforum.php"
+		q.Set("f", string(ref.SubForumID))
+	case canonurl.KindTopic:
+		if ref.SubForumID != "" {
+			q.Set("f", string(ref.SubForumID))
+		}
+		q.Set("t", string(ref.TopicID))
+	case canonurl.KindPost:
+		q.Set("p", string(ref.PostID))
+		fragment = "p" + string(ref.PostID)
+	}
+	if ref.Start > 0 && ref.Kind != canonurl.KindPost {
+		q.Set("start", strconv.Itoa(ref.Start))
+	}
+
+	u := url.URL{Scheme: ref.Scheme, Host: ref.Host, Path: ref.Dir + script, RawQuery: q.Encode(), Fragment: fragment}
+	return u.String()
+}
+
+// ExtractTopics reads the topic rows of a viewforum.php page. Announcements and stickies are
+// listed in their own ul.topiclist before the normal topics and are included.
+func (a PhpBB3) ExtractTopics(doc *goquery.Document, pageURL string, subForumID string) ([]data.Topic, error) {
+	parsedPageURL, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page URL %s: %w", pageURL, err)
+	}
+
+	topics := make([]data.Topic, 0)
+	doc.Find(phpbbTopicRowSelector).Each(func(i int, row *goquery.Selection) {
+		link := row.Find(phpbbTopicLinkSelector).First()
+		href, exists := link.Attr("href")
+		topicTitle := strings.TrimSpace(link.Text())
+		if !exists || topicTitle == "" {
+			return
+		}
+		ref, ok := topicRef(parsedPageURL, href)
+		if !ok {
+			log.Printf("[WARNING] FORUMADAPTER: Topic ID not found for link '%s' with title '%s' on page %s. Skipping topic.", href, topicTitle, pageURL)
+			return
+		}
+
+		topic := data.Topic{
+			ID:             string(ref.TopicID),
+			SubForumID:     subForumID,
+			Title:          topicTitle,
+			URL:            a.FormatURL(ref),
+			AuthorUsername: strings.TrimSpace(row.Find("dt " + phpbbUsernameSelector).First().Text()),
+			IsSticky:       row.HasClass("sticky") || row.HasClass("announce") || row.HasClass("global-announce"),
+			IsLocked:       row.Find("dl[class*='locked']").Length() > 0,
+		}
+		if replies, ok := leadingInt(row.Find("dd.posts").Text()); ok {
+			topic.Replies = replies
+		}
+		if views, ok := leadingInt(row.Find("dd.views").Text()); ok {
+			topic.Views = views
+		}
+		lastPost := row.Find("dd.lastpost")
+		topic.LastPostUsername = strings.TrimSpace(lastPost.Find(phpbbUsernameSelector).First().Text())
+		topic.LastPostTimestampRaw = phpbbTimestamp(lastPost)
+		topics = append(topics, topic)
+	})
+	return topics, nil
+}
+
+// PaginationLinks returns the page links of a listing or topic page.
+func (a PhpBB3) PaginationLinks(doc *goquery.Document, pageURL string) ([]string, error) {
+	return collectLinks(a, doc.Find(phpbbPaginationSelector), pageURL)
+}
+
+// PostBlocks returns the post divs of a viewtopic.php page.
+func (PhpBB3) PostBlocks(doc *goquery.Document) *goquery.Selection {
+	return doc.Find(phpbbPostSelector)
+}
+
+// PostMetadata reads a post div. phpBB does not number posts on the page, so OrderOnPage is
+// always -1 and callers use the block's position.
+func (a PhpBB3) PostMetadata(block *goquery.Selection) (PostMetadata, error) {
+	metadata := PostMetadata{OrderOnPage: -1}
+	var fieldErrs []error
+	fail := func(field string, err error) {
+		fieldErrs = append(fieldErrs, &FieldError{Field: field, Err: err})
+	}
+
+	post := block.Filter(phpbbPostSelector)
+	if post.Length() == 0 {
+		post = block.Find(phpbbPostSelector).First()
+	}
+	if post.Length() == 0 {
+		err := fmt.Errorf("post element ('%s') not found", phpbbPostSelector)
+		fail(FieldAuthorUsername, err)
+		fail(FieldTimestamp, err)
+		fail(FieldPostID, err)
+		return metadata, joinFieldErrors(fieldErrs)
+	}
+
+	if id := strings.TrimPrefix(post.AttrOr("id", ""), "p"); id == "" {
+		fail(FieldPostID, fmt.Errorf("post element has no post ID in its id attribute"))
+	} else {
+		metadata.PostID = id
+	}
+
+	if author := post.Find(phpbbAuthorSelector).First(); author.Length() == 0 {
+		fail(FieldAuthorUsername, fmt.Errorf("author username not found with selector: %s", phpbbAuthorSelector))
+	} else {
+		metadata.AuthorUsername = strings.TrimSpace(author.Text())
+	}
+
+	metadata.TimestampRaw = phpbbTimestamp(post.Find("p.author").First())
+	if metadata.TimestampRaw == "" {
+		fail(FieldTimestamp, fmt.Errorf("timestamp not found in the post's author line"))
+	} else if t, err := a.ParseTimestamp(metadata.TimestampRaw); err != nil {
+		fail(FieldTimestamp, err)
+	} else {
+		metadata.Timestamp = t
+	}
+
+	return metadata, joinFieldErrors(fieldErrs)
+}
+
+// phpbbTimestamp returns the datetime attribute of the first <time> in s or, on boards that
+// predate it, the first default-format timestamp in its text.
+func phpbbTimestamp(s *goquery.Selection) string {
+	if datetime, ok := s.Find("time[datetime]").First().Attr("datetime"); ok {
+		return strings.TrimSpace(datetime)
+	}
+	return phpbbTimestampRegex.FindString(strings.ReplaceAll(s.Text(), "\u00a0", " "))
+}
+
+// ContentBlock returns the body of a post.
+func (PhpBB3) ContentBlock(block *goquery.Selection) *goquery.Selection {
+	return block.Find(phpbbContentSelector).First()
+}
+
+// IsQuote reports whether node is a quote.
+func (PhpBB3) IsQuote(node *goquery.Selection) bool {
+	return node.Is(phpbbQuoteSelector)
+}
+
+// QuoteDetails reads a blockquote. The attribution is a <cite> ("bob wrote:", with a link and a
+// <time> or date on newer versions); quotes without one are anonymous.
+func (PhpBB3) QuoteDetails(node *goquery.Selection) (Quote, error) {
+	body := node.ChildrenFiltered("div").First()
+	if body.Length() == 0 {
+		body = node
+	}
+	body = body.Clone()
+
+	var quote Quote
+	if cite := body.ChildrenFiltered("cite").First(); cite.Length() > 0 {
+		quote.Timestamp = phpbbTimestamp(cite)
+		if user := cite.Find("a[href*='memberlist.php']").First(); user.Length() > 0 {
+			quote.User = strings.TrimSpace(user.Text())
+		} else {
+			quote.User = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(cite.Text()), "wrote:"))
+		}
+		cite.Remove()
+	}
+
+	text, err := body.Html()
+	if err != nil {
+		return Quote{}, fmt.Errorf("failed to read quote text: %w", err)
+	}
+	quote.Text = strings.TrimSpace(text)
+	return quote, nil
+}
+
+// ParseTimestamp parses a <time datetime> value or a default-format phpBB date.
+func (PhpBB3) ParseTimestamp(raw string) (time.Time, error) {
+	return parseWithLayouts(raw, phpbbTimestampLayouts)
+}
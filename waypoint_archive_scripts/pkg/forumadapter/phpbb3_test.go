@@ -0,0 +1,199 @@
+package forumadapter
+
+import (
+	"testing"
+	"time"
+
+	"waypoint_archive_scripts/pkg/canonurl"
+)
+
+const phpbbListingHTML = `<html><body>
+<div class="pagination">2 topics &bull; <strong>1</strong> <a href="./viewforum.php?f=2&amp;start=25">2</a> <a href="./viewforum.php?f=2&amp;start=25" rel="next">Next</a></div>
+<ul class="topiclist topics">
+<li class="row bg1 sticky"><dl class="row-item sticky_read">
+	<dt><div class="list-inner"><a href="./viewtopic.php?f=2&amp;t=10" class="topictitle">Board rules</a><br />
+	by <a href="./memberlist.php?mode=viewprofile&amp;u=2" class="username-coloured">admin</a></div></dt>
+	<dd class="posts">3 <dfn>Replies</dfn></dd>
+	<dd class="views">1,250 <dfn>Views</dfn></dd>
+	<dd class="lastpost"><span><dfn>Last post </dfn>by <a href="./memberlist.php?mode=viewprofile&amp;u=3" class="username">carol</a>
+	<br /><time datetime="2023-01-05T10:00:00+00:00">Thu Jan 05, 2023 10:00 am</time></span></dd>
+</dl></li>
+<li class="row bg2"><dl class="row-item topic_read_locked">
+	<dt><div class="list-inner"><a href="./viewtopic.php?f=2&amp;t=11" class="topictitle">Old thread</a><br />
+	by <span class="username">dave</span></div></dt>
+	<dd class="posts">0 <dfn>Replies</dfn></dd>
+	<dd class="views">7 <dfn>Views</dfn></dd>
+	<dd class="lastpost"><span>by <span class="username">dave</span> &raquo; Mon Jan 02, 2023 9:15 pm</span></dd>
+</dl></li>
+</ul>
+</body></html>`
+
+const phpbbTopicHTML = `<html><body>
+<div id="p101" class="post has-profile bg2"><div class="inner">
+	<dl class="postprofile"><dt><a href="./memberlist.php?mode=viewprofile&amp;u=3" class="username">carol</a></dt></dl>
+	<div class="postbody">
+		<p class="author">by <strong><a href="./memberlist.php?mode=viewprofile&amp;u=3" class="username">carol</a></strong> &raquo; <time datetime="2023-01-05T10:00:00+00:00">Thu Jan 05, 2023 10:00 am</time></p>
+		<div class="content"><blockquote><div><cite><a href="./memberlist.php?mode=viewprofile&amp;u=4">dave</a> wrote: <a href="./viewtopic.php?p=100#p100">&uarr;</a><span class="responsive-hide"><time datetime="2023-01-04T09:00:00+00:00">Wed Jan 04, 2023 9:00 am</time></span></cite>Original text</div></blockquote>Reply text</div>
+	</div>
+</div></div>
+<div id="p102" class="post bg1"><div class="inner"><div class="postbody">
+	<p class="author">by <strong><span class="username">erin</span></strong> &raquo; Thu Jan 05, 2023 11:30 pm</p>
+	<div class="content"><blockquote class="uncited"><div>Anonymous quote</div></blockquote></div>
+</div></div></div>
+</body></html>`
+
+func TestPhpBB3_ExtractTopics(t *testing.T) {
+	doc, err := ParseHTML(phpbbListingHTML)
+	if err != nil {
+		t.Fatalf("ParseHTML returned error: %v", err)
+	}
+	pageURL := "https://forum.example.com/phpBB3/viewforum.php?f=2"
+
+	topics, err := PhpBB3{}.ExtractTopics(doc, pageURL, "2")
+	if err != nil {
+		t.Fatalf("ExtractTopics returned error: %v", err)
+	}
+	if len(topics) != 2 {
+		t.Fatalf("expected 2 topics, got %d: %+v", len(topics), topics)
+	}
+
+	rules := topics[0]
+	if rules.ID != "10" || rules.Title != "Board rules" || rules.AuthorUsername != "admin" {
+		t.Errorf("unexpected first topic: %+v", rules)
+	}
+	if want := "https://forum.example.com/phpBB3/viewtopic.php?f=2&t=10"; rules.URL != want {
+		t.Errorf("URL = %s, want %s", rules.URL, want)
+	}
+	if !rules.IsSticky || rules.IsLocked {
+		t.Errorf("IsSticky/IsLocked = %v/%v, want true/false", rules.IsSticky, rules.IsLocked)
+	}
+	if rules.Replies != 3 || rules.Views != 1250 {
+		t.Errorf("Replies/Views = %d/%d, want 3/1250", rules.Replies, rules.Views)
+	}
+	if rules.LastPostUsername != "carol" || rules.LastPostTimestampRaw != "2023-01-05T10:00:00+00:00" {
+		t.Errorf("unexpected last post: %q by %q", rules.LastPostTimestampRaw, rules.LastPostUsername)
+	}
+
+	old := topics[1]
+	if !old.IsLocked || old.IsSticky {
+		t.Errorf("IsSticky/IsLocked = %v/%v, want false/true", old.IsSticky, old.IsLocked)
+	}
+	if old.LastPostTimestampRaw != "Mon Jan 02, 2023 9:15 pm" {
+		t.Errorf("LastPostTimestampRaw = %q, want the text timestamp", old.LastPostTimestampRaw)
+	}
+
+	links, err := PhpBB3{}.PaginationLinks(doc, pageURL)
+	if err != nil {
+		t.Fatalf("PaginationLinks returned error: %v", err)
+	}
+	if len(links) != 1 || links[0] != "https://forum.example.com/phpBB3/viewforum.php?f=2&start=25" {
+		t.Errorf("PaginationLinks = %v, want the single deduplicated page 2 link", links)
+	}
+}
+
+func TestPhpBB3_PostMetadata(t *testing.T) {
+	doc, err := ParseHTML(phpbbTopicHTML)
+	if err != nil {
+		t.Fatalf("ParseHTML returned error: %v", err)
+	}
+	adapter := PhpBB3{}
+	blocks := adapter.PostBlocks(doc)
+	if blocks.Length() != 2 {
+		t.Fatalf("expected 2 post blocks, got %d", blocks.Length())
+	}
+
+	tests := []struct {
+		index int
+		want  PostMetadata
+	}{
+		{0, PostMetadata{
+			PostID:         "101",
+			AuthorUsername: "carol",
+			TimestampRaw:   "2023-01-05T10:00:00+00:00",
+			Timestamp:      time.Date(2023, time.January, 5, 10, 0, 0, 0, time.UTC),
+			OrderOnPage:    -1,
+		}},
+		{1, PostMetadata{
+			PostID:         "102",
+			AuthorUsername: "erin",
+			TimestampRaw:   "Thu Jan 05, 2023 11:30 pm",
+			Timestamp:      time.Date(2023, time.January, 5, 23, 30, 0, 0, time.UTC),
+			OrderOnPage:    -1,
+		}},
+	}
+	for _, tt := range tests {
+		metadata, err := adapter.PostMetadata(blocks.Eq(tt.index))
+		if err != nil {
+			t.Errorf("post %d: PostMetadata returned error: %v", tt.index, err)
+			continue
+		}
+		if !metadata.Timestamp.Equal(tt.want.Timestamp) {
+			t.Errorf("post %d: Timestamp = %v, want %v", tt.index, metadata.Timestamp, tt.want.Timestamp)
+		}
+		metadata.Timestamp, tt.want.Timestamp = time.Time{}, time.Time{}
+		if metadata != tt.want {
+			t.Errorf("post %d: PostMetadata = %+v, want %+v", tt.index, metadata, tt.want)
+		}
+	}
+
+	emptyDoc, _ := ParseHTML("<div>no posts</div>")
+	_, err = adapter.PostMetadata(emptyDoc.Selection)
+	if FieldErr(err, FieldPostID) == nil || FieldErr(err, FieldAuthorUsername) == nil {
+		t.Errorf("expected post ID and author errors for a block without a post, got %v", err)
+	}
+}
+
+func TestPhpBB3_QuoteDetails(t *testing.T) {
+	doc, err := ParseHTML(phpbbTopicHTML)
+	if err != nil {
+		t.Fatalf("ParseHTML returned error: %v", err)
+	}
+	adapter := PhpBB3{}
+	blocks := adapter.PostBlocks(doc)
+
+	tests := []struct {
+		index int
+		want  Quote
+	}{
+		{0, Quote{User: "dave", Timestamp: "2023-01-04T09:00:00+00:00", Text: "Original text"}},
+		{1, Quote{Text: "Anonymous quote"}},
+	}
+	for _, tt := range tests {
+		node := adapter.ContentBlock(blocks.Eq(tt.index)).Children().First()
+		if !adapter.IsQuote(node) {
+			t.Errorf("post %d: IsQuote returned false for a blockquote", tt.index)
+			continue
+		}
+		quote, err := adapter.QuoteDetails(node)
+		if err != nil {
+			t.Errorf("post %d: QuoteDetails returned error: %v", tt.index, err)
+			continue
+		}
+		if quote != tt.want {
+			t.Errorf("post %d: QuoteDetails = %+v, want %+v", tt.index, quote, tt.want)
+		}
+	}
+}
+
+func TestPhpBB3_FormatURL(t *testing.T) {
+	ref, err := canonurl.Parse("https://forum.example.com/phpBB3/viewtopic.php?f=2&t=10&start=20&sid=abc")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	adapter := PhpBB3{}
+
+	tests := []struct {
+		ref  canonurl.Ref
+		want string
+	}{
+		{ref, "https://forum.example.com/phpBB3/viewtopic.php?f=2&start=20&t=10"},
+		{ref.WithStart(0), "https://forum.example.com/phpBB3/viewtopic.php?f=2&t=10"},
+		{canonurl.Ref{Kind: canonurl.KindForum, Scheme: "https", Host: "forum.example.com", Dir: "/", SubForumID: "2", Start: 25}, "https://forum.example.com/viewforum.php?f=2&start=25"},
+		{canonurl.Ref{Kind: canonurl.KindPost, Scheme: "https", Host: "forum.example.com", Dir: "/", TopicID: "10", PostID: "101", Start: 20}, "https://forum.example.com/viewtopic.php?p=101#p101"},
+	}
+	for _, tt := range tests {
+		if got := adapter.FormatURL(tt.ref); got != tt.want {
+			t.Errorf("FormatURL(%+v) = %s, want %s", tt.ref, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package forumadapter
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// Profile describes one skin of a table-based forum such as The Magic Cafe: the CSS selectors
+// and timestamp layouts used to read its pages and the markers that identify pages using it.
+// Profiles are loaded from JSON so that a markup change means editing a profile, not Go code.
+type Profile struct {
+	Name string `json:"name"`
+	// Markers are selectors identifying pages of this skin. A page uses the first profile with a
+	// matching marker; a profile without markers matches every page.
+	Markers          []string  `json:"markers"`
+	Selectors        Selectors `json:"selectors"`
+	TimestampLayouts []string  `json:"timestampLayouts"` // Go layouts of post and last-post timestamps
+}
+
+// Selectors are the CSS selectors of a Profile. Optional selectors may be left empty.
+type Selectors struct {
+	PostsTable       string `json:"postsTable"`       // Optional: table holding the post rows; empty searches the whole page
+	PostsTableIndex  int    `json:"postsTableIndex"`  // Which match of PostsTable holds the posts
+	PostRow          string `json:"postRow"`          // One element per post
+	AuthorCell       string `json:"authorCell"`       // Within a post row
+	AuthorName       string `json:"authorName"`       // Child of the author cell holding the username
+	ContentCell      string `json:"contentCell"`      // Within a post row; holds the header and the body
+	Timestamp        string `json:"timestamp"`        // Within the content cell
+	TimestampPrefix  string `json:"timestampPrefix"`  // Optional: text trimmed from the start of the timestamp
+	PostID           string `json:"postID"`           // Within the content cell
+	PostIDAttr       string `json:"postIDAttr"`       // Attribute of the PostID element holding the ID
+	PostIDPrefix     string `json:"postIDPrefix"`     // Optional: prefix trimmed from the attribute value
+	PostOrder        string `json:"postOrder"`        // Optional: element marking the post's position on the page
+	PostOrderAttr    string `json:"postOrderAttr"`    // Attribute of the PostOrder element holding the position
+	Quote            string `json:"quote"`            // Quote element inside the content cell
+	QuoteAttribution string `json:"quoteAttribution"` // Cell of the quote element holding the attribution
+	QuoteUser        string `json:"quoteUser"`        // Within the attribution cell
+	ListingRow       string `json:"listingRow"`       // Row of a sub-forum listing
+	TopicLink        string `json:"topicLink"`        // Topic link within a listing row
+	Pagination       string `json:"pagination"`       // Optional: page links of listing and topic pages
+}
+
+// profileFile is the layout of a selector profiles file.
+type profileFile struct {
+	Profiles []Profile `json:"profiles"`
+}
+
+// Validate checks that the required selectors are set, every selector compiles and at
+// least one timestamp layout is given.
+func (p Profile) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("profile has no name")
+	}
+	s := p.Selectors
+	required := []struct{ field, value string }{
+		{"postRow", s.PostRow},
+		{"authorCell", s.AuthorCell},
+		{"authorName", s.AuthorName},
+		{"contentCell", s.ContentCell},
+		{"timestamp", s.Timestamp},
+		{"postID", s.PostID},
+		{"postIDAttr", s.PostIDAttr},
+		{"quote", s.Quote},
+		{"quoteAttribution", s.QuoteAttribution},
+		{"quoteUser", s.QuoteUser},
+		{"listingRow", s.ListingRow},
+		{"topicLink", s.TopicLink},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			return fmt.Errorf("profile '%s': selector %s is required", p.Name, r.field)
+		}
+	}
+	if s.PostOrder != "" && s.PostOrderAttr == "" {
+		return fmt.Errorf("profile '%s': selector postOrderAttr is required when postOrder is set", p.Name)
+	}
+	if s.PostsTableIndex < 0 {
+		return fmt.Errorf("profile '%s': postsTableIndex must not be negative", p.Name)
+	}
+	if len(p.TimestampLayouts) == 0 {
+		return fmt.Errorf("profile '%s': at least one timestamp layout is required", p.Name)
+	}
+
+	selectors := append([]string{s.PostsTable, s.PostRow, s.AuthorCell, s.AuthorName, s.ContentCell, s.Timestamp,
+		s.PostID, s.PostOrder, s.Quote, s.QuoteAttribution, s.QuoteUser, s.ListingRow, s.TopicLink, s.Pagination}, p.Markers...)
+	for _, selector := range selectors {
+		if selector == "" {
+			continue
+		}
+		if _, err := cascadia.Compile(selector); err != nil {
+			return fmt.Errorf("profile '%s': invalid selector '%s': %w", p.Name, selector, err)
+		}
+	}
+	return nil
+}
+
+// matchesPage reports whether any of the profile's markers matches the page.
+func (p *Profile) matchesPage(page *goquery.Selection) bool {
+	if len(p.Markers) == 0 {
+		return true
+	}
+	for _, marker := range p.Markers {
+		if page.Find(marker).Length() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPost reports whether a post block has the author and content cells of the profile.
+func (p *Profile) matchesPost(block *goquery.Selection) bool {
+	return block.Find(p.Selectors.AuthorCell).Length() > 0 && block.Find(p.Selectors.ContentCell).Length() > 0
+}
+
+// ProfileSet is an ordered list of profiles. The first profile is the fallback for pages
+// that no profile's markers match.
+type ProfileSet struct {
+	profiles []Profile
+	layouts  []string
+}
+
+// NewProfileSet validates profiles and returns them as a set, in the given order.
+func NewProfileSet(profiles []Profile) (*ProfileSet, error) {
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("no selector profiles given")
+	}
+	set := &ProfileSet{profiles: make([]Profile, 0, len(profiles))}
+	names := make(map[string]bool)
+	seenLayouts := make(map[string]bool)
+	for _, profile := range profiles {
+		if err := profile.Validate(); err != nil {
+			return nil, err
+		}
+		if names[profile.Name] {
+			return nil, fmt.Errorf("duplicate selector profile '%s'", profile.Name)
+		}
+		names[profile.Name] = true
+		set.profiles = append(set.profiles, profile)
+		for _, layout := range profile.TimestampLayouts {
+			if !seenLayouts[layout] {
+				set.layouts = append(set.layouts, layout)
+				seenLayouts[layout] = true
+			}
+		}
+	}
+	return set, nil
+}
+
+// LoadProfiles reads a selector profiles file: a JSON object with a "profiles" array in
+// detection order. profiles/magiccafe.json in this package is the built-in set and a starting point.
+func LoadProfiles(filePath string) (*ProfileSet, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read selector profiles file %s: %w", filePath, err)
+	}
+	set, err := parseProfiles(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load selector profiles file %s: %w", filePath, err)
+	}
+	return set, nil
+}
+
+func parseProfiles(content []byte) (*ProfileSet, error) {
+	var file profileFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal selector profiles: %w", err)
+	}
+	return NewProfileSet(file.Profiles)
+}
+
+// Profiles returns the profiles of the set in detection order.
+func (s *ProfileSet) Profiles() []Profile {
+	return append([]Profile(nil), s.profiles...)
+}
+
+// Names returns the profile names in detection order.
+func (s *ProfileSet) Names() []string {
+	names := make([]string, len(s.profiles))
+	for i, profile := range s.profiles {
+		names[i] = profile.Name
+	}
+	return names
+}
+
+// Fingerprint identifies the selectors and layouts of the set, in detection order, so output
+// extracted with other profiles can be told apart.
+func (s *ProfileSet) Fingerprint() string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%+v", s.profiles)
+	return "profiles-" + hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// Detect returns the first profile whose markers match the page. ok is false if none does,
+// in which case the first profile is returned.
+func (s *ProfileSet) Detect(page *goquery.Selection) (profile *Profile, ok bool) {
+	for i := range s.profiles {
+		if s.profiles[i].matchesPage(page) {
+			return &s.profiles[i], true
+		}
+	}
+	return &s.profiles[0], false
+}
+
+// detectPost returns the first profile whose post cells are found in a post block. Blocks are
+// often parsed on their own, without the page markers, so they are matched by structure.
+func (s *ProfileSet) detectPost(block *goquery.Selection) *Profile {
+	for i := range s.profiles {
+		if s.profiles[i].matchesPost(block) {
+			return &s.profiles[i]
+		}
+	}
+	return &s.profiles[0]
+}
+
+// detectQuote returns the first profile whose quote selector matches node, or nil.
+func (s *ProfileSet) detectQuote(node *goquery.Selection) *Profile {
+	for i := range s.profiles {
+		if node.Is(s.profiles[i].Selectors.Quote) {
+			return &s.profiles[i]
+		}
+	}
+	return nil
+}
+
+//go:embed profiles/magiccafe.json
+var builtinProfilesJSON []byte
+
+// builtinProfiles is the profile set used by a MagicCafe adapter without profiles of its own.
+var builtinProfiles = mustParseProfiles(builtinProfilesJSON)
+
+func mustParseProfiles(content []byte) *ProfileSet {
+	set, err := parseProfiles(content)
+	if err != nil {
+		panic(fmt.Sprintf("forumadapter: invalid built-in selector profiles: %v", err))
+	}
+	return set
+}
+
+// BuiltinProfiles returns the built-in Magic Cafe profile set.
+func BuiltinProfiles() *ProfileSet {
+	return builtinProfiles
+}
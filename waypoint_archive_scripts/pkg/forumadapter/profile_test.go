@@ -0,0 +1,177 @@
+package forumadapter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// legacyProfile describes a made-up older skin with one table per post and no post order marker.
+var legacyProfile = Profile{
+	Name:    "legacy",
+	Markers: []string{"table.forumline"},
+	Selectors: Selectors{
+		PostRow:          "table.forumline > tbody > tr.post",
+		AuthorCell:       "td.poster",
+		AuthorName:       "span.name",
+		ContentCell:      "td.body",
+		Timestamp:        "span.postdetails",
+		TimestampPrefix:  "Posted:",
+		PostID:           "a[name^=post]",
+		PostIDAttr:       "name",
+		PostIDPrefix:     "post",
+		Quote:            "table.quote",
+		QuoteAttribution: "td.quoteauthor",
+		QuoteUser:        "span",
+		ListingRow:       "table.forumline tr",
+		TopicLink:        "a.topictitle",
+	},
+	TimestampLayouts: []string{"Mon Jan 02, 2006 3:04 pm"},
+}
+
+const legacyTopicHTML = `<html><body><table class="forumline">
+<tr class="post">
+	<td class="poster"><span class="name">Ann</span></td>
+	<td class="body"><a name="post42"></a><span class="postdetails">Posted: Thu Jan 23, 2003 2:45 pm</span><div>Old skin post</div></td>
+</tr>
+</table></body></html>`
+
+// writeProfiles writes the built-in profiles followed by extra into a profiles file.
+func writeProfiles(t *testing.T, extra ...Profile) string {
+	t.Helper()
+	content, err := json.Marshal(profileFile{Profiles: append(BuiltinProfiles().Profiles(), extra...)})
+	if err != nil {
+		t.Fatalf("failed to marshal profiles: %v", err)
+	}
+	filePath := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		t.Fatalf("failed to write profiles file: %v", err)
+	}
+	return filePath
+}
+
+func TestBuiltinProfiles(t *testing.T) {
+	names := BuiltinProfiles().Names()
+	if len(names) == 0 || names[0] != "magiccafe-likes" {
+		t.Fatalf("unexpected built-in profiles: %v", names)
+	}
+
+	doc, err := ParseHTML(mcTopicHTML)
+	if err != nil {
+		t.Fatalf("ParseHTML returned error: %v", err)
+	}
+	if profile, ok := BuiltinProfiles().Detect(doc.Selection); !ok || profile.Name != "magiccafe-likes" {
+		t.Errorf("Detect = %s, %v; want magiccafe-likes, true", profile.Name, ok)
+	}
+}
+
+func TestProfileSet_Fingerprint(t *testing.T) {
+	builtin := BuiltinProfiles().Fingerprint()
+	if !strings.HasPrefix(builtin, "profiles-") || builtin != BuiltinProfiles().Fingerprint() {
+		t.Fatalf("Fingerprint() = %q, want a stable profiles- fingerprint", builtin)
+	}
+	withLegacy, err := LoadProfiles(writeProfiles(t, legacyProfile))
+	if err != nil {
+		t.Fatalf("LoadProfiles returned error: %v", err)
+	}
+	if withLegacy.Fingerprint() == builtin {
+		t.Error("Fingerprint() did not change with an added profile")
+	}
+	changed := legacyProfile
+	changed.Selectors.AuthorName = "span.author"
+	withChanged, err := LoadProfiles(writeProfiles(t, changed))
+	if err != nil {
+		t.Fatalf("LoadProfiles returned error: %v", err)
+	}
+	if withChanged.Fingerprint() == withLegacy.Fingerprint() {
+		t.Error("Fingerprint() did not change with a changed selector")
+	}
+}
+
+func TestMagicCafe_DetectsProfilePerPage(t *testing.T) {
+	adapter, err := NewMagicCafe(writeProfiles(t, legacyProfile))
+	if err != nil {
+		t.Fatalf("NewMagicCafe returned error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		pageHTML   string
+		wantID     string
+		wantAuthor string
+		wantOrder  int
+	}{
+		{"current skin", mcTopicHTML, "175716", "Bob", 0},
+		{"legacy skin", legacyTopicHTML, "42", "Ann", -1},
+	}
+	for _, tt := range tests {
+		doc, err := ParseHTML(tt.pageHTML)
+		if err != nil {
+			t.Fatalf("%s: ParseHTML returned error: %v", tt.name, err)
+		}
+		blocks := adapter.PostBlocks(doc)
+		if blocks.Length() == 0 {
+			t.Errorf("%s: no post blocks found", tt.name)
+			continue
+		}
+		metadata, err := adapter.PostMetadata(blocks.First())
+		if err != nil {
+			t.Errorf("%s: PostMetadata returned error: %v", tt.name, err)
+			continue
+		}
+		if metadata.PostID != tt.wantID || metadata.AuthorUsername != tt.wantAuthor || metadata.OrderOnPage != tt.wantOrder {
+			t.Errorf("%s: PostMetadata = %+v, want ID %s by %s at %d", tt.name, metadata, tt.wantID, tt.wantAuthor, tt.wantOrder)
+		}
+		if metadata.Timestamp.IsZero() {
+			t.Errorf("%s: timestamp %q was not parsed", tt.name, metadata.TimestampRaw)
+		}
+	}
+}
+
+func TestLoadProfiles_Invalid(t *testing.T) {
+	missing := legacyProfile
+	missing.Name = "missing"
+	missing.Selectors.ContentCell = ""
+
+	invalid := legacyProfile
+	invalid.Name = "invalid"
+	invalid.Markers = []string{"table[class="}
+
+	tests := []struct {
+		name    string
+		profile Profile
+		wantErr string
+	}{
+		{"missing selector", missing, "selector contentCell is required"},
+		{"invalid selector", invalid, "invalid selector 'table[class='"},
+		{"duplicate name", BuiltinProfiles().Profiles()[0], "duplicate selector profile"},
+	}
+	for _, tt := range tests {
+		_, err := LoadProfiles(writeProfiles(t, tt.profile))
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: LoadProfiles error = %v, want it to contain %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if _, err := LoadProfiles(filepath.Join(t.TempDir(), "absent.json")); err == nil {
+		t.Error("LoadProfiles expected error for a missing file, got nil")
+	}
+}
+
+func TestWithProfilesFile(t *testing.T) {
+	filePath := writeProfiles(t, legacyProfile)
+
+	adapter, err := WithProfilesFile(MagicCafe{}, filePath)
+	if err != nil {
+		t.Fatalf("WithProfilesFile returned error: %v", err)
+	}
+	if got := adapter.(MagicCafe).Profiles.Names(); len(got) != 2 || got[1] != "legacy" {
+		t.Errorf("loaded profiles = %v, want the built-in profile and legacy", got)
+	}
+
+	if _, err := WithProfilesFile(PhpBB3{}, filePath); err == nil {
+		t.Error("WithProfilesFile expected error for the phpbb3 adapter, got nil")
+	}
+}
@@ -7,12 +7,15 @@ import (
 	"os"
 
 	"github.com/PuerkitoBio/goquery"
+
+	"waypoint_archive_scripts/pkg/forumadapter"
 )
 
 // HTMLPage represents a loaded HTML page from the archive
 type HTMLPage struct {
 	FilePath string
 	Content  *goquery.Document
+	Adapter  forumadapter.ForumAdapter // Forum engine the page was archived from; forumadapter.Default() if nil
 }
 
 // PostBlock represents an individual post identified on a page.
@@ -50,33 +53,25 @@ func LoadHTMLPage(filePath string) (*HTMLPage, error) {
 	}, nil
 }
 
-// GetPostBlocks identifies and returns the HTML blocks containing individual posts
-// Each block is a goquery.Selection representing the <tr> element of a post.
+// GetPostBlocks identifies and returns the HTML blocks containing individual posts.
+// Which elements are post blocks is up to the forum adapter, e.g. the <tr> of each post on Magic Cafe.
 func (p *HTMLPage) GetPostBlocks() ([]PostBlock, error) {
 	var blocks []PostBlock
-
-	// Find all tables with class "normal" that are direct children of "div#container".
-	// Then, select the second one (index 1), which is expected to be the posts table.
-	postsTable := p.Content.Find("body > div#container > table.normal").Eq(1)
-
-	if postsTable.Length() == 0 {
-		// This means the second table.normal was not found. Could be an unexpected page structure.
-		// Return empty blocks, let caller decide if it's an error.
-		return blocks, nil
-	}
-
-	// Now, within this specific table, find the <tr> elements that are actual posts.
-	// These <tr> elements must contain the characteristic <td> cells for user info and post content.
-	postRowSelector := "tr:has(td.normal.bgc1.c.w13.vat):has(td.normal.bgc1.vat.w90)"
-
-	postsTable.Find(postRowSelector).Each(func(i int, s *goquery.Selection) {
+	p.adapter().PostBlocks(p.Content).Each(func(i int, s *goquery.Selection) {
 		blocks = append(blocks, PostBlock{Selection: s})
 	})
 
-	// AC5: Handle pages with multiple posts (covered by Find().Each())
+	// AC5: Handle pages with multiple posts (covered by Each())
 	// AC6: Provide access to isolated HTML (PostBlock.Selection provides this)
 
 	// If no blocks were found, it might not be an error, but could be an empty page or different structure.
 	// The calling code can decide how to handle zero blocks based on context (e.g., log it as per AC7).
 	return blocks, nil
 }
+
+func (p *HTMLPage) adapter() forumadapter.ForumAdapter {
+	if p.Adapter != nil {
+		return p.Adapter
+	}
+	return forumadapter.Default()
+}
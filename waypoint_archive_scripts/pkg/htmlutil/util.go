@@ -6,14 +6,13 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"net/url"
-	"strings"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"golang.org/x/net/html/charset"
 
 	"waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/forumadapter"
+	"waypoint_archive_scripts/pkg/respcache"
 )
 
 // FetchHTMLer defines the interface for fetching HTML content.
@@ -35,7 +34,9 @@ type ExtractTopicser interface {
 type DefaultHTMLUtil struct {
 	UserAgent       string
 	PolitenessDelay time.Duration
-	ForumBaseURL    string // Used by pagination and topic extraction to resolve relative URLs
+	ForumBaseURL    string                    // Used by pagination and topic extraction to resolve relative URLs
+	Cache           *respcache.Cache          // Optional shared response cache consulted by FetchHTML
+	Adapter         forumadapter.ForumAdapter // Forum engine used for pagination and topic extraction; forumadapter.Default() if nil
 }
 
 // NewHTMLUtil is a constructor for DefaultHTMLUtil.
@@ -52,27 +53,28 @@ func NewHTMLUtil(userAgent string, politenessDelay time.Duration, forumBaseURL s
 // FetchHTML implements the FetchHTMLer interface.
 func (h *DefaultHTMLUtil) FetchHTML(pageURL string) (string, error) {
 	// Call the original standalone FetchHTML function, passing configured values.
-	return FetchHTML(pageURL, h.PolitenessDelay, h.UserAgent)
+	// Pages already in the response cache are returned without a request or politeness delay.
+	return h.Cache.Fetch(pageURL, func() (string, error) {
+		return FetchHTML(pageURL, h.PolitenessDelay, h.UserAgent)
+	})
 }
 
 // ParsePaginationLinks implements the ParsePaginationLinker interface.
+// basePageURL is used to resolve relative links and is assumed to be absolute.
 func (h *DefaultHTMLUtil) ParsePaginationLinks(htmlContent string, basePageURL string) ([]string, error) {
-	// The ForumBaseURL from the struct h might be more reliable if basePageURL is not absolute or incorrect.
-	// For now, directly using the original ParsePaginationLinks logic which uses basePageURL for resolution.
-	// If h.ForumBaseURL is intended to be the definitive base, the standalone ParsePaginationLinks function might need adjustment
-	// or this wrapper could try to use h.ForumBaseURL to create a more reliable basePageURL if the provided one is relative.
-	// For now, assume basePageURL is the one to use and is correctly formed by the caller.
-	return ParsePaginationLinks(htmlContent, basePageURL) // Calling the standalone version
+	return parsePaginationLinks(h.adapter(), htmlContent, basePageURL)
 }
 
 // ExtractTopics implements the ExtractTopicser interface.
 func (h *DefaultHTMLUtil) ExtractTopics(htmlContent string, pageURL string, subForumID string) ([]data.Topic, error) {
-	// Similar to ParsePaginationLinks, this calls the standalone function.
-	// If h.ForumBaseURL is relevant for resolving URLs inside topic extraction, the standalone
-	// ExtractTopicsFromHTMLInUtil should be made aware of it (e.g. by passing h.ForumBaseURL to it if it were modified to accept it)
-	// or this wrapper could pre-process URLs if necessary.
-	// For now, calling the existing standalone function directly.
-	return ExtractTopicsFromHTMLInUtil(htmlContent, pageURL, subForumID) // Calling the standalone version
+	return extractTopics(h.adapter(), htmlContent, pageURL, subForumID)
+}
+
+func (h *DefaultHTMLUtil) adapter() forumadapter.ForumAdapter {
+	if h.Adapter != nil {
+		return h.Adapter
+	}
+	return forumadapter.Default()
 }
 
 // NewHTMLFetcher is a constructor for a FetchHTMLer.
@@ -84,6 +86,16 @@ func NewHTMLFetcher(userAgent string, politenessDelay time.Duration) FetchHTMLer
 	}
 }
 
+// NewCachedHTMLFetcher is like NewHTMLFetcher but serves and stores pages through cache.
+// A nil cache behaves like NewHTMLFetcher.
+func NewCachedHTMLFetcher(userAgent string, politenessDelay time.Duration, cache *respcache.Cache) FetchHTMLer {
+	return &DefaultHTMLUtil{
+		UserAgent:       userAgent,
+		PolitenessDelay: politenessDelay,
+		Cache:           cache,
+	}
+}
+
 // NewPaginationParser is a constructor for a ParsePaginationLinker.
 func NewPaginationParser(forumBaseURL string) ParsePaginationLinker {
 	return &DefaultHTMLUtil{
@@ -164,164 +176,51 @@ func FetchHTML(pageURL string, delay time.Duration, userAgent string) (string, e
 }
 
 // ParsePaginationLinks extracts all unique pagination links from HTML content.
-// It assumes pagination links are within a common structure (e.g., div.pagination a).
+// The links are found and formatted by the process-wide forum adapter.
 // THIS IS THE ORIGINAL STANDALONE FUNCTION.
 func ParsePaginationLinks(pageHTML string, basePageURL string) ([]string, error) {
-	// log.Printf("[DEBUG_HTML] ParsePaginationLinks: Received HTML for %s:\n%s\n[END_DEBUG_HTML]", basePageURL, pageHTML) // HTML dump removed
+	return parsePaginationLinks(forumadapter.Default(), pageHTML, basePageURL)
+}
 
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
+func parsePaginationLinks(adapter forumadapter.ForumAdapter, pageHTML string, basePageURL string) ([]string, error) {
+	doc, err := forumadapter.ParseHTML(pageHTML)
 	if err != nil {
-		return nil, fmt.Errorf("ParsePaginationLinks: failed to create goquery document for %s: %w", basePageURL, err)
+		return nil, fmt.Errorf("ParsePaginationLinks: failed to parse page %s: %w", basePageURL, err)
 	}
-
-	parsedPageURL, err := url.Parse(basePageURL)
+	links, err := adapter.PaginationLinks(doc, basePageURL)
 	if err != nil {
-		return nil, fmt.Errorf("ParsePaginationLinks: failed to parse page URL %s: %w", basePageURL, err)
+		return nil, fmt.Errorf("ParsePaginationLinks: %w", err)
 	}
 
-	var links []string
-	seenLinks := make(map[string]bool)
-
-	// Common pagination selectors - this might need to be made more configurable or robust
-	// Based on the previous JIT refresh logic, it was looking for "div.pagination a"
-	// Added new selector for TheMagicCafe structure: "td.normal.bgc2.b.midtext a[href]"
-	// Attempting a slightly broader selector: td[class*="midtext"] a[href]
-	// Adding a very specific diagnostic selector for topic 42460 - THIS SHOULD BE REMOVED AFTER DIAGNOSIS
-	doc.Find("div.pagination a[href], .pagmenu a[href], .page-nav a[href], .nav-links a[href], td[class*=\"midtext\"] a[href]").Each(func(i int, s *goquery.Selection) {
-		// Note: The specific selector for topic 42460 (a[href^=\"viewtopic.php?topic=42460\"]) should be removed after this diagnostic phase.
-		href, exists := s.Attr("href")
-		if !exists || href == "" || href == "#" || strings.HasPrefix(strings.ToLower(href), "javascript:") {
-			return
-		}
-
-		absURL, err := parsedPageURL.Parse(href)
-		if err != nil {
-			log.Printf("[WARNING] ParsePaginationLinks: Error parsing pagination link '%s' on page %s: %v", href, basePageURL, err)
-			return
-		}
-		absLinkStr := absURL.String()
-
-		// Normalize: ensure 'forum' parameter is present if 'topic' is, using forum from basePageURL
-		tempURL, _ := url.Parse(absLinkStr)
-		queryParams := tempURL.Query()
-		if queryParams.Get("topic") != "" && queryParams.Get("forum") == "" {
-			baseQueryParams := parsedPageURL.Query()
-			if baseForumID := baseQueryParams.Get("forum"); baseForumID != "" {
-				queryParams.Set("forum", baseForumID)
-				tempURL.RawQuery = queryParams.Encode()
-				absLinkStr = tempURL.String()
-				log.Printf("[DEBUG] ParsePaginationLinks: Normalized URL to %s", absLinkStr)
-			}
-		}
-
-		if !seenLinks[absLinkStr] {
-			links = append(links, absLinkStr)
-			seenLinks[absLinkStr] = true
-		}
-	})
-
 	if len(links) == 0 {
-		log.Printf("[DEBUG] ParsePaginationLinks: No pagination links found on %s using common selectors.", basePageURL)
+		log.Printf("[DEBUG] ParsePaginationLinks: No pagination links found on %s using %s selectors.", basePageURL, adapter.Name())
 	} else {
 		log.Printf("[DEBUG] ParsePaginationLinks: Found %d unique pagination links on %s.", len(links), basePageURL)
 	}
-
 	return links, nil
 }
 
-// ExtractTopicsFromHTMLInUtil parses the HTML content of a sub-forum page and extracts topics.
-// This is adapted from internal/indexer/topic/ExtractTopics.
+// ExtractTopicsFromHTMLInUtil parses the HTML content of a sub-forum page and extracts topics
+// using the process-wide forum adapter.
 // THIS IS THE ORIGINAL STANDALONE FUNCTION.
 func ExtractTopicsFromHTMLInUtil(htmlContent string, pageURL string, subForumID string) ([]data.Topic, error) {
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	return extractTopics(forumadapter.Default(), htmlContent, pageURL, subForumID)
+}
+
+func extractTopics(adapter forumadapter.ForumAdapter, htmlContent string, pageURL string, subForumID string) ([]data.Topic, error) {
+	doc, err := forumadapter.ParseHTML(htmlContent)
 	if err != nil {
-		return nil, fmt.Errorf("ExtractTopicsFromHTMLInUtil: failed to create goquery document for %s: %w", pageURL, err)
+		return nil, fmt.Errorf("ExtractTopicsFromHTMLInUtil: failed to parse page %s: %w", pageURL, err)
 	}
-
-	parsedPageURL, err := url.Parse(pageURL)
+	// On-page duplicates are not removed here: JIT refresh de-duplicates at a higher level
+	// (against the existing index and across multiple JIT pages).
+	topics, err := adapter.ExtractTopics(doc, pageURL, subForumID)
 	if err != nil {
-		return nil, fmt.Errorf("ExtractTopicsFromHTMLInUtil: failed to parse page URL %s: %w", pageURL, err)
+		return nil, fmt.Errorf("ExtractTopicsFromHTMLInUtil: %w", err)
 	}
 
-	var topics []data.Topic = make([]data.Topic, 0)
-	// Note: The original ExtractTopics had a seenTopicIDs map for on-page de-duplication.
-	// This is removed here as JIT refresh logic handles de-duplication at a higher level
-	// (against existing index and across multiple JIT pages).
-	// If on-page duplicates are possible from the source HTML structure, it could be re-added.
-
-	// Selector based on the provided internal/indexer/topic/topic.go
-	// "table.normal tr" and then "td.normal.bgc2 > a.b[href*='viewtopic.php']"
-	doc.Find("table.normal tr").Each(func(i int, tr *goquery.Selection) {
-		tr.Find("td.normal.bgc2 > a.b[href*='viewtopic.php'], a.topic-title[href*='viewtopic.php'], a[href*='viewtopic.php'][title*='Topic:']").Each(func(j int, link *goquery.Selection) {
-			href, exists := link.Attr("href")
-			if !exists {
-				return
-			}
-
-			topicTitle := strings.TrimSpace(link.Text())
-			if topicTitle == "" {
-				// Attempt to get title from a 'title' attribute if text is empty
-				titleAttr, titleAttrExists := link.Attr("title")
-				if titleAttrExists {
-					topicTitle = strings.TrimSpace(strings.TrimPrefix(titleAttr, "Topic:")) // Example for "Topic: Actual Title"
-				}
-				if topicTitle == "" {
-					return // Still no title, skip
-				}
-			}
-
-			topicAbsURL, err := parsedPageURL.Parse(href)
-			if err != nil {
-				log.Printf("[WARNING] ExtractTopicsFromHTMLInUtil: Error parsing topic URL '%s' on page %s: %v. Skipping topic.", href, pageURL, err)
-				return
-			}
-
-			u, err := url.Parse(topicAbsURL.String())
-			if err != nil {
-				log.Printf("[WARNING] ExtractTopicsFromHTMLInUtil: Error parsing absolute topic URL '%s' on page %s: %v. Skipping topic.", topicAbsURL.String(), pageURL, err)
-				return
-			}
-
-			// Try to get topicID from query param "t", "topic", or "p" (post ID, sometimes links to post in topic)
-			topicID := u.Query().Get("t")
-			if topicID == "" {
-				topicID = u.Query().Get("topic")
-			}
-			if topicID == "" {
-				// If it's a link to a post, the post ID might be "p"
-				postID := u.Query().Get("p")
-				if postID != "" {
-					// This is a simplification; real mapping from postID to topicID might be complex
-					// or require another fetch. For now, use "p" + postID as a proxy if "t" is missing.
-					// Or, one might decide to skip topics only identified by post ID if a direct topic ID is required.
-					// topicID = "p" + postID
-					// For JIT, we need the actual topic ID that matches the one in the index.
-					// If "t" or "topic" query param is missing, we likely cannot reliably get the topic ID.
-					log.Printf("[WARNING] ExtractTopicsFromHTMLInUtil: Topic ID (t or topic param) not found for URL '%s' with title '%s' on page %s. Skipping topic.", topicAbsURL.String(), topicTitle, pageURL)
-					return
-				} else {
-					log.Printf("[WARNING] ExtractTopicsFromHTMLInUtil: Topic ID (t or topic param) not found for URL '%s' with title '%s' on page %s. Skipping topic.", topicAbsURL.String(), topicTitle, pageURL)
-					return
-				}
-			}
-
-			// Basic data.Topic population. Other fields (Replies, Views, etc.) are not available
-			// from this basic extraction and would remain zero/empty. JIT is primarily for discovering *new* topic IDs.
-			topicData := data.Topic{
-				ID:         topicID,
-				SubForumID: subForumID,
-				Title:      topicTitle,
-				URL:        topicAbsURL.String(),
-				// Other fields like AuthorUsername, Replies, Views, LastPostUsername, LastPostTimestampRaw, IsSticky, IsLocked
-				// are not typically available on the sub-forum topic listing page directly for each topic in a simple link.
-				// These would be filled if we fetched each topic page, but JIT refresh usually aims to be lightweight.
-			}
-			topics = append(topics, topicData)
-		})
-	})
-
 	if len(topics) == 0 {
-		log.Printf("[DEBUG] ExtractTopicsFromHTMLInUtil: No topics extracted from page %s using selectors. This might be an empty page, selector mismatch, or all topics lacked valid IDs.", pageURL)
+		log.Printf("[DEBUG] ExtractTopicsFromHTMLInUtil: No topics extracted from page %s using %s selectors. This might be an empty page, selector mismatch, or all topics lacked valid IDs.", pageURL, adapter.Name())
 	}
 	return topics, nil
 }
@@ -7,7 +7,10 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 
 	"waypoint_archive_scripts/pkg/data" // Assuming waypoint_archive_scripts is the module name
 )
@@ -223,3 +226,28 @@ func ReadSubForumListJSON(filePath string) ([]data.SubForum, error) {
 	log.Printf("[INFO] ReadSubForumListJSON: successfully read %d subforum entries from %s", len(subForums), filePath)
 	return subForums, nil
 }
+
+// ReadSubForumList reads a sub-forum list: JSON if the file ends in .json, otherwise the CSV
+// written by generate_subforum_list. CSV entries are returned in ID order.
+func ReadSubForumList(filePath string) ([]data.SubForum, error) {
+	if strings.EqualFold(filepath.Ext(filePath), ".json") {
+		return ReadSubForumListJSON(filePath)
+	}
+	byID, err := ReadSubForumListCSV(filePath)
+	if err != nil {
+		return nil, err
+	}
+	subForums := make([]data.SubForum, 0, len(byID))
+	for id, subForum := range byID {
+		subForums = append(subForums, data.SubForum{ID: id, Name: subForum.Name, URL: subForum.URL})
+	}
+	sort.Slice(subForums, func(i, j int) bool {
+		a, errA := strconv.Atoi(subForums[i].ID)
+		b, errB := strconv.Atoi(subForums[j].ID)
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return subForums[i].ID < subForums[j].ID
+	})
+	return subForums, nil
+}
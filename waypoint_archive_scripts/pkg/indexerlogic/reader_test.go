@@ -91,6 +91,43 @@ sf2,SubForum Two,http://example.com/sf2
 	}
 }
 
+func TestReadSubForumList_CSVAndJSON(t *testing.T) {
+	tempDir := t.TempDir()
+	csvPath := filepath.Join(tempDir, "subforum_list.csv")
+	csvContent := `sub_forum_id,sub_forum_name,base_url,description,topics_count,posts_count,last_active_datetime_str,last_active_by,last_post_id
+66,Close-up,https://www.themagiccafe.com/forums/viewforum.php?forum=66,,10,20,,,
+7,Books,https://www.themagiccafe.com/forums/viewforum.php?forum=7,,1,2,,,
+`
+	if err := os.WriteFile(csvPath, []byte(csvContent), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	jsonPath := filepath.Join(tempDir, "subforum_list.json")
+	jsonContent := `[{"sub_forum_id": "66", "sub_forum_name": "Close-up", "base_url": "https://www.themagiccafe.com/forums/viewforum.php?forum=66", "topics_count": 10}]`
+	if err := os.WriteFile(jsonPath, []byte(jsonContent), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	got, err := ReadSubForumList(csvPath)
+	if err != nil {
+		t.Fatalf("ReadSubForumList(csv) error = %v", err)
+	}
+	want := []data.SubForum{
+		{ID: "7", Name: "Books", URL: "https://www.themagiccafe.com/forums/viewforum.php?forum=7"},
+		{ID: "66", Name: "Close-up", URL: "https://www.themagiccafe.com/forums/viewforum.php?forum=66"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadSubForumList(csv) got = %v, want %v", got, want)
+	}
+
+	got, err = ReadSubForumList(jsonPath)
+	if err != nil {
+		t.Fatalf("ReadSubForumList(json) error = %v", err)
+	}
+	if len(got) != 1 || got[0].ID != "66" || got[0].TopicCount != 10 {
+		t.Errorf("ReadSubForumList(json) got = %v, want sub-forum 66 with 10 topics", got)
+	}
+}
+
 func TestReadTopicIndexCSV_VariedColumns(t *testing.T) {
 	// This test covers cases where optional columns might be missing or empty
 	// and ensures parsing is still robust.
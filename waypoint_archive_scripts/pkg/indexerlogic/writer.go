@@ -0,0 +1,86 @@
+package indexerlogic
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"waypoint_archive_scripts/pkg/data"
+)
+
+// DiscoveredViaJITRefresh marks topic index entries that were added by a JIT refresh.
+const DiscoveredViaJITRefresh = "jit_refresh"
+
+// MergeDiscoveredTopicsJSON adds topics that are not yet present to a topic index JSON file.
+// Existing entries are kept byte-for-byte (apart from indentation) so fields written by the
+// indexer are not lost. New entries are stamped with discoveredVia and discoveredAt.
+// The file is replaced atomically via a temporary file. It returns the number of topics added.
+func MergeDiscoveredTopicsJSON(filePath string, topics []data.Topic, discoveredVia string, discoveredAt time.Time) (int, error) {
+	var entries []json.RawMessage
+	existing, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("MergeDiscoveredTopicsJSON: failed to read %s: %w", filePath, err)
+	}
+	if len(existing) > 0 {
+		if err := json.Unmarshal(existing, &entries); err != nil {
+			return 0, fmt.Errorf("MergeDiscoveredTopicsJSON: failed to unmarshal JSON from %s: %w", filePath, err)
+		}
+	}
+
+	existingIDs := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		var idOnly struct{ ID string }
+		if err := json.Unmarshal(entry, &idOnly); err == nil && idOnly.ID != "" {
+			existingIDs[idOnly.ID] = struct{}{}
+		}
+	}
+
+	added := 0
+	for _, topic := range topics {
+		if _, ok := existingIDs[topic.ID]; ok || topic.ID == "" {
+			continue
+		}
+		topic.DiscoveredVia = discoveredVia
+		topic.DiscoveredAt = discoveredAt.UTC().Format(time.RFC3339)
+		entry, err := json.Marshal(topic)
+		if err != nil {
+			return 0, fmt.Errorf("MergeDiscoveredTopicsJSON: failed to marshal topic %s: %w", topic.ID, err)
+		}
+		entries = append(entries, entry)
+		existingIDs[topic.ID] = struct{}{}
+		added++
+	}
+
+	if added == 0 {
+		return 0, nil
+	}
+
+	if entries == nil {
+		entries = []json.RawMessage{}
+	}
+	merged, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("MergeDiscoveredTopicsJSON: failed to marshal merged index for %s: %w", filePath, err)
+	}
+
+	dir := filepath.Dir(filePath)
+	if dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return 0, fmt.Errorf("MergeDiscoveredTopicsJSON: failed to create directory %s: %w", dir, err)
+		}
+	}
+
+	tempFilePath := filePath + ".tmp"
+	if err := os.WriteFile(tempFilePath, merged, 0644); err != nil {
+		return 0, fmt.Errorf("MergeDiscoveredTopicsJSON: failed to write temporary file %s: %w", tempFilePath, err)
+	}
+	if err := os.Rename(tempFilePath, filePath); err != nil {
+		return 0, fmt.Errorf("MergeDiscoveredTopicsJSON: failed to rename %s to %s: %w", tempFilePath, filePath, err)
+	}
+
+	log.Printf("[INFO] MergeDiscoveredTopicsJSON: added %d topics (via %s) to %s", added, discoveredVia, filePath)
+	return added, nil
+}
@@ -0,0 +1,95 @@
+package indexerlogic
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"waypoint_archive_scripts/pkg/data"
+)
+
+func TestMergeDiscoveredTopicsJSON_AppendsNewTopics(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "forum_23", "topic_index_23.json")
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		t.Fatalf("Failed to create test dir: %v", err)
+	}
+	original := `[
+  {
+    "ID": "100164",
+    "Title": "Existing",
+    "URL": "https://www.themagiccafe.com/forums/viewtopic.php?topic=100164&forum=23"
+  }
+]`
+	if err := os.WriteFile(filePath, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	discoveredAt := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+	added, err := MergeDiscoveredTopicsJSON(filePath, []data.Topic{
+		{ID: "100164", Title: "Existing (duplicate)"},
+		{ID: "200001", Title: "Brand New", URL: "https://www.themagiccafe.com/forums/viewtopic.php?topic=200001&forum=23", Replies: 3},
+	}, DiscoveredViaJITRefresh, discoveredAt)
+	if err != nil {
+		t.Fatalf("MergeDiscoveredTopicsJSON() error = %v", err)
+	}
+	if added != 1 {
+		t.Errorf("MergeDiscoveredTopicsJSON() added = %d, want 1", added)
+	}
+
+	topics, err := ReadTopicIndexJSON(filePath, "23")
+	if err != nil {
+		t.Fatalf("ReadTopicIndexJSON() error = %v", err)
+	}
+	if len(topics) != 2 {
+		t.Fatalf("Expected 2 topics after merge, got %d: %#v", len(topics), topics)
+	}
+	if topics[0].Title != "Existing" || topics[0].DiscoveredVia != "" {
+		t.Errorf("Existing entry changed: %#v", topics[0])
+	}
+	if topics[1].ID != "200001" || topics[1].Replies != 3 {
+		t.Errorf("New entry not appended correctly: %#v", topics[1])
+	}
+	if topics[1].DiscoveredVia != DiscoveredViaJITRefresh || topics[1].DiscoveredAt != "2024-02-03T04:05:06Z" {
+		t.Errorf("New entry discovery markers = %q/%q, want %q/%q", topics[1].DiscoveredVia, topics[1].DiscoveredAt, DiscoveredViaJITRefresh, "2024-02-03T04:05:06Z")
+	}
+
+	raw, _ := os.ReadFile(filePath)
+	if !strings.Contains(string(raw), `"discovered_via": "jit_refresh"`) {
+		t.Errorf("Merged file missing discovered_via marker:\n%s", raw)
+	}
+	if _, err := os.Stat(filePath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Temporary file was left behind: %v", err)
+	}
+}
+
+func TestMergeDiscoveredTopicsJSON_NoChangesLeavesFileAlone(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "topic_index_5.json")
+	original := `[{"ID":"1","Title":"One"}]`
+	if err := os.WriteFile(filePath, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	added, err := MergeDiscoveredTopicsJSON(filePath, []data.Topic{{ID: "1", Title: "One"}}, DiscoveredViaJITRefresh, time.Now())
+	if err != nil || added != 0 {
+		t.Fatalf("MergeDiscoveredTopicsJSON() = %d, %v; want 0, nil", added, err)
+	}
+	raw, _ := os.ReadFile(filePath)
+	if string(raw) != original {
+		t.Errorf("File was rewritten although nothing was added:\n%s", raw)
+	}
+}
+
+func TestMergeDiscoveredTopicsJSON_CreatesMissingFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "forum_9", "topic_index_9.json")
+	added, err := MergeDiscoveredTopicsJSON(filePath, []data.Topic{{ID: "9001", Title: "First"}}, DiscoveredViaJITRefresh, time.Now())
+	if err != nil || added != 1 {
+		t.Fatalf("MergeDiscoveredTopicsJSON() = %d, %v; want 1, nil", added, err)
+	}
+	topics, err := ReadTopicIndexJSON(filePath, "9")
+	if err != nil || len(topics) != 1 {
+		t.Fatalf("ReadTopicIndexJSON() = %#v, %v; want one topic", topics, err)
+	}
+}
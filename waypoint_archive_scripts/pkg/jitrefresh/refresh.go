@@ -11,8 +11,9 @@ import (
 	"waypoint_archive_scripts/pkg/config"
 	"waypoint_archive_scripts/pkg/data"
 	"waypoint_archive_scripts/pkg/htmlutil" // New import
-	"waypoint_archive_scripts/pkg/state"    // For ShouldPerformJITRefresh
-	// New import
+	"waypoint_archive_scripts/pkg/pageplan"
+	forumparser "waypoint_archive_scripts/pkg/parser" // parser is the name of the pagination parameter below
+	"waypoint_archive_scripts/pkg/state"              // For ShouldPerformJITRefresh
 )
 
 // FetchHTMLer defines the signature for a function that fetches HTML content.
@@ -69,30 +70,65 @@ func ShouldPerformJITRefresh(
 	return false
 }
 
-// PerformJITRefresh fetches the first few pages of a sub-forum, parses live topic IDs,
-// compares them against the loaded index, and returns newly discovered topics.
+// BumpedTopic describes an already-indexed topic that received new activity since it was indexed.
+type BumpedTopic struct {
+	Live              data.Topic // Topic as seen on the live listing page (current reply count and last post)
+	Indexed           data.Topic // Topic as recorded in the loaded index
+	NewReplies        int        // Live replies minus indexed replies (may be 0 if only the last-post time changed)
+	PageDelta         int        // Number of topic pages added since the topic was indexed
+	FirstAffectedPage int        // 1-based page from which the archived copy is out of date
+	LivePageCount     int        // Total number of topic pages according to the live reply count
+}
+
+// AffectedPages returns the 1-based page numbers that need to be (re-)archived for a bumped topic.
+func (b BumpedTopic) AffectedPages() []int {
+	pages := make([]int, 0, b.LivePageCount-b.FirstAffectedPage+1)
+	for p := b.FirstAffectedPage; p <= b.LivePageCount; p++ {
+		pages = append(pages, p)
+	}
+	return pages
+}
+
+// RefreshResult is the structured outcome of a JIT refresh of one sub-forum.
+type RefreshResult struct {
+	SubForumID     string
+	ScannedPages   int
+	NewTopics      []data.Topic  // Live topics missing from the loaded index
+	BumpedTopics   []BumpedTopic // Indexed topics with new replies or a newer last post
+	VanishedTopics []data.Topic  // Indexed topics that should appear on the scanned pages but do not
+}
+
+// PerformJITRefresh fetches the first few pages of a sub-forum, parses the live topic listing,
+// and compares it against the loaded index. It reports new topics, bumped topics (with the
+// pages that changed) and indexed topics that have vanished from the scanned pages.
 // It now uses interfaces from the htmlutil package.
 func PerformJITRefresh(
 	subForumData data.SubForum,
-	cfg *config.Config, // cfg is used for PolitenessDelay, UserAgent, JITRefreshPages
+	cfg *config.Config, // cfg is used for PolitenessDelay, UserAgent, JITRefreshPages, PostsPerPage
 	fetcher htmlutil.FetchHTMLer,
 	parser htmlutil.ParsePaginationLinker,
 	extractor htmlutil.ExtractTopicser,
-) ([]data.Topic, error) {
+) (*RefreshResult, error) {
+	result := &RefreshResult{
+		SubForumID:     subForumData.ID,
+		NewTopics:      []data.Topic{},
+		BumpedTopics:   []BumpedTopic{},
+		VanishedTopics: []data.Topic{},
+	}
 
 	log.Printf("[INFO] JIT REFRESH: Starting for SubForum: %s (ID: %s, URL: %s), JITRefreshPages: %d", // Changed %d to %s for subForumData.ID
 		subForumData.Name, subForumData.ID, subForumData.URL, cfg.JITRefreshPages)
 
 	if subForumData.URL == "" {
 		log.Printf("[WARNING] JIT REFRESH: SubForum %s (ID: %s) has no URL. Skipping JIT refresh.", subForumData.Name, subForumData.ID) // Changed %d to %s for subForumData.ID
-		return []data.Topic{}, nil
+		return result, nil
 	}
 
 	if cfg.JITRefreshPages <= 0 {
 		// This check is technically redundant if ShouldPerformJITRefresh is called first,
 		// but good for robustness if PerformJITRefresh is called directly.
 		log.Printf("[INFO] JIT REFRESH: JITRefreshPages is %d for SubForum %s. Skipping JIT scan.", cfg.JITRefreshPages, subForumData.ID) // Changed %d to %s for subForumData.ID
-		return []data.Topic{}, nil
+		return result, nil
 	}
 
 	var allLiveTopics []data.Topic
@@ -164,24 +200,100 @@ func PerformJITRefresh(
 		}
 	}
 
-	existingTopicIDs := make(map[string]struct{})
+	existingTopics := make(map[string]data.Topic)
 	for _, existingTopic := range subForumData.Topics {
-		existingTopicIDs[existingTopic.ID] = struct{}{}
+		existingTopics[existingTopic.ID] = existingTopic
 	}
 
-	newTopics := []data.Topic{}
-	seenNewTopicIDs := make(map[string]struct{}) // To de-duplicate topics found across JIT scanned pages
+	seenLiveTopicIDs := make(map[string]struct{}) // To de-duplicate topics found across JIT scanned pages
+	var oldestLiveActivity time.Time              // Oldest last-post time among scanned non-sticky topics
 
 	for _, liveTopic := range allLiveTopics {
-		if _, existsInOriginal := existingTopicIDs[liveTopic.ID]; !existsInOriginal {
-			if _, existsInNew := seenNewTopicIDs[liveTopic.ID]; !existsInNew {
-				newTopics = append(newTopics, liveTopic)
-				seenNewTopicIDs[liveTopic.ID] = struct{}{}
-				log.Printf("[INFO] JIT REFRESH: Discovered new topic for %s: ID %s, Title: %s", subForumData.ID, liveTopic.ID, liveTopic.Title) // Changed %d to %s for subForumData.ID
+		if _, seen := seenLiveTopicIDs[liveTopic.ID]; seen {
+			continue
+		}
+		seenLiveTopicIDs[liveTopic.ID] = struct{}{}
+
+		if !liveTopic.IsSticky {
+			if lastPost, err := forumparser.ParseForumTimestamp(liveTopic.LastPostTimestampRaw); err == nil {
+				if oldestLiveActivity.IsZero() || lastPost.Before(oldestLiveActivity) {
+					oldestLiveActivity = lastPost
+				}
+			}
+		}
+
+		indexedTopic, existsInOriginal := existingTopics[liveTopic.ID]
+		if !existsInOriginal {
+			result.NewTopics = append(result.NewTopics, liveTopic)
+			log.Printf("[INFO] JIT REFRESH: Discovered new topic for %s: ID %s, Title: %s", subForumData.ID, liveTopic.ID, liveTopic.Title) // Changed %d to %s for subForumData.ID
+			continue
+		}
+
+		if bumped, ok := detectBump(indexedTopic, liveTopic, cfg.PostsPerPage); ok {
+			result.BumpedTopics = append(result.BumpedTopics, bumped)
+			log.Printf("[INFO] JIT REFRESH: Detected bumped topic for %s: ID %s, Replies %d -> %d, PageDelta %d, FirstAffectedPage %d", subForumData.ID, liveTopic.ID, indexedTopic.Replies, liveTopic.Replies, bumped.PageDelta, bumped.FirstAffectedPage)
+		}
+	}
+
+	// A topic whose indexed last post is at least as recent as the oldest activity on the scanned
+	// pages must be listed on those pages (the listing is ordered by last post). If it is missing,
+	// it has been moved, merged or deleted since it was indexed.
+	if !oldestLiveActivity.IsZero() {
+		for _, indexedTopic := range subForumData.Topics {
+			if indexedTopic.IsSticky {
+				continue
 			}
+			if _, seen := seenLiveTopicIDs[indexedTopic.ID]; seen {
+				continue
+			}
+			indexedLastPost, err := forumparser.ParseForumTimestamp(indexedTopic.LastPostTimestampRaw)
+			if err != nil || indexedLastPost.Before(oldestLiveActivity) {
+				continue
+			}
+			result.VanishedTopics = append(result.VanishedTopics, indexedTopic)
+			log.Printf("[WARNING] JIT REFRESH: Indexed topic %s (%s) in SubForum %s is missing from the first %d scanned page(s).", indexedTopic.ID, indexedTopic.Title, subForumData.ID, scannedPageCount)
 		}
 	}
 
-	log.Printf("[INFO] JIT REFRESH: Completed for SubForum %s. Discovered %d new topics from %d scanned pages.", subForumData.ID, len(newTopics), scannedPageCount) // Changed %d to %s for subForumData.ID
-	return newTopics, nil
+	result.ScannedPages = scannedPageCount
+	log.Printf("[INFO] JIT REFRESH: Completed for SubForum %s. Discovered %d new topics from %d scanned pages.", subForumData.ID, len(result.NewTopics), scannedPageCount) // Changed %d to %s for subForumData.ID
+	log.Printf("[INFO] JIT REFRESH: SubForum %s: %d bumped topics, %d vanished topics.", subForumData.ID, len(result.BumpedTopics), len(result.VanishedTopics))
+	return result, nil
+}
+
+// detectBump compares an indexed topic with its live listing entry.
+// A topic is bumped when its reply count grew or its last-post timestamp changed.
+func detectBump(indexed data.Topic, live data.Topic, postsPerPage int) (BumpedTopic, bool) {
+	repliesGrew := live.Replies > indexed.Replies
+	lastPostChanged := live.LastPostTimestampRaw != "" && indexed.LastPostTimestampRaw != "" && live.LastPostTimestampRaw != indexed.LastPostTimestampRaw
+	if !repliesGrew && !lastPostChanged {
+		return BumpedTopic{}, false
+	}
+
+	indexedPages := pageplan.PageCount(indexed.Replies, postsPerPage)
+	livePages := pageplan.PageCount(live.Replies, postsPerPage)
+	if livePages < indexedPages {
+		livePages = indexedPages // Replies were deleted; keep the pages we already know about
+	}
+
+	newReplies := live.Replies - indexed.Replies
+	if newReplies < 0 {
+		newReplies = 0
+	}
+
+	// Keep the indexed identity (URL, sub-forum) but take the live activity fields.
+	updated := indexed
+	updated.Replies = live.Replies
+	updated.Views = live.Views
+	updated.LastPostUsername = live.LastPostUsername
+	updated.LastPostTimestampRaw = live.LastPostTimestampRaw
+
+	return BumpedTopic{
+		Live:              updated,
+		Indexed:           indexed,
+		NewReplies:        newReplies,
+		PageDelta:         livePages - indexedPages,
+		FirstAffectedPage: indexedPages, // The last indexed page may have gained posts too
+		LivePageCount:     livePages,
+	}, true
 }
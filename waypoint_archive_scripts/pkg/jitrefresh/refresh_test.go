@@ -421,7 +421,7 @@ func TestPerformJITRefresh(t *testing.T) {
 			extractorAdapter := &mockExtractorAdapter{extractFunc: mockUtilExtractTopicsFromHTMLInUtil}
 
 			// Call PerformJITRefresh with the adapter instances
-			gotNewTopics, err := PerformJITRefresh(tt.subForumData, tt.cfg,
+			result, err := PerformJITRefresh(tt.subForumData, tt.cfg,
 				fetcherAdapter,
 				parserAdapter,
 				extractorAdapter,
@@ -432,6 +432,11 @@ func TestPerformJITRefresh(t *testing.T) {
 				return
 			}
 
+			var gotNewTopics []data.Topic
+			if result != nil {
+				gotNewTopics = result.NewTopics
+			}
+
 			if gotNewTopics == nil && tt.wantNewTopics != nil {
 				t.Errorf("PerformJITRefresh() returned nil gotNewTopics, but wantNewTopics is non-nil %#v", tt.wantNewTopics)
 			}
@@ -453,6 +458,68 @@ func TestPerformJITRefresh(t *testing.T) {
 	}
 }
 
+func TestPerformJITRefresh_BumpedAndVanished(t *testing.T) {
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer func() { log.SetOutput(os.Stderr) }()
+
+	subForum := data.SubForum{
+		ID:   "66",
+		Name: "Bump Forum",
+		URL:  "http://forum.example.com/sf66",
+		Topics: []data.Topic{
+			{ID: "t1", SubForumID: "66", Title: "Bumped", URL: "viewtopic.php?topic=t1&forum=66", Replies: 19, LastPostTimestampRaw: "Jan 10, 2024 10:00 am"},
+			{ID: "t2", SubForumID: "66", Title: "Unchanged", URL: "viewtopic.php?topic=t2&forum=66", Replies: 3, LastPostTimestampRaw: "Jan 5, 2024 09:00 am"},
+			{ID: "t3", SubForumID: "66", Title: "Gone", URL: "viewtopic.php?topic=t3&forum=66", Replies: 1, LastPostTimestampRaw: "Jan 7, 2024 08:00 pm"},
+			{ID: "t4", SubForumID: "66", Title: "Old", URL: "viewtopic.php?topic=t4&forum=66", Replies: 1, LastPostTimestampRaw: "Dec 1, 2020 08:00 pm"},
+		},
+	}
+	liveTopics := []data.Topic{
+		{ID: "t1", SubForumID: "66", Title: "Bumped", Replies: 41, LastPostTimestampRaw: "Feb 2, 2024 11:15 pm", LastPostUsername: "magician"},
+		{ID: "t2", SubForumID: "66", Title: "Unchanged", Replies: 3, LastPostTimestampRaw: "Jan 5, 2024 09:00 am"},
+		{ID: "t5", SubForumID: "66", Title: "Brand New", Replies: 0, LastPostTimestampRaw: "Feb 3, 2024 01:00 am"},
+	}
+
+	fetcher := &mockFetcherAdapter{fetchFunc: func(pageURL string, delay time.Duration, userAgent string) (string, error) {
+		return "<html></html>", nil
+	}}
+	parserAdapter := &mockParserAdapter{parseFunc: func(htmlContent string, pageURL string) ([]string, error) { return nil, nil }}
+	extractor := &mockExtractorAdapter{extractFunc: func(htmlContent string, pageURL string, subForumID string) ([]data.Topic, error) {
+		return liveTopics, nil
+	}}
+
+	result, err := PerformJITRefresh(subForum, &config.Config{JITRefreshPages: 1, PostsPerPage: 20}, fetcher, parserAdapter, extractor)
+	if err != nil {
+		t.Fatalf("PerformJITRefresh() returned error: %v", err)
+	}
+
+	if len(result.NewTopics) != 1 || result.NewTopics[0].ID != "t5" {
+		t.Errorf("NewTopics = %#v, want only t5", result.NewTopics)
+	}
+
+	if len(result.BumpedTopics) != 1 {
+		t.Fatalf("BumpedTopics = %#v, want only t1", result.BumpedTopics)
+	}
+	bumped := result.BumpedTopics[0]
+	if bumped.Live.ID != "t1" || bumped.Live.URL != "viewtopic.php?topic=t1&forum=66" || bumped.Live.Replies != 41 {
+		t.Errorf("Bumped topic live data = %#v, want indexed URL with live reply count", bumped.Live)
+	}
+	if bumped.NewReplies != 22 || bumped.PageDelta != 2 || bumped.FirstAffectedPage != 1 {
+		t.Errorf("Bumped topic NewReplies/PageDelta/FirstAffectedPage = %d/%d/%d, want 22/2/1", bumped.NewReplies, bumped.PageDelta, bumped.FirstAffectedPage)
+	}
+	if got := bumped.AffectedPages(); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("AffectedPages() = %v, want [1 2 3]", got)
+	}
+
+	if len(result.VanishedTopics) != 1 || result.VanishedTopics[0].ID != "t3" {
+		t.Errorf("VanishedTopics = %#v, want only t3", result.VanishedTopics)
+	}
+
+	if !strings.Contains(logBuf.String(), "[INFO] JIT REFRESH: SubForum 66: 1 bumped topics, 1 vanished topics.") {
+		t.Errorf("Missing bump summary log line. Full logs:\n%s", logBuf.String())
+	}
+}
+
 func sortTopics(topics []data.Topic) {
 	sort.Slice(topics, func(i, j int) bool {
 		return topics[i].ID < topics[j].ID
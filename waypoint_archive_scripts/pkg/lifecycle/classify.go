@@ -0,0 +1,85 @@
+package lifecycle
+
+import (
+	"time"
+
+	"waypoint_archive_scripts/pkg/canonurl"
+	"waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/downloader"
+)
+
+// Event sources.
+const (
+	SourceFetch      = "fetch"
+	SourceJITRefresh = "jit_refresh"
+)
+
+// ClassifyFetch inspects the outcome of fetching page pageNum (1-based) of topic and reports a
+// lifecycle event when the topic is gone or was redirected to another sub-forum or topic.
+// Only a 404/410 for the first page means the topic is gone: a missing later page is a problem
+// with that page (the topic may have shrunk since it was indexed), not with the topic. The ledger
+// only takes a gone topic as ended once the 404/410 has been confirmed (see Ledger.Ended).
+// Redirects that keep the same topic and forum (e.g. http to https) are not events.
+func ClassifyFetch(topic data.Topic, pageNum int, result *downloader.FetchResult, fetchErr error) (Event, bool) {
+	event := Event{ObservedAt: time.Now().UTC(), SubForumID: topic.SubForumID, Source: SourceFetch}
+	if result != nil {
+		event.RequestedURL = result.RequestedURL
+		event.FinalURL = result.FinalURL
+		event.HTTPStatus = result.StatusCode
+	}
+
+	if fetchErr != nil {
+		if pageNum == 1 && downloader.IsGone(fetchErr) {
+			event.Status = StatusGone
+			return event, true
+		}
+		return Event{}, false
+	}
+	if result == nil || !result.Redirected {
+		return Event{}, false
+	}
+
+	finalTopicID, finalForumID := TopicAndForumFromURL(result.FinalURL)
+	switch {
+	case finalTopicID != "" && finalTopicID != topic.ID:
+		event.Status = StatusMerged
+		event.NewTopicID = finalTopicID
+		event.NewSubForumID = finalForumID
+	case finalForumID != "" && finalForumID != topic.SubForumID:
+		event.Status = StatusMoved
+		event.NewSubForumID = finalForumID
+	default:
+		return Event{}, false
+	}
+	return event, true
+}
+
+// ClassifyListing reports a relocation when liveTopic (as listed in a sub-forum) carries a topic
+// ID that was indexed under indexedSubForumID, a different sub-forum.
+func ClassifyListing(liveTopic data.Topic, indexedSubForumID string) (Event, bool) {
+	liveSubForumID := liveTopic.SubForumID
+	if _, forumFromURL := TopicAndForumFromURL(liveTopic.URL); forumFromURL != "" {
+		liveSubForumID = forumFromURL
+	}
+	if indexedSubForumID == "" || liveSubForumID == "" || liveSubForumID == indexedSubForumID {
+		return Event{}, false
+	}
+	return Event{
+		Status:        StatusRelocated,
+		ObservedAt:    time.Now().UTC(),
+		SubForumID:    indexedSubForumID,
+		NewSubForumID: liveSubForumID,
+		FinalURL:      liveTopic.URL,
+		Source:        SourceJITRefresh,
+	}, true
+}
+
+// TopicAndForumFromURL extracts the topic and forum IDs from a topic URL via canonurl, so both the
+// Magic Cafe ("topic", "forum") and phpBB ("t", "f") parameter names are accepted.
+func TopicAndForumFromURL(rawURL string) (topicID string, forumID string) {
+	ref, err := canonurl.Parse(rawURL)
+	if err != nil {
+		return "", ""
+	}
+	return string(ref.TopicID), string(ref.SubForumID)
+}
@@ -0,0 +1,122 @@
+package lifecycle
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/downloader"
+)
+
+func TestClassifyFetch(t *testing.T) {
+	topic := data.Topic{ID: "100", SubForumID: "23", URL: "https://www.themagiccafe.com/forums/viewtopic.php?topic=100&forum=23"}
+
+	tests := []struct {
+		name       string
+		page       int // Page fetched; 0 means the first
+		result     *downloader.FetchResult
+		err        error
+		wantOK     bool
+		wantStatus Status
+		wantForum  string
+		wantTopic  string
+	}{
+		{
+			name:   "normal response",
+			result: &downloader.FetchResult{RequestedURL: topic.URL, FinalURL: topic.URL, StatusCode: 200},
+		},
+		{
+			name:       "404 is gone",
+			result:     &downloader.FetchResult{RequestedURL: topic.URL, FinalURL: topic.URL, StatusCode: 404},
+			err:        &downloader.HTTPError{StatusCode: http.StatusNotFound, URL: topic.URL},
+			wantOK:     true,
+			wantStatus: StatusGone,
+		},
+		{
+			name:       "410 is gone",
+			err:        &downloader.HTTPError{StatusCode: http.StatusGone, URL: topic.URL},
+			wantOK:     true,
+			wantStatus: StatusGone,
+		},
+		{
+			name: "404 on a later page is a page error",
+			page: 3,
+			err:  &downloader.HTTPError{StatusCode: http.StatusNotFound, URL: topic.URL + "&start=40"},
+		},
+		{
+			name: "500 is just an error",
+			err:  &downloader.HTTPError{StatusCode: http.StatusInternalServerError, URL: topic.URL},
+		},
+		{
+			name: "network error",
+			err:  errors.New("connection reset"),
+		},
+		{
+			name:       "redirect to other forum is moved",
+			result:     &downloader.FetchResult{RequestedURL: topic.URL, FinalURL: "https://www.themagiccafe.com/forums/viewtopic.php?topic=100&forum=41", StatusCode: 200, Redirected: true},
+			wantOK:     true,
+			wantStatus: StatusMoved,
+			wantForum:  "41",
+		},
+		{
+			name:       "redirect to other topic is merged",
+			result:     &downloader.FetchResult{RequestedURL: topic.URL, FinalURL: "https://www.themagiccafe.com/forums/viewtopic.php?topic=555&forum=23", StatusCode: 200, Redirected: true},
+			wantOK:     true,
+			wantStatus: StatusMerged,
+			wantForum:  "23",
+			wantTopic:  "555",
+		},
+		{
+			name:       "redirect on a later page is still merged",
+			page:       2,
+			result:     &downloader.FetchResult{RequestedURL: topic.URL + "&start=20", FinalURL: "https://www.themagiccafe.com/forums/viewtopic.php?topic=555&forum=23&start=20", StatusCode: 200, Redirected: true},
+			wantOK:     true,
+			wantStatus: StatusMerged,
+			wantForum:  "23",
+			wantTopic:  "555",
+		},
+		{
+			name:   "scheme-only redirect is not an event",
+			result: &downloader.FetchResult{RequestedURL: topic.URL, FinalURL: "http://www.themagiccafe.com/forums/viewtopic.php?topic=100&forum=23", StatusCode: 200, Redirected: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := tt.page
+			if page == 0 {
+				page = 1
+			}
+			event, ok := ClassifyFetch(topic, page, tt.result, tt.err)
+			if ok != tt.wantOK {
+				t.Fatalf("ClassifyFetch() ok = %v, want %v (event %#v)", ok, tt.wantOK, event)
+			}
+			if !ok {
+				return
+			}
+			if event.Status != tt.wantStatus || event.NewSubForumID != tt.wantForum || event.NewTopicID != tt.wantTopic {
+				t.Errorf("ClassifyFetch() = %#v, want status %s forum %q topic %q", event, tt.wantStatus, tt.wantForum, tt.wantTopic)
+			}
+			if event.SubForumID != "23" || event.Source != SourceFetch {
+				t.Errorf("ClassifyFetch() did not record origin: %#v", event)
+			}
+		})
+	}
+}
+
+func TestClassifyListing(t *testing.T) {
+	live := data.Topic{ID: "100", SubForumID: "41", URL: "https://www.themagiccafe.com/forums/viewtopic.php?topic=100&forum=41"}
+
+	event, ok := ClassifyListing(live, "23")
+	if !ok || event.Status != StatusRelocated || event.SubForumID != "23" || event.NewSubForumID != "41" {
+		t.Errorf("ClassifyListing() = %#v, %v; want relocation from 23 to 41", event, ok)
+	}
+
+	if _, ok := ClassifyListing(live, "41"); ok {
+		t.Error("ClassifyListing() reported a relocation for the same sub-forum")
+	}
+	if _, ok := ClassifyListing(live, ""); ok {
+		t.Error("ClassifyListing() reported a relocation for an unindexed topic")
+	}
+}
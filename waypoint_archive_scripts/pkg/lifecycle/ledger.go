@@ -0,0 +1,299 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/storer"
+)
+
+// Status describes what happened to a topic on the live forum.
+type Status string
+
+const (
+	StatusGone      Status = "gone"      // Topic page answers 404/410
+	StatusMoved     Status = "moved"     // Topic URL redirects to the same topic ID in another sub-forum
+	StatusMerged    Status = "merged"    // Topic URL redirects to a different topic ID
+	StatusRelocated Status = "relocated" // Same topic ID listed under a different forum= than where it was indexed
+	StatusReachable Status = "reachable" // Topic page answers again after a 404/410
+)
+
+// A single 404/410 may be transient (a topic hidden by a moderator for a while, a forum hiccup),
+// so a topic is only treated as gone for good once its first page has answered 404/410
+// GoneConfirmations times in a row, the last at least GoneRecheckInterval after the first.
+// Until then it is fetched again on every run.
+const (
+	GoneConfirmations   = 2
+	GoneRecheckInterval = 24 * time.Hour
+)
+
+// Event is a single lifecycle observation for a topic.
+type Event struct {
+	Status        Status    `json:"status"`
+	ObservedAt    time.Time `json:"observed_at"`
+	SubForumID    string    `json:"sub_forum_id"`               // Sub-forum the topic was expected in
+	NewSubForumID string    `json:"new_sub_forum_id,omitempty"` // Sub-forum the topic was found in (moved, relocated, merged)
+	NewTopicID    string    `json:"new_topic_id,omitempty"`     // Target topic ID (merged)
+	RequestedURL  string    `json:"requested_url,omitempty"`    // URL that was fetched
+	FinalURL      string    `json:"final_url,omitempty"`        // URL after redirects
+	HTTPStatus    int       `json:"http_status,omitempty"`      // Status code of the response, if any
+	Source        string    `json:"source,omitempty"`           // Where the observation came from (e.g. "fetch", "jit_refresh")
+	AliasPath     string    `json:"alias_path,omitempty"`       // Alias file written for this event, if any
+}
+
+// TopicRecord is the ledger entry for one topic ID.
+type TopicRecord struct {
+	TopicID            string  `json:"topic_id"`
+	Status             Status  `json:"status"`                         // Most recent status
+	ArchivedSubForumID string  `json:"archived_sub_forum_id"`          // Sub-forum directory holding the archived copy; never changes once set
+	CurrentSubForumID  string  `json:"current_sub_forum_id,omitempty"` // Latest known live sub-forum
+	MergedIntoTopicID  string  `json:"merged_into_topic_id,omitempty"` // Set when Status is StatusMerged
+	Events             []Event `json:"events"`
+}
+
+// Ledger tracks moved, merged, relocated and deleted topics across runs.
+type Ledger struct {
+	Topics map[string]*TopicRecord `json:"topics"` // TopicID to record
+
+	mux sync.Mutex
+}
+
+// NewLedger creates an empty Ledger.
+func NewLedger() *Ledger {
+	return &Ledger{Topics: make(map[string]*TopicRecord)}
+}
+
+// LoadLedger loads a ledger from filePath. A missing file yields an empty ledger.
+func LoadLedger(filePath string) (*Ledger, error) {
+	ledgerBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("[INFO] LIFECYCLE: Ledger file %s not found. Starting with an empty ledger.", filePath)
+			return NewLedger(), nil
+		}
+		return nil, fmt.Errorf("failed to read lifecycle ledger %s: %w", filePath, err)
+	}
+
+	ledger := NewLedger()
+	if len(ledgerBytes) == 0 {
+		return ledger, nil
+	}
+	if err := json.Unmarshal(ledgerBytes, ledger); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal lifecycle ledger %s: %w", filePath, err)
+	}
+	if ledger.Topics == nil {
+		ledger.Topics = make(map[string]*TopicRecord)
+	}
+	return ledger, nil
+}
+
+// Save writes the ledger to filePath atomically via a temporary file.
+func (l *Ledger) Save(filePath string) error {
+	l.mux.Lock()
+	ledgerBytes, err := json.MarshalIndent(l, "", "  ")
+	l.mux.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal lifecycle ledger: %w", err)
+	}
+
+	dir := filepath.Dir(filePath)
+	if dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory for lifecycle ledger %s: %w", dir, err)
+		}
+	}
+
+	tempFilePath := filePath + ".tmp"
+	if err := os.WriteFile(tempFilePath, ledgerBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write lifecycle ledger to temporary file %s: %w", tempFilePath, err)
+	}
+	if err := os.Rename(tempFilePath, filePath); err != nil {
+		return fmt.Errorf("failed to rename temporary lifecycle ledger %s to %s: %w", tempFilePath, filePath, err)
+	}
+	return nil
+}
+
+// Record appends an event for topicID. archivedSubForumID is where the topic's archived copy
+// lives (or would live); it is only used the first time a topic enters the ledger, so the
+// original location is never lost.
+func (l *Ledger) Record(topicID string, archivedSubForumID string, event Event) *TopicRecord {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if event.ObservedAt.IsZero() {
+		event.ObservedAt = time.Now().UTC()
+	}
+
+	record, exists := l.Topics[topicID]
+	if !exists {
+		record = &TopicRecord{TopicID: topicID, ArchivedSubForumID: archivedSubForumID}
+		l.Topics[topicID] = record
+	}
+	if record.ArchivedSubForumID == "" {
+		record.ArchivedSubForumID = archivedSubForumID
+	}
+
+	record.Status = event.Status
+	if event.NewSubForumID != "" {
+		record.CurrentSubForumID = event.NewSubForumID
+	}
+	if event.Status == StatusMerged {
+		record.MergedIntoTopicID = event.NewTopicID
+	}
+	record.Events = append(record.Events, event)
+
+	log.Printf("[INFO] LIFECYCLE: Topic %s recorded as %s (expected in %s, found in %s, target topic %s).", topicID, event.Status, event.SubForumID, event.NewSubForumID, event.NewTopicID)
+	return record
+}
+
+// RecordWithAlias records event like Record. For moved and relocated topics that are already
+// archived, it first writes an alias at the topic's new location pointing at the archived copy,
+// which stays where it is. It returns true when the topic no longer exists as such on the forum
+// (gone or merged), so callers can stop fetching it.
+func (l *Ledger) RecordWithAlias(s *storer.Storer, topicID string, storageSubForumID string, event Event, topicArchived bool) bool {
+	if (event.Status == StatusMoved || event.Status == StatusRelocated) &&
+		topicArchived && event.NewSubForumID != "" && event.NewSubForumID != storageSubForumID {
+		aliasPath, err := s.SaveTopicAlias(event.NewSubForumID, topicID, storer.TopicAlias{
+			SubForumID: storageSubForumID,
+			TopicID:    topicID,
+			Reason:     string(event.Status),
+			CreatedAt:  event.ObservedAt,
+		})
+		if err != nil {
+			log.Printf("[ERROR] LIFECYCLE: Failed to write alias for topic %s in sub-forum %s: %v", topicID, event.NewSubForumID, err)
+		} else {
+			event.AliasPath = aliasPath
+		}
+	}
+	l.Record(topicID, storageSubForumID, event)
+	return event.Status == StatusGone || event.Status == StatusMerged
+}
+
+// IndexedSubForumByTopic maps each topic ID in the loaded index to the sub-forum it is indexed
+// under, the first one if it is indexed more than once.
+func IndexedSubForumByTopic(subForums []data.SubForum) map[string]string {
+	indexed := make(map[string]string)
+	for _, sf := range subForums {
+		for _, t := range sf.Topics {
+			if _, seen := indexed[t.ID]; !seen {
+				indexed[t.ID] = sf.ID
+			}
+		}
+	}
+	return indexed
+}
+
+// FilterRelocated returns the topics discovered on the listing of subForumID that are genuinely
+// new. Topics whose ID is already indexed under another sub-forum (per indexedSubForumByTopic)
+// are recorded as relocated instead, through RecordWithAlias; isArchived reports whether a topic
+// has an archived copy to alias.
+func (l *Ledger) FilterRelocated(s *storer.Storer, newTopics []data.Topic, subForumID string, indexedSubForumByTopic map[string]string, isArchived func(topicID string) bool) []data.Topic {
+	genuinelyNew := make([]data.Topic, 0, len(newTopics))
+	for _, newTopic := range newTopics {
+		indexedSubForumID, indexedElsewhere := indexedSubForumByTopic[newTopic.ID]
+		if !indexedElsewhere || indexedSubForumID == subForumID {
+			genuinelyNew = append(genuinelyNew, newTopic)
+			continue
+		}
+		if newTopic.SubForumID == "" {
+			newTopic.SubForumID = subForumID
+		}
+		event, ok := ClassifyListing(newTopic, indexedSubForumID)
+		if !ok {
+			genuinelyNew = append(genuinelyNew, newTopic)
+			continue
+		}
+		storageSubForumID := l.StorageSubForumID(newTopic.ID, indexedSubForumID)
+		l.RecordWithAlias(s, newTopic.ID, storageSubForumID, event, isArchived(newTopic.ID))
+	}
+	return genuinelyNew
+}
+
+// RecordReachable records that the first page of topicID was fetched successfully. Only topics
+// the ledger has as gone get an event: their run of 404/410 answers ends, so a later one starts
+// a new run.
+func (l *Ledger) RecordReachable(topicID string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	record, ok := l.Topics[topicID]
+	if !ok || record.Status != StatusGone {
+		return
+	}
+	record.Status = StatusReachable
+	record.Events = append(record.Events, Event{Status: StatusReachable, ObservedAt: time.Now().UTC(), SubForumID: record.ArchivedSubForumID, Source: SourceFetch})
+	log.Printf("[INFO] LIFECYCLE: Topic %s answers again after %s.", topicID, StatusGone)
+}
+
+// Ended reports whether topicID no longer exists as such on the forum, so archivers stop
+// fetching it: it was merged, or it is gone and the 404/410 has been confirmed (see
+// GoneConfirmations). It returns the topic's latest status.
+func (l *Ledger) Ended(topicID string) (Status, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	record, ok := l.Topics[topicID]
+	if !ok {
+		return "", false
+	}
+	switch record.Status {
+	case StatusMerged:
+		return record.Status, true
+	case StatusGone:
+		return record.Status, goneConfirmed(record.Events)
+	default:
+		return record.Status, false
+	}
+}
+
+// goneConfirmed reports whether events end in GoneConfirmations or more gone events, the last at
+// least GoneRecheckInterval after the first of them.
+func goneConfirmed(events []Event) bool {
+	first := len(events)
+	for first > 0 && events[first-1].Status == StatusGone {
+		first--
+	}
+	if len(events)-first < GoneConfirmations {
+		return false
+	}
+	return events[len(events)-1].ObservedAt.Sub(events[first].ObservedAt) >= GoneRecheckInterval
+}
+
+// Lookup returns the ledger record for topicID, if any.
+func (l *Ledger) Lookup(topicID string) (*TopicRecord, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	record, ok := l.Topics[topicID]
+	return record, ok
+}
+
+// StorageSubForumID returns the sub-forum directory that pages of topicID must be stored under.
+// Topics in the ledger keep their original archive location so a move never splits or
+// duplicates an archived copy; other topics use fallback.
+func (l *Ledger) StorageSubForumID(topicID string, fallback string) string {
+	if record, ok := l.Lookup(topicID); ok && record.ArchivedSubForumID != "" {
+		return record.ArchivedSubForumID
+	}
+	return fallback
+}
+
+// IsGone reports whether the latest status of topicID is StatusGone.
+func (l *Ledger) IsGone(topicID string) bool {
+	record, ok := l.Lookup(topicID)
+	return ok && record.Status == StatusGone
+}
+
+// CountByStatus returns the number of topics whose latest status is each Status.
+func (l *Ledger) CountByStatus() map[Status]int {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	counts := make(map[Status]int)
+	for _, record := range l.Topics {
+		counts[record.Status]++
+	}
+	return counts
+}
@@ -0,0 +1,187 @@
+package lifecycle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/storer"
+)
+
+func TestLedger_RecordKeepsOriginalLocation(t *testing.T) {
+	ledger := NewLedger()
+
+	ledger.Record("t1", "sf1", Event{Status: StatusMoved, SubForumID: "sf1", NewSubForumID: "sf2"})
+	ledger.Record("t1", "sf2", Event{Status: StatusMoved, SubForumID: "sf2", NewSubForumID: "sf3"})
+
+	record, ok := ledger.Lookup("t1")
+	if !ok {
+		t.Fatal("Expected topic t1 in ledger")
+	}
+	if record.ArchivedSubForumID != "sf1" {
+		t.Errorf("ArchivedSubForumID = %s, want sf1 (original location must not change)", record.ArchivedSubForumID)
+	}
+	if record.CurrentSubForumID != "sf3" {
+		t.Errorf("CurrentSubForumID = %s, want sf3", record.CurrentSubForumID)
+	}
+	if len(record.Events) != 2 {
+		t.Errorf("Expected 2 events, got %d", len(record.Events))
+	}
+	if got := ledger.StorageSubForumID("t1", "sf3"); got != "sf1" {
+		t.Errorf("StorageSubForumID = %s, want sf1", got)
+	}
+	if got := ledger.StorageSubForumID("unknown", "sf9"); got != "sf9" {
+		t.Errorf("StorageSubForumID for unknown topic = %s, want fallback sf9", got)
+	}
+}
+
+func TestLedger_MergedAndGone(t *testing.T) {
+	ledger := NewLedger()
+	ledger.Record("t1", "sf1", Event{Status: StatusMerged, SubForumID: "sf1", NewTopicID: "t99"})
+	ledger.Record("t2", "sf1", Event{Status: StatusGone, SubForumID: "sf1", HTTPStatus: 404})
+
+	if record, _ := ledger.Lookup("t1"); record.MergedIntoTopicID != "t99" {
+		t.Errorf("MergedIntoTopicID = %s, want t99", record.MergedIntoTopicID)
+	}
+	if !ledger.IsGone("t2") || ledger.IsGone("t1") {
+		t.Error("IsGone reported the wrong topics")
+	}
+	counts := ledger.CountByStatus()
+	if counts[StatusMerged] != 1 || counts[StatusGone] != 1 {
+		t.Errorf("CountByStatus = %v", counts)
+	}
+}
+
+func TestLedger_EndedNeedsConfirmedGone(t *testing.T) {
+	firstSeen := time.Date(2025, time.March, 1, 10, 0, 0, 0, time.UTC)
+	ledger := NewLedger()
+	ledger.Record("t1", "sf1", Event{Status: StatusMerged, SubForumID: "sf1", NewTopicID: "t99"})
+	if status, ended := ledger.Ended("t1"); !ended || status != StatusMerged {
+		t.Errorf("Ended(t1) = %s, %v; want merged, true", status, ended)
+	}
+	if _, ended := ledger.Ended("unknown"); ended {
+		t.Error("Ended() reported an unknown topic as ended")
+	}
+
+	ledger.Record("t2", "sf1", Event{Status: StatusGone, SubForumID: "sf1", HTTPStatus: 404, ObservedAt: firstSeen})
+	if _, ended := ledger.Ended("t2"); ended {
+		t.Error("Ended() reported a topic as ended after a single 404")
+	}
+	ledger.Record("t2", "sf1", Event{Status: StatusGone, SubForumID: "sf1", HTTPStatus: 404, ObservedAt: firstSeen.Add(time.Hour)})
+	if _, ended := ledger.Ended("t2"); ended {
+		t.Error("Ended() reported a topic as ended after two 404s within the re-check interval")
+	}
+
+	// A successful fetch ends the run of 404s, so the next one starts over
+	ledger.RecordReachable("t2")
+	if record, _ := ledger.Lookup("t2"); record.Status != StatusReachable {
+		t.Errorf("Status after RecordReachable = %s, want %s", record.Status, StatusReachable)
+	}
+	ledger.Record("t2", "sf1", Event{Status: StatusGone, SubForumID: "sf1", HTTPStatus: 410, ObservedAt: firstSeen.Add(48 * time.Hour)})
+	if _, ended := ledger.Ended("t2"); ended {
+		t.Error("Ended() counted 404s from before the topic answered again")
+	}
+	ledger.Record("t2", "sf1", Event{Status: StatusGone, SubForumID: "sf1", HTTPStatus: 404, ObservedAt: firstSeen.Add(48*time.Hour + GoneRecheckInterval)})
+	if status, ended := ledger.Ended("t2"); !ended || status != StatusGone {
+		t.Errorf("Ended(t2) = %s, %v; want gone, true after a confirmed 404", status, ended)
+	}
+
+	ledger.RecordReachable("t1")
+	if record, _ := ledger.Lookup("t1"); record.Status != StatusMerged || len(record.Events) != 1 {
+		t.Error("RecordReachable() changed a topic that was not gone")
+	}
+}
+
+func TestLedger_SaveAndLoad(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "nested", "topic_lifecycle.json")
+
+	missing, err := LoadLedger(filePath)
+	if err != nil || len(missing.Topics) != 0 {
+		t.Fatalf("LoadLedger on missing file = %#v, %v; want empty ledger", missing, err)
+	}
+
+	ledger := NewLedger()
+	ledger.Record("t1", "sf1", Event{Status: StatusRelocated, SubForumID: "sf1", NewSubForumID: "sf2", AliasPath: "archive/sf2/t1/alias.json"})
+	if err := ledger.Save(filePath); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := os.Stat(filePath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Temporary ledger file left behind: %v", err)
+	}
+
+	loaded, err := LoadLedger(filePath)
+	if err != nil {
+		t.Fatalf("LoadLedger failed: %v", err)
+	}
+	record, ok := loaded.Lookup("t1")
+	if !ok || record.Status != StatusRelocated || record.ArchivedSubForumID != "sf1" || record.Events[0].AliasPath != "archive/sf2/t1/alias.json" {
+		t.Errorf("Loaded record = %#v", record)
+	}
+}
+
+func TestLedger_RecordWithAliasKeepsArchivedCopy(t *testing.T) {
+	archiveRoot := t.TempDir()
+	s := storer.NewStorer(archiveRoot)
+	if _, err := s.SaveTopicHTML("sf1", "t1", 1, []byte("<html>archived</html>")); err != nil {
+		t.Fatalf("SaveTopicHTML failed: %v", err)
+	}
+
+	ledger := NewLedger()
+	ended := ledger.RecordWithAlias(s, "t1", "sf1", Event{Status: StatusMoved, SubForumID: "sf1", NewSubForumID: "sf2"}, true)
+	if ended {
+		t.Error("RecordWithAlias() reported a moved topic as ended")
+	}
+
+	alias, err := s.LoadTopicAlias("sf2", "t1")
+	if err != nil || alias == nil || alias.SubForumID != "sf1" || alias.Reason != string(StatusMoved) {
+		t.Fatalf("LoadTopicAlias() = %#v, %v; want alias to sf1", alias, err)
+	}
+	if _, err := os.Stat(filepath.Join(archiveRoot, "sf1", "t1", "page_1.html")); err != nil {
+		t.Errorf("Archived copy moved or removed: %v", err)
+	}
+	if record, _ := ledger.Lookup("t1"); record.Events[0].AliasPath == "" {
+		t.Error("Alias path not recorded in ledger event")
+	}
+
+	if !ledger.RecordWithAlias(s, "t2", "sf1", Event{Status: StatusGone, SubForumID: "sf1"}, false) {
+		t.Error("RecordWithAlias() did not report a gone topic as ended")
+	}
+	if _, err := os.Stat(filepath.Join(archiveRoot, "sf2", "t2")); !os.IsNotExist(err) {
+		t.Error("Alias written for a topic that was not archived")
+	}
+}
+
+func TestLedger_FilterRelocated(t *testing.T) {
+	archiveRoot := t.TempDir()
+	s := storer.NewStorer(archiveRoot)
+	indexed := IndexedSubForumByTopic([]data.SubForum{
+		{ID: "23", Topics: []data.Topic{{ID: "t1"}, {ID: "t2"}}},
+		{ID: "41", Topics: []data.Topic{{ID: "t2"}, {ID: "t3"}}},
+	})
+	if indexed["t2"] != "23" {
+		t.Errorf("IndexedSubForumByTopic()[t2] = %s, want the first sub-forum 23", indexed["t2"])
+	}
+
+	ledger := NewLedger()
+	newTopics := []data.Topic{
+		{ID: "t1", URL: "http://www.themagiccafe.com/forums/viewtopic.php?topic=t1&forum=41"},
+		{ID: "t3", URL: "http://www.themagiccafe.com/forums/viewtopic.php?topic=t3&forum=41"},
+		{ID: "t9", URL: "http://www.themagiccafe.com/forums/viewtopic.php?topic=t9&forum=41"},
+	}
+	got := ledger.FilterRelocated(s, newTopics, "41", indexed, func(topicID string) bool { return topicID == "t1" })
+	if len(got) != 2 || got[0].ID != "t3" || got[1].ID != "t9" {
+		t.Fatalf("FilterRelocated() = %+v, want t3 and t9", got)
+	}
+	record, ok := ledger.Lookup("t1")
+	if !ok || record.Status != StatusRelocated || record.ArchivedSubForumID != "23" || record.CurrentSubForumID != "41" {
+		t.Fatalf("Lookup(t1) = %#v, %v; want relocation from 23 to 41", record, ok)
+	}
+	if alias, err := s.LoadTopicAlias("41", "t1"); err != nil || alias == nil || alias.SubForumID != "23" {
+		t.Errorf("LoadTopicAlias() = %#v, %v; want alias to 23", alias, err)
+	}
+	if _, tracked := ledger.Lookup("t9"); tracked {
+		t.Error("A genuinely new topic was recorded in the ledger")
+	}
+}
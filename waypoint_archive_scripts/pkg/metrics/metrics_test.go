@@ -77,6 +77,7 @@ func TestPerformanceLogger_AppendAndLoadMetrics(t *testing.T) {
 		AvgPagesPerMin:   30.0,
 		AvgTopicsPerHour: 30.0,
 		AvgMBPerMin:      8.14,
+		IndexTopicsAdded: 4,
 	}
 
 	// Test appending metrics
@@ -99,7 +100,8 @@ func TestPerformanceLogger_AppendAndLoadMetrics(t *testing.T) {
 	if loaded.BatchID != metrics.BatchID ||
 		loaded.PagesArchived != metrics.PagesArchived ||
 		loaded.TopicsArchived != metrics.TopicsArchived ||
-		loaded.BytesArchived != metrics.BytesArchived {
+		loaded.BytesArchived != metrics.BytesArchived ||
+		loaded.IndexTopicsAdded != metrics.IndexTopicsAdded {
 		t.Error("Loaded metrics don't match original metrics")
 	}
 }
@@ -204,7 +204,7 @@ func (l *PerformanceLogger) AppendMetrics(metrics HistoricalMetrics) error {
 		header := []string{
 			"TimestampUTC", "BatchID", "DurationSeconds", "PagesArchived",
 			"TopicsArchived", "BytesArchived", "AvgPagesPerMin",
-			"AvgTopicsPerHour", "AvgMBPerMin",
+			"AvgTopicsPerHour", "AvgMBPerMin", "IndexTopicsAdded",
 		}
 		if err := writer.Write(header); err != nil {
 			return fmt.Errorf("failed to write header: %w", err)
@@ -222,6 +222,7 @@ func (l *PerformanceLogger) AppendMetrics(metrics HistoricalMetrics) error {
 		fmt.Sprintf("%.2f", metrics.AvgPagesPerMin),
 		fmt.Sprintf("%.2f", metrics.AvgTopicsPerHour),
 		fmt.Sprintf("%.2f", metrics.AvgMBPerMin),
+		strconv.FormatInt(metrics.IndexTopicsAdded, 10),
 	}
 
 	if err := writer.Write(row); err != nil {
@@ -240,6 +241,7 @@ func (l *PerformanceLogger) LoadHistoricalMetrics() ([]HistoricalMetrics, error)
 	defer file.Close()
 
 	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 // Older logs lack the IndexTopicsAdded column
 
 	// Skip header
 	if _, err := reader.Read(); err != nil {
@@ -267,6 +269,10 @@ func (l *PerformanceLogger) LoadHistoricalMetrics() ([]HistoricalMetrics, error)
 		avgPages, _ := strconv.ParseFloat(record[6], 64)
 		avgTopics, _ := strconv.ParseFloat(record[7], 64)
 		avgMB, _ := strconv.ParseFloat(record[8], 64)
+		var indexTopicsAdded int64
+		if len(record) > 9 { // Column added later; older logs have 9 columns
+			indexTopicsAdded, _ = strconv.ParseInt(record[9], 10, 64)
+		}
 
 		metrics = append(metrics, HistoricalMetrics{
 			TimestampUTC:     timestamp,
@@ -278,6 +284,7 @@ func (l *PerformanceLogger) LoadHistoricalMetrics() ([]HistoricalMetrics, error)
 			AvgPagesPerMin:   avgPages,
 			AvgTopicsPerHour: avgTopics,
 			AvgMBPerMin:      avgMB,
+			IndexTopicsAdded: indexTopicsAdded,
 		})
 	}
 
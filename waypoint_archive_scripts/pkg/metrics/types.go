@@ -33,6 +33,7 @@ const (
 	ActionJITFetchSubforum MetricAction = "JITFetchSubforumPage"
 	ActionJITExtractTopics MetricAction = "JITExtractTopics"
 	ActionJITFoundNewTopic MetricAction = "JITFoundNewTopic"
+	ActionJITIndexUpdated  MetricAction = "JITIndexUpdated"
 	// Add other actions as needed
 )
 
@@ -60,6 +61,7 @@ type BatchMetrics struct {
 	BytesArchived     int64
 	TopicsSkipped     int64
 	ErrorsEncountered int64
+	IndexTopicsAdded  int64 // Topics persisted into topic index files by JIT refresh
 
 	// Current rates (updated periodically)
 	CurrentPagesPerMin   float64
@@ -84,6 +86,7 @@ type HistoricalMetrics struct {
 	AvgPagesPerMin   float64
 	AvgTopicsPerHour float64
 	AvgMBPerMin      float64
+	IndexTopicsAdded int64
 }
 
 // NewBatchMetrics creates a new BatchMetrics instance with initialized start time
@@ -151,5 +154,6 @@ func (m *BatchMetrics) ToHistoricalMetrics(batchID string) HistoricalMetrics {
 		AvgPagesPerMin:   float64(m.PagesArchived) / (duration / 60),
 		AvgTopicsPerHour: float64(m.TopicsArchived) / (duration / 3600),
 		AvgMBPerMin:      (float64(m.BytesArchived) / (1024 * 1024)) / (duration / 60),
+		IndexTopicsAdded: m.IndexTopicsAdded,
 	}
 }
@@ -0,0 +1,181 @@
+package pageplan
+
+import (
+	"fmt"
+	"log"
+
+	"waypoint_archive_scripts/pkg/canonurl"
+	"waypoint_archive_scripts/pkg/config"
+	"waypoint_archive_scripts/pkg/data"
+	"waypoint_archive_scripts/pkg/forumadapter"
+	"waypoint_archive_scripts/pkg/htmlutil"
+	"waypoint_archive_scripts/pkg/util"
+)
+
+// DefaultPostsPerPage is the number of posts Magic Cafe shows on each topic page.
+// It is used whenever the configured value is missing or not positive.
+const DefaultPostsPerPage = canonurl.DefaultPostsPerPage
+
+// PageCount returns the number of topic pages needed to hold a topic with the given
+// number of replies. The opening post counts as one post, so a topic always has at least one page.
+func PageCount(replies int, postsPerPage int) int {
+	if postsPerPage <= 0 {
+		postsPerPage = DefaultPostsPerPage
+	}
+	if replies < 0 {
+		replies = 0
+	}
+	totalPosts := replies + 1
+	return (totalPosts + postsPerPage - 1) / postsPerPage
+}
+
+// PageURL builds the normalized URL of a 1-based page of a topic.
+// Magic Cafe pagination is a plain 'start=' post offset, so page N starts at (N-1)*postsPerPage.
+func PageURL(topic data.Topic, pageNum int, postsPerPage int) (string, error) {
+	if topic.URL == "" {
+		return "", fmt.Errorf("PageURL: topic %s has no base URL", topic.ID)
+	}
+	if pageNum < 1 {
+		return "", fmt.Errorf("PageURL: invalid page number %d for topic %s", pageNum, topic.ID)
+	}
+	if postsPerPage <= 0 {
+		postsPerPage = DefaultPostsPerPage
+	}
+
+	ref, err := canonurl.Parse(topic.URL)
+	if err != nil {
+		return "", fmt.Errorf("PageURL: failed to parse topic URL '%s': %w", topic.URL, err)
+	}
+	normalized, err := util.NormalizeTopicPageURL(ref.WithStart(canonurl.StartForPage(pageNum, postsPerPage)).String(), topic.SubForumID)
+	if err != nil {
+		return "", err
+	}
+	// Page URLs are fetched, so they take the form the forum engine serves
+	normalizedRef, err := canonurl.Parse(normalized)
+	if err != nil {
+		return "", fmt.Errorf("PageURL: failed to parse normalized URL '%s': %w", normalized, err)
+	}
+	return forumadapter.Default().FormatURL(normalizedRef), nil
+}
+
+// PlanTopicPageURLs derives the full, ordered list of page URLs for a topic from its indexed reply count.
+// No network access is performed.
+func PlanTopicPageURLs(topic data.Topic, postsPerPage int) ([]string, error) {
+	return pageURLsForCount(topic, PageCount(topic.Replies, postsPerPage), postsPerPage)
+}
+
+// CountsLookStale reports whether the indexed counts for a topic cannot be trusted for planning.
+// Index files that only carry ID, title and URL leave Replies at zero with no last-post data,
+// which is indistinguishable from "unknown", so those topics need live verification. The indexer
+// saves replies and the last post with every topic, so topics indexed with it are planned without
+// a fetch; older index files need a re-index to benefit.
+func CountsLookStale(topic data.Topic) bool {
+	if topic.Replies < 0 {
+		return true
+	}
+	return topic.Replies == 0 && topic.LastPostTimestampRaw == ""
+}
+
+// Planner plans topic page URLs from reply counts and falls back to live pagination
+// only when the counts look stale (or when verification is forced by configuration).
+type Planner struct {
+	PostsPerPage int
+	AlwaysVerify bool
+	Fetcher      htmlutil.FetchHTMLer
+	Parser       htmlutil.ParsePaginationLinker
+}
+
+// NewPlanner creates a Planner using the posts-per-page and verification settings from the config.
+// fetcher and parser are only used for live verification and may be nil if it is never needed.
+func NewPlanner(cfg *config.Config, fetcher htmlutil.FetchHTMLer, parser htmlutil.ParsePaginationLinker) *Planner {
+	postsPerPage := cfg.PostsPerPage
+	if postsPerPage <= 0 {
+		log.Printf("[WARNING] PAGEPLAN: PostsPerPage is %d, falling back to default of %d.", cfg.PostsPerPage, DefaultPostsPerPage)
+		postsPerPage = DefaultPostsPerPage
+	}
+	return &Planner{
+		PostsPerPage: postsPerPage,
+		AlwaysVerify: cfg.AlwaysVerifyTopicPages,
+		Fetcher:      fetcher,
+		Parser:       parser,
+	}
+}
+
+// TopicPageURLs returns all page URLs for a topic.
+// If the indexed counts look trustworthy the list is computed without fetching anything.
+// Otherwise the first page is fetched and its pagination links are used to find the
+// highest page; the result is still regenerated from offsets so it is complete and ordered
+// even when the forum collapses the pagination with ellipses.
+func (p *Planner) TopicPageURLs(topic data.Topic) ([]string, error) {
+	if topic.URL == "" {
+		return nil, fmt.Errorf("topic %s has no base URL", topic.ID)
+	}
+
+	plannedCount := PageCount(topic.Replies, p.PostsPerPage)
+	if !p.AlwaysVerify && !CountsLookStale(topic) {
+		log.Printf("[DEBUG] PAGEPLAN: Topic %s planned as %d page(s) from %d replies.", topic.ID, plannedCount, topic.Replies)
+		return pageURLsForCount(topic, plannedCount, p.PostsPerPage)
+	}
+
+	liveCount, err := p.livePageCount(topic)
+	if err != nil {
+		return nil, err
+	}
+	pageCount := plannedCount
+	if liveCount > pageCount {
+		pageCount = liveCount
+	}
+	log.Printf("[DEBUG] PAGEPLAN: Topic %s verified against live pagination: planned %d, live %d, using %d page(s).", topic.ID, plannedCount, liveCount, pageCount)
+	return pageURLsForCount(topic, pageCount, p.PostsPerPage)
+}
+
+// livePageCount fetches the first page of a topic and returns the highest page number
+// referenced by its pagination links.
+func (p *Planner) livePageCount(topic data.Topic) (int, error) {
+	if p.Fetcher == nil || p.Parser == nil {
+		return 0, fmt.Errorf("topic %s needs live pagination verification but no fetcher/parser is configured", topic.ID)
+	}
+
+	firstPageURL, err := PageURL(topic, 1, p.PostsPerPage)
+	if err != nil {
+		return 0, err
+	}
+	firstPageHTML, err := p.Fetcher.FetchHTML(firstPageURL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch first page for topic %s: %w", topic.ID, err)
+	}
+
+	links, err := p.Parser.ParsePaginationLinks(firstPageHTML, firstPageURL)
+	if err != nil {
+		log.Printf("[WARNING] PAGEPLAN: Failed to parse pagination links for topic %s: %v. Assuming a single page.", topic.ID, err)
+		return 1, nil
+	}
+
+	maxPage := 1
+	for _, link := range links {
+		ref, err := canonurl.Parse(link)
+		if err != nil || ref.Kind != canonurl.KindTopic {
+			continue
+		}
+		if string(ref.TopicID) != topic.ID {
+			continue // Pagination selectors also match breadcrumb and other navigation links
+		}
+		if page := ref.Page(p.PostsPerPage); page > maxPage {
+			maxPage = page
+		}
+	}
+	return maxPage, nil
+}
+
+// pageURLsForCount builds the URLs for pages 1..pageCount of a topic.
+func pageURLsForCount(topic data.Topic, pageCount int, postsPerPage int) ([]string, error) {
+	pageURLs := make([]string, 0, pageCount)
+	for pageNum := 1; pageNum <= pageCount; pageNum++ {
+		pageURL, err := PageURL(topic, pageNum, postsPerPage)
+		if err != nil {
+			return nil, err
+		}
+		pageURLs = append(pageURLs, pageURL)
+	}
+	return pageURLs, nil
+}
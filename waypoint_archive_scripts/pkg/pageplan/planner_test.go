@@ -0,0 +1,141 @@
+package pageplan
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"waypoint_archive_scripts/pkg/config"
+	"waypoint_archive_scripts/pkg/data"
+)
+
+type mockFetcher struct {
+	responses map[string]string
+	calls     []string
+}
+
+func (m *mockFetcher) FetchHTML(pageURL string) (string, error) {
+	m.calls = append(m.calls, pageURL)
+	if content, ok := m.responses[pageURL]; ok {
+		return content, nil
+	}
+	return "", fmt.Errorf("mockFetcher: no response for %s", pageURL)
+}
+
+type mockParser struct {
+	links []string
+}
+
+func (m *mockParser) ParsePaginationLinks(htmlContent string, basePageURL string) ([]string, error) {
+	return m.links, nil
+}
+
+func testTopic(replies int, lastPost string) data.Topic {
+	return data.Topic{
+		ID:                   "19618",
+		SubForumID:           "66",
+		URL:                  "https://www.themagiccafe.com/forums/viewtopic.php?topic=19618&forum=66",
+		Replies:              replies,
+		LastPostTimestampRaw: lastPost,
+	}
+}
+
+func TestPageCount(t *testing.T) {
+	tests := []struct {
+		replies, perPage, want int
+	}{
+		{0, 20, 1},
+		{19, 20, 1},
+		{20, 20, 2},
+		{39, 20, 2},
+		{40, 20, 3},
+		{5, 0, 1}, // falls back to DefaultPostsPerPage
+		{-3, 20, 1},
+	}
+	for _, tt := range tests {
+		if got := PageCount(tt.replies, tt.perPage); got != tt.want {
+			t.Errorf("PageCount(%d, %d) = %d, want %d", tt.replies, tt.perPage, got, tt.want)
+		}
+	}
+}
+
+func TestPlanTopicPageURLs(t *testing.T) {
+	got, err := PlanTopicPageURLs(testTopic(45, "Jan 23, 2003 02:45 pm"), 20)
+	if err != nil {
+		t.Fatalf("PlanTopicPageURLs returned error: %v", err)
+	}
+	want := []string{
+		"https://www.themagiccafe.com/forums/viewtopic.php?forum=66&topic=19618",
+		"https://www.themagiccafe.com/forums/viewtopic.php?forum=66&start=20&topic=19618",
+		"https://www.themagiccafe.com/forums/viewtopic.php?forum=66&start=40&topic=19618",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PlanTopicPageURLs() = %v, want %v", got, want)
+	}
+}
+
+func TestPlanner_NoFetchWhenCountsTrusted(t *testing.T) {
+	fetcher := &mockFetcher{}
+	planner := NewPlanner(&config.Config{PostsPerPage: 20}, fetcher, &mockParser{})
+
+	got, err := planner.TopicPageURLs(testTopic(25, "Jan 23, 2003 02:45 pm"))
+	if err != nil {
+		t.Fatalf("TopicPageURLs returned error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("Expected 2 planned pages, got %d: %v", len(got), got)
+	}
+	if len(fetcher.calls) != 0 {
+		t.Errorf("Expected no fetches for a topic with trusted counts, got %v", fetcher.calls)
+	}
+}
+
+func TestPlanner_VerifiesStaleCounts(t *testing.T) {
+	firstPage := "https://www.themagiccafe.com/forums/viewtopic.php?forum=66&topic=19618"
+	fetcher := &mockFetcher{responses: map[string]string{firstPage: "<html></html>"}}
+	parser := &mockParser{links: []string{
+		"https://www.themagiccafe.com/forums/viewtopic.php?topic=19618&forum=66&start=20",
+		"https://www.themagiccafe.com/forums/viewtopic.php?topic=19618&forum=66&start=60", // Last page behind an ellipsis
+		"https://www.themagiccafe.com/forums/viewforum.php?forum=66",
+		"https://www.themagiccafe.com/forums/viewtopic.php?topic=99999&forum=66&start=200", // Unrelated topic
+	}}
+	planner := NewPlanner(&config.Config{PostsPerPage: 20}, fetcher, parser)
+
+	got, err := planner.TopicPageURLs(testTopic(0, ""))
+	if err != nil {
+		t.Fatalf("TopicPageURLs returned error: %v", err)
+	}
+	if len(fetcher.calls) != 1 || fetcher.calls[0] != firstPage {
+		t.Errorf("Expected a single fetch of %s, got %v", firstPage, fetcher.calls)
+	}
+	if len(got) != 4 {
+		t.Fatalf("Expected 4 pages from live pagination, got %d: %v", len(got), got)
+	}
+	if got[2] != "https://www.themagiccafe.com/forums/viewtopic.php?forum=66&start=40&topic=19618" {
+		t.Errorf("Expected gap page 3 to be filled in, got %s", got[2])
+	}
+}
+
+func TestPlanner_AlwaysVerifyKeepsLargerPlannedCount(t *testing.T) {
+	firstPage := "https://www.themagiccafe.com/forums/viewtopic.php?forum=66&topic=19618"
+	fetcher := &mockFetcher{responses: map[string]string{firstPage: "<html></html>"}}
+	planner := NewPlanner(&config.Config{PostsPerPage: 20, AlwaysVerifyTopicPages: true}, fetcher, &mockParser{})
+
+	got, err := planner.TopicPageURLs(testTopic(50, "Jan 23, 2003 02:45 pm"))
+	if err != nil {
+		t.Fatalf("TopicPageURLs returned error: %v", err)
+	}
+	if len(fetcher.calls) != 1 {
+		t.Errorf("Expected verification fetch, got %v", fetcher.calls)
+	}
+	if len(got) != 3 {
+		t.Errorf("Expected planned count of 3 pages to be kept, got %d", len(got))
+	}
+}
+
+func TestPlanner_StaleWithoutFetcher(t *testing.T) {
+	planner := NewPlanner(&config.Config{PostsPerPage: 20}, nil, nil)
+	if _, err := planner.TopicPageURLs(testTopic(0, "")); err == nil {
+		t.Error("Expected an error when verification is needed but no fetcher is configured")
+	}
+}
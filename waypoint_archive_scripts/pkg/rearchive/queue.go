@@ -0,0 +1,141 @@
+// Package rearchive holds the queue of topics to archive again even though the archive progress
+// state marks them as archived, e.g. because extraction found posts or pages missing.
+package rearchive
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry is a topic waiting to be archived again.
+type Entry struct {
+	TopicID    string    `json:"topic_id"`
+	SubForumID string    `json:"sub_forum_id"`
+	Reason     string    `json:"reason"` // Why the topic was queued, e.g. "short: 18 of 23 posts extracted"
+	Source     string    `json:"source"` // What queued it, e.g. "completeness"
+	QueuedAt   time.Time `json:"queued_at"`
+}
+
+// Queue is the set of topics to archive again, keyed by topic ID. The archiver removes a topic
+// once all of its pages have been fetched again.
+type Queue struct {
+	Topics map[string]*Entry `json:"topics"` // TopicID to entry
+
+	mux sync.Mutex
+}
+
+// NewQueue creates an empty Queue.
+func NewQueue() *Queue {
+	return &Queue{Topics: make(map[string]*Entry)}
+}
+
+// LoadQueue loads a queue from filePath. A missing file yields an empty queue.
+func LoadQueue(filePath string) (*Queue, error) {
+	queueBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("[INFO] REARCHIVE: Queue file %s not found. Starting with an empty queue.", filePath)
+			return NewQueue(), nil
+		}
+		return nil, fmt.Errorf("failed to read re-archive queue %s: %w", filePath, err)
+	}
+
+	queue := NewQueue()
+	if len(queueBytes) == 0 {
+		return queue, nil
+	}
+	if err := json.Unmarshal(queueBytes, queue); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal re-archive queue %s: %w", filePath, err)
+	}
+	if queue.Topics == nil {
+		queue.Topics = make(map[string]*Entry)
+	}
+	return queue, nil
+}
+
+// Save writes the queue to filePath atomically via a temporary file.
+func (q *Queue) Save(filePath string) error {
+	q.mux.Lock()
+	queueBytes, err := json.MarshalIndent(q, "", "  ")
+	q.mux.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal re-archive queue: %w", err)
+	}
+
+	dir := filepath.Dir(filePath)
+	if dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory for re-archive queue %s: %w", dir, err)
+		}
+	}
+
+	tempFilePath := filePath + ".tmp"
+	if err := os.WriteFile(tempFilePath, queueBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write re-archive queue to temporary file %s: %w", tempFilePath, err)
+	}
+	if err := os.Rename(tempFilePath, filePath); err != nil {
+		return fmt.Errorf("failed to rename temporary re-archive queue %s to %s: %w", tempFilePath, filePath, err)
+	}
+	return nil
+}
+
+// Add queues a topic. A topic that is already queued keeps its place but takes the new reason.
+// QueuedAt defaults to now. It reports whether the topic was newly queued.
+func (q *Queue) Add(entry Entry) bool {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	if entry.QueuedAt.IsZero() {
+		entry.QueuedAt = time.Now().UTC()
+	}
+	if existing, ok := q.Topics[entry.TopicID]; ok {
+		existing.Reason = entry.Reason
+		existing.Source = entry.Source
+		return false
+	}
+	q.Topics[entry.TopicID] = &entry
+	log.Printf("[INFO] REARCHIVE: Topic %s (sub-forum %s) queued for re-archiving: %s", entry.TopicID, entry.SubForumID, entry.Reason)
+	return true
+}
+
+// Contains reports whether topicID is queued.
+func (q *Queue) Contains(topicID string) bool {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	_, ok := q.Topics[topicID]
+	return ok
+}
+
+// Remove takes topicID off the queue and reports whether it was queued.
+func (q *Queue) Remove(topicID string) bool {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if _, ok := q.Topics[topicID]; !ok {
+		return false
+	}
+	delete(q.Topics, topicID)
+	return true
+}
+
+// Entries returns the queued topics, oldest first.
+func (q *Queue) Entries() []Entry {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	entries := make([]Entry, 0, len(q.Topics))
+	for _, entry := range q.Topics {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].QueuedAt.Equal(entries[j].QueuedAt) {
+			return entries[i].QueuedAt.Before(entries[j].QueuedAt)
+		}
+		return entries[i].TopicID < entries[j].TopicID
+	})
+	return entries
+}
@@ -0,0 +1,63 @@
+package rearchive
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQueue_AddRemove(t *testing.T) {
+	queue := NewQueue()
+	queuedAt := time.Date(2026, time.October, 18, 9, 0, 0, 0, time.UTC)
+
+	if !queue.Add(Entry{TopicID: "t2", SubForumID: "sf1", Reason: "short", QueuedAt: queuedAt.Add(time.Minute)}) {
+		t.Error("Expected t2 to be newly queued")
+	}
+	queue.Add(Entry{TopicID: "t1", SubForumID: "sf1", Reason: "missing page 2", QueuedAt: queuedAt})
+	if queue.Add(Entry{TopicID: "t2", SubForumID: "sf1", Reason: "short again"}) {
+		t.Error("Expected queuing t2 a second time to report false")
+	}
+
+	entries := queue.Entries()
+	if len(entries) != 2 || entries[0].TopicID != "t1" || entries[1].TopicID != "t2" {
+		t.Fatalf("Entries() = %+v, want t1 then t2", entries)
+	}
+	if entries[1].Reason != "short again" || !entries[1].QueuedAt.Equal(queuedAt.Add(time.Minute)) {
+		t.Errorf("Requeued entry = %+v, want the new reason and the original QueuedAt", entries[1])
+	}
+
+	if !queue.Contains("t1") || !queue.Remove("t1") {
+		t.Error("Expected t1 to be queued and removed")
+	}
+	if queue.Contains("t1") || queue.Remove("t1") {
+		t.Error("Expected t1 to be gone after Remove")
+	}
+}
+
+func TestQueue_SaveAndLoad(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "state", "rearchive_queue.json")
+
+	queue, err := LoadQueue(filePath)
+	if err != nil {
+		t.Fatalf("LoadQueue on a missing file: %v", err)
+	}
+	if len(queue.Topics) != 0 {
+		t.Fatalf("Expected an empty queue, got %d topics", len(queue.Topics))
+	}
+
+	queue.Add(Entry{TopicID: "19618", SubForumID: "66", Reason: "short: 18 of 23 posts extracted", Source: "completeness"})
+	if err := queue.Save(filePath); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := LoadQueue(filePath)
+	if err != nil {
+		t.Fatalf("LoadQueue: %v", err)
+	}
+	if !loaded.Contains("19618") {
+		t.Fatal("Expected topic 19618 in the loaded queue")
+	}
+	if entry := loaded.Topics["19618"]; entry.SubForumID != "66" || entry.Source != "completeness" {
+		t.Errorf("Loaded entry = %+v", entry)
+	}
+}
@@ -0,0 +1,270 @@
+// Package respcache is a persistent, on-disk cache of fetched forum pages shared by the indexer,
+// JIT refresh and the archiver, so a page fetched by one of them is not fetched again by another
+// while it is still fresh. Entries are keyed on the canonical URL and expire after a TTL chosen
+// by the URL's class: sub-forum listings change often, topic pages much less.
+//
+// Bodies are kept byte for byte, and listing and topic pages are only cached when the forum
+// adapter finds topics or posts in them, so the error pages the forum serves with 200 OK are
+// fetched again next time rather than replayed for a whole TTL.
+package respcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"waypoint_archive_scripts/pkg/canonurl"
+	"waypoint_archive_scripts/pkg/config"
+	"waypoint_archive_scripts/pkg/forumadapter"
+)
+
+// Class groups URLs that share a TTL.
+type Class string
+
+const (
+	ClassListing Class = "listing" // Sub-forum listing pages (viewforum.php)
+	ClassTopic   Class = "topic"   // Topic pages and post links (viewtopic.php)
+	ClassOther   Class = "other"   // Everything else, e.g. the forum index
+)
+
+// Entry is one cached page.
+type Entry struct {
+	URL       string    `json:"url"`                 // Canonical URL the entry is keyed on
+	FinalURL  string    `json:"final_url,omitempty"` // URL the page was served from after redirects, if different
+	Class     Class     `json:"class"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Body      []byte    `json:"body"` // Page content as returned by the fetcher that stored it (base64 in JSON)
+}
+
+// Redirected reports whether the cached page was served from a different URL than requested.
+func (e Entry) Redirected() bool {
+	return e.FinalURL != "" && e.FinalURL != e.URL
+}
+
+// Cache is a directory of cached pages. A nil *Cache is valid and caches nothing, so fetchers
+// can call it unconditionally.
+type Cache struct {
+	dir  string
+	ttls map[Class]time.Duration
+	now  func() time.Time
+
+	mux    sync.Mutex
+	hits   int
+	misses int
+}
+
+// NewCache opens (creating if needed) the cache directory dir. A class missing from ttls, or
+// with a TTL that is not positive, is never cached.
+func NewCache(dir string, ttls map[Class]time.Duration) (*Cache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("respcache: cache directory is empty")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create response cache directory %s: %w", dir, err)
+	}
+	copied := make(map[Class]time.Duration, len(ttls))
+	for class, ttl := range ttls {
+		copied[class] = ttl
+	}
+	return &Cache{dir: dir, ttls: copied, now: time.Now}, nil
+}
+
+// FromConfig opens the cache configured in cfg. It returns nil when no cache directory is set.
+func FromConfig(cfg *config.Config) (*Cache, error) {
+	if cfg.ResponseCacheDir == "" {
+		return nil, nil
+	}
+	cache, err := NewCache(cfg.ResponseCacheDir, map[Class]time.Duration{
+		ClassListing: cfg.ResponseCacheListingTTL,
+		ClassTopic:   cfg.ResponseCacheTopicTTL,
+		ClassOther:   cfg.ResponseCacheOtherTTL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] RESPCACHE: Using response cache %s (listing TTL %s, topic TTL %s, other TTL %s)",
+		cfg.ResponseCacheDir, cfg.ResponseCacheListingTTL, cfg.ResponseCacheTopicTTL, cfg.ResponseCacheOtherTTL)
+	return cache, nil
+}
+
+// ClassOf returns the class of rawURL.
+func ClassOf(rawURL string) Class {
+	ref, err := canonurl.Parse(rawURL)
+	if err != nil {
+		return ClassOther
+	}
+	switch ref.Kind {
+	case canonurl.KindForum:
+		return ClassListing
+	case canonurl.KindTopic, canonurl.KindPost:
+		return ClassTopic
+	}
+	return ClassOther
+}
+
+// Key returns the canonical form of rawURL that entries are keyed on.
+func Key(rawURL string) string {
+	if canonical, err := canonurl.Canonicalize(rawURL); err == nil {
+		return canonical
+	}
+	return rawURL
+}
+
+// Get returns the fresh entry for rawURL, if there is one.
+func (c *Cache) Get(rawURL string) (Entry, bool) {
+	if c == nil {
+		return Entry{}, false
+	}
+	key := Key(rawURL)
+	class := ClassOf(key)
+	ttl := c.ttls[class]
+	if ttl <= 0 {
+		return Entry{}, false
+	}
+
+	var entry Entry
+	entryBytes, err := os.ReadFile(c.entryPath(class, key))
+	if err == nil {
+		err = json.Unmarshal(entryBytes, &entry)
+	}
+	if err != nil || entry.URL != key || c.now().Sub(entry.FetchedAt) > ttl {
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("[WARNING] RESPCACHE: Ignoring unreadable cache entry for %s: %v", key, err)
+		}
+		c.count(false)
+		return Entry{}, false
+	}
+	c.count(true)
+	return entry, true
+}
+
+// Put stores a freshly fetched page for rawURL. finalURL is where the page was actually served
+// from and may be empty. Failures are logged, never returned: the cache is only an optimisation.
+func (c *Cache) Put(rawURL string, finalURL string, body []byte) {
+	if c == nil {
+		return
+	}
+	key := Key(rawURL)
+	class := ClassOf(key)
+	if c.ttls[class] <= 0 {
+		return
+	}
+	if !holdsContent(class, key, body) {
+		log.Printf("[DEBUG] RESPCACHE: Not caching %s: no %s content found, probably a forum error page", key, class)
+		return
+	}
+	entry := Entry{URL: key, Class: class, FetchedAt: c.now().UTC(), Body: body}
+	if finalURL != "" {
+		if finalKey := Key(finalURL); finalKey != key {
+			entry.FinalURL = finalKey
+		}
+	}
+	if err := c.write(class, key, entry); err != nil {
+		log.Printf("[WARNING] RESPCACHE: Failed to cache %s: %v", key, err)
+	}
+}
+
+// Fetch returns the cached page for rawURL, or calls fetch and caches what it returns.
+func (c *Cache) Fetch(rawURL string, fetch func() (string, error)) (string, error) {
+	if entry, ok := c.Get(rawURL); ok {
+		log.Printf("[DEBUG] RESPCACHE: Serving %s from cache (fetched %s)", entry.URL, entry.FetchedAt.Format(time.RFC3339))
+		return string(entry.Body), nil
+	}
+	return c.Refresh(rawURL, fetch)
+}
+
+// Refresh always calls fetch and caches what it returns, replacing any entry for rawURL.
+// It is for callers that must see the live page, such as a re-scan looking for bumped topics.
+func (c *Cache) Refresh(rawURL string, fetch func() (string, error)) (string, error) {
+	body, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	c.Put(rawURL, "", []byte(body))
+	return body, nil
+}
+
+// holdsContent reports whether body is a real page of its class: a listing with at least one
+// topic, or a topic page with at least one post, as the forum adapter finds them. Other classes
+// are not checked.
+func holdsContent(class Class, key string, body []byte) bool {
+	if class != ClassListing && class != ClassTopic {
+		return true
+	}
+	doc, err := forumadapter.ParseHTML(string(body))
+	if err != nil {
+		return false
+	}
+	adapter := forumadapter.Default()
+	if class == ClassTopic {
+		return adapter.PostBlocks(doc).Length() > 0
+	}
+	ref, err := canonurl.Parse(key)
+	if err != nil {
+		return false
+	}
+	topics, err := adapter.ExtractTopics(doc, key, string(ref.SubForumID))
+	return err == nil && len(topics) > 0
+}
+
+// Stats returns the number of lookups served from the cache and the number that missed.
+func (c *Cache) Stats() (hits int, misses int) {
+	if c == nil {
+		return 0, 0
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.hits, c.misses
+}
+
+func (c *Cache) count(hit bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+}
+
+// entryPath returns the file holding the entry for key: <dir>/<class>/<sha256 of key>.json.
+func (c *Cache) entryPath(class Class, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, string(class), hex.EncodeToString(sum[:])+".json")
+}
+
+// write saves entry atomically, so concurrent readers never see a partial file.
+func (c *Cache) write(class Class, key string, entry Entry) error {
+	entryPath := c.entryPath(class, key)
+	if err := os.MkdirAll(filepath.Dir(entryPath), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(entryPath), filepath.Base(entryPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary cache entry: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	_, err = tmpFile.Write(entryBytes)
+	if errClose := tmpFile.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write cache entry %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, entryPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename cache entry %s: %w", tmpPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package respcache
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"waypoint_archive_scripts/pkg/config"
+)
+
+const (
+	listingURL = "https://www.themagiccafe.com/forums/viewforum.php?forum=66"
+	topicURL   = "https://www.themagiccafe.com/forums/viewtopic.php?topic=19618&forum=66"
+
+	listingPage = `<html><body><div id="container"><table class="normal"><tr>
+<td class="normal bgc1 c w5"></td>
+<td class="normal bgc2"><a class="b" href="viewtopic.php?topic=19618&amp;forum=66">A topic</a></td>
+</tr></table></div></body></html>`
+	topicPage = `<html><body><div id="container">
+<table class="normal"><tr><td class="w99 mltext"><a href="index.php">Index</a></td></tr></table>
+<table class="normal"><tr>
+<td class="normal bgc1 c w13 vat"><strong>Bob</strong></td>
+<td class="normal bgc1 vat w90"><div class="vt1 liketext"><div class="like_left">Posted: <span class="b">Jan 23, 2003 02:45 pm</span></div></div><div class="w100">Reply</div></td>
+</tr></table></div></body></html>`
+	// busyPage is the error page the forum serves with 200 OK when it is overloaded
+	busyPage = `<html><body><div id="container"><table class="normal">
+<tr><td class="normal bgc1 c">Sorry, the Magic Cafe is very busy right now. Please try again in a few moments.</td></tr>
+</table></div></body></html>`
+)
+
+func newTestCache(t *testing.T, ttls map[Class]time.Duration) (*Cache, *time.Time) {
+	t.Helper()
+	cache, err := NewCache(t.TempDir(), ttls)
+	if err != nil {
+		t.Fatalf("NewCache returned error: %v", err)
+	}
+	now := time.Date(2003, time.January, 10, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	return cache, &now
+}
+
+func TestClassOf(t *testing.T) {
+	tests := map[string]Class{
+		listingURL: ClassListing,
+		topicURL:   ClassTopic,
+		"https://www.themagiccafe.com/forums/viewtopic.php?topic=1&forum=2&post=3": ClassTopic,
+		"https://www.themagiccafe.com/forums/index.php":                            ClassOther,
+		"://bad": ClassOther,
+	}
+	for rawURL, want := range tests {
+		if got := ClassOf(rawURL); got != want {
+			t.Errorf("ClassOf(%q) = %s, want %s", rawURL, got, want)
+		}
+	}
+}
+
+func TestGetPut_TTLPerClass(t *testing.T) {
+	cache, now := newTestCache(t, map[Class]time.Duration{ClassListing: 30 * time.Minute, ClassTopic: 6 * time.Hour})
+	cache.Put(listingURL, "", []byte(listingPage))
+	cache.Put(topicURL, "", []byte(topicPage))
+	cache.Put("https://www.themagiccafe.com/forums/index.php", "", []byte("index")) // ClassOther has no TTL
+
+	if entry, ok := cache.Get("http://themagiccafe.com/forums/viewforum.php?forum=66&sid=abc"); !ok || string(entry.Body) != listingPage {
+		t.Errorf("Expected the listing under its canonical URL, got %+v, %t", entry, ok)
+	}
+	if _, ok := cache.Get("https://www.themagiccafe.com/forums/index.php"); ok {
+		t.Error("Expected a class without a TTL never to be cached")
+	}
+
+	*now = now.Add(time.Hour)
+	if _, ok := cache.Get(listingURL); ok {
+		t.Error("Expected the listing to have expired after its TTL")
+	}
+	if entry, ok := cache.Get(topicURL); !ok || string(entry.Body) != topicPage || entry.Class != ClassTopic {
+		t.Errorf("Expected the topic page to still be fresh, got %+v, %t", entry, ok)
+	}
+	if hits, misses := cache.Stats(); hits != 2 || misses != 1 { // Uncached classes are not lookups
+		t.Errorf("Stats = %d hits, %d misses; want 2, 1", hits, misses)
+	}
+}
+
+func TestPut_RecordsRedirect(t *testing.T) {
+	cache, _ := newTestCache(t, map[Class]time.Duration{ClassTopic: time.Hour})
+	movedTo := "https://www.themagiccafe.com/forums/viewtopic.php?topic=19618&forum=54"
+	cache.Put(topicURL, movedTo, []byte(topicPage))
+	cache.Put(listingURL, listingURL, []byte(listingPage))
+
+	entry, ok := cache.Get(topicURL)
+	if !ok || !entry.Redirected() || entry.FinalURL != Key(movedTo) {
+		t.Errorf("Expected a redirected entry pointing at %s, got %+v", Key(movedTo), entry)
+	}
+}
+
+func TestFetchAndRefresh(t *testing.T) {
+	cache, _ := newTestCache(t, map[Class]time.Duration{ClassListing: time.Hour})
+	calls := 0
+	fetch := func() (string, error) {
+		calls++
+		return listingPage, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if body, err := cache.Fetch(listingURL, fetch); err != nil || body != listingPage {
+			t.Fatalf("Fetch returned %q, %v", body, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected Fetch to call through once, got %d calls", calls)
+	}
+	if _, err := cache.Refresh(listingURL, fetch); err != nil || calls != 2 {
+		t.Errorf("Expected Refresh to always call through, got %d calls, %v", calls, err)
+	}
+
+	failing := func() (string, error) { return "", errors.New("busy") }
+	if _, err := cache.Refresh(listingURL, failing); err == nil {
+		t.Error("Expected Refresh to return the fetch error")
+	}
+	if entry, ok := cache.Get(listingURL); !ok || string(entry.Body) != listingPage {
+		t.Error("Expected a failed fetch to leave the cached page alone")
+	}
+}
+
+func TestNilCacheAndFromConfig(t *testing.T) {
+	var cache *Cache
+	cache.Put(listingURL, "", []byte(listingPage))
+	if _, ok := cache.Get(listingURL); ok {
+		t.Error("Expected a nil cache to hold nothing")
+	}
+	calls := 0
+	if _, err := cache.Fetch(listingURL, func() (string, error) { calls++; return "page", nil }); err != nil || calls != 1 {
+		t.Errorf("Expected a nil cache to call through, got %d calls, %v", calls, err)
+	}
+
+	if cache, err := FromConfig(&config.Config{}); cache != nil || err != nil {
+		t.Errorf("Expected no cache without a directory, got %v, %v", cache, err)
+	}
+	cache, err := FromConfig(&config.Config{ResponseCacheDir: t.TempDir(), ResponseCacheListingTTL: time.Minute})
+	if err != nil || cache == nil {
+		t.Fatalf("FromConfig returned %v, %v", cache, err)
+	}
+	cache.Put(listingURL, "", []byte(listingPage))
+	cache.Put(topicURL, "", []byte(topicPage)) // Topic TTL left at zero
+	if _, ok := cache.Get(listingURL); !ok {
+		t.Error("Expected the listing to be cached")
+	}
+	if _, ok := cache.Get(topicURL); ok {
+		t.Error("Expected topic pages not to be cached with a zero TTL")
+	}
+}
+
+func TestPut_KeepsBytesAndSkipsErrorPages(t *testing.T) {
+	cache, _ := newTestCache(t, map[Class]time.Duration{ClassListing: time.Hour, ClassTopic: time.Hour, ClassOther: time.Hour})
+
+	// A page served as ISO-8859-1 and stored undecoded is not valid UTF-8
+	latin1 := []byte(strings.Replace(topicPage, "Reply", "Caf\xe9 \xa9 2003", 1))
+	cache.Put(topicURL, "", latin1)
+	if entry, ok := cache.Get(topicURL); !ok || !bytes.Equal(entry.Body, latin1) {
+		t.Errorf("Expected the body back byte for byte, got %q, %t", entry.Body, ok)
+	}
+
+	cache.Put(listingURL, "", []byte(busyPage))
+	if _, ok := cache.Get(listingURL); ok {
+		t.Error("Expected a listing without topics (the busy page) not to be cached")
+	}
+	calls := 0
+	fetchBusy := func() (string, error) { calls++; return busyPage, nil }
+	for i := 0; i < 2; i++ {
+		if body, err := cache.Fetch(topicURL+"&start=20", fetchBusy); err != nil || body != busyPage {
+			t.Fatalf("Fetch returned %q, %v", body, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected a topic page without posts to be fetched again, got %d calls", calls)
+	}
+
+	cache.Put("https://www.themagiccafe.com/forums/index.php", "", []byte(busyPage))
+	if _, ok := cache.Get("https://www.themagiccafe.com/forums/index.php"); !ok {
+		t.Error("Expected pages of other classes to be cached without a content check")
+	}
+}
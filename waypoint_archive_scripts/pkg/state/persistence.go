@@ -93,10 +93,10 @@ func (aps *ArchiveProgressState) IsTopicArchived(topicID string) bool {
 	if aps == nil || aps.ArchivedTopics == nil {
 		return false
 	}
-	_, exists := aps.ArchivedTopics[topicID]
-	// For a topic to be considered archived, its entry must exist.
-	// Additional checks (e.g., all pages archived) could be added here if TopicDetail had such a flag.
-	return exists
+	detail, exists := aps.ArchivedTopics[topicID]
+	// Archiving a page creates the topic's entry too; only MarkTopicAsArchived sets ArchivedAt,
+	// so a topic with some of its pages archived is not reported as archived.
+	return exists && !detail.ArchivedAt.IsZero()
 }
 
 // MarkTopicAsArchived marks a topic as fully archived.
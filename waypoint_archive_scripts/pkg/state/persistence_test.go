@@ -100,3 +100,23 @@ func TestSaveAndLoadState(t *testing.T) {
 		t.Errorf("LoadState() with malformed JSON expected error, got nil")
 	}
 }
+
+func TestIsTopicArchived_PartialTopic(t *testing.T) {
+	aps := NewArchiveProgressState()
+	aps.MarkPageAsArchived("100", 1, "https://example.com/viewtopic.php?topic=100")
+	if aps.IsTopicArchived("100") {
+		t.Errorf("Topic with only some pages archived is reported as archived")
+	}
+	if !aps.IsPageArchived("100", 1) || aps.IsPageArchived("100", 2) {
+		t.Errorf("Unexpected page state: %+v", aps.ArchivedTopics["100"])
+	}
+
+	aps.MarkPageAsArchived("100", 2, "https://example.com/viewtopic.php?topic=100&start=20")
+	aps.MarkTopicAsArchived("100")
+	if !aps.IsTopicArchived("100") {
+		t.Errorf("Topic marked as archived is not reported as archived")
+	}
+	if len(aps.ArchivedTopics["100"].ArchivedPages) != 2 {
+		t.Errorf("Marking the topic lost its pages: %+v", aps.ArchivedTopics["100"])
+	}
+}
@@ -1,11 +1,26 @@
 package storer
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
+// TopicAliasFileName is the file written into a topic directory that points at the
+// directory where the topic's pages were actually archived.
+const TopicAliasFileName = "alias.json"
+
+// TopicAlias records that a topic directory is an alias for an archived copy stored elsewhere,
+// e.g. because the topic was moved to another sub-forum after it was archived.
+type TopicAlias struct {
+	SubForumID string    `json:"sub_forum_id"` // Sub-forum directory holding the archived pages
+	TopicID    string    `json:"topic_id"`     // Topic directory holding the archived pages
+	Reason     string    `json:"reason"`       // Lifecycle status that caused the alias (moved, relocated, merged)
+	CreatedAt  time.Time `json:"created_at"`
+}
+
 // Storer handles the saving of HTML content to the file system.
 // It ensures that the directory structure <ArchiveOutputRootDir>/<SubForumID>/<TopicID>/page_<PageNum>.html is used.
 type Storer struct {
@@ -41,3 +56,43 @@ func (s *Storer) SaveTopicHTML(subForumID, topicID string, pageNum int, htmlByte
 	// log.Printf("[DEBUG] Storer: Successfully saved HTML file: %s (Size: %d bytes)", filePath, len(htmlBytes))
 	return filePath, nil
 }
+
+// SaveTopicAlias writes an alias file into <root>/<subForumID>/<topicID>/ pointing at alias's target.
+// Any pages already stored in that directory are left untouched.
+// Returns the full path to the alias file or an error.
+func (s *Storer) SaveTopicAlias(subForumID, topicID string, alias TopicAlias) (string, error) {
+	topicDir := filepath.Join(s.ArchiveOutputRootDir, subForumID, topicID)
+	if err := os.MkdirAll(topicDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create topic directory %s: %w", topicDir, err)
+	}
+
+	aliasBytes, err := json.MarshalIndent(alias, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal topic alias for %s/%s: %w", subForumID, topicID, err)
+	}
+
+	filePath := filepath.Join(topicDir, TopicAliasFileName)
+	if err := os.WriteFile(filePath, aliasBytes, 0644); err != nil {
+		return "", fmt.Errorf("failed to write topic alias file %s: %w", filePath, err)
+	}
+	return filePath, nil
+}
+
+// LoadTopicAlias reads the alias file of a topic directory.
+// It returns nil and no error if the directory has no alias.
+func (s *Storer) LoadTopicAlias(subForumID, topicID string) (*TopicAlias, error) {
+	filePath := filepath.Join(s.ArchiveOutputRootDir, subForumID, topicID, TopicAliasFileName)
+	aliasBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read topic alias file %s: %w", filePath, err)
+	}
+
+	var alias TopicAlias
+	if err := json.Unmarshal(aliasBytes, &alias); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal topic alias file %s: %w", filePath, err)
+	}
+	return &alias, nil
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSaveTopicHTML_Success(t *testing.T) {
@@ -192,3 +193,39 @@ func TestSaveTopicHTML_Error_WriteFileFails(t *testing.T) {
 		t.Errorf("Expected error message to contain '%s', got '%s'", expectedErrorMsgPart, err.Error())
 	}
 }
+
+func TestSaveTopicAlias_RoundTrip(t *testing.T) {
+	tempDir := t.TempDir()
+	storerInstance := NewStorer(tempDir)
+
+	if _, err := storerInstance.SaveTopicHTML("sf1", "t1", 1, []byte("<html>original</html>")); err != nil {
+		t.Fatalf("SaveTopicHTML failed: %v", err)
+	}
+
+	alias := TopicAlias{SubForumID: "sf1", TopicID: "t1", Reason: "relocated", CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	aliasPath, err := storerInstance.SaveTopicAlias("sf2", "t1", alias)
+	if err != nil {
+		t.Fatalf("SaveTopicAlias failed: %v", err)
+	}
+	if want := filepath.Join(tempDir, "sf2", "t1", TopicAliasFileName); aliasPath != want {
+		t.Errorf("Expected alias path %s, got %s", want, aliasPath)
+	}
+
+	loaded, err := storerInstance.LoadTopicAlias("sf2", "t1")
+	if err != nil {
+		t.Fatalf("LoadTopicAlias failed: %v", err)
+	}
+	if loaded == nil || *loaded != alias {
+		t.Errorf("Loaded alias = %#v, want %#v", loaded, alias)
+	}
+
+	// The original archived copy must stay where it was.
+	if _, err := os.Stat(filepath.Join(tempDir, "sf1", "t1", "page_1.html")); err != nil {
+		t.Errorf("Original page missing after alias creation: %v", err)
+	}
+
+	none, err := storerInstance.LoadTopicAlias("sf1", "t1")
+	if err != nil || none != nil {
+		t.Errorf("LoadTopicAlias for a directory without alias = %#v, %v; want nil, nil", none, err)
+	}
+}